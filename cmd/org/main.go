@@ -4,11 +4,20 @@ import (
 	"fmt"
 	"orglang/pkg/cmd"
 	"os"
+	"runtime/debug"
 )
 
 func main() {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "internal error: %v\n%s", r, debug.Stack())
+			os.Exit(cmd.ExitInternal)
+		}
+	}()
 	if err := cmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		if !cmd.Reported(err) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		os.Exit(cmd.ExitCode(err))
 	}
 }
@@ -0,0 +1,80 @@
+// Package examples embeds the example programs of the repository in the
+// org binary, for org examples. Each file opens with a comment naming it
+// and one giving its topic:
+//
+//	# 01_basics.org
+//	# Basic arithmetic and variable assignment
+package examples
+
+import (
+	"embed"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.org
+var files embed.FS
+
+// Example is one of the example programs.
+type Example struct {
+	Name  string // the file name without its number and extension: basics
+	File  string // 01_basics.org
+	Topic string // what the example shows, from its header comment
+}
+
+// List returns the examples in file order, which is the order to read
+// them in.
+func List() []Example {
+	entries, _ := files.ReadDir(".")
+	var out []Example
+	for _, e := range entries {
+		src, _ := files.ReadFile(e.Name())
+		out = append(out, Example{Name: name(e.Name()), File: e.Name(), Topic: topic(src)})
+	}
+	return out
+}
+
+// Find returns the example called name: its name (basics), its file with
+// or without the extension (01_basics.org, 01_basics) or its number (1).
+func Find(name string) (Example, bool) {
+	n := atoi(name)
+	for _, e := range List() {
+		num, _, _ := strings.Cut(e.File, "_")
+		if name == e.Name || name == e.File || name+".org" == e.File || n >= 0 && atoi(num) == n {
+			return e, true
+		}
+	}
+	return Example{}, false
+}
+
+// Source returns the source of e.
+func (e Example) Source() []byte {
+	src, _ := files.ReadFile(e.File)
+	return src
+}
+
+// name strips the number and extension of an example's file name.
+func name(file string) string {
+	base := strings.TrimSuffix(file, ".org")
+	if num, rest, ok := strings.Cut(base, "_"); ok && atoi(num) > 0 {
+		return rest
+	}
+	return base
+}
+
+// topic returns the second line of the header comment of src.
+func topic(src []byte) string {
+	lines := strings.SplitN(string(src), "\n", 3)
+	if len(lines) < 2 || !strings.HasPrefix(lines[1], "#") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(lines[1], "#"))
+}
+
+func atoi(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,36 @@
+package examples
+
+import (
+	"testing"
+
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+)
+
+func TestList(t *testing.T) {
+	list := List()
+	if len(list) == 0 {
+		t.Fatal("no examples embedded")
+	}
+	for _, e := range list {
+		if e.Topic == "" {
+			t.Errorf("%s: no topic on the second line of its header", e.File)
+		}
+		p := parser.New(lexer.New(e.Source()), parser.WithStrict(true))
+		p.ParseProgram()
+		if errs := p.Errors(); len(errs) > 0 {
+			t.Errorf("%s does not parse: %v", e.File, errs)
+		}
+	}
+	if first := list[0]; first.File != "01_basics.org" || first.Name != "basics" {
+		t.Errorf("first example = %+v", first)
+	}
+	for _, name := range []string{"basics", "01_basics", "01_basics.org", "1", "01"} {
+		if e, ok := Find(name); !ok || e.File != "01_basics.org" {
+			t.Errorf("Find(%q) = %+v, %v", name, e, ok)
+		}
+	}
+	if e, ok := Find("nothing"); ok {
+		t.Errorf("Find(nothing) = %+v", e)
+	}
+}
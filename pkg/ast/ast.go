@@ -8,8 +8,33 @@ import (
 // Node interface for all AST nodes
 type Node interface {
 	String() string
+	// Location returns the source range of the node, set by the parser.
+	Location() Span
+	SetLocation(Span)
 }
 
+// Pos is a position in the source: 1-indexed line and column, as in
+// token.Token, and the byte offset.
+type Pos struct {
+	Line, Column, Offset int
+}
+
+// Span is the source range of a node, from the first byte of its first
+// token to just past its last one. Every node embeds it; nodes built
+// outside the parser have the zero Span.
+type Span struct {
+	Start, End Pos
+}
+
+func (s Span) Location() Span      { return s }
+func (s *Span) SetLocation(l Span) { *s = l }
+
+// IsZero reports whether s was never set.
+func (s Span) IsZero() bool { return s == Span{} }
+
+// Contains reports whether the byte offset off lies within s.
+func (s Span) Contains(off int) bool { return s.Start.Offset <= off && off < s.End.Offset }
+
 // Statement interface (for now, same as Node, but semantic distinction)
 type Statement interface {
 	Node
@@ -24,6 +49,7 @@ type Expression interface {
 
 // Program node
 type Program struct {
+	Span
 	Statements []Statement
 }
 
@@ -39,6 +65,7 @@ func (p *Program) String() string {
 // --- Literals ---
 
 type IntegerLiteral struct {
+	Span
 	Value string
 }
 
@@ -47,6 +74,7 @@ func (il *IntegerLiteral) expressionNode() {}
 func (il *IntegerLiteral) statementNode()  {}
 
 type DecimalLiteral struct {
+	Span
 	Value string
 }
 
@@ -55,6 +83,7 @@ func (dl *DecimalLiteral) expressionNode() {}
 func (dl *DecimalLiteral) statementNode()  {}
 
 type RationalLiteral struct {
+	Span
 	Numerator   string
 	Denominator string
 }
@@ -63,7 +92,20 @@ func (rl *RationalLiteral) String() string  { return fmt.Sprintf("%s/%s", rl.Num
 func (rl *RationalLiteral) expressionNode() {}
 func (rl *RationalLiteral) statementNode()  {}
 
+// QuantityLiteral is a number with a unit: a duration (250ms) or a size
+// (10KB).
+type QuantityLiteral struct {
+	Span
+	Value string // the number, as written: 250
+	Unit  string // the unit suffix: ms
+}
+
+func (ql *QuantityLiteral) String() string  { return ql.Value + ql.Unit }
+func (ql *QuantityLiteral) expressionNode() {}
+func (ql *QuantityLiteral) statementNode()  {}
+
 type StringLiteral struct {
+	Span
 	Value string
 	IsDoc bool
 	IsRaw bool
@@ -81,7 +123,34 @@ func (sl *StringLiteral) String() string {
 func (sl *StringLiteral) expressionNode() {}
 func (sl *StringLiteral) statementNode()  {}
 
+// InterpolatedString is "text${expr}text...": the texts around the
+// expressions, one more than the expressions.
+type InterpolatedString struct {
+	Span
+	Texts []string
+	Exprs []Expression
+}
+
+func (is *InterpolatedString) String() string {
+	var out strings.Builder
+	out.WriteString(`"`)
+	for i, t := range is.Texts {
+		if i > 0 {
+			out.WriteString("}")
+		}
+		out.WriteString(t)
+		if i < len(is.Exprs) {
+			out.WriteString("${" + is.Exprs[i].String())
+		}
+	}
+	out.WriteString(`"`)
+	return out.String()
+}
+func (is *InterpolatedString) expressionNode() {}
+func (is *InterpolatedString) statementNode()  {}
+
 type BooleanLiteral struct {
+	Span
 	Value bool
 }
 
@@ -91,6 +160,7 @@ func (bl *BooleanLiteral) statementNode()  {}
 
 // FunctionLiteral represents { ... } or N{ ... }M
 type FunctionLiteral struct {
+	Span
 	LBP  *int // Leading Binding Power (optional)
 	Body []Statement
 	RBP  *int // Right Binding Power (optional)
@@ -119,6 +189,7 @@ func (fl *FunctionLiteral) statementNode()  {}
 
 // TableLiteral represents [...]
 type TableLiteral struct {
+	Span
 	Elements []Expression
 }
 
@@ -140,6 +211,7 @@ func (tl *TableLiteral) statementNode()  {}
 // --- Expressions ---
 
 type Name struct {
+	Span
 	Value string
 }
 
@@ -148,6 +220,7 @@ func (n *Name) expressionNode() {}
 func (n *Name) statementNode()  {}
 
 type PrefixExpr struct {
+	Span
 	Op    string
 	Right Expression
 }
@@ -159,6 +232,7 @@ func (pe *PrefixExpr) expressionNode() {}
 func (pe *PrefixExpr) statementNode()  {}
 
 type InfixExpr struct {
+	Span
 	Left  Expression
 	Op    string
 	Right Expression
@@ -172,6 +246,7 @@ func (ie *InfixExpr) statementNode()  {}
 
 // DotExpr represents left.key
 type DotExpr struct {
+	Span
 	Left Expression
 	Key  Expression
 }
@@ -184,6 +259,7 @@ func (de *DotExpr) statementNode()  {}
 
 // BindingExpr represents name : value or name :+ value
 type BindingExpr struct {
+	Span
 	Name     Expression
 	Operator string // ":" by default, or ":+", ":-", etc.
 	Value    Expression
@@ -201,6 +277,7 @@ func (be *BindingExpr) statementNode()  {}
 
 // ResourceDef represents name @: value
 type ResourceDef struct {
+	Span
 	Name  Expression
 	Value Expression
 }
@@ -213,6 +290,7 @@ func (rd *ResourceDef) statementNode()  {}
 
 // ResourceInst represents @name
 type ResourceInst struct {
+	Span
 	Name Expression
 }
 
@@ -224,6 +302,7 @@ func (ri *ResourceInst) statementNode()  {}
 
 // ElvisExpr represents left ?: right
 type ElvisExpr struct {
+	Span
 	Left  Expression
 	Right Expression
 }
@@ -234,8 +313,40 @@ func (ee *ElvisExpr) String() string {
 func (ee *ElvisExpr) expressionNode() {}
 func (ee *ElvisExpr) statementNode()  {}
 
+// GuardExpr represents cond !> value, a statement of a block returning
+// value from the block when cond holds.
+type GuardExpr struct {
+	Span
+	Cond  Expression
+	Value Expression
+}
+
+func (ge *GuardExpr) String() string {
+	return fmt.Sprintf("(%s !> %s)", ge.Cond.String(), ge.Value.String())
+}
+func (ge *GuardExpr) expressionNode() {}
+func (ge *GuardExpr) statementNode()  {}
+
+// AssertExpr represents assert cond "message", a check that cond holds,
+// made by debug builds only.
+type AssertExpr struct {
+	Span
+	Cond    Expression
+	Message Expression // a string, or nil
+}
+
+func (ae *AssertExpr) String() string {
+	if ae.Message == nil {
+		return fmt.Sprintf("(assert %s)", ae.Cond.String())
+	}
+	return fmt.Sprintf("(assert %s %s)", ae.Cond.String(), ae.Message.String())
+}
+func (ae *AssertExpr) expressionNode() {}
+func (ae *AssertExpr) statementNode()  {}
+
 // CommaExpr represents left, right
 type CommaExpr struct {
+	Span
 	Left  Expression
 	Right Expression
 }
@@ -248,6 +359,7 @@ func (ce *CommaExpr) statementNode()  {}
 
 // GroupExpr represents (inner)
 type GroupExpr struct {
+	Span
 	Inner Expression
 }
 
@@ -262,6 +374,7 @@ func (ge *GroupExpr) statementNode()  {}
 
 // ErrorExpr represents a parsing error or undefined identifier
 type ErrorExpr struct {
+	Span
 	Message string
 }
 
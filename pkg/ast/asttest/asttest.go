@@ -0,0 +1,243 @@
+// Package asttest builds syntax trees and compares them, for tests of the
+// parser and of the code consuming its trees. A tree built with Int,
+// Infix and the other builders is compared with a parsed one by Diff,
+// which ignores source spans and names each difference by its path in
+// the tree, so a failing test says where the trees part rather than
+// printing two long strings.
+//
+//	asttest.Equal(t, prog, asttest.Program(
+//		asttest.Bind(asttest.Name("x"), asttest.Infix("+", asttest.Int("1"), asttest.Int("2"))),
+//	))
+package asttest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"orglang/pkg/ast"
+)
+
+// Program returns a program of stmts.
+func Program(stmts ...ast.Statement) *ast.Program {
+	return &ast.Program{Statements: stmts}
+}
+
+// Int returns the integer literal written v.
+func Int(v string) *ast.IntegerLiteral { return &ast.IntegerLiteral{Value: v} }
+
+// Dec returns the decimal literal written v.
+func Dec(v string) *ast.DecimalLiteral { return &ast.DecimalLiteral{Value: v} }
+
+// Rat returns the rational literal num/den.
+func Rat(num, den string) *ast.RationalLiteral {
+	return &ast.RationalLiteral{Numerator: num, Denominator: den}
+}
+
+// Quantity returns the literal of the number v with a unit: 250 ms.
+func Quantity(v, unit string) *ast.QuantityLiteral {
+	return &ast.QuantityLiteral{Value: v, Unit: unit}
+}
+
+// Str returns a double-quoted string literal.
+func Str(v string) *ast.StringLiteral { return &ast.StringLiteral{Value: v} }
+
+// Interpolated returns the string of texts around exprs, one more text
+// than expressions: Interpolated([]string{"a", "b"}, Name("x")) is "a${x}b".
+func Interpolated(texts []string, exprs ...ast.Expression) *ast.InterpolatedString {
+	return &ast.InterpolatedString{Texts: texts, Exprs: exprs}
+}
+
+// Bool returns true or false.
+func Bool(v bool) *ast.BooleanLiteral { return &ast.BooleanLiteral{Value: v} }
+
+// Name returns the name v.
+func Name(v string) *ast.Name { return &ast.Name{Value: v} }
+
+// Block returns the block { body }, without binding powers.
+func Block(body ...ast.Statement) *ast.FunctionLiteral {
+	return &ast.FunctionLiteral{Body: body}
+}
+
+// Table returns the table [elems].
+func Table(elems ...ast.Expression) *ast.TableLiteral {
+	return &ast.TableLiteral{Elements: elems}
+}
+
+// Prefix returns op right.
+func Prefix(op string, right ast.Expression) *ast.PrefixExpr {
+	return &ast.PrefixExpr{Op: op, Right: right}
+}
+
+// Infix returns left op right.
+func Infix(op string, left, right ast.Expression) *ast.InfixExpr {
+	return &ast.InfixExpr{Left: left, Op: op, Right: right}
+}
+
+// Dot returns left.key.
+func Dot(left, key ast.Expression) *ast.DotExpr {
+	return &ast.DotExpr{Left: left, Key: key}
+}
+
+// Bind returns name : value.
+func Bind(name, value ast.Expression) *ast.BindingExpr {
+	return BindOp(":", name, value)
+}
+
+// BindOp returns the binding of name with the operator op, such as :+.
+func BindOp(op string, name, value ast.Expression) *ast.BindingExpr {
+	return &ast.BindingExpr{Name: name, Operator: op, Value: value}
+}
+
+// Resource returns name @: value.
+func Resource(name, value ast.Expression) *ast.ResourceDef {
+	return &ast.ResourceDef{Name: name, Value: value}
+}
+
+// Elvis returns left ?: right.
+func Elvis(left, right ast.Expression) *ast.ElvisExpr {
+	return &ast.ElvisExpr{Left: left, Right: right}
+}
+
+// Guard returns cond !> value.
+func Guard(cond, value ast.Expression) *ast.GuardExpr {
+	return &ast.GuardExpr{Cond: cond, Value: value}
+}
+
+// Assert returns assert cond message; message may be nil.
+func Assert(cond, message ast.Expression) *ast.AssertExpr {
+	return &ast.AssertExpr{Cond: cond, Message: message}
+}
+
+// Comma returns left, right.
+func Comma(left, right ast.Expression) *ast.CommaExpr {
+	return &ast.CommaExpr{Left: left, Right: right}
+}
+
+// Group returns (inner).
+func Group(inner ast.Expression) *ast.GroupExpr {
+	return &ast.GroupExpr{Inner: inner}
+}
+
+// Diff compares the trees got and want, ignoring their spans, and
+// describes each difference on a line of its own, by the path of the
+// node or field from the root: "Statements[1].Value.Op: got "-", want "+"".
+// It returns "" for equal trees.
+func Diff(got, want ast.Node) string {
+	var d differ
+	d.node("", nodeValue(got), nodeValue(want))
+	return strings.Join(d.lines, "\n")
+}
+
+// Equal fails t, with the differences, unless the trees got and want are
+// equal but for their spans.
+func Equal(t testing.TB, got, want ast.Node) {
+	t.Helper()
+	if d := Diff(got, want); d != "" {
+		t.Errorf("syntax trees differ:\n%s\ngot:  %s\nwant: %s", d, describe(nodeValue(got)), describe(nodeValue(want)))
+	}
+}
+
+type differ struct{ lines []string }
+
+func (d *differ) add(path, format string, args ...any) {
+	if path == "" {
+		path = "root"
+	}
+	d.lines = append(d.lines, path+": "+fmt.Sprintf(format, args...))
+}
+
+// node compares two values of interface type: nodes, or nil.
+func (d *differ) node(path string, got, want reflect.Value) {
+	switch {
+	case !got.IsValid() && !want.IsValid():
+		return
+	case !got.IsValid() || !want.IsValid() || got.Type() != want.Type():
+		d.add(path, "got %s, want %s", describe(got), describe(want))
+		return
+	}
+	g, w := got.Elem(), want.Elem()
+	for i := 0; i < g.NumField(); i++ {
+		f := g.Type().Field(i)
+		if f.Anonymous {
+			continue // the Span
+		}
+		d.field(join(path, f.Name), g.Field(i), w.Field(i))
+	}
+}
+
+// field compares the fields of two nodes of the same type.
+func (d *differ) field(path string, got, want reflect.Value) {
+	switch got.Kind() {
+	case reflect.Interface:
+		d.node(path, nodeValue(got), nodeValue(want))
+	case reflect.Slice:
+		n := min(got.Len(), want.Len())
+		for i := 0; i < n; i++ {
+			d.field(fmt.Sprintf("%s[%d]", path, i), got.Index(i), want.Index(i))
+		}
+		for i := n; i < got.Len(); i++ {
+			d.add(fmt.Sprintf("%s[%d]", path, i), "got %s, want nothing", describeElem(got.Index(i)))
+		}
+		for i := n; i < want.Len(); i++ {
+			d.add(fmt.Sprintf("%s[%d]", path, i), "got nothing, want %s", describeElem(want.Index(i)))
+		}
+	case reflect.Pointer:
+		switch {
+		case got.IsNil() && want.IsNil():
+		case got.IsNil() || want.IsNil() || !reflect.DeepEqual(got.Elem().Interface(), want.Elem().Interface()):
+			d.add(path, "got %s, want %s", describePointer(got), describePointer(want))
+		}
+	default:
+		if !reflect.DeepEqual(got.Interface(), want.Interface()) {
+			d.add(path, "got %#v, want %#v", got.Interface(), want.Interface())
+		}
+	}
+}
+
+// nodeValue returns the node in v, which is a node or holds one, as an
+// interface value, or the invalid Value for none.
+func nodeValue(v any) reflect.Value {
+	rv, ok := v.(reflect.Value)
+	if !ok {
+		rv = reflect.ValueOf(v)
+	}
+	if rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() || rv.IsNil() {
+		return reflect.Value{}
+	}
+	return rv
+}
+
+func describe(v reflect.Value) string {
+	if !v.IsValid() {
+		return "nil"
+	}
+	// A program prints a statement per line.
+	s := strings.ReplaceAll(strings.TrimSpace(v.Interface().(ast.Node).String()), "\n", "; ")
+	return v.Elem().Type().Name() + " " + s
+}
+
+func describeElem(v reflect.Value) string {
+	if v.Kind() == reflect.Interface {
+		return describe(nodeValue(v))
+	}
+	return fmt.Sprintf("%#v", v.Interface())
+}
+
+func describePointer(v reflect.Value) string {
+	if v.IsNil() {
+		return "nil"
+	}
+	return fmt.Sprint(v.Elem().Interface())
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
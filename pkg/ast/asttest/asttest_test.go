@@ -0,0 +1,42 @@
+package asttest
+
+import (
+	"testing"
+
+	"orglang/pkg/ast"
+)
+
+func TestDiff(t *testing.T) {
+	parsed := Program(Bind(Name("x"), Infix("+", Int("1"), Int("2"))))
+	parsed.Statements[0].SetLocation(ast.Span{Start: ast.Pos{Line: 1, Column: 1}})
+	tests := []struct {
+		name string
+		want ast.Node
+		diff string
+	}{
+		{"equal but for spans", Program(Bind(Name("x"), Infix("+", Int("1"), Int("2")))), ""},
+		{"attribute", Program(Bind(Name("x"), Infix("-", Int("1"), Int("2")))),
+			`Statements[0].Value.Op: got "+", want "-"`},
+		{"node type", Program(Bind(Name("x"), Int("3"))),
+			"Statements[0].Value: got InfixExpr (1 + 2), want IntegerLiteral 3"},
+		{"several", Program(Bind(Name("y"), Infix("+", Int("1"), Dec("2.0")))),
+			"Statements[0].Name.Value: got \"x\", want \"y\"\nStatements[0].Value.Right: got IntegerLiteral 2, want DecimalLiteral 2.0"},
+		{"missing statement", Program(parsed.Statements[0], Name("z")),
+			"Statements[1]: got nothing, want Name z"},
+		{"nil", Program(Assert(Name("x"), nil)),
+			"Statements[0]: got BindingExpr (x : (1 + 2)), want AssertExpr (assert x)"},
+		{"root", Name("x"), "root: got Program (x : (1 + 2)), want Name x"},
+	}
+	for _, tt := range tests {
+		if got := Diff(parsed, tt.want); got != tt.diff {
+			t.Errorf("%s: diff\n%s\nwant\n%s", tt.name, got, tt.diff)
+		}
+	}
+	if got := Diff(Assert(Name("x"), nil), Assert(Name("x"), Str("m"))); got != `Message: got nil, want StringLiteral "m"` {
+		t.Errorf("nil field: diff %q", got)
+	}
+	lbp := 50
+	if got := Diff(&ast.FunctionLiteral{LBP: &lbp}, Block()); got != "LBP: got 50, want nil" {
+		t.Errorf("binding power: diff %q", got)
+	}
+}
@@ -0,0 +1,156 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Dump and ToJSON print a tree generically: a node is its type name, its
+// Span and its fields, where fields holding nodes are children and the
+// other fields (operators, literal text, flags) are attributes.
+
+// Dump writes n as an indented tree, one node per line with its range
+// and attributes, children indented under it and labeled with the field
+// holding them:
+//
+//	Program 1:1-1:11
+//	  Statements[0]: BindingExpr 1:1-1:10 Operator=":"
+//	    Name: Name 1:1-1:2 Value="x"
+//	    Value: InfixExpr 1:5-1:10 Op="+"
+//	      Left: IntegerLiteral 1:5-1:6 Value="1"
+//	      Right: IntegerLiteral 1:9-1:10 Value="2"
+func Dump(w io.Writer, n Node) {
+	dump(w, "", n, 0)
+}
+
+func dump(w io.Writer, label string, n Node, depth int) {
+	v := reflect.ValueOf(n).Elem()
+	t := v.Type()
+	var b strings.Builder
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(label)
+	b.WriteString(t.Name())
+	if s := n.Location(); !s.IsZero() {
+		fmt.Fprintf(&b, " %d:%d-%d:%d", s.Start.Line, s.Start.Column, s.End.Line, s.End.Column)
+	}
+	type child struct {
+		label string
+		node  Node
+	}
+	var children []child
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			continue
+		}
+		fv := v.Field(i)
+		if node, ok := asNode(fv); ok {
+			children = append(children, child{f.Name + ": ", node})
+			continue
+		}
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Interface {
+			for j := 0; j < fv.Len(); j++ {
+				if node, ok := asNode(fv.Index(j)); ok {
+					children = append(children, child{fmt.Sprintf("%s[%d]: ", f.Name, j), node})
+				}
+			}
+			continue
+		}
+		if attr, ok := attribute(fv); ok {
+			fmt.Fprintf(&b, " %s=%s", f.Name, attr)
+		}
+	}
+	fmt.Fprintln(w, b.String())
+	for _, c := range children {
+		dump(w, c.label, c.node, depth+1)
+	}
+}
+
+// asNode returns the node held by v, a field or slice element of
+// interface type, if there is one.
+func asNode(v reflect.Value) (Node, bool) {
+	if v.Kind() != reflect.Interface || v.IsNil() {
+		return nil, false
+	}
+	n, ok := v.Interface().(Node)
+	return n, ok
+}
+
+// attribute formats a field that is not a node, leaving out unset
+// optional ones.
+func attribute(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("%q", v.String()), true
+	case reflect.Pointer:
+		if v.IsNil() {
+			return "", false
+		}
+		return attribute(v.Elem())
+	case reflect.Bool:
+		if !v.Bool() {
+			return "", false
+		}
+	case reflect.Slice:
+		return fmt.Sprintf("%q", v.Interface()), true
+	}
+	return fmt.Sprint(v.Interface()), true
+}
+
+// ToJSON returns n as a value for encoding/json: an object with the type
+// of the node, its span and its fields, named in lower camel case. Nodes
+// absent from an optional field are null.
+//
+//	{"type": "Name", "span": {"start": {"line": 1, ...}, ...}, "value": "x"}
+func ToJSON(n Node) any {
+	if n == nil || reflect.ValueOf(n).IsNil() {
+		return nil
+	}
+	v := reflect.ValueOf(n).Elem()
+	t := v.Type()
+	s := n.Location()
+	out := map[string]any{
+		"type": t.Name(),
+		"span": map[string]any{"start": jsonPos(s.Start), "end": jsonPos(s.End)},
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			continue
+		}
+		name := lowerFirst(f.Name)
+		fv := v.Field(i)
+		switch {
+		case fv.Kind() == reflect.Interface:
+			node, _ := asNode(fv)
+			out[name] = ToJSON(node)
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Interface:
+			nodes := []any{}
+			for j := 0; j < fv.Len(); j++ {
+				node, _ := asNode(fv.Index(j))
+				nodes = append(nodes, ToJSON(node))
+			}
+			out[name] = nodes
+		default:
+			out[name] = fv.Interface()
+		}
+	}
+	return out
+}
+
+func jsonPos(p Pos) map[string]int {
+	return map[string]int{"line": p.Line, "column": p.Column, "offset": p.Offset}
+}
+
+func lowerFirst(s string) string {
+	r := []rune(s)
+	// All-caps names such as LBP are lowered whole.
+	if strings.ToUpper(s) == s {
+		return strings.ToLower(s)
+	}
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
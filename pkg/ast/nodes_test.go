@@ -0,0 +1,226 @@
+package ast_test
+
+import (
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	orgast "orglang/pkg/ast"
+	"orglang/pkg/diag"
+	"orglang/pkg/eval"
+	"orglang/pkg/format"
+	"orglang/pkg/ir"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+)
+
+// nodeSamples holds, for every kind of node, a source holding one, or the
+// node itself for those the parser does not build, and whether its
+// children refer to left, for the analysis of operands. A new node type
+// must be added here, and so to every check below.
+var nodeSamples = map[string]struct {
+	src  string
+	left bool
+	node orgast.Statement
+}{
+	"Program":            {src: "1", left: false},
+	"IntegerLiteral":     {src: "42", left: false},
+	"DecimalLiteral":     {src: "1.5", left: false},
+	"RationalLiteral":    {src: "1/3", left: false},
+	"QuantityLiteral":    {src: "250ms", left: false},
+	"StringLiteral":      {src: `"text"`, left: false},
+	"InterpolatedString": {src: `"x${left}y"`, left: true},
+	"BooleanLiteral":     {src: "true", left: false},
+	"FunctionLiteral":    {src: "g : { left }", left: false}, // a block has its own operands
+	"TableLiteral":       {src: "[left 2]", left: true},
+	"Name":               {src: "left", left: true},
+	"PrefixExpr":         {src: "! left", left: true},
+	"InfixExpr":          {src: "left + 1", left: true},
+	"DotExpr":            {src: "left.key", left: true},
+	"BindingExpr":        {src: "x : left", left: true},
+	"ResourceDef":        {src: "r @: left", left: true},
+	"ResourceInst":       {node: &orgast.ResourceInst{Name: &orgast.Name{Value: "left"}}, left: true},
+	"ElvisExpr":          {src: "left ?: 0", left: true},
+	"GuardExpr":          {src: "left !> 1", left: true},
+	"AssertExpr":         {src: `assert left "broken"`, left: true},
+	"CommaExpr":          {src: "left, 1", left: true},
+	"GroupExpr":          {src: "(left)", left: true},
+	"ErrorExpr":          {src: "x : )", left: false},
+}
+
+// nodeKinds returns the names of the node types of package ast, the
+// structs embedding Span, read from its source.
+func nodeKinds(t *testing.T) []string {
+	t.Helper()
+	file, err := goparser.ParseFile(token.NewFileSet(), "ast.go", nil, goparser.SkipObjectResolution)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok {
+			return false
+		}
+		for _, f := range st.Fields.List {
+			if id, ok := f.Type.(*ast.Ident); ok && len(f.Names) == 0 && id.Name == "Span" {
+				kinds = append(kinds, spec.Name.Name)
+			}
+		}
+		return false
+	})
+	slices.Sort(kinds)
+	return kinds
+}
+
+// kindOf is the name of the type of n.
+func kindOf(n orgast.Node) string { return reflect.TypeOf(n).Elem().Name() }
+
+// TestEveryNodeKind checks that each consumer of the tree handles every
+// kind of node: the walker and Dump reach all of its children, the
+// analysis of operands looks into them, the interpreter evaluates it, the
+// IR lowering knows it and the formatter keeps it, none of them panicking.
+func TestEveryNodeKind(t *testing.T) {
+	kinds := nodeKinds(t)
+	if len(kinds) == 0 {
+		t.Fatal("no node types found in ast.go")
+	}
+	for kind := range nodeSamples {
+		if !slices.Contains(kinds, kind) {
+			t.Errorf("sample for %s, which is not a node type", kind)
+		}
+	}
+	for _, kind := range kinds {
+		sample, ok := nodeSamples[kind]
+		if !ok {
+			t.Errorf("%s: no sample; add one to nodeSamples", kind)
+			continue
+		}
+		t.Run(kind, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("%s: panic on %q: %v", kind, sample.src, r)
+				}
+			}()
+			var prog *orgast.Program
+			var diags []diag.Diagnostic
+			if sample.node != nil {
+				prog = &orgast.Program{Statements: []orgast.Statement{sample.node}}
+			} else {
+				p := parser.New(lexer.New([]byte(sample.src)))
+				prog = p.ParseProgram()
+				diags = p.Diagnostics()
+			}
+			found := false
+			orgast.Inspect(prog, func(n orgast.Node) bool {
+				found = found || kindOf(n) == kind
+				checkFields(t, n)
+				return true
+			})
+			if !found {
+				t.Fatalf("%q parses to no %s:\n%s", sample.src, kind, dump(prog))
+			}
+			dump(prog)
+			orgast.ToJSON(prog)
+
+			if kind != "Program" {
+				checkOperands(t, kind, prog, sample.left)
+			}
+			checkEval(t, kind, prog)
+			checkLower(t, kind, prog)
+			if sample.node == nil && len(diags) == 0 {
+				checkFormat(t, sample.src)
+			}
+		})
+	}
+}
+
+// checkFields fails if a field of n holds nodes that Inspect, Dump and
+// ToJSON cannot see: they follow only fields of interface type, alone or
+// in slices.
+func checkFields(t *testing.T, n orgast.Node) {
+	t.Helper()
+	node := reflect.TypeFor[orgast.Node]()
+	typ := reflect.TypeOf(n).Elem()
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		ft := f.Type
+		if f.Anonymous || ft.Kind() == reflect.Interface {
+			continue
+		}
+		if ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Interface {
+			continue
+		}
+		for ft.Kind() == reflect.Slice || ft.Kind() == reflect.Pointer || ft.Kind() == reflect.Array {
+			ft = ft.Elem()
+		}
+		if ft.Implements(node) || reflect.PointerTo(ft).Implements(node) || ft.Kind() == reflect.Struct {
+			t.Errorf("%s.%s holds %s, which the walker does not follow", typ.Name(), f.Name, f.Type)
+		}
+	}
+}
+
+// checkOperands puts the statements of prog in a block and checks that
+// parser.Operands sees the left operand in them if the sample uses it.
+func checkOperands(t *testing.T, kind string, prog *orgast.Program, left bool) {
+	t.Helper()
+	fl := &orgast.FunctionLiteral{Body: prog.Statements}
+	if got, _ := parser.Operands(fl); got != left {
+		t.Errorf("%s: Operands of %s gives left %v, want %v", kind, fl, got, left)
+	}
+}
+
+// checkEval evaluates prog, which must not reach the interpreter's
+// fallback for nodes it does not know.
+func checkEval(t *testing.T, kind string, prog *orgast.Program) {
+	t.Helper()
+	in := eval.New()
+	in.Stdout, in.Stderr = io.Discard, io.Discard
+	v := in.Force(in.Eval(prog))
+	if e, ok := v.(*eval.Error); ok && strings.HasPrefix(e.Message, "cannot evaluate") {
+		t.Errorf("%s: %s", kind, e.Message)
+	}
+}
+
+// checkLower lowers the statements of prog in a block, where the samples
+// may use their operands, to IR. Lowering must support every kind of node
+// that has a sample: any Unsupported diagnostic fails, not only the
+// fallback for nodes the lowering does not know.
+func checkLower(t *testing.T, kind string, prog *orgast.Program) {
+	t.Helper()
+	block := &orgast.Program{Statements: []orgast.Statement{&orgast.FunctionLiteral{Body: prog.Statements}}}
+	_, diags := ir.Lower(block, "sample.org")
+	for _, d := range diags {
+		if d.Code == diag.Unsupported {
+			t.Errorf("%s: %s", kind, d.Message)
+		}
+	}
+}
+
+// checkFormat formats src, which must give the same result again.
+func checkFormat(t *testing.T, src string) {
+	t.Helper()
+	once, err := format.Source([]byte(src))
+	if err != nil {
+		t.Fatalf("format %q: %v", src, err)
+	}
+	twice, err := format.Source(once)
+	if err != nil || string(twice) != string(once) {
+		t.Errorf("format %q is not stable: %q, then %q (%v)", src, once, twice, err)
+	}
+}
+
+func dump(n orgast.Node) string {
+	var b strings.Builder
+	orgast.Dump(&b, n)
+	return b.String()
+}
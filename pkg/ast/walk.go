@@ -0,0 +1,31 @@
+package ast
+
+import "reflect"
+
+// Inspect traverses the tree of n depth first, calling f on each node
+// before its children, which are the nodes held by its fields in field
+// order, as Dump shows them. If f returns false, the children of the
+// node are skipped.
+func Inspect(n Node, f func(Node) bool) {
+	if n == nil || reflect.ValueOf(n).IsNil() || !f(n) {
+		return
+	}
+	v := reflect.ValueOf(n).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Anonymous {
+			continue
+		}
+		fv := v.Field(i)
+		if child, ok := asNode(fv); ok {
+			Inspect(child, f)
+			continue
+		}
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Interface {
+			for j := 0; j < fv.Len(); j++ {
+				if child, ok := asNode(fv.Index(j)); ok {
+					Inspect(child, f)
+				}
+			}
+		}
+	}
+}
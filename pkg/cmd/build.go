@@ -1,27 +1,532 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/codegen"
+	"orglang/pkg/deps"
+	"orglang/pkg/doc"
+	"orglang/pkg/ir"
+	"orglang/pkg/lexer"
+	"orglang/pkg/manifest"
+	"orglang/pkg/parser"
+	orgruntime "orglang/pkg/runtime"
 
 	"github.com/spf13/cobra"
 )
 
 var buildCmd = &cobra.Command{
-	Use:   "build [flags] <input>",
-	Short: "Compile OrgLang source code (TBD)",
-	Long:  `Compiles OrgLang source code into an executable or bytecode.`,
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println(headerStyle.Render("Build"))
-		printInfo("Input", args[0])
-		printInfo("Status", "TBD - Build logic not yet implemented")
+	Use:   "build [flags] [input | ./...]",
+	Short: "Compile OrgLang source code",
+	Long: `Compiles OrgLang source code into an executable.
+
+The program and the modules it imports are printed as C, or LLVM IR with
+--backend=llvm, and compiled with the runtime, which org carries, into
+the --output executable, by default the input without its extension. It
+links against GMP (libgmp). The executable sizes the pages of its memory
+arena from $ORG_ARENA_SIZE (bytes, or with a K, M or G suffix), 1M by
+default.
+
+Without an input, the build is that of the project in the current
+directory or above it: the entry point given by the main key of its
+org.toml, built into bin/<name> under the project root (see org init).
+
+With --all, or ./... as the input, every [[target]] of the manifest is
+built instead, in order, each into bin/<name>. The optimize, cflags,
+ldflags and libs keys of a target replace the project's; -O and the C
+flags of the command line still win over both.
+
+A project with dependencies only builds if they match its org.lock, as
+fetched by org get.
+
+The modules the program imports (alias : "path" @ org), directly or not,
+are loaded once each, by canonical path: relative to the importing file,
+absolute, cleaned and with symlinks resolved, so ./a.org and a.org are
+one module. Modules importing each other fail the build with the chain of
+the cycle, and two bindings whose C symbols would clash at link time,
+such as stdout bound by two modules, fail it naming both.
+
+With --library the module is built as a C library instead, lib<name>.a
+(static, the default) or lib<name>.so (--library=shared), together with a
+C header and, under orglang/, the runtime's headers it includes. The
+header declares <name>_init and <name>_shutdown and one function per
+binding tagged @export in its docstring; "@export c_name" picks the C
+name, which otherwise is <name>_<binding>. Programs using it link with
+-l<name> -lgmp.
+
+With --library --python a CPython extension module is written too
+(<name>module.c) and compiled, with python3-config, into the module
+Python imports: each export becomes a Python function, with values
+converted by the runtime's python/pyconv.c.
+
+With --json the parse errors and import cycles are printed on stdout as a JSON array, as by
+org check --json, and nothing else is: an empty array when there are
+none.
+
+assert statements are checked by --debug builds and by those below -O2;
+-O2 and above compile them to nothing.
+
+The C compiler is given by --cc, else $ORG_CC, else the first of gcc,
+clang, cc, zig cc and tcc found in PATH; the build stops at once, saying
+what to install, if there is none.
+
+--backend picks the code generator: c (the default), printing C for the
+C compiler, or llvm, printing LLVM IR that llc (LLVM 14 or later)
+compiles at the -O level of the build. Both name their symbols alike, so
+modules built by either link together.
+
+--emit stops the build after a stage and writes what it produced, to
+the --output file or else to stdout, without building a binary: tokens
+(the token stream, as org lex prints it), ast (the syntax tree, as org
+ast prints it), ir (the intermediate representation the code is printed
+from), c (the C of the c backend), llvm (the LLVM IR of the llvm backend)
+or obj (the object file, to --output). Errors of the stages run are
+reported after the output and fail the build. An --output that is a
+directory gets a file per module of the program. obj is compiled by the
+C compiler from the C, or by llc with --backend=llvm.
+
+--watch builds again each time the input or a module it imports changes,
+after the files have stayed unchanged for a moment.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stage, _ := cmd.Flags().GetString("emit")
+		if err := checkEmit(stage); err != nil {
+			return err
+		}
+		if _, err := backendFor(cmd, stage); err != nil {
+			return err
+		}
+		all, _ := cmd.Flags().GetBool("all")
+		if len(args) == 1 && args[0] == "./..." {
+			all, args = true, nil
+		}
+		if all {
+			return buildAll(cmd, args)
+		}
+		if len(args) == 0 {
+			m, err := projectManifest()
+			if err != nil {
+				return err
+			}
+			args = []string{relativePath(m.Entry())}
+			if !cmd.Flags().Changed("output") && stage == "" {
+				cmd.Flags().Set("output", relativePath(m.Output()))
+			}
+		}
+		if w, _ := cmd.Flags().GetBool("watch"); w {
+			return watch(args[0])
+		}
+		if stage != "" {
+			output, _ := cmd.Flags().GetString("output")
+			return emit(cmd, stage, args[0], output)
+		}
+		output, _ := cmd.Flags().GetString("output")
+		return buildInput(cmd, args[0], output, nil)
 	},
 }
 
+// buildAll builds every target of the project in the working directory
+// or above, in the order of its manifest, stopping at the first failing.
+func buildAll(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("--all builds the targets of %s and takes no input", manifest.FileName)
+	}
+	for _, name := range []string{"output", "emit", "watch", "library", "python", "json"} {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--all cannot be used with --%s", name)
+		}
+	}
+	m, err := manifest.Find(".")
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return fmt.Errorf("--all: no %s found (see org init)", manifest.FileName)
+	}
+	if len(m.Targets) == 0 {
+		return fmt.Errorf("--all: %s has no [[target]]", m.Path)
+	}
+	for i := range m.Targets {
+		t := &m.Targets[i]
+		if err := buildInput(cmd, relativePath(m.TargetEntry(t)), relativePath(m.TargetOutput(t)), t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildInput builds input into output, with the options of target, a
+// target of its project's manifest, when it is not nil.
+func buildInput(cmd *cobra.Command, input, output string, target *manifest.Target) error {
+	if err := verifyDependencies(input); err != nil {
+		return err
+	}
+	cc, err := ccOptionsFromFlags(cmd, input, target)
+	if err != nil {
+		return err
+	}
+	backend, _ := cmd.Flags().GetString("backend")
+	var llc *codegen.LLC
+	if backend == "llvm" {
+		if llc, err = codegen.FindLLC(); err != nil {
+			return err
+		}
+	}
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	strict, _ := cmd.Flags().GetBool("strict")
+	asJSON, _ := cmd.Flags().GetBool("json")
+	optimize, _ := cmd.Flags().GetInt("optimize")
+	if target != nil && target.Optimize != nil && !cmd.Flags().Changed("optimize") {
+		optimize = *target.Optimize
+	}
+
+	src, err := lexer.ReadSource(input)
+	if err != nil {
+		return err
+	}
+	pre, err := preludeFor(cmd, input)
+	if err != nil {
+		return err
+	}
+	l := lexer.New(src)
+	p := parser.New(l, parser.WithStrict(strict), parser.WithBindings(pre.Bindings))
+	prog := p.ParseProgram()
+	var r report
+	f := r.file(input, src)
+	for _, d := range append(l.Diagnostics(), p.Diagnostics()...) {
+		f.add(d)
+	}
+	var modules []*codegen.Module
+	if len(f.diags) == 0 {
+		if modules, err = loadModules(input, prog, strict); err != nil {
+			var cycle *codegen.ImportCycle
+			if !errors.As(err, &cycle) {
+				return err
+			}
+			path := relativePath(cycle.Module())
+			src, _ := lexer.ReadSource(path)
+			r.file(path, src).add(cycle.Diagnostic())
+		}
+		// Two bindings of the program whose C symbols clash would
+		// fail to link.
+		syms := codegen.NewSymbolTable()
+		declareModules(syms, modules)
+		for _, mod := range modules {
+			path := relativePath(mod.Path)
+			if diags := syms.Diagnostics(path); len(diags) > 0 {
+				src, _ := lexer.ReadSource(path)
+				f := r.file(path, src)
+				for _, d := range diags {
+					f.add(d)
+				}
+			}
+		}
+	}
+	if asJSON {
+		if err := r.printJSON(os.Stdout); err != nil {
+			return err
+		}
+		if err := r.err("build failed"); err != nil {
+			return err
+		}
+	} else if errs, _ := r.counts(); errs > 0 {
+		for _, f := range r.files {
+			printDiagnostics(os.Stderr, f.path, f.src, f.diags)
+		}
+		return failed("build failed")
+	}
+
+	library, _ := cmd.Flags().GetString("library")
+	python, _ := cmd.Flags().GetBool("python")
+	debug, _ := cmd.Flags().GetBool("debug")
+	var generated []string
+	if python && library == "" {
+		return fmt.Errorf("--python requires --library")
+	}
+	if library != "" && backend != "c" {
+		return fmt.Errorf("--library: the library's glue is C, built with the c backend, not --backend=%s", backend)
+	}
+	if library != "" {
+		if library != "static" && library != "shared" {
+			return fmt.Errorf("--library: want static or shared, got %q", library)
+		}
+		lib, written, err := writeLibrary(input, output, src, library == "shared", python)
+		if err != nil {
+			return err
+		}
+		built, err := linkLibrary(lib, input, prog, pre, modules, cc, optimize, debug)
+		written = append(written, built...)
+		// What was written is recorded even if linking failed, for org
+		// clean to remove.
+		if rerr := recordArtifacts(input, written); err == nil {
+			err = rerr
+		}
+		if err != nil {
+			return err
+		}
+		for _, path := range written {
+			// The runtime's headers are listed as their directory.
+			if rel, _ := filepath.Rel(lib.dir, path); !strings.HasPrefix(rel, runtimeHeaders+string(filepath.Separator)) {
+				generated = append(generated, path)
+			}
+		}
+		generated = append(generated, filepath.Join(lib.dir, runtimeHeaders)+string(filepath.Separator))
+	}
+	if library == "" {
+		if output == "" {
+			output = strings.TrimSuffix(input, filepath.Ext(input))
+			if output == input {
+				return fmt.Errorf("%s has no extension to drop for the executable's name; give --output", input)
+			}
+		}
+		b, err := codegen.FindBackend(backend)
+		if err != nil {
+			return err
+		}
+		var obj *objCompiler
+		if llc != nil {
+			obj = llcCompiler(llc, optimize)
+		}
+		if err := link(input, output, prog, pre, modules, b, obj, cc, optimize, debug); err != nil {
+			return err
+		}
+		if err := recordArtifacts(input, []string{output}); err != nil {
+			return err
+		}
+	}
+
+	if asJSON {
+		return nil
+	}
+	fmt.Println(headerStyle.Render("Build"))
+	if target != nil {
+		printInfo("Target", target.Name)
+	}
+	printInfo("Input", input)
+	if output != "" {
+		printInfo("Output", output)
+	}
+	if verbose {
+		printInfo("Backend", backend)
+		if llc != nil {
+			printInfo("LLC", fmt.Sprintf("%s (LLVM %d)", llc.Path, llc.Version))
+		}
+		printInfo("Compiler", fmt.Sprintf("%s (%s)", cc.Compiler.Name, cc.Compiler))
+	}
+	if verbose && len(cc.Args()) > 0 {
+		printInfo("C flags", strings.Join(cc.Args(), " "))
+	}
+	if verbose {
+		asserts := "removed"
+		if codegen.Assertions(debug, optimize) {
+			asserts = "checked"
+		}
+		printInfo("Assertions", asserts)
+		printInfo("Modules", fmt.Sprint(len(modules)))
+		if dir, err := cacheDir(); err == nil {
+			printInfo("Cache", dir)
+		}
+	}
+	for _, path := range generated {
+		printInfo("Generated", path)
+	}
+	return nil
+}
+
+// verifyDependencies checks the dependencies of the project input belongs
+// to, if any, against its org.lock, so a build never uses dependencies
+// other than those locked.
+func verifyDependencies(input string) error {
+	m, err := manifest.Find(filepath.Dir(input))
+	if err != nil || m == nil {
+		return err
+	}
+	return deps.VerifyProject(m)
+}
+
+// projectManifest returns the manifest of the project in the working
+// directory or above, which must name an entry point.
+func projectManifest() (*manifest.Manifest, error) {
+	m, err := manifest.Find(".")
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("no input given and no %s found (see org init)", manifest.FileName)
+	}
+	if m.Main == "" && len(m.Targets) > 0 {
+		return nil, fmt.Errorf("no input given and %s has no main key (org build --all builds its targets)", m.Path)
+	}
+	if m.Main == "" {
+		return nil, fmt.Errorf("no input given and %s has no main key", m.Path)
+	}
+	return m, nil
+}
+
+// loadModules loads the modules of the program input, parsed as prog,
+// and of the modules it imports, each parsed on its own.
+func loadModules(input string, prog *ast.Program, strict bool) ([]*codegen.Module, error) {
+	root, err := codegen.CanonicalPath("", input)
+	if err != nil {
+		return nil, err
+	}
+	return codegen.LoadModules(input, func(path string) (*ast.Program, error) {
+		if path == root {
+			return prog, nil
+		}
+		src, err := lexer.ReadSource(path)
+		if err != nil {
+			return nil, err
+		}
+		l := lexer.New(src)
+		p := parser.New(l, parser.WithStrict(strict))
+		imported := p.ParseProgram()
+		if diags := append(l.Diagnostics(), p.Diagnostics()...); len(diags) > 0 {
+			return nil, fmt.Errorf("%s: %s", relativePath(path), diags[0])
+		}
+		return imported, nil
+	})
+}
+
+// declareModules declares in syms the C symbols of modules, loaded by
+// loadModules, each known by its path relative to the working directory.
+// Constructs the code generator does not support yet do not change the
+// symbols, so their diagnostics are left to org build --emit.
+func declareModules(syms *codegen.SymbolTable, modules []*codegen.Module) {
+	for _, mod := range modules {
+		m, _ := ir.Lower(mod.Program, relativePath(mod.Path))
+		syms.DeclareModule(m)
+	}
+}
+
+// relativePath returns path relative to the working directory when it is
+// below it.
+func relativePath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && filepath.IsLocal(rel) {
+		return rel
+	}
+	return path
+}
+
+// recordArtifacts records the files the build of input wrote, under its
+// project, for org clean to remove.
+func recordArtifacts(input string, paths []string) error {
+	dir, err := cacheDir()
+	if err != nil {
+		return err
+	}
+	root, err := projectRoot(filepath.Dir(input))
+	if err != nil {
+		return err
+	}
+	return artifacts(dir, root).Record(paths...)
+}
+
+// library is the library build of a module.
+type library struct {
+	name    string // of the header, <name>.h, and of the library, lib<name>
+	dir     string // the directory it is written to
+	exports []codegen.Export
+	shared  bool // lib<name>.so rather than lib<name>.a
+	python  bool // with a CPython extension module
+}
+
+// runtimeHeaders is the directory, next to the header of a library, of
+// the runtime's headers the header includes.
+const runtimeHeaders = "orglang"
+
+// writeLibrary writes the C header of a library build of input next to the
+// output (default: the input without its extension), the runtime's
+// headers it includes, and with python the source of its Python extension
+// module. It returns the library and the paths written.
+func writeLibrary(input, output string, src []byte, shared, python bool) (*library, []string, error) {
+	base := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	if output == "" {
+		output = strings.TrimSuffix(input, filepath.Ext(input))
+	}
+	lib := strings.TrimSuffix(filepath.Base(output), filepath.Ext(output))
+	lib = strings.TrimPrefix(lib, "lib")
+
+	mod, err := doc.Parse(base, input, src)
+	if err != nil {
+		return nil, nil, err
+	}
+	var exports []codegen.Export
+	for _, b := range mod.Bindings {
+		if b.Export {
+			exports = append(exports, codegen.Export{
+				Binding: b.Name, C: b.ExportName, Kind: b.Kind,
+				Operator: b.IsOperator(), Doc: b.Doc,
+				File: input, Line: b.Line,
+			})
+		}
+	}
+	if len(exports) == 0 {
+		return nil, nil, fmt.Errorf("%s: no binding is tagged @export", input)
+	}
+
+	module := filepath.Base(input)
+	h, err := codegen.Header(lib, module, exports)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", input, err)
+	}
+	files := [][2]string{{lib + ".h", h}}
+	if python {
+		m, err := codegen.PythonModule(lib, module, exports)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", input, err)
+		}
+		files = append(files, [2]string{lib + "module.c", m})
+	}
+
+	l := &library{name: lib, dir: filepath.Dir(output), exports: exports, shared: shared, python: python}
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return nil, nil, err
+	}
+	var paths []string
+	for _, f := range files {
+		path := filepath.Join(l.dir, f[0])
+		if err := os.WriteFile(path, []byte(f[1]), 0o644); err != nil {
+			return nil, nil, err
+		}
+		paths = append(paths, path)
+	}
+	headers, err := orgruntime.WriteHeaders(filepath.Join(l.dir, runtimeHeaders))
+	return l, append(paths, headers...), err
+}
+
+// addBuildFlags registers on c the options of org build that commands
+// building binaries with buildInput share with it.
+func addBuildFlags(c *cobra.Command) {
+	c.Flags().IntP("optimize", "O", 1, "Optimization level")
+	c.Flags().BoolP("verbose", "v", false, "Verbose output during compilation")
+	c.Flags().Bool("debug", false, "Include debug information and check assert statements at any -O")
+	c.Flags().Bool("strict", true, "Reject undefined identifiers")
+	c.Flags().StringSlice("use", nil, "Standard library modules to use (e.g. math)")
+	c.Flags().String("backend", "c", "Code generator: c (C for the C compiler) or llvm (LLVM IR for llc)")
+	addCCFlags(c)
+}
+
 func init() {
 	rootCmd.AddCommand(buildCmd)
 	// Add flags here
 	buildCmd.Flags().StringP("output", "o", "", "Output file name")
 	buildCmd.Flags().StringP("target", "t", "", "Target architecture (future)")
-	buildCmd.Flags().IntP("optimize", "O", 1, "Optimization level")
+	buildCmd.Flags().Bool("json", false, "Print the parse errors as a JSON array")
+	buildCmd.Flags().String("library", "", "Build a C library with a header of the @export bindings (static or shared)")
+	buildCmd.Flags().Lookup("library").NoOptDefVal = "static"
+	buildCmd.Flags().Bool("python", false, "With --library, also generate a CPython extension module")
+	buildCmd.Flags().String("emit", "", "Stop after a stage and write its output instead of a binary: tokens, ast, ir, c, llvm or obj")
+	buildCmd.Flags().Bool("all", false, "Build every [[target]] of the project's org.toml")
+	buildCmd.Flags().Bool("watch", false, "Build again whenever the input or a module it imports changes")
+	addBuildFlags(buildCmd)
 }
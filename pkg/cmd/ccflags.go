@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"orglang/pkg/codegen"
+	"orglang/pkg/manifest"
+
+	"github.com/spf13/cobra"
+)
+
+// ccOptions holds the C compiler and the extra flags appended to its
+// invocation. The compiler comes from --cc, else $ORG_CC, else the first
+// one found in PATH; the flags from the cflags, ldflags and libs keys of
+// the project's org.toml, then --cflags, --ldflags and --lib.
+type ccOptions struct {
+	Compiler *codegen.Compiler
+	CFlags   []string
+	LDFlags  []string
+	Libs     []string
+}
+
+var libNameRe = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.+-]*$`)
+
+// addCCFlags registers the C compiler flag options on a command.
+func addCCFlags(c *cobra.Command) {
+	c.Flags().String("cc", "", "C compiler: gcc, clang, zig (zig cc), tcc or a command (default: $ORG_CC, else the first found)")
+	c.Flags().String("cflags", "", "Extra flags for the C compiler (e.g. \"-I/opt/include -DNDEBUG\")")
+	c.Flags().String("ldflags", "", "Extra flags for the linker (e.g. \"-L/opt/lib\")")
+	c.Flags().StringSlice("lib", []string{}, "Library to link against (repeatable, e.g. --lib m --lib curl)")
+}
+
+// ccOptionsFromFlags reads and validates the C compiler flag options of
+// the build of input, those of its project's manifest first. The keys of
+// target, a target of that manifest or nil, replace the project's.
+func ccOptionsFromFlags(c *cobra.Command, input string, target *manifest.Target) (ccOptions, error) {
+	var opts ccOptions
+
+	cflags, _ := c.Flags().GetString("cflags")
+	ldflags, _ := c.Flags().GetString("ldflags")
+	libs, _ := c.Flags().GetStringSlice("lib")
+	m, err := manifest.Find(filepath.Dir(input))
+	if err != nil {
+		return opts, err
+	}
+	if m != nil {
+		key, mc, ml, mlibs := m.Path+": ", m.CFlags, m.LDFlags, m.Libs
+		if target != nil {
+			key = fmt.Sprintf("%s: target %q: ", m.Path, target.Name)
+			if target.CFlags != nil {
+				mc = target.CFlags
+			}
+			if target.LDFlags != nil {
+				ml = target.LDFlags
+			}
+			if target.Libs != nil {
+				mlibs = target.Libs
+			}
+		}
+		if opts.CFlags, err = checkCCFlags(key+"cflags", mc); err != nil {
+			return opts, err
+		}
+		if opts.LDFlags, err = checkCCFlags(key+"ldflags", ml); err != nil {
+			return opts, err
+		}
+		if opts.Libs, err = libNames(key+"libs", mlibs); err != nil {
+			return opts, err
+		}
+	}
+
+	choice, _ := c.Flags().GetString("cc")
+	if choice == "" {
+		choice = os.Getenv("ORG_CC")
+	}
+	if opts.Compiler, err = codegen.FindCompiler(choice, nil); err != nil {
+		return opts, err
+	}
+	flags, err := splitCCFlags("--cflags", cflags)
+	if err != nil {
+		return opts, err
+	}
+	opts.CFlags = append(opts.CFlags, flags...)
+	if flags, err = splitCCFlags("--ldflags", ldflags); err != nil {
+		return opts, err
+	}
+	opts.LDFlags = append(opts.LDFlags, flags...)
+	if flags, err = libNames("--lib", libs); err != nil {
+		return opts, err
+	}
+	opts.Libs = append(opts.Libs, flags...)
+	return opts, nil
+}
+
+// splitCCFlags splits a flag string on whitespace and checks the flags
+// with checkCCFlags.
+func splitCCFlags(name, s string) ([]string, error) {
+	return checkCCFlags(name, strings.Fields(s))
+}
+
+// checkCCFlags rejects flags that would clash with flags the compiler
+// driver controls itself, each flag being one argument of the compiler,
+// as an element of a manifest's array is: it is not split again, so it
+// may hold spaces. name is the option or key the flags come from, for
+// errors.
+func checkCCFlags(name string, flags []string) ([]string, error) {
+	for _, f := range flags {
+		if strings.HasPrefix(f, "-o") {
+			return nil, fmt.Errorf("%s: %q is not allowed (use --output instead)", name, f)
+		}
+		if f == "" {
+			return nil, fmt.Errorf("%s: empty flag", name)
+		}
+		if strings.ContainsRune(f, 0) {
+			return nil, fmt.Errorf("%s: invalid character in %q", name, f)
+		}
+	}
+	return slices.Clone(flags), nil
+}
+
+// libNames returns the libraries libs, with or without their -l, checking
+// that each is a library name.
+func libNames(name string, libs []string) ([]string, error) {
+	var names []string
+	for _, lib := range libs {
+		lib = strings.TrimPrefix(strings.TrimSpace(lib), "-l")
+		if !libNameRe.MatchString(lib) {
+			return nil, fmt.Errorf("%s: invalid library name %q", name, lib)
+		}
+		names = append(names, lib)
+	}
+	return names, nil
+}
+
+// Args returns the flags in the order they are appended to the compiler
+// command: compile flags, linker flags, then -l libraries.
+func (o ccOptions) Args() []string {
+	args := make([]string, 0, len(o.CFlags)+len(o.LDFlags)+len(o.Libs))
+	args = append(args, o.CFlags...)
+	args = append(args, o.LDFlags...)
+	for _, lib := range o.Libs {
+		args = append(args, "-l"+lib)
+	}
+	return args
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCheckCCFlags(t *testing.T) {
+	// An element of a manifest's array is one argument, spaces and all.
+	flags := []string{"-I/opt/my include", "-DNAME=a b"}
+	got, err := checkCCFlags("org.toml: cflags", flags)
+	if err != nil || !slices.Equal(got, flags) {
+		t.Errorf("checkCCFlags(%q) = %q, %v", flags, got, err)
+	}
+	for _, bad := range [][]string{{"-O2", "-o", "x"}, {"-ofile"}, {""}, {"-I\x00"}} {
+		if _, err := checkCCFlags("org.toml: cflags", bad); err == nil {
+			t.Errorf("checkCCFlags(%q): no error", bad)
+		}
+	}
+
+	// A flag string is split on whitespace.
+	if got, err := splitCCFlags("--cflags", " -I/opt/include  -DNDEBUG "); err != nil || !slices.Equal(got, []string{"-I/opt/include", "-DNDEBUG"}) {
+		t.Errorf("splitCCFlags = %q, %v", got, err)
+	}
+	if _, err := splitCCFlags("--ldflags", "-L/opt/lib -o out"); err == nil {
+		t.Error("splitCCFlags: -o accepted")
+	}
+}
@@ -1,24 +1,164 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"orglang/pkg/codegen"
+	"orglang/pkg/lexer"
+	"orglang/pkg/lint"
+	"orglang/pkg/manifest"
+	"orglang/pkg/parser"
 
 	"github.com/spf13/cobra"
 )
 
 var checkCmd = &cobra.Command{
-	Use:     "check <input>",
-	Short:   "Static analysis (TBD)",
-	Long:    `Performs static analysis without compiling/running.`,
+	Use:   "check [flags] <inputs...>",
+	Short: "Static analysis",
+	Long: `Performs static analysis without compiling/running.
+
+Each input file, or each .org file under an input directory, is parsed and the enabled lints are run over it. The unicode
+lint reports bidirectional control characters and look-alike characters
+in identifiers and strings ("Trojan Source" attacks); it is on by default.
+The deprecated lint reports uses of bindings tagged @deprecated, in the
+file itself and in the modules it imports. The duplicate lint reports
+names bound twice in one scope (file, block or table literal), the scope
+lint uses of left, right and this outside any block. The symbols lint
+reports symbol operators such as ∑ that are neither bound in the file nor
+by a std module it uses (the use key of org.toml, or --use). As in org
+build, an import cycle through the modules a file imports is an error
+(ORG4002) at the import closing it.
+
+Rule severities come from the [lint] table of the project's org.toml and
+can be overridden with flags. A "# org:ignore [rules]" comment silences
+findings on its line, or on the next line when it stands alone.
+
+Diagnostics are grouped by file and followed by a summary. The exit code
+is 0 when there are no errors, 1 when some file has errors and 2 when the
+check itself could not run. With --json the diagnostics of all files are
+printed instead as one JSON array of objects with file, line, column,
+code, severity, message and, when there is one, hint.`,
 	Aliases: []string{"vet"},
-	Args:    cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println(headerStyle.Render("Check"))
-		printInfo("Input", args[0])
-		printInfo("Status", "TBD - Static analysis not yet implemented")
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files, err := sourceFiles(args)
+		if err != nil {
+			return err
+		}
+		strict, _ := cmd.Flags().GetBool("strict")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		var r report
+		cycles := map[string]bool{}
+		for _, path := range files {
+			if err := checkFile(cmd, &r, path, strict, cycles); err != nil {
+				return err
+			}
+		}
+		if asJSON {
+			if err := r.printJSON(os.Stdout); err != nil {
+				return err
+			}
+		} else {
+			r.print(os.Stdout)
+		}
+		return r.err("check failed")
 	},
 }
 
+// checkFile adds the parse errors and lint findings of path to r, and the
+// import cycle through the modules it imports, unless cycles already has
+// it.
+func checkFile(cmd *cobra.Command, r *report, path string, strict bool, cycles map[string]bool) error {
+	cfg, err := lintConfig(cmd, path)
+	if err != nil {
+		return err
+	}
+	src, err := lexer.ReadSource(path)
+	if err != nil {
+		return err
+	}
+
+	pre, err := preludeFor(cmd, path)
+	if err != nil {
+		return err
+	}
+
+	// Lexical errors (bad escapes, unterminated strings, non-UTF-8
+	// input) surface as ILLEGAL tokens, recorded by the lexer as the
+	// parser reads them.
+	l := lexer.New(src)
+	p := parser.New(l, parser.WithStrict(strict), parser.WithBindings(pre.Bindings))
+	prog := p.ParseProgram()
+	diags := append(l.Diagnostics(), p.Diagnostics()...)
+	var cycle *codegen.ImportCycle
+	if len(diags) == 0 {
+		// The modules are loaded as org build loads them; a module that
+		// cannot be read or parsed is reported when it is checked itself.
+		if _, err := loadModules(path, prog, strict); errors.As(err, &cycle) {
+			// The same cycle, reached from another of its modules, closes
+			// elsewhere: it is known by its modules, in any order.
+			modules := slices.Sorted(slices.Values(cycle.Chain[1:]))
+			at := strings.Join(modules, "\x00")
+			if cycles[at] {
+				cycle = nil
+			}
+			cycles[at] = true
+		}
+	}
+	for _, finding := range lint.Check(path, src, cfg, pre.Names) {
+		diags = append(diags, finding.Diagnostic())
+	}
+	sortDiagnostics(diags)
+	f := r.file(path, src)
+	for _, d := range diags {
+		f.add(d)
+	}
+	if cycle != nil {
+		module := relativePath(cycle.Module())
+		src, _ := lexer.ReadSource(module)
+		r.file(module, src).add(cycle.Diagnostic())
+	}
+	return nil
+}
+
+// lintConfig resolves the rule severities for input: the defaults, then
+// the [lint] table of the enclosing project's org.toml, then flags.
+func lintConfig(cmd *cobra.Command, input string) (lint.Config, error) {
+	cfg := lint.DefaultConfig()
+	m, err := manifest.Find(filepath.Dir(input))
+	if err != nil {
+		return nil, err
+	}
+	if m != nil {
+		if err := cfg.Set(m.Lint); err != nil {
+			return nil, fmt.Errorf("%s: %w", m.Path, err)
+		}
+	}
+	for rule := range lint.Rules {
+		if cmd.Flags().Changed(rule) {
+			level, _ := cmd.Flags().GetString(rule)
+			if err := cfg.Set(map[string]string{rule: level}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return cfg, nil
+}
+
 func init() {
+	checkCmd.Flags().String("unicode", "error", "Severity of the unicode lint: error, warning or off")
+	checkCmd.Flags().Bool("strict", true, "Report undefined identifiers")
+	checkCmd.Flags().Bool("json", false, "Print the diagnostics as a JSON array")
+	checkCmd.Flags().String("deprecated", "warning", "Severity of the deprecated lint: error, warning or off")
+	checkCmd.Flags().String("duplicate", "warning", "Severity of the duplicate lint: error, warning or off")
+	checkCmd.Flags().String("scope", "error", "Severity of the scope lint: error, warning or off")
+	checkCmd.Flags().String("symbols", "warning", "Severity of the symbols lint: error, warning or off")
+	checkCmd.Flags().StringSlice("use", nil, "Standard library modules to use (e.g. math)")
 	rootCmd.AddCommand(checkCmd)
 }
@@ -1,21 +1,122 @@
 package cmd
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"orglang/pkg/codegen"
+	"orglang/pkg/lsp"
+	"orglang/pkg/manifest"
 
 	"github.com/spf13/cobra"
 )
 
 var cleanCmd = &cobra.Command{
 	Use:   "clean",
-	Short: "Remove build artifacts (TBD)",
-	Long:  `Removes build artifacts.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Short: "Remove build artifacts",
+	Long: `Removes the build artifacts of the project in the current directory
+(that of the org.toml found there or above, else the directory itself):
+the files org build wrote for it (C sources, headers, libraries and
+binaries), wherever it wrote them, then what the cache directory holds
+for it: its module cache, where org build keeps what it compiles from
+each module so an unchanged module is not compiled again, the coverage
+profiles org test saved for its files and the symbol index org lsp keeps
+of it. Other projects' artifacts and cache entries are left alone.
+
+org build records every file it writes in the cache directory, under
+the project it built, with a hash of its contents. A file changed since
+it was built is kept, and listed, since it is no longer only the build's.
+
+The cache directory is $ORG_CACHE when set, otherwise org under the
+user's cache directory (~/.cache/org on Linux).`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := cacheDir()
+		if err != nil {
+			return err
+		}
+		root, err := projectRoot(".")
+		if err != nil {
+			return err
+		}
+		removed, kept, err := artifacts(dir, root).Remove()
 		fmt.Println(headerStyle.Render("Clean"))
-		printInfo("Status", "TBD - Clean logic not yet implemented")
+		printInfo("Project", root)
+		for _, path := range removed {
+			printInfo("Removed", relativePath(path))
+		}
+		for _, path := range kept {
+			printInfo("Kept", relativePath(path)+" (changed since it was built)")
+		}
+		if err != nil {
+			return err
+		}
+		if err := moduleCache(dir, root).Clear(); err != nil {
+			return err
+		}
+		profiles, err := removeProfiles(filepath.Join(dir, "coverage"), root)
+		if err != nil {
+			return err
+		}
+		index := lsp.IndexFile(filepath.Join(dir, "lsp"), root)
+		if err := os.Remove(index); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		printInfo("Cleared", fmt.Sprintf("the module cache, %s and the symbol index", plural(profiles, "coverage profile")))
+		return nil
 	},
 }
 
+// projectRoot returns the root of the project holding dir: the directory
+// of its org.toml, or else dir itself, made absolute. Builds are recorded,
+// and cleaned, by project root.
+func projectRoot(dir string) (string, error) {
+	m, err := manifest.Find(dir)
+	if err != nil {
+		return "", err
+	}
+	if m != nil {
+		dir = m.Dir()
+	}
+	return filepath.Abs(dir)
+}
+
+// projectKey names the entries of the project at root in the cache
+// directory.
+func projectKey(root string) string {
+	sum := sha256.Sum256([]byte(root))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// artifacts returns the record of the files the builds of the project at
+// root wrote, kept in the cache directory dir.
+func artifacts(dir, root string) *codegen.Artifacts {
+	return &codegen.Artifacts{Path: filepath.Join(dir, "artifacts", projectKey(root)+".json")}
+}
+
+// moduleCache returns the module cache of the project at root, in the
+// cache directory dir.
+func moduleCache(dir, root string) *codegen.ModuleCache {
+	return &codegen.ModuleCache{Dir: filepath.Join(dir, "modules", projectKey(root))}
+}
+
+// cacheDir returns the directory of the module cache.
+func cacheDir() (string, error) {
+	if dir := os.Getenv("ORG_CACHE"); dir != "" {
+		return filepath.Abs(dir)
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate cache directory: %w (set ORG_CACHE)", err)
+	}
+	return filepath.Join(dir, "org"), nil
+}
+
 func init() {
 	rootCmd.AddCommand(cleanCmd)
 }
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/eval"
+	"orglang/pkg/lexer"
+	"orglang/pkg/manifest"
+)
+
+// coveredFile is a test file and the coverage of its statements.
+type coveredFile struct {
+	path  string
+	src   []byte
+	stmts []eval.StatementCoverage
+}
+
+// coverageDir returns the directory of the coverage profiles org test
+// saves for org lsp.
+func coverageDir() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "coverage"), nil
+}
+
+// importedModules collects the modules test files import, for their
+// coverage. Each file runs in its own interpreter, which parses the
+// modules it imports anew: progs holds every parse of a module.
+type importedModules struct {
+	paths []string
+	progs map[string][]*ast.Program
+}
+
+// add records prog, a parse of the module at path. Dependencies are not
+// the project's modules and are left out.
+func (m *importedModules) add(path string, prog *ast.Program) {
+	if slices.Contains(strings.Split(filepath.ToSlash(path), "/"), manifest.DepsDir) {
+		return
+	}
+	if m.progs == nil {
+		m.progs = map[string][]*ast.Program{}
+	}
+	if _, ok := m.progs[path]; !ok {
+		m.paths = append(m.paths, path)
+	}
+	m.progs[path] = append(m.progs[path], prog)
+}
+
+// covered returns the coverage of each module recorded, other than the
+// test files, in the order they were first imported: a statement ran if it
+// did in any parse of the module.
+func (m *importedModules) covered(cov *eval.Coverage, tests []string) ([]coveredFile, error) {
+	var files []coveredFile
+	for _, path := range m.paths {
+		if slices.ContainsFunc(tests, func(t string) bool { return sameFile(t, path) }) {
+			continue
+		}
+		src, err := lexer.ReadSource(path)
+		if err != nil {
+			return nil, err
+		}
+		f := coveredFile{path: path, src: src}
+		for _, prog := range m.progs[path] {
+			stmts := cov.Statements(prog)
+			if f.stmts == nil {
+				f.stmts = stmts
+				continue
+			}
+			for i := range min(len(stmts), len(f.stmts)) {
+				f.stmts[i].Ran = f.stmts[i].Ran || stmts[i].Ran
+			}
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// sameFile reports whether the paths a and b name the same file.
+func sameFile(a, b string) bool {
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	ib, err := os.Stat(b)
+	return err == nil && os.SameFile(ia, ib)
+}
+
+// removeProfiles removes the coverage profiles, in dir, of the modules
+// under root, and returns how many there were.
+func removeProfiles(dir, root string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return removed, err
+		}
+		var p eval.Profile
+		if json.Unmarshal(data, &p) != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, p.Source); err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// saveProfiles saves the coverage of files for org lsp to show.
+func saveProfiles(files []coveredFile) error {
+	dir, err := coverageDir()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		path, err := filepath.Abs(f.path)
+		if err != nil {
+			return err
+		}
+		p := &eval.Profile{Source: path, Hash: eval.SourceHash(f.src), Statements: f.stmts}
+		if err := eval.SaveProfile(dir, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printCoverage prints the share of statements that ran in each file,
+// and in all of them together if there are several.
+func printCoverage(files []coveredFile) {
+	allRan, allTotal := 0, 0
+	for _, f := range files {
+		ran, total := eval.CoveredCount(f.stmts)
+		allRan, allTotal = allRan+ran, allTotal+total
+		printInfo("Coverage", relativePath(f.path)+" "+coverageShare(ran, total))
+	}
+	if len(files) > 1 {
+		printInfo("Coverage", "total "+coverageShare(allRan, allTotal))
+	}
+}
+
+func coverageShare(ran, total int) string {
+	if total == 0 {
+		return "no statements"
+	}
+	return fmt.Sprintf("%.1f%% of statements (%d/%d)", 100*float64(ran)/float64(total), ran, total)
+}
+
+var coverageTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>OrgLang coverage</title>
+<style>
+body { font-family: sans-serif; }
+pre { background: #f8f8f8; padding: 0.5em; }
+.ran { background: #d4f4d4; }
+.missed { background: #f8d0d0; }
+.line { color: #999; user-select: none; }
+</style>
+</head>
+<body>
+{{range .}}<h2>{{.Path}} <small>{{.Share}}</small></h2>
+<pre>{{range .Lines}}<span class="line">{{printf "%4d" .Number}}</span> <span class="{{.Class}}">{{.Text}}</span>
+{{end}}</pre>
+{{end}}</body>
+</html>
+`))
+
+// writeCoverageHTML writes the coverage of files to path as an HTML page:
+// each file's source, lines whose statements all ran in green and those
+// with one that did not in red.
+func writeCoverageHTML(path string, files []coveredFile) error {
+	type line struct {
+		Number      int
+		Text, Class string
+	}
+	type page struct {
+		Path, Share string
+		Lines       []line
+	}
+	var pages []page
+	for _, f := range files {
+		covered := eval.CoveredLines(f.stmts)
+		p := page{Path: relativePath(f.path), Share: coverageShare(eval.CoveredCount(f.stmts))}
+		for i, text := range bytes.Split(bytes.TrimSuffix(f.src, []byte("\n")), []byte("\n")) {
+			l := line{Number: i + 1, Text: string(text)}
+			if ran, ok := covered[i+1]; ok {
+				l.Class = "missed"
+				if ran {
+					l.Class = "ran"
+				}
+			}
+			p.Lines = append(p.Lines, l)
+		}
+		pages = append(pages, p)
+	}
+	var out bytes.Buffer
+	if err := coverageTemplate.Execute(&out, pages); err != nil {
+		return err
+	}
+	return os.WriteFile(path, out.Bytes(), 0o644)
+}
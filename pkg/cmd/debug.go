@@ -0,0 +1,379 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/codegen"
+	"orglang/pkg/eval"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+
+	"github.com/spf13/cobra"
+)
+
+var debugCmd = &cobra.Command{
+	Use:   "debug [flags] <input> [args...]",
+	Short: "Run an OrgLang program under the debugger",
+	Long: `Runs the OrgLang program as org run does, stopping between its statements
+to show where it is and the values of its bindings.
+
+OrgLang programs are not compiled to binaries yet, so the program runs with
+the interpreter (pkg/eval), which keeps the source position of every
+statement: the debugger stops at the statements of the input file, at the
+top level and in its blocks, and not in those of the prelude or of --use
+modules.
+
+The program stops at its first statement, or with --break at the first
+line given. Commands are then read from stdin, one per line:
+
+  break, b <line>     stop at the statement starting on line
+  clear <line>        remove the breakpoint on line
+  continue, c         run to the next breakpoint
+  step, s             run to the next statement, into the blocks called
+  next, n             run to the next statement of this block or above
+  print, p <name>     show the value of name, evaluating it if needed
+  bindings            show the program's bindings visible here
+  where               show the statement stopped at and the call depth
+  quit, q             stop the program
+  help                list the commands
+
+print also takes the C symbol of a global binding, the accessor generated
+C has for it, so org_v4_main_rows is the binding rows of main.org. An empty line repeats the last command; the end
+of the input continues to the end of the program.
+
+The program's @stdin is the same as the debugger's: it reads what follows
+the commands given so far.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		input := args[0]
+		breaks, _ := cmd.Flags().GetIntSlice("break")
+
+		src, err := lexer.ReadSource(input)
+		if err != nil {
+			return err
+		}
+		pre, err := preludeFor(cmd, input)
+		if err != nil {
+			return err
+		}
+		l := lexer.New(src)
+		p := parser.New(l, parser.WithStrict(true), parser.WithBindings(pre.Bindings))
+		own := p.ParseProgram()
+		if diags := append(l.Diagnostics(), p.Diagnostics()...); len(diags) > 0 {
+			printDiagnostics(os.Stderr, input, src, diags)
+			return failed("debug failed")
+		}
+		prog := pre.Apply(&ast.Program{Statements: own.Statements})
+
+		in := eval.New()
+		in.Args = args[1:]
+		in.Modules, in.File = moduleLoader(cmd), input
+		d := newDebugSession(in, input, src, own, os.Stdin, os.Stdout)
+		for _, line := range breaks {
+			d.setBreak(line)
+		}
+		if len(breaks) == 0 {
+			d.mode = debugStep
+		}
+		in.Debugger = d
+
+		code, quit := d.run(prog)
+		if quit {
+			fmt.Fprintln(d.out, subtextStyle.Render("program stopped"))
+			return nil
+		}
+		fmt.Fprintln(d.out, subtextStyle.Render(fmt.Sprintf("program exited with status %d", code)))
+		if code != eval.ExitOK {
+			return exitStatus(code)
+		}
+		return nil
+	},
+}
+
+// How a debugged program runs until it next stops.
+const (
+	debugContinue = iota // to a breakpoint
+	debugStep            // to the next statement
+	debugNext            // to the next statement at most as deep
+)
+
+// debugQuit is raised out of the interpreter by the quit command.
+type debugQuit struct{}
+
+// debugSession is the eval.Debugger of org debug: it decides where the
+// program stops and reads the commands given there.
+type debugSession struct {
+	in     *eval.Interp
+	file   string
+	module string
+	src    []byte
+	own    map[ast.Statement]bool // the statements of the input file
+	lines  map[int]bool           // the lines starting one
+	global []string               // the names the file binds at the top level
+	breaks map[int]bool
+
+	mode  int
+	depth int // of the stop a next started at
+	last  string
+	cmds  io.Reader
+	out   io.Writer
+}
+
+func newDebugSession(in *eval.Interp, file string, src []byte, own *ast.Program, cmds io.Reader, out io.Writer) *debugSession {
+	d := &debugSession{
+		in:     in,
+		file:   file,
+		module: codegen.ModuleName(file),
+		src:    src,
+		own:    map[ast.Statement]bool{},
+		lines:  map[int]bool{},
+		breaks: map[int]bool{},
+		cmds:   cmds,
+		out:    out,
+	}
+	add := func(body []ast.Statement) {
+		for _, s := range body {
+			d.own[s] = true
+			if !s.Location().IsZero() {
+				d.lines[s.Location().Start.Line] = true
+			}
+		}
+	}
+	add(own.Statements)
+	for _, s := range own.Statements {
+		ast.Inspect(s, func(n ast.Node) bool {
+			if fl, ok := n.(*ast.FunctionLiteral); ok {
+				add(fl.Body)
+			}
+			return true
+		})
+		switch b := s.(type) {
+		case *ast.BindingExpr:
+			d.global = append(d.global, b.Name.String())
+		case *ast.ResourceDef:
+			d.global = append(d.global, b.Name.String())
+		}
+	}
+	return d
+}
+
+// run runs prog, its main if it has one, and returns its exit status, or
+// quit true if the quit command stopped it.
+func (d *debugSession) run(prog *ast.Program) (code int, quit bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(debugQuit); !ok {
+				panic(r)
+			}
+			quit = true
+		}
+	}()
+	if slices.Contains(d.global, "main") {
+		return d.in.Run(prog, d.file), false
+	}
+	if err, ok := d.in.Force(d.in.Eval(prog)).(*eval.Error); ok {
+		fmt.Fprintln(d.in.Stderr, err)
+		return eval.ExitError, false
+	}
+	return eval.ExitOK, false
+}
+
+// Stop is called before each statement; it stops at those of the input
+// file where the mode or a breakpoint says to.
+func (d *debugSession) Stop(s ast.Statement, f eval.Frame) {
+	if !d.own[s] {
+		return
+	}
+	line := s.Location().Start.Line
+	switch {
+	case d.mode == debugStep:
+	case d.mode == debugNext && f.Depth <= d.depth:
+	case d.breaks[line]:
+	default:
+		return
+	}
+	d.where(s, f)
+	for {
+		fmt.Fprint(d.out, subtextStyle.Render("(org)")+" ")
+		text, ok := d.readLine()
+		if !ok {
+			fmt.Fprintln(d.out)
+			d.mode = debugContinue
+			clear(d.breaks)
+			return
+		}
+		if text = strings.TrimSpace(text); text == "" {
+			text = d.last
+		}
+		d.last = text
+		if d.command(text, s, f) {
+			return
+		}
+	}
+}
+
+// command runs one command at s, and reports whether the program goes on.
+func (d *debugSession) command(text string, s ast.Statement, f eval.Frame) bool {
+	name, arg, _ := strings.Cut(text, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case "":
+	case "continue", "c":
+		d.mode = debugContinue
+		return true
+	case "step", "s":
+		d.mode = debugStep
+		return true
+	case "next", "n":
+		d.mode, d.depth = debugNext, f.Depth
+		return true
+	case "break", "b", "clear":
+		line, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Fprintf(d.out, "%s needs a line number\n", name)
+		} else if name == "clear" {
+			delete(d.breaks, line)
+		} else {
+			d.setBreak(line)
+		}
+	case "print", "p":
+		d.print(arg, f)
+	case "bindings":
+		d.bindings(f)
+	case "where":
+		d.where(s, f)
+	case "quit", "q":
+		panic(debugQuit{})
+	case "help", "h":
+		fmt.Fprintln(d.out, "break <line>, clear <line>, continue, step, next, print <name>, bindings, where, quit")
+	default:
+		fmt.Fprintf(d.out, "unknown command %q (help lists them)\n", name)
+	}
+	return false
+}
+
+// setBreak sets a breakpoint on line, which should start a statement.
+func (d *debugSession) setBreak(line int) {
+	if !d.lines[line] {
+		fmt.Fprintf(d.out, "no statement starts on line %d of %s\n", line, relativePath(d.file))
+		return
+	}
+	d.breaks[line] = true
+}
+
+// where shows the position of s and its source line.
+func (d *debugSession) where(s ast.Statement, f eval.Frame) {
+	pos := s.Location().Start
+	at := fmt.Sprintf("%s:%d", relativePath(d.file), pos.Line)
+	if f.Depth > 0 {
+		at += fmt.Sprintf(" (depth %d)", f.Depth)
+	}
+	fmt.Fprintf(d.out, "%s\n  %s\n", headerStyle.Render(at), d.sourceLine(pos.Offset))
+}
+
+// sourceLine returns the line of the source holding offset.
+func (d *debugSession) sourceLine(offset int) string {
+	start := strings.LastIndexByte(string(d.src[:offset]), '\n') + 1
+	end := len(d.src)
+	if i := strings.IndexByte(string(d.src[offset:]), '\n'); i >= 0 {
+		end = offset + i
+	}
+	return strings.TrimSpace(string(d.src[start:end]))
+}
+
+// print shows the value of name, or of the global binding whose C symbol
+// it is.
+func (d *debugSession) print(name string, f eval.Frame) {
+	if name == "" {
+		fmt.Fprintln(d.out, "print needs a name")
+		return
+	}
+	if source, ok := d.sourceName(name); ok {
+		name = source
+	}
+	v, ok := d.in.Lookup(f.Env, name)
+	if !ok {
+		fmt.Fprintf(d.out, "%s is not bound here\n", name)
+		return
+	}
+	fmt.Fprintf(d.out, "%s = %s\n", name, d.in.Force(v))
+}
+
+// sourceName maps the C symbol of a global binding of the file back to
+// its name.
+func (d *debugSession) sourceName(symbol string) (string, bool) {
+	for _, name := range d.global {
+		if codegen.GlobalSymbol(d.module, name) == symbol {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// bindings shows the bindings visible from f, innermost first, without
+// evaluating them: those of the blocks called, then the file's own.
+func (d *debugSession) bindings(f eval.Frame) {
+	for env := f.Env; env != nil; env = env.Parent() {
+		names := env.Names()
+		if env == d.in.Global {
+			names = slices.DeleteFunc(slices.Clone(d.global), func(n string) bool { return !slices.Contains(names, n) })
+		}
+		for _, name := range names {
+			v, pending, _ := env.Evaluated(name)
+			value := subtextStyle.Render("(not evaluated)")
+			if !pending {
+				value = brief(v.String())
+			}
+			fmt.Fprintf(d.out, "  %s = %s\n", name, value)
+		}
+	}
+}
+
+// brief returns the first line of s, cut to fit a line of the listing.
+func brief(s string) string {
+	const max = 60
+	cut := false
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s, cut = s[:i], true
+	}
+	if r := []rune(s); len(r) > max {
+		s, cut = string(r[:max]), true
+	}
+	if cut {
+		s += "…"
+	}
+	return s
+}
+
+// readLine reads a command from cmds a byte at a time, so that the
+// program reads what follows it.
+func (d *debugSession) readLine() (string, bool) {
+	var b strings.Builder
+	buf := make([]byte, 1)
+	for {
+		n, err := d.cmds.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				return b.String(), true
+			}
+			b.WriteByte(buf[0])
+		}
+		if err != nil {
+			return b.String(), b.Len() > 0
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(debugCmd)
+	debugCmd.Flags().IntSliceP("break", "b", nil, "Lines of the input to stop at (e.g. -b 12,30)")
+	debugCmd.Flags().StringSlice("use", nil, "Standard library modules to use (e.g. math)")
+	// Flags after the input belong to the program.
+	debugCmd.Flags().SetInterspersed(false)
+}
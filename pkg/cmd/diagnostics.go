@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"orglang/pkg/diag"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// diagStyles renders diagnostics in the palette of the other commands.
+var diagStyles = diag.Styles{
+	Error:   render(lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true)),
+	Warning: render(lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true)),
+	Message: render(lipgloss.NewStyle().Bold(true)),
+	Gutter:  render(headerStyle),
+	Hint:    render(subtextStyle),
+}
+
+// styles returns diagStyles with the tab width of $ORG_TAB_WIDTH, when it
+// is a number: by default tabs are printed as they are.
+func styles() diag.Styles {
+	st := diagStyles
+	if n, err := strconv.Atoi(os.Getenv("ORG_TAB_WIDTH")); err == nil {
+		st.TabWidth = n
+	}
+	return st
+}
+
+func render(s lipgloss.Style) func(string) string {
+	return func(text string) string { return s.Render(text) }
+}
+
+// printDiagnostics writes diags, found in src read from path, with their
+// source lines, each followed by a blank line.
+func printDiagnostics(w io.Writer, path string, src []byte, diags []diag.Diagnostic) {
+	st := styles()
+	for _, d := range diags {
+		diag.Render(w, d, path, src, st)
+		fmt.Fprintln(w)
+	}
+}
+
+// renderDiagnostic returns d as printDiagnostics writes it, without the
+// final newline.
+func renderDiagnostic(d diag.Diagnostic, path string, src []byte) string {
+	var b strings.Builder
+	diag.Render(&b, d, path, src, styles())
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// sortDiagnostics orders diags by position, keeping the order of those at
+// the same one.
+func sortDiagnostics(diags []diag.Diagnostic) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i].Span.Start, diags[j].Span.Start
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+}
@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"orglang/pkg/manifest"
+	orgruntime "orglang/pkg/runtime"
+
+	"github.com/spf13/cobra"
+)
+
+var distCmd = &cobra.Command{
+	Use:   "dist [flags]",
+	Short: "Build release archives of a project's binaries",
+	Long: `Builds the binaries of the project in the current directory or above for
+each target of --targets, os/arch pairs, and packages them for release:
+one <name>_<version>_<os>_<arch>.tar.gz archive per target (.zip for
+windows), holding the binaries, the project's LICENSE files and the
+notice of the OrgLang runtime (RUNTIME-NOTICE), with a checksums.txt of
+their SHA-256 next to them, in the --output directory, dist under the
+project root by default.
+
+The binaries are those org install installs: the [[target]]s of the
+org.toml, else the binary of its main key. They are built as by org
+build, with the same flags. The target of the machine org runs on,
+the default, uses the C compiler of the build (--cc, $ORG_CC or the
+first found); any other is built by the cross compiler
+$ORG_CC_<OS>_<ARCH> gives (ORG_CC_LINUX_ARM64="aarch64-linux-gnu-gcc",
+say), which must find GMP built for that target.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := manifest.Find(".")
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			return fmt.Errorf("no %s found (see org init)", manifest.FileName)
+		}
+		bins, err := projectBinaries(m, nil)
+		if err != nil {
+			return err
+		}
+		if m.Name == "" || m.Version == "" {
+			return fmt.Errorf("%s needs a name and a version key, which name the archives", m.Path)
+		}
+		list, _ := cmd.Flags().GetStringSlice("targets")
+		backend, _ := cmd.Flags().GetString("backend")
+		var targets []distTarget
+		for _, t := range list {
+			target, err := parseTarget(t)
+			if err != nil {
+				return err
+			}
+			if !target.host() && backend != "c" {
+				return fmt.Errorf("--backend=%s builds for %s/%s only, not %s", backend, runtime.GOOS, runtime.GOARCH, target)
+			}
+			targets = append(targets, target)
+		}
+		out, _ := cmd.Flags().GetString("output")
+		if !cmd.Flags().Changed("output") {
+			out = filepath.Join(m.Dir(), out)
+		}
+		licenses, err := filepath.Glob(filepath.Join(m.Dir(), "LICENSE*"))
+		if err != nil {
+			return err
+		}
+
+		hostCC, _ := cmd.Flags().GetString("cc")
+		var archives []string
+		for _, target := range targets {
+			cc := hostCC
+			if !target.host() {
+				if cc = os.Getenv(target.ccVar()); cc == "" {
+					return fmt.Errorf("no C compiler for %s: set %s to a cross compiler for it, with GMP built for %s%s", target, target.ccVar(), target, target.ccHint())
+				}
+			}
+			if err := cmd.Flags().Set("cc", cc); err != nil {
+				return err
+			}
+			stem := fmt.Sprintf("%s_%s_%s_%s", m.Name, m.Version, target.os, target.arch)
+			archive, err := distArchive(cmd, out, stem, target, bins, licenses)
+			if err != nil {
+				return err
+			}
+			archives = append(archives, archive)
+		}
+		sums := filepath.Join(out, "checksums.txt")
+		if err := writeChecksums(sums, archives); err != nil {
+			return err
+		}
+		if err := recordArtifacts(m.Path, append(archives, sums)); err != nil {
+			return err
+		}
+		fmt.Println(headerStyle.Render("Dist"))
+		for _, archive := range archives {
+			printInfo("Archive", relativePath(archive))
+		}
+		printInfo("Checksums", relativePath(sums))
+		return nil
+	},
+}
+
+// distArchive builds bins for target in the directory out/stem and
+// archives them there with the licenses and the runtime's notice, under
+// stem, returning the archive's path. The directory is removed after.
+func distArchive(cmd *cobra.Command, out, stem string, target distTarget, bins []binary, licenses []string) (string, error) {
+	stage := filepath.Join(out, stem)
+	if err := os.RemoveAll(stage); err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(stage)
+	var files []string
+	for _, b := range bins {
+		exe := filepath.Join(stage, b.name+target.exeSuffix())
+		if err := buildInput(cmd, relativePath(b.input), relativePath(exe), b.target); err != nil {
+			return "", err
+		}
+		files = append(files, exe)
+	}
+	notice := filepath.Join(stage, "RUNTIME-NOTICE")
+	if err := os.WriteFile(notice, []byte(orgruntime.Notice), 0o644); err != nil {
+		return "", err
+	}
+	archive := filepath.Join(out, stem+target.archiveExt())
+	return archive, writeArchive(archive, stem, append(append(files, licenses...), notice))
+}
+
+// distTarget is an os/arch target of org dist, as Go names them.
+type distTarget struct{ os, arch string }
+
+var targetPartRe = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// parseTarget parses an "os/arch" target.
+func parseTarget(t string) (distTarget, error) {
+	goos, goarch, ok := strings.Cut(t, "/")
+	if !ok || !targetPartRe.MatchString(goos) || !targetPartRe.MatchString(goarch) {
+		return distTarget{}, fmt.Errorf("invalid target %q (expected os/arch, e.g. linux/amd64)", t)
+	}
+	return distTarget{goos, goarch}, nil
+}
+
+func (t distTarget) String() string { return t.os + "/" + t.arch }
+
+// host reports whether t is the machine org runs on.
+func (t distTarget) host() bool { return t.os == runtime.GOOS && t.arch == runtime.GOARCH }
+
+// ccVar is the environment variable giving the C compiler of t.
+func (t distTarget) ccVar() string {
+	return "ORG_CC_" + strings.ToUpper(t.os) + "_" + strings.ToUpper(t.arch)
+}
+
+// zigTargets are the zig cc -target triples of common targets.
+var zigTargets = map[string]string{
+	"linux/amd64":   "x86_64-linux-gnu",
+	"linux/arm64":   "aarch64-linux-gnu",
+	"darwin/amd64":  "x86_64-macos",
+	"darwin/arm64":  "aarch64-macos",
+	"windows/amd64": "x86_64-windows-gnu",
+}
+
+// ccHint suggests a compiler for t, for the error when there is none.
+func (t distTarget) ccHint() string {
+	if triple, ok := zigTargets[t.String()]; ok {
+		return fmt.Sprintf(" (e.g. %s=\"zig cc -target %s\")", t.ccVar(), triple)
+	}
+	return ""
+}
+
+func (t distTarget) exeSuffix() string {
+	if t.os == "windows" {
+		return ".exe"
+	}
+	return ""
+}
+
+func (t distTarget) archiveExt() string {
+	if t.os == "windows" {
+		return ".zip"
+	}
+	return ".tar.gz"
+}
+
+// writeArchive writes the files into the archive path, a .zip or else a
+// gzipped tar, under the directory dir. The binaries keep their mode, so
+// they stay executable once extracted.
+func writeArchive(path, dir string, files []string) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if strings.HasSuffix(path, ".zip") {
+		zw := zip.NewWriter(f)
+		for _, file := range files {
+			if err := addFile(file, func(info os.FileInfo) (io.Writer, error) {
+				h, err := zip.FileInfoHeader(info)
+				if err != nil {
+					return nil, err
+				}
+				h.Name = dir + "/" + info.Name()
+				h.Method = zip.Deflate
+				return zw.CreateHeader(h)
+			}); err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+	}
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	for _, file := range files {
+		if err := addFile(file, func(info os.FileInfo) (io.Writer, error) {
+			h, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return nil, err
+			}
+			h.Name = dir + "/" + info.Name()
+			h.Uname, h.Gname = "", ""
+			return tw, tw.WriteHeader(h)
+		}); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// addFile copies file into the writer entry returns for it.
+func addFile(file string, entry func(os.FileInfo) (io.Writer, error)) error {
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	w, err := entry(info)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, in)
+	return err
+}
+
+// writeChecksums writes the SHA-256 of each file to path, one
+// "<hex>  <name>" line each, as sha256sum prints them.
+func writeChecksums(path string, files []string) error {
+	var b strings.Builder
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(&b, "%s  %s\n", hex.EncodeToString(sum[:]), filepath.Base(file))
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
+
+func init() {
+	rootCmd.AddCommand(distCmd)
+	distCmd.Flags().StringSlice("targets", []string{runtime.GOOS + "/" + runtime.GOARCH}, "Target matrix (os/arch), this machine's by default")
+	distCmd.Flags().StringP("output", "o", "dist", "Output directory for archives (default dist under the project root)")
+	addBuildFlags(distCmd)
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTarget(t *testing.T) {
+	target, err := parseTarget("linux/arm64")
+	if err != nil || target.String() != "linux/arm64" || target.ccVar() != "ORG_CC_LINUX_ARM64" {
+		t.Errorf("parseTarget(linux/arm64) = %v (%s), %v", target, target.ccVar(), err)
+	}
+	if w, _ := parseTarget("windows/amd64"); w.archiveExt() != ".zip" || w.exeSuffix() != ".exe" {
+		t.Errorf("windows/amd64: archive %s, executable suffix %q", w.archiveExt(), w.exeSuffix())
+	}
+	for _, bad := range []string{"linux", "linux/", "/amd64", "linux/arm64/v8", "Linux/amd64", "../x"} {
+		if _, err := parseTarget(bad); err == nil {
+			t.Errorf("parseTarget(%q): no error", bad)
+		}
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	dir := t.TempDir()
+	tool := filepath.Join(dir, "tool")
+	notice := filepath.Join(dir, "RUNTIME-NOTICE")
+	if err := os.WriteFile(tool, []byte("binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(notice, []byte("notice"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]os.FileMode{"tool_1.0.0_linux_amd64/tool": 0o755, "tool_1.0.0_linux_amd64/RUNTIME-NOTICE": 0o644}
+
+	tgz := filepath.Join(dir, "tool_1.0.0_linux_amd64.tar.gz")
+	if err := writeArchive(tgz, "tool_1.0.0_linux_amd64", []string{tool, notice}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(tgz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	got := map[string]os.FileMode{}
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[h.Name] = os.FileMode(h.Mode).Perm()
+	}
+	if len(got) != len(want) || got["tool_1.0.0_linux_amd64/tool"] != 0o755 || got["tool_1.0.0_linux_amd64/RUNTIME-NOTICE"] != 0o644 {
+		t.Errorf("tar.gz holds %v, want %v", got, want)
+	}
+
+	zipped := filepath.Join(dir, "tool_1.0.0_windows_amd64.zip")
+	if err := writeArchive(zipped, "tool_1.0.0_windows_amd64", []string{tool}); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.OpenReader(zipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	if len(zr.File) != 1 || zr.File[0].Name != "tool_1.0.0_windows_amd64/tool" {
+		t.Errorf("zip holds %v", zr.File)
+	}
+
+	sums := filepath.Join(dir, "checksums.txt")
+	if err := writeChecksums(sums, []string{tool}); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("binary"))
+	if data, _ := os.ReadFile(sums); string(data) != hex.EncodeToString(sum[:])+"  tool\n" {
+		t.Errorf("checksums.txt = %q", data)
+	}
+}
@@ -1,25 +1,125 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
+
+	"orglang/pkg/doc"
 
 	"github.com/spf13/cobra"
 )
 
 var docCmd = &cobra.Command{
-	Use:   "doc <input>",
-	Short: "Generate documentation (TBD)",
-	Long:  `Generates documentation from docstrings.`,
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println(headerStyle.Render("Doc"))
-		printInfo("Input", args[0])
-		printInfo("Status", "TBD - Documentation generator not yet implemented")
+	Use:   "doc [flags] <input...>",
+	Short: "Generate documentation",
+	Long: `Generates documentation from docstrings.
+
+Each input is a .org file or a directory of modules. A docstring placed
+right before a binding documents it; a leading docstring documents the module.
+With --html a cross-linked static site is written to the output directory.
+With --diff the exported bindings of two versions are compared.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if diff, _ := cmd.Flags().GetBool("diff"); diff {
+			return runDocDiff(cmd, args)
+		}
+
+		mods, err := doc.Load(args...)
+		if err != nil {
+			return err
+		}
+
+		asHTML, _ := cmd.Flags().GetBool("html")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		switch {
+		case asJSON:
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(mods)
+		case asHTML:
+			out, _ := cmd.Flags().GetString("output")
+			title, _ := cmd.Flags().GetString("title")
+			if err := doc.NewSite(title, mods).Write(out); err != nil {
+				return err
+			}
+			fmt.Println(headerStyle.Render("Doc"))
+			printInfo("Modules", fmt.Sprintf("%d", len(mods)))
+			printInfo("Output", out)
+			return nil
+		}
+
+		for _, m := range mods {
+			printHeader(m.Name)
+			if m.Doc != "" {
+				fmt.Println(m.Doc)
+			}
+			for _, b := range m.Bindings {
+				fmt.Printf("  %s %s\n", b.Name, subtextStyle.Render(b.Kind))
+				if b.Doc != "" {
+					fmt.Printf("      %s\n", doc.Summary(b.Doc))
+				}
+			}
+			fmt.Println()
+		}
+		return nil
 	},
 }
 
+// runDocDiff reports the API changes between two versions of a module
+// (two files) or of a project (two directories, modules paired by name).
+func runDocDiff(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("--diff takes exactly two inputs: <old> <new>")
+	}
+	old, err := doc.Load(args[0])
+	if err != nil {
+		return err
+	}
+	new, err := doc.Load(args[1])
+	if err != nil {
+		return err
+	}
+	if len(old) == 1 && len(new) == 1 {
+		// Two single files: compare them whatever their names.
+		new[0].Name = old[0].Name
+	}
+	changes := doc.DiffModules(old, new)
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(changes)
+	}
+
+	fmt.Println(headerStyle.Render("API Diff"))
+	printInfo("Old", args[0])
+	printInfo("New", args[1])
+	if len(changes) == 0 {
+		fmt.Println(subtextStyle.Render("No API changes."))
+		return nil
+	}
+	marks := map[doc.ChangeKind]string{doc.Added: "+", doc.Removed: "-", doc.Changed: "~"}
+	for _, c := range changes {
+		target := c.Module
+		if c.Name != "" {
+			target += "." + c.Name
+		}
+		fmt.Printf("%s %s", marks[c.Kind], target)
+		if len(c.Details) > 0 {
+			fmt.Printf(" %s", subtextStyle.Render("("+strings.Join(c.Details, "; ")+")"))
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(docCmd)
 	docCmd.Flags().Bool("html", false, "Output HTML")
 	docCmd.Flags().Bool("json", false, "Output JSON")
+	docCmd.Flags().StringP("output", "o", "doc", "Output directory for --html")
+	docCmd.Flags().Bool("diff", false, "Compare the API of two versions: org doc --diff <old> <new>")
+	docCmd.Flags().String("title", "OrgLang Documentation", "Site title for --html")
 }
@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/codegen"
+	"orglang/pkg/diag"
+	"orglang/pkg/ir"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+	orgruntime "orglang/pkg/runtime"
+
+	"github.com/spf13/cobra"
+)
+
+// emitStages are the stages org build --emit stops after, in pipeline
+// order; c and llvm are the code of the backend of that name.
+var emitStages = []string{"tokens", "ast", "ir", "c", "llvm", "obj"}
+
+// checkEmit validates the stage given to --emit.
+func checkEmit(stage string) error {
+	if stage != "" && !slices.Contains(emitStages, stage) {
+		return fmt.Errorf("--emit: want tokens, ast, ir, c, llvm or obj, got %q", stage)
+	}
+	return nil
+}
+
+// backendFor returns the backend of --backend, or the one named by the
+// --emit stage, which --backend cannot contradict.
+func backendFor(cmd *cobra.Command, stage string) (codegen.Backend, error) {
+	name, _ := cmd.Flags().GetString("backend")
+	if stage == "c" || stage == "llvm" {
+		if cmd.Flags().Changed("backend") && name != stage {
+			return codegen.Backend{}, fmt.Errorf("--emit=%s prints the code of the %s backend, not of --backend=%s", stage, stage, name)
+		}
+		name = stage
+	}
+	b, err := codegen.FindBackend(name)
+	if err != nil {
+		return b, fmt.Errorf("--backend: %v", err)
+	}
+	return b, nil
+}
+
+// emit runs the build of input up to stage and writes what that stage
+// produced to output, or to stdout if output is "", instead of building a
+// binary. The code of a program can also be written to a directory, the
+// output when it is one or ends in a separator: each module of the
+// program then has a file there, named by moduleFile, compiling on its
+// own. Errors of the stages run fail it after the output is written,
+// as org lex and org ast do, since the output shows where they went wrong.
+//
+// An object file is compiled at the -O level of the build, by the C
+// compiler from the C, or by llc from the LLVM IR.
+func emit(cmd *cobra.Command, stage, input, output string) error {
+	backend, err := backendFor(cmd, stage)
+	if err != nil {
+		return err
+	}
+	optimize, _ := cmd.Flags().GetInt("optimize")
+	var obj *objCompiler
+	if stage == "obj" {
+		if output == "" {
+			return fmt.Errorf("--emit=obj: an object file is written to --output, not to stdout")
+		}
+		if backend.Name == "c" {
+			cc, err := ccOptionsFromFlags(cmd, input, nil)
+			if err != nil {
+				return err
+			}
+			include, err := os.MkdirTemp("", "org-runtime")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(include)
+			if _, err := orgruntime.Write(include); err != nil {
+				return err
+			}
+			obj = ccCompiler(cc, include, optimize)
+		} else {
+			llc, err := codegen.FindLLC()
+			if err != nil {
+				return err
+			}
+			obj = llcCompiler(llc, optimize)
+		}
+	}
+	dir := ""
+	if (stage == "c" || stage == "llvm" || stage == "obj") && output != "" {
+		if info, err := os.Stat(output); (err == nil && info.IsDir()) || os.IsPathSeparator(output[len(output)-1]) {
+			dir = output
+		}
+	}
+	src, err := lexer.ReadSource(input)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	var files []string
+	var diags []diag.Diagnostic
+	switch stage {
+	case "tokens":
+		l := lexer.New(src)
+		if err := writeTokens(&out, l.Tokenize()); err != nil {
+			return err
+		}
+		diags = l.Diagnostics()
+	case "ast", "ir", "c", "llvm", "obj":
+		pre, err := preludeFor(cmd, input)
+		if err != nil {
+			return err
+		}
+		strict, _ := cmd.Flags().GetBool("strict")
+		l := lexer.New(src)
+		p := parser.New(l, parser.WithStrict(strict), parser.WithBindings(pre.Bindings))
+		prog := p.ParseProgram()
+		diags = append(l.Diagnostics(), p.Diagnostics()...)
+		if stage == "ast" {
+			ast.Dump(&out, prog)
+			break
+		}
+		// The std modules' bindings come first, as for org run; only the
+		// program's own constructs are reported.
+		parsed := len(diags) == 0
+		debug, _ := cmd.Flags().GetBool("debug")
+		asserts := codegen.Assertions(debug, optimize)
+		m, lowered := ir.Lower(pre.Apply(prog), input, ir.WithAssertions(asserts))
+		diags = append(diags, lowered...)
+		if stage == "ir" {
+			out.WriteString(m.String())
+			break
+		}
+		// The symbols of the modules the program imports, which come
+		// before it, are checked against the program's. Their code is
+		// written too when the output is a directory, a file per module.
+		syms := codegen.NewSymbolTable()
+		var modules []*codegen.Module
+		if parsed {
+			if modules, err = loadModules(input, prog, strict); err != nil && dir != "" {
+				return err
+			}
+			if len(modules) > 0 {
+				modules = modules[:len(modules)-1]
+			}
+			declareModules(syms, modules)
+		}
+		ext := backend.Ext
+		if stage == "obj" {
+			ext = ".o"
+		}
+		if dir != "" {
+			written, err := emitModules(dir, ext, modules, syms, backend, obj, asserts)
+			if err != nil {
+				return err
+			}
+			output = filepath.Join(dir, moduleFile(input, ext))
+			files = append(written, output)
+		}
+		if err := backend.Print(&out, m, codeFile(output, backend), syms); err != nil {
+			return err
+		}
+	}
+
+	if output == "" {
+		if _, err := os.Stdout.Write(out.Bytes()); err != nil {
+			return err
+		}
+	} else {
+		if err := writeCode(output, out.Bytes(), obj); err != nil {
+			return err
+		}
+		if files == nil {
+			files = []string{output}
+		}
+		if err := recordArtifacts(input, files); err != nil {
+			return err
+		}
+	}
+	if len(diags) > 0 {
+		sortDiagnostics(diags)
+		printDiagnostics(os.Stderr, input, src, diags)
+		return failed("build failed")
+	}
+	return nil
+}
+
+// emitModules writes the code of modules, imported by the program, to
+// dir, creating it, and returns the files written: the backend's code,
+// or the object files obj compiles from it when obj is not nil. Their
+// assert statements are checked if asserts holds. The diagnostics of a
+// module are printed, and fail the build, as the program's do.
+func emitModules(dir, ext string, modules []*codegen.Module, syms *codegen.SymbolTable, backend codegen.Backend, obj *objCompiler, asserts bool) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, mod := range modules {
+		path := relativePath(mod.Path)
+		m, diags := ir.Lower(mod.Program, path, ir.WithAssertions(asserts))
+		if len(diags) > 0 {
+			src, _ := lexer.ReadSource(mod.Path)
+			sortDiagnostics(diags)
+			printDiagnostics(os.Stderr, path, src, diags)
+			return nil, failed("build failed")
+		}
+		file := filepath.Join(dir, moduleFile(mod.Path, ext))
+		var out bytes.Buffer
+		if err := backend.Print(&out, m, codeFile(file, backend), syms); err != nil {
+			return nil, err
+		}
+		if err := writeCode(file, out.Bytes(), obj); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// objCompiler compiles the code a backend prints, in files of extension
+// ext, into object files.
+type objCompiler struct {
+	ext     string
+	compile func(src, obj string) error
+}
+
+// llcCompiler compiles LLVM IR with llc at -O optimize.
+func llcCompiler(llc *codegen.LLC, optimize int) *objCompiler {
+	return &objCompiler{".ll", func(src, obj string) error {
+		if out, err := exec.Command(llc.Path, llc.Args(optimize, src, obj)...).CombinedOutput(); err != nil {
+			return fmt.Errorf("llc: %v\n%s", err, out)
+		}
+		return nil
+	}}
+}
+
+// ccCompiler compiles C with the compiler of cc, its flags, the extra
+// flags and at -O optimize, against the runtime headers in include.
+func ccCompiler(cc ccOptions, include string, optimize int, extra ...string) *objCompiler {
+	return &objCompiler{".c", func(src, obj string) error {
+		args := append(slices.Clone(cc.Compiler.Command[1:]), fmt.Sprintf("-O%d", optimize), "-I", include)
+		args = append(append(append(args, extra...), cc.CFlags...), "-c", "-o", obj, src)
+		if out, err := exec.Command(cc.Compiler.Command[0], args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %v\n%s", cc.Compiler.Name, err, out)
+		}
+		return nil
+	}}
+}
+
+// writeCode writes code to file, or, when obj is not nil, the object
+// file it compiles code into.
+func writeCode(file string, code []byte, obj *objCompiler) error {
+	if obj == nil {
+		return os.WriteFile(file, code, 0o644)
+	}
+	tmp, err := os.MkdirTemp("", "org-obj")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	src := filepath.Join(tmp, strings.TrimSuffix(filepath.Base(file), ".o")+obj.ext)
+	if err := os.WriteFile(src, code, 0o644); err != nil {
+		return err
+	}
+	return obj.compile(src, file)
+}
+
+// codeFile is the name the code printed for file, an output, refers to
+// itself by: file, but its .ll for an object file compiled from it.
+func codeFile(file string, backend codegen.Backend) string {
+	if strings.HasSuffix(file, ".o") {
+		return strings.TrimSuffix(file, ".o") + backend.Ext
+	}
+	return file
+}
+
+// moduleFile is the name of the file of extension ext of the module at
+// path, unique in a program as the module's symbols are.
+func moduleFile(path, ext string) string {
+	return codegen.MangleIdentifier(codegen.ModuleName(path)) + ext
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCheckEmit(t *testing.T) {
+	for _, stage := range []string{"", "tokens", "ast", "ir", "c", "llvm", "obj"} {
+		if err := checkEmit(stage); err != nil {
+			t.Errorf("checkEmit(%q): %v", stage, err)
+		}
+	}
+	for _, stage := range []string{"asm", "C", "exe"} {
+		if err := checkEmit(stage); err == nil {
+			t.Errorf("checkEmit(%q): no error", stage)
+		}
+	}
+}
+
+func TestBackendFor(t *testing.T) {
+	tests := []struct {
+		args  []string
+		stage string
+		want  string // the backend, or "" for an error
+	}{
+		{nil, "", "c"},
+		{nil, "llvm", "llvm"},
+		{[]string{"--backend=llvm"}, "", "llvm"},
+		{[]string{"--backend=llvm"}, "obj", "llvm"},
+		{[]string{"--backend=c"}, "c", "c"},
+		{[]string{"--backend=llvm"}, "c", ""},
+		{[]string{"--backend=c"}, "llvm", ""},
+		{[]string{"--backend=wasm"}, "", ""},
+	}
+	for _, tt := range tests {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("backend", "c", "")
+		if err := cmd.Flags().Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		b, err := backendFor(cmd, tt.stage)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("backendFor(%q, --emit=%s) = %s, want an error", tt.args, tt.stage, b.Name)
+		case tt.want != "" && (err != nil || b.Name != tt.want):
+			t.Errorf("backendFor(%q, --emit=%s) = %s, %v, want %s", tt.args, tt.stage, b.Name, err, tt.want)
+		}
+	}
+}
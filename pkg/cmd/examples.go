@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"orglang/examples"
+	"orglang/pkg/ast"
+	"orglang/pkg/eval"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+	"orglang/pkg/token"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+)
+
+var examplesCmd = &cobra.Command{
+	Use:   "examples [flags] [name]",
+	Short: "Browse and run the example programs",
+	Long: `Lists the example programs built into org, one per topic, in the order
+to read them: basic arithmetic, tables, functions, flow control and so on.
+
+With a name, prints the source of that example, highlighted when the
+output is a terminal. An example is named by its name (basics), its file
+(01_basics.org) or its number (1).
+
+--run runs the example instead. A program with a main binding runs as by
+org run; the others, which are sequences of bindings, are evaluated one
+statement at a time, each statement printed with its value, as in the
+REPL.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		run, _ := cmd.Flags().GetBool("run")
+		if len(args) == 0 {
+			if run {
+				return fmt.Errorf("--run needs the name of an example")
+			}
+			fmt.Println(headerStyle.Render("Examples"))
+			for _, e := range examples.List() {
+				fmt.Printf("  %-12s %s\n", e.Name, subtextStyle.Render(e.Topic))
+			}
+			fmt.Println()
+			fmt.Println(subtextStyle.Render("org examples <name> shows one, org examples --run <name> runs it."))
+			return nil
+		}
+		e, ok := examples.Find(args[0])
+		if !ok {
+			return fmt.Errorf("no example %q (org examples lists them)", args[0])
+		}
+		if run {
+			return runExample(e)
+		}
+		fmt.Print(highlight(e.Source()))
+		return nil
+	},
+}
+
+// runExample runs e: its main if it has one, otherwise each statement in
+// turn, printed with its value.
+func runExample(e examples.Example) error {
+	src := e.Source()
+	l := lexer.New(src)
+	p := parser.New(l, parser.WithStrict(true))
+	prog := p.ParseProgram()
+	if diags := append(l.Diagnostics(), p.Diagnostics()...); len(diags) > 0 {
+		printDiagnostics(os.Stderr, e.File, src, diags)
+		return failed("run failed")
+	}
+	in := eval.New()
+	for _, s := range prog.Statements {
+		if b, ok := s.(*ast.BindingExpr); ok && b.Name.String() == "main" {
+			if code := in.Run(prog, e.File); code != eval.ExitOK {
+				return exitStatus(code)
+			}
+			return nil
+		}
+	}
+	for _, s := range prog.Statements {
+		v := in.Force(in.Eval(s))
+		span := s.Location()
+		text := highlight(src[span.Start.Offset:span.End.Offset])
+		if strings.Contains(text, "\n") {
+			fmt.Printf("%s\n  %s %s\n", text, subtextStyle.Render("⇒"), v)
+		} else {
+			fmt.Printf("%s  %s %s\n", text, subtextStyle.Render("⇒"), v)
+		}
+	}
+	return nil
+}
+
+// highlightStyles color source by token type; other tokens are plain.
+var highlightStyles = map[token.TokenType]lipgloss.Style{
+	token.COMMENT:       subtextStyle,
+	token.BLOCK_COMMENT: subtextStyle,
+	token.INTEGER:       lipgloss.NewStyle().Foreground(lipgloss.Color("14")),
+	token.DECIMAL:       lipgloss.NewStyle().Foreground(lipgloss.Color("14")),
+	token.RATIONAL:      lipgloss.NewStyle().Foreground(lipgloss.Color("14")),
+	token.DURATION:      lipgloss.NewStyle().Foreground(lipgloss.Color("14")),
+	token.SIZE:          lipgloss.NewStyle().Foreground(lipgloss.Color("14")),
+	token.BOOLEAN:       lipgloss.NewStyle().Foreground(lipgloss.Color("14")),
+	token.STRING:        lipgloss.NewStyle().Foreground(lipgloss.Color("10")),
+	token.RAWSTRING:     lipgloss.NewStyle().Foreground(lipgloss.Color("10")),
+	token.INTERP_START:  lipgloss.NewStyle().Foreground(lipgloss.Color("10")),
+	token.INTERP_MID:    lipgloss.NewStyle().Foreground(lipgloss.Color("10")),
+	token.INTERP_END:    lipgloss.NewStyle().Foreground(lipgloss.Color("10")),
+	token.DOCSTRING:     subtextStyle.Italic(true),
+	token.RAWDOC:        subtextStyle.Italic(true),
+	token.KEYWORD:       lipgloss.NewStyle().Foreground(lipgloss.Color("13")),
+}
+
+// bindingStyle marks the names being bound.
+var bindingStyle = headerStyle
+
+// highlight returns src with its tokens styled, keeping the text between
+// them as it is.
+func highlight(src []byte) string {
+	tokens := lexer.New(src, lexer.WithComments(true)).Tokenize()
+	var b strings.Builder
+	at := 0
+	for i, tok := range tokens {
+		if tok.Type == token.EOF || tok.Offset < at {
+			continue
+		}
+		b.Write(src[at:tok.Offset])
+		text := string(src[tok.Offset:tok.End])
+		style, ok := highlightStyles[tok.Type]
+		if tok.Type == token.IDENTIFIER && token.IsDefinition(tokens, i) {
+			style, ok = bindingStyle, true
+		}
+		if ok {
+			text = renderLines(style, text)
+		}
+		b.WriteString(text)
+		at = tok.End
+	}
+	b.Write(src[at:])
+	return b.String()
+}
+
+// renderLines renders each line of text on its own, so a multi-line
+// token is not padded into a block.
+func renderLines(style lipgloss.Style, text string) string {
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = style.Render(l)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func init() {
+	rootCmd.AddCommand(examplesCmd)
+	examplesCmd.Flags().Bool("run", false, "Run the example rather than print it")
+}
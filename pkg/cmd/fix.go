@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"orglang/pkg/fix"
+	"orglang/pkg/lexer"
+
+	"github.com/spf13/cobra"
+)
+
+var fixCmd = &cobra.Command{
+	Use:   "fix [flags] <files...>",
+	Short: "Rewrite deprecated constructs",
+	Long: `Rewrites deprecated constructs to their replacements.
+
+Each use of a binding whose docstring has a @deprecated tag naming its
+replacement ("@deprecated use ` + "`new_name`" + `") is rewritten to it: a
+binding of the file, used by name, and a binding of an imported module,
+used as lib.old_name, which becomes lib.new_name. Imports are resolved as
+org check resolves them. --rename adds renames of your own.
+
+Without -w the edits are listed and no file is changed.
+Comments and formatting are preserved.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		write, _ := cmd.Flags().GetBool("write")
+		renames, err := renamesFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		total := 0
+		for _, path := range args {
+			src, err := lexer.ReadSource(path)
+			if err != nil {
+				return err
+			}
+			_, deprecated, err := fix.Deprecated(path, src)
+			if err != nil {
+				return err
+			}
+			_, renamed := fix.Renames(src, renames)
+			out, edits := fix.Merge(src, deprecated, renamed)
+			for _, e := range edits {
+				fmt.Printf("%s: %s\n", path, e)
+			}
+			total += len(edits)
+			if write && len(edits) > 0 {
+				info, err := os.Stat(path)
+				if err != nil {
+					return err
+				}
+				if err := os.WriteFile(path, out, info.Mode().Perm()); err != nil {
+					return err
+				}
+			}
+		}
+
+		if total == 0 {
+			printInfo("Status", "Nothing to fix")
+		} else if !write {
+			printInfo("Status", fmt.Sprintf("%d edit(s); run with -w to apply", total))
+		}
+		return nil
+	},
+}
+
+// renamesFromFlags parses the repeatable --rename old=new flag.
+func renamesFromFlags(cmd *cobra.Command) ([]fix.Rename, error) {
+	values, _ := cmd.Flags().GetStringArray("rename")
+	var renames []fix.Rename
+	for _, v := range values {
+		old, new, ok := strings.Cut(v, "=")
+		if !ok || old == "" || new == "" {
+			return nil, fmt.Errorf("invalid --rename %q (want old=new)", v)
+		}
+		renames = append(renames, fix.Rename{Old: old, New: new})
+	}
+	return renames, nil
+}
+
+func init() {
+	rootCmd.AddCommand(fixCmd)
+	fixCmd.Flags().BoolP("write", "w", false, "Write the fixed source back to the files")
+	fixCmd.Flags().StringArray("rename", nil, "Rename references to a binding (old=new); repeatable")
+}
@@ -1,18 +1,103 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"os"
+
+	"orglang/pkg/format"
+	"orglang/pkg/lexer"
 
 	"github.com/spf13/cobra"
 )
 
 var fmtCmd = &cobra.Command{
-	Use:   "fmt [files...]",
-	Short: "Format source code (TBD)",
-	Long:  `Formats OrgLang source files to standard style.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println(headerStyle.Render("Format"))
-		printInfo("Status", "TBD - Formatter not yet implemented")
+	Use:   "fmt [flags] [inputs...]",
+	Short: "Format source code",
+	Long: `Formats OrgLang source files to standard style.
+
+Each input file, or each .org file under an input directory, is printed in
+canonical style: one space around binding colons and operators, "key: value"
+in tables, "{ body }" blocks, four-space indentation by nesting, at most one
+blank line in a row and aligned trailing comments. Line breaks, comments
+and literals are kept. Without inputs, standard input is formatted.
+
+With -w the files are rewritten instead of printed. With --check nothing
+is written; the files that are not formatted are listed and the exit code
+is 1 if there are any.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		write, _ := cmd.Flags().GetBool("write")
+		check, _ := cmd.Flags().GetBool("check")
+
+		if len(args) == 0 {
+			src, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+			out, err := format.Source(src)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "<stdin>: %v\n", err)
+				return failed("fmt failed")
+			}
+			if check {
+				if !bytes.Equal(src, out) {
+					fmt.Println("<stdin>")
+					return failed("input is not formatted")
+				}
+				return nil
+			}
+			_, err = os.Stdout.Write(out)
+			return err
+		}
+
+		files, err := sourceFiles(args)
+		if err != nil {
+			return err
+		}
+		bad, unformatted := 0, 0
+		for _, path := range files {
+			src, err := lexer.ReadSource(path)
+			if err != nil {
+				return err
+			}
+			out, err := format.Source(src)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				bad++
+				continue
+			}
+			changed := !bytes.Equal(src, out)
+			switch {
+			case check:
+				if changed {
+					fmt.Println(path)
+					unformatted++
+				}
+			case write:
+				if changed {
+					info, err := os.Stat(path)
+					if err != nil {
+						return err
+					}
+					if err := os.WriteFile(path, out, info.Mode().Perm()); err != nil {
+						return err
+					}
+				}
+			default:
+				if _, err := os.Stdout.Write(out); err != nil {
+					return err
+				}
+			}
+		}
+
+		switch {
+		case bad > 0:
+			return failed(fmt.Sprintf("%s could not be formatted", plural(bad, "file")))
+		case unformatted > 0:
+			return failed(fmt.Sprintf("%s not formatted", plural(unformatted, "file")))
+		}
+		return nil
 	},
 }
 
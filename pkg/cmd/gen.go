@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"orglang/pkg/lexer"
+	"orglang/pkg/service"
+
+	"github.com/spf13/cobra"
+)
+
+var genCmd = &cobra.Command{
+	Use:   "gen",
+	Short: "Generate code from descriptions",
+	Long:  `Generates OrgLang modules and their C glue from descriptions.`,
+}
+
+var genServiceCmd = &cobra.Command{
+	Use:   "service [flags] <spec.org>",
+	Short: "Generate a network service scaffold",
+	Long: `Generates a service from the table bound to "service" in the spec:
+
+    service : [
+        name: "calc"
+        port: 8080
+        methods: [
+            add: [params: ["a" "b"] doc: "Adds a and b."]
+        ]
+    ];
+
+Two files are written to the output directory. <name>.org holds a handler
+stub per method, the exported dispatcher <name>_handle and the <name>
+resource; it is kept if it exists, since the handlers are edited by hand,
+unless --force is given. <name>_server.c is always rewritten: it serves the
+library build of <name>.org as JSON-RPC 2.0 over TCP, one request per line.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, _ := cmd.Flags().GetString("dir")
+		force, _ := cmd.Flags().GetBool("force")
+
+		src, err := lexer.ReadSource(args[0])
+		if err != nil {
+			return err
+		}
+		spec, err := service.Parse(src)
+		if err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+
+		fmt.Println(headerStyle.Render("Gen"))
+		printInfo("Service", fmt.Sprintf("%s (%s, port %d)", spec.Name, plural(len(spec.Methods), "method"), spec.Port))
+		for _, f := range spec.Generate(filepath.Base(args[0])) {
+			path := filepath.Join(dir, f.Name)
+			if f.Skeleton && !force {
+				if _, err := os.Stat(path); err == nil {
+					printInfo("Kept", path)
+					continue
+				} else if !errors.Is(err, fs.ErrNotExist) {
+					return err
+				}
+			}
+			if err := os.WriteFile(path, []byte(f.Content), 0o644); err != nil {
+				return err
+			}
+			printInfo("Generated", path)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(genCmd)
+	genCmd.AddCommand(genServiceCmd)
+	genServiceCmd.Flags().StringP("dir", "o", ".", "Output directory")
+	genServiceCmd.Flags().Bool("force", false, "Overwrite an existing <name>.org")
+}
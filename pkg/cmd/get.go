@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"orglang/pkg/deps"
+	"orglang/pkg/manifest"
+
+	"github.com/spf13/cobra"
+)
+
+var getCmd = &cobra.Command{
+	Use:   "get [flags] [names...]",
+	Short: "Fetch the project's dependencies",
+	Long: `Fetches the dependencies of the project in the current directory or
+above, listed in the [dependencies] table of its org.toml, into its deps
+directory: deps/<name> for each.
+
+A source is a git URL, with the branch or tag to check out after #, or
+the URL of a .tar.gz, .tgz or .zip archive:
+
+  [dependencies]
+  http = "https://example.com/org-http.git#v1.0.0"
+  json = "https://example.com/org-json-1.0.tar.gz"
+
+Modules then import a dependency by name: http : "http" @ org loads its
+entry point (the main key of its own org.toml, else http.org, else
+main.org), and "http/client.org" a file within it. A file of that path
+next to the importing module still comes first.
+
+Each fetched dependency is recorded in org.lock, next to org.toml: the
+commit a git source resolved to and a hash of its files. Later fetches
+check out that commit and check that hash, so every checkout of the
+project builds with the same dependencies, and org build refuses to build
+with dependencies that do not match the lock. Commit org.lock with the
+project.
+
+Given names, only those dependencies are fetched. A dependency already in
+deps that matches the lock is left alone; one that does not is fetched
+again. --update fetches the dependencies anew, ignoring the lock, and
+records what they resolve to now.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		update, _ := cmd.Flags().GetBool("update")
+		m, err := manifest.Find(".")
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			return fmt.Errorf("no %s found (see org init)", manifest.FileName)
+		}
+		names := args
+		if len(names) == 0 {
+			names = slices.Sorted(maps.Keys(m.Dependencies))
+		}
+		for _, name := range names {
+			if _, ok := m.Dependencies[name]; !ok {
+				return fmt.Errorf("%s has no dependency %q", m.Path, name)
+			}
+		}
+
+		lock, err := deps.LoadLock(deps.LockPath(m))
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(headerStyle.Render("Get"))
+		for _, name := range names {
+			source := m.Dependencies[name]
+			src, dir, want := deps.ParseSource(source), m.DepDir(name), ""
+			if locked, ok := lock.Get(name); ok && locked.Source == source && !update {
+				if deps.Verify(locked, dir) == nil {
+					printInfo("Present", name)
+					continue
+				}
+				src.Commit, want = locked.Commit, locked.Hash
+			}
+			commit, hash, err := deps.Fetch(src, dir, want)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			lock.Set(deps.Locked{Name: name, Source: source, Commit: commit, Hash: hash})
+			printInfo("Fetched", fmt.Sprintf("%s (%s)", name, src))
+		}
+		if len(args) == 0 {
+			for _, d := range slices.Clone(lock.Dependencies) {
+				if _, ok := m.Dependencies[d.Name]; !ok {
+					lock.Remove(d.Name)
+				}
+			}
+		}
+		return lock.Save(deps.LockPath(m))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(getCmd)
+	getCmd.Flags().BoolP("update", "u", false, "Fetch dependencies anew, ignoring org.lock")
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"orglang/pkg/manifest"
+
+	"github.com/spf13/cobra"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init [flags] [dir]",
+	Short: "Create a project",
+	Long: `Creates a project in dir (default: the current directory, created if
+missing): an org.toml manifest giving its name, version, entry point and
+source directories, and the entry point src/main.org, a program printing
+a greeting, unless it exists.
+
+The name is that of the directory unless --name is given. A directory
+already holding an org.toml is left alone, failing the command.
+
+Within the project, org build needs no input: it builds the entry point
+into bin/<name>.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		name, _ := cmd.Flags().GetString("name")
+		if name == "" {
+			name = filepath.Base(dir)
+		}
+
+		path := filepath.Join(dir, manifest.FileName)
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists", path)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		main := filepath.Join(dir, "src", "main.org")
+		if err := os.MkdirAll(filepath.Dir(main), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, manifest.Scaffold(name), 0o644); err != nil {
+			return err
+		}
+		created := []string{path}
+		if _, err := os.Stat(main); errors.Is(err, fs.ErrNotExist) {
+			src := fmt.Sprintf("main : { \"Hello from %s!\" -> @stdout };\n", name)
+			if err := os.WriteFile(main, []byte(src), 0o644); err != nil {
+				return err
+			}
+			created = append(created, main)
+		}
+
+		fmt.Println(headerStyle.Render("Init"))
+		printInfo("Project", name)
+		for _, path := range created {
+			printInfo("Created", relativePath(path))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+	initCmd.Flags().String("name", "", "Project name (default: the directory's)")
+}
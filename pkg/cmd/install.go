@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"orglang/pkg/manifest"
+
+	"github.com/spf13/cobra"
+)
+
+var installCmd = &cobra.Command{
+	Use:   "install [flags] [targets...]",
+	Short: "Build and install a project's binaries",
+	Long: `Builds the binaries of the project in the current directory or above,
+as org build does, and copies them into the install directory, for the
+tools written in OrgLang to be run from PATH.
+
+The binaries are the [[target]]s of its org.toml, or those named, else
+the binary of its main key, bin/<name>. The install directory is $ORG_BIN
+when set, otherwise ~/.org/bin; add it to PATH once.
+
+Installs are versioned by the version key of the org.toml: each binary
+gets a <name>.version file next to it, and a binary is not replaced by
+the same version or an older one unless --force is given. That check is
+made before anything is built.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := manifest.Find(".")
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			return fmt.Errorf("no %s found (see org init)", manifest.FileName)
+		}
+		bins, err := projectBinaries(m, args)
+		if err != nil {
+			return err
+		}
+		if m.Version == "" {
+			return fmt.Errorf("%s has no version key, which installs are versioned by", m.Path)
+		}
+		dir, err := installDir()
+		if err != nil {
+			return err
+		}
+		force, _ := cmd.Flags().GetBool("force")
+		if !force {
+			for _, b := range bins {
+				if err := checkInstalled(dir, b.name, m.Version); err != nil {
+					return err
+				}
+			}
+		}
+		for _, b := range bins {
+			if err := buildInput(cmd, relativePath(b.input), relativePath(b.output), b.target); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		fmt.Println(headerStyle.Render("Install"))
+		for _, b := range bins {
+			path, err := installBinary(dir, b.name, b.output, m.Version)
+			if err != nil {
+				return err
+			}
+			printInfo("Installed", fmt.Sprintf("%s (%s)", path, m.Version))
+		}
+		if !inPath(dir) {
+			printInfo("Note", dir+" is not in PATH")
+		}
+		return nil
+	},
+}
+
+// binary is a binary of a project: its name, the entry point built into
+// it and where org build writes it, with the target of the manifest it
+// is built as, if any.
+type binary struct {
+	name, input, output string
+	target              *manifest.Target
+}
+
+// projectBinaries returns the binaries of m named by names: its targets,
+// or without targets the binary of its main key, all of them if names is
+// empty.
+func projectBinaries(m *manifest.Manifest, names []string) ([]binary, error) {
+	var all []binary
+	for i := range m.Targets {
+		t := &m.Targets[i]
+		all = append(all, binary{t.Name, m.TargetEntry(t), m.TargetOutput(t), t})
+	}
+	if len(all) == 0 {
+		if m.Main == "" {
+			return nil, fmt.Errorf("%s has neither a main key nor a [[target]] to build", m.Path)
+		}
+		if m.Name == "" {
+			return nil, fmt.Errorf("%s has no name key, which names the binary", m.Path)
+		}
+		all = append(all, binary{m.Name, m.Entry(), m.Output(), nil})
+	}
+	if len(names) == 0 {
+		return all, nil
+	}
+	var bins []binary
+	for _, name := range names {
+		i := slices.IndexFunc(all, func(b binary) bool { return b.name == name })
+		if i < 0 {
+			var have []string
+			for _, b := range all {
+				have = append(have, b.name)
+			}
+			return nil, fmt.Errorf("%s builds no binary %q (have %s)", m.Path, name, strings.Join(have, ", "))
+		}
+		bins = append(bins, all[i])
+	}
+	return bins, nil
+}
+
+// installDir returns the directory binaries are installed into.
+func installDir() (string, error) {
+	if dir := os.Getenv("ORG_BIN"); dir != "" {
+		return filepath.Abs(dir)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate install directory: %w (set ORG_BIN)", err)
+	}
+	return filepath.Join(home, ".org", "bin"), nil
+}
+
+// checkInstalled fails if the binary name installed in dir has version
+// or a newer one, as its <name>.version file says. A binary without that
+// file was not installed by org install, and is not replaced either.
+func checkInstalled(dir, name, version string) error {
+	path := filepath.Join(dir, name)
+	data, err := os.ReadFile(path + ".version")
+	if errors.Is(err, fs.ErrNotExist) {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s exists and was not installed by org install (use --force to replace it)", path)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	installed := strings.TrimSpace(string(data))
+	c, err := manifest.CompareVersions(version, installed)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path+".version", err)
+	}
+	if c <= 0 {
+		return fmt.Errorf("%s %s is installed, not older than %s (use --force to replace it)", path, installed, version)
+	}
+	return nil
+}
+
+// installBinary copies the binary built at src into dir as name, with its
+// version in <name>.version, and returns its path. The copy is renamed
+// into place, so a running binary is never seen half written.
+func installBinary(dir, name, src, version string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(dir, "."+name+"-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, in)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o755)
+	}
+	path := filepath.Join(dir, name)
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path+".version", []byte(version+"\n"), 0o644)
+}
+
+// inPath reports whether dir is one of the directories of $PATH.
+func inPath(dir string) bool {
+	for _, d := range filepath.SplitList(os.Getenv("PATH")) {
+		if abs, err := filepath.Abs(d); err == nil && abs == dir {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	rootCmd.AddCommand(installCmd)
+	installCmd.Flags().BoolP("force", "f", false, "Replace installed binaries even with the same or an older version")
+	addBuildFlags(installCmd)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstallVersions(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(t.TempDir(), "tool")
+	if err := os.WriteFile(src, []byte("binary"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkInstalled(dir, "tool", "0.1.0"); err != nil {
+		t.Fatalf("nothing installed: %v", err)
+	}
+	path, err := installBinary(dir, "tool", src, "0.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o755 {
+		t.Errorf("installed %s: %v, %v", path, info, err)
+	}
+	if data, _ := os.ReadFile(path + ".version"); string(data) != "0.1.0\n" {
+		t.Errorf("tool.version = %q", data)
+	}
+	for _, version := range []string{"0.1.0", "0.0.9", "0.1.0-rc1"} {
+		if err := checkInstalled(dir, "tool", version); err == nil || !strings.Contains(err.Error(), "--force") {
+			t.Errorf("installing %s over 0.1.0: %v", version, err)
+		}
+	}
+	if err := checkInstalled(dir, "tool", "0.1.1"); err != nil {
+		t.Errorf("installing 0.1.1 over 0.1.0: %v", err)
+	}
+
+	// A file org install did not write is not replaced.
+	if err := os.WriteFile(filepath.Join(dir, "other"), nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkInstalled(dir, "other", "1.0.0"); err == nil {
+		t.Error("replacing a binary without a version file: no error")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 3 {
+		t.Errorf("install left %d files, want tool, tool.version and other", len(entries))
+	}
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"orglang/pkg/eval"
+	"orglang/pkg/learn"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+)
+
+var learnCmd = &cobra.Command{
+	Use:   "learn [flags] [lesson]",
+	Short: "Learn OrgLang with an interactive tutorial",
+	Long: `Starts a tutorial of OrgLang in the terminal. Each lesson explains one
+idea of the language, such as bindings, tables, blocks as operators or
+errors as values, and asks for a little code. Answers are evaluated by the
+interpreter as in the REPL, and a lesson is passed when its check holds:
+the next one starts, with the bindings of the earlier answers still
+defined.
+
+Besides code, the prompt takes :hint, :solution, :skip and :quit. Ctrl-D
+also quits.
+
+Without an argument the tutorial starts at the first lesson; a lesson is
+given by its number or title. --list lists the lessons.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		lessons := learn.Lessons()
+		if list, _ := cmd.Flags().GetBool("list"); list {
+			fmt.Println(headerStyle.Render("Lessons"))
+			for i, l := range lessons {
+				fmt.Printf("  %2d  %s\n", i+1, l.Title)
+			}
+			return nil
+		}
+		start := 0
+		if len(args) == 1 {
+			i, ok := findLesson(lessons, args[0])
+			if !ok {
+				return fmt.Errorf("no lesson %q (org learn --list lists them)", args[0])
+			}
+			start = i
+		}
+		return runLearn(os.Stdin, os.Stdout, lessons, start)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(learnCmd)
+	learnCmd.Flags().Bool("list", false, "List the lessons")
+}
+
+const (
+	learnPrompt = "learn> "
+	// learnAttempts failed answers suggest :hint and :solution.
+	learnAttempts = 3
+)
+
+var (
+	lessonStyle = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("12")).Padding(0, 1)
+	taskStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true)
+	passedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true)
+	notYetStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+)
+
+// findLesson returns the index of the lesson named by arg, its number
+// from 1 or its title.
+func findLesson(lessons []learn.Lesson, arg string) (int, bool) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		return n - 1, n >= 1 && n <= len(lessons)
+	}
+	for i, l := range lessons {
+		if strings.EqualFold(l.Title, arg) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// runLearn runs the lessons from start, reading answers from r.
+func runLearn(r io.Reader, w io.Writer, lessons []learn.Lesson, start int) error {
+	s := learn.NewSession(w)
+	scanner := bufio.NewScanner(r)
+	for i := start; i < len(lessons); i++ {
+		l := lessons[i]
+		title := headerStyle.Render(fmt.Sprintf("Lesson %d of %d: %s", i+1, len(lessons), l.Title))
+		fmt.Fprintln(w, lessonStyle.Render(title+"\n\n"+l.Text))
+		fmt.Fprintln(w, taskStyle.Render(l.Task))
+		passed, err := learnLesson(scanner, w, s, l)
+		if err != nil || !passed {
+			return err
+		}
+	}
+	fmt.Fprintln(w, passedStyle.Render("You have finished the tutorial."),
+		subtextStyle.Render("org examples has more to read, org repl more to try."))
+	return nil
+}
+
+// learnLesson reads answers until l is passed or skipped, and reports
+// false if the user quit.
+func learnLesson(scanner *bufio.Scanner, w io.Writer, s *learn.Session, l learn.Lesson) (bool, error) {
+	failures := 0
+	var input strings.Builder
+	fmt.Fprint(w, learnPrompt)
+	for scanner.Scan() {
+		input.WriteString(scanner.Text())
+		input.WriteString("\n")
+		if incomplete(input.String()) {
+			fmt.Fprint(w, replContinuing)
+			continue
+		}
+		src := strings.TrimSpace(input.String())
+		input.Reset()
+		switch src {
+		case "":
+		case ":hint":
+			fmt.Fprintln(w, subtextStyle.Render("Hint: ")+l.Hint)
+		case ":solution":
+			fmt.Fprintln(w, subtextStyle.Render("One answer: ")+l.Solution)
+		case ":skip":
+			fmt.Fprintln(w)
+			return true, nil
+		case ":quit":
+			return false, nil
+		default:
+			v, err := s.Eval(src)
+			switch {
+			case err != nil:
+				fmt.Fprintln(w, err)
+			case v != nil:
+				fmt.Fprintln(w, v)
+			}
+			if s.Passed(l) {
+				fmt.Fprintln(w, passedStyle.Render("✓ Passed"))
+				fmt.Fprintln(w)
+				return true, nil
+			}
+			failures++
+			msg := "Not yet: " + l.Task
+			if _, isErr := v.(*eval.Error); isErr {
+				msg = "That gave an Error; try again."
+			}
+			fmt.Fprintln(w, notYetStyle.Render(msg))
+			if failures%learnAttempts == 0 {
+				fmt.Fprintln(w, subtextStyle.Render(":hint gives a hint, :solution an answer."))
+			}
+		}
+		fmt.Fprint(w, learnPrompt)
+	}
+	fmt.Fprintln(w)
+	return false, scanner.Err()
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/codegen"
+	"orglang/pkg/ir"
+	"orglang/pkg/lexer"
+	orgruntime "orglang/pkg/runtime"
+	"orglang/pkg/std"
+)
+
+// program is the code of a program written out for the C compiler in a
+// temporary directory, which remove removes.
+type program struct {
+	dir     string
+	include string   // the runtime, compiled with as -I include
+	runtime []string // the C sources of the runtime a program links
+	files   []string // the code of each module, the program's last
+}
+
+// writeProgram writes the program input, parsed as prog and run after the
+// std modules of pre, and the runtime. modules are those loadModules
+// loaded, the program last. Each module's code is printed by backend, and
+// compiled by obj when it is not nil. assert statements are checked as
+// codegen.Assertions decides for debug and optimize.
+func writeProgram(input string, prog *ast.Program, pre *std.Prelude, modules []*codegen.Module, backend codegen.Backend, obj *objCompiler, optimize int, debug bool) (*program, error) {
+	tmp, err := os.MkdirTemp("", "org-build")
+	if err != nil {
+		return nil, err
+	}
+	p := &program{dir: tmp, include: filepath.Join(tmp, "runtime")}
+	if p.runtime, err = orgruntime.Write(p.include); err != nil {
+		p.remove()
+		return nil, err
+	}
+
+	ext := backend.Ext
+	if obj != nil {
+		ext = ".o"
+	}
+	syms := codegen.NewSymbolTable()
+	imported := modules[:len(modules)-1]
+	declareModules(syms, imported)
+	asserts := codegen.Assertions(debug, optimize)
+	if p.files, err = emitModules(tmp, ext, imported, syms, backend, obj, asserts); err != nil {
+		p.remove()
+		return nil, err
+	}
+	m, diags := ir.Lower(pre.Apply(prog), input, ir.WithAssertions(asserts))
+	if len(diags) > 0 {
+		p.remove()
+		src, _ := lexer.ReadSource(input)
+		sortDiagnostics(diags)
+		printDiagnostics(os.Stderr, input, src, diags)
+		return nil, failed("build failed")
+	}
+	file := filepath.Join(tmp, moduleFile(input, ext))
+	var code bytes.Buffer
+	err = backend.Print(&code, m, codeFile(file, backend), syms)
+	if err == nil {
+		err = writeCode(file, code.Bytes(), obj)
+	}
+	if err != nil {
+		p.remove()
+		return nil, err
+	}
+	p.files = append(p.files, file)
+	return p, nil
+}
+
+func (p *program) remove() { os.RemoveAll(p.dir) }
+
+// ccArgs returns the arguments of the C compiler of cc at -O optimize,
+// with the runtime's headers and, if debug, debug information.
+func (p *program) ccArgs(cc ccOptions, optimize int, debug bool) []string {
+	args := append(slices.Clone(cc.Compiler.Command[1:]), fmt.Sprintf("-O%d", optimize), "-I", p.include)
+	if debug {
+		args = append(args, "-g")
+	}
+	return args
+}
+
+// linkArgs returns the flags and libraries of cc followed by those of
+// the runtime, which end the command linking a program.
+func linkArgs(cc ccOptions) []string {
+	args := cc.Args()
+	for _, lib := range orgruntime.Libs {
+		args = append(args, "-l"+lib)
+	}
+	return args
+}
+
+// runCC runs the C compiler of cc with args.
+func runCC(cc ccOptions, args []string) error {
+	if out, err := exec.Command(cc.Compiler.Command[0], args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v\n%s", cc.Compiler.Name, err, out)
+	}
+	return nil
+}
+
+// link builds the executable output of the program input: the code of
+// writeProgram, compiled by the C compiler of cc with the entry point of
+// codegen.PrintMain and the runtime at -O optimize, and linked with the
+// runtime's libraries. All of it happens in a temporary directory,
+// removed afterwards, so only output is written.
+func link(input, output string, prog *ast.Program, pre *std.Prelude, modules []*codegen.Module, backend codegen.Backend, obj *objCompiler, cc ccOptions, optimize int, debug bool) error {
+	p, err := writeProgram(input, prog, pre, modules, backend, obj, optimize, debug)
+	if err != nil {
+		return err
+	}
+	defer p.remove()
+	var code bytes.Buffer
+	if err := codegen.PrintMain(&code, input); err != nil {
+		return err
+	}
+	main := filepath.Join(p.dir, "org_main.c")
+	if err := os.WriteFile(main, code.Bytes(), 0o644); err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(output); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	args := append(p.ccArgs(cc, optimize, debug), "-o", output)
+	args = append(args, p.files...)
+	args = append(args, main)
+	args = append(args, p.runtime...)
+	return runCC(cc, append(args, linkArgs(cc)...))
+}
+
+// linkLibrary builds the library lib of the program input, as link builds
+// its executable but with the glue of codegen.LibrarySource instead of a
+// main(), everything compiled as position-independent code: archived
+// into lib<name>.a, or linked into lib<name>.so when lib is shared. With
+// lib.python, the extension module writeLibrary wrote is compiled with
+// the same objects into <name> and the suffix python3-config gives. It
+// returns the paths of the files it wrote.
+func linkLibrary(lib *library, input string, prog *ast.Program, pre *std.Prelude, modules []*codegen.Module, cc ccOptions, optimize int, debug bool) ([]string, error) {
+	var pyFlags []string
+	var pySuffix string
+	if lib.python {
+		var err error
+		if pyFlags, pySuffix, err = pythonConfig(); err != nil {
+			return nil, err
+		}
+	}
+	backend, err := codegen.FindBackend("c")
+	if err != nil {
+		return nil, err
+	}
+	p, err := writeProgram(input, prog, pre, modules, backend, nil, optimize, debug)
+	if err != nil {
+		return nil, err
+	}
+	defer p.remove()
+	glue := filepath.Join(p.dir, "org_library.c")
+	src, err := codegen.LibrarySource(lib.name, input, glue, lib.exports)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", input, err)
+	}
+	if err := os.WriteFile(glue, []byte(src), 0o644); err != nil {
+		return nil, err
+	}
+
+	// The glue includes the header, and with it the runtime's headers,
+	// from the directory of the library.
+	extra := []string{"-fPIC", "-I", lib.dir}
+	if debug {
+		extra = append(extra, "-g")
+	}
+	compiler := ccCompiler(cc, p.include, optimize, extra...)
+	var objs []string
+	for i, src := range slices.Concat(p.files, []string{glue}, p.runtime) {
+		obj := filepath.Join(p.dir, fmt.Sprintf("%d.o", i))
+		if err := compiler.compile(src, obj); err != nil {
+			return nil, err
+		}
+		objs = append(objs, obj)
+	}
+
+	var written []string
+	if lib.shared {
+		out := filepath.Join(lib.dir, "lib"+lib.name+".so")
+		args := append(p.ccArgs(cc, optimize, debug), "-shared", "-o", out)
+		if err := runCC(cc, append(append(args, objs...), linkArgs(cc)...)); err != nil {
+			return nil, err
+		}
+		written = append(written, out)
+	} else {
+		ar, err := exec.LookPath("ar")
+		if err != nil {
+			return nil, fmt.Errorf("--library=static needs ar (binutils) in PATH")
+		}
+		out := filepath.Join(lib.dir, "lib"+lib.name+".a")
+		// ar adds to an archive; start from none, so no member is stale.
+		if err := os.Remove(out); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if out, err := exec.Command(ar, append([]string{"rcs", out}, objs...)...).CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("ar: %v\n%s", err, out)
+		}
+		written = append(written, out)
+	}
+
+	if lib.python {
+		out := filepath.Join(lib.dir, lib.name+pySuffix)
+		args := append(p.ccArgs(cc, optimize, debug), "-shared", "-fPIC", "-DORG_WITH_PYTHON", "-I", lib.dir)
+		args = append(append(args, pyFlags...), cc.CFlags...)
+		args = append(args, "-o", out, filepath.Join(lib.dir, lib.name+"module.c"), filepath.Join(p.include, "python", "pyconv.c"))
+		if err := runCC(cc, append(append(args, objs...), linkArgs(cc)...)); err != nil {
+			return nil, err
+		}
+		written = append(written, out)
+	}
+	return written, nil
+}
+
+// pythonConfig returns the include flags of CPython and the file name
+// suffix of its extension modules, as python3-config gives them.
+func pythonConfig() (includes []string, suffix string, err error) {
+	config, err := exec.LookPath("python3-config")
+	if err != nil {
+		return nil, "", fmt.Errorf("--python needs python3-config (the CPython development files) in PATH")
+	}
+	out, err := exec.Command(config, "--includes").Output()
+	if err != nil {
+		return nil, "", fmt.Errorf("python3-config --includes: %v", err)
+	}
+	includes = strings.Fields(string(out))
+	if out, err = exec.Command(config, "--extension-suffix").Output(); err != nil {
+		return nil, "", fmt.Errorf("python3-config --extension-suffix: %v", err)
+	}
+	return includes, strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"orglang/pkg/lsp"
+
+	"github.com/spf13/cobra"
+)
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Start the language server",
+	Long: `Starts a Language Server Protocol server on standard input and output,
+for editors.
+
+Open documents are checked on every change with the same checks as
+"org check" (lexical and parse errors, undefined identifiers and the lints
+of the project's org.toml) and the results are published as diagnostics.
+The outline lists the top-level bindings of a document as symbols, and
+workspace symbol search finds those of every module of the workspace,
+matching names fuzzily. The workspace's symbols are indexed when the
+editor connects and kept under lsp/ in the cache directory (that of org
+clean): the next start analyzes only the files changed since, and a
+saved document updates its own symbols.
+
+Hovering a binding of the document, or alias.name for a module it
+imports, shows its kind and docstring, with the parameters, result,
+examples and deprecation note of its tags.
+
+Inlay hints show the binding powers of the operators the program defines
+where they are used, and the implicit operands (left, right) each block
+refers to.
+
+Formatting follows org fmt, for the whole document, a range of lines
+widened to whole statements, or the statement a ; or } just typed ends.
+
+Code lenses over main and over each test_ or golden_ binding run the file with org
+run, or that one test with org test, as saved; the output goes to the
+editor's log and the outcome to a message.
+
+After org test --coverage, the statements of a tested module that did
+not run are shown faded, with a hint, as long as the module is unchanged.
+
+The server exits when the editor closes the connection. Logs go to
+standard error.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s := lsp.NewServer(os.Stdin, os.Stdout, os.Stderr)
+		if dir, err := cacheDir(); err == nil {
+			s.IndexDir = filepath.Join(dir, "lsp")
+		}
+		if dir, err := coverageDir(); err == nil {
+			s.CoverageDir = dir
+		}
+		if exe, err := os.Executable(); err == nil {
+			s.Org = exe
+		}
+		err := s.Run()
+		if errors.Is(err, lsp.ErrNoShutdown) {
+			return failed("lsp: exit before shutdown")
+		}
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lspCmd)
+	// Editors launch servers with --stdio; it is the only transport.
+	lspCmd.Flags().Bool("stdio", true, "Use standard input and output (the only transport)")
+}
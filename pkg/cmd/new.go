@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"orglang/pkg/manifest"
+	"orglang/pkg/scaffold"
+
+	"github.com/spf13/cobra"
+)
+
+var newCmd = &cobra.Command{
+	Use:   "new [flags] <kind> [dir]",
+	Short: "Create a project from a template",
+	Long: `Creates a project of the given kind in dir (default: the directory
+named by --name, or else the current directory; created if missing).
+Every project has an org.toml manifest, a .gitignore leaving out bin, deps and
+dist, and under src an entry point main.org, a module it imports and a
+test of that module, ready for org run, org test and org check; a lib
+has no entry point, its module being lib.org:
+
+  cli       a command-line program reading its arguments
+  pipeline  a program sending its input through stages of a flow
+  lib       a library for other projects to depend on
+
+The lib key of a lib's manifest names the module its dependents import
+by name.
+The name of the project is that of the directory unless --name is given.
+A directory already holding an org.toml is left alone, failing the
+command, as are files of the template that already exist.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kind := args[0]
+		name, _ := cmd.Flags().GetString("name")
+		dir := "."
+		if len(args) > 1 {
+			dir = args[1]
+		} else if name != "" {
+			dir = name
+		}
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			name = filepath.Base(dir)
+		}
+		files, err := scaffold.Files(kind, name)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, manifest.FileName)
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists", path)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		var created []string
+		for _, f := range files {
+			path := filepath.Join(dir, filepath.FromSlash(f.Path))
+			if _, err := os.Stat(path); err == nil {
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, f.Data, 0o644); err != nil {
+				return err
+			}
+			created = append(created, path)
+		}
+
+		fmt.Println(headerStyle.Render("New"))
+		printInfo("Project", name+" ("+kind+")")
+		for _, path := range created {
+			printInfo("Created", relativePath(path))
+		}
+		next := []string{"org test", "org check src"}
+		if kind != "lib" {
+			next = append([]string{"org run src/main.org"}, next...)
+		}
+		if rel := relativePath(dir); rel != "." {
+			next = append([]string{"cd " + rel}, next...)
+		}
+		fmt.Println(subtextStyle.Render("Next: " + strings.Join(next, ", ")))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(newCmd)
+	newCmd.Flags().String("name", "", "Project name (default: the directory's)")
+}
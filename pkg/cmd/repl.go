@@ -1,22 +1,346 @@
 package cmd
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
+	"maps"
+	"os"
+	"strings"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/doc"
+	"orglang/pkg/eval"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+	"orglang/pkg/token"
 
 	"github.com/spf13/cobra"
 )
 
 var replCmd = &cobra.Command{
 	Use:   "repl",
-	Short: "Start interactive REPL (TBD)",
-	Long:  `Starts an interactive Read-Eval-Print Loop for OrgLang.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println(headerStyle.Render("REPL"))
-		printInfo("Status", "TBD - REPL logic not yet implemented")
+	Short: "Start interactive REPL",
+	Long: `Starts an interactive Read-Eval-Print Loop for OrgLang.
+
+Each input is parsed and evaluated by the interpreter (pkg/eval); names and
+operators defined by earlier inputs stay defined. An input continues on the
+next line while a (, [ or { is unclosed or a string is unterminated.
+Bindings print nothing, other expressions print their value. Exit with
+Ctrl-D.
+
+Lines starting with : are commands rather than code:
+
+  :type name  how the parser classifies name: a value, a prefix or infix
+              operator with its binding powers, or a resource
+  :doc name   the docstring of a binding made in the session, with its
+              @param, @returns, @example and @deprecated tags
+  :save file  write the session to file: the operators registered, the
+              bindings made, as values, or as their source for operators
+              and resources, and the docstrings
+  :load file  add a session written by :save to this one`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		history, _ := cmd.Flags().GetString("history")
+		banner, _ := cmd.Flags().GetBool("banner")
+		return runREPL(os.Stdin, os.Stdout, history, banner)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(replCmd)
-	replCmd.Flags().String("history", "", "Path to history file")
+	replCmd.Flags().String("history", "", "Append every input to this file")
+	replCmd.Flags().Bool("banner", true, "Show the welcome message")
+}
+
+const (
+	replPrompt     = "org> "
+	replContinuing = "...  "
+)
+
+// repl is the state kept across inputs: the operators known to the parser,
+// the interpreter's global scope and the docstrings of the bindings made so
+// far.
+type repl struct {
+	bindings *parser.BindingTable
+	interp   *eval.Interp
+	out      io.Writer
+
+	docs       map[string]string
+	pendingDoc string // a docstring waiting for the binding it documents
+
+	// names are the bindings made, in the order they were first made,
+	// and sources the source of the last statement making each.
+	names   []string
+	sources map[string]string
+}
+
+func runREPL(r io.Reader, w io.Writer, historyPath string, banner bool) error {
+	var history io.Writer
+	if historyPath != "" {
+		f, err := os.OpenFile(historyPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		history = f
+	}
+
+	in := eval.New()
+	in.Stdout = w
+	s := &repl{bindings: parser.NewBindingTable(), interp: in, out: w, docs: map[string]string{}, sources: map[string]string{}}
+
+	if banner {
+		fmt.Fprintln(w, headerStyle.Render("OrgLang REPL")+" "+subtextStyle.Render("(Ctrl-D to exit)"))
+	}
+	scanner := bufio.NewScanner(r)
+	var input strings.Builder
+	fmt.Fprint(w, replPrompt)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); input.Len() == 0 && strings.HasPrefix(line, ":") {
+			s.command(line)
+			fmt.Fprint(w, replPrompt)
+			continue
+		}
+		input.WriteString(scanner.Text())
+		input.WriteString("\n")
+		if incomplete(input.String()) {
+			fmt.Fprint(w, replContinuing)
+			continue
+		}
+		src := input.String()
+		input.Reset()
+		if strings.TrimSpace(src) != "" {
+			if history != nil {
+				if _, err := io.WriteString(history, src); err != nil {
+					return err
+				}
+			}
+			s.eval(src)
+		}
+		fmt.Fprint(w, replPrompt)
+	}
+	fmt.Fprintln(w)
+	return scanner.Err()
+}
+
+// eval parses and evaluates one input, printing parse errors or the value
+// of each statement that is not a binding.
+func (s *repl) eval(src string) {
+	p := parser.New(lexer.New([]byte(src)), parser.WithBindings(s.bindings))
+	prog := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		for _, e := range errs {
+			fmt.Fprintln(s.out, e)
+		}
+		return
+	}
+	for _, stmt := range prog.Statements {
+		if sl, ok := stmt.(*ast.StringLiteral); ok && sl.IsDoc {
+			s.pendingDoc = sl.Value
+			continue
+		}
+		var name string
+		switch b := stmt.(type) {
+		case *ast.BindingExpr:
+			name = b.Name.String()
+		case *ast.ResourceDef:
+			name = b.Name.String()
+		}
+		if name != "" {
+			span := stmt.Location()
+			s.record(name, src[span.Start.Offset:span.End.Offset])
+			if s.pendingDoc != "" {
+				s.docs[name] = s.pendingDoc
+			}
+		}
+		s.pendingDoc = ""
+		v := s.interp.Eval(stmt)
+		switch stmt.(type) {
+		case *ast.BindingExpr, *ast.ResourceDef:
+			if _, isErr := v.(*eval.Error); !isErr {
+				continue
+			}
+		}
+		fmt.Fprintln(s.out, s.interp.Force(v))
+	}
+}
+
+// record notes that the statement source bound name.
+func (s *repl) record(name, source string) {
+	if _, ok := s.sources[name]; !ok {
+		s.names = append(s.names, name)
+	}
+	s.sources[name] = source
+}
+
+// command runs a : command line.
+func (s *repl) command(line string) {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case ":save", ":load":
+		if len(fields) != 2 {
+			fmt.Fprintf(s.out, "usage: %s file\n", fields[0])
+			return
+		}
+		save := s.save
+		if fields[0] == ":load" {
+			save = s.load
+		}
+		if err := save(fields[1]); err != nil {
+			fmt.Fprintln(s.out, err)
+		}
+	case ":type", ":doc":
+		if len(fields) != 2 {
+			fmt.Fprintf(s.out, "usage: %s name\n", fields[0])
+			return
+		}
+		name := fields[1]
+		if fields[0] == ":doc" {
+			s.printDoc(name)
+			return
+		}
+		entry, ok := s.bindings.Lookup(name)
+		if !ok {
+			fmt.Fprintf(s.out, "%s is not bound\n", name)
+			return
+		}
+		fmt.Fprintf(s.out, "%s : %s\n", name, entry.Kind())
+	default:
+		fmt.Fprintf(s.out, "unknown command %s (have :type, :doc, :save, :load)\n", fields[0])
+	}
+}
+
+// session is the file of :save and :load: the operator table entries
+// registered in the session, its bindings in the order they were first
+// made, and their docstrings. A binding is saved as its value through the
+// value serializer; an operator or a resource, which has no serialized
+// form, as the source of the statement that made it, evaluated again.
+type session struct {
+	Operators map[string]parser.BindingEntry `json:"operators"`
+	Bindings  []savedBinding                 `json:"bindings"`
+	Docs      map[string]string              `json:"docs,omitempty"`
+}
+
+type savedBinding struct {
+	Name   string          `json:"name"`
+	Value  json.RawMessage `json:"value,omitempty"`
+	Source string          `json:"source,omitempty"`
+}
+
+// save writes the session to path.
+func (s *repl) save(path string) error {
+	ses := session{Operators: s.bindings.Entries(), Docs: s.docs}
+	for _, name := range s.names {
+		b := savedBinding{Name: name, Source: s.sources[name]}
+		if v, ok := s.interp.Lookup(s.interp.Global, name); ok {
+			if data, err := s.interp.MarshalValue(v); err == nil {
+				b.Value, b.Source = data, ""
+			}
+		}
+		ses.Bindings = append(ses.Bindings, b)
+	}
+	data, err := json.MarshalIndent(ses, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "saved %s to %s\n", plural(len(ses.Bindings), "binding"), path)
+	return nil
+}
+
+// load adds the session saved in path to this one: the operator table
+// entries of the bindings saved as values, then its bindings in order. A
+// binding saved as source registers its own entry when it is parsed
+// again, which it could not do once the entry were there.
+func (s *repl) load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var ses session
+	if err := json.Unmarshal(data, &ses); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	fromSource := map[string]bool{}
+	for _, b := range ses.Bindings {
+		fromSource[b.Name] = b.Value == nil
+	}
+	for name, entry := range ses.Operators {
+		if !fromSource[name] {
+			s.bindings.Register(name, entry)
+		}
+	}
+	for _, b := range ses.Bindings {
+		if b.Value == nil {
+			s.eval(b.Source)
+			continue
+		}
+		v, err := eval.UnmarshalValue(b.Value)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", path, b.Name, err)
+		}
+		s.interp.Global.Set(b.Name, v)
+		s.record(b.Name, b.Name+" : "+v.String())
+	}
+	maps.Copy(s.docs, ses.Docs)
+	fmt.Fprintf(s.out, "loaded %s from %s\n", plural(len(ses.Bindings), "binding"), path)
+	return nil
+}
+
+// printDoc prints the docstring of name, its text then its tags.
+func (s *repl) printDoc(name string) {
+	text, ok := s.docs[name]
+	if !ok {
+		if _, bound := s.bindings.Lookup(name); !bound {
+			fmt.Fprintf(s.out, "%s is not bound\n", name)
+		} else {
+			fmt.Fprintf(s.out, "%s has no docstring\n", name)
+		}
+		return
+	}
+	d := doc.ParseDocstring(text)
+	if d.Deprecated {
+		fmt.Fprintf(s.out, "%s: %s\n", subtextStyle.Render("Deprecated"), d.DeprecatedNote)
+	}
+	if d.Text != "" {
+		fmt.Fprintln(s.out, d.Text)
+	}
+	for _, p := range d.Params {
+		fmt.Fprintf(s.out, "%s: %s\n", subtextStyle.Render("Param "+p.Name), p.Desc)
+	}
+	if d.Returns != "" {
+		fmt.Fprintf(s.out, "%s: %s\n", subtextStyle.Render("Returns"), d.Returns)
+	}
+	for _, e := range d.Examples {
+		fmt.Fprintln(s.out, subtextStyle.Render("Example:"))
+		for _, l := range strings.Split(e, "\n") {
+			fmt.Fprintln(s.out, "  "+l)
+		}
+	}
+}
+
+// incomplete reports whether src needs more lines: a bracket is still
+// open or a string is unterminated.
+func incomplete(src string) bool {
+	l := lexer.New([]byte(src))
+	depth := 0
+	for {
+		t := l.NextToken()
+		switch t.Type {
+		case token.EOF:
+			return depth > 0
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			depth--
+		case token.ILLEGAL:
+			if strings.HasPrefix(t.Literal, "unterminated") && !strings.Contains(t.Literal, "escape") {
+				return true
+			}
+		}
+	}
 }
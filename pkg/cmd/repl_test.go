@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runSession runs the REPL over the lines of input and returns what it
+// printed, prompts included.
+func runSession(t *testing.T, input ...string) string {
+	t.Helper()
+	var out strings.Builder
+	if err := runREPL(strings.NewReader(strings.Join(input, "\n")+"\n"), &out, "", false); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestREPLSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.orgs")
+	out := runSession(t,
+		`"""Doubles a number."""`,
+		"double : { right * 2 };",
+		"plus3 : { left + right + 3 };",
+		"n : 5 plus3 4;",
+		":save "+path,
+	)
+	if !strings.Contains(out, "saved 3 bindings to "+path) {
+		t.Fatalf("save: got\n%s", out)
+	}
+
+	// A new session knows the operators, their binding powers and the docs.
+	out = runSession(t,
+		":load "+path,
+		"2 plus3 1;",
+		"double n;",
+		":type plus3",
+		":doc double",
+	)
+	for _, want := range []string{
+		"loaded 3 bindings from " + path,
+		"org> 6\n",
+		"org> 24\n",
+		"plus3 : infix operator (lbp 100, rbp 101)",
+		"Doubles a number.",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("load: missing %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestREPLTypeDoc(t *testing.T) {
+	out := runSession(t,
+		"n : 42;",
+		`"""Doubles a number.`,
+		"@param right the number",
+		`@returns twice right"""`,
+		"double : { right * 2 };",
+		":type n",
+		":type double",
+		":type missing",
+		":doc double",
+		":doc n",
+		":doc missing",
+		":type",
+	)
+	for _, want := range []string{
+		"n : value\n",
+		"double : prefix operator (bp 100)\n",
+		"missing is not bound\n",
+		"Doubles a number.\n",
+		"Param right: the number\n",
+		"Returns: twice right\n",
+		"n has no docstring\n",
+		"usage: :type name\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"orglang/pkg/diag"
+)
+
+// report collects the diagnostics of several files and prints them
+// grouped by file, with counts, once every file has been looked at.
+type report struct {
+	files []*fileReport
+}
+
+type fileReport struct {
+	path     string
+	src      []byte
+	diags    []diag.Diagnostic
+	errors   int
+	warnings int
+}
+
+// file starts the diagnostics of path, whose source is src, or returns
+// those already started.
+func (r *report) file(path string, src []byte) *fileReport {
+	for _, f := range r.files {
+		if filepath.Clean(f.path) == filepath.Clean(path) {
+			return f
+		}
+	}
+	f := &fileReport{path: path, src: src}
+	r.files = append(r.files, f)
+	return f
+}
+
+func (f *fileReport) add(d diag.Diagnostic) {
+	f.diags = append(f.diags, d)
+	if d.Severity == diag.Error {
+		f.errors++
+	} else {
+		f.warnings++
+	}
+}
+
+func (r *report) counts() (errors, warnings int) {
+	for _, f := range r.files {
+		errors += f.errors
+		warnings += f.warnings
+	}
+	return errors, warnings
+}
+
+// print writes the files that have diagnostics, then a summary line:
+//
+//	main.org (1 error, 0 warnings)
+//	  error[ORG2002]: undefined identifier: y
+//	   --> main.org:2:9
+//	    |
+//	  2 | z : x + y;
+//	    |         ^
+//	    = hint: bind it before this use, as in `y : ...`, or import the module defining it
+//
+//	2 files checked: 1 error, 0 warnings
+func (r *report) print(w io.Writer) {
+	for _, f := range r.files {
+		if len(f.diags) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s %s\n", headerStyle.Render(f.path), subtextStyle.Render("("+counts(f.errors, f.warnings)+")"))
+		for _, d := range f.diags {
+			for _, line := range strings.Split(renderDiagnostic(d, f.path, f.src), "\n") {
+				fmt.Fprintf(w, "  %s\n", line)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	errors, warnings := r.counts()
+	fmt.Fprintf(w, "%s checked: %s\n", plural(len(r.files), "file"), counts(errors, warnings))
+}
+
+// jsonDiagnostic is a diagnostic as printed by --json.
+type jsonDiagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Code     string `json:"code"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Hint     string `json:"hint,omitempty"`
+}
+
+// printJSON writes the diagnostics of every file as one JSON array, in
+// file order, for editors and CI systems:
+//
+//	[
+//	  {"file": "main.org", "line": 2, "column": 9, "code": "ORG2002", "severity": "error", ...}
+//	]
+func (r *report) printJSON(w io.Writer) error {
+	out := []jsonDiagnostic{}
+	for _, f := range r.files {
+		for _, d := range f.diags {
+			out = append(out, jsonDiagnostic{
+				File:     f.path,
+				Line:     d.Span.Start.Line,
+				Column:   d.Span.Start.Column,
+				Code:     d.Code.String(),
+				Severity: d.Severity.String(),
+				Message:  d.Message,
+				Hint:     d.Hint,
+			})
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// err returns a failure when any file has errors.
+func (r *report) err(msg string) error {
+	if errors, _ := r.counts(); errors > 0 {
+		return failed(msg)
+	}
+	return nil
+}
+
+func counts(errors, warnings int) string {
+	return plural(errors, "error") + ", " + plural(warnings, "warning")
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+// sourceFiles expands the inputs of a multi-file command: files are kept
+// as given, directories are walked for .org files.
+func sourceFiles(inputs []string) ([]string, error) {
+	var files []string
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, input)
+			continue
+		}
+		err = filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".org") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/charmbracelet/lipgloss"
@@ -22,12 +23,58 @@ var rootCmd = &cobra.Command{
 Design: Distinct, yet Sober.`,
 	// Silence usages on error to keep output clean
 	SilenceUsage: true,
+	// main prints the returned error itself
+	SilenceErrors: true,
 }
 
 func Execute() error {
 	return rootCmd.Execute()
 }
 
+// Exit codes shared by every command.
+const (
+	ExitOK       = 0
+	ExitFailure  = 1 // the input has errors, already reported
+	ExitInternal = 2 // the command could not run: I/O, configuration, usage, crashes
+)
+
+// failure is returned by commands whose input has errors. The diagnostics
+// have been printed by then, so main only reports the summary message.
+type failure struct{ msg string }
+
+func (f *failure) Error() string { return f.msg }
+
+func failed(msg string) error { return &failure{msg} }
+
+// exitStatus is returned by run when the program itself exits non-zero.
+// The program has reported its own errors, so main prints nothing.
+type exitStatus int
+
+func (s exitStatus) Error() string { return fmt.Sprintf("exit status %d", int(s)) }
+
+// Reported tells whether the error returned by Execute has been reported
+// already and main should only exit with its code.
+func Reported(err error) bool {
+	var s exitStatus
+	return errors.As(err, &s)
+}
+
+// ExitCode maps the error returned by Execute to the process exit code.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var f *failure
+	if errors.As(err, &f) {
+		return ExitFailure
+	}
+	var s exitStatus
+	if errors.As(err, &s) {
+		return int(s)
+	}
+	return ExitInternal
+}
+
 func init() {
 	// Global flags can be defined here
 }
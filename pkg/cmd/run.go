@@ -1,31 +1,97 @@
 package cmd
 
 import (
-	"fmt"
-	"strings"
+	"os"
+	"time"
+
+	"orglang/pkg/eval"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
 
 	"github.com/spf13/cobra"
 )
 
 var runCmd = &cobra.Command{
 	Use:   "run [flags] <input> [args...]",
-	Short: "Compile and execute OrgLang program (TBD)",
-	Long:  `Compiles the OrgLang program and executes it immediately.`,
-	Args:  cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	Short: "Execute an OrgLang program",
+	Long: `Executes the OrgLang program with the interpreter (pkg/eval). The
+modules it imports are found as org build finds them, and each runs once.
+
+The arguments after the input, followed by those of --args, are the table
+@args. The program's main is called with them as its right operand and its
+result gives the exit status: an Integer from 0 to 255 is the status, a
+Table has its positional elements printed one per line, an Error is
+reported on stderr and exits 1. A program without main exits 2.
+
+@stdin is a source of the lines of standard input. A flow from it, such
+as @stdin -> { "${right}!" } -> @stdout, is streamed a line at a time, so
+input of any size runs in constant memory.
+
+--timeout sets a deadline for the whole run, as if main were wrapped in
+` + "`seconds deadline { ... }`" + `: past it, the next operator call returns the
+Error "deadline exceeded".
+
+--checkpoint enables the checkpoint stages of the program: each saves the
+progress of its scan in the directory given, every --checkpoint-every, and
+a later run with the same directory resumes it where the last one stopped.
+
+--use adds standard library modules, such as math, to those listed by the
+use key of the project's org.toml: their bindings are defined before the
+program's own.
+
+--watch runs the program again each time its file or a module it imports
+changes, stopping the previous run if it is still going. Changes are
+debounced: the files must stay unchanged for a moment first.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		input := args[0]
-		progArgs := args[1:]
+		if w, _ := cmd.Flags().GetBool("watch"); w {
+			return watch(input)
+		}
+		extra, _ := cmd.Flags().GetStringSlice("args")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		checkpoints, _ := cmd.Flags().GetString("checkpoint")
+		every, _ := cmd.Flags().GetDuration("checkpoint-every")
+
+		src, err := lexer.ReadSource(input)
+		if err != nil {
+			return err
+		}
+		pre, err := preludeFor(cmd, input)
+		if err != nil {
+			return err
+		}
+		l := lexer.New(src)
+		p := parser.New(l, parser.WithStrict(true), parser.WithBindings(pre.Bindings))
+		prog := pre.Apply(p.ParseProgram())
+		if diags := append(l.Diagnostics(), p.Diagnostics()...); len(diags) > 0 {
+			printDiagnostics(os.Stderr, input, src, diags)
+			return failed("run failed")
+		}
 
-		fmt.Println(headerStyle.Render("Run"))
-		printInfo("Input", input)
-		if len(progArgs) > 0 {
-			printInfo("Args", strings.Join(progArgs, " "))
+		in := eval.New()
+		in.Args = append(args[1:], extra...)
+		in.Modules, in.File = moduleLoader(cmd), input
+		if timeout > 0 {
+			in.Deadline = time.Now().Add(timeout)
+		}
+		in.Checkpoints = checkpoints
+		in.CheckpointEvery = every
+		if code := in.Run(prog, input); code != eval.ExitOK {
+			return exitStatus(code)
 		}
-		printInfo("Status", "TBD - Run logic not yet implemented")
+		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(runCmd)
 	runCmd.Flags().StringSliceP("args", "a", []string{}, "Arguments to pass to the program")
+	runCmd.Flags().Duration("timeout", 0, "Abort the program after this long (e.g. 30s); 0 for no limit")
+	runCmd.Flags().String("checkpoint", "", "Directory where checkpoint stages save their progress; none disables them")
+	runCmd.Flags().Duration("checkpoint-every", 5*time.Second, "Time between two checkpoints of a stage")
+	runCmd.Flags().StringSlice("use", nil, "Standard library modules to use (e.g. math)")
+	runCmd.Flags().Bool("watch", false, "Run again whenever the program or a module it imports changes")
+	// Flags after the input belong to the program.
+	runCmd.Flags().SetInterspersed(false)
 }
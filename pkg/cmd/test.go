@@ -2,22 +2,254 @@ package cmd
 
 import (
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/eval"
+	"orglang/pkg/lexer"
+	"orglang/pkg/manifest"
+	"orglang/pkg/parser"
 
 	"github.com/spf13/cobra"
 )
 
+// testSuffix ends the names of the files org test looks for in
+// directories.
+const testSuffix = "_test.org"
+
 var testCmd = &cobra.Command{
 	Use:   "test [flags] [files...]",
-	Short: "Run tests (TBD)",
-	Long:  `Runs tests defined in OrgLang files.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Short: "Run tests",
+	Long: `Runs the tests of OrgLang files with the interpreter (pkg/eval).
+
+A test is a top-level binding whose name starts with test_. Its value, or
+for a block without operands the result of calling it, decides the test:
+true passes, anything false fails, and so does an Error, such as that of
+a failed assert:
+
+  test_add : { assert 1 + 2 = 3; 2 + 2 = 4 };
+  test_empty : [] = [];
+
+A golden test, a binding whose name starts with golden_, is run the same
+way, but only an Error fails it: what it writes to @stdout must match
+the file next to it named after the module and the test, so
+report_test.org's golden_summary is compared with
+report_test.summary.golden. --update writes those files from the
+output instead, for new tests or after an intended change.
+
+Golden tests also cover interactive programs: @tty is a scripted
+terminal, 80 columns by 24 rows, that writes to the same output and reads
+report_test.menu.input for golden_menu, one line per prompt, password or
+key press (a character, or a key name such as enter or up). What is typed
+at a prompt is echoed as a terminal would. Colors and styles are on, and
+escape characters are written as \e in the golden file, carriage
+returns as \r, so the file stays readable and differences show.
+
+Files are run as given; directories are searched for *_test.org files,
+leaving out hidden directories and deps. Without arguments, the working
+directory is searched. Each file runs in a fresh interpreter, its tests in
+source order.
+
+--filter runs only the tests whose name matches a regular expression.
+Failures are reported with their reason, passing tests only with
+--verbose; a summary ends the run, which fails if any test did.
+
+--coverage records which statements of each file ran, at the top level
+and in blocks, and reports the share per file; --coverage-html also
+writes the files as an HTML page with the lines that ran in green and
+those that did not in red. The coverage is also saved in the cache
+directory of org clean, for org lsp to show the statements that did not
+run in the editor. The statements of the modules the test files import
+count too, after the test files, each module once however many files
+import it; the std modules and the dependencies under deps are left
+out.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		coverage, _ := cmd.Flags().GetBool("coverage")
+		coverageHTML, _ := cmd.Flags().GetString("coverage-html")
+		update, _ := cmd.Flags().GetBool("update")
+		filter, _ := cmd.Flags().GetString("filter")
+		re, err := regexp.Compile(filter)
+		if err != nil {
+			return fmt.Errorf("--filter: %w", err)
+		}
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+		files, err := testFiles(args)
+		if err != nil {
+			return err
+		}
+
+		var cov *eval.Coverage
+		if coverage || coverageHTML != "" {
+			cov = eval.NewCoverage()
+		}
+		var covered []coveredFile
+		var imported importedModules
+
 		fmt.Println(headerStyle.Render("Test"))
-		printInfo("Status", "TBD - Test framework not yet implemented")
+		passed, failures, broken := 0, 0, 0
+		for _, file := range files {
+			results, f, err := runTestFile(cmd, file, re, cov, &imported)
+			if err != nil {
+				return err
+			}
+			if f == nil {
+				broken++
+				continue
+			}
+			if cov != nil {
+				covered = append(covered, *f)
+			}
+			for _, r := range results {
+				if r.Golden {
+					r.CompareGolden(eval.GoldenFile(file, r.Name), update)
+				}
+				took := subtextStyle.Render(fmt.Sprintf("(%s)", r.Duration.Round(time.Microsecond)))
+				if r.Passed() {
+					passed++
+					if verbose {
+						fmt.Printf("PASS %s %s %s\n", relativePath(file), r.Name, took)
+					}
+					continue
+				}
+				failures++
+				fmt.Printf("FAIL %s %s %s\n     %s\n", relativePath(file), r.Name, took, r.Failure)
+			}
+		}
+
+		summary := fmt.Sprintf("%d passed, %d failed", passed, failures)
+		if broken > 0 {
+			summary += fmt.Sprintf(", %d files did not parse", broken)
+		}
+		printInfo("Tests", summary)
+		if cov != nil {
+			modules, err := imported.covered(cov, files)
+			if err != nil {
+				return err
+			}
+			covered = append(covered, modules...)
+			printCoverage(covered)
+			if err := saveProfiles(covered); err != nil {
+				return err
+			}
+		}
+		if coverageHTML != "" {
+			if err := writeCoverageHTML(coverageHTML, covered); err != nil {
+				return err
+			}
+			printInfo("Coverage report", coverageHTML)
+		}
+		if failures > 0 || broken > 0 {
+			return failed("tests failed")
+		}
+		return nil
 	},
 }
 
+// runTestFile runs the tests of file matching re, recording the
+// statements that run in cov, and the modules file imports in imported,
+// if cov is not nil. The returned file is nil if it did not parse, its
+// errors printed.
+func runTestFile(cmd *cobra.Command, file string, re *regexp.Regexp, cov *eval.Coverage, imported *importedModules) ([]eval.TestResult, *coveredFile, error) {
+	src, err := lexer.ReadSource(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	pre, err := preludeFor(cmd, file)
+	if err != nil {
+		return nil, nil, err
+	}
+	l := lexer.New(src)
+	p := parser.New(l, parser.WithStrict(true), parser.WithBindings(pre.Bindings))
+	own := p.ParseProgram()
+	if diags := append(l.Diagnostics(), p.Diagnostics()...); len(diags) > 0 {
+		printDiagnostics(os.Stderr, file, src, diags)
+		return nil, nil, nil
+	}
+	// The prelude's statements are not the file's: coverage leaves them out.
+	prog := pre.Apply(&ast.Program{Statements: own.Statements})
+	in := eval.New()
+	in.Coverage = cov
+	in.Modules, in.File = moduleLoader(cmd), file
+	if cov != nil {
+		load := in.Modules
+		in.Modules = func(from, path string) (string, *ast.Program, error) {
+			module, prog, err := load(from, path)
+			if err == nil {
+				imported.add(module, prog)
+			}
+			return module, prog, err
+		}
+	}
+	results := in.RunTests(prog, re.MatchString, func(name string) []string {
+		return goldenInput(eval.GoldenInput(file, name))
+	})
+	f := &coveredFile{path: file, src: src}
+	if cov != nil {
+		f.stmts = cov.Statements(own)
+	}
+	return results, f, nil
+}
+
+// goldenInput returns the lines of the input file of a golden test; a
+// test without one reads nothing.
+func goldenInput(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"), "\n")
+}
+
+// testFiles expands the inputs of org test: files are kept as given,
+// directories are walked for test files, skipping hidden directories and
+// those of dependencies.
+func testFiles(inputs []string) ([]string, error) {
+	var files []string
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, input)
+			continue
+		}
+		err = filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != input && (strings.HasPrefix(d.Name(), ".") || d.Name() == manifest.DepsDir) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(path, testSuffix) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
 func init() {
 	rootCmd.AddCommand(testCmd)
-	testCmd.Flags().BoolP("verbose", "v", false, "Verbose output")
-	testCmd.Flags().Bool("coverage", false, "Generate coverage report")
+	testCmd.Flags().BoolP("verbose", "v", false, "Also list the tests that pass")
+	testCmd.Flags().String("filter", "", "Run only the tests whose name matches this regular expression")
+	testCmd.Flags().Bool("update", false, "Write the output of golden tests to their golden files")
+	testCmd.Flags().StringSlice("use", nil, "Standard library modules to use (e.g. math)")
+	testCmd.Flags().Bool("coverage", false, "Report the share of statements the tests ran")
+	testCmd.Flags().String("coverage-html", "", "Write the coverage of each file as an HTML page to this file")
 }
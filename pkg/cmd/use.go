@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/codegen"
+	"orglang/pkg/eval"
+	"orglang/pkg/lexer"
+	"orglang/pkg/manifest"
+	"orglang/pkg/parser"
+	"orglang/pkg/std"
+
+	"github.com/spf13/cobra"
+)
+
+// preludeFor loads the std modules input uses: those of the use list of
+// the enclosing project's org.toml, then those of --use.
+func preludeFor(cmd *cobra.Command, input string) (*std.Prelude, error) {
+	var names []string
+	m, err := manifest.Find(filepath.Dir(input))
+	if err != nil {
+		return nil, err
+	}
+	if m != nil {
+		names = append(names, m.Use...)
+	}
+	extra, _ := cmd.Flags().GetStringSlice("use")
+	names = append(names, extra...)
+	return std.Load(names)
+}
+
+// moduleLoader returns the eval.Loader of org run, test and debug: it
+// resolves an import as org build does (codegen.CanonicalPath) and
+// parses the module with the operators of the std modules it uses.
+func moduleLoader(cmd *cobra.Command) eval.Loader {
+	return func(from, path string) (string, *ast.Program, error) {
+		file, err := codegen.CanonicalPath(from, path)
+		if err != nil {
+			return "", nil, err
+		}
+		src, err := lexer.ReadSource(file)
+		if err != nil {
+			return "", nil, err
+		}
+		pre, err := preludeFor(cmd, file)
+		if err != nil {
+			return "", nil, err
+		}
+		l := lexer.New(src)
+		p := parser.New(l, parser.WithStrict(true), parser.WithBindings(pre.Bindings))
+		prog := p.ParseProgram()
+		if diags := append(l.Diagnostics(), p.Diagnostics()...); len(diags) > 0 {
+			return "", nil, fmt.Errorf("%s: %s", relativePath(file), diags[0])
+		}
+		return file, prog, nil
+	}
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/codegen"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+)
+
+const (
+	// watchInterval is how often the watched files are checked.
+	watchInterval = 200 * time.Millisecond
+	// watchDebounce is how long the files must stay unchanged after a
+	// change before the command runs again, so an editor saving several
+	// files, or one file in several writes, causes a single rebuild.
+	watchDebounce = 300 * time.Millisecond
+)
+
+// watch runs the command again, in a child process of the same org
+// binary with the same arguments but --watch, each time input or a module
+// it imports changes, stopping the previous run if it is still going. It
+// returns only if the child cannot be started; Ctrl-C stops both.
+func watch(input string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := unwatchedArgs(os.Args[1:], input)
+	for {
+		files := watchedFiles(input)
+		stamps := fileStamps(files)
+		child := exec.Command(exe, args...)
+		child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := child.Start(); err != nil {
+			return err
+		}
+		done := make(chan error, 1)
+		go func() { done <- child.Wait() }()
+
+		running := true
+		changed := ""
+		ticker := time.NewTicker(watchInterval)
+		for changed == "" {
+			select {
+			case err := <-done:
+				running, done = false, nil
+				watchStatus(exitMessage(err) + ", waiting for changes")
+			case <-ticker.C:
+				changed = changedFile(files, stamps)
+			}
+		}
+		ticker.Stop()
+		for stamps = fileStamps(files); ; stamps = fileStamps(files) {
+			time.Sleep(watchDebounce)
+			if changedFile(files, stamps) == "" {
+				break
+			}
+		}
+		watchStatus(relativePath(changed) + " changed, rebuilding…")
+		if running {
+			child.Process.Kill()
+			<-done
+		}
+	}
+}
+
+// unwatchedArgs returns the arguments of the command without --watch,
+// which only the flags before input may hold: those after it belong to
+// the program.
+func unwatchedArgs(args []string, input string) []string {
+	var out []string
+	flags := true
+	for _, a := range args {
+		if a == input {
+			flags = false
+		}
+		if flags && (a == "--watch" || a == "--watch=true") {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// watchedFiles returns the canonical paths of input and of the modules it
+// imports, directly or not. A module that does not parse is still
+// watched, as is an import of a missing file, which a fix may create;
+// the imports of a module with a syntax error are those it had parsed.
+func watchedFiles(input string) []string {
+	var files []string
+	codegen.LoadModules(input, func(path string) (*ast.Program, error) {
+		files = append(files, path)
+		src, err := lexer.ReadSource(path)
+		if err != nil {
+			return &ast.Program{}, nil
+		}
+		return parser.New(lexer.New(src)).ParseProgram(), nil
+	})
+	if len(files) == 0 {
+		files = []string{input}
+	}
+	return files
+}
+
+// fileStamp is what tells that a file changed: a file written twice
+// within the resolution of its modification time changes size, usually.
+type fileStamp struct {
+	mod  time.Time
+	size int64
+}
+
+// fileStamps returns the stamps of files; a missing file has the zero
+// stamp.
+func fileStamps(files []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(files))
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			stamps[f] = fileStamp{info.ModTime(), info.Size()}
+		} else {
+			stamps[f] = fileStamp{}
+		}
+	}
+	return stamps
+}
+
+// changedFile returns the first of files whose stamp is no longer the one
+// in stamps, or "" if none changed.
+func changedFile(files []string, stamps map[string]fileStamp) string {
+	now := fileStamps(files)
+	for _, f := range files {
+		if now[f] != stamps[f] {
+			return f
+		}
+	}
+	return ""
+}
+
+// exitMessage describes how a child run ended.
+func exitMessage(err error) string {
+	var exit *exec.ExitError
+	switch {
+	case err == nil:
+		return "exited"
+	case errors.As(err, &exit):
+		return fmt.Sprintf("exited with status %d", exit.ExitCode())
+	default:
+		return err.Error()
+	}
+}
+
+func watchStatus(msg string) {
+	fmt.Fprintln(os.Stderr, subtextStyle.Render("[watch] "+msg))
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestUnwatchedArgs(t *testing.T) {
+	// --watch is the command's before the input, the program's after it.
+	args := []string{"run", "--watch", "-v", "main.org", "--watch", "x"}
+	got := unwatchedArgs(args, "main.org")
+	if want := []string{"run", "-v", "main.org", "--watch", "x"}; !slices.Equal(got, want) {
+		t.Errorf("unwatchedArgs(%q) = %q, want %q", args, got, want)
+	}
+	args = []string{"build", "--watch=true", "main.org"}
+	if got, want := unwatchedArgs(args, "main.org"), []string{"build", "main.org"}; !slices.Equal(got, want) {
+		t.Errorf("unwatchedArgs(%q) = %q, want %q", args, got, want)
+	}
+}
+
+func TestWatchedFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("main.org", `a : "lib/a.org" @ org; m : "missing.org" @ org;`)
+	write("lib/a.org", `b : "b.org" @ org;`)
+	write("lib/b.org", `x : 1;`)
+
+	var got []string
+	for _, f := range watchedFiles(filepath.Join(dir, "main.org")) {
+		got = append(got, filepath.Base(f))
+	}
+	slices.Sort(got)
+	if want := []string{"a.org", "b.org", "main.org", "missing.org"}; !slices.Equal(got, want) {
+		t.Errorf("watched %q, want %q", got, want)
+	}
+}
+
+func TestChangedFile(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.org"), filepath.Join(dir, "b.org")
+	if err := os.WriteFile(a, []byte("x : 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	files := []string{a, b}
+	stamps := fileStamps(files)
+	if got := changedFile(files, stamps); got != "" {
+		t.Fatalf("changedFile = %q before any change", got)
+	}
+
+	// A change, even a file created, is still seen once the debounce
+	// has passed.
+	if err := os.WriteFile(b, []byte("y : 2;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(watchDebounce)
+	if got := changedFile(files, stamps); got != b {
+		t.Errorf("changedFile = %q, want %q", got, b)
+	}
+	stamps = fileStamps(files)
+	if err := os.WriteFile(a, []byte("x : 10;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(watchDebounce)
+	if got := changedFile(files, stamps); got != a {
+		t.Errorf("changedFile = %q, want %q", got, a)
+	}
+}
@@ -0,0 +1,125 @@
+package codegen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Artifacts records the files builds write outside the module cache (C
+// sources, headers, libraries, binaries), wherever they are written, so
+// that org clean can remove them. The record is a JSON file at Path,
+// mapping the absolute path of each artifact to a hash of what the build
+// wrote: a file changed since, by hand or by another tool, is no longer
+// the build's and is kept.
+type Artifacts struct {
+	Path string
+}
+
+// Record adds the files at paths, as they are now, to the record.
+func (a *Artifacts) Record(paths ...string) error {
+	files, err := a.load()
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		sum, err := fileHash(abs)
+		if err != nil {
+			return err
+		}
+		files[abs] = sum
+	}
+	return a.save(files)
+}
+
+// Remove deletes the recorded artifacts that are unchanged and forgets
+// them all. It returns the files removed and those kept because they
+// changed since they were built, in order; files already gone are
+// neither.
+func (a *Artifacts) Remove() (removed, kept []string, err error) {
+	files, err := a.load()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		sum, err := fileHash(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			continue
+		case err != nil || sum != files[path]:
+			kept = append(kept, path)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, kept, err
+		}
+		removed = append(removed, path)
+	}
+	if err := os.Remove(a.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return removed, kept, err
+	}
+	return removed, kept, nil
+}
+
+// load reads the record; a missing one is empty.
+func (a *Artifacts) load() (map[string]string, error) {
+	files := map[string]string{}
+	data, err := os.ReadFile(a.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return files, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &files); err != nil {
+		return nil, fmt.Errorf("%s: %w", a.Path, err)
+	}
+	return files, nil
+}
+
+// save writes the record through a temporary file renamed into place.
+func (a *Artifacts) save(files map[string]string) error {
+	data, err := json.MarshalIndent(files, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(a.Path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(a.Path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), a.Path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func fileHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
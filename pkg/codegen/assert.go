@@ -0,0 +1,8 @@
+package codegen
+
+// Assertions reports whether a build checks the assert statements of the
+// program: a --debug build always does, an optimized one (-O2 and above)
+// compiles them to nothing, and the others check them.
+func Assertions(debug bool, optimize int) bool {
+	return debug || optimize < 2
+}
@@ -0,0 +1,43 @@
+package codegen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// auxHashLen is the number of hex digits of the content hash kept in the
+// name of an auxiliary function.
+const auxHashLen = 12
+
+// AuxNamer names the auxiliary C functions the emitter generates, such as
+// the body of a function literal (kind "fn") or a module initialiser
+// (kind "module").
+//
+// Names derive from a hash of the module path and the function's body
+// rather than from a counter, so editing unrelated code leaves them, and
+// the generated C around them, unchanged. Functions with identical bodies
+// in one module are told apart by their order in the source: the second
+// gets a _2 suffix, and so on.
+type AuxNamer struct {
+	used map[string]int
+}
+
+// NewAuxNamer creates a namer with no names taken.
+func NewAuxNamer() *AuxNamer {
+	return &AuxNamer{used: map[string]int{}}
+}
+
+// Name returns the C name of an auxiliary function of the given kind in
+// module. body must be a canonical rendering of the function, e.g. the
+// String() of its AST node, so formatting changes do not rename it.
+// Functions must be named in source order.
+func (n *AuxNamer) Name(kind, module, body string) string {
+	sum := sha256.Sum256([]byte(module + "\x00" + body))
+	name := "org_" + kind + "_" + hex.EncodeToString(sum[:])[:auxHashLen]
+	n.used[name]++
+	if k := n.used[name]; k > 1 {
+		name += "_" + strconv.Itoa(k)
+	}
+	return name
+}
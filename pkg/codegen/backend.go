@@ -0,0 +1,38 @@
+package codegen
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"orglang/pkg/ir"
+)
+
+// Backend prints lowered modules as the source of a compiler: C for a C
+// compiler, or LLVM IR for llc. Both print the same symbols, so the
+// modules of a program can be built by either.
+type Backend struct {
+	Name  string // as given to org build --backend
+	Ext   string // of the files it prints
+	Print func(w io.Writer, m *ir.Module, self string, syms *SymbolTable) error
+}
+
+// Backends returns the backends, the default first.
+func Backends() []Backend {
+	return []Backend{
+		{"c", ".c", PrintC},
+		{"llvm", ".ll", PrintLLVM},
+	}
+}
+
+// FindBackend returns the backend named name.
+func FindBackend(name string) (Backend, error) {
+	var names []string
+	for _, b := range Backends() {
+		if b.Name == name {
+			return b, nil
+		}
+		names = append(names, b.Name)
+	}
+	return Backend{}, fmt.Errorf("unknown backend %q (have %s)", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,79 @@
+package codegen
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+)
+
+// cacheFormat is mixed into every cache key; bump it when the layout of
+// the cached artifacts changes, so old entries are never read back.
+const cacheFormat = "org-module-cache-1"
+
+// ModuleCache stores the artifacts compiled from modules (emitted C, or
+// an object file) in Dir, by a key hashing everything they depend on, so
+// an unchanged module is not compiled again. Entries are never updated
+// in place: a changed module has a new key.
+type ModuleCache struct {
+	Dir string
+}
+
+// ModuleKey returns the cache key of the artifact compiled from the
+// module at path (canonical, see CanonicalPath) with source src. The path
+// matters as well as the content, since it prefixes the module's C
+// symbols; toolchain identifies the compiler (its version) and the
+// options affecting the output (optimization level, target).
+func ModuleKey(path string, src []byte, toolchain string) string {
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(cacheFormat), []byte(toolchain), []byte(path), src} {
+		// Length-prefix each part, so no two inputs hash alike.
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(part))))
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// path returns the file of key, under a directory named by its first two
+// digits to keep directories small.
+func (c *ModuleCache) path(key, ext string) string {
+	return filepath.Join(c.Dir, key[:2], key+ext)
+}
+
+// Get returns the artifact stored under key with extension ext (".c",
+// ".o"), if any.
+func (c *ModuleCache) Get(key, ext string) ([]byte, bool) {
+	data, err := os.ReadFile(c.path(key, ext))
+	return data, err == nil
+}
+
+// Put stores data under key with extension ext. It writes a temporary
+// file and renames it, so concurrent builds never read a partial entry.
+func (c *ModuleCache) Put(key, ext string, data []byte) error {
+	path := c.path(key, ext)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Clear removes every entry, and Dir itself. A missing Dir is not an
+// error.
+func (c *ModuleCache) Clear() error {
+	return os.RemoveAll(c.Dir)
+}
@@ -0,0 +1,121 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"orglang/examples"
+	"orglang/pkg/ir"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+)
+
+// Results of these benchmarks are recorded in testdata/bench.txt; update
+// it when changing what PrintC emits:
+//
+//	go test ./pkg/codegen -run '^$' -bench Generate -benchmem -count 3
+//
+// Besides the time, each reports C-bytes/op, the size of the C emitted:
+// what a reader of --emit=c reviews and the C compiler has to compile.
+
+// generate lowers the program src, read from path, and returns its C.
+// Constructs the lowering does not support are emitted as the Error they
+// give, as org build --emit=c does.
+func generate(tb testing.TB, path string, src []byte) []byte {
+	tb.Helper()
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		tb.Fatalf("%s: parse errors: %v", path, p.Errors()[:1])
+	}
+	m, _ := ir.Lower(prog, path)
+	var out bytes.Buffer
+	if err := PrintC(&out, m, "", nil); err != nil {
+		tb.Fatalf("%s: %v", path, err)
+	}
+	return out.Bytes()
+}
+
+// chainedBindings returns n bindings, each using the previous one.
+func chainedBindings(n int) string {
+	var b strings.Builder
+	b.WriteString("x0 : 0;\n")
+	for i := 1; i < n; i++ {
+		fmt.Fprintf(&b, "x%d : x%d + %d * 2;\n", i, i-1, i)
+	}
+	return b.String()
+}
+
+// manyBlocks returns n bindings of a block guarding its operand.
+func manyBlocks(n int) string {
+	var b strings.Builder
+	for i := range n {
+		fmt.Fprintf(&b, "f%d : { right > %d ? right - 1 : right * 2 };\n", i, i)
+	}
+	return b.String()
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	type input struct {
+		name string
+		src  string
+	}
+	inputs := []input{
+		{"Bindings1k", chainedBindings(1000)},
+		{"Blocks1k", manyBlocks(1000)},
+	}
+	for _, e := range examples.List() {
+		inputs = append(inputs, input{"Example_" + e.Name, string(e.Source())})
+	}
+	for _, in := range inputs {
+		b.Run(in.name, func(b *testing.B) {
+			src := []byte(in.src)
+			b.SetBytes(int64(len(src)))
+			b.ReportAllocs()
+			var size int
+			for b.Loop() {
+				size = len(generate(b, "bench.org", src))
+			}
+			b.ReportMetric(float64(size), "C-bytes/op")
+		})
+	}
+}
+
+// TestCSizeBudget keeps the C of trivial programs small, and that of
+// longer ones growing with the source, not faster: a change making them
+// exceed their budget should be deliberate, raising it here.
+func TestCSizeBudget(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		budget int // bytes
+	}{
+		{"empty", "", 640},
+		{"binding", "x : 1;", 900},
+		{"hello", "main : { \"Hello, world!\" -> @stdout };", 1500},
+		{"block", "double : { right * 2 };", 1400},
+	}
+	for _, tt := range tests {
+		if n := len(generate(t, "m.org", []byte(tt.src))); n > tt.budget {
+			t.Errorf("%s: %d bytes of C, over the budget of %d", tt.name, n, tt.budget)
+		}
+	}
+
+	// A binding or block more adds about as much C each time.
+	for _, tt := range []struct {
+		name   string
+		gen    func(int) string
+		budget int // bytes per binding
+	}{
+		{"bindings", chainedBindings, 550},
+		{"blocks", manyBlocks, 800},
+	} {
+		small := len(generate(t, "m.org", []byte(tt.gen(10))))
+		large := len(generate(t, "m.org", []byte(tt.gen(110))))
+		if per := (large - small) / 100; per > tt.budget {
+			t.Errorf("%s: %d bytes of C per binding, over the budget of %d", tt.name, per, tt.budget)
+		}
+	}
+}
@@ -0,0 +1,969 @@
+package codegen
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/diag"
+	"orglang/pkg/ir"
+	"orglang/pkg/lexer"
+	"orglang/pkg/manifest"
+	"orglang/pkg/parser"
+	"orglang/pkg/runtime"
+)
+
+func TestMangleIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"count":   "count",
+		"add_one": "add__one",
+		"plus!":   "plus_u0021",
+		"π":       "_u03C0",
+		"🚀":       "_U0001F680",
+		"_u0021":  "__u0021",
+	}
+	for in, expected := range tests {
+		if got := MangleIdentifier(in); got != expected {
+			t.Errorf("MangleIdentifier(%q): expected %q, got %q", in, expected, got)
+		}
+	}
+}
+
+func TestMangleIdentifierAdversarial(t *testing.T) {
+	// Each pair must mangle to distinct names.
+	inputs := []string{
+		"_u0021", "!",
+		"café",         // precomposed é
+		"café",        // e + combining acute accent
+		"👨‍👩‍👧", "👨👩👧", // with and without zero-width joiners
+		"a_", "a__",
+		"", "_",
+	}
+	seen := map[string]string{}
+	for _, in := range inputs {
+		out := MangleIdentifier(in)
+		if prev, ok := seen[out]; ok {
+			t.Errorf("%q and %q both mangle to %q", prev, in, out)
+		}
+		seen[out] = in
+	}
+}
+
+func TestGlobalSymbol(t *testing.T) {
+	if got := GlobalSymbol("lib", "helper"); got != "org_v3_lib_helper" {
+		t.Errorf("got %q", got)
+	}
+	if got := GlobalSymbol("lib", "stdout"); got != "org_var_stdout" {
+		t.Errorf("exempt global should not be module prefixed, got %q", got)
+	}
+	if GlobalSymbol("a", "b_c") == GlobalSymbol("a_b", "c") {
+		t.Error("a.b_c and a_b.c must not share a symbol")
+	}
+	if GlobalSymbol("a", "_b") == GlobalSymbol("a_", "b") {
+		t.Error("a._b and a_.b must not share a symbol")
+	}
+}
+
+func TestGlobalSymbolLengthLimit(t *testing.T) {
+	long := strings.Repeat("∑", 40)
+	a := GlobalSymbol("lib", long)
+	b := GlobalSymbol("lib", long+"x")
+	for _, sym := range []string{a, b} {
+		if len(sym) != MaxIdentLen {
+			t.Errorf("%q: expected length %d, got %d", sym, MaxIdentLen, len(sym))
+		}
+		if !strings.HasPrefix(sym, "org_v3_lib_") {
+			t.Errorf("%q: lost its readable prefix", sym)
+		}
+	}
+	if a == b {
+		t.Error("names sharing a long prefix must keep distinct symbols")
+	}
+	if got := LimitIdent("short"); got != "short" {
+		t.Errorf("short symbols must be kept, got %q", got)
+	}
+}
+
+func declare(t *SymbolTable, module, name string, line int) error {
+	return t.Declare(Symbol{C: GlobalSymbol(module, name), Module: module, Binding: name, Line: line})
+}
+
+func TestSymbolTableCollision(t *testing.T) {
+	st := NewSymbolTable()
+	if err := st.Declare(Symbol{C: "org_fn", Module: "a", Binding: "f", Line: 1}); err != nil {
+		t.Fatal(err)
+	}
+	err := st.Declare(Symbol{C: "org_fn", Module: "b", Binding: "g", Line: 3})
+	if err == nil {
+		t.Fatal("expected a collision")
+	}
+	for _, want := range []string{"org_fn", `"f" (a:1)`, `"g" (b:3)`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+	if st.Check() == nil {
+		t.Error("Check should report the collision")
+	}
+	if diags := st.Diagnostics("a"); len(diags) != 0 {
+		t.Errorf("Diagnostics of a = %+v, want none", diags)
+	}
+	diags := st.Diagnostics("b")
+	if len(diags) != 1 || diags[0].Code != diag.SymbolClash || diags[0].Span.Start.Line != 3 {
+		t.Errorf("Diagnostics = %+v, want one ORG4001 at line 3", diags)
+	}
+}
+
+func TestSymbolTableMangledNames(t *testing.T) {
+	st := NewSymbolTable()
+	declare(st, "a", "b_c", 1)
+	declare(st, "a_b", "c", 1)
+	if err := st.Check(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSymbolTableExemptGlobals(t *testing.T) {
+	st := NewSymbolTable()
+	if err := declare(st, "main", "stdout", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := declare(st, "lib", "stdout", 7); err == nil {
+		t.Error("two modules defining stdout should collide")
+	}
+}
+
+func TestSymbolTableRebinding(t *testing.T) {
+	st := NewSymbolTable()
+	declare(st, "main", "x", 1)
+	if err := declare(st, "main", "x", 2); err != nil {
+		t.Errorf("rebinding within a module is not a collision: %v", err)
+	}
+	if err := st.Check(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPrintCSymbolClash(t *testing.T) {
+	lower := func(path, src string) *ir.Module {
+		m, diags := ir.Lower(parser.New(lexer.New([]byte(src))).ParseProgram(), path)
+		if len(diags) > 0 {
+			t.Fatal(diags)
+		}
+		return m
+	}
+	syms := NewSymbolTable()
+	syms.DeclareModule(lower("lib.org", "stdout : 1;\nx : 2;"))
+	var b strings.Builder
+	err := PrintC(&b, lower("main.org", "x : 1;\nstdout : 2;"), "", syms)
+	if err == nil || !strings.Contains(err.Error(), `"stdout" (lib.org:1) and "stdout" (main.org:2)`) {
+		t.Errorf("err = %v", err)
+	}
+	if b.Len() > 0 {
+		t.Errorf("wrote C despite the clash:\n%s", b.String())
+	}
+	if diags := syms.Diagnostics("main.org"); len(diags) != 1 || diags[0].Span.Start.Line != 2 {
+		t.Errorf("Diagnostics = %+v", diags)
+	}
+}
+
+func TestAuxNamerStable(t *testing.T) {
+	bodies := []string{"{ (left + right) }", "{ (right * 2) }"}
+
+	a := NewAuxNamer()
+	first := []string{a.Name("fn", "main", bodies[0]), a.Name("fn", "main", bodies[1])}
+
+	// An unrelated function inserted before them must not rename them.
+	b := NewAuxNamer()
+	b.Name("fn", "main", "{ (right - 1) }")
+	second := []string{b.Name("fn", "main", bodies[0]), b.Name("fn", "main", bodies[1])}
+
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("%q renamed to %q after an unrelated insertion", first[i], second[i])
+		}
+		if !strings.HasPrefix(first[i], "org_fn_") || len(first[i]) != len("org_fn_")+auxHashLen {
+			t.Errorf("unexpected name %q", first[i])
+		}
+	}
+	if first[0] == first[1] {
+		t.Error("different bodies must get different names")
+	}
+}
+
+func TestAuxNamerDistinct(t *testing.T) {
+	n := NewAuxNamer()
+	body := "{ (right + 1) }"
+	x := n.Name("fn", "main", body)
+	y := n.Name("fn", "main", body)
+	if y != x+"_2" {
+		t.Errorf("identical bodies: got %q and %q", x, y)
+	}
+	if z := n.Name("fn", "lib", body); z == x || z == y {
+		t.Errorf("same body in another module must get another name, got %q", z)
+	}
+	if m := n.Name("module", "main", body); !strings.HasPrefix(m, "org_module_") {
+		t.Errorf("kind must prefix the name, got %q", m)
+	}
+}
+
+// TestReservedRuntimeSymbols checks that every name the runtime headers
+// declare is reserved, so new runtime functions stay out of the way of
+// the C names of exports.
+func TestReservedRuntimeSymbols(t *testing.T) {
+	headers, err := filepath.Glob("../runtime/*/*.h")
+	if err != nil || len(headers) == 0 {
+		t.Fatalf("no runtime headers found: %v", err)
+	}
+	comment := regexp.MustCompile(`(?s)/\*.*?\*/|//[^\n]*`)
+	decl := regexp.MustCompile(`(?m)(?:^#define\s+(\w+)|^\w[\w\s*]*?\b(\w+)\s*\(|^typedef\s[^;{]*?\b(\w+);|^}\s*(\w+);)`)
+	for _, h := range headers {
+		src, err := os.ReadFile(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range decl.FindAllStringSubmatch(comment.ReplaceAllString(string(src), ""), -1) {
+			name := m[1] + m[2] + m[3] + m[4]
+			if cReserved[name] || strings.HasPrefix(name, "mpz") || strings.HasPrefix(name, "mpq") {
+				continue
+			}
+			if !Reserved(name) {
+				t.Errorf("%s: %s is not reserved", h, name)
+			}
+		}
+	}
+}
+
+func TestHeader(t *testing.T) {
+	h, err := Header("mathlib", "mathlib.org", []Export{
+		{Binding: "sq", Kind: "prefix operator (bp 100)", Operator: true, Doc: "Squares right.\n\nMore text."},
+		{Binding: "pi", Kind: "value", Doc: "Ends a comment */ early?"},
+		{Binding: "add", C: "ml_add", Kind: "infix operator (lbp 50, rbp 51)", Operator: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"#ifndef ORG_LIB_MATHLIB_H\n",
+		"#include \"orglang/core/values.h\"\n",
+		"extern \"C\" {",
+		"int mathlib_init(void);\n",
+		"void mathlib_shutdown(void);\n",
+		"/* add: infix operator (lbp 50, rbp 51). */\nOrgValue ml_add(OrgValue left, OrgValue right);\n",
+		"/* pi: value. Ends a comment * / early? */\nOrgValue mathlib_pi(void);\n",
+		"/* sq: prefix operator (bp 100). Squares right. */\nOrgValue mathlib_sq(OrgValue left, OrgValue right);\n",
+		"#endif /* ORG_LIB_MATHLIB_H */\n",
+	} {
+		if !strings.Contains(h, want) {
+			t.Errorf("header lacks %q:\n%s", want, h)
+		}
+	}
+	if strings.Index(h, "mathlib_sq") > strings.Index(h, "ml_add") {
+		t.Errorf("exports not sorted by C name:\n%s", h)
+	}
+}
+
+func TestLibrarySource(t *testing.T) {
+	src, err := LibrarySource("mathlib", "lib/mathlib.org", "org_library.c", []Export{
+		{Binding: "sq", Kind: "prefix operator (bp 100)", Operator: true, File: "lib/mathlib.org", Line: 4},
+		{Binding: "pi", Kind: "value"},
+		{Binding: "add", C: "ml_add", Kind: "infix operator (lbp 50, rbp 51)", Operator: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sq := GlobalSymbol("mathlib", "sq")
+	for _, want := range []string{
+		"#include \"mathlib.h\"\n#include \"liborg.h\"\n",
+		"OrgValue org_module_init_mathlib(Arena *arena);\n",
+		"OrgValue " + sq + "(OrgValue env);\n",
+		"int mathlib_init(void) {\n  if (arena)\n    return 0;\n",
+		"  module = org_module_init_mathlib(arena);\n",
+		"void mathlib_shutdown(void) {\n",
+		"#line 4 \"lib/mathlib.org\"\nOrgValue mathlib_sq(OrgValue left, OrgValue right) {\n  return org_call(arena, " + sq + "(module), left, right);\n}\n#line ",
+		"OrgValue mathlib_pi(void) { return " + GlobalSymbol("mathlib", "pi") + "(module); }\n",
+		"OrgValue ml_add(OrgValue left, OrgValue right) {\n",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("source lacks %q:\n%s", want, src)
+		}
+	}
+	if !strings.Contains(src, `"org_library.c"`) {
+		t.Errorf("directives do not return to org_library.c:\n%s", src)
+	}
+}
+
+func TestLineDirectives(t *testing.T) {
+	if got := LineDirective(3, `my "lib".org`); got != `#line 3 "my \"lib\".org"`+"\n" {
+		t.Errorf("LineDirective = %q", got)
+	}
+	exports := []Export{
+		{Binding: "sq", Kind: "prefix operator (bp 100)", Operator: true, File: "lib/mathlib.org", Line: 12},
+		{Binding: "pi", Kind: "value"},
+	}
+	h, err := Header("mathlib", "mathlib.org", exports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(h, "\n")
+	i := slices.Index(lines, "OrgValue mathlib_sq(OrgValue left, OrgValue right);")
+	if i < 1 || lines[i-1] != `#line 12 "lib/mathlib.org"` || lines[i+1] != fmt.Sprintf(`#line %d "mathlib.h"`, i+3) {
+		t.Errorf("declaration of sq not mapped to its binding:\n%s", h)
+	}
+	if strings.Count(h, "#line") != 2 {
+		t.Errorf("want directives around sq only:\n%s", h)
+	}
+
+	src, err := PythonModule("mathlib", "mathlib.org", exports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "#line 12 \"lib/mathlib.org\"\n    result = org_to_py(mathlib_sq(l, r));\n#line "; !strings.Contains(src, want) {
+		t.Errorf("module lacks %q:\n%s", want, src)
+	}
+}
+
+func TestPrintC(t *testing.T) {
+	src := "double : { right * 2 };\nbig : 10000000000000000000000 && double 3"
+	p := parser.New(lexer.New([]byte(src)))
+	m, diags := ir.Lower(p.ParseProgram(), "m.org")
+	if len(diags) > 0 || len(p.Errors()) > 0 {
+		t.Fatalf("lower: %v %v", p.Errors(), diags)
+	}
+	var b strings.Builder
+	if err := PrintC(&b, m, "m.c", nil); err != nil {
+		t.Fatal(err)
+	}
+	c := b.String()
+	fn := NewAuxNamer().Name("fn", "m", "{ (right * 2) }")
+	for _, want := range []string{
+		"#include \"liborg.h\"",
+		"static OrgValue " + fn + "(Arena *arena, OrgValue env, OrgValue self, OrgValue left, OrgValue right);\nOrgValue org_module_init_m(Arena *arena);\nOrgValue org_v1_m_double(OrgValue env);\nOrgValue org_v1_m_big(OrgValue env);\n\nstatic OrgValue org_module_top_m(Arena *arena, OrgValue env) {",
+		"\nOrgValue org_module_init_m(Arena *arena) {\n\tstatic OrgValue module = ORG_UNUSED;\n\tif (ORG_IS_UNUSED(module)) {\n\t\tmodule = org_module_scope(arena);\n\t\torg_module_top_m(arena, module);\n\t}\n\treturn module;\n}\n",
+		"OrgValue v0 = org_make_closure(arena, " + fn + ", env, 1, \"{ (right * 2) }\");",
+		// The block reads right only, so only right is guarded.
+		"OrgValue left, OrgValue right) {\n\tright = org_operand(arena, right, \"right\", \"{ (right * 2) }\");\n#line",
+		"\torg_scope_bind(arena, env, \"double\", v0);",
+		`org_make_bigint_str(arena, "10000000000000000000000")`,
+		"OrgValue v2 = org_mul(arena, v0, v1);",
+		"org_call(arena, org_scope_get(arena, env, \"double\"), ORG_UNUSED, v",
+		"if (org_is_error(v",
+		"b1:;\n",
+		"\nOrgValue org_v1_m_double(OrgValue env) { return org_table_get_cstr(env, \"double\"); }\nOrgValue org_v1_m_big(OrgValue env) {",
+	} {
+		if !strings.Contains(c, want) {
+			t.Errorf("C lacks %q:\n%s", want, c)
+		}
+	}
+	// The code of each line follows a directive naming it, and each
+	// function one returning to the C file at the right line.
+	lines := strings.Split(c, "\n")
+	for i, l := range lines {
+		if n, ok := strings.CutPrefix(l, "#line "); ok && strings.HasSuffix(n, `"m.c"`) {
+			if want := fmt.Sprintf("#line %d \"m.c\"", i+2); l != want {
+				t.Errorf("line %d is %q, want %q", i+1, l, want)
+			}
+		}
+	}
+	if !strings.Contains(c, "#line 2 \"m.org\"\n\tOrgValue v2 = org_make_bigint_str") {
+		t.Errorf("line 2 not mapped:\n%s", c)
+	}
+	if strings.Count(c, `"m.c"`) != 2 {
+		t.Errorf("want a directive back to m.c after each of the 2 functions:\n%s", c)
+	}
+}
+
+func TestPrintCLoop(t *testing.T) {
+	p := parser.New(lexer.New([]byte("1 loop { break right }")))
+	m, diags := ir.Lower(p.ParseProgram(), "m.org")
+	if len(diags) > 0 || len(p.Errors()) > 0 {
+		t.Fatalf("lower: %v %v", p.Errors(), diags)
+	}
+	var b strings.Builder
+	if err := PrintC(&b, m, "m.c", nil); err != nil {
+		t.Fatal(err)
+	}
+	c := b.String()
+	// The step is called in a loop of gotos on its state, v3, until its
+	// result is a break, whose value is the loop's.
+	for _, want := range []string{
+		"b4:;\n\tOrgValue v4 = org_call(arena, v2, ORG_UNUSED, v3);\n",
+		"\tif (org_is_break(v4)) goto b8;\n\tgoto b10;\n",
+		"b8:;\n\tOrgValue v5 = org_break_value(v4);\n\tv1 = v5;\n\tgoto b1;\n",
+		"b10:;\n\tv3 = v4;\n\tgoto b4;\n",
+		"OrgValue v1 = org_make_break(arena, v0);",
+	} {
+		if !strings.Contains(c, want) {
+			t.Errorf("C lacks %q:\n%s", want, c)
+		}
+	}
+}
+
+func TestPrintCImport(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "app", "lib"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app", manifest.FileName), manifest.Scaffold("app"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app", "lib", "util.org"), []byte("f : { right };"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both paths name the same module, so its initialiser is declared once.
+	src := "lib : \"./lib/util.org\" @ org;\nagain : \"lib/util.org\" @ org;\nx : lib.f 1"
+	p := parser.New(lexer.New([]byte(src)))
+	m, diags := ir.Lower(p.ParseProgram(), filepath.Join(dir, "app", "main.org"))
+	if len(diags) > 0 || len(p.Errors()) > 0 {
+		t.Fatalf("lower: %v %v", p.Errors(), diags)
+	}
+	if got, want := Imports(m), []string{"org_module_init_lib_u002Futil"}; !slices.Equal(got, want) {
+		t.Errorf("Imports = %q, want %q", got, want)
+	}
+	var b strings.Builder
+	if err := PrintC(&b, m, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	c := b.String()
+	if n := strings.Count(c, "OrgValue org_module_init_lib_u002Futil(Arena *arena);\n"); n != 1 {
+		t.Errorf("imported initialiser declared %d times, want 1:\n%s", n, c)
+	}
+	if n := strings.Count(c, "= org_module_init_lib_u002Futil(arena);"); n != 2 {
+		t.Errorf("imported initialiser called %d times, want 2:\n%s", n, c)
+	}
+}
+
+// TestPrintCLinks compiles the C of a program of two modules and its
+// PrintMain with the runtime Write writes, links it and runs it, when a C
+// compiler and GMP are there.
+func TestPrintCLinks(t *testing.T) {
+	cc, err := FindCompiler("", nil)
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	sources := map[string]string{
+		"lib.org": "double : { right * 2 };\nanswer : 42;\n",
+		"main.org": "lib : \"lib.org\" @ org;\nsq : { left * right };\n" +
+			"fact : { (right <= 1) ? [true: 1 false: (right * this(right - 1))] };\n" +
+			"main : { [(21 -> lib.double) (\"b\" ? [a: 1 b: 2]) (3 sq 4) (5 -> (10 |> +)) ([1 2 3] -> { right * right }) lib.answer \"x${lib.answer}y\" 250ms (1s + 500ms) (fact 20)] -> @stdout; (1 -> { right; left }) -> @stdout; 3 };\n",
+	}
+	syms := NewSymbolTable()
+	args := []string{"-I", filepath.Join(dir, "runtime"), "-o", filepath.Join(dir, "main")}
+	for _, name := range []string{"lib.org", "main.org"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(sources[name]), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		p := parser.New(lexer.New([]byte(sources[name])))
+		m, diags := ir.Lower(p.ParseProgram(), path)
+		if len(diags) > 0 || len(p.Errors()) > 0 {
+			t.Fatalf("lower %s: %v %v", name, p.Errors(), diags)
+		}
+		var b strings.Builder
+		if err := PrintC(&b, m, "", syms); err != nil {
+			t.Fatal(err)
+		}
+		c := filepath.Join(dir, strings.TrimSuffix(name, ".org")+".c")
+		if err := os.WriteFile(c, []byte(b.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		args = append(args, c)
+	}
+	var main strings.Builder
+	if err := PrintMain(&main, filepath.Join(dir, "main.org")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "org_main.c"), []byte(main.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	linked, err := runtime.Write(filepath.Join(dir, "runtime"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	args = append(append(args, filepath.Join(dir, "org_main.c")), linked...)
+	args = append(args, "-lgmp")
+	build := exec.Command(cc.Command[0], append(cc.Command[1:], args...)...)
+	if out, err := build.CombinedOutput(); err != nil {
+		if strings.Contains(string(out), "gmp.h") {
+			t.Skip("no GMP to link with")
+		}
+		t.Fatalf("%s: %v\n%s", cc, err, out)
+	}
+	// Pages of 4 KiB, from the environment, make the arena chain many.
+	run := exec.Command(filepath.Join(dir, "main"))
+	run.Env = append(os.Environ(), "ORG_ARENA_SIZE=4K")
+	out, err := run.Output()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 3 {
+		t.Errorf("exit: %v, want status 3", err)
+	}
+	// A block called without the left operand it reads returns an Error.
+	if want := "42\n2\n12\n15\n[1 4 9]\n42\nx42y\n250ms\n3/2\n2432902008176640000\nError: block { right; left } expects a left operand\n"; string(out) != want {
+		t.Errorf("output %q, want %q", out, want)
+	}
+}
+
+func TestPrintLLVM(t *testing.T) {
+	src := "double : { right * 2 };\nbig : 10000000000000000000000 && double 3;\nsay : \"a \\\"quote\\\"\\n\""
+	p := parser.New(lexer.New([]byte(src)))
+	m, diags := ir.Lower(p.ParseProgram(), "m.org")
+	if len(diags) > 0 || len(p.Errors()) > 0 {
+		t.Fatalf("lower: %v %v", p.Errors(), diags)
+	}
+	var b strings.Builder
+	if err := PrintLLVM(&b, m, "m.ll", nil); err != nil {
+		t.Fatal(err)
+	}
+	ll := b.String()
+	fn := NewAuxNamer().Name("fn", "m", "{ (right * 2) }")
+	for _, want := range []string{
+		"source_filename = \"m.ll\"",
+		`c"a \22quote\22\0A\00"`,
+		"declare i64 @org_mul(ptr, i64, i64)\n",
+		"define internal i64 @org_module_top_m(ptr %arena, i64 %env) {\nb0:\n\t; m.org:1\n",
+		"define internal i64 @" + fn + "(ptr %arena, i64 %env, i64 %self, i64 %left.arg, i64 %right.arg) {\nb0:\n\t%right = call i64 @org_operand(ptr %arena, i64 %right.arg, ptr @.str.",
+		"%v0 = call i64 @org_make_closure(ptr %arena, ptr @" + fn + ", i64 %env, i32 1, ptr @.str.",
+		// Small integers are tagged constants: 2 is 2<<2|1.
+		"call i64 @org_mul(ptr %arena, i64 %right, i64 9)",
+		`call i64 @org_make_bigint_str(ptr %arena, ptr @.str.`,
+		// The join of && takes the value of each jump to it: false (2),
+		// the truth of the right operand, or the Error on the left.
+		"%v3 = phi i64 [ 2, %b3 ], [ %v7, %b4 ], [ %v2, %b5 ]\n",
+		"call i1 @org_ll_is_error(i64 %v2)",
+		"define linkonce_odr hidden i1 @org_ll_is_error(i64 %v) {",
+		"define i64 @org_module_init_m(ptr %arena) {",
+		"define i64 @org_v1_m_double(i64 %env) {",
+	} {
+		if !strings.Contains(ll, want) {
+			t.Errorf("LLVM IR lacks %q:\n%s", want, ll)
+		}
+	}
+	// The symbols are those of the C, so the backends link together.
+	for _, g := range Globals(m) {
+		if !strings.Contains(ll, "define i64 @"+g.C+"(") {
+			t.Errorf("%s not defined:\n%s", g.C, ll)
+		}
+	}
+
+	// llvm-as checks the module is well-formed IR, when it is installed.
+	as, err := exec.LookPath("llvm-as")
+	if err != nil {
+		t.Skip("no llvm-as to check the IR with")
+	}
+	version, _ := exec.Command(as, "--version").Output()
+	llvm, err := ParseLLC(as, string(version))
+	if err != nil {
+		t.Skip(err)
+	}
+	args := []string{"-o", os.DevNull}
+	if llvm.Version == 14 {
+		args = append(args, "-opaque-pointers")
+	}
+	cmd := exec.Command(as, args...)
+	cmd.Stdin = strings.NewReader(ll)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("llvm-as: %v\n%s\n%s", err, out, ll)
+	}
+}
+
+func TestLLC(t *testing.T) {
+	l, err := ParseLLC("/usr/bin/llc", "LLVM (http://llvm.org/):\n  Debian LLVM version 14.0.6\n  Optimized build.\n")
+	if err != nil || l.Version != 14 {
+		t.Fatalf("ParseLLC = %+v, %v", l, err)
+	}
+	if got, want := strings.Join(l.Args(2, "m.ll", "m.o"), " "), "-O2 -filetype=obj -relocation-model=pic -opaque-pointers -o m.o m.ll"; got != want {
+		t.Errorf("LLVM 14 args = %q, want %q", got, want)
+	}
+	l.Version = 17
+	if got, want := strings.Join(l.Args(5, "m.ll", "m.o"), " "), "-O3 -filetype=obj -relocation-model=pic -o m.o m.ll"; got != want {
+		t.Errorf("LLVM 17 args = %q, want %q", got, want)
+	}
+	if _, err := ParseLLC("llc", "LLVM version 12.0.1"); err == nil || !strings.Contains(err.Error(), "LLVM 14 or later") {
+		t.Errorf("LLVM 12: %v", err)
+	}
+	if _, err := ParseLLC("llc", "usage: llc"); err == nil {
+		t.Error("no version: no error")
+	}
+
+	if b, err := FindBackend("llvm"); err != nil || b.Ext != ".ll" {
+		t.Errorf("FindBackend(llvm) = %+v, %v", b, err)
+	}
+	if _, err := FindBackend("wasm"); err == nil || !strings.Contains(err.Error(), "have c, llvm") {
+		t.Errorf("FindBackend(wasm): %v", err)
+	}
+}
+
+func TestHeaderRejectsBadNames(t *testing.T) {
+	tests := []struct {
+		exports []Export
+		want    string
+	}{
+		{[]Export{{Binding: "f", C: "2fast"}}, "not a C identifier"},
+		{[]Export{{Binding: "f", C: "free"}}, "reserved"},
+		{[]Export{{Binding: "f", C: "org_f"}}, "reserved"},
+		{[]Export{{Binding: "f", C: "g"}, {Binding: "h", C: "g"}}, `already used by "f"`},
+		{[]Export{{Binding: "f", C: "lib_init"}}, "already used by the library entry point"},
+	}
+	for _, tt := range tests {
+		_, err := Header("lib", "lib.org", tt.exports)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%+v: got %v, want %q", tt.exports, err, tt.want)
+		}
+	}
+}
+
+func TestPythonModule(t *testing.T) {
+	src, err := PythonModule("mathlib", "mathlib.org", []Export{
+		{Binding: "sq", Kind: "prefix operator (bp 100)", Operator: true, Doc: "Squares \"right\"."},
+		{Binding: "pi", Kind: "value"},
+		{Binding: "+.", C: "ml_add", Kind: "infix operator (lbp 50, rbp 51)", Operator: true},
+		{Binding: "class", Kind: "value"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"#include \"mathlib.h\"\n#include \"orglang/python/pyconv.h\"\n",
+		"static PyObject *py_mathlib_sq(PyObject *self, PyObject *args, PyObject *kwargs) {\n",
+		"    result = org_to_py(mathlib_sq(l, r));\n",
+		"  return org_to_py(mathlib_pi());\n",
+		`{"sq", (PyCFunction)(void (*)(void))py_mathlib_sq, METH_VARARGS | METH_KEYWORDS,`,
+		`"sq(left=None, right=None)\n--\n\nsq: prefix operator (bp 100). Squares \"right\"."},`,
+		`{"pi", py_mathlib_pi, METH_NOARGS,`,
+		// Not Python identifiers: the C name is used.
+		`{"ml_add", (PyCFunction)`,
+		`{"mathlib_class", py_mathlib_class, METH_NOARGS,`,
+		"PyMODINIT_FUNC PyInit_mathlib(void) {\n  if (mathlib_init() != 0) {\n",
+		"  mathlib_shutdown();\n",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("module lacks %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestPythonModuleRejectsBadNames(t *testing.T) {
+	tests := []struct {
+		lib     string
+		exports []Export
+		want    string
+	}{
+		{"my-lib", []Export{{Binding: "f"}}, "not a Python module name"},
+		{"lib", []Export{{Binding: "f"}, {Binding: "+.", C: "f"}}, `already used by "f"`},
+		{"lib", []Export{{Binding: "Error"}}, "already used by the exception type"},
+	}
+	for _, tt := range tests {
+		_, err := PythonModule(tt.lib, "lib.org", tt.exports)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %+v: got %v, want %q", tt.lib, tt.exports, err, tt.want)
+		}
+	}
+}
+
+// parseFile parses the module at path for LoadModules.
+func parseFile(path string) (*ast.Program, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parser.New(lexer.New(src)).ParseProgram(), nil
+}
+
+func TestAssertions(t *testing.T) {
+	tests := []struct {
+		debug    bool
+		optimize int
+		want     bool
+	}{
+		{false, 0, true},
+		{false, 1, true},
+		{false, 2, false},
+		{false, 3, false},
+		{true, 1, true},
+		{true, 3, true},
+	}
+	for _, tt := range tests {
+		if got := Assertions(tt.debug, tt.optimize); got != tt.want {
+			t.Errorf("Assertions(%v, %d) = %v, want %v", tt.debug, tt.optimize, got, tt.want)
+		}
+	}
+	// The code of both backends checks an assert at -O1 and has no trace
+	// of it at -O2, as org build --emit=c|llvm -O<n> prints it.
+	src := "half : { assert right > 0 \"positive\"; right / 2 };\nhalf 4"
+	for _, optimize := range []int{1, 2} {
+		p := parser.New(lexer.New([]byte(src)))
+		m, diags := ir.Lower(p.ParseProgram(), "m.org", ir.WithAssertions(Assertions(false, optimize)))
+		if len(diags) > 0 || len(p.Errors()) > 0 {
+			t.Fatalf("lower: %v %v", p.Errors(), diags)
+		}
+		for _, backend := range Backends() {
+			var b strings.Builder
+			if err := backend.Print(&b, m, "m"+backend.Ext, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := strings.Contains(b.String(), "org_assert"), optimize < 2; got != want {
+				t.Errorf("-O%d %s: org_assert emitted = %v, want %v:\n%s", optimize, backend.Name, got, want, b.String())
+			}
+		}
+	}
+}
+
+func TestLoadModules(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("main.org", `a : "./lib/a.org" @ org; b : "lib/b.org" @ org;`)
+	write("lib/a.org", `b : "b.org" @ org; again : "../lib/./b.org" @ org;`)
+	write("lib/b.org", `x : 1;`)
+
+	parsed := map[string]int{}
+	mods, err := LoadModules(filepath.Join(dir, "main.org"), func(path string) (*ast.Program, error) {
+		parsed[path]++
+		return parseFile(path)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, m := range mods {
+		rel, _ := filepath.Rel(dir, m.Path)
+		order = append(order, filepath.ToSlash(rel))
+	}
+	if got := strings.Join(order, " "); got != "lib/b.org lib/a.org main.org" {
+		t.Errorf("order = %s, want dependencies first", got)
+	}
+	for path, n := range parsed {
+		if n != 1 {
+			t.Errorf("%s parsed %d times", path, n)
+		}
+	}
+	if a := mods[1]; len(a.Imports) != 2 || a.Imports[0].Path != a.Imports[1].Path {
+		t.Errorf("imports of lib/a.org = %+v, want one module twice", a.Imports)
+	}
+}
+
+func TestCanonicalPathDependency(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"org.toml":           "[dependencies]\nhttp = \"https://example.com/http.git\"\n",
+		"src/main.org":       `h : "http" @ org;`,
+		"deps/http/http.org": `get : 1;`,
+		"src/http/local.org": `x : 1;`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	from := filepath.Join(dir, "src", "main.org")
+	tests := []struct{ path, want string }{
+		{"http", "deps/http/http.org"},
+		// A file next to the importing module comes first.
+		{"http/local.org", "src/http/local.org"},
+	}
+	for _, tt := range tests {
+		got, err := CanonicalPath(from, tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(dir, filepath.FromSlash(tt.want)); got != want {
+			t.Errorf("CanonicalPath(%q) = %s, want %s", tt.path, got, want)
+		}
+	}
+}
+
+func TestLoadModulesCycle(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"main.org": `a : "a.org" @ org;`,
+		"a.org":    `b : "./b.org" @ org;`,
+		"b.org":    "x : 1;\na : \"a.org\" @ org;",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := LoadModules(filepath.Join(dir, "main.org"), parseFile)
+	var cycle *ImportCycle
+	if !errors.As(err, &cycle) {
+		t.Fatalf("err = %v, want an import cycle", err)
+	}
+	if want := "import cycle: a.org -> b.org -> a.org"; err.Error() != want {
+		t.Errorf("err = %q, want %q", err, want)
+	}
+	if filepath.Base(cycle.Module()) != "b.org" {
+		t.Errorf("cycle placed in %s, want b.org", cycle.Module())
+	}
+	d := cycle.Diagnostic()
+	if d.Code != diag.ImportCycle || d.Span.Start.Line != 2 || d.Span.Start.Column != 5 {
+		t.Errorf("Diagnostic = %+v, want ORG4002 at 2:5", d)
+	}
+}
+
+func TestArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	h, c, gone := write("lib.h", "int f(void);"), write("libmodule.c", "/* gen */"), write("old.c", "")
+	a := &Artifacts{Path: filepath.Join(dir, "cache", "artifacts.json")}
+	if err := a.Record(h, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Record(gone); err != nil {
+		t.Fatal(err)
+	}
+	write("libmodule.c", "/* edited */")
+	os.Remove(gone)
+
+	removed, kept, err := a.Remove()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0] != h || len(kept) != 1 || kept[0] != c {
+		t.Errorf("removed %v, kept %v", removed, kept)
+	}
+	if _, err := os.Stat(h); !os.IsNotExist(err) {
+		t.Errorf("%s is still there", h)
+	}
+	if _, err := os.Stat(c); err != nil {
+		t.Errorf("the edited %s was removed", c)
+	}
+	if removed, kept, err := a.Remove(); err != nil || removed != nil || kept != nil {
+		t.Errorf("second Remove: %v, %v, %v", removed, kept, err)
+	}
+}
+
+func TestFindCompiler(t *testing.T) {
+	path := func(installed ...string) func(string) (string, error) {
+		return func(name string) (string, error) {
+			for _, i := range installed {
+				if filepath.Base(i) == name || i == name {
+					return "/usr/bin/" + filepath.Base(name), nil
+				}
+			}
+			return "", errors.New("not found")
+		}
+	}
+	tests := []struct {
+		choice    string
+		installed []string
+		want      string // the compiler's name and command, or the start of the error
+	}{
+		{"", []string{"clang", "gcc"}, "gcc /usr/bin/gcc"},
+		{"", []string{"tcc", "zig"}, "zig /usr/bin/zig cc"},
+		{"", []string{"tcc"}, "tcc /usr/bin/tcc"},
+		{"clang", []string{"clang", "gcc"}, "clang /usr/bin/clang"},
+		{"zig", []string{"zig"}, "zig /usr/bin/zig cc"},
+		{"zig cc -target x86_64-windows", []string{"zig"}, "zig /usr/bin/zig cc -target x86_64-windows"},
+		{"gcc-13", []string{"gcc-13"}, "gcc /usr/bin/gcc-13"},
+		{"ccache gcc", []string{"ccache"}, "ccache /usr/bin/ccache gcc"},
+		{"", nil, "no C compiler found"},
+		{"clang", []string{"gcc"}, `C compiler "clang" not found: install it (apt install clang`},
+		{"zig", nil, `C compiler "zig" not found: install zig from https://ziglang.org`},
+	}
+	for _, tt := range tests {
+		c, err := FindCompiler(tt.choice, path(tt.installed...))
+		got := ""
+		if err != nil {
+			got = err.Error()
+		} else {
+			got = c.Name + " " + c.String()
+		}
+		if !strings.HasPrefix(got, tt.want) {
+			t.Errorf("FindCompiler(%q) with %v = %q, want %q", tt.choice, tt.installed, got, tt.want)
+		}
+	}
+}
+
+func TestModuleCache(t *testing.T) {
+	key := ModuleKey("/src/a.org", []byte("x : 1;"), "v1 -O1")
+	for _, other := range []string{
+		ModuleKey("/src/a.org", []byte("x : 2;"), "v1 -O1"),
+		ModuleKey("/src/b.org", []byte("x : 1;"), "v1 -O1"),
+		ModuleKey("/src/a.org", []byte("x : 1;"), "v1 -O2"),
+		ModuleKey("/src/a.or", []byte("gx : 1;"), "v1 -O1"),
+	} {
+		if other == key {
+			t.Errorf("different inputs share the key %s", key)
+		}
+	}
+	if again := ModuleKey("/src/a.org", []byte("x : 1;"), "v1 -O1"); again != key {
+		t.Errorf("key not stable: %s, then %s", key, again)
+	}
+
+	c := &ModuleCache{Dir: filepath.Join(t.TempDir(), "cache")}
+	if _, ok := c.Get(key, ".c"); ok {
+		t.Error("empty cache has an entry")
+	}
+	if err := c.Put(key, ".c", []byte("int x;")); err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := c.Get(key, ".c"); !ok || string(data) != "int x;" {
+		t.Errorf("Get = %q, %v", data, ok)
+	}
+	if _, ok := c.Get(key, ".o"); ok {
+		t.Error("Get(.o) found the .c entry")
+	}
+	if err := c.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(c.Dir); !os.IsNotExist(err) {
+		t.Errorf("Clear left %s", c.Dir)
+	}
+	if err := c.Clear(); err != nil {
+		t.Errorf("Clear of a missing cache: %v", err)
+	}
+}
+
+func TestModuleName(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "app", "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app", manifest.FileName), manifest.Scaffold("app"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"app/src/main.org", "solo.org"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte("x : 1;"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+
+	// The symbols do not depend on how the path is written.
+	for _, path := range []string{"app/src/main.org", "./app/src/../src/main.org", filepath.Join(dir, "app/src/main.org")} {
+		if got := ModuleName(path); got != "src/main" {
+			t.Errorf("ModuleName(%q) = %q, want src/main", path, got)
+		}
+	}
+	if got := ModuleName("solo.org"); got != "solo" {
+		t.Errorf("outside a project: %q, want solo", got)
+	}
+}
@@ -0,0 +1,130 @@
+package codegen
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Compiler is the C compiler driver a build runs: Command, then the
+// compiler's arguments.
+type Compiler struct {
+	Name    string   // gcc, clang, zig, tcc, cc, or the executable's name
+	Command []string // the executable, resolved in PATH, and its own arguments: zig cc
+}
+
+func (c *Compiler) String() string { return strings.Join(c.Command, " ") }
+
+// knownCompilers are the drivers tried, in order, when none is chosen.
+// zig ships clang as zig cc, and so cross-compiles out of the box.
+var knownCompilers = [][]string{{"gcc"}, {"clang"}, {"cc"}, {"zig", "cc"}, {"tcc"}}
+
+// FindCompiler returns the C compiler to build with. choice is the
+// command chosen by --cc or ORG_CC, its arguments split on spaces: a
+// known name (gcc, clang, tcc, zig, which means zig cc) or any other
+// command, such as a path or "ccache gcc". Without a choice the first
+// known driver found in PATH is used. lookPath is exec.LookPath, or a
+// stand-in for tests.
+//
+// The errors say what to install.
+func FindCompiler(choice string, lookPath func(string) (string, error)) (*Compiler, error) {
+	if lookPath == nil {
+		lookPath = exec.LookPath
+	}
+	if fields := strings.Fields(choice); len(fields) > 0 {
+		if fields[0] == "zig" && len(fields) == 1 {
+			fields = append(fields, "cc")
+		}
+		path, err := lookPath(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("C compiler %q not found: %s", choice, installHint(fields[0]))
+		}
+		return &Compiler{Name: compilerName(fields[0]), Command: append([]string{path}, fields[1:]...)}, nil
+	}
+	for _, c := range knownCompilers {
+		if path, err := lookPath(c[0]); err == nil {
+			return &Compiler{Name: c[0], Command: append([]string{path}, c[1:]...)}, nil
+		}
+	}
+	return nil, fmt.Errorf("no C compiler found: org build needs one of gcc, clang, zig cc or tcc in PATH.\n" +
+		"Install one, for example:\n" +
+		"  Debian, Ubuntu:  sudo apt install gcc\n" +
+		"  Fedora:          sudo dnf install gcc\n" +
+		"  macOS:           xcode-select --install  (clang)\n" +
+		"  Windows, other:  zig from https://ziglang.org, then --cc zig\n" +
+		"or choose a compiler with --cc or ORG_CC")
+}
+
+// compilerName names the driver at path: gcc-13 and x86_64-linux-gnu-gcc
+// are gcc.
+func compilerName(path string) string {
+	base := strings.TrimSuffix(filepath.Base(path), ".exe")
+	for _, c := range knownCompilers {
+		if base == c[0] || strings.HasPrefix(base, c[0]+"-") || strings.HasSuffix(base, "-"+c[0]) {
+			return c[0]
+		}
+	}
+	return base
+}
+
+// installHint tells how to get the compiler name.
+func installHint(name string) string {
+	switch compilerName(name) {
+	case "gcc", "clang", "tcc":
+		n := compilerName(name)
+		return fmt.Sprintf("install it (apt install %s, dnf install %s, brew install %s) or choose another with --cc or ORG_CC", n, n, n)
+	case "zig":
+		return "install zig from https://ziglang.org or choose another compiler with --cc or ORG_CC"
+	}
+	return "check the command, or choose gcc, clang, zig or tcc with --cc or ORG_CC"
+}
+
+// LLC is llc, the LLVM static compiler turning the IR of PrintLLVM into
+// object files.
+type LLC struct {
+	Path    string
+	Version int // the major version of LLVM
+}
+
+var llvmVersionRe = regexp.MustCompile(`LLVM version (\d+)`)
+
+// FindLLC returns llc as found in PATH, with the version it reports.
+// PrintLLVM writes opaque pointers, which need LLVM 14 or later.
+func FindLLC() (*LLC, error) {
+	path, err := exec.LookPath("llc")
+	if err != nil {
+		return nil, fmt.Errorf("llc not found: the llvm backend needs LLVM 14 or later (apt install llvm, dnf install llvm, brew install llvm)")
+	}
+	out, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s --version: %v", path, err)
+	}
+	return ParseLLC(path, string(out))
+}
+
+// ParseLLC returns the llc at path from the output of its --version.
+func ParseLLC(path, version string) (*LLC, error) {
+	m := llvmVersionRe.FindStringSubmatch(version)
+	if m == nil {
+		return nil, fmt.Errorf("%s: no LLVM version in its --version", path)
+	}
+	v, _ := strconv.Atoi(m[1])
+	if v < 14 {
+		return nil, fmt.Errorf("%s is LLVM %d: the llvm backend needs LLVM 14 or later", path, v)
+	}
+	return &LLC{Path: path, Version: v}, nil
+}
+
+// Args returns the arguments compiling the IR file input into the object
+// file output at -O optimize, 0 to 3.
+func (l *LLC) Args(optimize int, input, output string) []string {
+	args := []string{fmt.Sprintf("-O%d", min(max(optimize, 0), 3)), "-filetype=obj", "-relocation-model=pic"}
+	if l.Version == 14 {
+		// Opaque pointers are the default from LLVM 15 on.
+		args = append(args, "-opaque-pointers")
+	}
+	return append(args, "-o", output, input)
+}
@@ -0,0 +1,358 @@
+package codegen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"strings"
+
+	"orglang/pkg/ir"
+)
+
+// primitives are the operators the runtime implements as C functions of
+// their two operands; prefix - is org_neg.
+var primitives = map[string]string{
+	"+": "org_add", "-": "org_sub", "*": "org_mul", "/": "org_div", "%": "org_mod", "**": "org_pow",
+	"=": "org_eq", "<>": "org_ne", "~=": "org_ne", "<": "org_lt", "<=": "org_le", ">": "org_gt", ">=": "org_ge",
+	"?": "org_select", "|>": "org_partial", "o": "org_compose", "->": "org_op_arrow",
+}
+
+// Small integers are tagged in place; others are GMP integers.
+var smallMin, smallMax = big.NewInt(-1 << 61), big.NewInt(1<<61 - 1)
+
+// PrintC writes m as a C translation unit over the runtime (liborg.h):
+// a static function per block of the program, named by AuxNamer, a static
+// function evaluating the top level in env, and the module initialiser
+// org_module_init_<module>, the module's ABI: it runs the top level once,
+// in a new module scope, and returns that scope, the table the module's
+// importers get. An import ("path" @ org) calls the initialiser of the
+// module imported, which is declared but defined in its own translation
+// unit, so each module compiles on its own. Each top-level binding has an
+// accessor, named by GlobalSymbol, returning its value in the env given.
+// The module is named by ModuleName, so the symbols do not depend on how
+// m.Path was written. Every function is declared before the first is
+// defined, so blocks can refer to each other.
+//
+// Each IR value becomes a C variable vN, each basic block after the first
+// a label bN. The code of an instruction is preceded by a #line directive
+// naming its line in m.Path when that line changes, and a function is
+// followed by one returning to self, the name of the generated file; with
+// self "" the output is not mapped back.
+//
+// The external symbols are declared in syms, shared by the modules of a
+// program, or in a table of their own when syms is nil; a clash with a
+// symbol declared before fails PrintC, naming both bindings, before
+// anything is written.
+func PrintC(w io.Writer, m *ir.Module, self string, syms *SymbolTable) error {
+	if syms == nil {
+		syms = NewSymbolTable()
+	}
+	syms.DeclareModule(m)
+	if err := syms.Check(); err != nil {
+		return err
+	}
+	p := &cPrinter{w: bufio.NewWriter(w), m: m, self: self}
+	module := ModuleName(m.Path)
+	namer := NewAuxNamer()
+	p.names = make([]string, len(m.Funcs))
+	p.names[0] = LimitIdent("org_module_top_" + MangleIdentifier(module))
+	for i, f := range m.Funcs[1:] {
+		p.names[i+1] = namer.Name("fn", module, f.Source)
+	}
+	init := initSymbol(module)
+	globals := Globals(m)[1:]
+
+	p.printf("// Code generated by org build from %s. DO NOT EDIT.\n\n#include \"liborg.h\"\n\n", m.Path)
+	for i := range m.Funcs {
+		p.printf("%s;\n", p.signature(i))
+	}
+	p.printf("OrgValue %s(Arena *arena);\n", init)
+	for _, g := range globals {
+		p.printf("OrgValue %s(OrgValue env);\n", g.C)
+	}
+	for _, imp := range Imports(m) {
+		if imp != init {
+			p.printf("OrgValue %s(Arena *arena);\n", imp)
+		}
+	}
+	for i := range m.Funcs {
+		p.printf("\n")
+		p.function(i)
+	}
+	p.printf("\nOrgValue %s(Arena *arena) {\n", init)
+	p.printf("\tstatic OrgValue module = ORG_UNUSED;\n")
+	p.printf("\tif (ORG_IS_UNUSED(module)) {\n")
+	p.printf("\t\tmodule = org_module_scope(arena);\n")
+	p.printf("\t\t%s(arena, module);\n", p.names[0])
+	p.printf("\t}\n\treturn module;\n}\n")
+	if len(globals) > 0 {
+		p.printf("\n")
+	}
+	for _, g := range globals {
+		p.printf("OrgValue %s(OrgValue env) { return org_table_get_cstr(env, %s); }\n", g.C, cString(g.Binding))
+	}
+	return p.w.Flush()
+}
+
+// PrintMain writes the C entry point of a program whose entry module is
+// at entry, as given to org build: main runs the module with org_run, over
+// an arena of pages of $ORG_ARENA_SIZE bytes, or ARENA_DEFAULT_PAGE_SIZE
+// (arena_page_size_from_env), so a program is sized without rebuilding.
+func PrintMain(w io.Writer, entry string) error {
+	init := initSymbol(ModuleName(entry))
+	_, err := fmt.Fprintf(w, `// Code generated by org build from %s. DO NOT EDIT.
+
+#include "liborg.h"
+
+OrgValue %s(Arena *arena);
+
+int main(int argc, char **argv) {
+	Arena *arena = arena_new(arena_page_size_from_env(ARENA_DEFAULT_PAGE_SIZE));
+	return org_run(arena, %s, %s, argc, argv);
+}
+`, entry, init, init, cString(entry))
+	return err
+}
+
+// Imports returns the initialisers of the modules m imports, in the
+// order first imported.
+func Imports(m *ir.Module) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, f := range m.Funcs {
+		for _, in := range f.Insts {
+			if in.Op != ir.Import {
+				continue
+			}
+			if sym := importSymbol(m.Path, in.Text); !seen[sym] {
+				seen[sym] = true
+				out = append(out, sym)
+			}
+		}
+	}
+	return out
+}
+
+// importSymbol is the C name of the initialiser of the module that the
+// module from imports as path.
+func importSymbol(from, path string) string {
+	if file, err := CanonicalPath(from, path); err == nil {
+		path = file
+	}
+	return initSymbol(ModuleName(path))
+}
+
+// initSymbol is the C name of the initialiser of module.
+func initSymbol(module string) string {
+	return LimitIdent("org_module_init_" + MangleIdentifier(module))
+}
+
+// Globals returns the external C symbols PrintC emits for m: the module
+// initialiser, whose Binding is "", then the accessor of each top-level
+// binding in the order first bound, at the line of that binding.
+func Globals(m *ir.Module) []Symbol {
+	module := ModuleName(m.Path)
+	out := []Symbol{{C: initSymbol(module), Module: m.Path}}
+	seen := map[string]bool{}
+	for _, in := range m.Funcs[0].Insts {
+		if in.Op != ir.Bind || seen[in.Text] {
+			continue
+		}
+		seen[in.Text] = true
+		out = append(out, Symbol{C: GlobalSymbol(module, in.Text), Module: m.Path, Binding: in.Text, Line: in.Pos.Line})
+	}
+	return out
+}
+
+type cPrinter struct {
+	w     *bufio.Writer
+	m     *ir.Module
+	self  string
+	names []string
+	lines int // written so far
+}
+
+func (p *cPrinter) printf(format string, args ...any) {
+	s := fmt.Sprintf(format, args...)
+	p.lines += strings.Count(s, "\n")
+	p.w.WriteString(s)
+}
+
+func (p *cPrinter) signature(i int) string {
+	if i == 0 {
+		return fmt.Sprintf("static OrgValue %s(Arena *arena, OrgValue env)", p.names[0])
+	}
+	return fmt.Sprintf("static OrgValue %s(Arena *arena, OrgValue env, OrgValue self, OrgValue left, OrgValue right)", p.names[i])
+}
+
+func (p *cPrinter) function(i int) {
+	f := p.m.Funcs[i]
+	used := uses(f)
+	p.printf("%s {\n", p.signature(i))
+	// A block called without an operand it reads gets ORG_UNUSED for it,
+	// which org_operand makes an Error naming the block.
+	for _, side := range operands(f) {
+		p.printf("\t%s = org_operand(arena, %s, %s, %s);\n", side, side, cString(side), cString(f.Source))
+	}
+	// Params are assigned by the jumps before their block, so they are
+	// declared first.
+	for v, in := range f.Insts {
+		if in.Op == ir.Param {
+			p.printf("\tOrgValue v%d;\n", v)
+		}
+	}
+	line := 0
+	for b, blk := range f.Blocks {
+		if b > 0 {
+			// The empty statement lets a declaration follow the label.
+			p.printf("b%d:;\n", b)
+		}
+		for _, v := range blk.Insts {
+			in := f.Insts[v]
+			if in.Op == ir.Param {
+				continue
+			}
+			if in.Pos.Line > 0 && in.Pos.Line != line {
+				line = in.Pos.Line
+				p.printf("%s", LineDirective(line, p.m.Path))
+			}
+			if used[v] {
+				p.printf("\tOrgValue v%d = %s;\n", v, p.expr(in))
+			} else {
+				p.printf("\t%s;\n", p.expr(in))
+			}
+		}
+		p.term(f, blk.Term)
+	}
+	p.printf("}\n")
+	if p.self != "" && line > 0 {
+		// The directive about to be written is on line n+1; it names the next.
+		p.printf("%s", LineDirective(p.lines+2, p.self))
+	}
+}
+
+// operands returns the operands of the block f reads, left before right.
+func operands(f *ir.Func) []string {
+	var out []string
+	for _, side := range []string{"left", "right"} {
+		if slices.ContainsFunc(f.Insts, func(in ir.Inst) bool { return in.Op == ir.Operand && in.Text == side }) {
+			out = append(out, side)
+		}
+	}
+	return out
+}
+
+// uses returns the values of f read by an instruction or a terminator.
+func uses(f *ir.Func) map[ir.Value]bool {
+	used := map[ir.Value]bool{}
+	for _, in := range f.Insts {
+		for _, a := range in.Args {
+			used[a] = true
+		}
+	}
+	for _, blk := range f.Blocks {
+		used[blk.Term.Value] = true
+	}
+	return used
+}
+
+func (p *cPrinter) term(f *ir.Func, t ir.Term) {
+	switch t.Kind {
+	case ir.Return:
+		p.printf("\treturn v%d;\n", t.Value)
+	case ir.Jump:
+		if param := f.Blocks[t.Then].Insts; len(param) > 0 && f.Insts[param[0]].Op == ir.Param {
+			p.printf("\tv%d = v%d;\n", param[0], t.Value)
+		}
+		p.printf("\tgoto b%d;\n", t.Then)
+	case ir.IfTrue:
+		p.printf("\tif (org_truthy(v%d)) goto b%d;\n\tgoto b%d;\n", t.Value, t.Then, t.Else)
+	case ir.IfError:
+		p.printf("\tif (org_is_error(v%d)) goto b%d;\n\tgoto b%d;\n", t.Value, t.Then, t.Else)
+	case ir.IfBreak:
+		p.printf("\tif (org_is_break(v%d)) goto b%d;\n\tgoto b%d;\n", t.Value, t.Then, t.Else)
+	}
+}
+
+// expr returns the C expression computing in.
+func (p *cPrinter) expr(in ir.Inst) string {
+	arg := func(i int) string {
+		if i >= len(in.Args) || in.Args[i] == ir.NoValue {
+			return "ORG_UNUSED"
+		}
+		return in.Args[i].String()
+	}
+	switch in.Op {
+	case ir.Int:
+		n, ok := new(big.Int).SetString(in.Text, 10)
+		if ok && n.Cmp(smallMin) >= 0 && n.Cmp(smallMax) <= 0 {
+			return fmt.Sprintf("ORG_TAG_SMALL_INT(INT64_C(%s))", n)
+		}
+		return fmt.Sprintf("org_make_bigint_str(arena, %s)", cString(in.Text))
+	case ir.Decimal:
+		return fmt.Sprintf("org_make_decimal_str(arena, %s)", cString(in.Text))
+	case ir.Rational:
+		num, den, _ := strings.Cut(in.Text, "/")
+		return fmt.Sprintf("org_make_rational_str(arena, %s, %s)", cString(num), cString(den))
+	case ir.Quantity:
+		return fmt.Sprintf("org_make_quantity(arena, %s)", cString(in.Text))
+	case ir.String:
+		return fmt.Sprintf("org_make_string(arena, %s, %d)", cString(in.Text), len(in.Text))
+	case ir.Bool:
+		if in.Text == "true" {
+			return "ORG_TRUE"
+		}
+		return "ORG_FALSE"
+	case ir.Operand:
+		if in.Text == "this" {
+			return "self"
+		}
+		return in.Text
+	case ir.Load:
+		return fmt.Sprintf("org_scope_get(arena, env, %s)", cString(in.Text))
+	case ir.Bind:
+		return fmt.Sprintf("org_scope_bind(arena, env, %s, %s)", cString(in.Text), arg(0))
+	case ir.Assign:
+		return fmt.Sprintf("org_scope_assign(arena, env, %s, %s)", cString(in.Text), arg(0))
+	case ir.Call:
+		if in.Text == "-" && in.Args[0] == ir.NoValue {
+			return fmt.Sprintf("org_neg(arena, %s)", arg(1))
+		}
+		if fn, ok := primitives[in.Text]; ok && in.Args[0] != ir.NoValue {
+			return fmt.Sprintf("%s(arena, %s, %s)", fn, arg(0), arg(1))
+		}
+		return fmt.Sprintf("org_call(arena, org_scope_get(arena, env, %s), %s, %s)", cString(in.Text), arg(0), arg(1))
+	case ir.Apply:
+		return fmt.Sprintf("org_call(arena, %s, %s, %s)", arg(0), arg(1), arg(2))
+	case ir.Concat:
+		return fmt.Sprintf("org_concat(arena, %s, %s)", arg(0), arg(1))
+	case ir.Break:
+		return fmt.Sprintf("org_make_break(arena, %s)", arg(0))
+	case ir.Unbreak:
+		return fmt.Sprintf("org_break_value(%s)", arg(0))
+	case ir.Dot:
+		return fmt.Sprintf("org_index(arena, %s, %s)", arg(0), arg(1))
+	case ir.Table:
+		return "org_table_new(arena)"
+	case ir.Push:
+		return fmt.Sprintf("org_table_push(arena, %s, %s)", arg(0), arg(1))
+	case ir.Set:
+		return fmt.Sprintf("org_table_set(arena, %s, %s, %s)", arg(0), arg(1), arg(2))
+	case ir.Closure:
+		fn := p.m.Funcs[in.Func]
+		return fmt.Sprintf("org_make_closure(arena, %s, env, %d, %s)", p.names[in.Func], fn.Arity, cString(fn.Source))
+	case ir.Resource:
+		return fmt.Sprintf("org_resource_inst(arena, env, %s)", cString(in.Text))
+	case ir.Truth:
+		return fmt.Sprintf("org_truth(%s)", arg(0))
+	case ir.Assert:
+		return fmt.Sprintf("org_assert(arena, %s, %s, %s, %s, %d)", arg(0), arg(1), cString(in.Text), cString(p.m.Path), in.Pos.Line)
+	case ir.Error:
+		return fmt.Sprintf("org_make_error(arena, %s)", cString(in.Text))
+	case ir.Import:
+		return fmt.Sprintf("%s(arena)", importSymbol(p.m.Path, in.Text))
+	}
+	return fmt.Sprintf("org_make_error(arena, %s)", cString("cannot print "+in.Op.String()))
+}
@@ -0,0 +1,172 @@
+package codegen
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Export is a top-level binding exposed to C by a library build
+// (`org build --library`), selected with the @export docstring tag.
+type Export struct {
+	Binding  string // OrgLang name
+	C        string // C name given to @export, or "" for the default
+	Kind     string // how the parser classifies the binding (doc.Binding.Kind)
+	Operator bool   // called with operands rather than read as a value
+	Doc      string // docstring text; its first paragraph goes in the header
+	File     string // the OrgLang source of the binding, for #line directives
+	Line     int    // the line of the binding in File; 0 if unknown
+}
+
+var cIdentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ExportSymbol returns the C name of an export of library lib: the name
+// given to @export, or lib_<mangled binding>. Names that are not C
+// identifiers or that are reserved (Reserved) are rejected.
+func ExportSymbol(lib string, e Export) (string, error) {
+	name := e.C
+	if name == "" {
+		name = LimitIdent(MangleIdentifier(lib) + "_" + MangleIdentifier(e.Binding))
+	}
+	if !cIdentRe.MatchString(name) {
+		return "", fmt.Errorf("@export of %q: %q is not a C identifier", e.Binding, name)
+	}
+	if len(name) > MaxIdentLen {
+		return "", fmt.Errorf("@export of %q: %q is longer than %d characters", e.Binding, name, MaxIdentLen)
+	}
+	if Reserved(name) {
+		return "", fmt.Errorf("@export of %q: %q is reserved in C or by the runtime", e.Binding, name)
+	}
+	return name, nil
+}
+
+// Header returns the C header of library lib built from module, declaring
+// the library's entry points and one function per export:
+//
+//	int <lib>_init(void);          evaluates the module; 0 on success
+//	void <lib>_shutdown(void);     releases the runtime
+//	OrgValue <name>(OrgValue left, OrgValue right);   operators
+//	OrgValue <name>(void);                            values
+//
+// Operators are called with ORG_UNUSED for an operand they do not take.
+// The runtime's headers are included from orglang/ next to the header,
+// where org build --library writes them (runtime.WriteHeaders).
+// The declaration of an export with a source position is mapped to its
+// binding with #line directives (see LineDirective), so the compiler
+// reports a conflicting declaration at the binding. All exports are checked with ExportSymbol, and two exports sharing a C
+// name are an error.
+func Header(lib, module string, exports []Export) (string, error) {
+	base := MangleIdentifier(lib)
+	guard := "ORG_LIB_" + strings.ToUpper(base) + "_H"
+
+	type decl struct {
+		name, proto, doc string
+		export           Export
+	}
+	var decls []decl
+	seen := map[string]string{
+		base + "_init":     "the library entry point",
+		base + "_shutdown": "the library entry point",
+	}
+	for _, e := range exports {
+		name, err := ExportSymbol(lib, e)
+		if err != nil {
+			return "", err
+		}
+		if prev, ok := seen[name]; ok {
+			return "", fmt.Errorf("@export of %q: C name %s is already used by %s", e.Binding, name, prev)
+		}
+		seen[name] = fmt.Sprintf("%q", e.Binding)
+
+		proto := "OrgValue " + name + "(void);"
+		if e.Operator {
+			proto = "OrgValue " + name + "(OrgValue left, OrgValue right);"
+		}
+		decls = append(decls, decl{name, proto, exportComment(e), e})
+	}
+	sort.Slice(decls, func(i, j int) bool { return decls[i].name < decls[j].name })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "/* %s.h — generated by org build --library from %s. Do not edit. */\n", lib, module)
+	fmt.Fprintf(&b, "#ifndef %s\n#define %s\n\n", guard, guard)
+	b.WriteString("#include \"orglang/core/values.h\"\n\n")
+	b.WriteString("#ifdef __cplusplus\nextern \"C\" {\n#endif\n\n")
+	fmt.Fprintf(&b, "/* Starts the runtime and evaluates %s. Call once before any other\n", module)
+	b.WriteString(" * function of this header; returns 0 on success or an exit status. */\n")
+	fmt.Fprintf(&b, "int %s_init(void);\n\n", base)
+	b.WriteString("/* Releases the runtime; the values returned so far become invalid. */\n")
+	fmt.Fprintf(&b, "void %s_shutdown(void);\n", base)
+	for _, d := range decls {
+		fmt.Fprintf(&b, "\n/* %s */\n", d.doc)
+		writeMapped(&b, lib+".h", d.export, d.proto+"\n")
+	}
+	b.WriteString("\n#ifdef __cplusplus\n}\n#endif\n\n")
+	fmt.Fprintf(&b, "#endif /* %s */\n", guard)
+	return b.String(), nil
+}
+
+// LibrarySource returns the C source of library lib built from the module
+// at path, defining what Header declares over the code PrintC emits for
+// the module:
+//
+//	<lib>_init       runs the module's initialiser in the library's arena
+//	<lib>_shutdown   destroys the arena
+//	exports          an operator calls the binding, a value returns it
+//
+// Each export reads its binding from the module table through the
+// accessor of the binding (GlobalSymbol), and its definition is mapped to
+// the binding with #line directives, as in the header, returning to self,
+// the file the source is written to.
+func LibrarySource(lib, path, self string, exports []Export) (string, error) {
+	base := MangleIdentifier(lib)
+	module := ModuleName(path)
+	var b strings.Builder
+	fmt.Fprintf(&b, "/* %s — generated by org build --library from %s. Do not edit. */\n", filepath.Base(self), filepath.Base(path))
+	fmt.Fprintf(&b, "#include \"%s.h\"\n#include \"liborg.h\"\n\n", lib)
+	fmt.Fprintf(&b, "OrgValue %s(Arena *arena);\n", initSymbol(module))
+	seen := map[string]bool{}
+	for _, e := range exports {
+		if acc := GlobalSymbol(module, e.Binding); !seen[acc] {
+			seen[acc] = true
+			fmt.Fprintf(&b, "OrgValue %s(OrgValue env);\n", acc)
+		}
+	}
+	b.WriteString("\n/* The arena of the library's values, and the table of its module. */\n")
+	b.WriteString("static Arena *arena = NULL;\nstatic OrgValue module = ORG_UNUSED;\n\n")
+	fmt.Fprintf(&b, "int %s_init(void) {\n", base)
+	b.WriteString("  if (arena)\n    return 0;\n")
+	b.WriteString("  arena = arena_new(arena_page_size_from_env(ARENA_DEFAULT_PAGE_SIZE));\n")
+	b.WriteString("  if (!arena)\n    return ORG_EXIT_OOM;\n")
+	b.WriteString("  org_gmp_init();\n  org_gmp_set_arena(arena);\n")
+	fmt.Fprintf(&b, "  module = %s(arena);\n  return 0;\n}\n\n", initSymbol(module))
+	fmt.Fprintf(&b, "void %s_shutdown(void) {\n", base)
+	b.WriteString("  if (!arena)\n    return;\n")
+	b.WriteString("  arena_destroy(arena);\n  arena = NULL;\n  module = ORG_UNUSED;\n}\n")
+	for _, e := range exports {
+		name, err := ExportSymbol(lib, e)
+		if err != nil {
+			return "", err
+		}
+		acc := GlobalSymbol(module, e.Binding)
+		b.WriteString("\n")
+		if e.Operator {
+			writeMapped(&b, self, e, fmt.Sprintf("OrgValue %s(OrgValue left, OrgValue right) {\n  return org_call(arena, %s(module), left, right);\n}\n", name, acc))
+		} else {
+			writeMapped(&b, self, e, fmt.Sprintf("OrgValue %s(void) { return %s(module); }\n", name, acc))
+		}
+	}
+	return b.String(), nil
+}
+
+// exportComment describes an export in the header: its OrgLang name,
+// kind and the first paragraph of its docstring.
+func exportComment(e Export) string {
+	c := fmt.Sprintf("%s: %s.", e.Binding, e.Kind)
+	if doc, _, _ := strings.Cut(strings.TrimSpace(e.Doc), "\n\n"); doc != "" {
+		c += " " + strings.Join(strings.Fields(doc), " ")
+	}
+	// Keep the comment closed.
+	return strings.ReplaceAll(c, "*/", "* /")
+}
@@ -0,0 +1,31 @@
+package codegen
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// LineDirective returns the #line directive making the compiler, and the
+// debugger through the debug info, take the line after it for line of
+// file: the OrgLang source a stretch of C was generated from, or the
+// generated file itself after such a stretch.
+func LineDirective(line int, file string) string {
+	return fmt.Sprintf("#line %d %s\n", line, cString(filepath.ToSlash(file)))
+}
+
+// writeMapped writes code, generated for e, to b, the source of the
+// generated file self: between a #line directive naming the binding of e
+// and one returning to self, so the compiler reports errors in code at the
+// binding. Exports without a source position are written as is.
+func writeMapped(b *strings.Builder, self string, e Export, code string) {
+	if e.File == "" || e.Line == 0 {
+		b.WriteString(code)
+		return
+	}
+	b.WriteString(LineDirective(e.Line, e.File))
+	b.WriteString(code)
+	// The directive about to be written is on line n+1; it names the next.
+	n := strings.Count(b.String(), "\n")
+	b.WriteString(LineDirective(n+2, self))
+}
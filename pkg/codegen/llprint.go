@@ -0,0 +1,377 @@
+package codegen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"strings"
+
+	"orglang/pkg/ir"
+)
+
+// The tagged values of values.h, which the LLVM IR spells out as the
+// constants the C macros expand to.
+const (
+	llTrue   = 0x06
+	llFalse  = 0x02
+	llError  = 0x0A
+	llUnused = 0x0E
+	// ORG_TYPE_ERROR_OBJ, the type byte of the header of an Error object.
+	llErrorType = 7
+)
+
+// PrintLLVM writes m as an LLVM IR module over the runtime, the other
+// backend of PrintC: the same functions, named with the same symbols, so
+// a module printed by one links with modules printed by the other. An
+// OrgValue is an i64 and the arena a ptr. The macros of values.h are
+// expanded: small integers and Booleans are constants, and org_is_error,
+// inline in the runtime header, is defined in the module as a
+// linkonce_odr function. Where the C has a #line directive the IR has a
+// comment naming the line of m.Path. self names the file the module is
+// written to, its source_filename, or is "".
+//
+// The symbols are checked as PrintC checks them.
+func PrintLLVM(w io.Writer, m *ir.Module, self string, syms *SymbolTable) error {
+	if syms == nil {
+		syms = NewSymbolTable()
+	}
+	syms.DeclareModule(m)
+	if err := syms.Check(); err != nil {
+		return err
+	}
+	module := ModuleName(m.Path)
+	p := &llPrinter{m: m, strings: map[string]int{}, declared: map[string]string{}, init: initSymbol(module)}
+	namer := NewAuxNamer()
+	p.names = make([]string, len(m.Funcs))
+	p.names[0] = LimitIdent("org_module_top_" + MangleIdentifier(module))
+	for i, f := range m.Funcs[1:] {
+		p.names[i+1] = namer.Name("fn", module, f.Source)
+	}
+	for i := range m.Funcs {
+		p.body.WriteString("\n")
+		p.function(i)
+	}
+	p.initFunc()
+	for _, g := range Globals(m)[1:] {
+		p.printf("\ndefine i64 @%s(i64 %%env) {\n", g.C)
+		p.printf("\t%%v = %s\n\tret i64 %%v\n}\n", p.call("i64", "org_table_get_cstr", "i64 %env", p.str(g.Binding)))
+	}
+	if p.usesIsError {
+		p.isError()
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "; Code generated by org build from %s. DO NOT EDIT.\n\n", m.Path)
+	if self != "" {
+		fmt.Fprintf(bw, "source_filename = %s\n\n", llString(self))
+	}
+	for i, s := range p.consts {
+		fmt.Fprintf(bw, "@.str.%d = private unnamed_addr constant [%d x i8] c%s\n", i, len(s)+1, llString(s+"\x00"))
+	}
+	if len(p.consts) > 0 {
+		bw.WriteString("\n")
+	}
+	names := make([]string, 0, len(p.declared))
+	for name := range p.declared {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(bw, "%s\n", p.declared[name])
+	}
+	bw.WriteString(p.body.String())
+	return bw.Flush()
+}
+
+type llPrinter struct {
+	m        *ir.Module
+	names    []string
+	body     strings.Builder
+	consts   []string          // the string constants, @.str.N
+	strings  map[string]int    // index of each in consts
+	declared map[string]string // the declaration of each runtime function called
+	tmp      int               // temporaries of the current function
+	init     string            // the module initialiser
+
+	usesIsError bool
+}
+
+func (p *llPrinter) printf(format string, args ...any) {
+	fmt.Fprintf(&p.body, format, args...)
+}
+
+// str returns the operand pointing to the NUL-terminated constant s.
+func (p *llPrinter) str(s string) string {
+	i, ok := p.strings[s]
+	if !ok {
+		i = len(p.consts)
+		p.strings[s] = i
+		p.consts = append(p.consts, s)
+	}
+	return fmt.Sprintf("ptr @.str.%d", i)
+}
+
+// call returns a call of the external function fn returning ret with
+// args, each a type and a value, declaring fn. Functions of the module
+// are called directly, without a declaration.
+func (p *llPrinter) call(ret, fn string, args ...string) string {
+	if fn == "org_ll_is_error" {
+		p.usesIsError = true
+	} else if _, ok := p.declared[fn]; !ok && !slices.Contains(p.names, fn) && fn != p.init {
+		types := make([]string, len(args))
+		for i, a := range args {
+			types[i], _, _ = strings.Cut(a, " ")
+		}
+		p.declared[fn] = fmt.Sprintf("declare %s @%s(%s)", ret, fn, strings.Join(types, ", "))
+	}
+	return fmt.Sprintf("call %s @%s(%s)", ret, fn, strings.Join(args, ", "))
+}
+
+func (p *llPrinter) signature(i int) string {
+	if i == 0 {
+		return fmt.Sprintf("define internal i64 @%s(ptr %%arena, i64 %%env)", p.names[0])
+	}
+	return fmt.Sprintf("define internal i64 @%s(ptr %%arena, i64 %%env, i64 %%self, i64 %%left.arg, i64 %%right.arg)", p.names[i])
+}
+
+func (p *llPrinter) function(i int) {
+	f := p.m.Funcs[i]
+	p.tmp = 0
+	p.printf("%s {\n", p.signature(i))
+	// The values jumping to each join, by block: the incoming of its phi.
+	incoming := map[int][]string{}
+	for b, blk := range f.Blocks {
+		if t := blk.Term; t.Kind == ir.Jump {
+			incoming[t.Then] = append(incoming[t.Then], fmt.Sprintf("[ %s, %%b%d ]", p.value(f, t.Value), b))
+		}
+	}
+	line := 0
+	for b, blk := range f.Blocks {
+		// The entry block is named too, as the joins it jumps to name it.
+		p.printf("b%d:\n", b)
+		if b == 0 {
+			// %left and %right are the operands as org_operand guards
+			// them, defined only when the body reads them.
+			for _, side := range operands(f) {
+				p.printf("\t%%%s = %s\n", side, p.call("i64", "org_operand", "ptr %arena", "i64 %"+side+".arg", p.str(side), p.str(f.Source)))
+			}
+		}
+		for _, v := range blk.Insts {
+			in := f.Insts[v]
+			if in.Op == ir.Param {
+				p.printf("\t%%v%d = phi i64 %s\n", v, strings.Join(incoming[b], ", "))
+				continue
+			}
+			if constant(in) {
+				continue
+			}
+			if in.Pos.Line > 0 && in.Pos.Line != line {
+				line = in.Pos.Line
+				p.printf("\t; %s:%d\n", p.m.Path, line)
+			}
+			p.printf("\t%%v%d = %s\n", v, p.inst(f, in))
+		}
+		p.term(f, blk.Term)
+	}
+	p.printf("}\n")
+}
+
+// initFunc prints the module initialiser, running the top level once.
+func (p *llPrinter) initFunc() {
+	name := p.init
+	p.printf("\n@module = internal global i64 %d\n", llUnused)
+	p.printf("\ndefine i64 @%s(ptr %%arena) {\n", name)
+	p.printf("entry:\n")
+	p.printf("\t%%cached = load i64, ptr @module\n")
+	p.printf("\t%%unused = icmp eq i64 %%cached, %d\n", llUnused)
+	p.printf("\tbr i1 %%unused, label %%run, label %%done\n")
+	p.printf("run:\n")
+	p.printf("\t%%scope = %s\n", p.call("i64", "org_module_scope", "ptr %arena"))
+	p.printf("\tstore i64 %%scope, ptr @module\n")
+	p.printf("\t%%top = call i64 @%s(ptr %%arena, i64 %%scope)\n", p.names[0])
+	p.printf("\tbr label %%done\n")
+	p.printf("done:\n")
+	p.printf("\t%%module = phi i64 [ %%cached, %%entry ], [ %%scope, %%run ]\n")
+	p.printf("\tret i64 %%module\n}\n")
+}
+
+// isError prints org_ll_is_error, org_is_error of values.h: true for the
+// ORG_ERROR singleton and for a pointer to an Error object.
+func (p *llPrinter) isError() {
+	p.printf("\ndefine linkonce_odr hidden i1 @org_ll_is_error(i64 %%v) {\n")
+	p.printf("\t%%singleton = icmp eq i64 %%v, %d\n", llError)
+	p.printf("\tbr i1 %%singleton, label %%yes, label %%tag\n")
+	p.printf("tag:\n")
+	p.printf("\t%%low = and i64 %%v, 3\n")
+	p.printf("\t%%pointer = icmp eq i64 %%low, 0\n")
+	p.printf("\tbr i1 %%pointer, label %%object, label %%no\n")
+	p.printf("object:\n")
+	p.printf("\t%%header = inttoptr i64 %%v to ptr\n")
+	p.printf("\t%%type = load i8, ptr %%header\n")
+	p.printf("\t%%error = icmp eq i8 %%type, %d\n", llErrorType)
+	p.printf("\tret i1 %%error\n")
+	p.printf("yes:\n\tret i1 true\n")
+	p.printf("no:\n\tret i1 false\n}\n")
+}
+
+// constant tells whether in is an operand of the instructions using it
+// rather than an instruction: a small integer, a Boolean or an operand
+// of the block.
+func constant(in ir.Inst) bool {
+	switch in.Op {
+	case ir.Bool, ir.Operand:
+		return true
+	case ir.Int:
+		_, ok := smallInt(in.Text)
+		return ok
+	}
+	return false
+}
+
+// smallInt returns the tagged small integer of the literal text, if it
+// fits one.
+func smallInt(text string) (int64, bool) {
+	n, ok := new(big.Int).SetString(text, 10)
+	if !ok || n.Cmp(smallMin) < 0 || n.Cmp(smallMax) > 0 {
+		return 0, false
+	}
+	return n.Int64()<<2 | 1, true
+}
+
+// value returns the operand of the value v of f.
+func (p *llPrinter) value(f *ir.Func, v ir.Value) string {
+	if v == ir.NoValue {
+		return fmt.Sprint(llUnused)
+	}
+	in := f.Insts[v]
+	switch in.Op {
+	case ir.Bool:
+		if in.Text == "true" {
+			return fmt.Sprint(llTrue)
+		}
+		return fmt.Sprint(llFalse)
+	case ir.Operand:
+		if in.Text == "this" {
+			return "%self"
+		}
+		return "%" + in.Text
+	case ir.Int:
+		if n, ok := smallInt(in.Text); ok {
+			return fmt.Sprint(n)
+		}
+	}
+	return "%" + v.String()
+}
+
+func (p *llPrinter) term(f *ir.Func, t ir.Term) {
+	switch t.Kind {
+	case ir.Return:
+		p.printf("\tret i64 %s\n", p.value(f, t.Value))
+	case ir.Jump:
+		p.printf("\tbr label %%b%d\n", t.Then)
+	case ir.IfTrue:
+		p.tmp++
+		p.printf("\t%%t%d = %s\n", p.tmp, p.call("i32", "org_truthy", "i64 "+p.value(f, t.Value)))
+		p.printf("\t%%t%d.c = icmp ne i32 %%t%d, 0\n", p.tmp, p.tmp)
+		p.printf("\tbr i1 %%t%d.c, label %%b%d, label %%b%d\n", p.tmp, t.Then, t.Else)
+	case ir.IfError:
+		p.tmp++
+		p.printf("\t%%t%d = %s\n", p.tmp, p.call("i1", "org_ll_is_error", "i64 "+p.value(f, t.Value)))
+		p.printf("\tbr i1 %%t%d, label %%b%d, label %%b%d\n", p.tmp, t.Then, t.Else)
+	case ir.IfBreak:
+		p.tmp++
+		p.printf("\t%%t%d = %s\n", p.tmp, p.call("i32", "org_is_break", "i64 "+p.value(f, t.Value)))
+		p.printf("\t%%t%d.c = icmp ne i32 %%t%d, 0\n", p.tmp, p.tmp)
+		p.printf("\tbr i1 %%t%d.c, label %%b%d, label %%b%d\n", p.tmp, t.Then, t.Else)
+	}
+}
+
+// inst returns the LLVM instruction computing in, as PrintC's expr.
+func (p *llPrinter) inst(f *ir.Func, in ir.Inst) string {
+	arg := func(i int) string {
+		if i >= len(in.Args) {
+			return fmt.Sprintf("i64 %d", llUnused)
+		}
+		return "i64 " + p.value(f, in.Args[i])
+	}
+	const arena = "ptr %arena"
+	switch in.Op {
+	case ir.Int:
+		return p.call("i64", "org_make_bigint_str", arena, p.str(in.Text))
+	case ir.Decimal:
+		return p.call("i64", "org_make_decimal_str", arena, p.str(in.Text))
+	case ir.Rational:
+		num, den, _ := strings.Cut(in.Text, "/")
+		return p.call("i64", "org_make_rational_str", arena, p.str(num), p.str(den))
+	case ir.Quantity:
+		return p.call("i64", "org_make_quantity", arena, p.str(in.Text))
+	case ir.String:
+		return p.call("i64", "org_make_string", arena, p.str(in.Text), fmt.Sprintf("i64 %d", len(in.Text)))
+	case ir.Load:
+		return p.call("i64", "org_scope_get", arena, "i64 %env", p.str(in.Text))
+	case ir.Bind:
+		return p.call("i64", "org_scope_bind", arena, "i64 %env", p.str(in.Text), arg(0))
+	case ir.Assign:
+		return p.call("i64", "org_scope_assign", arena, "i64 %env", p.str(in.Text), arg(0))
+	case ir.Call:
+		if in.Text == "-" && in.Args[0] == ir.NoValue {
+			return p.call("i64", "org_neg", arena, arg(1))
+		}
+		if fn, ok := primitives[in.Text]; ok && in.Args[0] != ir.NoValue {
+			return p.call("i64", fn, arena, arg(0), arg(1))
+		}
+		p.tmp++
+		p.printf("\t%%t%d = %s\n", p.tmp, p.call("i64", "org_scope_get", arena, "i64 %env", p.str(in.Text)))
+		return p.call("i64", "org_call", arena, fmt.Sprintf("i64 %%t%d", p.tmp), arg(0), arg(1))
+	case ir.Apply:
+		return p.call("i64", "org_call", arena, arg(0), arg(1), arg(2))
+	case ir.Concat:
+		return p.call("i64", "org_concat", arena, arg(0), arg(1))
+	case ir.Break:
+		return p.call("i64", "org_make_break", arena, arg(0))
+	case ir.Unbreak:
+		return p.call("i64", "org_break_value", arg(0))
+	case ir.Dot:
+		return p.call("i64", "org_index", arena, arg(0), arg(1))
+	case ir.Table:
+		return p.call("i64", "org_table_new", arena)
+	case ir.Push:
+		return p.call("i64", "org_table_push", arena, arg(0), arg(1))
+	case ir.Set:
+		return p.call("i64", "org_table_set", arena, arg(0), arg(1), arg(2))
+	case ir.Closure:
+		fn := p.m.Funcs[in.Func]
+		return p.call("i64", "org_make_closure", arena, "ptr @"+p.names[in.Func], "i64 %env", fmt.Sprintf("i32 %d", fn.Arity), p.str(fn.Source))
+	case ir.Resource:
+		return p.call("i64", "org_resource_inst", arena, "i64 %env", p.str(in.Text))
+	case ir.Truth:
+		return p.call("i64", "org_truth", arg(0))
+	case ir.Assert:
+		return p.call("i64", "org_assert", arena, arg(0), arg(1), p.str(in.Text), p.str(p.m.Path), fmt.Sprintf("i32 %d", in.Pos.Line))
+	case ir.Error:
+		return p.call("i64", "org_make_error", arena, p.str(in.Text))
+	case ir.Import:
+		return p.call("i64", importSymbol(p.m.Path, in.Text), arena)
+	}
+	return p.call("i64", "org_make_error", arena, p.str("cannot print "+in.Op.String()))
+}
+
+// llString returns s as the body of an LLVM string constant, quoted,
+// with quotes, backslashes and bytes that are not printable ASCII as
+// \XX escapes.
+func llString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c > 0x7e || c == '"' || c == '\\' {
+			fmt.Fprintf(&b, "\\%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
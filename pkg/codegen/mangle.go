@@ -0,0 +1,78 @@
+// Package codegen holds the pieces of the C back end that do not depend
+// on the emitter itself: how OrgLang names become C symbols and how the
+// emitted symbols are checked before the C compiler sees them.
+package codegen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxIdentLen is the longest C symbol the code generator emits. C99
+// only guarantees 63 significant initial characters for identifiers.
+const MaxIdentLen = 63
+
+// hashSuffixLen is the number of hex digits kept from the SHA-256 of an
+// over-long symbol.
+const hashSuffixLen = 12
+
+// exemptGlobals are runtime-provided names emitted without a module
+// prefix, so every module refers to the same C symbol.
+var exemptGlobals = map[string]bool{
+	"stdin":  true,
+	"stdout": true,
+	"stderr": true,
+	"args":   true,
+	"org":    true,
+}
+
+// MangleIdentifier turns an OrgLang identifier into a C identifier
+// fragment. The mapping is injective: ASCII letters and digits pass
+// through, '_' is doubled, and any other rune is written as _uXXXX
+// (or _UXXXXXXXX outside the Basic Multilingual Plane), so every '_' in
+// the result is followed by '_', 'u' or 'U'.
+func MangleIdentifier(name string) string {
+	var out strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			out.WriteRune(r)
+		case r == '_':
+			out.WriteString("__")
+		case r <= 0xFFFF:
+			fmt.Fprintf(&out, "_u%04X", r)
+		default:
+			fmt.Fprintf(&out, "_U%08X", r)
+		}
+	}
+	return out.String()
+}
+
+// GlobalSymbol returns the C symbol of a top-level binding of a module.
+//
+// The module fragment is length-prefixed (org_v<len>_<module>_<name>), so
+// no two (module, name) pairs share a symbol, e.g. a + b_c and a_b + c.
+// Symbols that would exceed MaxIdentLen are shortened by LimitIdent.
+func GlobalSymbol(module, name string) string {
+	if exemptGlobals[name] {
+		return LimitIdent("org_var_" + MangleIdentifier(name))
+	}
+	m := MangleIdentifier(module)
+	return LimitIdent("org_v" + strconv.Itoa(len(m)) + "_" + m + "_" + MangleIdentifier(name))
+}
+
+// LimitIdent keeps sym within MaxIdentLen. Symbols of MaxIdentLen
+// characters or more are truncated and end in _h plus the first hex
+// digits of the SHA-256 of the full symbol; the result is exactly
+// MaxIdentLen long, so it cannot equal a symbol that was kept as is.
+func LimitIdent(sym string) string {
+	if len(sym) < MaxIdentLen {
+		return sym
+	}
+	sum := sha256.Sum256([]byte(sym))
+	suffix := "_h" + hex.EncodeToString(sum[:])[:hashSuffixLen]
+	return sym[:MaxIdentLen-len(suffix)] + suffix
+}
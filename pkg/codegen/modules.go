@@ -0,0 +1,201 @@
+package codegen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/diag"
+	"orglang/pkg/manifest"
+)
+
+// Module is a source file of a program, known by its canonical path so
+// that `./a.org`, `a.org` and a symlink to it are one module, compiled
+// once.
+type Module struct {
+	Path    string // canonical path (see CanonicalPath)
+	Program *ast.Program
+	Imports []Import
+}
+
+// Import is a top-level `alias : "path" @ org` of a module.
+type Import struct {
+	Path string   // canonical path of the imported module
+	Span ast.Span // of the path string in the importing module
+}
+
+// CanonicalPath returns the path of the module that the file from
+// imports as path: relative to the directory of from, or else to the
+// working directory, as org check resolves imports, or else in the
+// dependency of the project it names (manifest.ResolveImport); then
+// absolute, cleaned and with symlinks resolved.
+func CanonicalPath(from, path string) (string, error) {
+	candidates := []string{path}
+	if !filepath.IsAbs(path) {
+		candidates = []string{filepath.Join(filepath.Dir(from), path), path}
+	}
+	resolved := ""
+	for _, c := range candidates {
+		if info, err := os.Stat(c); err == nil && !info.IsDir() {
+			resolved = c
+			break
+		}
+	}
+	if resolved == "" {
+		resolved = candidates[0]
+		if dep, ok := manifest.ResolveImport(from, path); from != "" && ok {
+			resolved = dep
+		}
+	}
+	abs, err := filepath.Abs(resolved)
+	if err != nil {
+		return "", err
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real, nil
+	}
+	return abs, nil
+}
+
+// ModuleName returns the name of the module in file that the C symbols
+// emitted for it derive from: its canonical path (CanonicalPath)
+// relative to the root of the project holding it, slash-separated and
+// without the .org extension, or outside a project its base name without
+// it. The module gets the same symbols however its path was written on
+// the command line, and on any machine.
+func ModuleName(file string) string {
+	path, err := CanonicalPath("", file)
+	if err != nil {
+		path = file
+	}
+	name := filepath.Base(path)
+	if m, err := manifest.Find(filepath.Dir(path)); err == nil && m != nil {
+		if rel, err := filepath.Rel(m.Dir(), path); err == nil && filepath.IsLocal(rel) {
+			name = rel
+		}
+	}
+	return strings.TrimSuffix(filepath.ToSlash(name), ".org")
+}
+
+// LoadModules parses the module entry and every module it imports,
+// directly or not, each once; parse reads and parses the module at a
+// canonical path. The modules are returned in dependency order, each
+// after the modules it imports and entry last, the order their
+// initialisers must run in. Modules importing each other are an
+// *ImportCycle error.
+func LoadModules(entry string, parse func(path string) (*ast.Program, error)) ([]*Module, error) {
+	root, err := CanonicalPath("", entry)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		order []*Module
+		done  = map[string]bool{}
+		stack []*Module // the modules being loaded, importers first
+	)
+	var load func(path string) error
+	load = func(path string) error {
+		for i, m := range stack {
+			if m.Path == path {
+				chain := []string{}
+				for _, m := range stack[i:] {
+					chain = append(chain, m.Path)
+				}
+				last := stack[len(stack)-1]
+				return &ImportCycle{Chain: append(chain, path), Span: last.Imports[len(last.Imports)-1].Span}
+			}
+		}
+		if done[path] {
+			return nil
+		}
+		prog, err := parse(path)
+		if err != nil {
+			return err
+		}
+		m := &Module{Path: path, Program: prog}
+		stack = append(stack, m)
+		for _, stmt := range prog.Statements {
+			lit, ok := importLiteral(stmt)
+			if !ok {
+				continue
+			}
+			target, err := CanonicalPath(path, lit.Value)
+			if err != nil {
+				return err
+			}
+			m.Imports = append(m.Imports, Import{Path: target, Span: lit.Span})
+			if err := load(target); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		done[path] = true
+		order = append(order, m)
+		return nil
+	}
+	if err := load(root); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// importLiteral returns the path string of a top-level
+// `alias : "path" @ org`.
+func importLiteral(stmt ast.Statement) (*ast.StringLiteral, bool) {
+	b, ok := stmt.(*ast.BindingExpr)
+	if !ok {
+		return nil, false
+	}
+	ie, ok := b.Value.(*ast.InfixExpr)
+	if !ok || ie.Op != "@" {
+		return nil, false
+	}
+	path, isStr := ie.Left.(*ast.StringLiteral)
+	org, isName := ie.Right.(*ast.Name)
+	if !isStr || !isName || org.Value != "org" {
+		return nil, false
+	}
+	return path, true
+}
+
+// ImportCycle is modules importing each other, which no initialisation
+// order satisfies.
+type ImportCycle struct {
+	// Chain is the canonical paths of the cycle, each importing the next;
+	// the first is repeated at the end.
+	Chain []string
+	// Span is the import closing the cycle, in the module
+	// Chain[len(Chain)-2].
+	Span ast.Span
+}
+
+// Error names the modules of the cycle relative to the directory of the
+// first.
+func (e *ImportCycle) Error() string {
+	dir := filepath.Dir(e.Chain[0])
+	names := make([]string, len(e.Chain))
+	for i, p := range e.Chain {
+		names[i] = p
+		if rel, err := filepath.Rel(dir, p); err == nil {
+			names[i] = rel
+		}
+	}
+	return fmt.Sprintf("import cycle: %s", strings.Join(names, " -> "))
+}
+
+// Module returns the path of the module holding the import closing the
+// cycle, the file Diagnostic is placed in.
+func (e *ImportCycle) Module() string { return e.Chain[len(e.Chain)-2] }
+
+// Diagnostic returns the cycle as an error at the import closing it.
+func (e *ImportCycle) Diagnostic() diag.Diagnostic {
+	return diag.Diagnostic{
+		Code:     diag.ImportCycle,
+		Severity: diag.Error,
+		Span:     e.Span,
+		Message:  e.Error(),
+		Hint:     "move what the modules share into a module both import",
+	}
+}
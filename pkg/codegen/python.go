@@ -0,0 +1,163 @@
+package codegen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// pythonKeywords cannot be used as attribute names of a Python module.
+var pythonKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true,
+	"assert": true, "async": true, "await": true, "break": true, "class": true,
+	"continue": true, "def": true, "del": true, "elif": true, "else": true,
+	"except": true, "finally": true, "for": true, "from": true, "global": true,
+	"if": true, "import": true, "in": true, "is": true, "lambda": true,
+	"nonlocal": true, "not": true, "or": true, "pass": true, "raise": true,
+	"return": true, "try": true, "while": true, "with": true, "yield": true,
+}
+
+// PythonName returns the name of an export in the Python module: its
+// OrgLang name when that is a Python identifier, otherwise its C name.
+func PythonName(lib string, e Export) (string, error) {
+	if cIdentRe.MatchString(e.Binding) && !pythonKeywords[e.Binding] {
+		return e.Binding, nil
+	}
+	return ExportSymbol(lib, e)
+}
+
+// PythonModule returns the C source of a CPython extension module named
+// lib wrapping the library of Header: importing it calls <lib>_init, and
+// each export becomes a module function converting its arguments and
+// result with the runtime's python/pyconv.h.
+//
+//	operators   f(left=None, right=None)   None is an absent operand
+//	values      f()
+//
+// OrgLang Errors are raised as <lib>.Error. Each call's arguments live in
+// an arena checkpoint released once the result is converted. As in the
+// header, the call of each export is mapped to its binding with #line
+// directives.
+func PythonModule(lib, module string, exports []Export) (string, error) {
+	base := MangleIdentifier(lib)
+	if base != lib || !cIdentRe.MatchString(lib) {
+		return "", fmt.Errorf("library name %q is not a Python module name", lib)
+	}
+
+	type fn struct {
+		py, c, doc string
+		operator   bool
+		export     Export
+	}
+	var fns []fn
+	seen := map[string]string{"Error": "the exception type"}
+	for _, e := range exports {
+		c, err := ExportSymbol(lib, e)
+		if err != nil {
+			return "", err
+		}
+		py, err := PythonName(lib, e)
+		if err != nil {
+			return "", err
+		}
+		if prev, ok := seen[py]; ok {
+			return "", fmt.Errorf("@export of %q: Python name %s is already used by %s", e.Binding, py, prev)
+		}
+		seen[py] = fmt.Sprintf("%q", e.Binding)
+		// The signature line gives help() the parameter names.
+		sig := py + "()"
+		if e.Operator {
+			sig = py + "(left=None, right=None)"
+		}
+		fns = append(fns, fn{py, c, sig + "\n--\n\n" + exportComment(e), e.Operator, e})
+	}
+	sort.Slice(fns, func(i, j int) bool { return fns[i].py < fns[j].py })
+
+	self := lib + "module.c"
+	var b strings.Builder
+	fmt.Fprintf(&b, "/* %smodule.c — generated by org build --library --python from %s.\n", lib, module)
+	b.WriteString(" * Do not edit. Compile with -DORG_WITH_PYTHON and the CPython includes. */\n")
+	b.WriteString("#define PY_SSIZE_T_CLEAN\n#include <Python.h>\n\n")
+	fmt.Fprintf(&b, "#include \"%s.h\"\n", lib)
+	b.WriteString("#include \"orglang/python/pyconv.h\"\n\n")
+	b.WriteString("/* Arguments converted from Python, released after each call. */\n")
+	b.WriteString("static Arena *py_arena = NULL;\n")
+
+	for _, f := range fns {
+		b.WriteString("\n")
+		if f.operator {
+			fmt.Fprintf(&b, "static PyObject *py_%s(PyObject *self, PyObject *args, PyObject *kwargs) {\n", f.c)
+			b.WriteString("  static char *kwlist[] = {\"left\", \"right\", NULL};\n")
+			b.WriteString("  PyObject *left = Py_None, *right = Py_None;\n")
+			b.WriteString("  (void)self;\n")
+			fmt.Fprintf(&b, "  if (!PyArg_ParseTupleAndKeywords(args, kwargs, \"|OO:%s\", kwlist, &left, &right))\n", f.py)
+			b.WriteString("    return NULL;\n")
+			b.WriteString("  ArenaCheckpoint cp = arena_save(py_arena);\n")
+			b.WriteString("  OrgValue l, r;\n")
+			b.WriteString("  PyObject *result = NULL;\n")
+			b.WriteString("  if (org_from_py(py_arena, left, &l) == 0 && org_from_py(py_arena, right, &r) == 0)\n")
+			writeMapped(&b, self, f.export, fmt.Sprintf("    result = org_to_py(%s(l, r));\n", f.c))
+			b.WriteString("  arena_restore(py_arena, cp);\n")
+			b.WriteString("  return result;\n")
+		} else {
+			fmt.Fprintf(&b, "static PyObject *py_%s(PyObject *self, PyObject *unused) {\n", f.c)
+			b.WriteString("  (void)self;\n  (void)unused;\n")
+			writeMapped(&b, self, f.export, fmt.Sprintf("  return org_to_py(%s());\n", f.c))
+		}
+		b.WriteString("}\n")
+	}
+
+	b.WriteString("\nstatic PyMethodDef methods[] = {\n")
+	for _, f := range fns {
+		if f.operator {
+			fmt.Fprintf(&b, "    {%s, (PyCFunction)(void (*)(void))py_%s, METH_VARARGS | METH_KEYWORDS,\n     %s},\n",
+				cString(f.py), f.c, cString(f.doc))
+		} else {
+			fmt.Fprintf(&b, "    {%s, py_%s, METH_NOARGS,\n     %s},\n", cString(f.py), f.c, cString(f.doc))
+		}
+	}
+	b.WriteString("    {NULL, NULL, 0, NULL},\n};\n\n")
+
+	fmt.Fprintf(&b, "static void release(void *module) {\n  (void)module;\n  %s_shutdown();\n", base)
+	b.WriteString("  if (py_arena) {\n    arena_destroy(py_arena);\n    py_arena = NULL;\n  }\n}\n\n")
+
+	b.WriteString("static struct PyModuleDef module_def = {\n")
+	fmt.Fprintf(&b, "    PyModuleDef_HEAD_INIT, %s,\n", cString(lib))
+	fmt.Fprintf(&b, "    %s,\n", cString("OrgLang library built from "+module+"."))
+	b.WriteString("    -1, methods, NULL, NULL, NULL, release,\n};\n\n")
+
+	fmt.Fprintf(&b, "PyMODINIT_FUNC PyInit_%s(void) {\n", base)
+	fmt.Fprintf(&b, "  if (%s_init() != 0) {\n", base)
+	fmt.Fprintf(&b, "    PyErr_SetString(PyExc_ImportError, %s);\n", cString(lib+": evaluating "+module+" failed"))
+	b.WriteString("    return NULL;\n  }\n")
+	b.WriteString("  py_arena = arena_new(ARENA_DEFAULT_PAGE_SIZE);\n")
+	b.WriteString("  PyObject *m = py_arena ? PyModule_Create(&module_def) : PyErr_NoMemory();\n")
+	b.WriteString("  if (m && org_py_init(m) < 0) {\n    Py_DECREF(m);\n    return NULL;\n  }\n")
+	b.WriteString("  return m;\n}\n")
+	return b.String(), nil
+}
+
+// cString quotes s as a C string literal. UTF-8 is kept as is.
+func cString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&b, `\%03o`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package codegen
+
+import "strings"
+
+// cReserved are names a generated C identifier must not take: C keywords
+// (up to C23), the standard library names the generated code and the
+// runtime include, and the entry point.
+var cReserved = map[string]bool{
+	// Keywords
+	"auto": true, "break": true, "case": true, "char": true, "const": true,
+	"continue": true, "default": true, "do": true, "double": true, "else": true,
+	"enum": true, "extern": true, "float": true, "for": true, "goto": true,
+	"if": true, "inline": true, "int": true, "long": true, "register": true,
+	"restrict": true, "return": true, "short": true, "signed": true,
+	"sizeof": true, "static": true, "struct": true, "switch": true,
+	"typedef": true, "union": true, "unsigned": true, "void": true,
+	"volatile": true, "while": true, "alignas": true, "alignof": true,
+	"bool": true, "constexpr": true, "false": true, "nullptr": true,
+	"static_assert": true, "thread_local": true, "true": true,
+	"typeof": true, "typeof_unqual": true, "asm": true,
+
+	// Standard library
+	"main": true, "NULL": true, "EOF": true, "errno": true, "assert": true,
+	"stdin": true, "stdout": true, "stderr": true, "exit": true, "abort": true,
+	"malloc": true, "calloc": true, "realloc": true, "free": true,
+	"memcpy": true, "memmove": true, "memset": true, "memcmp": true,
+	"strlen": true, "strcmp": true, "strncmp": true, "strcpy": true,
+	"printf": true, "fprintf": true, "snprintf": true, "sprintf": true,
+	"puts": true, "putchar": true, "fputs": true, "fwrite": true,
+	"read": true, "write": true, "open": true, "close": true,
+	"getenv": true, "atexit": true, "size_t": true, "ptrdiff_t": true,
+	"int64_t": true, "uint64_t": true, "int32_t": true, "uint32_t": true,
+	"uint16_t": true, "uint8_t": true, "uintptr_t": true,
+}
+
+// runtimePrefixes are the prefixes of the symbols exported by the runtime
+// (pkg/runtime), GMP and CPython (included by Python extension modules).
+var runtimePrefixes = []string{
+	"org_", "ORG_", "Org", "arena_", "ARENA_", "Arena",
+	"mpz_", "mpq_", "mpf_", "mp_", "gmp_", "__gmp",
+	"Py", "PY_", "py_",
+}
+
+// Reserved reports whether sym, a C identifier, is a keyword, a standard
+// library name, a runtime, GMP or CPython symbol, or begins with an underscore
+// (reserved to the implementation in C).
+func Reserved(sym string) bool {
+	if cReserved[sym] || strings.HasPrefix(sym, "_") {
+		return true
+	}
+	for _, p := range runtimePrefixes {
+		if strings.HasPrefix(sym, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package codegen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/diag"
+	"orglang/pkg/ir"
+)
+
+// Symbol is a C symbol emitted for an OrgLang binding.
+type Symbol struct {
+	C       string // emitted C name
+	Module  string // source module path
+	Binding string // OrgLang name of the binding; "" for the initialiser
+	Line    int    // line of the binding in Module
+}
+
+func (s Symbol) source() string {
+	if s.Binding == "" {
+		return "the initialiser of " + s.Module
+	}
+	if s.Line > 0 {
+		return fmt.Sprintf("%q (%s:%d)", s.Binding, s.Module, s.Line)
+	}
+	return fmt.Sprintf("%q (%s)", s.Binding, s.Module)
+}
+
+// SymbolTable records the C symbols emitted across all modules of a
+// program and detects two different bindings landing on the same name,
+// which would fail, or worse silently merge, when the modules are
+// linked. PrintC declares those of each module it prints.
+//
+// Rebinding a name inside one module reuses its symbol and is not a clash.
+type SymbolTable struct {
+	byC     map[string]Symbol
+	clashes []clash
+}
+
+// clash is a binding whose C name was already taken.
+type clash struct {
+	err error
+	sym Symbol
+}
+
+// NewSymbolTable creates an empty table.
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{byC: map[string]Symbol{}}
+}
+
+// Declare records sym, returning an error naming both source bindings if
+// its C name is already taken by a different binding.
+func (t *SymbolTable) Declare(sym Symbol) error {
+	prev, ok := t.byC[sym.C]
+	if !ok {
+		t.byC[sym.C] = sym
+		return nil
+	}
+	if prev.Module == sym.Module && prev.Binding == sym.Binding {
+		return nil
+	}
+	err := fmt.Errorf("C symbol %s is emitted for both %s and %s", sym.C, prev.source(), sym.source())
+	t.clashes = append(t.clashes, clash{err, sym})
+	return err
+}
+
+// Check returns every clash found so far as a single error, or nil.
+func (t *SymbolTable) Check() error {
+	if len(t.clashes) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(t.clashes))
+	for i, c := range t.clashes {
+		msgs[i] = c.err.Error()
+	}
+	sort.Strings(msgs)
+	return fmt.Errorf("symbol collisions:\n  %s", strings.Join(msgs, "\n  "))
+}
+
+// DeclareModule declares the external symbols of the C of m (Globals),
+// the clashes being reported by Check and Diagnostics.
+func (t *SymbolTable) DeclareModule(m *ir.Module) {
+	for _, sym := range Globals(m) {
+		t.Declare(sym)
+	}
+}
+
+// Diagnostics returns the clashes found so far in module, the Module of
+// the later Symbol given to Declare, in the order declared. Each is
+// placed at the line of that binding.
+func (t *SymbolTable) Diagnostics(module string) []diag.Diagnostic {
+	var out []diag.Diagnostic
+	for _, c := range t.clashes {
+		if c.sym.Module != module {
+			continue
+		}
+		out = append(out, diag.Diagnostic{
+			Code:     diag.SymbolClash,
+			Severity: diag.Error,
+			Span:     ast.Span{Start: ast.Pos{Line: c.sym.Line, Column: 1}},
+			Message:  c.err.Error(),
+			Hint:     "rename one of the bindings",
+		})
+	}
+	return out
+}
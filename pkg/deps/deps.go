@@ -0,0 +1,260 @@
+// Package deps fetches the dependencies of a project, the modules listed
+// in the [dependencies] table of its org.toml, into its deps directory.
+package deps
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Source is where a dependency comes from: a git repository, checked out
+// at Ref (a branch or tag; "" for the default branch), or an archive.
+type Source struct {
+	URL     string
+	Ref     string
+	Archive bool
+	// Commit pins a git repository to a commit, the one org.lock
+	// records, instead of the head of Ref.
+	Commit string
+}
+
+// ParseSource reads a source as written in org.toml: a URL, with the ref
+// of a git repository after #. URLs of .tar.gz, .tgz and .zip files are
+// archives, the others git repositories.
+func ParseSource(s string) Source {
+	url, ref, _ := strings.Cut(s, "#")
+	lower := strings.ToLower(url)
+	for _, ext := range []string{".tar.gz", ".tgz", ".zip"} {
+		if strings.HasSuffix(lower, ext) {
+			return Source{URL: url, Archive: true}
+		}
+	}
+	return Source{URL: url, Ref: ref}
+}
+
+// String returns the source as written in org.toml; Commit is not part
+// of it.
+func (s Source) String() string {
+	if s.Ref != "" {
+		return s.URL + "#" + s.Ref
+	}
+	return s.URL
+}
+
+// Fetch puts the files of src in dir, replacing what dir held, and
+// returns the commit checked out ("" for an archive) and the Hash of the
+// files. A git repository is cloned without its history, and without its
+// .git directory, so a dependency is a plain copy either way. An archive
+// holding a single directory, as those of source forges do, has it
+// stripped. The files are fetched into a temporary directory first, so a
+// failed fetch leaves dir as it was; so does a hash other than want,
+// unless want is "".
+func Fetch(src Source, dir, want string) (commit, hash string, err error) {
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return "", "", err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), filepath.Base(dir)+".tmp*")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	root := tmp
+	if src.Archive {
+		if err := fetchArchive(src.URL, tmp); err != nil {
+			return "", "", fmt.Errorf("%s: %w", src.URL, err)
+		}
+		root = stripSingleDir(tmp)
+	} else {
+		root = filepath.Join(tmp, "repo")
+		if commit, err = clone(src, root); err != nil {
+			return "", "", err
+		}
+		if err := os.RemoveAll(filepath.Join(root, ".git")); err != nil {
+			return "", "", err
+		}
+	}
+	if hash, err = Hash(root); err != nil {
+		return "", "", err
+	}
+	if want != "" && hash != want {
+		return "", "", &Mismatch{Dir: dir, Want: want, Got: hash}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return "", "", err
+	}
+	return commit, hash, os.Rename(root, dir)
+}
+
+// clone clones the repository of src into dir with git, at src.Commit if
+// set, and returns the commit checked out.
+func clone(src Source, dir string) (string, error) {
+	if src.Commit == "" {
+		args := []string{"clone", "--quiet", "--depth", "1"}
+		if src.Ref != "" {
+			args = append(args, "--branch", src.Ref)
+		}
+		if _, err := git("", append(args, "--", src.URL, dir)...); err != nil {
+			return "", fmt.Errorf("git clone %s: %w", src, err)
+		}
+	} else {
+		// A commit is not a ref clone accepts: fetch it alone.
+		for _, args := range [][]string{
+			{"init", "--quiet", dir},
+			{"-C", dir, "fetch", "--quiet", "--depth", "1", "--", src.URL, src.Commit},
+			{"-C", dir, "checkout", "--quiet", "FETCH_HEAD"},
+		} {
+			if _, err := git("", args...); err != nil {
+				return "", fmt.Errorf("git fetch %s at %s: %w", src, src.Commit, err)
+			}
+		}
+	}
+	return git(dir, "rev-parse", "HEAD")
+}
+
+// git runs git in dir ("" for the working directory) and returns its
+// output, trimmed; a failure is the message git printed.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s", msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// fetchArchive downloads the archive at url and extracts it into dir.
+func fetchArchive(url, dir string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(strings.ToLower(url), ".zip") {
+		return extractZip(data, dir)
+	}
+	return extractTarGz(data, dir)
+}
+
+func extractTarGz(data []byte, dir string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch h.Typeflag {
+		case tar.TypeDir:
+			err = extractDir(dir, h.Name)
+		case tar.TypeReg:
+			err = extractFile(dir, h.Name, tr, h.FileInfo().Mode())
+		}
+		// Links and other entries are skipped: a module is plain files.
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractZip(data []byte, dir string) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			if err := extractDir(dir, f.Name); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = extractFile(dir, f.Name, r, f.Mode())
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryPath returns where the archive entry name goes in dir, refusing
+// names reaching out of it.
+func entryPath(dir, name string) (string, error) {
+	name = filepath.FromSlash(strings.TrimPrefix(name, "./"))
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("archive entry %q is outside the archive", name)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func extractDir(dir, name string) error {
+	path, err := entryPath(dir, name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path, 0o755)
+}
+
+func extractFile(dir, name string, r io.Reader, mode os.FileMode) error {
+	path, err := entryPath(dir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0o600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// stripSingleDir returns the only entry of dir if it is a directory, and
+// dir otherwise.
+func stripSingleDir(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err == nil && len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(dir, entries[0].Name())
+	}
+	return dir
+}
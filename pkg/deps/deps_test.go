@@ -0,0 +1,301 @@
+package deps
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"orglang/pkg/manifest"
+)
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Source
+	}{
+		{"https://example.com/http.git", Source{URL: "https://example.com/http.git"}},
+		{"https://example.com/http.git#v1.2.0", Source{URL: "https://example.com/http.git", Ref: "v1.2.0"}},
+		{"https://example.com/json-1.0.tar.gz", Source{URL: "https://example.com/json-1.0.tar.gz", Archive: true}},
+		{"https://example.com/json.TGZ", Source{URL: "https://example.com/json.TGZ", Archive: true}},
+		{"https://example.com/util.zip", Source{URL: "https://example.com/util.zip", Archive: true}},
+	}
+	for _, tt := range tests {
+		if got := ParseSource(tt.in); got != tt.want {
+			t.Errorf("ParseSource(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func zipped(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func serve(t *testing.T, archives map[string][]byte) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := archives[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFetchArchive(t *testing.T) {
+	srv := serve(t, map[string][]byte{
+		"/json-1.0.tar.gz": tarGz(t, map[string]string{"json-1.0/json.org": "parse : 1;\n", "json-1.0/lib/util.org": "u : 1;\n"}),
+		"/util.zip":        zipped(t, map[string]string{"main.org": "id : 1;\n", "doc/README": "util\n"}),
+	})
+	root := t.TempDir()
+
+	dir := filepath.Join(root, "deps", "json")
+	if _, _, err := Fetch(ParseSource(srv.URL+"/json-1.0.tar.gz"), dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(dir, "json.org")); got != "parse : 1;\n" {
+		t.Errorf("json.org = %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "lib", "util.org")); got != "u : 1;\n" {
+		t.Errorf("lib/util.org = %q", got)
+	}
+
+	dir = filepath.Join(root, "deps", "util")
+	if _, _, err := Fetch(ParseSource(srv.URL+"/util.zip"), dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(dir, "main.org")); got != "id : 1;\n" {
+		t.Errorf("main.org = %q", got)
+	}
+
+	// A failed fetch leaves the dependency as it was.
+	if _, _, err := Fetch(ParseSource(srv.URL+"/missing.zip"), dir, ""); err == nil {
+		t.Error("fetching a missing archive succeeded")
+	}
+	if got := readFile(t, filepath.Join(dir, "main.org")); got != "id : 1;\n" {
+		t.Errorf("after a failed fetch, main.org = %q", got)
+	}
+	entries, _ := os.ReadDir(filepath.Join(root, "deps"))
+	if len(entries) != 2 {
+		t.Errorf("deps holds %d entries, want json and util only", len(entries))
+	}
+}
+
+func TestFetchArchiveOutside(t *testing.T) {
+	srv := serve(t, map[string][]byte{
+		"/evil.tar.gz": tarGz(t, map[string]string{"../evil.org": "x : 1;\n"}),
+	})
+	root := t.TempDir()
+	if _, _, err := Fetch(ParseSource(srv.URL+"/evil.tar.gz"), filepath.Join(root, "deps", "evil"), ""); err == nil {
+		t.Error("an entry outside the archive was extracted")
+	}
+	if _, err := os.Stat(filepath.Join(root, "deps", "evil.org")); err == nil {
+		t.Error("evil.org was written")
+	}
+}
+
+func TestFetchGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+			"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "--quiet")
+	os.WriteFile(filepath.Join(repo, "http.org"), []byte("get : 1;\n"), 0o644)
+	git("add", ".")
+	git("commit", "--quiet", "-m", "v1")
+	git("tag", "v1")
+	os.WriteFile(filepath.Join(repo, "http.org"), []byte("get : 2;\n"), 0o644)
+	git("commit", "--quiet", "-am", "v2")
+
+	dir := filepath.Join(t.TempDir(), "deps", "http")
+	v1, v1Hash, err := Fetch(ParseSource("file://"+repo+"#v1"), dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v1) != 40 || v1Hash == "" {
+		t.Errorf("fetched commit %q, hash %q", v1, v1Hash)
+	}
+	if got := readFile(t, filepath.Join(dir, "http.org")); got != "get : 1;\n" {
+		t.Errorf("at v1, http.org = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		t.Error(".git was kept")
+	}
+	if _, _, err := Fetch(ParseSource("file://"+repo), dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(dir, "http.org")); got != "get : 2;\n" {
+		t.Errorf("at the default branch, http.org = %q", got)
+	}
+
+	// A locked commit is fetched rather than the head of the ref, and
+	// its files must hash as locked.
+	pinned := ParseSource("file://" + repo)
+	pinned.Commit = v1
+	commit, hash, err := Fetch(pinned, dir, v1Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit != v1 || hash != v1Hash || readFile(t, filepath.Join(dir, "http.org")) != "get : 1;\n" {
+		t.Errorf("pinned fetch: commit %s, hash %s, http.org %q", commit, hash, readFile(t, filepath.Join(dir, "http.org")))
+	}
+	var mismatch *Mismatch
+	if _, _, err := Fetch(ParseSource("file://"+repo), dir, v1Hash); !errors.As(err, &mismatch) {
+		t.Errorf("fetching other files than locked: err = %v, want a mismatch", err)
+	}
+	if got := readFile(t, filepath.Join(dir, "http.org")); got != "get : 1;\n" {
+		t.Errorf("after a mismatch, http.org = %q", got)
+	}
+}
+
+func TestHashAndVerify(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	for _, dir := range []string{a, b} {
+		os.MkdirAll(filepath.Join(dir, "lib"), 0o755)
+		os.WriteFile(filepath.Join(dir, "main.org"), []byte("x : 1;\n"), 0o644)
+		os.WriteFile(filepath.Join(dir, "lib", "util.org"), []byte("u : 1;\n"), 0o600)
+	}
+	ha, err := Hash(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hb, _ := Hash(b); ha != hb {
+		t.Errorf("copies hash differently: %s, %s", ha, hb)
+	}
+	locked := Locked{Name: "a", Hash: ha}
+	if err := Verify(locked, b); err != nil {
+		t.Errorf("Verify of a copy: %v", err)
+	}
+
+	// Moving content from a file to another changes the hash.
+	os.WriteFile(filepath.Join(b, "main.org"), []byte("x : 1;\nu : 1;\n"), 0o644)
+	os.WriteFile(filepath.Join(b, "lib", "util.org"), nil, 0o644)
+	var mismatch *Mismatch
+	if err := Verify(locked, b); !errors.As(err, &mismatch) || mismatch.Got == "" {
+		t.Errorf("Verify of changed files: %v", err)
+	}
+	if err := Verify(locked, filepath.Join(b, "missing")); !errors.As(err, &mismatch) || mismatch.Got != "" {
+		t.Errorf("Verify of a missing directory: %v", err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LockFile)
+	l, err := LoadLock(path)
+	if err != nil || len(l.Dependencies) != 0 {
+		t.Fatalf("missing lock: %+v, %v", l, err)
+	}
+	l.Set(Locked{Name: "json", Source: "https://example.com/json.tar.gz", Hash: "sha256:2"})
+	l.Set(Locked{Name: "http", Source: "https://example.com/http.git#v1", Commit: "abc", Hash: "sha256:1"})
+	l.Set(Locked{Name: "old", Hash: "sha256:3"})
+	l.Remove("old")
+	if err := l.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = LoadLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Locked{
+		{Name: "http", Source: "https://example.com/http.git#v1", Commit: "abc", Hash: "sha256:1"},
+		{Name: "json", Source: "https://example.com/json.tar.gz", Hash: "sha256:2"},
+	}
+	if !slices.Equal(l.Dependencies, want) {
+		t.Errorf("lock = %+v, want %+v", l.Dependencies, want)
+	}
+	if d, ok := l.Get("json"); !ok || d.Hash != "sha256:2" {
+		t.Errorf("Get(json) = %+v, %v", d, ok)
+	}
+}
+
+func TestVerifyProject(t *testing.T) {
+	root := t.TempDir()
+	toml := "[dependencies]\nutil = \"https://example.com/util.zip\"\n"
+	os.WriteFile(filepath.Join(root, manifest.FileName), []byte(toml), 0o644)
+	m, err := manifest.Load(filepath.Join(root, manifest.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyProject(m); err == nil || !strings.Contains(err.Error(), "dependency util is not locked") {
+		t.Errorf("without a lock: %v", err)
+	}
+
+	dir := m.DepDir("util")
+	os.MkdirAll(dir, 0o755)
+	os.WriteFile(filepath.Join(dir, "main.org"), []byte("id : 1;\n"), 0o644)
+	hash, _ := Hash(dir)
+	l := &Lock{}
+	l.Set(Locked{Name: "util", Source: "https://example.com/util.zip", Hash: hash})
+	if err := l.Save(LockPath(m)); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyProject(m); err != nil {
+		t.Errorf("locked files: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "main.org"), []byte("id : 2;\n"), 0o644)
+	if err := VerifyProject(m); err == nil || !strings.Contains(err.Error(), "does not match org.lock") {
+		t.Errorf("changed files: %v", err)
+	}
+
+	m.Dependencies["util"] = "https://example.com/util-2.zip"
+	if err := VerifyProject(m); err == nil || !strings.Contains(err.Error(), "not locked") {
+		t.Errorf("changed source: %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package deps
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"orglang/pkg/manifest"
+
+	"github.com/BurntSushi/toml"
+)
+
+// LockFile is the name of the lock file, next to org.toml.
+const LockFile = "org.lock"
+
+// Lock is the content of org.lock: each dependency as org get fetched it,
+// so that a later fetch gets the same files and a build can tell when the
+// files in deps are not those.
+type Lock struct {
+	Dependencies []Locked `toml:"dependency"`
+}
+
+// Locked is a fetched dependency.
+type Locked struct {
+	Name string `toml:"name"`
+	// Source is the source in org.toml when it was fetched; a dependency
+	// whose source changed since is fetched anew.
+	Source string `toml:"source"`
+	// Commit is the commit of a git repository, fetched again rather than
+	// the head of its ref.
+	Commit string `toml:"commit,omitempty"`
+	// Hash is the Hash of its files.
+	Hash string `toml:"hash"`
+}
+
+// LoadLock reads the lock file at path; a missing file is an empty lock.
+func LoadLock(path string) (*Lock, error) {
+	var l Lock
+	if _, err := toml.DecodeFile(path, &l); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &Lock{}, nil
+		}
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &l, nil
+}
+
+// Save writes the lock to path, the dependencies sorted by name.
+func (l *Lock) Save(path string) error {
+	slices.SortFunc(l.Dependencies, func(a, b Locked) int { return strings.Compare(a.Name, b.Name) })
+	var buf bytes.Buffer
+	buf.WriteString("# Written by org get: the dependencies as fetched. Do not edit.\n\n")
+	if err := toml.NewEncoder(&buf).Encode(l); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// Get returns the entry of the dependency name, if any.
+func (l *Lock) Get(name string) (Locked, bool) {
+	i := slices.IndexFunc(l.Dependencies, func(d Locked) bool { return d.Name == name })
+	if i < 0 {
+		return Locked{}, false
+	}
+	return l.Dependencies[i], true
+}
+
+// Set records d, replacing the entry of the same name.
+func (l *Lock) Set(d Locked) {
+	l.Remove(d.Name)
+	l.Dependencies = append(l.Dependencies, d)
+}
+
+// Remove forgets the dependency name.
+func (l *Lock) Remove(name string) {
+	l.Dependencies = slices.DeleteFunc(l.Dependencies, func(d Locked) bool { return d.Name == name })
+}
+
+// Hash returns the hash of the files below dir: sha256 over the path of
+// each regular file, relative to dir and slash-separated, and its
+// content, in path order. Modification times and permissions do not
+// count, so a copy of the files hashes alike.
+func Hash(dir string) (string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	slices.Sort(files)
+	h := sha256.New()
+	for _, rel := range files {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return "", err
+		}
+		for _, part := range [][]byte{[]byte(rel), data} {
+			// Length-prefix each part, so no two trees hash alike.
+			h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(part))))
+			h.Write(part)
+		}
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Mismatch is a dependency whose files are not those org.lock records.
+type Mismatch struct {
+	Dir       string
+	Want, Got string // hashes; Got is "" if Dir is missing
+}
+
+func (e *Mismatch) Error() string {
+	if e.Got == "" {
+		return fmt.Sprintf("%s is missing (locked at %s)", e.Dir, e.Want)
+	}
+	return fmt.Sprintf("%s does not match %s: hash %s, locked %s", e.Dir, LockFile, e.Got, e.Want)
+}
+
+// LockPath returns the path of the lock file of the project of m.
+func LockPath(m *manifest.Manifest) string {
+	return filepath.Join(m.Dir(), LockFile)
+}
+
+// VerifyProject checks that every dependency of the project of m is in
+// its lock file, from the same source, and that its files in deps are
+// those locked. The error says how to fix it.
+func VerifyProject(m *manifest.Manifest) error {
+	if len(m.Dependencies) == 0 {
+		return nil
+	}
+	l, err := LoadLock(LockPath(m))
+	if err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(m.Dependencies)) {
+		d, ok := l.Get(name)
+		if !ok || d.Source != m.Dependencies[name] {
+			return fmt.Errorf("dependency %s is not locked in %s; run org get", name, LockPath(m))
+		}
+		if err := Verify(d, m.DepDir(name)); err != nil {
+			return fmt.Errorf("%w; run org get to restore it", err)
+		}
+	}
+	return nil
+}
+
+// Verify checks that the files in dir are those locked by d.
+func Verify(d Locked, dir string) error {
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return &Mismatch{Dir: dir, Want: d.Hash}
+	}
+	got, err := Hash(dir)
+	if err != nil {
+		return err
+	}
+	if got != d.Hash {
+		return &Mismatch{Dir: dir, Want: d.Hash, Got: got}
+	}
+	return nil
+}
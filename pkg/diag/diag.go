@@ -0,0 +1,254 @@
+// Package diag defines the diagnostics reported by the lexer, the parser,
+// the lints of `org check` and the code generator, and renders them with
+// the offending source line and a caret under the problem.
+package diag
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/token"
+)
+
+// Severity is how serious a diagnostic is.
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Code identifies the kind of a diagnostic, printed as ORG followed by
+// four digits. The first digit is the phase reporting it: 1 the lexer,
+// 2 the parser, 3 the lints and 4 the code generator. Codes are stable:
+// new ones are added, existing ones never renumbered.
+type Code int
+
+const (
+	// LexError is a bad escape, an unterminated string or input that is
+	// not UTF-8.
+	LexError Code = 1001
+
+	// SyntaxError is source the parser cannot make sense of.
+	SyntaxError Code = 2001
+	// UndefinedIdentifier is a name used without a binding in scope.
+	UndefinedIdentifier Code = 2002
+
+	// Unicode is a bidirectional control or look-alike character.
+	Unicode Code = 3001
+	// Deprecated is a use of a binding tagged @deprecated.
+	Deprecated Code = 3002
+	// Duplicate is a name bound twice in one scope.
+	Duplicate Code = 3003
+	// Scope is left, right or this used outside any block.
+	Scope Code = 3004
+	// UndeclaredSymbol is a symbol operator such as ∑ bound nowhere.
+	UndeclaredSymbol Code = 3005
+
+	// SymbolClash is two bindings emitted as the same C symbol.
+	SymbolClash Code = 4001
+	// ImportCycle is modules importing each other.
+	ImportCycle Code = 4002
+	// Unsupported is a construct the code generator cannot lower yet.
+	Unsupported Code = 4003
+)
+
+func (c Code) String() string {
+	return fmt.Sprintf("ORG%04d", int(c))
+}
+
+// Diagnostic is one problem found in a source file.
+type Diagnostic struct {
+	Code     Code
+	Severity Severity
+	Span     ast.Span // Start is required; a zero End marks one character
+	Message  string
+	Hint     string // optional advice on fixing the problem
+}
+
+// String formats d on one line, "line L:C: message", the form parser
+// errors have always had.
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("line %d:%d: %s", d.Span.Start.Line, d.Span.Start.Column, d.Message)
+}
+
+// At returns the span of t.
+func At(t token.Token) ast.Span {
+	return ast.Span{
+		Start: ast.Pos{Line: t.Line, Column: t.Column, Offset: t.Offset},
+		End:   ast.Pos{Line: t.EndLine, Column: t.EndColumn, Offset: t.End},
+	}
+}
+
+// Styles sets how a rendered diagnostic looks: the colors of its parts,
+// where a nil function leaves its part plain, and the width of a tab.
+type Styles struct {
+	Error, Warning func(string) string // the severity, code and caret
+	Message        func(string) string
+	Gutter         func(string) string // line numbers, bars and the arrow
+	Hint           func(string) string
+
+	// TabWidth, when above 1, expands the tabs of the source line to the
+	// next multiple of it and reports the column as shown so. Otherwise
+	// tabs are kept, the caret lining up under them however wide they
+	// are shown, and the column counts a tab as one.
+	TabWidth int
+}
+
+func apply(f func(string) string, s string) string {
+	if f == nil {
+		return s
+	}
+	return f(s)
+}
+
+// Render writes d, found in src read from path, as
+//
+//	error[ORG2002]: undefined identifier: z
+//	 --> main.org:2:9
+//	  |
+//	2 | y : x + z;
+//	  |         ^
+//	  = hint: bind z before using it
+//
+// The caret covers the span on its first line. The source line is left
+// out when src does not reach it.
+func Render(w io.Writer, d Diagnostic, path string, src []byte, st Styles) {
+	sev := st.Error
+	if d.Severity == Warning {
+		sev = st.Warning
+	}
+	start := d.Span.Start
+	fmt.Fprintf(w, "%s: %s\n", apply(sev, fmt.Sprintf("%s[%s]", d.Severity, d.Code)), apply(st.Message, d.Message))
+
+	line, ok := sourceLine(src, start.Line)
+	num := strconv.Itoa(start.Line)
+	pad := strings.Repeat(" ", len(num))
+	bar := apply(st.Gutter, pad+" |")
+	col, lead, carets := start.Column, indent(line, start.Column), width(d.Span, line)
+	if st.TabWidth > 1 && ok {
+		col = displayColumn(line, start.Column, st.TabWidth)
+		lead = strings.Repeat(" ", col-1)
+		carets = max(displayColumn(line, start.Column+carets, st.TabWidth)-col, 1)
+		line = expandTabs(line, st.TabWidth)
+	}
+	fmt.Fprintf(w, "%s %s:%d:%d\n", apply(st.Gutter, pad+"-->"), path, start.Line, col)
+	if ok {
+		fmt.Fprintln(w, bar)
+		fmt.Fprintf(w, "%s %s\n", apply(st.Gutter, num+" |"), line)
+		fmt.Fprintf(w, "%s %s%s\n", bar, lead, apply(sev, strings.Repeat("^", carets)))
+	}
+	if d.Hint != "" {
+		fmt.Fprintf(w, "%s %s\n", apply(st.Gutter, pad+" ="), apply(st.Hint, "hint: "+d.Hint))
+	}
+}
+
+// sourceLine returns line n (1-indexed) of src without its line ending.
+func sourceLine(src []byte, n int) (string, bool) {
+	if n < 1 {
+		return "", false
+	}
+	for i := 1; i < n; i++ {
+		nl := bytes.IndexByte(src, '\n')
+		if nl < 0 {
+			return "", false
+		}
+		src = src[nl+1:]
+	}
+	if nl := bytes.IndexByte(src, '\n'); nl >= 0 {
+		src = src[:nl]
+	}
+	return strings.TrimSuffix(string(src), "\r"), true
+}
+
+// indent is the blank space before column col of line, keeping its tabs
+// so the caret lines up however wide they are shown.
+func indent(line string, col int) string {
+	var b strings.Builder
+	for _, r := range line {
+		if col <= 1 {
+			break
+		}
+		col--
+		if r == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	if col > 1 {
+		b.WriteString(strings.Repeat(" ", col-1))
+	}
+	return b.String()
+}
+
+// displayColumn returns column col of line, which counts runes, as shown
+// with tabs advancing to the next multiple of tabWidth.
+func displayColumn(line string, col, tabWidth int) int {
+	shown := 1
+	for _, r := range line {
+		if col <= 1 {
+			break
+		}
+		col--
+		if r == '\t' {
+			shown = ((shown-1)/tabWidth+1)*tabWidth + 1
+		} else {
+			shown++
+		}
+	}
+	return shown + max(col-1, 0)
+}
+
+// expandTabs returns line with its tabs replaced by the spaces up to the
+// next multiple of tabWidth.
+func expandTabs(line string, tabWidth int) string {
+	if !strings.ContainsRune(line, '\t') {
+		return line
+	}
+	var b strings.Builder
+	shown := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := tabWidth - shown%tabWidth
+			b.WriteString(strings.Repeat(" ", n))
+			shown += n
+			continue
+		}
+		b.WriteRune(r)
+		shown++
+	}
+	return b.String()
+}
+
+// width is the number of carets under s on line: the columns it covers
+// there, at least one.
+func width(s ast.Span, line string) int {
+	end := s.End.Column
+	switch s.End.Line {
+	case 0:
+		return 1
+	case s.Start.Line:
+	default:
+		end = utf8.RuneCountInString(line) + 1
+	}
+	if n := end - s.Start.Column; n > 1 {
+		return n
+	}
+	return 1
+}
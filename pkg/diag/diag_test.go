@@ -0,0 +1,105 @@
+package diag
+
+import (
+	"strings"
+	"testing"
+
+	"orglang/pkg/ast"
+)
+
+func span(line, col, endCol int) ast.Span {
+	return ast.Span{Start: ast.Pos{Line: line, Column: col}, End: ast.Pos{Line: line, Column: endCol}}
+}
+
+func TestCode(t *testing.T) {
+	if got := UndefinedIdentifier.String(); got != "ORG2002" {
+		t.Errorf("got %q", got)
+	}
+	if got := (Diagnostic{Span: span(2, 9, 10), Message: "oops"}).String(); got != "line 2:9: oops" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	src := []byte("x : 1;\ny : x + zed;\n")
+	tests := []struct {
+		name     string
+		d        Diagnostic
+		expected string
+	}{
+		{"error with hint", Diagnostic{Code: UndefinedIdentifier, Span: span(2, 9, 12), Message: "undefined identifier: zed", Hint: "bind it first"}, `
+error[ORG2002]: undefined identifier: zed
+ --> main.org:2:9
+  |
+2 | y : x + zed;
+  |         ^^^
+  = hint: bind it first
+`},
+		{"warning without end", Diagnostic{Code: Duplicate, Severity: Warning, Span: ast.Span{Start: ast.Pos{Line: 1, Column: 1}}, Message: "x is bound twice"}, `
+warning[ORG3003]: x is bound twice
+ --> main.org:1:1
+  |
+1 | x : 1;
+  | ^
+`},
+		{"line past the source", Diagnostic{Code: SymbolClash, Span: span(7, 1, 1), Message: "clash"}, `
+error[ORG4001]: clash
+ --> main.org:7:1
+`},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		Render(&b, tt.d, "main.org", src, Styles{})
+		if got := b.String(); got != tt.expected[1:] {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.expected[1:])
+		}
+	}
+}
+
+func TestRenderAlignment(t *testing.T) {
+	// Tabs are kept so the caret lines up; a span running past its line
+	// is underlined to the end of it.
+	d := Diagnostic{Span: ast.Span{Start: ast.Pos{Line: 10, Column: 3}, End: ast.Pos{Line: 11, Column: 2}}, Message: "m"}
+	src := []byte(strings.Repeat("\n", 9) + "\t\t\"ab\r\nc\"")
+	var b strings.Builder
+	Render(&b, d, "f.org", src, Styles{})
+	lines := strings.Split(b.String(), "\n")
+	if got, want := lines[3], "10 | \t\t\"ab"; got != want {
+		t.Errorf("source line: got %q, want %q", got, want)
+	}
+	if got, want := lines[4], "   | \t\t^^^"; got != want {
+		t.Errorf("caret line: got %q, want %q", got, want)
+	}
+}
+
+func TestRenderTabWidth(t *testing.T) {
+	// With a tab width, tabs are expanded and the column is the one shown.
+	d := Diagnostic{Span: span(1, 4, 6), Message: "m"}
+	src := []byte("\t x\tyz;")
+	var b strings.Builder
+	Render(&b, d, "f.org", src, Styles{TabWidth: 4})
+	lines := strings.Split(b.String(), "\n")
+	if got, want := lines[1], " --> f.org:1:7"; got != want {
+		t.Errorf("location: got %q, want %q", got, want)
+	}
+	if got, want := lines[3], "1 |      x  yz;"; got != want {
+		t.Errorf("source line: got %q, want %q", got, want)
+	}
+	if got, want := lines[4], "  |       ^^^"; got != want {
+		t.Errorf("caret line: got %q, want %q", got, want)
+	}
+}
+
+func TestRenderStyles(t *testing.T) {
+	tag := func(name string) func(string) string {
+		return func(s string) string { return "<" + name + ">" + s + "</" + name + ">" }
+	}
+	d := Diagnostic{Code: Scope, Severity: Warning, Span: span(1, 1, 2), Message: "m", Hint: "h"}
+	var b strings.Builder
+	Render(&b, d, "f.org", []byte("x"), Styles{Error: tag("e"), Warning: tag("w"), Message: tag("m"), Gutter: tag("g"), Hint: tag("h")})
+	for _, want := range []string{"<w>warning[ORG3004]</w>: <m>m</m>", "<g>1 |</g> x", "<w>^</w>", "<h>hint: h</h>"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %q in\n%s", want, b.String())
+		}
+	}
+}
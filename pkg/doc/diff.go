@@ -0,0 +1,99 @@
+package doc
+
+import (
+	"fmt"
+	"slices"
+)
+
+// ChangeKind classifies an API change between two versions of a module.
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "added"
+	Removed ChangeKind = "removed"
+	Changed ChangeKind = "changed"
+)
+
+// Change is one difference between two versions of a module's API.
+// Name is empty when the whole module was added or removed.
+type Change struct {
+	Kind    ChangeKind `json:"kind"`
+	Module  string     `json:"module"`
+	Name    string     `json:"name,omitempty"`
+	Details []string   `json:"details,omitempty"`
+}
+
+func (c Change) String() string {
+	target := c.Module
+	if c.Name != "" {
+		target += "." + c.Name
+	}
+	return fmt.Sprintf("%s %s", c.Kind, target)
+}
+
+// DiffModules compares two sets of modules, pairing them by name.
+func DiffModules(old, new []*Module) []Change {
+	var changes []Change
+	oldByName := map[string]*Module{}
+	for _, m := range old {
+		oldByName[m.Name] = m
+	}
+	seen := map[string]bool{}
+	for _, m := range new {
+		seen[m.Name] = true
+		if o, ok := oldByName[m.Name]; ok {
+			changes = append(changes, Diff(o, m)...)
+		} else {
+			changes = append(changes, Change{Kind: Added, Module: m.Name})
+		}
+	}
+	for _, m := range old {
+		if !seen[m.Name] {
+			changes = append(changes, Change{Kind: Removed, Module: m.Name})
+		}
+	}
+	return changes
+}
+
+// Diff compares the bindings of two versions of a module: their names,
+// operator classification (including binding powers) and documentation.
+// The result follows the binding order of new, then the removed bindings.
+func Diff(old, new *Module) []Change {
+	var changes []Change
+	for _, b := range new.Bindings {
+		o := old.Lookup(b.Name)
+		if o == nil {
+			changes = append(changes, Change{Kind: Added, Module: new.Name, Name: b.Name, Details: []string{b.Kind}})
+			continue
+		}
+		var details []string
+		if o.Kind != b.Kind {
+			details = append(details, fmt.Sprintf("kind: %s -> %s", o.Kind, b.Kind))
+		}
+		if o.Doc != b.Doc {
+			details = append(details, "docstring changed")
+		}
+		if !slices.Equal(o.Params, b.Params) {
+			details = append(details, "parameters changed")
+		}
+		if o.Returns != b.Returns {
+			details = append(details, "returns changed")
+		}
+		if o.Deprecated != b.Deprecated {
+			if b.Deprecated {
+				details = append(details, "deprecated")
+			} else {
+				details = append(details, "no longer deprecated")
+			}
+		}
+		if len(details) > 0 {
+			changes = append(changes, Change{Kind: Changed, Module: new.Name, Name: b.Name, Details: details})
+		}
+	}
+	for _, o := range old.Bindings {
+		if new.Lookup(o.Name) == nil {
+			changes = append(changes, Change{Kind: Removed, Module: new.Name, Name: o.Name, Details: []string{o.Kind}})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,272 @@
+// Package doc extracts documentation from OrgLang source files.
+//
+// A docstring, in triple double quotes or, raw, in triple single quotes,
+// standing as its own statement documents the binding that immediately
+// follows it. A leading docstring that is not followed by a binding
+// documents the module itself.
+package doc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+)
+
+// Binding is a top-level binding of a module. Doc holds the free text of
+// its docstring; the structured tags are split into their own fields.
+type Binding struct {
+	Name     string   `json:"name"`
+	Kind     string   `json:"kind"`
+	Line     int      `json:"line,omitempty"` // of its first binding in the module
+	Doc      string   `json:"doc,omitempty"`
+	Params   []Param  `json:"params,omitempty"`
+	Returns  string   `json:"returns,omitempty"`
+	Examples []string `json:"examples,omitempty"`
+
+	Deprecated     bool   `json:"deprecated,omitempty"`
+	DeprecatedNote string `json:"deprecated_note,omitempty"`
+
+	Export     bool   `json:"export,omitempty"`
+	ExportName string `json:"export_name,omitempty"`
+
+	Resource bool `json:"resource,omitempty"`
+	Prefix   bool `json:"prefix,omitempty"`
+	Infix    bool `json:"infix,omitempty"`
+	PrefixBP int  `json:"prefix_bp,omitempty"`
+	LBP      int  `json:"lbp,omitempty"`
+	RBP      int  `json:"rbp,omitempty"`
+}
+
+// IsOperator reports whether the binding is used as a prefix or infix operator.
+func (b *Binding) IsOperator() bool {
+	return b.Prefix || b.Infix
+}
+
+// Module is the documentation model of a single source file.
+type Module struct {
+	Name     string     `json:"name"`
+	Path     string     `json:"path"`
+	Doc      string     `json:"doc,omitempty"`
+	Imports  []Import   `json:"imports,omitempty"`
+	Bindings []*Binding `json:"bindings"`
+}
+
+// Import is a module loaded with `alias : "path" @ org`.
+type Import struct {
+	Alias string `json:"alias"`
+	Path  string `json:"path"`
+}
+
+// ImportPath returns the path an import of m refers to: relative paths
+// are taken from the directory of m.
+func (m *Module) ImportPath(imp Import) string {
+	if filepath.IsAbs(imp.Path) {
+		return filepath.Clean(imp.Path)
+	}
+	return filepath.Join(filepath.Dir(m.Path), imp.Path)
+}
+
+// Lookup returns the binding with the given name, or nil.
+func (m *Module) Lookup(name string) *Binding {
+	for _, b := range m.Bindings {
+		if b.Name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// Parse builds the documentation model of a module from its source.
+// It fails if the parser reports errors.
+func Parse(name, path string, src []byte) (*Module, error) {
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, fmt.Errorf("%s: %s", path, strings.Join(errs, "; "))
+	}
+
+	mod := &Module{Name: name, Path: path}
+	byName := map[string]*Binding{}
+	var pending *ast.StringLiteral
+	pendingIdx := -1
+
+	// flush gives a docstring that documents no binding to the module,
+	// provided it opens the file.
+	flush := func() {
+		if pending != nil && pendingIdx == 0 {
+			mod.Doc = pending.Value
+		}
+		pending = nil
+	}
+
+	for i, stmt := range prog.Statements {
+		if sl, ok := stmt.(*ast.StringLiteral); ok && sl.IsDoc {
+			flush()
+			pending, pendingIdx = sl, i
+			continue
+		}
+
+		name, ok := bindingName(stmt)
+		if !ok {
+			flush()
+			continue
+		}
+		if path, ok := importPath(stmt); ok {
+			mod.Imports = append(mod.Imports, Import{Alias: name, Path: path})
+		}
+
+		b, seen := byName[name]
+		if !seen {
+			b = &Binding{Name: name, Line: stmt.Location().Start.Line}
+			byName[name] = b
+			mod.Bindings = append(mod.Bindings, b)
+		}
+		if pending != nil {
+			d := ParseDocstring(pending.Value)
+			b.Doc, b.Params, b.Returns, b.Examples = d.Text, d.Params, d.Returns, d.Examples
+			b.Deprecated, b.DeprecatedNote = d.Deprecated, d.DeprecatedNote
+			b.Export, b.ExportName = d.Export, d.ExportName
+			pending = nil
+		}
+	}
+	flush()
+
+	for _, b := range mod.Bindings {
+		entry, _ := p.Bindings().Lookup(b.Name)
+		b.Kind = entry.Kind()
+		b.Resource = entry.IsResource
+		b.Prefix = entry.IsPrefix
+		b.Infix = entry.IsInfix
+		b.PrefixBP = entry.PrefixBP
+		b.LBP = entry.LBP
+		b.RBP = entry.RBP
+	}
+	return mod, nil
+}
+
+// importPath returns the path of a top-level `alias : "path" @ org`.
+func importPath(stmt ast.Statement) (string, bool) {
+	b, ok := stmt.(*ast.BindingExpr)
+	if !ok {
+		return "", false
+	}
+	ie, ok := b.Value.(*ast.InfixExpr)
+	if !ok || ie.Op != "@" {
+		return "", false
+	}
+	path, isStr := ie.Left.(*ast.StringLiteral)
+	org, isName := ie.Right.(*ast.Name)
+	if !isStr || !isName || org.Value != "org" {
+		return "", false
+	}
+	return path.Value, true
+}
+
+// bindingName returns the bound name of a top-level `name : value` or
+// `name @: value` statement.
+func bindingName(stmt ast.Statement) (string, bool) {
+	switch s := stmt.(type) {
+	case *ast.BindingExpr:
+		if n, ok := s.Name.(*ast.Name); ok && (s.Operator == "" || s.Operator == ":") {
+			return n.Value, true
+		}
+	case *ast.ResourceDef:
+		if n, ok := s.Name.(*ast.Name); ok {
+			return n.Value, true
+		}
+	}
+	return "", false
+}
+
+// splitFences separates the fenced (```) code blocks of a text from its prose.
+func splitFences(text string) (string, []string) {
+	var prose, code []string
+	var blocks []string
+	inFence := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if inFence {
+				blocks = append(blocks, strings.Join(code, "\n"))
+				code = nil
+			}
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			code = append(code, line)
+		} else {
+			prose = append(prose, line)
+		}
+	}
+	if inFence {
+		prose = append(prose, code...)
+	}
+	return strings.TrimSpace(collapseBlankLines(prose)), blocks
+}
+
+// collapseBlankLines joins lines, squeezing runs of blank lines into one.
+func collapseBlankLines(lines []string) string {
+	var out []string
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" && i > 0 && strings.TrimSpace(lines[i-1]) == "" {
+			continue
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// Load reads every module under the given paths. A path may be a single
+// .org file or a directory, which is walked recursively. Module names are
+// the file paths relative to the directory, without the .org extension.
+func Load(paths ...string) ([]*Module, error) {
+	var mods []*Module
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			mod, err := loadFile(strings.TrimSuffix(filepath.Base(root), ".org"), root)
+			if err != nil {
+				return nil, err
+			}
+			mods = append(mods, mod)
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() || filepath.Ext(path) != ".org" {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			mod, err := loadFile(filepath.ToSlash(strings.TrimSuffix(rel, ".org")), path)
+			if err != nil {
+				return err
+			}
+			mods = append(mods, mod)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(mods, func(i, j int) bool { return mods[i].Name < mods[j].Name })
+	return mods, nil
+}
+
+func loadFile(name, path string) (*Module, error) {
+	src, err := lexer.ReadSource(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(name, path, src)
+}
@@ -0,0 +1,315 @@
+package doc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const mathSrc = `"""
+Small math helpers.
+"""
+
+"""
+Squares its right operand.
+
+` + "```" + `
+sq 4
+` + "```" + `
+"""
+sq : { right * right };
+
+"""Adds both operands. See ` + "`sq`" + `."""
+add : { left + right };
+
+limit : 10;
+`
+
+func TestParse(t *testing.T) {
+	mod, err := Parse("math", "math.org", []byte(mathSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mod.Doc != "Small math helpers." {
+		t.Errorf("module doc: got %q", mod.Doc)
+	}
+	if len(mod.Bindings) != 3 {
+		t.Fatalf("expected 3 bindings, got %d", len(mod.Bindings))
+	}
+
+	sq := mod.Lookup("sq")
+	if !strings.HasPrefix(sq.Doc, "Squares its right operand.") {
+		t.Errorf("sq doc: got %q", sq.Doc)
+	}
+	if len(sq.Examples) != 1 || sq.Examples[0] != "sq 4" {
+		t.Errorf("sq examples: got %q", sq.Examples)
+	}
+	if !sq.Prefix || sq.PrefixBP != 100 || sq.Kind != "prefix operator (bp 100)" {
+		t.Errorf("sq classification: got %+v", sq)
+	}
+
+	add := mod.Lookup("add")
+	if !add.Infix || add.LBP != 100 || add.RBP != 101 {
+		t.Errorf("add classification: got %+v", add)
+	}
+
+	limit := mod.Lookup("limit")
+	if limit.Doc != "" || limit.Kind != "value" {
+		t.Errorf("limit: got %+v", limit)
+	}
+}
+
+func TestParseLeadingDocOnBinding(t *testing.T) {
+	mod, err := Parse("m", "m.org", []byte(`"""The answer."""
+answer : 42;`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mod.Doc != "" {
+		t.Errorf("module doc should be empty, got %q", mod.Doc)
+	}
+	if got := mod.Lookup("answer").Doc; got != "The answer." {
+		t.Errorf("answer doc: got %q", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	if _, err := Parse("bad", "bad.org", []byte("( 1 + 1")); err == nil {
+		t.Error("expected parse error")
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := map[string]string{
+		"add_five": "add_five",
+		"plus!":    "plus-21-",
+		"-":        "-2d-",
+		"lib/math": "lib-2f-math",
+		"∑":        "-2211-",
+	}
+	for in, expected := range tests {
+		if got := slug(in); got != expected {
+			t.Errorf("slug(%q): expected %q, got %q", in, expected, got)
+		}
+	}
+}
+
+func TestSiteWrite(t *testing.T) {
+	math, err := Parse("math", "math.org", []byte(mathSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	util, err := Parse("util", "util.org", []byte(`"""Uses `+"`math.sq`"+` and `+"`limit`"+`."""
+twice : { right + right };`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	site := NewSite("Test", []*Module{math, util})
+	if err := site.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []string{"index.html", "operators.html", "m.math.html", "b.math.sq.html", "b.util.twice.html"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Errorf("missing page %s", f)
+		}
+	}
+
+	twice, _ := os.ReadFile(filepath.Join(dir, "b.util.twice.html"))
+	if !strings.Contains(string(twice), `<a href="b.math.sq.html"><code>math.sq</code></a>`) {
+		t.Errorf("qualified reference not linked:\n%s", twice)
+	}
+	if !strings.Contains(string(twice), `<a href="b.math.limit.html"><code>limit</code></a>`) {
+		t.Errorf("unique reference not linked:\n%s", twice)
+	}
+
+	add, _ := os.ReadFile(filepath.Join(dir, "b.math.add.html"))
+	if !strings.Contains(string(add), `<a href="b.math.sq.html"><code>sq</code></a>`) {
+		t.Errorf("local reference not linked:\n%s", add)
+	}
+
+	ops, _ := os.ReadFile(filepath.Join(dir, "operators.html"))
+	if !strings.Contains(string(ops), "<code>add</code>") || strings.Contains(string(ops), "<code>limit</code>") {
+		t.Errorf("operator table should list operators only:\n%s", ops)
+	}
+}
+
+func TestSiteImports(t *testing.T) {
+	math, err := Parse("lib/math", "lib/math.org", []byte(mathSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := Parse("app", "app.org", []byte(`m : "lib/math.org" @ org;
+json : "vendor/json.org" @ org;
+
+"""Squares with `+"`m.sq`"+`."""
+area : { m.sq right };`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Import{{"m", "lib/math.org"}, {"json", "vendor/json.org"}}
+	if len(app.Imports) != 2 || app.Imports[0] != want[0] || app.Imports[1] != want[1] {
+		t.Fatalf("imports: got %+v", app.Imports)
+	}
+
+	dir := t.TempDir()
+	if err := NewSite("Test", []*Module{app, math}).Write(dir); err != nil {
+		t.Fatal(err)
+	}
+	page, _ := os.ReadFile(filepath.Join(dir, "m.app.html"))
+	for _, want := range []string{
+		`<td><code>m</code></td><td><a href="m.lib-2f-math.html">lib/math</a></td>`,
+		`<td><code>json</code></td><td><code>vendor/json.org</code></td>`,
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("module page lacks %s:\n%s", want, page)
+		}
+	}
+	lib, _ := os.ReadFile(filepath.Join(dir, "m.lib-2f-math.html"))
+	if !strings.Contains(string(lib), `Imported by <a href="m.app.html">app</a>`) {
+		t.Errorf("imported module does not link back:\n%s", lib)
+	}
+	area, _ := os.ReadFile(filepath.Join(dir, "b.app.area.html"))
+	if !strings.Contains(string(area), `<a href="b.lib-2f-math.sq.html"><code>m.sq</code></a>`) {
+		t.Errorf("reference through an import alias not linked:\n%s", area)
+	}
+}
+
+func TestParseDocstringTags(t *testing.T) {
+	d := ParseDocstring(`Raises left to the power of right.
+
+@param left the base
+@param right the exponent, an integer
+@returns the power, as a rational
+  when right is negative
+@example
+    2 pow 10
+    2 pow -1`)
+
+	if d.Text != "Raises left to the power of right." {
+		t.Errorf("text: got %q", d.Text)
+	}
+	if len(d.Params) != 2 || d.Params[0] != (Param{"left", "the base"}) || d.Params[1] != (Param{"right", "the exponent, an integer"}) {
+		t.Errorf("params: got %+v", d.Params)
+	}
+	if d.Returns != "the power, as a rational\n  when right is negative" {
+		t.Errorf("returns: got %q", d.Returns)
+	}
+	if len(d.Examples) != 1 || d.Examples[0] != "2 pow 10\n2 pow -1" {
+		t.Errorf("examples: got %q", d.Examples)
+	}
+}
+
+func TestParseDocstringUnknownTags(t *testing.T) {
+	d := ParseDocstring("Writes to\n@stdout by default.\n@since 1.0")
+	if d.Text != "Writes to\n@stdout by default.\n@since 1.0" {
+		t.Errorf("text: got %q", d.Text)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old, err := Parse("m", "old.org", []byte(`"""Adds."""
+add : { left + right };
+sq : { right * right };
+pi : 3.14;`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	new, err := Parse("m", "new.org", []byte(`"""Adds both operands."""
+add : { left + right };
+sq : 50{ left * right }51;
+tau : 6.28;`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := Diff(old, new)
+	expected := []Change{
+		{Kind: Changed, Module: "m", Name: "add", Details: []string{"docstring changed"}},
+		{Kind: Changed, Module: "m", Name: "sq", Details: []string{"kind: prefix operator (bp 100) -> infix operator (lbp 50, rbp 51)"}},
+		{Kind: Added, Module: "m", Name: "tau", Details: []string{"value"}},
+		{Kind: Removed, Module: "m", Name: "pi", Details: []string{"value"}},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %d: %v", len(expected), len(changes), changes)
+	}
+	for i, c := range changes {
+		if c.String() != expected[i].String() || strings.Join(c.Details, "|") != strings.Join(expected[i].Details, "|") {
+			t.Errorf("change %d: expected %v %v, got %v %v", i, expected[i], expected[i].Details, c, c.Details)
+		}
+	}
+}
+
+func TestDiffModules(t *testing.T) {
+	a := &Module{Name: "a"}
+	b := &Module{Name: "b"}
+	changes := DiffModules([]*Module{a}, []*Module{b})
+	if len(changes) != 2 || changes[0].String() != "added b" || changes[1].String() != "removed a" {
+		t.Errorf("got %v", changes)
+	}
+}
+
+func TestReplacement(t *testing.T) {
+	for note, want := range map[string]string{
+		"use `fold_add`\n  instead": "fold_add",
+		"use pow.":                  "pow",
+		"use `a + b`":               "",
+		"use `unclosed":             "",
+		"removed in 2.0":            "",
+		"":                          "",
+	} {
+		if got := Replacement(note); got != want {
+			t.Errorf("Replacement(%q) = %q, want %q", note, got, want)
+		}
+	}
+}
+
+func TestDeprecatedTag(t *testing.T) {
+	d := ParseDocstring("Sums a table.\n@deprecated use `fold_add`\n  instead")
+	if !d.Deprecated || d.DeprecatedNote != "use `fold_add`\n  instead" || d.Text != "Sums a table." {
+		t.Errorf("got %+v", d)
+	}
+	if d := ParseDocstring("@deprecated"); !d.Deprecated || d.DeprecatedNote != "" {
+		t.Errorf("bare tag: got %+v", d)
+	}
+
+	old, _ := Parse("m", "old.org", []byte(`sum : { right };`))
+	new, err := Parse("m", "new.org", []byte(`"""@deprecated use `+"`fold`"+`"""
+sum : { right };`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := new.Lookup("sum"); !b.Deprecated || b.DeprecatedNote != "use `fold`" {
+		t.Errorf("binding: got %+v", b)
+	}
+	changes := Diff(old, new)
+	if len(changes) != 1 || strings.Join(changes[0].Details, "|") != "deprecated" {
+		t.Errorf("diff: got %v", changes)
+	}
+}
+
+func TestExportTag(t *testing.T) {
+	mod, err := Parse("m", "m.org", []byte(`"""Adds.
+@export m_add"""
+add : { left + right };
+
+"""@export"""
+pi : 3.14;
+
+sq : { right * right };`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := mod.Lookup("add"); !b.Export || b.ExportName != "m_add" || b.Doc != "Adds." {
+		t.Errorf("add: got %+v", b)
+	}
+	if b := mod.Lookup("pi"); !b.Export || b.ExportName != "" {
+		t.Errorf("pi: got %+v", b)
+	}
+	if b := mod.Lookup("sq"); b.Export {
+		t.Errorf("sq: got %+v", b)
+	}
+}
@@ -0,0 +1,324 @@
+package doc
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Site is a set of modules rendered as cross-linked HTML pages.
+//
+// All pages live in a single directory: index.html, operators.html,
+// one page per module and one page per binding. Names inside backticks
+// in docstrings are resolved through the site's symbol table and become
+// links to the documented binding. Module pages link to the modules they
+// import and to those importing them.
+type Site struct {
+	Title   string
+	Modules []*Module
+
+	symbols map[string][]*symbol
+	byPath  map[string]*Module
+	tmpl    *template.Template
+}
+
+type symbol struct {
+	mod *Module
+	b   *Binding
+}
+
+// NewSite builds the symbol table for the given modules.
+func NewSite(title string, mods []*Module) *Site {
+	s := &Site{Title: title, Modules: mods, symbols: map[string][]*symbol{}, byPath: map[string]*Module{}}
+	for _, m := range mods {
+		s.byPath[filepath.Clean(m.Path)] = m
+		for _, b := range m.Bindings {
+			s.symbols[b.Name] = append(s.symbols[b.Name], &symbol{mod: m, b: b})
+			qualified := m.Name + "." + b.Name
+			s.symbols[qualified] = append(s.symbols[qualified], &symbol{mod: m, b: b})
+		}
+	}
+	return s
+}
+
+// Resolve returns the page of the binding a reference from module `from`
+// points to. References are looked up in `from` first, then across the
+// site; `module.name` selects a binding of another module explicitly, as
+// does `alias.name` for a module `from` imports as alias.
+// Ambiguous or unknown references resolve to "".
+func (s *Site) Resolve(from *Module, ref string) string {
+	if from != nil {
+		if b := from.Lookup(ref); b != nil {
+			return BindingPage(from, b)
+		}
+		if alias, name, ok := strings.Cut(ref, "."); ok {
+			for _, imp := range from.Imports {
+				if m := s.Imported(from, imp); m != nil && imp.Alias == alias {
+					if b := m.Lookup(name); b != nil {
+						return BindingPage(m, b)
+					}
+				}
+			}
+		}
+	}
+	if syms := s.symbols[ref]; len(syms) == 1 {
+		return BindingPage(syms[0].mod, syms[0].b)
+	}
+	return ""
+}
+
+// Imported returns the site module an import of m loads, or nil if it is
+// not part of the site.
+func (s *Site) Imported(m *Module, imp Import) *Module {
+	return s.byPath[m.ImportPath(imp)]
+}
+
+// Importers returns the site modules importing m.
+func (s *Site) Importers(m *Module) []*Module {
+	var mods []*Module
+	for _, other := range s.Modules {
+		for _, imp := range other.Imports {
+			if s.Imported(other, imp) == m {
+				mods = append(mods, other)
+				break
+			}
+		}
+	}
+	return mods
+}
+
+// ModulePage returns the file name of a module's page.
+func ModulePage(m *Module) string {
+	return "m." + slug(m.Name) + ".html"
+}
+
+// BindingPage returns the file name of a binding's page.
+func BindingPage(m *Module, b *Binding) string {
+	return "b." + slug(m.Name) + "." + slug(b.Name) + ".html"
+}
+
+// slug encodes a name into a portable file name component. ASCII letters,
+// digits and '_' are kept, every other rune (including '-') is written as
+// -<hex>-, so distinct names never share a slug.
+func slug(name string) string {
+	var out strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			out.WriteRune(r)
+		default:
+			fmt.Fprintf(&out, "-%x-", r)
+		}
+	}
+	return out.String()
+}
+
+// Write renders the site into dir, creating it if needed.
+func (s *Site) Write(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	s.tmpl = s.templates()
+	if err := s.writePage(dir, "index.html", "index", s.Title, map[string]any{"Modules": s.Modules}); err != nil {
+		return err
+	}
+	if err := s.writePage(dir, "operators.html", "operators", "Operators", map[string]any{"Operators": s.operators()}); err != nil {
+		return err
+	}
+	for _, m := range s.Modules {
+		if err := s.writePage(dir, ModulePage(m), "module", m.Name, map[string]any{"Module": m}); err != nil {
+			return err
+		}
+		for _, b := range m.Bindings {
+			data := map[string]any{"Module": m, "Binding": b}
+			if err := s.writePage(dir, BindingPage(m, b), "binding", m.Name+"."+b.Name, data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type operatorRow struct {
+	Module  *Module
+	Binding *Binding
+}
+
+// operators lists every operator binding of the site, sorted by name.
+func (s *Site) operators() []operatorRow {
+	var rows []operatorRow
+	for _, m := range s.Modules {
+		for _, b := range m.Bindings {
+			if b.IsOperator() {
+				rows = append(rows, operatorRow{Module: m, Binding: b})
+			}
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Binding.Name < rows[j].Binding.Name })
+	return rows
+}
+
+func (s *Site) writePage(dir, file, body, title string, data map[string]any) error {
+	f, err := os.Create(filepath.Join(dir, file))
+	if err != nil {
+		return err
+	}
+	data["Site"] = s
+	data["Title"] = title
+	if err := s.tmpl.ExecuteTemplate(f, body, data); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return f.Close()
+}
+
+func (s *Site) templates() *template.Template {
+	funcs := template.FuncMap{
+		"modulePage":  ModulePage,
+		"bindingPage": BindingPage,
+		"summary":     Summary,
+		"imported":    s.Imported,
+		"importers":   s.Importers,
+		"render": func(m *Module, text string) template.HTML {
+			return s.render(m, text)
+		},
+	}
+	return template.Must(template.New("site").Funcs(funcs).Parse(siteTemplates))
+}
+
+var refRe = regexp.MustCompile("`([^`\n]+)`")
+
+// render turns docstring text into HTML: blank-line separated paragraphs,
+// fenced (```) code blocks, and `references` linked through the symbol table.
+func (s *Site) render(m *Module, text string) template.HTML {
+	var out strings.Builder
+	var para []string
+	var code []string
+	inFence := false
+
+	flushPara := func() {
+		if len(para) == 0 {
+			return
+		}
+		escaped := html.EscapeString(strings.Join(para, "\n"))
+		linked := refRe.ReplaceAllStringFunc(escaped, func(match string) string {
+			ref := html.UnescapeString(match[1 : len(match)-1])
+			if page := s.Resolve(m, ref); page != "" {
+				return fmt.Sprintf(`<a href="%s"><code>%s</code></a>`, page, html.EscapeString(ref))
+			}
+			return "<code>" + html.EscapeString(ref) + "</code>"
+		})
+		out.WriteString("<p>" + linked + "</p>\n")
+		para = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if inFence {
+				out.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+				code = nil
+			} else {
+				flushPara()
+			}
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			code = append(code, line)
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			flushPara()
+			continue
+		}
+		para = append(para, line)
+	}
+	if inFence {
+		para = append(para, code...)
+	}
+	flushPara()
+	return template.HTML(out.String())
+}
+
+// Summary returns the first line of a docstring.
+func Summary(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
+
+const siteTemplates = `
+{{define "header"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #222; line-height: 1.5; }
+a { color: #1f5fbf; text-decoration: none; }
+a:hover { text-decoration: underline; }
+nav { color: #777; font-size: 0.9rem; margin-bottom: 1.5rem; }
+code, pre { font-family: ui-monospace, monospace; font-size: 0.9rem; }
+pre { background: #f5f5f5; padding: 0.75rem; overflow-x: auto; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.25rem 0.75rem 0.25rem 0; border-bottom: 1px solid #eee; vertical-align: top; }
+.kind { color: #777; }
+.deprecated { border-left: 3px solid #c0392b; padding-left: 0.75rem; }
+</style>
+</head>
+<body>
+<nav><a href="index.html">{{.Site.Title}}</a> · <a href="operators.html">Operators</a></nav>
+{{end}}
+
+{{define "footer"}}</body>
+</html>
+{{end}}
+
+{{define "index"}}{{template "header" .}}<h1>{{.Site.Title}}</h1>
+<table>
+<tr><th>Module</th><th>Synopsis</th></tr>
+{{range .Modules}}<tr><td><a href="{{modulePage .}}">{{.Name}}</a></td><td>{{summary .Doc}}</td></tr>
+{{end}}</table>
+{{template "footer" .}}{{end}}
+
+{{define "operators"}}{{template "header" .}}<h1>Operators</h1>
+<table>
+<tr><th>Operator</th><th>Module</th><th>Prefix BP</th><th>LBP</th><th>RBP</th></tr>
+{{range .Operators}}<tr><td><a href="{{bindingPage .Module .Binding}}"><code>{{.Binding.Name}}</code></a></td><td><a href="{{modulePage .Module}}">{{.Module.Name}}</a></td><td>{{if .Binding.Prefix}}{{.Binding.PrefixBP}}{{end}}</td><td>{{if .Binding.Infix}}{{.Binding.LBP}}{{end}}</td><td>{{if .Binding.Infix}}{{.Binding.RBP}}{{end}}</td></tr>
+{{end}}</table>
+{{template "footer" .}}{{end}}
+
+{{define "module"}}{{template "header" .}}<h1>{{.Module.Name}}</h1>
+{{render .Module .Module.Doc}}
+{{$m := .Module}}{{if .Module.Imports}}<h2>Imports</h2>
+<table>
+{{range .Module.Imports}}<tr><td><code>{{.Alias}}</code></td><td>{{with imported $m .}}<a href="{{modulePage .}}">{{.Name}}</a>{{else}}<code>{{.Path}}</code>{{end}}</td></tr>
+{{end}}</table>
+{{end}}{{with importers .Module}}<p class="kind">Imported by {{range $i, $u := .}}{{if $i}}, {{end}}<a href="{{modulePage $u}}">{{$u.Name}}</a>{{end}}</p>
+{{end}}<table>
+<tr><th>Binding</th><th>Kind</th><th>Synopsis</th></tr>
+{{range .Module.Bindings}}<tr><td><a href="{{bindingPage $m .}}"><code>{{.Name}}</code></a></td><td class="kind">{{.Kind}}{{if .Deprecated}}, deprecated{{end}}</td><td>{{summary .Doc}}</td></tr>
+{{end}}</table>
+{{range .Module.Bindings}}{{if .Examples}}<h2>Example: <a href="{{bindingPage $m .}}"><code>{{.Name}}</code></a></h2>
+{{range .Examples}}<pre><code>{{.}}</code></pre>
+{{end}}{{end}}{{end}}{{template "footer" .}}{{end}}
+
+{{define "binding"}}{{template "header" .}}<h1><code>{{.Binding.Name}}</code></h1>
+<p class="kind">{{.Binding.Kind}} in <a href="{{modulePage .Module}}">{{.Module.Name}}</a></p>
+{{if .Binding.Deprecated}}<div class="deprecated"><p><strong>Deprecated.</strong></p>
+{{render .Module .Binding.DeprecatedNote}}</div>
+{{end}}{{render .Module .Binding.Doc}}
+{{if .Binding.Params}}<h2>Parameters</h2>
+<table>
+{{range .Binding.Params}}<tr><td><code>{{.Name}}</code></td><td>{{render $.Module .Desc}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Binding.Returns}}<h2>Returns</h2>
+{{render .Module .Binding.Returns}}
+{{end}}{{if .Binding.Examples}}<h2>Examples</h2>
+{{range .Binding.Examples}}<pre><code>{{.}}</code></pre>
+{{end}}{{end}}{{template "footer" .}}{{end}}
+`
@@ -0,0 +1,162 @@
+package doc
+
+import (
+	"strings"
+
+	"orglang/pkg/lexer"
+	"orglang/pkg/token"
+)
+
+// Param documents one operand of a binding (@param left ..., @param right ...).
+type Param struct {
+	Name string `json:"name"`
+	Desc string `json:"desc,omitempty"`
+}
+
+// Docstring is a docstring split into its free text and structured tags.
+//
+// A tag is a line starting with @name; its value runs until the next tag
+// or the end of the docstring. The recognized tags are:
+//
+//	@param <operand> <description>
+//	@returns <description>
+//	@example
+//	    <code>
+//	@deprecated [<note, naming the replacement>]
+//	@export [<C name>]
+//
+// Fenced (```) blocks in the free text are moved to the examples as well.
+type Docstring struct {
+	Text           string
+	Params         []Param
+	Returns        string
+	Examples       []string
+	Deprecated     bool
+	DeprecatedNote string
+	Export         bool   // exposed to C by library builds
+	ExportName     string // C name given to @export, if any
+}
+
+// ParseDocstring splits a docstring into text and tags.
+func ParseDocstring(s string) Docstring {
+	var d Docstring
+	var text []string
+	tag, value := "", []string(nil)
+
+	flush := func() {
+		v := strings.TrimRight(strings.Join(value, "\n"), " \t\n")
+		switch tag {
+		case "param":
+			name, desc, _ := strings.Cut(strings.TrimSpace(v), " ")
+			d.Params = append(d.Params, Param{Name: name, Desc: strings.TrimSpace(desc)})
+		case "returns", "return":
+			d.Returns = strings.TrimSpace(v)
+		case "example":
+			d.Examples = append(d.Examples, dedent(strings.Trim(v, "\n")))
+		case "deprecated":
+			d.Deprecated = true
+			d.DeprecatedNote = strings.TrimSpace(v)
+		case "export":
+			d.Export = true
+			d.ExportName = strings.TrimSpace(v)
+		}
+		tag, value = "", nil
+	}
+
+	for _, line := range strings.Split(s, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if name, rest, ok := cutTag(trimmed); ok {
+			flush()
+			tag = name
+			if rest != "" {
+				value = append(value, rest)
+			}
+			continue
+		}
+		if tag != "" {
+			value = append(value, line)
+		} else {
+			text = append(text, line)
+		}
+	}
+	flush()
+
+	prose, fenced := splitFences(strings.Join(text, "\n"))
+	d.Text = prose
+	d.Examples = append(fenced, d.Examples...)
+	return d
+}
+
+// Replacement returns the binding a @deprecated note names as the
+// replacement, which org fix rewrites the uses to: the word after a
+// leading "use", backquoted or not, as in "use `pow` instead". It is ""
+// when the note starts otherwise or the word is not an identifier.
+func Replacement(note string) string {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(note), "use ")
+	if !ok {
+		return ""
+	}
+	rest = strings.TrimSpace(rest)
+	var word string
+	if quoted, ok := strings.CutPrefix(rest, "`"); ok {
+		word, _, ok = strings.Cut(quoted, "`")
+		if !ok {
+			return ""
+		}
+	} else {
+		word = strings.TrimRight(strings.Fields(rest)[0], ".,;")
+	}
+	l := lexer.New([]byte(word))
+	tok := l.NextToken()
+	if tok.Type != token.IDENTIFIER || tok.End != len(word) || len(l.Diagnostics()) > 0 {
+		return ""
+	}
+	return tok.Literal
+}
+
+// knownTags are the tags recognized at the start of a docstring line.
+// Anything else starting with @ (e.g. a resource such as @stdout) is text.
+var knownTags = map[string]bool{
+	"param":      true,
+	"returns":    true,
+	"return":     true,
+	"example":    true,
+	"deprecated": true,
+	"export":     true,
+}
+
+// cutTag recognizes a "@tag rest" line.
+func cutTag(line string) (tag, rest string, ok bool) {
+	if !strings.HasPrefix(line, "@") {
+		return "", "", false
+	}
+	tag, rest, _ = strings.Cut(line[1:], " ")
+	if !knownTags[tag] {
+		return "", "", false
+	}
+	return tag, strings.TrimSpace(rest), true
+}
+
+// dedent removes the common leading whitespace of non-empty lines.
+func dedent(s string) string {
+	lines := strings.Split(s, "\n")
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent <= 0 {
+		return s
+	}
+	for i, line := range lines {
+		if len(line) >= indent {
+			lines[i] = line[indent:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,86 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+
+	"orglang/pkg/ast"
+)
+
+// assert evaluates `assert cond "message"`: cond, if it holds, and
+// otherwise an Error giving the position of cond, the message (cond
+// itself without one) and the values the check failed on: those of the
+// operands of cond when it is an operator call, such as a comparison, or
+// of cond itself, leaving out literals.
+//
+//	assertion failed at line 3:5: right must be positive (right = -3)
+//
+// An Error raised by cond is returned as it is.
+func (in *Interp) assert(n *ast.AssertExpr, env *Env) Value {
+	cond := n.Cond
+	for g, ok := cond.(*ast.GroupExpr); ok; g, ok = cond.(*ast.GroupExpr) {
+		cond = g.Inner
+	}
+
+	var exprs []ast.Expression
+	var values []Value
+	var v Value
+	if ie, ok := cond.(*ast.InfixExpr); ok && !shortCircuit[ie.Op] {
+		l, r := in.eval(ie.Left, env), in.eval(ie.Right, env)
+		for _, operand := range []Value{l, r} {
+			if e, ok := operand.(*Error); ok {
+				return e
+			}
+		}
+		v = in.call(in.eval(&ast.Name{Value: ie.Op}, env), l, r)
+		exprs, values = []ast.Expression{ie.Left, ie.Right}, []Value{l, r}
+	} else {
+		v = in.eval(cond, env)
+		exprs, values = []ast.Expression{cond}, []Value{v}
+	}
+	if e, ok := v.(*Error); ok {
+		return e
+	}
+	if Truthy(v) {
+		return v
+	}
+
+	var b strings.Builder
+	b.WriteString("assertion failed")
+	if pos := n.Cond.Location().Start; pos.Line > 0 {
+		fmt.Fprintf(&b, " at line %d:%d", pos.Line, pos.Column)
+	}
+	msg := cond.String()
+	if n.Message != nil {
+		if m, ok := in.eval(n.Message, env).(String); ok {
+			msg = string(m)
+		}
+	}
+	b.WriteString(": " + msg)
+	var shown []string
+	for i, e := range exprs {
+		if !isLiteral(e) {
+			shown = append(shown, fmt.Sprintf("%s = %s", e, in.Force(values[i])))
+		}
+	}
+	if len(shown) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(shown, ", "))
+	}
+	return &Error{Message: b.String()}
+}
+
+// shortCircuit holds the operators evalInfix does not evaluate both
+// operands of, or not as a call.
+var shortCircuit = map[string]bool{
+	"&&": true, "||": true, "??": true, "?": true, "|>": true, "o": true,
+	"->": true, "-<": true, "-<>": true, "@": true,
+}
+
+func isLiteral(e ast.Expression) bool {
+	switch e.(type) {
+	case *ast.IntegerLiteral, *ast.DecimalLiteral, *ast.RationalLiteral,
+		*ast.QuantityLiteral, *ast.StringLiteral, *ast.BooleanLiteral:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package eval
+
+import (
+	"fmt"
+	"time"
+)
+
+// batch and window are stages: in `source -> batch 10 -> sink` they take
+// the whole source at once, rather than being mapped over its elements,
+// and pass on a table of groups of its elements. The last group is passed
+// on when the source ends, however full it is.
+
+// batch is `batch n`: the stage grouping every n elements.
+func batch(in *Interp, right Value) Value {
+	if e, ok := right.(*Error); ok {
+		return e
+	}
+	n, ok := right.(*Number)
+	if !ok || !n.isInt() || n.Rat.Sign() <= 0 || !n.Rat.Num().IsInt64() {
+		return errorf("batch needs a positive integer, got %s", right)
+	}
+	size := int(n.Rat.Num().Int64())
+	return stage(fmt.Sprintf("(batch %s)", right), func(in *Interp) func(int) bool {
+		return func(filled int) bool { return filled == size }
+	})
+}
+
+// window is `window seconds`: the stage grouping the elements that
+// arrive within seconds of the first one of their group.
+func window(in *Interp, right Value) Value {
+	if e, ok := right.(*Error); ok {
+		return e
+	}
+	d, ok := seconds(right)
+	if !ok {
+		return errorf("window needs a positive number of seconds, got %s", right)
+	}
+	return stage(fmt.Sprintf("(window %s)", right), func(in *Interp) func(int) bool {
+		var start time.Time
+		return func(filled int) bool {
+			now := in.clock()
+			if filled == 0 || now.Sub(start) >= d {
+				start = now
+				return filled > 0
+			}
+			return false
+		}
+	})
+}
+
+// stage returns the stage splitting its source into groups. For each
+// source, split makes a function that is told how many elements the
+// current group holds as each new element arrives, and says whether the
+// group is closed, the element starting the next one.
+func stage(name string, split func(*Interp) func(filled int) bool) *Builtin {
+	return &Builtin{Name: name, stage: true, unary: func(in *Interp, src Value) Value {
+		if e, ok := src.(*Error); ok {
+			return e
+		}
+		t, ok := src.(*Table)
+		if !ok {
+			t = &Table{}
+			t.push(evaluated(src))
+		}
+		closes := split(in)
+		out, cur := &Table{}, &Table{}
+		add := func(k *key, v Value) {
+			if closes(cur.Len()) {
+				out.push(evaluated(cur))
+				cur = &Table{}
+			}
+			if k == nil {
+				cur.push(evaluated(v))
+			} else {
+				cur.set(*k, evaluated(v))
+			}
+			t.progress.tick()
+		}
+		for _, th := range t.items {
+			add(nil, th.force(in))
+		}
+		for _, k := range t.keys {
+			add(&k, t.byKey[k].force(in))
+		}
+		if cur.Len() > 0 {
+			out.push(evaluated(cur))
+		}
+		t.progress.finish()
+		return out
+	}}
+}
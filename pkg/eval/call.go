@@ -0,0 +1,165 @@
+package eval
+
+import (
+	"orglang/pkg/ast"
+	"orglang/pkg/parser"
+)
+
+// Operators take at most two operands. A unary call passes no left
+// operand (nil); inside a block, left is then bound to an Error.
+
+// Block is a function literal together with the scope it was created in.
+type Block struct {
+	Lit *ast.FunctionLiteral
+	env *Env
+
+	// noLeft and noRight are the operands of a call without them, made
+	// once, as printing the block for their messages is costly.
+	noLeft, noRight *Error
+}
+
+func (b *Block) String() string { return b.Lit.String() }
+
+// Builtin is a predefined operator such as + or !, usable as a value:
+// `10 |> +`.
+type Builtin struct {
+	Name   string
+	binary func(in *Interp, left, right Value) Value
+	unary  func(in *Interp, right Value) Value
+	stage  bool       // a flow passes it the whole source, not each element
+	scan   *scanStage // the fold of a stage made by scan
+}
+
+func (b *Builtin) String() string { return b.Name }
+
+// Partial is an operator with one operand fixed by |>: the left operand of
+// a binary operator, or the right operand of a unary one.
+type Partial struct {
+	Op    Value
+	Left  Value
+	Right Value
+}
+
+func (p *Partial) String() string {
+	if p.Right != nil {
+		return "(" + p.Right.String() + " |> " + p.Op.String() + ")"
+	}
+	return "(" + p.Left.String() + " |> " + p.Op.String() + ")"
+}
+
+// Composed is `G o F`: the result of F becomes the right operand of G.
+type Composed struct {
+	G, F Value
+}
+
+func (c *Composed) String() string { return "(" + c.G.String() + " o " + c.F.String() + ")" }
+
+// arity returns 0, 1 or 2 for a callable value and -1 for any other.
+func arity(v Value) int {
+	switch v := v.(type) {
+	case *Block:
+		left, right := parser.Operands(v.Lit)
+		switch {
+		case left:
+			return 2
+		case right:
+			return 1
+		}
+		return 0
+	case *Builtin:
+		if v.binary != nil {
+			return 2
+		}
+		return 1
+	case *Partial:
+		return arity(v.Op) - 1
+	case *Composed:
+		if arity(v.G) == 2 || arity(v.F) == 2 {
+			return 2
+		}
+		return 1
+	}
+	return -1
+}
+
+// maxDepth bounds nested calls, so runaway recursion becomes an Error
+// rather than exhausting the Go stack.
+const maxDepth = 10000
+
+// call applies f to its operands; left is nil for a unary call.
+func (in *Interp) call(f Value, left, right Value) Value {
+	in.depth++
+	defer func() { in.depth-- }()
+	if in.depth > maxDepth {
+		return errorf("call depth exceeds %d", maxDepth)
+	}
+	if err := in.expired(); err != nil {
+		return err
+	}
+
+	switch f := f.(type) {
+	case *Block:
+		env := NewEnv(f.env)
+		if left == nil {
+			if f.noLeft == nil {
+				f.noLeft = errorf("%s has no left operand", f)
+			}
+			left = f.noLeft
+		}
+		if right == nil {
+			if f.noRight == nil {
+				f.noRight = errorf("%s has no right operand", f)
+			}
+			right = f.noRight
+		}
+		env.Set("left", left)
+		env.Set("right", right)
+		env.Set("this", f)
+		return in.evalBody(f.Lit.Body, env)
+	case *Builtin:
+		if left == nil && f.unary != nil {
+			return f.unary(in, right)
+		}
+		if f.binary == nil {
+			return errorf("%s is a prefix operator", f.Name)
+		}
+		if left == nil {
+			return errorf("%s needs a left operand", f.Name)
+		}
+		return f.binary(in, left, right)
+	case *Partial:
+		if f.Right != nil {
+			return in.call(f.Op, nil, f.Right)
+		}
+		return in.call(f.Op, f.Left, right)
+	case *Composed:
+		inner := in.call(f.F, operand(f.F, left), right)
+		return in.call(f.G, operand(f.G, left), inner)
+	case *Error:
+		return f
+	}
+	return errorf("%s is not an operator", f)
+}
+
+// operand is left if f is binary and nil otherwise, following the arity
+// rules of composition.
+func operand(f Value, left Value) Value {
+	if arity(f) == 2 {
+		return left
+	}
+	return nil
+}
+
+// partial implements `left |> op`.
+func partial(left, op Value) Value {
+	if e, ok := left.(*Error); ok {
+		return e
+	}
+	switch arity(op) {
+	case 2:
+		return &Partial{Op: op, Left: left}
+	case 1:
+		return &Partial{Op: op, Right: left}
+	}
+	return errorf("%s takes no operand", op)
+}
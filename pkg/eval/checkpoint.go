@@ -0,0 +1,205 @@
+package eval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A scan is a stateful stage: `init scan op` folds the elements of its
+// source into a state, starting from init, and passes on the state after
+// each one. `"name" checkpoint stage` saves the progress of a scan to a
+// file while it runs, when checkpoints are enabled (Interp.Checkpoints):
+// how many elements it has consumed and its state. A run stopped part way
+// resumes from there the next time: the elements already consumed are
+// skipped without being evaluated, and the states passed on for them are
+// not passed on again. A checkpointed scan stops itself at the first step
+// giving an Error, such as one past its deadline, saving its progress up
+// to the element before, which the next run tries again. A scan that
+// reaches the end of its source removes its checkpoint.
+
+// checkpointInterval is the time between two saves when
+// Interp.CheckpointEvery is zero.
+const checkpointInterval = 5 * time.Second
+
+// scanStage is the fold of a scan stage.
+type scanStage struct {
+	init, op Value
+}
+
+// scan is `init scan op`.
+func scan(in *Interp, left, right Value) Value {
+	if e, ok := left.(*Error); ok {
+		return e
+	}
+	if arity(right) != 2 {
+		return errorf("scan needs a binary operator, got %s", right)
+	}
+	s := &scanStage{init: left, op: right}
+	return &Builtin{Name: fmt.Sprintf("(%s scan %s)", left, right), stage: true, scan: s,
+		unary: func(in *Interp, src Value) Value { return s.run(in, src, nil) }}
+}
+
+// checkpoint is `"name" checkpoint stage`: stage, a scan, saving its
+// progress under name. Without checkpoints enabled it is stage itself.
+func checkpoint(in *Interp, left, right Value) Value {
+	name, err := textOperand("checkpoint", left)
+	if err != nil {
+		return err
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return errorf("checkpoint: bad name %s", left)
+	}
+	if e, ok := right.(*Error); ok {
+		return e
+	}
+	b, ok := right.(*Builtin)
+	if !ok || b.scan == nil {
+		return errorf("checkpoint needs a scan stage, got %s", right)
+	}
+	if in.Checkpoints == "" {
+		return b
+	}
+	s := b.scan
+	every := in.CheckpointEvery
+	if every <= 0 {
+		every = checkpointInterval
+	}
+	return &Builtin{Name: fmt.Sprintf("(%s checkpoint %s)", left, right), stage: true,
+		unary: func(in *Interp, src Value) Value {
+			ck := &saved{path: filepath.Join(in.Checkpoints, name+".ckpt"), every: every, last: in.clock()}
+			return s.run(in, src, ck)
+		}}
+}
+
+// run folds src. With ck, it starts from the progress saved there, saves
+// its own as it goes, and stops at an Error, passing on the states so far
+// followed by the Error.
+func (s *scanStage) run(in *Interp, src Value, ck *saved) Value {
+	if e, ok := src.(*Error); ok {
+		return e
+	}
+	t, ok := src.(*Table)
+	if !ok {
+		t = &Table{}
+		t.push(evaluated(src))
+	}
+	state, skip := s.init, 0
+	if ck != nil {
+		var err *Error
+		if state, skip, err = ck.load(state); err != nil {
+			return err
+		}
+	}
+	out := &Table{}
+	done := 0
+	step := func(k *key, th *thunk) *Error {
+		if done < skip {
+			done++
+			return nil
+		}
+		next := in.call(s.op, state, th.force(in))
+		if e, ok := next.(*Error); ok && ck != nil {
+			if err := ck.save(in, done, state); err != nil {
+				return err
+			}
+			return e
+		}
+		state = next
+		if k == nil {
+			out.push(evaluated(state))
+		} else {
+			out.set(*k, evaluated(state))
+		}
+		done++
+		t.progress.tick()
+		if ck != nil && in.clock().Sub(ck.last) >= ck.every {
+			if err := ck.save(in, done, state); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	stop := func(err *Error) Value {
+		out.push(evaluated(err))
+		t.progress.finish()
+		return out
+	}
+	for _, th := range t.items {
+		if err := step(nil, th); err != nil {
+			return stop(err)
+		}
+	}
+	for _, k := range t.keys {
+		if err := step(&k, t.byKey[k]); err != nil {
+			return stop(err)
+		}
+	}
+	t.progress.finish()
+	if ck != nil {
+		if err := os.Remove(ck.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return errorf("checkpoint: %v", err)
+		}
+	}
+	return out
+}
+
+// saved is the checkpoint file of a scan.
+type saved struct {
+	path  string
+	every time.Duration
+	last  time.Time // of the last save
+}
+
+type savedRecord struct {
+	Consumed int             `json:"consumed"`
+	State    json.RawMessage `json:"state"`
+}
+
+// load returns the state and element count saved, or init and 0 when
+// there is no checkpoint.
+func (ck *saved) load(init Value) (Value, int, *Error) {
+	b, err := os.ReadFile(ck.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return init, 0, nil
+	}
+	if err != nil {
+		return nil, 0, errorf("checkpoint: %v", err)
+	}
+	var r savedRecord
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, 0, errorf("checkpoint: %s: %v", ck.path, err)
+	}
+	state, err := unmarshalValue(r.State)
+	if err != nil {
+		return nil, 0, errorf("checkpoint: %s: %v", ck.path, err)
+	}
+	return state, r.Consumed, nil
+}
+
+// save writes the progress of the scan, replacing the last checkpoint
+// atomically.
+func (ck *saved) save(in *Interp, consumed int, state Value) *Error {
+	ck.last = in.clock()
+	v, err := marshalValue(in, state)
+	if err != nil {
+		return errorf("checkpoint: %s", err.Message)
+	}
+	b, _ := json.Marshal(savedRecord{Consumed: consumed, State: v})
+	if err := os.MkdirAll(filepath.Dir(ck.path), 0o755); err != nil {
+		return errorf("checkpoint: %v", err)
+	}
+	tmp := ck.path + ".tmp"
+	if err := writeSynced(tmp, b, os.O_TRUNC); err != nil {
+		return errorf("checkpoint: %v", err)
+	}
+	if err := os.Rename(tmp, ck.path); err != nil {
+		return errorf("checkpoint: %v", err)
+	}
+	return nil
+}
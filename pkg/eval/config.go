@@ -0,0 +1,423 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+)
+
+// A program's configuration is read by config: `"app" config defaults`
+// is the keyed table defaults overridden, in turn, by
+//
+//   - the config files of app: app/config.org, .json or .toml (the first
+//     found) in each XDG config directory, those of $XDG_CONFIG_DIRS
+//     (default /etc/xdg) from the last, then $XDG_CONFIG_HOME (default
+//     ~/.config); APP_CONFIG or --config=path names a single file instead;
+//   - the environment variables APP_KEY, APP_SECTION_KEY for a key of a
+//     nested table, of the keys already set;
+//   - the flags --key=value, --section.key=value or --key value among the
+//     program's arguments, of the keys already set; a bare --key sets a
+//     Boolean to true. Other arguments are left to the program.
+//
+// Nested tables are merged key by key; any other value replaces the one
+// before. A value from the environment or a flag is text, read as the
+// type of the value it replaces: an Integer or Decimal, a Boolean
+// (true, false, 1 or 0) or a String. APP is app in upper case, with
+// characters other than letters and digits as _; in key names, - in a
+// flag and _ in a variable stand for either.
+//
+// A .org file holds one table literal of data: numbers, strings, booleans
+// and tables, with no operators or names to evaluate. A JSON null leaves
+// the value before it, or is an Error for a new key, as a YAML null is. A
+// file that does not read, or a value that does not convert, makes the
+// whole result an Error naming it.
+
+// configFormats are the extensions of config files, in the order they
+// are looked for in a directory.
+var configFormats = []string{".org", ".json", ".toml"}
+
+// config is `app config defaults`.
+func config(in *Interp, left, right Value) Value {
+	app, err := textOperand("config", left)
+	if err != nil {
+		return err
+	}
+	defaults, ok := right.(*Table)
+	if !ok {
+		if e, isErr := right.(*Error); isErr {
+			return e
+		}
+		return errorf("config needs a table of defaults, got %s", right)
+	}
+	cfg := copyConfig(in, defaults)
+	files, perr := configFiles(app, in.Args)
+	if perr != nil {
+		return errorf("config: %v", perr)
+	}
+	for _, path := range files {
+		v := readConfig(in, path)
+		t, ok := v.(*Table)
+		if !ok {
+			if e, isErr := v.(*Error); isErr {
+				return errorf("config: %s: %s", path, e.Message)
+			}
+			return errorf("config: %s: not a table", path)
+		}
+		cfg = mergeConfig(in, cfg, t)
+	}
+	if e := overrideFromEnv(in, cfg, envPrefix(app)); e != nil {
+		return e
+	}
+	if e := overrideFromFlags(in, cfg, in.Args); e != nil {
+		return e
+	}
+	return cfg
+}
+
+// envPrefix is app as the prefix of its environment variables.
+func envPrefix(app string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, app)
+}
+
+// configFiles returns the config files of app, least important first:
+// the one named by APP_CONFIG or --config, else those found in the XDG
+// config directories.
+func configFiles(app string, args []string) ([]string, error) {
+	named := os.Getenv(envPrefix(app) + "_CONFIG")
+	for i, a := range args {
+		if a == "--" {
+			break
+		}
+		if v, ok := strings.CutPrefix(a, "--config="); ok {
+			named = v
+		} else if a == "--config" && i+1 < len(args) {
+			named = args[i+1]
+		}
+	}
+	if named != "" {
+		if _, err := os.Stat(named); err != nil {
+			return nil, err
+		}
+		return []string{named}, nil
+	}
+
+	dirs := filepath.SplitList(os.Getenv("XDG_CONFIG_DIRS"))
+	if len(dirs) == 0 {
+		dirs = []string{"/etc/xdg"}
+	}
+	home := os.Getenv("XDG_CONFIG_HOME")
+	if home == "" {
+		if h, err := os.UserHomeDir(); err == nil {
+			home = filepath.Join(h, ".config")
+		}
+	}
+	// The first directory is the most important, and home more than any.
+	slices.Reverse(dirs)
+	var files []string
+	for _, dir := range append(dirs, home) {
+		if dir == "" || !filepath.IsAbs(dir) {
+			// The specification ignores relative paths.
+			continue
+		}
+		for _, ext := range configFormats {
+			path := filepath.Join(dir, app, "config"+ext)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				files = append(files, path)
+				break
+			} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+// readConfig reads the config file path by its extension.
+func readConfig(in *Interp, path string) Value {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return errorf("%v", err)
+	}
+	switch filepath.Ext(path) {
+	case ".json":
+		return jsonConfig(src)
+	case ".toml":
+		return tomlParse(in, String(src))
+	}
+	return orgConfig(in, src)
+}
+
+// orgConfig reads the table literal of a .org config file.
+func orgConfig(in *Interp, src []byte) Value {
+	p := parser.New(lexer.New(src), parser.WithStrict(true))
+	prog := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return errorf("%s", errs[0])
+	}
+	if len(prog.Statements) != 1 {
+		return errorf("want one table, got %d statements", len(prog.Statements))
+	}
+	if n := nonData(prog.Statements[0]); n != nil {
+		return errorf("line %d: %s is not data", n.Location().Start.Line, n)
+	}
+	v := in.eval(prog.Statements[0], NewEnv(in.Global))
+	forceDeep(in, v)
+	return v
+}
+
+// nonData returns the first node of e that is not a literal of data, or
+// nil if there is none, so evaluating e runs no code.
+func nonData(e ast.Node) ast.Node {
+	switch n := e.(type) {
+	case *ast.IntegerLiteral, *ast.DecimalLiteral, *ast.RationalLiteral, *ast.StringLiteral, *ast.BooleanLiteral:
+		return nil
+	case *ast.GroupExpr:
+		return nonData(n.Inner)
+	case *ast.TableLiteral:
+		for _, el := range n.Elements {
+			if bad := nonData(el); bad != nil {
+				return bad
+			}
+		}
+		return nil
+	case *ast.BindingExpr:
+		switch n.Name.(type) {
+		case *ast.Name, *ast.StringLiteral, *ast.IntegerLiteral, *ast.BooleanLiteral:
+		default:
+			return n.Name
+		}
+		if n.Operator != ":" {
+			return n
+		}
+		return nonData(n.Value)
+	}
+	return e
+}
+
+// jsonConfig reads a JSON document, keeping the order of its members.
+func jsonConfig(src []byte) Value {
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.UseNumber()
+	v, err := fromJSON(dec)
+	if err == nil {
+		if _, end := dec.Token(); end != io.EOF {
+			err = errors.New("data after the document")
+		}
+	}
+	if err != nil {
+		return errorf("JSON: %v", err)
+	}
+	return v
+}
+
+func fromJSON(dec *json.Decoder) (Value, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case nil:
+		return errorf("null"), nil
+	case bool:
+		return Boolean(tok), nil
+	case string:
+		return String(tok), nil
+	case json.Number:
+		if z, ok := new(big.Int).SetString(tok.String(), 10); ok {
+			return ratNumber(new(big.Rat).SetInt(z)), nil
+		}
+		return decimalText(tok.String()), nil
+	}
+	t := &Table{}
+	for dec.More() {
+		var k Value
+		if tok == json.Delim('{') {
+			if k, err = fromJSON(dec); err != nil {
+				return nil, err
+			}
+		}
+		v, err := fromJSON(dec)
+		if err != nil {
+			return nil, err
+		}
+		if k == nil {
+			t.push(evaluated(v))
+		} else {
+			t.set(key{'s', string(k.(String))}, evaluated(v))
+		}
+	}
+	_, err = dec.Token()
+	return t, err
+}
+
+// mergeConfig returns base overridden by over: keyed tables in both are
+// merged, other values of over replace those of base but for a null (an
+// Error), and positional elements of over replace all of those of base.
+func mergeConfig(in *Interp, base, over *Table) *Table {
+	t := &Table{items: base.items}
+	if len(over.items) > 0 {
+		t.items = over.items
+	}
+	for _, k := range base.keys {
+		t.set(k, base.byKey[k])
+	}
+	for _, k := range over.keys {
+		v := over.byKey[k].force(in)
+		old, ok := t.byKey[k]
+		if _, isNull := v.(*Error); isNull && ok {
+			continue
+		}
+		if ok {
+			a, aok := old.force(in).(*Table)
+			b, bok := v.(*Table)
+			if aok && bok && len(a.keys) > 0 && len(b.keys) > 0 {
+				v = mergeConfig(in, a, b)
+			}
+		}
+		t.set(k, evaluated(v))
+	}
+	return t
+}
+
+// copyConfig returns a copy of t and of the tables in it, which the
+// overrides can set without changing t.
+func copyConfig(in *Interp, t *Table) *Table {
+	c := &Table{}
+	for _, th := range t.items {
+		c.push(evaluated(th.force(in)))
+	}
+	for _, k := range t.keys {
+		v := t.byKey[k].force(in)
+		if sub, ok := v.(*Table); ok {
+			v = copyConfig(in, sub)
+		}
+		c.set(k, evaluated(v))
+	}
+	return c
+}
+
+// configLeaves calls f with the path of each keyed value of t that is not
+// itself a keyed table, depth first in key order.
+func configLeaves(in *Interp, t *Table, path []string, f func(path []string, t *Table, k key) *Error) *Error {
+	for _, k := range t.keys {
+		if k.kind != 's' {
+			continue
+		}
+		p := append(path[:len(path):len(path)], k.s)
+		if sub, ok := t.byKey[k].force(in).(*Table); ok && len(sub.keys) > 0 {
+			if err := configLeaves(in, sub, p, f); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := f(p, t, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func overrideFromEnv(in *Interp, cfg *Table, prefix string) *Error {
+	return configLeaves(in, cfg, nil, func(path []string, t *Table, k key) *Error {
+		name := prefix + "_" + envPrefix(strings.Join(path, "_"))
+		text, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		v, err := configText(text, t.byKey[k].force(in))
+		if err != nil {
+			return errorf("config: %s: %s", name, err.Message)
+		}
+		t.set(k, evaluated(v))
+		return nil
+	})
+}
+
+func overrideFromFlags(in *Interp, cfg *Table, args []string) *Error {
+	type leaf struct {
+		t *Table
+		k key
+	}
+	leaves := map[string]leaf{}
+	configLeaves(in, cfg, nil, func(path []string, t *Table, k key) *Error {
+		leaves[flagName(strings.Join(path, "."))] = leaf{t, k}
+		return nil
+	})
+	for i := 0; i < len(args) && args[i] != "--"; i++ {
+		name, ok := strings.CutPrefix(args[i], "--")
+		if !ok {
+			continue
+		}
+		name, text, hasValue := strings.Cut(name, "=")
+		l, known := leaves[flagName(name)]
+		if !known {
+			continue
+		}
+		old := l.t.byKey[l.k].force(in)
+		if !hasValue {
+			if _, isBool := old.(Boolean); isBool {
+				text = "true"
+			} else if i+1 < len(args) {
+				i++
+				text = args[i]
+			} else {
+				return errorf("config: --%s needs a value", name)
+			}
+		}
+		v, err := configText(text, old)
+		if err != nil {
+			return errorf("config: --%s: %s", name, err.Message)
+		}
+		l.t.set(l.k, evaluated(v))
+	}
+	return nil
+}
+
+// flagName folds the spellings of a key in a flag: - and _ are the same.
+func flagName(s string) string { return strings.ReplaceAll(s, "-", "_") }
+
+// configText reads text as a value of the type of old.
+func configText(text string, old Value) (Value, *Error) {
+	switch old := old.(type) {
+	case Boolean:
+		switch strings.ToLower(text) {
+		case "true", "1":
+			return Boolean(true), nil
+		case "false", "0":
+			return Boolean(false), nil
+		}
+		return nil, errorf("%q is not a Boolean", text)
+	case *Number:
+		if z, ok := new(big.Int).SetString(text, 10); ok {
+			return ratNumber(new(big.Rat).SetInt(z)), nil
+		}
+		if v, ok := decimalText(text).(*Number); ok {
+			return v, nil
+		}
+		return nil, errorf("%q is not a number", text)
+	case String, *Error:
+		return String(text), nil
+	default:
+		return nil, errorf("%s cannot be set from text", fmt.Sprint(old))
+	}
+}
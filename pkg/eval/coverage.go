@@ -0,0 +1,155 @@
+package eval
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"orglang/pkg/ast"
+)
+
+// Coverage records which statements an interpreter ran, for org test
+// --coverage: those at the top level of a program and those in the
+// bodies of its blocks. One Coverage may serve several programs.
+type Coverage struct {
+	ran map[ast.Statement]bool
+}
+
+// NewCoverage returns an empty Coverage.
+func NewCoverage() *Coverage {
+	return &Coverage{ran: map[ast.Statement]bool{}}
+}
+
+// mark records that s ran; it does nothing on a nil Coverage.
+func (c *Coverage) mark(s ast.Statement) {
+	if c != nil {
+		c.ran[s] = true
+	}
+}
+
+// StatementCoverage tells whether one statement ran.
+type StatementCoverage struct {
+	Span ast.Span `json:"span"`
+	Ran  bool     `json:"ran"`
+}
+
+// Statements returns the statements of prog, at its top level and in the
+// bodies of its blocks, nested or not, in source order, and whether each
+// ran. Statements without a position, which the parser did not read from
+// the source, are left out.
+func (c *Coverage) Statements(prog *ast.Program) []StatementCoverage {
+	var out []StatementCoverage
+	add := func(body []ast.Statement) {
+		for _, s := range body {
+			if !s.Location().IsZero() {
+				out = append(out, StatementCoverage{Span: s.Location(), Ran: c.ran[s]})
+			}
+		}
+	}
+	add(prog.Statements)
+	for _, s := range prog.Statements {
+		ast.Inspect(s, func(n ast.Node) bool {
+			if fl, ok := n.(*ast.FunctionLiteral); ok {
+				add(fl.Body)
+			}
+			return true
+		})
+	}
+	slices.SortStableFunc(out, func(a, b StatementCoverage) int {
+		return cmp.Compare(a.Span.Start.Offset, b.Span.Start.Offset)
+	})
+	return out
+}
+
+// CoveredCount returns how many of stmts ran, out of how many.
+func CoveredCount(stmts []StatementCoverage) (ran, total int) {
+	for _, s := range stmts {
+		if s.Ran {
+			ran++
+		}
+	}
+	return ran, len(stmts)
+}
+
+// CoveredLines returns, by line, whether the statements starting on it
+// ran: true if they all did, false if one did not. Lines on which no
+// statement starts are absent.
+func CoveredLines(stmts []StatementCoverage) map[int]bool {
+	lines := map[int]bool{}
+	for _, s := range stmts {
+		line := s.Span.Start.Line
+		if ran, ok := lines[line]; !ok || ran {
+			lines[line] = s.Ran
+		}
+	}
+	return lines
+}
+
+// Profile is the coverage of one module, saved by org test --coverage for
+// org lsp to show. Hash identifies the source it was measured on: the
+// positions of the statements only hold for that text.
+type Profile struct {
+	Source     string              `json:"source"` // absolute path
+	Hash       string              `json:"hash"`
+	Statements []StatementCoverage `json:"statements"`
+}
+
+// SourceHash returns the Hash of the profile of src.
+func SourceHash(src []byte) string {
+	sum := sha256.Sum256(src)
+	return hex.EncodeToString(sum[:])
+}
+
+// ProfileFile returns the file, in dir, holding the profile of the module
+// at the absolute path source: one file per module, named by a hash of
+// its path.
+func ProfileFile(dir, source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])[:16]+".json")
+}
+
+// SaveProfile writes p to its file in dir, replacing the profile of an
+// earlier run, through a temporary file renamed into place.
+func SaveProfile(dir string, p *Profile) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	path := ProfileFile(dir, p.Source)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// LoadProfile returns the profile of the module at the absolute path
+// source saved in dir, or nil if there is none or it cannot be read.
+func LoadProfile(dir, source string) *Profile {
+	data, err := os.ReadFile(ProfileFile(dir, source))
+	if err != nil {
+		return nil
+	}
+	var p Profile
+	if json.Unmarshal(data, &p) != nil || p.Source != source {
+		return nil
+	}
+	return &p
+}
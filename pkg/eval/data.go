@@ -0,0 +1,191 @@
+package eval
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"orglang/pkg/yaml"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Configuration formats are read into nested tables: mappings become
+// keyed tables in document order, sequences positional tables,
+// integers Integers and other numbers exact Decimals. A null (YAML only) becomes an
+// Error, as an absent operand does, so `cfg.port ?? 8080` supplies a
+// default. Syntax errors are Errors naming the line.
+
+// yamlParse is yaml_parse: the value of the YAML document right.
+func yamlParse(_ *Interp, right Value) Value {
+	src, err := textOperand("yaml_parse", right)
+	if err != nil {
+		return err
+	}
+	doc, perr := yaml.Parse([]byte(src))
+	if perr != nil {
+		return errorf("yaml_parse: %v", perr)
+	}
+	return fromYAML(doc)
+}
+
+func fromYAML(v any) Value {
+	switch v := v.(type) {
+	case nil:
+		return errorf("null")
+	case bool:
+		return Boolean(v)
+	case string:
+		return String(v)
+	case *big.Int:
+		return ratNumber(new(big.Rat).SetInt(v))
+	case yaml.Float:
+		return decimalText(string(v))
+	case []any:
+		t := &Table{}
+		for _, x := range v {
+			t.push(evaluated(fromYAML(x)))
+		}
+		return t
+	case *yaml.Mapping:
+		t := &Table{}
+		for i, k := range v.Keys {
+			key, ok := keyOf(fromYAML(k))
+			if !ok {
+				return errorf("yaml_parse: key %v cannot be a table key", k)
+			}
+			t.set(key, evaluated(fromYAML(v.Values[i])))
+		}
+		return t
+	}
+	return errorf("yaml_parse: unexpected %T", v)
+}
+
+// tomlParse is toml_parse: the table of the TOML document right.
+func tomlParse(_ *Interp, right Value) Value {
+	src, err := textOperand("toml_parse", right)
+	if err != nil {
+		return err
+	}
+	var doc map[string]any
+	md, terr := toml.Decode(src, &doc)
+	if terr != nil {
+		return errorf("toml_parse: %s", strings.TrimPrefix(terr.Error(), "toml: "))
+	}
+	// Decode returns maps; the metadata keeps the document order.
+	order := map[string]int{}
+	for i, k := range md.Keys() {
+		if _, seen := order[k.String()]; !seen {
+			order[k.String()] = i
+		}
+	}
+	return fromTOML(doc, "", order)
+}
+
+func fromTOML(v any, path string, order map[string]int) Value {
+	switch v := v.(type) {
+	case bool:
+		return Boolean(v)
+	case string:
+		return String(v)
+	case int64:
+		return Int(v)
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return errorf("toml_parse: %s: %v has no exact value", path, v)
+		}
+		return decimalText(strconv.FormatFloat(v, 'f', -1, 64))
+	case time.Time:
+		return String(tomlTime(v))
+	case []any:
+		t := &Table{}
+		for _, x := range v {
+			t.push(evaluated(fromTOML(x, path, order)))
+		}
+		return t
+	case []map[string]any:
+		t := &Table{}
+		for _, x := range v {
+			t.push(evaluated(fromTOML(x, path, order)))
+		}
+		return t
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sub := func(k string) string {
+			if path == "" {
+				return toml.Key{k}.String()
+			}
+			return path + "." + toml.Key{k}.String()
+		}
+		pos := func(k string) int {
+			if i, ok := order[sub(k)]; ok {
+				return i
+			}
+			return len(order)
+		}
+		sort.SliceStable(keys, func(i, j int) bool {
+			pi, pj := pos(keys[i]), pos(keys[j])
+			if pi != pj {
+				return pi < pj
+			}
+			return keys[i] < keys[j]
+		})
+		t := &Table{}
+		for _, k := range keys {
+			t.set(key{'s', k}, evaluated(fromTOML(v[k], sub(k), order)))
+		}
+		return t
+	}
+	return errorf("toml_parse: %s: unexpected %T", path, v)
+}
+
+// tomlTime formats a TOML date or time as written in TOML. The decoder
+// marks local dates and times with zones of these names.
+func tomlTime(t time.Time) string {
+	switch t.Location().String() {
+	case "date-local":
+		return t.Format("2006-01-02")
+	case "time-local":
+		return t.Format("15:04:05.999999999")
+	case "datetime-local":
+		return t.Format("2006-01-02T15:04:05.999999999")
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// textOperand returns the String operand of a parsing builtin.
+func textOperand(name string, v Value) (string, *Error) {
+	switch v := v.(type) {
+	case *Error:
+		return "", v
+	case String:
+		return string(v), nil
+	}
+	return "", errorf("%s needs a String, got %s", name, v)
+}
+
+// decimalText returns the exact Decimal written as s, which may have an
+// exponent; its scale is the number of digits after the point, less the
+// exponent.
+func decimalText(s string) Value {
+	q, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return errorf("invalid number %s", s)
+	}
+	mant, exp := strings.ToLower(s), 0
+	if i := strings.IndexByte(mant, 'e'); i >= 0 {
+		exp, _ = strconv.Atoi(mant[i+1:])
+		mant = mant[:i]
+	}
+	scale := 0
+	if i := strings.IndexByte(mant, '.'); i >= 0 {
+		scale = len(mant) - i - 1
+	}
+	return decNumber(q, max(scale-exp, 0))
+}
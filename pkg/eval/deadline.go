@@ -0,0 +1,71 @@
+package eval
+
+import (
+	"math"
+	"time"
+)
+
+// A deadline cancels evaluation cooperatively: every operator call checks
+// it, so a pipeline past its deadline stops at the next stage, which
+// returns an Error that the flow carries out like any other.
+
+// clock returns the current time of in.
+func (in *Interp) clock() time.Time {
+	if in.now != nil {
+		return in.now()
+	}
+	return time.Now()
+}
+
+// expired returns the Error of a passed deadline, or nil.
+func (in *Interp) expired() *Error {
+	if in.Deadline.IsZero() || in.clock().Before(in.Deadline) {
+		return nil
+	}
+	return errorf("deadline exceeded")
+}
+
+// seconds reads a positive number of seconds, or a positive Duration
+// quantity, as a duration, capped at the longest one.
+func seconds(v Value) (time.Duration, bool) {
+	if q, ok := v.(*Quantity); ok && q.Kind == Duration {
+		v = q.Amount
+	}
+	n, ok := v.(*Number)
+	if !ok || n.Rat.Sign() <= 0 {
+		return 0, false
+	}
+	secs, _ := n.Rat.Float64()
+	d := time.Duration(math.MaxInt64)
+	if secs < d.Seconds() {
+		d = time.Duration(secs * float64(time.Second))
+	}
+	return d, true
+}
+
+// deadline is `seconds deadline op`: op called with no operands, and its
+// result evaluated in full, within seconds from now or the deadline
+// already in force, whichever comes first.
+func deadline(in *Interp, left, right Value) Value {
+	if e, ok := left.(*Error); ok {
+		return e
+	}
+	d, ok := seconds(left)
+	if !ok {
+		return errorf("deadline needs a positive number of seconds, got %s", left)
+	}
+	if arity(right) < 0 {
+		return errorf("deadline needs an operator, got %s", right)
+	}
+	outer := in.Deadline
+	if at := in.clock().Add(d); outer.IsZero() || at.Before(outer) {
+		in.Deadline = at
+	}
+	defer func() { in.Deadline = outer }()
+	v := in.call(right, nil, nil)
+	forceDeep(in, v)
+	if err := in.expired(); err != nil {
+		return err
+	}
+	return v
+}
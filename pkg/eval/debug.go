@@ -0,0 +1,67 @@
+package eval
+
+import (
+	"sort"
+
+	"orglang/pkg/ast"
+)
+
+// Debugger stops a program between statements, for org debug: Stop is
+// called before each statement the interpreter runs, at the top level and
+// in the blocks it calls, and the statement runs once it returns. While
+// Stop runs, values it forces (see Lookup) evaluate without stopping.
+type Debugger interface {
+	Stop(s ast.Statement, f Frame)
+}
+
+// Frame is where a stopped program is: the scope the statement runs in
+// and how many block calls deep it is, 0 at the top level.
+type Frame struct {
+	Env   *Env
+	Depth int
+}
+
+// stop hands s to the debugger, if there is one and it is not already
+// stopped.
+func (in *Interp) stop(s ast.Statement, env *Env) {
+	if in.Debugger == nil || in.stopped {
+		return
+	}
+	in.stopped = true
+	defer func() { in.stopped = false }()
+	in.Debugger.Stop(s, Frame{Env: env, Depth: in.depth})
+}
+
+// Names returns the names bound in this scope, not those around it,
+// sorted.
+func (e *Env) Names() []string {
+	names := make([]string, 0, len(e.vars))
+	for name := range e.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parent returns the enclosing scope, nil for the outermost.
+func (e *Env) Parent() *Env { return e.parent }
+
+// Evaluated returns the value of name in this scope or those around it
+// without evaluating it: pending is true for a table entry not yet used.
+func (e *Env) Evaluated(name string) (v Value, pending, ok bool) {
+	th, ok := e.lookup(name)
+	if !ok {
+		return nil, false, false
+	}
+	return th.value, th.value == nil, true
+}
+
+// Lookup returns the value of name in env or the scopes around it,
+// evaluating it if it was not yet.
+func (in *Interp) Lookup(env *Env, name string) (Value, bool) {
+	th, ok := env.lookup(name)
+	if !ok {
+		return nil, false
+	}
+	return in.Force(th.force(in)), true
+}
@@ -0,0 +1,38 @@
+package eval
+
+// Env is a scope: the bindings of a file, a block call or a table
+// literal, with a link to the enclosing scope.
+type Env struct {
+	vars   map[string]*thunk
+	parent *Env
+}
+
+// NewEnv returns an empty scope inside parent (nil for the outermost).
+func NewEnv(parent *Env) *Env {
+	return &Env{vars: make(map[string]*thunk), parent: parent}
+}
+
+// Set binds name to v in this scope, shadowing outer bindings.
+func (e *Env) Set(name string, v Value) {
+	e.vars[name] = evaluated(v)
+}
+
+func (e *Env) lookup(name string) (*thunk, bool) {
+	for s := e; s != nil; s = s.parent {
+		if th, ok := s.vars[name]; ok {
+			return th, true
+		}
+	}
+	return nil, false
+}
+
+// assign rebinds name where it is defined, or here if it is not.
+func (e *Env) assign(name string, v Value) {
+	for s := e; s != nil; s = s.parent {
+		if _, ok := s.vars[name]; ok {
+			s.Set(name, v)
+			return
+		}
+	}
+	e.Set(name, v)
+}
@@ -0,0 +1,549 @@
+// Package eval is a tree-walking evaluator for OrgLang, used by the REPL.
+//
+// It follows the semantics of the README and the numeric rules of the C
+// runtime (pkg/runtime/ops). Table entries are lazy; bindings in a file or
+// block scope are evaluated when they are made, so the REPL reports their
+// errors at once. Modules (infix @) are loaded through Interp.Modules. The
+// concurrent flows -< and -<> are not supported and evaluate to an Error.
+package eval
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"orglang/pkg/ast"
+)
+
+// Interp holds the global scope and the streams of the built-in
+// resources. The zero value is not usable; call New.
+type Interp struct {
+	Global   *Env
+	Stdin    io.Reader // @stdin, read a line at a time
+	Stdout   io.Writer
+	Stderr   io.Writer
+	Args     []string  // @args
+	TTY      Terminal  // @tty; nil opens the controlling terminal on first use
+	Color    bool      // fg, bg, bold and underline emit ANSI escapes
+	Progress bool      // @progress draws on Stderr
+	Deadline time.Time // calls past it return an Error; zero for none
+
+	// Checkpoints is the directory where checkpoint stages save their
+	// progress, every CheckpointEvery (zero for 5s); "" disables them.
+	Checkpoints     string
+	CheckpointEvery time.Duration
+
+	// Coverage, if set, records the statements that run.
+	Coverage *Coverage
+	// Debugger, if set, is given each statement before it runs.
+	Debugger Debugger
+
+	// Modules, if set, reads the modules imported with infix @; File is
+	// the path of the program evaluated, from which they are resolved.
+	// Without Modules, an import evaluates to an Error.
+	Modules Loader
+	File    string
+
+	now     func() time.Time    // clock of @progress, deadline and throttle; nil is time.Now
+	sleepFn func(time.Duration) // pause of throttle; nil is time.Sleep
+	stores  map[string]*kvStore // stores opened by kv_open, by absolute path
+	modules map[string]*Table   // imported modules by path; nil while loading
+	stdin   *bufio.Reader       // Stdin, buffered on first use
+
+	depth   int
+	stopped bool // in Debugger.Stop
+}
+
+// New returns an interpreter with the built-in operators bound in its
+// global scope, reading @stdin from and writing @stdout and @stderr to
+// the process streams, styling text if the environment allows color
+// (colorEnabled) and drawing @progress if stderr is a terminal.
+func New() *Interp {
+	in := &Interp{Global: NewEnv(nil), Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr,
+		Color: colorEnabled(), Progress: stderrIsTerminal()}
+	for _, b := range builtins() {
+		in.Global.Set(b.Name, b)
+	}
+	return in
+}
+
+// Eval evaluates node in the global scope. A program evaluates to the
+// value of its last statement, or nil if it has none.
+func (in *Interp) Eval(node ast.Node) Value {
+	if prog, ok := node.(*ast.Program); ok {
+		var v Value
+		for _, s := range prog.Statements {
+			in.Coverage.mark(s)
+			in.stop(s, in.Global)
+			v = in.eval(s, in.Global)
+		}
+		return v
+	}
+	return in.eval(node, in.Global)
+}
+
+// Force evaluates the entries of v, if it is a table, so that printing
+// it shows values rather than pending expressions.
+func (in *Interp) Force(v Value) Value {
+	forceDeep(in, v)
+	return v
+}
+
+func (in *Interp) evalBody(body []ast.Statement, env *Env) Value {
+	var v Value = &Table{}
+	for _, s := range body {
+		in.Coverage.mark(s)
+		in.stop(s, env)
+		if g, ok := s.(*ast.GuardExpr); ok {
+			cond := in.eval(g.Cond, env)
+			if _, isErr := cond.(*Error); isErr {
+				return cond
+			}
+			if Truthy(cond) {
+				return in.eval(g.Value, env)
+			}
+			v = cond
+			continue
+		}
+		if a, ok := s.(*ast.AssertExpr); ok {
+			if v = in.assert(a, env); isError(v) {
+				return v
+			}
+			continue
+		}
+		v = in.eval(s, env)
+	}
+	return v
+}
+
+func (in *Interp) eval(node ast.Node, env *Env) Value {
+	switch n := node.(type) {
+	case *ast.IntegerLiteral:
+		z, ok := new(big.Int).SetString(n.Value, 10)
+		if !ok {
+			return errorf("invalid integer %s", n.Value)
+		}
+		return ratNumber(new(big.Rat).SetInt(z))
+	case *ast.DecimalLiteral:
+		q, ok := new(big.Rat).SetString(n.Value)
+		if !ok {
+			return errorf("invalid decimal %s", n.Value)
+		}
+		return decNumber(q, len(n.Value)-strings.IndexByte(n.Value, '.')-1)
+	case *ast.QuantityLiteral:
+		q, err := quantity(n.Value, n.Unit)
+		if err != nil {
+			return err
+		}
+		return q
+	case *ast.RationalLiteral:
+		num, ok1 := new(big.Int).SetString(n.Numerator, 10)
+		den, ok2 := new(big.Int).SetString(n.Denominator, 10)
+		if !ok1 || !ok2 {
+			return errorf("invalid rational %s", n)
+		}
+		if den.Sign() == 0 {
+			return errorf("division by zero")
+		}
+		return ratNumber(new(big.Rat).SetFrac(num, den))
+	case *ast.StringLiteral:
+		return String(n.Value)
+	case *ast.InterpolatedString:
+		var b strings.Builder
+		for i, text := range n.Texts {
+			b.WriteString(text)
+			if i < len(n.Exprs) {
+				v := in.eval(n.Exprs[i], env)
+				if isError(v) {
+					return v
+				}
+				forceDeep(in, v)
+				b.WriteString(Display(v))
+			}
+		}
+		return String(b.String())
+	case *ast.BooleanLiteral:
+		return Boolean(n.Value)
+	case *ast.ErrorExpr:
+		return &Error{Message: n.Message}
+	case *ast.Name:
+		if th, ok := env.lookup(n.Value); ok {
+			return th.force(in)
+		}
+		return errorf("undefined identifier: %s", n.Value)
+	case *ast.GroupExpr:
+		return in.eval(n.Inner, env)
+	case *ast.FunctionLiteral:
+		return &Block{Lit: n, env: env}
+	case *ast.TableLiteral:
+		return in.evalTable(n.Elements, env)
+	case *ast.CommaExpr:
+		return in.evalTable(commaElements(n, nil), env)
+	case *ast.BindingExpr:
+		return in.evalBinding(n, env)
+	case *ast.ResourceDef:
+		v := in.eval(n.Value, env)
+		env.Set(bindingName(n.Name), v)
+		return v
+	case *ast.ResourceInst:
+		return in.resource(n.Name, env)
+	case *ast.DotExpr:
+		return in.evalDot(n, env)
+	case *ast.GuardExpr:
+		return errorf("!> must be a statement of a block, returning from it")
+	case *ast.AssertExpr:
+		return in.assert(n, env)
+	case *ast.ElvisExpr:
+		if l := in.eval(n.Left, env); Truthy(l) {
+			return l
+		}
+		return in.eval(n.Right, env)
+	case *ast.PrefixExpr:
+		if n.Op == "@" {
+			return in.resource(n.Right, env)
+		}
+		op := in.eval(&ast.Name{Value: n.Op}, env)
+		return in.call(op, nil, in.eval(n.Right, env))
+	case *ast.InfixExpr:
+		return in.evalInfix(n, env)
+	case *ast.Program:
+		return in.evalBody(n.Statements, env)
+	}
+	return errorf("cannot evaluate %s", node)
+}
+
+func (in *Interp) evalInfix(n *ast.InfixExpr, env *Env) Value {
+	switch n.Op {
+	case "&&", "||":
+		l := in.eval(n.Left, env)
+		if e, ok := l.(*Error); ok {
+			return e
+		}
+		if Truthy(l) != (n.Op == "&&") {
+			return Boolean(n.Op == "||")
+		}
+		r := in.eval(n.Right, env)
+		if e, ok := r.(*Error); ok {
+			return e
+		}
+		return Boolean(Truthy(r))
+	case "??":
+		if l := in.eval(n.Left, env); !isError(l) {
+			return l
+		}
+		return in.eval(n.Right, env)
+	case "?":
+		return in.selectKey(in.eval(n.Left, env), in.eval(n.Right, env))
+	case "|>":
+		return partial(in.eval(n.Left, env), in.eval(n.Right, env))
+	case "o":
+		return &Composed{G: in.eval(n.Left, env), F: in.eval(n.Right, env)}
+	case "->":
+		return in.evalFlow(n, env)
+	case "-<", "-<>":
+		return errorf("%s is not supported by the interpreter", n.Op)
+	case "@":
+		return in.importModule(n)
+	}
+	op := in.eval(&ast.Name{Value: n.Op}, env)
+	return in.call(op, in.eval(n.Left, env), in.eval(n.Right, env))
+}
+
+func isError(v Value) bool {
+	_, ok := v.(*Error)
+	return ok
+}
+
+// evalBinding binds a name in env; an extended assignment (x :+ 1)
+// combines the current value with the operator after the colon.
+func (in *Interp) evalBinding(n *ast.BindingExpr, env *Env) Value {
+	name := bindingName(n.Name)
+	v := in.eval(n.Value, env)
+	if pattern, ok := n.Name.(*ast.TableLiteral); ok && (n.Operator == "" || n.Operator == ":") {
+		return in.destructure(pattern, v, env)
+	}
+	if n.Operator == "" || n.Operator == ":" {
+		env.Set(name, v)
+		return v
+	}
+
+	op := strings.TrimPrefix(n.Operator, ":")
+	if op == ">>>" {
+		op = ">>"
+	}
+	cur := in.eval(&ast.Name{Value: name}, env)
+	if op == "~" {
+		v = in.call(in.eval(&ast.Name{Value: op}, env), nil, v)
+	} else {
+		v = in.call(in.eval(&ast.Name{Value: op}, env), cur, v)
+	}
+	env.assign(name, v)
+	return v
+}
+
+// destructure binds the names of `[a b] : v` to the unkeyed elements of
+// the table v in order, each still unevaluated if it was. A v that is not
+// a table of as many is an Error, which the names are bound to as well.
+func (in *Interp) destructure(pattern *ast.TableLiteral, v Value, env *Env) Value {
+	t, ok := v.(*Table)
+	if !ok || len(t.items) != len(pattern.Elements) {
+		switch got := v.(type) {
+		case *Error:
+		case *Table:
+			v = errorf("%s needs a table of %d unkeyed elements, got %d", pattern, len(pattern.Elements), len(got.items))
+		default:
+			v = errorf("%s needs a table of %d unkeyed elements, got %s", pattern, len(pattern.Elements), v)
+		}
+		for _, el := range pattern.Elements {
+			env.Set(bindingName(el), v)
+		}
+		return v
+	}
+	for i, el := range pattern.Elements {
+		env.vars[bindingName(el)] = t.items[i]
+	}
+	return v
+}
+
+// bindingName returns the name bound by `name : value`; keys written as
+// literals ("status": 1, true: 1) are bound under their text.
+func bindingName(e ast.Expression) string {
+	switch e := e.(type) {
+	case *ast.Name:
+		return e.Value
+	case *ast.StringLiteral:
+		return e.Value
+	}
+	return e.String()
+}
+
+// bindingKey returns the table key of `k : value` inside a table.
+func (in *Interp) bindingKey(k ast.Expression, env *Env) (key, *Error) {
+	var v Value
+	switch k := k.(type) {
+	case *ast.Name:
+		v = String(k.Value)
+	default:
+		v = in.eval(k, env)
+	}
+	if e, ok := v.(*Error); ok {
+		return key{}, e
+	}
+	kk, ok := keyOf(v)
+	if !ok {
+		return key{}, errorf("%s cannot be a table key", v)
+	}
+	return kk, nil
+}
+
+// evalTable builds a table from its elements without evaluating them.
+// Bindings become keyed entries, visible by name to the other elements.
+func (in *Interp) evalTable(elems []ast.Expression, env *Env) Value {
+	t := &Table{}
+	scope := NewEnv(env)
+	for _, e := range elems {
+		var k ast.Expression
+		var val ast.Expression
+		switch b := e.(type) {
+		case *ast.BindingExpr:
+			if b.Operator == "" || b.Operator == ":" {
+				k, val = b.Name, b.Value
+			}
+		case *ast.ResourceDef:
+			k, val = b.Name, b.Value
+		}
+		if k == nil {
+			t.push(&thunk{expr: e, env: scope})
+			continue
+		}
+		kk, err := in.bindingKey(k, env)
+		if err != nil {
+			return err
+		}
+		th := &thunk{expr: val, env: scope}
+		t.set(kk, th)
+		if kk.kind == 's' {
+			scope.vars[kk.s] = th
+		}
+	}
+	return t
+}
+
+// commaElements flattens a chain of commas: 1, 2, 3 is one table.
+func commaElements(e ast.Expression, out []ast.Expression) []ast.Expression {
+	if c, ok := e.(*ast.CommaExpr); ok {
+		return commaElements(c.Right, commaElements(c.Left, out))
+	}
+	return append(out, e)
+}
+
+func (in *Interp) evalDot(n *ast.DotExpr, env *Env) Value {
+	left := in.eval(n.Left, env)
+	if e, ok := left.(*Error); ok {
+		return e
+	}
+	var k Value
+	switch key := n.Key.(type) {
+	case *ast.Name:
+		k = String(key.Value)
+	default:
+		k = in.eval(key, env)
+	}
+	return in.index(left, k)
+}
+
+// index returns the entry k of a table or the codepoint k of a string.
+func (in *Interp) index(v, k Value) Value {
+	if e, ok := k.(*Error); ok {
+		return e
+	}
+	kk, ok := keyOf(k)
+	if !ok {
+		return errorf("%s cannot be a table key", k)
+	}
+	switch v := v.(type) {
+	case *Table:
+		if th, ok := v.get(kk); ok {
+			return th.force(in)
+		}
+	case String:
+		if i, err := strconv.Atoi(kk.s); kk.kind == 'i' && err == nil {
+			for _, r := range string(v) {
+				if i == 0 {
+					return String(r)
+				}
+				i--
+			}
+		}
+	default:
+		return errorf("%s is not a table", v)
+	}
+	return errorf("no key %s", kk)
+}
+
+// selectKey is `cond ? table`: the entry of table keyed by cond. Only the
+// selected entry is evaluated.
+func (in *Interp) selectKey(cond, table Value) Value {
+	if e, ok := cond.(*Error); ok {
+		return e
+	}
+	if e, ok := table.(*Error); ok {
+		return e
+	}
+	return in.index(table, cond)
+}
+
+// flow is `source -> sink`. A resource sink writes the source, one line
+// per element of a table; an operator sink is called with the source as
+// its right operand, or mapped over the elements of a table source,
+// unless it is a stage such as batch, which takes the source whole.
+// @progress passes the source on, tracking the next flow; a @kv store
+// takes the entries of the source as kv_put does. A flow from @stdin is
+// streamed (see stream).
+func (in *Interp) flow(src, sink Value) Value {
+	if e, ok := sink.(*Error); ok {
+		return e
+	}
+	if isStdin(src) {
+		return in.stream([]Value{sink})
+	}
+	if r, ok := sink.(*Resource); ok {
+		if r.Name == "progress" {
+			return in.track(src)
+		}
+		if r.store != nil {
+			return kvPut(in, r, src)
+		}
+		return in.write(r, src)
+	}
+	if arity(sink) < 0 {
+		return errorf("%s is not a sink", sink)
+	}
+	t, ok := src.(*Table)
+	if b, isStage := sink.(*Builtin); !ok || isStage && b.stage {
+		return in.call(sink, nil, src)
+	}
+	out := &Table{}
+	for _, th := range t.items {
+		out.push(evaluated(in.call(sink, nil, th.force(in))))
+		t.progress.tick()
+	}
+	for _, k := range t.keys {
+		out.set(k, evaluated(in.call(sink, nil, t.byKey[k].force(in))))
+		t.progress.tick()
+	}
+	t.progress.finish()
+	return out
+}
+
+func (in *Interp) write(r *Resource, v Value) Value {
+	var w io.Writer
+	switch r.Name {
+	case "stdout":
+		w = in.Stdout
+	case "stderr":
+		w = in.Stderr
+	case "tty":
+		t, err := in.terminal()
+		if err != nil {
+			return err
+		}
+		w = t
+	default:
+		return errorf("cannot write to %s", r)
+	}
+	t, ok := v.(*Table)
+	if !ok {
+		in.Force(v)
+		if _, err := fmt.Fprintln(w, Display(v)); err != nil {
+			return errorf("write to %s: %v", r, err)
+		}
+		return r
+	}
+	values := make([]Value, len(t.items))
+	for i, th := range t.items {
+		values[i] = th.force(in)
+	}
+	for _, v := range values {
+		in.Force(v)
+		if _, err := fmt.Fprintln(w, Display(v)); err != nil {
+			return errorf("write to %s: %v", r, err)
+		}
+		t.progress.tick()
+	}
+	t.progress.finish()
+	return r
+}
+
+// resource evaluates @name: a resource defined with @: in scope, or one
+// of the built-in resources stdin, stdout, stderr, tty, progress and args. Any other operand,
+// such as @(glob "*.org"), is evaluated and must be a resource or a
+// table, which serves as a source of its elements.
+func (in *Interp) resource(name ast.Expression, env *Env) Value {
+	switch name.(type) {
+	case *ast.Name, *ast.StringLiteral:
+	default:
+		switch v := in.eval(name, env).(type) {
+		case *Resource, *Table, *Error:
+			return v
+		default:
+			return errorf("%s is not a resource", v)
+		}
+	}
+	n := bindingName(name)
+	if th, ok := env.lookup(n); ok {
+		return th.force(in)
+	}
+	switch n {
+	case "stdin", "stdout", "stderr", "tty", "progress":
+		return &Resource{Name: n}
+	case "args":
+		return in.args()
+	}
+	return errorf("unknown resource @%s", n)
+}
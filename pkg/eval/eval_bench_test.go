@@ -0,0 +1,80 @@
+package eval
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+)
+
+// Results of these benchmarks are recorded in testdata/bench.txt; update
+// it when changing the path of streams:
+//
+//	go test ./pkg/eval -run '^$' -bench Stream -benchmem -count 3
+//
+// Each iteration streams the same 10,000 lines; -benchtime 1000x pushes
+// a gigabyte through.
+
+// streamLines returns n lines of about 100 bytes.
+func streamLines(n int) []byte {
+	var b bytes.Buffer
+	for i := range n {
+		fmt.Fprintf(&b, "%08d %090d\n", i, i)
+	}
+	return b.Bytes()
+}
+
+// devNull opens the null device, so the benchmarks write with system
+// calls as a program writing to a file or pipe does.
+func devNull(b *testing.B) *os.File {
+	f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { f.Close() })
+	return f
+}
+
+// BenchmarkStream runs `@stdin -> transform -> @stdout` in the
+// interpreter.
+func BenchmarkStream(b *testing.B) {
+	src := streamLines(10000)
+	p := parser.New(lexer.New([]byte(`@stdin -> { "${right}!" } -> @stdout`)), parser.WithStrict(true))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		b.Fatal(p.Errors())
+	}
+	in := New()
+	in.Stdout = devNull(b)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for b.Loop() {
+		in.Stdin, in.stdin = bytes.NewReader(src), nil
+		if e, ok := in.Eval(prog).(*Error); ok {
+			b.Fatal(e)
+		}
+	}
+}
+
+// BenchmarkStreamNative is the same stream as a Go loop, the bound
+// BenchmarkStream is measured against.
+func BenchmarkStreamNative(b *testing.B) {
+	src := streamLines(10000)
+	out := devNull(b)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for b.Loop() {
+		s := bufio.NewScanner(bytes.NewReader(src))
+		w := bufio.NewWriter(out)
+		for s.Scan() {
+			fmt.Fprintf(w, "%s!\n", s.Text())
+		}
+		if err := w.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
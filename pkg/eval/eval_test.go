@@ -0,0 +1,1307 @@
+package eval
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+)
+
+// run evaluates input in a fresh interpreter and returns its printed
+// result and what it wrote to @stdout.
+func run(t *testing.T, input string) (string, string) {
+	t.Helper()
+	p := parser.New(lexer.New([]byte(input)), parser.WithStrict(true))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse %q: %v", input, p.Errors())
+	}
+	in := New()
+	var out bytes.Buffer
+	in.Stdout = &out
+	v := in.Force(in.Eval(prog))
+	if v == nil {
+		return "", out.String()
+	}
+	return v.String(), out.String()
+}
+
+// evalIn evaluates input in the interpreter in and returns its printed
+// result.
+func evalIn(t *testing.T, in *Interp, input string) string {
+	t.Helper()
+	p := parser.New(lexer.New([]byte(input)), parser.WithStrict(true))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse %q: %v", input, p.Errors())
+	}
+	return in.Force(in.Eval(prog)).String()
+}
+
+func TestEvalArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"(1 + 2) * 3", "9"},
+		{"20 / 10", "2"},
+		{"1 / 3", "1/3"},
+		{"1/2 + 1/4", "3/4"},
+		{"1/2 + 1/2", "1"},
+		{"7 % 3", "1"},
+		{"-7 % 3", "-1"},
+		{"2 ** 3 ** 2", "512"},
+		{"2 ** 100", "1267650600228229401496703205376"},
+		{"1.5 + 1", "2.5"},
+		{"sqrt 16", "4"},
+		{"sqrt 9/4", "3/2"},
+		{"sqrt 2", "1.4142135623730950"},
+		{"sqrt (0 - 1)", "Error: sqrt of negative number -1"},
+		{"1.25 * 2.0", "2.500"},
+		{"1.0 / 3", "0.3"},
+		{"1.5 ** 2", "2.25"},
+		{"- 5", "-5"},
+		{`"abc" + "de"`, "5"},
+		{"[1 2 3] * 2", "6"},
+		{"true + true", "2"},
+		{"10 & 2", "2"},
+		{"10 | 5", "15"},
+		{"10 ^ 5", "15"},
+		{"~ 0", "-1"},
+		{"1 << 2", "4"},
+		{"8 >> 1", "4"},
+	}
+	for _, tt := range tests {
+		if got, _ := run(t, tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 / 0", "Error: division by zero"},
+		{"((1 / 0) + 1) * 2", "Error: division by zero"},
+		{"1 / 0 ?? 10", "10"},
+		{"5 ?? 10", "5"},
+		{"1.5 % 2", "Error: 1.5 is not an integer"},
+		{"2 ** (0 - 1)", "Error: exponent -1 out of range"},
+		{"x : 1 / 0; x + 1", "Error: division by zero"},
+		{"[1 2].5", "Error: no key 5"},
+		{"f : { this right }; f 1", "Error: call depth exceeds 10000"},
+	}
+	for _, tt := range tests {
+		if got, _ := run(t, tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEvalLogic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 < 2", "true"},
+		{`"abc" = "abc"`, "true"},
+		{`"abc" = "abd"`, "false"},
+		{`"abc" = 3`, "true"},
+		{"1/2 = 0.5", "true"},
+		{"1 <> 2", "true"},
+		{"false && (1 / 0)", "false"},
+		{"true || (1 / 0)", "true"},
+		{"true && 0", "false"},
+		{"true & false", "false"},
+		{"! 0", "true"},
+		{`"" ?: "Guest"`, `"Guest"`},
+		{`"Ann" ?: "Guest"`, `"Ann"`},
+		{"(1 / 0) ?: 3", "3"},
+		{`(1 > 0) ? [true: "Ok" false: (1 / 0)]`, `"Ok"`},
+		{`(1 - 0) ? ["Not Ok" "Ok"]`, `"Ok"`},
+	}
+	for _, tt := range tests {
+		if got, _ := run(t, tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEvalDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"divmod : { [((left - (left % right)) / right) (left % right)] }; [q r] : 7 divmod 2; [q r]", "[3 1]"},
+		{"[a b] : [1 (1 / 0)]; a", "1"},
+		{"[a b] : [1 (1 / 0)]; b", "Error: division by zero"},
+		{"[a b] : [1 2 k: 3]; a + b", "3"},
+		{"[a b] : [1 2 3]; a", "Error: [a b] needs a table of 2 unkeyed elements, got 3"},
+		{"[a b] : 5", "Error: [a b] needs a table of 2 unkeyed elements, got 5"},
+		{"[a b] : 1 / 0; b", "Error: division by zero"},
+		{"f : { [x y] : right; x * y }; f [6 7]", "42"},
+	}
+	for _, tt := range tests {
+		if got, _ := run(t, tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEvalGuards(t *testing.T) {
+	classify := `classify : { right < 0 !> "negative"; right = 0 !> "zero"; "positive" }; `
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{classify + `[(classify (0 - 5)) (classify 0) (classify 7)]`, `["negative" "zero" "positive"]`},
+		{`fact : { right <= 1 !> 1; right * this (right - 1) }; fact 5`, "120"},
+		{`f : { x : right * 2; x > 10 !> "big"; x }; [(f 3) (f 6)]`, `[6 "big"]`},
+		{`f : { right !> (1 / 0); 2 }; [(f true) (f false)]`, "[Error: division by zero 2]"},
+		{`f : { (right / 0) !> 1; 2 }; f 1`, "Error: division by zero"},
+		{`f : { right > 0 !> "up" }; f 0`, "false"},
+	}
+	for _, tt := range tests {
+		if got, _ := run(t, tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEvalAssert(t *testing.T) {
+	isqrt := "isqrt : {\n  assert right >= 0 \"isqrt needs a non-negative number\";\n  right\n};\n"
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{isqrt + "isqrt 4", "4"},
+		{isqrt + "isqrt (0 - 3)", "Error: assertion failed at line 2:10: isqrt needs a non-negative number (right = -3)"},
+		{`f : { assert right > 0; right }; f 0`, "Error: assertion failed at line 1:14: (right > 0) (right = 0)"},
+		{`x : 2; y : 3; assert x = y "x and y differ"`, "Error: assertion failed at line 1:22: x and y differ (x = 2, y = 3)"},
+		{`ok : false; assert ok "not ok: ${ok}"`, "Error: assertion failed at line 1:20: not ok: false (ok = false)"},
+		{`f : { assert (right / 0) > 1; 2 }; f 1`, "Error: division by zero"},
+		{`assert 1 < 2`, "true"},
+	}
+	for _, tt := range tests {
+		if got, _ := run(t, tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEvalLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1 0] loop { [i s] : right; i > 10 !> break s; [(i + 1) (s + i)] }`, "55"},
+		{`gcd : { right loop { [a b] : right; b = 0 !> break a; [b (a % b)] } }; gcd [48 18]`, "6"},
+		{`[1 0] loop { [i s] : right; i > 100000 !> break s; [(i + 1) (s + i)] }`, "5000050000"},
+		{`1 loop { right > 3 !> (1 / 0); right + 1 }`, "Error: division by zero"},
+		{`1 loop 2`, "Error: loop needs a unary operator, got 2"},
+		{`break 3`, "(break 3)"},
+	}
+	for _, tt := range tests {
+		if got, _ := run(t, tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEvalTables(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1 2 3]", "[1 2 3]"},
+		{"1, 2, 3", "[1 2 3]"},
+		{"[1, 2, 3]", "[[1 2 3]]"},
+		{`mixed : [10 "status": "active" 20]; mixed.1`, "20"},
+		{`mixed : [10 "status": "active" 20]; mixed."status"`, `"active"`},
+		{"t : [a: 1 b: (a + 1)]; t.b", "2"},
+		{"t : [a: 1 b: 2]; t", "[a: 1 b: 2]"},
+		{"[(1 / 0) 2].1", "2"},
+		{`"héllo".1`, `"é"`},
+		{"[1 2 3] -> { right * 10 }", "[10 20 30]"},
+		{`"Hello, $0! The answer is $1." $ ["World" 42]`, `"Hello, World! The answer is 42."`},
+		{`"$name is $age" $ [name: "Ann" age: 30]`, `"Ann is 30"`},
+	}
+	for _, tt := range tests {
+		if got, _ := run(t, tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEvalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"square : { right * right }; square 4", "16"},
+		{"add : { left + right }; 4 add 5", "9"},
+		{"op : 50{ left - right }60; 10 op 3", "7"},
+		{"factorial : { (right <= 1) ? [true: 1 false: (right * this(right - 1))] }; factorial 5", "120"},
+		{"fib : { (right <= 1) ? [true: 1 false: ((this (right - 1)) + (this (right - 2)))] }; fib 10", "89"},
+		{"add_ten : 10 |> +; 5 -> add_ten", "15"},
+		{"inc : { right + 1 }; double : { right * 2 }; h : double o inc; 5 -> h", "12"},
+		{"clamp : { (right.0 < right.1) ? [true: right.1 false: right.0] }; clamp [5 7]", "7"},
+		{"mk : { n : right; { right + n } }; add2 : mk 2; 40 -> add2", "42"},
+		{"x : 1; x :+ 2; x :* 5; x", "15"},
+		{"5 -> { right + 1 }", "6"},
+		{"f : { right; left }; 1 -> f", "Error: { right; left } has no left operand"},
+	}
+	for _, tt := range tests {
+		if got, _ := run(t, tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEvalInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`x : 2; "a${x + 1}b"`, `"a3b"`},
+		{`name : "Ann"; "Hello ${name}!"`, `"Hello Ann!"`},
+		{`t : [1 "b"]; "${t} ${t.1}${1/2}"`, `"[1 \"b\"] b1/2"`},
+		{`"<${ "(${1})" }>"`, `"<(1)>"`},
+		{`"\${x}"`, `"${x}"`},
+		{`"a${1 / 0}b"`, "Error: division by zero"},
+	}
+	for _, tt := range tests {
+		got, _ := run(t, tt.input)
+		if got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEvalDataFormats(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`yaml_parse "b: 1\na: [x, 2.50]\n"`, `[b: 1 a: ["x" 2.50]]`},
+		{`cfg : yaml_parse "server:\n  port: 8080\n"; cfg.server.port`, "8080"},
+		{`cfg : yaml_parse "port: ~\n"; cfg.port ?? 80`, "80"},
+		{`yaml_parse "- 1.5e1\n- yes\n- true\n"`, `[15 "yes" true]`},
+		{`yaml_parse "a: [1\n"`, "Error: yaml_parse: line 1: did not find expected ',' or ']'"},
+		{`yaml_parse "{0', '}"`, "Error: yaml_parse: found unexpected end of stream"},
+		{`yaml_parse 3`, "Error: yaml_parse needs a String, got 3"},
+		{`toml_parse "z = 1\na = 0.25\n[t]\ny = true\n"`, `[z: 1 a: 0.25 t: [y: true]]`},
+		{`toml_parse "[[p]]\nn = 1\n[[p]]\nn = 2\n"`, "[p: [[n: 1] [n: 2]]]"},
+		{`(toml_parse "d = 1979-05-27\n").d`, `"1979-05-27"`},
+		{`(toml_parse "a = inf\n").a`, "Error: toml_parse: a: +Inf has no exact value"},
+		{`toml_parse "a = \n"`, "Error: toml_parse: line 1 (last key \"a\"): expected value but found '\\n' instead"},
+	}
+	for _, tt := range tests {
+		if got, _ := run(t, tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEvalFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"src/a.org":      "a",
+		"src/lib/b.org":  "bb",
+		"src/lib/c.txt":  "ccc",
+		"src/.git/d.org": "",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mtime := time.Unix(1700000000, 0)
+	if err := os.Chtimes(filepath.Join(dir, "src", "a.org"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`glob "src/**/*.org"`, `["src/a.org" "src/lib/b.org"]`},
+		{`glob "src/.*/*"`, `["src/.git/d.org"]`},
+		{`glob "*/*"`, `["src/a.org" "src/lib"]`},
+		{`glob "src/lib/c.txt"`, `["src/lib/c.txt"]`},
+		{`glob "missing/*"`, "[]"},
+		{`glob "src/["`, "Error: glob: bad pattern src/["},
+		{`@(glob "src/*.org") -> { path_base right }`, `["a.org"]`},
+		{`(walk "src/lib") -> { right.name }`, `["b.org" "c.txt"]`},
+		{`f : (walk "src").1; [f.path f.name f.size f.mtime]`, `["src/a.org" "a.org" 1 1700000000]`},
+		{`(walk "missing") ?? "none"`, `"none"`},
+		{`path_join ["src" "lib/" "../a.org"]`, `"src/a.org"`},
+		{`[(path_dir "src/a.org") (path_base "src/a.org") (path_ext "a.tar.gz")]`, `["src" "a.org" ".gz"]`},
+		{`path_ext 3`, "Error: path_ext needs a String, got 3"},
+		{`@(1 + 1)`, "Error: 2 is not a resource"},
+	}
+	for _, tt := range tests {
+		if got, _ := run(t, tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEvalDigests(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sha256 "abc"`, `"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"`},
+		{`md5 ""`, `"d41d8cd98f00b204e9800998ecf8427e"`},
+		{`crc32 "123456789"`, `"cbf43926"`},
+		{`crc32_bytes "123456789"`, "[203 244 57 38]"},
+		{`md5 [97 98 99]`, `"900150983cd24fb0d6963f7d28e17f72"`},
+		{`(sha256_bytes "abc") + 0`, "32"},
+		{`sha256 (sha256_bytes "abc")`, `"4f8b42c22dd3729b519ba6f68d2da7cc5b2d606d05daed5ad5128cc03e6c6358"`},
+		{`md5 [1 256]`, "Error: md5: 256 is not a byte"},
+		{`md5 [a: 1]`, "Error: md5 needs a String or a Table of bytes, got a keyed Table"},
+		{`crc32 1.5`, "Error: crc32 needs a String or a Table of bytes, got 1.5"},
+	}
+	for _, tt := range tests {
+		if got, _ := run(t, tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEvalStdout(t *testing.T) {
+	_, out := run(t, `"Hello" -> @stdout; [1 "two" 3/4] -> @stdout`)
+	if want := "Hello\n1\ntwo\n3/4\n"; out != want {
+		t.Errorf("stdout = %q, want %q", out, want)
+	}
+}
+
+func TestEvalStdin(t *testing.T) {
+	tests := []struct {
+		input, stdin string
+		expected     string
+		stdout       string
+	}{
+		{`@stdin -> { "${right}!" } -> @stdout`, "a\nb\r\nc", "@stdout", "a!\nb!\nc!\n"},
+		{`@stdin -> @stdout`, "", "@stdout", ""},
+		{`@stdin -> { "<${right}>" }`, "1\n2\n", `["<1>" "<2>"]`, ""},
+		{`@stdin -> { "${right}" -> @stdout; right } -> @stdout`, "1\n2\n", "@stdout", "1\n1\n2\n2\n"},
+		{`@stdin -> batch 2 -> @stdout`, "1\n2\n3\n", "@stdout", "[\"1\" \"2\"]\n[\"3\"]\n"},
+		{`lines : @stdin; lines -> @stdout`, "x\n", "@stdout", "x\n"},
+		// Flows that cannot stream run as they would on a table.
+		{`@stdin -> 1 -> @stdout`, "x\n", "@stdout", "Error: 1 is not a sink\n"},
+		{`@stdin -> @stdout -> @stdout`, "x\n", "@stdout", "x\n@stdout\n"},
+	}
+	for _, tt := range tests {
+		p := parser.New(lexer.New([]byte(tt.input)), parser.WithStrict(true))
+		prog := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("parse %q: %v", tt.input, p.Errors())
+		}
+		in := New()
+		var out bytes.Buffer
+		in.Stdin, in.Stdout = strings.NewReader(tt.stdin), &out
+		if got := in.Force(in.Eval(prog)).String(); got != tt.expected || out.String() != tt.stdout {
+			t.Errorf("%s = %s writing %q, want %s writing %q", tt.input, got, out.String(), tt.expected, tt.stdout)
+		}
+	}
+}
+
+// TestEvalStdinFlush checks that a stream writes what it has before
+// waiting for more input.
+func TestEvalStdinFlush(t *testing.T) {
+	p := parser.New(lexer.New([]byte(`@stdin -> { "${right}!" } -> @stdout`)), parser.WithStrict(true))
+	prog := p.ParseProgram()
+	r, w := io.Pipe()
+	out := make(chan string)
+	in := New()
+	in.Stdin, in.Stdout = r, writerFunc(func(b []byte) (int, error) {
+		out <- string(b)
+		return len(b), nil
+	})
+	go in.Eval(prog)
+	for _, line := range []string{"a", "b"} {
+		fmt.Fprintln(w, line)
+		if got := <-out; got != line+"!\n" {
+			t.Errorf("wrote %q, want %q", got, line+"!\n")
+		}
+	}
+	w.Close()
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) { return f(b) }
+
+// fakeTerminal types lines and keys, recording what is written and
+// whether each line was read with echo.
+type fakeTerminal struct {
+	bytes.Buffer
+	lines, keys []string
+	echo        []bool
+}
+
+func (f *fakeTerminal) ReadLine(echo bool) (string, error) {
+	if len(f.lines) == 0 {
+		return "", io.EOF
+	}
+	f.echo = append(f.echo, echo)
+	line := f.lines[0]
+	f.lines = f.lines[1:]
+	return line, nil
+}
+
+func (f *fakeTerminal) ReadKey() (string, error) {
+	if len(f.keys) == 0 {
+		return "", io.EOF
+	}
+	k := f.keys[0]
+	f.keys = f.keys[1:]
+	return k, nil
+}
+
+func (f *fakeTerminal) Size() (int, int, error) { return 80, 24, nil }
+
+func TestEvalTTY(t *testing.T) {
+	input := `user : prompt "User: ";
+pass : password "Password: ";
+k : read_key "Continue? ";
+"${user}:${pass}:${k}" -> @tty;
+[tty_size @tty (prompt "again: ") (tty_size 1)]`
+	term := &fakeTerminal{lines: []string{"ann", "s3cret"}, keys: []string{"y"}}
+	in := New()
+	in.TTY = term
+	got := evalIn(t, in, input)
+	want := `[[cols: 80 rows: 24] Error: prompt: end of input Error: tty_size needs @tty, got 1]`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if out := term.String(); out != "User: Password: Continue? ann:s3cret:y\nagain: " {
+		t.Errorf("terminal output %q", out)
+	}
+	if len(term.echo) != 2 || !term.echo[0] || term.echo[1] {
+		t.Errorf("echo = %v, want [true false]", term.echo)
+	}
+}
+
+func TestEvalStyle(t *testing.T) {
+	tests := []struct {
+		input, color, plain string
+	}{
+		{`"red" fg "error"`, `"\x1b[31merror\x1b[39m"`, `"error"`},
+		{`"muted" fg 42`, `"\x1b[38;5;240m42\x1b[39m"`, `"42"`},
+		{`12 bg "x"`, `"\x1b[104mx\x1b[49m"`, `"x"`},
+		{`"#ff8000" fg "x"`, `"\x1b[38;2;255;128;0mx\x1b[39m"`, `"x"`},
+		{`bold ("green" fg "ok")`, `"\x1b[1m\x1b[32mok\x1b[39m\x1b[22m"`, `"ok"`},
+		{`underline "u"`, `"\x1b[4mu\x1b[24m"`, `"u"`},
+		{`warn : "yellow" |> fg; "w" -> warn`, `"\x1b[33mw\x1b[39m"`, `"w"`},
+		{`"mauve" fg "x"`, `Error: fg: unknown color "mauve"`, `Error: fg: unknown color "mauve"`},
+		{`256 bg "x"`, "Error: bg: unknown color 256", "Error: bg: unknown color 256"},
+		{`bold (1 / 0)`, "Error: division by zero", "Error: division by zero"},
+	}
+	for _, tt := range tests {
+		in := New()
+		in.Color = true
+		if got := evalIn(t, in, tt.input); got != tt.color {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.color)
+		}
+		in = New()
+		in.Color = false
+		if got := evalIn(t, in, tt.input); got != tt.plain {
+			t.Errorf("%s without color = %s, want %s", tt.input, got, tt.plain)
+		}
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	t.Setenv("FORCE_COLOR", "1")
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm")
+	if !colorEnabled() {
+		t.Error("FORCE_COLOR: color disabled")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if colorEnabled() {
+		t.Error("NO_COLOR: color enabled")
+	}
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "dumb")
+	if colorEnabled() {
+		t.Error("TERM=dumb: color enabled")
+	}
+}
+
+func TestEvalProgress(t *testing.T) {
+	clock := time.Unix(0, 0)
+	in := New()
+	var out, errOut bytes.Buffer
+	in.Stdout, in.Stderr = &out, &errOut
+	in.Progress = true
+	in.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	if got := evalIn(t, in, `[1 2 4] -> @progress -> { right * 2 }`); got != "[2 4 8]" {
+		t.Errorf("got %s, want [2 4 8]", got)
+	}
+	want := "\r\x1b[K⠙ ██████░░░░░░░░░░░░░░  33% 1/3 1.0/s ETA 2s" +
+		"\r\x1b[K⠹ █████████████░░░░░░░  66% 2/3 1.0/s ETA 1s" +
+		"\r\x1b[K⠸ ████████████████████ 100% 3/3 1.0/s ETA 0s" +
+		"\r\x1b[Kdone 3/3 in 4s (0.8/s)\n"
+	if got := errOut.String(); got != want {
+		t.Errorf("stderr = %q, want %q", got, want)
+	}
+
+	errOut.Reset()
+	evalIn(t, in, `["a" "b"] -> @progress -> @stdout`)
+	if out.String() != "a\nb\n" || !strings.HasSuffix(errOut.String(), "done 2/2 in 3s (0.7/s)\n") {
+		t.Errorf("stdout %q, stderr %q", out.String(), errOut.String())
+	}
+
+	errOut.Reset()
+	in.Progress = false
+	if got := evalIn(t, in, `[1 2] -> @progress -> { right + 1 }`); got != "[2 3]" || errOut.Len() != 0 {
+		t.Errorf("disabled: got %s, stderr %q", got, errOut.String())
+	}
+	if got := evalIn(t, in, `"x" -> @progress`); got != `"x"` {
+		t.Errorf("single value: got %s", got)
+	}
+}
+
+func TestEvalDeadline(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`10 deadline { 1 + 2 }`, "3"},
+		{`10 deadline { [1 2] -> { right * 2 } }`, "[2 4]"},
+		{`3 deadline { [1 2 3 4 5] -> { right * 2 } }`, "Error: deadline exceeded"},
+		{`3 deadline { [slow: (1 + 2 + 3 + 4)] }`, "Error: deadline exceeded"},
+		{`(3 deadline { 1 + 2 + 3 + 4 }) ?? "late"`, `"late"`},
+		{`100 deadline { 2 deadline { 1 + 2 + 3 } }`, "Error: deadline exceeded"},
+		{`0 deadline { 1 }`, "Error: deadline needs a positive number of seconds, got 0"},
+		{`1 deadline 5`, "Error: deadline needs an operator, got 5"},
+		{`10s deadline { 1 + 2 }`, "3"},
+		{`2500ms deadline { 1 + 2 + 3 + 4 }`, "Error: deadline exceeded"},
+		{`1KB deadline { 1 }`, "Error: deadline needs a positive number of seconds, got 1KB"},
+	}
+	for _, tt := range tests {
+		// Every reading of the clock advances it by a second.
+		clock := time.Unix(0, 0)
+		in := New()
+		in.now = func() time.Time {
+			clock = clock.Add(time.Second)
+			return clock
+		}
+		if got := evalIn(t, in, tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+		if !in.Deadline.IsZero() {
+			t.Errorf("%s: deadline left set", tt.input)
+		}
+	}
+
+	in := New()
+	var errOut bytes.Buffer
+	in.Stderr = &errOut
+	in.Deadline = time.Now().Add(-time.Second)
+	prog := parser.New(lexer.New([]byte("main : 0;"))).ParseProgram()
+	if code := in.Run(prog, "prog.org"); code != ExitError || errOut.String() != "Error: deadline exceeded\n" {
+		t.Errorf("run past deadline: status %d, stderr %q", code, errOut.String())
+	}
+}
+
+func TestEvalThrottle(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		slept    time.Duration
+	}{
+		{`[1 2 3 4 5] -> 2 throttle { right * 10 }`, "[10 20 30 40 50]", 2 * time.Second},
+		{`[1 2 3 4 5] -> [rate: 2 burst: 3] throttle { right * 10 }`, "[10 20 30 40 50]", time.Second},
+		{`add3 : 3 |> ([rate: 4] throttle +); [1 2] -> add3`, "[4 5]", 250 * time.Millisecond},
+		{`[1 2 3] -> 1/2 throttle { right }`, "[1 2 3]", 4 * time.Second},
+		{`5 deadline { [1 2 3] -> 1/10 throttle { right } }`, "[1 Error: deadline exceeded Error: deadline exceeded]", 0},
+		{`0 throttle { right }`, "Error: throttle needs calls per second or [rate: burst:], got 0", 0},
+		{`[burst: 2] throttle { right }`, "Error: throttle: [burst: 2] has no rate", 0},
+		{`[rate: 1 burst: 1/2] throttle { right }`, "Error: throttle: bad burst in [rate: 1 burst: 1/2]", 0},
+		{`2 throttle 3`, "Error: throttle needs an operator, got 3", 0},
+	}
+	for _, tt := range tests {
+		clock := time.Unix(0, 0)
+		var slept time.Duration
+		in := New()
+		in.now = func() time.Time { return clock }
+		in.sleepFn = func(d time.Duration) {
+			slept += d
+			clock = clock.Add(d)
+		}
+		if got := evalIn(t, in, tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+		if slept != tt.slept {
+			t.Errorf("%s slept %s, want %s", tt.input, slept, tt.slept)
+		}
+	}
+}
+
+func TestEvalBatch(t *testing.T) {
+	// Each call of slow takes a second on the fake clock, after the first.
+	const slow = `slow : 1 throttle { right }; `
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1 2 3 4 5] -> batch 2`, "[[1 2] [3 4] [5]]"},
+		{`[1 2 3 4] -> batch 2 -> { right.0 + right.1 }`, "[3 7]"},
+		{`[a: 1 b: 2 3] -> batch 2`, "[[3 a: 1] [b: 2]]"},
+		{`7 -> batch 3`, "[[7]]"},
+		{`[] -> batch 3`, "[]"},
+		{slow + `[(1 -> slow) (2 -> slow) (3 -> slow) (4 -> slow) (5 -> slow)] -> window 2`, "[[1 2] [3 4] [5]]"},
+		{slow + `[(1 -> slow) (2 -> slow) (3 -> slow)] -> window 1/2`, "[[1] [2] [3]]"},
+		{`[1 2 3] -> window 10`, "[[1 2 3]]"},
+		{`batch 0`, "Error: batch needs a positive integer, got 0"},
+		{`batch 3/2`, "Error: batch needs a positive integer, got 3/2"},
+		{`window "1s"`, `Error: window needs a positive number of seconds, got "1s"`},
+	}
+	for _, tt := range tests {
+		clock := time.Unix(0, 0)
+		in := New()
+		in.now = func() time.Time { return clock }
+		in.sleepFn = func(d time.Duration) { clock = clock.Add(d) }
+		if got := evalIn(t, in, tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEvalScan(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1 2 3 4] -> 0 scan +`, "[1 3 6 10]"},
+		{`[a: 1 b: 2 3] -> 10 scan +`, "[13 a: 14 b: 16]"},
+		{`[2 3] -> 1 scan * -> { right + 1 }`, "[3 7]"},
+		{`5 -> 1 scan +`, "[6]"},
+		{`[] -> 0 scan +`, "[]"},
+		{`[1 2] -> "x" checkpoint (0 scan +)`, "[1 3]"},
+		{`0 scan { right }`, "Error: scan needs a binary operator, got { right }"},
+		{`"x" checkpoint (batch 2)`, "Error: checkpoint needs a scan stage, got (batch 2)"},
+		{`"a/b" checkpoint (0 scan +)`, `Error: checkpoint: bad name "a/b"`},
+		{`3 checkpoint (0 scan +)`, "Error: checkpoint needs a String, got 3"},
+	}
+	for _, tt := range tests {
+		if got := evalIn(t, New(), tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEvalCheckpoint(t *testing.T) {
+	// Each call of slow takes a second on the fake clock, after the first.
+	const input = `slow : 1 throttle { right }; ` +
+		`[(1 -> slow) (2 -> slow) (3 -> slow) (4 -> slow) (5 -> slow)] -> "sum" checkpoint (0 scan +)`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sum.ckpt")
+	run := func(timeout time.Duration) string {
+		clock := time.Unix(0, 0)
+		in := New()
+		in.now = func() time.Time { return clock }
+		in.sleepFn = func(d time.Duration) { clock = clock.Add(d) }
+		if timeout > 0 {
+			in.Deadline = clock.Add(timeout)
+		}
+		in.Checkpoints = dir
+		in.CheckpointEvery = time.Second
+		return evalIn(t, in, input)
+	}
+
+	// Cut off by the deadline, as by org run --timeout, the scan saves
+	// what it has done.
+	if got, want := run(2500*time.Millisecond), "[1 3 6 Error: deadline exceeded]"; got != want {
+		t.Errorf("first run = %s, want %s", got, want)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"consumed":3,"state":{"i":"6"}}`; string(b) != want {
+		t.Errorf("checkpoint = %s, want %s", b, want)
+	}
+
+	// The next run resumes after the elements consumed, and removes the
+	// checkpoint when done.
+	if got, want := run(0), "[10 15]"; got != want {
+		t.Errorf("resumed run = %s, want %s", got, want)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("checkpoint left after the scan completed: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := run(0); !strings.HasPrefix(got, "Error: checkpoint: ") {
+		t.Errorf("with a bad checkpoint = %s, want an error", got)
+	}
+}
+func TestEvalKV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.kv")
+	open := fmt.Sprintf("db : kv_open %q; ", path)
+	steps := []struct {
+		input    string
+		expected string
+	}{
+		{`[count: 1 name: "x"] -> db; db kv_put [t: [1 2 a: 1.50] q: 3/2]; db kv_get "count"`, "1"},
+		{`db kv_delete "name"; db kv_get "name"`, "Error: kv_get: no key name"},
+		{`db kv_put ["zero"]; kv_items db`, `[count: 1 t: [1 2 a: 1.50] q: 3/2 0: "zero"]`},
+		{`db kv_put [count: ((db kv_get "count") + 1)]; db kv_get "count"`, "2"},
+		{`db kv_put [f: { right }]`, "Error: kv_put: f: cannot serialize { right }"},
+		{`db kv_get [1]`, "Error: kv_get: [1] cannot be a key"},
+		{`3 kv_get "count"`, "Error: kv_get needs a store from kv_open, got 3"},
+		{`db kv_put 3`, "Error: kv_put needs a table of entries, got 3"},
+	}
+	for _, tt := range steps {
+		// Each step opens the store anew, reading what the last one wrote.
+		if got := evalIn(t, New(), open+tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+
+	// A record cut short by a crash is dropped.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"k": {"s": "count"}, "v": {"i"`)
+	f.Close()
+	if got := evalIn(t, New(), open+`db kv_put [count: 3]; db kv_get "count"`); got != "3" {
+		t.Errorf("after a torn write: count = %s, want 3", got)
+	}
+	if got := evalIn(t, New(), open+`db kv_get "count"`); got != "3" {
+		t.Errorf("reopened after a torn write: count = %s, want 3", got)
+	}
+}
+
+// TestKVReadme runs the run counter of the README, which stores how many
+// times it has run.
+func TestKVReadme(t *testing.T) {
+	t.Chdir(t.TempDir())
+	const counter = `db : kv_open "state.kv";
+count : (db kv_get "runs") ?? 0;
+[runs: (count + 1)] -> db;
+`
+	for runs := 1; runs <= 3; runs++ {
+		got := evalIn(t, New(), counter+`db kv_get "runs"`)
+		if want := fmt.Sprint(runs); got != want {
+			t.Errorf("run %d: runs = %s, want %s", runs, got, want)
+		}
+	}
+}
+
+func TestKVCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.kv")
+	var log strings.Builder
+	for i := range kvCompactMin + 1 {
+		fmt.Fprintf(&log, `{"k":{"s":"n"},"v":{"i":"%d"}}`+"\n", i)
+	}
+	if err := os.WriteFile(path, []byte(log.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := evalIn(t, New(), fmt.Sprintf("kv_open %q kv_get \"n\"", path)); got != fmt.Sprint(kvCompactMin) {
+		t.Errorf("n = %s, want %d", got, kvCompactMin)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf(`{"k":{"s":"n"},"v":{"i":"%d"}}`+"\n", kvCompactMin); string(b) != want {
+		t.Errorf("compacted log = %q, want %q", b, want)
+	}
+}
+
+func TestEvalQuantities(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[250ms 1.5h 3MiB]`, "[250ms 1.5h 3MiB]"},
+		{`1s + 500ms`, "3/2"},
+		{`2m / 1s`, "120"},
+		{`[(1.5h + 0) (100ns + 0)]`, "[5400 1/10000000]"},
+		{`[(1KiB + 0) (10KB + 0) (1GiB > 1GB)]`, "[1024 10000 true]"},
+		{`[(1s = 1000ms) (0ms ?: 5)]`, "[true 5]"},
+	}
+	for _, tt := range tests {
+		if got, _ := run(t, tt.input); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestSerialize(t *testing.T) {
+	for _, input := range []string{
+		`42`, `-3/2`, `1.50`, `250ms`, `1.5KiB`, `"tab\t é"`, `true`, `[]`,
+		`[1 "two" [3] k: 4 "a b": false 7: 8 1/2: 9 true: 10]`,
+	} {
+		in := New()
+		v := in.Force(in.Eval(parser.New(lexer.New([]byte(input))).ParseProgram()))
+		b, err := marshalValue(in, v)
+		if err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		back, uerr := unmarshalValue(b)
+		if uerr != nil {
+			t.Errorf("%s: %s: %v", input, b, uerr)
+			continue
+		}
+		if back.String() != v.String() {
+			t.Errorf("%s: read back %s from %s", input, back, b)
+		}
+	}
+}
+
+func TestKeyName(t *testing.T) {
+	for seq, want := range map[string]string{
+		"a": "a", "\r": "enter", "\x1b": "escape", "\x1b[A": "up", "\x03": "ctrl+c", "é": "é", "\x1b[99~": "\x1b[99~",
+	} {
+		if got := keyName(seq); got != want {
+			t.Errorf("keyName(%q) = %q, want %q", seq, got, want)
+		}
+	}
+}
+
+func TestEvalPersistentScope(t *testing.T) {
+	in := New()
+	bt := parser.NewBindingTable()
+	var got string
+	for _, line := range []string{"double : { right * 2 };", "x : double 21;", "x + 0"} {
+		p := parser.New(lexer.New([]byte(line)), parser.WithBindings(bt), parser.WithStrict(true))
+		prog := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("parse %q: %v", line, p.Errors())
+		}
+		got = in.Eval(prog).String()
+	}
+	if got != "42" {
+		t.Errorf("got %s, want 42", got)
+	}
+}
+
+func TestRunMain(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		args   []string
+		status int
+		stdout string
+		stderr string
+	}{
+		{"integer status", "main : { 3 };", nil, 3, "", ""},
+		{"zero status", "main : { 0 };", nil, 0, "", ""},
+		{"expression main", "main : 7;", nil, 7, "", ""},
+		{"args as right", "main : { right.1 };", []string{"a", "b"}, 0, "", ""},
+		{"args count", "main : { right + 0 };", []string{"a", "b"}, 2, "", ""},
+		{"args resource", "main : { @args + 0 };", []string{"a"}, 1, "", ""},
+		{"table printed", `main : { [right.0 "x" 3/4 [1 "y"]] };`, []string{"hi"}, 0, "hi\nx\n3/4\n[1 \"y\"]\n", ""},
+		{"keyed entries not printed", "main : { [1 k: 2] };", nil, 0, "1\n", ""},
+		{"string not printed", `main : { "done" };`, nil, 0, "", ""},
+		{"uncaught error", "main : { 1 / 0 };", nil, 1, "", "Error: division by zero\n"},
+		{"status out of range", "main : { 256 };", nil, 1, "", "Error: exit status 256 out of range 0-255\n"},
+		{"negative status", "main : { 0 - 1 };", nil, 1, "", "Error: exit status -1 out of range 0-255\n"},
+		{"no main", "x : 1;", nil, 2, "", "Error: no main in prog.org\n"},
+		{"binary main", "main : { left ?? right.0 };", []string{"only"}, 0, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(lexer.New([]byte(tt.input)), parser.WithStrict(true))
+			prog := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("parse: %v", p.Errors())
+			}
+			in := New()
+			var stdout, stderr bytes.Buffer
+			in.Stdout, in.Stderr, in.Args = &stdout, &stderr, tt.args
+			if got := in.Run(prog, "prog.org"); got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+			if stdout.String() != tt.stdout {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.stdout)
+			}
+			if stderr.String() != tt.stderr {
+				t.Errorf("stderr = %q, want %q", stderr.String(), tt.stderr)
+			}
+		})
+	}
+}
+
+func TestRunTests(t *testing.T) {
+	input := `add : { left + right };
+test_add : { assert 1 add 2 = 3; 2 add 2 = 4 };
+test_value : 1 add 1 = 2;
+test_false : 1 = 2;
+test_assert : { assert 2 * 2 = 5 "bad sum"; true };
+test_error : { 1 / 0 };
+test_operands : { right };
+helper : false;
+`
+	p := parser.New(lexer.New([]byte(input)), parser.WithStrict(true))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse: %v", p.Errors())
+	}
+	got := map[string]string{}
+	var names []string
+	for _, r := range New().RunTests(prog, nil, nil) {
+		names = append(names, r.Name)
+		got[r.Name] = r.Failure
+	}
+	want := map[string]string{
+		"test_add":      "",
+		"test_value":    "",
+		"test_false":    "got false",
+		"test_assert":   "Error: assertion failed at line 5:24: bad sum ((2 * 2) = 4)",
+		"test_error":    "Error: division by zero",
+		"test_operands": "a test cannot take operands",
+	}
+	if !slices.Equal(names, []string{"test_add", "test_value", "test_false", "test_assert", "test_error", "test_operands"}) {
+		t.Errorf("tests = %v", names)
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("%s: failure %q, want %q", name, got[name], w)
+		}
+	}
+
+	var ran []string
+	for _, r := range New().RunTests(prog, func(name string) bool { return strings.HasSuffix(name, "_add") }, nil) {
+		ran = append(ran, r.Name)
+	}
+	if !slices.Equal(ran, []string{"test_add"}) {
+		t.Errorf("filtered tests = %v", ran)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	input := `abs : {
+  right < 0 ? 0 - right;
+  right
+};
+unused : { 1 };
+x : abs 3;
+x
+`
+	p := parser.New(lexer.New([]byte(input)), parser.WithStrict(true))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse: %v", p.Errors())
+	}
+	in := New()
+	in.Coverage = NewCoverage()
+	in.Force(in.Eval(prog))
+
+	stmts := in.Coverage.Statements(prog)
+	var got []string
+	for _, s := range stmts {
+		got = append(got, fmt.Sprintf("%d:%d %t", s.Span.Start.Line, s.Span.Start.Column, s.Ran))
+	}
+	want := []string{"1:1 true", "2:3 true", "3:3 true", "5:1 true", "5:12 false", "6:1 true", "7:1 true"}
+	if !slices.Equal(got, want) {
+		t.Errorf("statements = %v, want %v", got, want)
+	}
+	if ran, total := CoveredCount(stmts); ran != 6 || total != 7 {
+		t.Errorf("covered %d/%d, want 6/7", ran, total)
+	}
+	lines := CoveredLines(stmts)
+	if !lines[1] || lines[5] || len(lines) != 6 {
+		t.Errorf("lines = %v", lines)
+	}
+}
+
+// TestCoverageImport checks that the statements of an imported module are
+// recorded as those of the program.
+func TestCoverageImport(t *testing.T) {
+	lib := parser.New(lexer.New([]byte("twice : { right * 2 };\nunused : { 1 };\n")), parser.WithStrict(true)).ParseProgram()
+	in := New()
+	in.Coverage = NewCoverage()
+	in.Modules = func(from, path string) (string, *ast.Program, error) { return path, lib, nil }
+	if got := evalIn(t, in, `lib : "lib.org" @ org; 4 -> lib.twice`); got != "8" {
+		t.Fatalf("got %s", got)
+	}
+	if ran, total := CoveredCount(in.Coverage.Statements(lib)); ran != 3 || total != 4 {
+		t.Errorf("module covered %d/%d, want 3/4", ran, total)
+	}
+}
+
+// stops records where a Debugger stopped, and the value of x there.
+type stops struct {
+	in  *Interp
+	got []string
+}
+
+func (d *stops) Stop(s ast.Statement, f Frame) {
+	x := "unbound"
+	if v, pending, ok := f.Env.Evaluated("x"); pending {
+		x = "pending"
+	} else if ok {
+		x = v.String()
+	}
+	if f.Depth > 0 {
+		v, _ := d.in.Lookup(f.Env, "right")
+		x += " right=" + v.String()
+	}
+	d.got = append(d.got, fmt.Sprintf("%d:%d x=%s", s.Location().Start.Line, f.Depth, x))
+}
+
+func TestDebugger(t *testing.T) {
+	input := `double : {
+  right * 2
+};
+x : 1 + 2;
+y : double x;
+y
+`
+	p := parser.New(lexer.New([]byte(input)), parser.WithStrict(true))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse: %v", p.Errors())
+	}
+	in := New()
+	d := &stops{in: in}
+	in.Debugger = d
+	if v := in.Force(in.Eval(prog)); v.String() != "6" {
+		t.Errorf("result = %s, want 6", v)
+	}
+	// The body of double stops when y calls it, one level down.
+	want := []string{"1:0 x=unbound", "4:0 x=unbound", "5:0 x=3", "2:1 x=3 right=3", "6:0 x=3"}
+	if !slices.Equal(d.got, want) {
+		t.Errorf("stops = %q, want %q", d.got, want)
+	}
+	if names := in.Global.Names(); !slices.Contains(names, "double") || !slices.Contains(names, "y") {
+		t.Errorf("Names() = %v, want double and y among them", names)
+	}
+}
+
+func TestGoldenTests(t *testing.T) {
+	input := `golden_list : { [1 2] -> @stdout; "end" -> @stdout; false };
+golden_error : { "partial" -> @stdout; 1 / 0 };
+`
+	p := parser.New(lexer.New([]byte(input)), parser.WithStrict(true))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse: %v", p.Errors())
+	}
+	in := New()
+	var stdout bytes.Buffer
+	in.Stdout = &stdout
+	results := in.RunTests(prog, nil, nil)
+	if len(results) != 2 || !results[0].Golden || string(results[0].Output) != "1\n2\nend\n" || !results[0].Passed() {
+		t.Fatalf("results = %+v", results)
+	}
+	if results[1].Failure != "Error: division by zero" {
+		t.Errorf("golden_error: failure %q", results[1].Failure)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("golden output leaked to @stdout: %q", stdout.String())
+	}
+
+	dir := t.TempDir()
+	path := GoldenFile(filepath.Join(dir, "list_test.org"), "golden_list")
+	if filepath.Base(path) != "list_test.list.golden" {
+		t.Errorf("golden file = %s", path)
+	}
+	r := results[0]
+	if r.CompareGolden(path, false); !strings.HasPrefix(r.Failure, "no golden file") {
+		t.Errorf("missing file: failure %q", r.Failure)
+	}
+	r = results[0]
+	if r.CompareGolden(path, true); !r.Passed() {
+		t.Fatalf("update: failure %q", r.Failure)
+	}
+	r = results[0]
+	if r.CompareGolden(path, false); !r.Passed() {
+		t.Errorf("after update: failure %q", r.Failure)
+	}
+	os.WriteFile(path, []byte("1\n3\nend\n"), 0o644)
+	r = results[0]
+	want := "output differs from " + path + " at line 2:\n       got:  \"2\"\n       want: \"3\""
+	if r.CompareGolden(path, false); r.Failure != want {
+		t.Errorf("changed file: failure %q, want %q", r.Failure, want)
+	}
+}
+
+func TestGoldenTerminal(t *testing.T) {
+	input := `golden_login : {
+  user : prompt "User: ";
+  pass : password "Password: ";
+  k : read_key "Go? ";
+  ("green" fg "${user}:${pass}:${k}") -> @tty;
+  size : tty_size @tty;
+  [size.cols size.rows] -> @stdout;
+  prompt "More: "
+};
+`
+	p := parser.New(lexer.New([]byte(input)), parser.WithStrict(true))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse: %v", p.Errors())
+	}
+	in := New()
+	in.Color = false
+	results := in.RunTests(prog, nil, func(name string) []string {
+		if name != "golden_login" {
+			t.Errorf("input asked for %s", name)
+		}
+		return []string{"ann", "s3cret", "up"}
+	})
+	if len(results) != 1 || results[0].Failure != "Error: prompt: end of input" {
+		t.Fatalf("results = %+v", results)
+	}
+	want := `User: ann
+Password: 
+Go? \e[32mann:s3cret:up\e[39m
+80
+24
+More: `
+	if got := string(results[0].Output); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	if in.Color || in.TTY != nil {
+		t.Errorf("terminal settings leaked: color %v, tty %v", in.Color, in.TTY)
+	}
+}
+
+func TestNormalizeTerminal(t *testing.T) {
+	got := string(NormalizeTerminal([]byte("a\r\nb\r\x1b[2Kc\x07\td")))
+	if want := `a` + "\n" + `b\r\e[2Kc\x07` + "\t" + `d`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestEvalImport(t *testing.T) {
+	modules := map[string]string{
+		"lib.org":   `"lib.org" -> @stdout; twice : { right * 2 }; base : "base.org" @ org; ten : base.five * 2;`,
+		"base.org":  `five : 5;`,
+		"cycle.org": `again : "cycle.org" @ org;`,
+	}
+	var imports []string
+	load := func(from, path string) (string, *ast.Program, error) {
+		imports = append(imports, from+" "+path)
+		src, ok := modules[path]
+		if !ok {
+			return "", nil, fmt.Errorf("no module %s", path)
+		}
+		p := parser.New(lexer.New([]byte(src)), parser.WithStrict(true))
+		return path, p.ParseProgram(), nil
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`lib : "lib.org" @ org; [lib.ten (4 -> lib.twice)]`, "[10 8]"},
+		{`a : "lib.org" @ org; b : "lib.org" @ org; a.ten + b.ten`, "20"},
+		{`lib : "missing.org" @ org; lib`, "Error: no module missing.org"},
+		{`c : "cycle.org" @ org; c.again`, "Error: import cycle through cycle.org"},
+		{`lib : "lib.org" @ sys; lib`, `Error: a module is imported as "path" @ org`},
+	}
+	for i, tt := range tests {
+		in := New()
+		var out bytes.Buffer
+		in.Stdout = &out
+		in.Modules, in.File = load, "main.org"
+		imports = nil
+		if got := evalIn(t, in, tt.input); got != tt.expected {
+			t.Errorf("%s: got %s, want %s", tt.input, got, tt.expected)
+		}
+		if tt.expected == "20" && out.String() != "lib.org\n" {
+			t.Errorf("%s: a module imported twice ran %q", tt.input, out.String())
+		}
+		// base.org is imported from lib.org, not from the program.
+		if want := []string{"main.org lib.org", "lib.org base.org"}; i == 0 && !slices.Equal(imports, want) {
+			t.Errorf("imports = %q, want %q", imports, want)
+		}
+	}
+
+	in := New()
+	if got := evalIn(t, in, `lib : "lib.org" @ org; lib`); !strings.HasPrefix(got, "Error: module loading") {
+		t.Errorf("without a loader: got %s", got)
+	}
+}
+
+func TestEvalConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("etc/app/config.toml", "port = 80\nname = \"system\"\n[db]\nhost = \"db\"\nuser = \"root\"\n")
+	write("home/app/config.org", "# Mine.\n[\"port\": 9000 db: [host: \"local\"] \"tags\": [\"a\" (-1)]]\n")
+	write("home/app/config.json", `{"port": 1}`) // after config.org, so not read
+	write("other.json", `{"db": {"host": null, "user": "json"}, "ratio": 2.5, "tags": [1, {"k": true}]}`)
+	write("code.org", `[port: (1 + 2)]`)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "home"))
+	t.Setenv("XDG_CONFIG_DIRS", filepath.Join(dir, "etc")+string(os.PathListSeparator)+"relative")
+	t.Setenv("APP_CONFIG", "")
+
+	defaults := `"app" config ["port": 8080 "verbose": false "ratio": 1.5 "db": ["host": "localhost" "user": "me"] "dry_run": false]`
+	tests := []struct {
+		env      map[string]string
+		args     []string
+		expected string
+	}{
+		{nil, nil, `[port: 9000 verbose: false ratio: 1.5 db: [host: "local" user: "root"] dry_run: false name: "system" tags: ["a" -1]]`},
+		{map[string]string{"APP_PORT": "1", "APP_DB_USER": "env", "APP_VERBOSE": "1"}, nil,
+			`[port: 1 verbose: true ratio: 1.5 db: [host: "local" user: "env"] dry_run: false name: "system" tags: ["a" -1]]`},
+		{map[string]string{"APP_PORT": "1"}, []string{"in.txt", "--port", "2", "--verbose", "--db.host=flag", "--dry-run", "--ratio=0.25", "--unknown", "--", "--name=x"},
+			`[port: 2 verbose: true ratio: 0.25 db: [host: "flag" user: "root"] dry_run: true name: "system" tags: ["a" -1]]`},
+		{map[string]string{"APP_CONFIG": filepath.Join(dir, "other.json")}, nil,
+			`[port: 8080 verbose: false ratio: 2.5 db: [host: "localhost" user: "json"] dry_run: false tags: [1 [k: true]]]`},
+		{nil, []string{"--config=" + filepath.Join(dir, "code.org")}, "Error: config: " + filepath.Join(dir, "code.org") + ": line 1: (1 + 2) is not data"},
+		{nil, []string{"--port=x"}, `Error: config: --port: "x" is not a number`},
+		{map[string]string{"APP_VERBOSE": "maybe"}, nil, `Error: config: APP_VERBOSE: "maybe" is not a Boolean`},
+	}
+	for _, tt := range tests {
+		for k, v := range tt.env {
+			t.Setenv(k, v)
+		}
+		in := New()
+		in.Args = tt.args
+		if got := evalIn(t, in, defaults); got != tt.expected {
+			t.Errorf("env %v, args %q:\n got %s\nwant %s", tt.env, tt.args, got, tt.expected)
+		}
+		for k := range tt.env {
+			t.Setenv(k, "")
+			os.Unsetenv(k)
+		}
+	}
+
+	in := New()
+	if got := evalIn(t, in, `cfg : "app" config ["port": 1]; [cfg.port (["a": 1] ?? 0)]`); got != "[9000 [a: 1]]" {
+		t.Errorf("got %s", got)
+	}
+}
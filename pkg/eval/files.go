@@ -0,0 +1,198 @@
+package eval
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Paths are Strings separated by /, as on the command line of a build
+// script; the file builtins convert them for the operating system.
+
+// globFiles is glob: the paths matching the pattern right, sorted. A
+// segment ** matches any number of directories. As in the shell, a
+// wildcard does not match a name starting with a dot unless its segment
+// does.
+func globFiles(_ *Interp, right Value) Value {
+	pattern, err := textOperand("glob", right)
+	if err != nil {
+		return err
+	}
+	segs := strings.Split(path.Clean(pattern), "/")
+	for _, s := range segs {
+		if _, merr := path.Match(s, ""); merr != nil {
+			return errorf("glob: bad pattern %s", pattern)
+		}
+	}
+	// The literal leading segments name the directory to search.
+	root := 0
+	for root < len(segs)-1 && !hasMeta(segs[root]) {
+		root++
+	}
+	base := path.Join(segs[:root]...)
+	if strings.HasPrefix(pattern, "/") {
+		base = "/" + base
+	}
+	segs = segs[root:]
+
+	t := &Table{}
+	werr := filepath.WalkDir(dirPath(base), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dirPath(base) && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		rel := relPath(dirPath(base), p)
+		if rel == "" {
+			return nil
+		}
+		parts := strings.Split(rel, "/")
+		if globMatch(segs, parts) {
+			t.push(evaluated(String(joinPath(base, rel))))
+		}
+		if d.IsDir() && !globPrefix(segs, parts) {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if werr != nil {
+		return errorf("glob: %v", werr)
+	}
+	return t
+}
+
+func hasMeta(s string) bool { return strings.ContainsAny(s, `*?[\`) }
+
+// globMatch reports whether the path segments parts match the pattern
+// segments segs.
+func globMatch(segs, parts []string) bool {
+	if len(segs) == 0 {
+		return len(parts) == 0
+	}
+	if segs[0] == "**" {
+		if globMatch(segs[1:], parts) {
+			return true
+		}
+		return len(parts) > 0 && !hidden("**", parts[0]) && globMatch(segs, parts[1:])
+	}
+	return len(parts) > 0 && segMatch(segs[0], parts[0]) && globMatch(segs[1:], parts[1:])
+}
+
+// globPrefix reports whether a path below the directory parts can still
+// match segs.
+func globPrefix(segs, parts []string) bool {
+	if len(parts) == 0 {
+		return true
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	if segs[0] == "**" {
+		return globPrefix(segs[1:], parts) || !hidden("**", parts[0]) && globPrefix(segs, parts[1:])
+	}
+	return segMatch(segs[0], parts[0]) && globPrefix(segs[1:], parts[1:])
+}
+
+func segMatch(seg, name string) bool {
+	ok, _ := path.Match(seg, name)
+	return ok && !hidden(seg, name)
+}
+
+func hidden(seg, name string) bool {
+	return strings.HasPrefix(name, ".") && !strings.HasPrefix(seg, ".")
+}
+
+// walkFiles is walk: a table [path: name: size: mtime:] for every file
+// below the directory right, in lexical order of their paths. mtime is
+// in seconds since the Unix epoch.
+func walkFiles(_ *Interp, right Value) Value {
+	dir, err := textOperand("walk", right)
+	if err != nil {
+		return err
+	}
+	t := &Table{}
+	werr := filepath.WalkDir(dirPath(dir), func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f := &Table{}
+		f.set(key{'s', "path"}, evaluated(String(joinPath(dir, relPath(dirPath(dir), p)))))
+		f.set(key{'s', "name"}, evaluated(String(d.Name())))
+		f.set(key{'s', "size"}, evaluated(Int(info.Size())))
+		f.set(key{'s', "mtime"}, evaluated(Int(info.ModTime().Unix())))
+		t.push(evaluated(f))
+		return nil
+	})
+	if werr != nil {
+		return errorf("walk: %v", werr)
+	}
+	return t
+}
+
+// dirPath is the operating system path of the directory p.
+func dirPath(p string) string {
+	if p == "" {
+		return "."
+	}
+	return filepath.FromSlash(p)
+}
+
+// relPath returns p relative to the walked directory root, with slashes.
+func relPath(root, p string) string {
+	rel, _ := filepath.Rel(root, p)
+	if rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// joinPath joins base and rel without cleaning away a leading "./".
+func joinPath(base, rel string) string {
+	switch {
+	case base == "":
+		return rel
+	case rel == "":
+		return base
+	case strings.HasSuffix(base, "/"):
+		return base + rel
+	}
+	return base + "/" + rel
+}
+
+// pathJoin is path_join: the parts in the table right joined with /.
+func pathJoin(in *Interp, right Value) Value {
+	t, ok := right.(*Table)
+	if !ok {
+		if e, ok := right.(*Error); ok {
+			return e
+		}
+		return errorf("path_join needs a Table, got %s", right)
+	}
+	var parts []string
+	for _, th := range t.items {
+		s, err := textOperand("path_join", th.force(in))
+		if err != nil {
+			return err
+		}
+		parts = append(parts, s)
+	}
+	return String(path.Join(parts...))
+}
+
+// pathFunc returns a builtin applying f to a String path.
+func pathFunc(name string, f func(string) string) func(*Interp, Value) Value {
+	return func(_ *Interp, right Value) Value {
+		p, err := textOperand(name, right)
+		if err != nil {
+			return err
+		}
+		return String(f(p))
+	}
+}
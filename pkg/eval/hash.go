@@ -0,0 +1,65 @@
+package eval
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+)
+
+// The digest builtins follow org_hash in the runtime (hash/hash.h): the
+// data is a String, hashed as UTF-8, or a Table of byte Integers such as
+// a raw digest; sha256, md5 and crc32 return lowercase hex, the _bytes
+// variants a Table of byte Integers. CRC-32 is big-endian.
+
+func sha256Sum(b []byte) []byte { s := sha256.Sum256(b); return s[:] }
+
+func md5Sum(b []byte) []byte { s := md5.Sum(b); return s[:] }
+
+func crc32Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(b))
+}
+
+// digest returns the builtin name computing sum, as hex or raw.
+func digest(name string, sum func([]byte) []byte, raw bool) func(*Interp, Value) Value {
+	return func(in *Interp, right Value) Value {
+		data, err := dataBytes(in, name, right)
+		if err != nil {
+			return err
+		}
+		d := sum(data)
+		if !raw {
+			return String(hex.EncodeToString(d))
+		}
+		t := &Table{}
+		for _, b := range d {
+			t.push(evaluated(Int(int64(b))))
+		}
+		return t
+	}
+}
+
+// dataBytes returns the bytes of a String or of a Table of byte Integers.
+func dataBytes(in *Interp, name string, v Value) ([]byte, *Error) {
+	switch v := v.(type) {
+	case *Error:
+		return nil, v
+	case String:
+		return []byte(v), nil
+	case *Table:
+		if len(v.keys) > 0 {
+			return nil, errorf("%s needs a String or a Table of bytes, got a keyed Table", name)
+		}
+		b := make([]byte, len(v.items))
+		for i, th := range v.items {
+			n, ok := th.force(in).(*Number)
+			if !ok || !n.isInt() || !n.Rat.Num().IsInt64() || n.Rat.Num().Int64() < 0 || n.Rat.Num().Int64() > 255 {
+				return nil, errorf("%s: %s is not a byte", name, th.force(in))
+			}
+			b[i] = byte(n.Rat.Num().Int64())
+		}
+		return b, nil
+	}
+	return nil, errorf("%s needs a String or a Table of bytes, got %s", name, v)
+}
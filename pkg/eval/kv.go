@@ -0,0 +1,295 @@
+package eval
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// A key-value store is a file-backed table: `kv_open "state.kv"` returns
+// the @kv resource of the store, read with kv_get and kv_items, written
+// with kv_put, kv_delete or a flow `[k: v] -> db`. The file is a log
+// appending one JSON record per change, keys and values in the form of
+// the value serializer:
+//
+//	{"k": {"s": "count"}, "v": {"i": "3"}}
+//	{"k": {"s": "count"}, "del": true}
+//
+// Every write is synced before it returns, so it survives a crash. A
+// record cut short by a crash at the end of the log is dropped on open.
+// Opening a store whose log holds more than twice as many records as
+// keys, and more than kvCompactMin, rewrites it with one record per key.
+
+// kvCompactMin is the smallest log compacted on open.
+const kvCompactMin = 1000
+
+// kvStore is an open store: its log and the table it replays to.
+type kvStore struct {
+	path string
+	data *Table
+}
+
+type kvRecord struct {
+	Key   json.RawMessage `json:"k"`
+	Value json.RawMessage `json:"v,omitempty"`
+	Del   bool            `json:"del,omitempty"`
+}
+
+// kvOpen is kv_open: the store at path right, created if missing. Each
+// path is opened once per interpreter; opening it again returns the same
+// store.
+func kvOpen(in *Interp, right Value) Value {
+	p, err := textOperand("kv_open", right)
+	if err != nil {
+		return err
+	}
+	abs, aerr := filepath.Abs(dirPath(p))
+	if aerr != nil {
+		return errorf("kv_open: %v", aerr)
+	}
+	if s, ok := in.stores[abs]; ok {
+		return &Resource{Name: "kv", store: s}
+	}
+	s, oerr := openStore(abs)
+	if oerr != nil {
+		return errorf("kv_open: %v", oerr)
+	}
+	if in.stores == nil {
+		in.stores = map[string]*kvStore{}
+	}
+	in.stores[abs] = s
+	return &Resource{Name: "kv", store: s}
+}
+
+func openStore(path string) (*kvStore, error) {
+	s := &kvStore{path: path, data: &Table{}}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	records, end := 0, 0
+	for line := 1; end < len(b); line++ {
+		rec, _, complete := bytes.Cut(b[end:], []byte("\n"))
+		if !complete {
+			// Cut short by a crash: drop it, so the next record does
+			// not continue it.
+			if err := os.Truncate(path, int64(end)); err != nil {
+				return nil, err
+			}
+			break
+		}
+		if err := s.replay(rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		records++
+		end += len(rec) + 1
+	}
+	if records > kvCompactMin && records > 2*s.data.Len() {
+		if err := s.compact(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// replay applies one record of the log.
+func (s *kvStore) replay(line []byte) error {
+	var r kvRecord
+	if err := json.Unmarshal(line, &r); err != nil {
+		return err
+	}
+	kv, err := unmarshalValue(r.Key)
+	if err != nil {
+		return err
+	}
+	k, ok := keyOf(kv)
+	if !ok {
+		return fmt.Errorf("bad key %s", r.Key)
+	}
+	if r.Del {
+		s.data.remove(k)
+		return nil
+	}
+	v, err := unmarshalValue(r.Value)
+	if err != nil {
+		return err
+	}
+	s.data.set(k, evaluated(v))
+	return nil
+}
+
+// compact rewrites the log with one record per key, replacing it
+// atomically.
+func (s *kvStore) compact() error {
+	var buf bytes.Buffer
+	for _, k := range s.data.keys {
+		v, _ := serialize(s.data.byKey[k].value)
+		line, err := json.Marshal(map[string]any{"k": serializeKey(k), "v": v})
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	tmp := s.path + ".tmp"
+	if err := writeSynced(tmp, buf.Bytes(), os.O_TRUNC); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// append writes records to the end of the log and syncs it.
+func (s *kvStore) append(records [][]byte) error {
+	var buf bytes.Buffer
+	for _, r := range records {
+		buf.Write(r)
+		buf.WriteByte('\n')
+	}
+	return writeSynced(s.path, buf.Bytes(), os.O_APPEND)
+}
+
+func writeSynced(path string, data []byte, mode int) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|mode, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	w.Write(data)
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// storeOperand returns the store of the @kv resource v.
+func storeOperand(name string, v Value) (*kvStore, *Error) {
+	if e, ok := v.(*Error); ok {
+		return nil, e
+	}
+	if r, ok := v.(*Resource); ok && r.store != nil {
+		return r.store, nil
+	}
+	return nil, errorf("%s needs a store from kv_open, got %s", name, v)
+}
+
+// keyOperand returns the key of v, a String, Number or Boolean.
+func keyOperand(in *Interp, name string, v Value) (key, *Error) {
+	if e, ok := v.(*Error); ok {
+		return key{}, e
+	}
+	k, ok := keyOf(v)
+	if !ok {
+		forceDeep(in, v)
+		return key{}, errorf("%s: %s cannot be a key", name, v)
+	}
+	return k, nil
+}
+
+// kvGet is `db kv_get key`: the value stored under key.
+func kvGet(in *Interp, left, right Value) Value {
+	s, err := storeOperand("kv_get", left)
+	if err != nil {
+		return err
+	}
+	k, err := keyOperand(in, "kv_get", right)
+	if err != nil {
+		return err
+	}
+	if th, ok := s.data.get(k); ok {
+		return th.value
+	}
+	return errorf("kv_get: no key %s", k)
+}
+
+// kvPut is `db kv_put table`, and the flow `table -> db`: store each entry
+// of table under its key, positional entries under their index, and
+// return db.
+func kvPut(in *Interp, left, right Value) Value {
+	s, err := storeOperand("kv_put", left)
+	if err != nil {
+		return err
+	}
+	if e, ok := right.(*Error); ok {
+		return e
+	}
+	t, ok := right.(*Table)
+	if !ok {
+		return errorf("kv_put needs a table of entries, got %s", right)
+	}
+	var keys []key
+	var values []Value
+	for i, th := range t.items {
+		keys = append(keys, key{'i', fmt.Sprint(i)})
+		values = append(values, th.force(in))
+	}
+	for _, k := range t.keys {
+		keys = append(keys, k)
+		values = append(values, t.byKey[k].force(in))
+	}
+	var records [][]byte
+	for i, k := range keys {
+		v, err := marshalValue(in, values[i])
+		if err != nil {
+			return errorf("kv_put: %s: %s", k, err.Message)
+		}
+		kb, _ := json.Marshal(serializeKey(k))
+		line, _ := json.Marshal(kvRecord{Key: kb, Value: v})
+		records = append(records, line)
+	}
+	if werr := s.append(records); werr != nil {
+		return errorf("kv_put: %v", werr)
+	}
+	for i, k := range keys {
+		s.data.set(k, evaluated(values[i]))
+	}
+	return left
+}
+
+// kvDelete is `db kv_delete key`: remove key from the store and return
+// db. Deleting a missing key does nothing.
+func kvDelete(in *Interp, left, right Value) Value {
+	s, err := storeOperand("kv_delete", left)
+	if err != nil {
+		return err
+	}
+	k, err := keyOperand(in, "kv_delete", right)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.data.get(k); !ok {
+		return left
+	}
+	kb, _ := json.Marshal(serializeKey(k))
+	line, _ := json.Marshal(kvRecord{Key: kb, Del: true})
+	if werr := s.append([][]byte{line}); werr != nil {
+		return errorf("kv_delete: %v", werr)
+	}
+	s.data.remove(k)
+	return left
+}
+
+// kvItems is `kv_items db`: a table of the entries of the store, in the
+// order their keys were first stored.
+func kvItems(_ *Interp, right Value) Value {
+	s, err := storeOperand("kv_items", right)
+	if err != nil {
+		return err
+	}
+	out := &Table{}
+	for _, k := range s.data.keys {
+		out.set(k, s.data.byKey[k])
+	}
+	return out
+}
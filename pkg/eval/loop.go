@@ -0,0 +1,50 @@
+package eval
+
+// A loop is `init loop op`: op called on init, then on each of its
+// results in turn, until one is `break value`, which ends the loop with
+// value. The state passed around is usually a table, taken apart with a
+// pattern and stopped with a guard:
+//
+//	[48 18] loop { [a b] : right; b = 0 !> break a; [b (a % b)] }
+//
+// Each state is evaluated in full before the next call, so a long loop
+// does not build a chain of pending computations. An Error result ends
+// the loop with the Error; the deadline in force is checked on every call
+// like on any other.
+
+// Break is `break value`, the result ending a loop with value. Outside a
+// loop it is a plain value, printed as (break value).
+type Break struct {
+	Value Value
+}
+
+func (b *Break) String() string { return "(break " + b.Value.String() + ")" }
+
+func breakLoop(_ *Interp, right Value) Value {
+	return &Break{Value: right}
+}
+
+// loop is `init loop op`.
+func loop(in *Interp, left, right Value) Value {
+	if e, ok := left.(*Error); ok {
+		return e
+	}
+	if e, ok := right.(*Error); ok {
+		return e
+	}
+	if arity(right) != 1 {
+		return errorf("loop needs a unary operator, got %s", right)
+	}
+	state := left
+	for {
+		next := in.call(right, nil, state)
+		forceDeep(in, next)
+		switch v := next.(type) {
+		case *Break:
+			return v.Value
+		case *Error:
+			return v
+		}
+		state = next
+	}
+}
@@ -0,0 +1,75 @@
+package eval
+
+import (
+	"fmt"
+
+	"orglang/pkg/ast"
+)
+
+// Exit statuses of a program, the same as those of compiled programs
+// (pkg/runtime/core/status.h).
+const (
+	ExitOK       = 0
+	ExitError    = 1 // an uncaught Error
+	ExitNoMain   = 2 // the entry module defines no main
+	ExitResource = 4 // writing the result failed
+)
+
+// Run evaluates prog as the entry module named module and then its main.
+//
+// A main that is an operator is called with the command-line arguments
+// (the table @args) as its right operand; any other main is the result
+// itself. The result gives the exit status, as org_finish does for
+// compiled programs: an Error is reported on Stderr and exits 1, an
+// Integer from 0 to 255 is the status, a Table has its positional
+// elements written to Stdout one per line, and anything else exits 0.
+// A run that outlives in.Deadline is an Error, whatever main returned.
+func (in *Interp) Run(prog *ast.Program, module string) int {
+	in.Eval(prog)
+	th, ok := in.Global.vars["main"]
+	if !ok {
+		fmt.Fprintf(in.Stderr, "Error: no main in %s\n", module)
+		return ExitNoMain
+	}
+	result := th.force(in)
+	if arity(result) >= 0 {
+		result = in.call(result, nil, in.args())
+	}
+	if err := in.expired(); err != nil {
+		result = err
+	}
+	return in.finish(result)
+}
+
+func (in *Interp) finish(result Value) int {
+	switch v := result.(type) {
+	case *Error:
+		fmt.Fprintln(in.Stderr, v)
+		return ExitError
+	case *Number:
+		if !v.isInt() {
+			return ExitOK
+		}
+		if n := v.Rat.Num(); n.IsInt64() && n.Int64() >= 0 && n.Int64() <= 255 {
+			return int(n.Int64())
+		}
+		fmt.Fprintf(in.Stderr, "Error: exit status %s out of range 0-255\n", v)
+		return ExitError
+	case *Table:
+		for _, th := range v.items {
+			if _, err := fmt.Fprintln(in.Stdout, Display(in.Force(th.force(in)))); err != nil {
+				return ExitResource
+			}
+		}
+	}
+	return ExitOK
+}
+
+// args returns the command-line arguments as a table of strings.
+func (in *Interp) args() *Table {
+	t := &Table{}
+	for _, a := range in.Args {
+		t.push(evaluated(String(a)))
+	}
+	return t
+}
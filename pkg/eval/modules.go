@@ -0,0 +1,57 @@
+package eval
+
+import (
+	"orglang/pkg/ast"
+)
+
+// Loader reads the module that the file from imports as path, in
+// `alias : "path" @ org`: it returns the module's canonical path, naming
+// it once however it is imported, and its program. from is "" for a
+// program without a file, such as a REPL line.
+type Loader func(from, path string) (string, *ast.Program, error)
+
+// importModule evaluates "path" @ org. The module's top level runs the
+// first time it is imported, in a scope of its own under the global one,
+// and the import is the table of its bindings; later imports of the same
+// module give the same table. A module imported while it is still
+// loading, in a cycle, is an Error.
+func (in *Interp) importModule(n *ast.InfixExpr) Value {
+	lit, isStr := n.Left.(*ast.StringLiteral)
+	org, isName := n.Right.(*ast.Name)
+	if !isStr || !isName || org.Value != "org" {
+		return errorf(`a module is imported as "path" @ org`)
+	}
+	if in.Modules == nil {
+		return errorf("module loading is not supported here")
+	}
+	path, prog, err := in.Modules(in.File, lit.Value)
+	if err != nil {
+		return errorf("%s", err)
+	}
+	if t, ok := in.modules[path]; ok {
+		if t == nil {
+			return errorf("import cycle through %s", path)
+		}
+		return t
+	}
+	if in.modules == nil {
+		in.modules = make(map[string]*Table)
+	}
+	in.modules[path] = nil
+
+	file, depth := in.File, in.depth
+	in.File, in.depth = path, 0
+	env := NewEnv(in.Global)
+	for _, s := range prog.Statements {
+		in.Coverage.mark(s)
+		in.eval(s, env)
+	}
+	in.File, in.depth = file, depth
+
+	t := &Table{}
+	for _, name := range env.Names() {
+		t.set(key{'s', name}, env.vars[name])
+	}
+	in.modules[path] = t
+	return t
+}
@@ -0,0 +1,468 @@
+package eval
+
+import (
+	"math/big"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// number coerces v for arithmetic and comparison: numbers as themselves,
+// booleans as 1 or 0, strings and tables as their size (README
+// §Arithmetic operators).
+func number(v Value) (*Number, *Error) {
+	switch v := v.(type) {
+	case *Number:
+		return v, nil
+	case *Quantity:
+		return v.Amount, nil
+	case Boolean:
+		if v {
+			return Int(1), nil
+		}
+		return Int(0), nil
+	case String:
+		return Int(int64(runeLen(v))), nil
+	case *Table:
+		return Int(int64(v.Len())), nil
+	case *Error:
+		return nil, v
+	}
+	return nil, errorf("%s is not a number", v)
+}
+
+func integer(v Value) (*big.Int, *Error) {
+	n, err := number(v)
+	if err != nil {
+		return nil, err
+	}
+	if !n.isInt() {
+		return nil, errorf("%s is not an integer", n)
+	}
+	return n.Rat.Num(), nil
+}
+
+// Truthy reports whether v counts as true for ?:, &&, || and !: false,
+// zero, empty strings and tables, and Errors are false.
+func Truthy(v Value) bool {
+	switch v := v.(type) {
+	case Boolean:
+		return bool(v)
+	case *Number:
+		return v.Rat.Sign() != 0
+	case *Quantity:
+		return v.Amount.Rat.Sign() != 0
+	case String:
+		return v != ""
+	case *Table:
+		return v.Len() > 0
+	case *Error:
+		return false
+	}
+	return true
+}
+
+// arith combines two numbers with the scale rules of the C runtime
+// (pkg/runtime/ops): a Decimal result keeps the larger scale for + and -
+// and the sum of scales for *.
+func arith(a, b Value, op func(x, y *big.Rat) *big.Rat, scale func(x, y int) int) Value {
+	x, err := number(a)
+	if err != nil {
+		return err
+	}
+	y, err := number(b)
+	if err != nil {
+		return err
+	}
+	q := op(x.Rat, y.Rat)
+	if x.Kind == Decimal || y.Kind == Decimal {
+		return decNumber(q, scale(x.Scale, y.Scale))
+	}
+	return ratNumber(q)
+}
+
+func maxScale(x, y int) int { return max(x, y) }
+
+func add(_ *Interp, a, b Value) Value {
+	return arith(a, b, func(x, y *big.Rat) *big.Rat { return new(big.Rat).Add(x, y) }, maxScale)
+}
+
+func sub(_ *Interp, a, b Value) Value {
+	return arith(a, b, func(x, y *big.Rat) *big.Rat { return new(big.Rat).Sub(x, y) }, maxScale)
+}
+
+func mul(_ *Interp, a, b Value) Value {
+	return arith(a, b, func(x, y *big.Rat) *big.Rat { return new(big.Rat).Mul(x, y) },
+		func(x, y int) int { return x + y })
+}
+
+func div(_ *Interp, a, b Value) Value {
+	x, err := number(a)
+	if err != nil {
+		return err
+	}
+	y, err := number(b)
+	if err != nil {
+		return err
+	}
+	if y.Rat.Sign() == 0 {
+		return errorf("division by zero")
+	}
+	q := new(big.Rat).Quo(x.Rat, y.Rat)
+	if x.Kind == Decimal || y.Kind == Decimal {
+		scale := x.Scale
+		if scale == 0 {
+			scale = y.Scale
+		}
+		return decNumber(q, max(scale, 1))
+	}
+	return ratNumber(q)
+}
+
+func mod(_ *Interp, a, b Value) Value {
+	x, err := integer(a)
+	if err != nil {
+		return err
+	}
+	y, err := integer(b)
+	if err != nil {
+		return err
+	}
+	if y.Sign() == 0 {
+		return errorf("division by zero")
+	}
+	return ratNumber(new(big.Rat).SetInt(new(big.Int).Rem(x, y)))
+}
+
+func pow(_ *Interp, a, b Value) Value {
+	x, err := number(a)
+	if err != nil {
+		return err
+	}
+	e, err := integer(b)
+	if err != nil {
+		return err
+	}
+	if e.Sign() < 0 || !e.IsInt64() || e.Int64() > 1<<20 {
+		return errorf("exponent %s out of range", e)
+	}
+	n := e.Int64()
+	num := new(big.Int).Exp(x.Rat.Num(), e, nil)
+	den := new(big.Int).Exp(x.Rat.Denom(), e, nil)
+	q := new(big.Rat).SetFrac(num, den)
+	if x.Kind == Decimal {
+		return decNumber(q, x.Scale*int(n))
+	}
+	return ratNumber(q)
+}
+
+// sqrtPlaces is the number of decimal places of an inexact square root.
+const sqrtPlaces = 16
+
+// sqrt is the square root of a non-negative number: exact when the
+// operand is the square of an Integer or Rational, else a Decimal of
+// sqrtPlaces places, rounded down.
+func sqrt(_ *Interp, a Value) Value {
+	x, err := number(a)
+	if err != nil {
+		return err
+	}
+	if x.Rat.Sign() < 0 {
+		return errorf("sqrt of negative number %s", x)
+	}
+	num, den := new(big.Int).Sqrt(x.Rat.Num()), new(big.Int).Sqrt(x.Rat.Denom())
+	if q := new(big.Rat).SetFrac(num, den); new(big.Rat).Mul(q, q).Cmp(x.Rat) == 0 {
+		if x.Kind == Decimal {
+			return decNumber(q, x.Scale)
+		}
+		return ratNumber(q)
+	}
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(sqrtPlaces), nil)
+	scaled := new(big.Int).Mul(x.Rat.Num(), new(big.Int).Mul(unit, unit))
+	scaled.Quo(scaled, x.Rat.Denom())
+	return decNumber(new(big.Rat).SetFrac(scaled.Sqrt(scaled), unit), sqrtPlaces)
+}
+
+func neg(_ *Interp, a Value) Value {
+	x, err := number(a)
+	if err != nil {
+		return err
+	}
+	return &Number{Kind: x.Kind, Rat: new(big.Rat).Neg(x.Rat), Scale: x.Scale}
+}
+
+// bitwise applies op to integers, or, for two booleans, its logical
+// counterpart without short-circuit (README §Boolean operators).
+func bitwise(op func(z, x, y *big.Int) *big.Int, logical func(x, y bool) bool) func(*Interp, Value, Value) Value {
+	return func(_ *Interp, a, b Value) Value {
+		if x, ok := a.(Boolean); ok {
+			if y, ok := b.(Boolean); ok {
+				return Boolean(logical(bool(x), bool(y)))
+			}
+		}
+		x, err := integer(a)
+		if err != nil {
+			return err
+		}
+		y, err := integer(b)
+		if err != nil {
+			return err
+		}
+		return ratNumber(new(big.Rat).SetInt(op(new(big.Int), x, y)))
+	}
+}
+
+func shift(left bool) func(*Interp, Value, Value) Value {
+	return func(_ *Interp, a, b Value) Value {
+		x, err := integer(a)
+		if err != nil {
+			return err
+		}
+		n, err := integer(b)
+		if err != nil {
+			return err
+		}
+		if n.Sign() < 0 || !n.IsInt64() || n.Int64() > 1<<20 {
+			return errorf("shift %s out of range", n)
+		}
+		z := new(big.Int)
+		if left {
+			z.Lsh(x, uint(n.Int64()))
+		} else {
+			z.Rsh(x, uint(n.Int64()))
+		}
+		return ratNumber(new(big.Rat).SetInt(z))
+	}
+}
+
+// compare orders two values after numeric coercion.
+func compare(pred func(c int) bool) func(*Interp, Value, Value) Value {
+	return func(_ *Interp, a, b Value) Value {
+		x, err := number(a)
+		if err != nil {
+			return err
+		}
+		y, err := number(b)
+		if err != nil {
+			return err
+		}
+		return Boolean(pred(x.Rat.Cmp(y.Rat)))
+	}
+}
+
+// equal is = : strings compare by content and booleans by value, other
+// values numerically after coercion.
+func equal(in *Interp, a, b Value) Value {
+	if e, ok := a.(*Error); ok {
+		return e
+	}
+	if e, ok := b.(*Error); ok {
+		return e
+	}
+	if x, ok := a.(String); ok {
+		if y, ok := b.(String); ok {
+			return Boolean(x == y)
+		}
+	}
+	if x, ok := a.(Boolean); ok {
+		if y, ok := b.(Boolean); ok {
+			return Boolean(x == y)
+		}
+	}
+	return compare(func(c int) bool { return c == 0 })(in, a, b)
+}
+
+func notEqual(in *Interp, a, b Value) Value {
+	v := equal(in, a, b)
+	if eq, ok := v.(Boolean); ok {
+		return !eq
+	}
+	return v
+}
+
+func not(_ *Interp, a Value) Value {
+	if e, ok := a.(*Error); ok {
+		return e
+	}
+	return Boolean(!Truthy(a))
+}
+
+func complement(_ *Interp, a Value) Value {
+	x, err := integer(a)
+	if err != nil {
+		return err
+	}
+	return ratNumber(new(big.Rat).SetInt(new(big.Int).Not(x)))
+}
+
+// logical is && or || applied to evaluated operands, when used as a value
+// (`true |> &&`); the infix forms short-circuit in eval.
+func logical(and bool) func(*Interp, Value, Value) Value {
+	return func(_ *Interp, a, b Value) Value {
+		if e, ok := a.(*Error); ok {
+			return e
+		}
+		if Truthy(a) != and {
+			return Boolean(!and)
+		}
+		if e, ok := b.(*Error); ok {
+			return e
+		}
+		return Boolean(Truthy(b))
+	}
+}
+
+func coalesce(_ *Interp, a, b Value) Value {
+	if _, ok := a.(*Error); ok {
+		return b
+	}
+	return a
+}
+
+// interpolate is `template $ table`: $0, $1, ... are replaced by the
+// positional elements of table and $name by its keyed entries (README
+// §String Interpolation).
+func interpolate(in *Interp, a, b Value) Value {
+	if e, ok := a.(*Error); ok {
+		return e
+	}
+	if e, ok := b.(*Error); ok {
+		return e
+	}
+	tmpl, ok := a.(String)
+	if !ok {
+		return errorf("$ needs a template string, not %s", a)
+	}
+	t, ok := b.(*Table)
+	if !ok {
+		t = &Table{}
+		t.push(evaluated(b))
+	}
+
+	var out strings.Builder
+	s := string(tmpl)
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 {
+			out.WriteString(s)
+			break
+		}
+		out.WriteString(s[:i])
+		s = s[i+1:]
+		name := placeholder(s)
+		if name == "" {
+			out.WriteByte('$')
+			continue
+		}
+		s = s[len(name):]
+		var k key
+		if name[0] >= '0' && name[0] <= '9' {
+			k = key{'i', strings.TrimLeft(name, "0")}
+			if k.s == "" {
+				k.s = "0"
+			}
+		} else {
+			k = key{'s', name}
+		}
+		th, ok := t.get(k)
+		if !ok {
+			return errorf("no value for $%s", name)
+		}
+		v := th.force(in)
+		if e, ok := v.(*Error); ok {
+			return e
+		}
+		out.WriteString(Display(v))
+	}
+	return String(out.String())
+}
+
+// placeholder returns the digits or identifier at the start of s.
+func placeholder(s string) string {
+	if s != "" && s[0] >= '0' && s[0] <= '9' {
+		return s[:len(s)-len(strings.TrimLeft(s, "0123456789"))]
+	}
+	n := 0
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if r != '_' && !unicode.IsLetter(r) && (n == 0 || !unicode.IsDigit(r)) {
+			break
+		}
+		n += size
+	}
+	return s[:n]
+}
+
+func inc(in *Interp, a Value) Value { return add(in, a, Int(1)) }
+func dec(in *Interp, a Value) Value { return sub(in, a, Int(1)) }
+
+// builtins are the predefined operators, bound in every Interp's global
+// scope under their names.
+func builtins() []*Builtin {
+	return []*Builtin{
+		{Name: "+", binary: add},
+		{Name: "-", binary: sub, unary: neg},
+		{Name: "*", binary: mul},
+		{Name: "/", binary: div},
+		{Name: "%", binary: mod},
+		{Name: "**", binary: pow},
+		{Name: "sqrt", unary: sqrt},
+		{Name: "&", binary: bitwise((*big.Int).And, func(x, y bool) bool { return x && y })},
+		{Name: "|", binary: bitwise((*big.Int).Or, func(x, y bool) bool { return x || y })},
+		{Name: "^", binary: bitwise((*big.Int).Xor, func(x, y bool) bool { return x != y })},
+		{Name: "<<", binary: shift(true)},
+		{Name: ">>", binary: shift(false)},
+		{Name: "=", binary: equal},
+		{Name: "<>", binary: notEqual},
+		{Name: "~=", binary: notEqual},
+		{Name: "<", binary: compare(func(c int) bool { return c < 0 })},
+		{Name: ">", binary: compare(func(c int) bool { return c > 0 })},
+		{Name: "<=", binary: compare(func(c int) bool { return c <= 0 })},
+		{Name: ">=", binary: compare(func(c int) bool { return c >= 0 })},
+		{Name: "&&", binary: logical(true)},
+		{Name: "||", binary: logical(false)},
+		{Name: "??", binary: coalesce},
+		{Name: "$", binary: interpolate},
+		{Name: "!", unary: not},
+		{Name: "~", unary: complement},
+		{Name: "++", unary: inc},
+		{Name: "--", unary: dec},
+		{Name: "yaml_parse", unary: yamlParse},
+		{Name: "toml_parse", unary: tomlParse},
+		{Name: "config", binary: config},
+		{Name: "glob", unary: globFiles},
+		{Name: "walk", unary: walkFiles},
+		{Name: "path_join", unary: pathJoin},
+		{Name: "path_dir", unary: pathFunc("path_dir", path.Dir)},
+		{Name: "path_base", unary: pathFunc("path_base", path.Base)},
+		{Name: "path_ext", unary: pathFunc("path_ext", path.Ext)},
+		{Name: "sha256", unary: digest("sha256", sha256Sum, false)},
+		{Name: "sha256_bytes", unary: digest("sha256_bytes", sha256Sum, true)},
+		{Name: "md5", unary: digest("md5", md5Sum, false)},
+		{Name: "md5_bytes", unary: digest("md5_bytes", md5Sum, true)},
+		{Name: "crc32", unary: digest("crc32", crc32Sum, false)},
+		{Name: "crc32_bytes", unary: digest("crc32_bytes", crc32Sum, true)},
+		{Name: "prompt", unary: prompt("prompt", true)},
+		{Name: "password", unary: prompt("password", false)},
+		{Name: "read_key", unary: readKey},
+		{Name: "tty_size", unary: ttySize},
+		{Name: "fg", binary: colorize("fg", 30)},
+		{Name: "bg", binary: colorize("bg", 40)},
+		{Name: "bold", unary: attribute("1", "22")},
+		{Name: "underline", unary: attribute("4", "24")},
+		{Name: "deadline", binary: deadline},
+		{Name: "throttle", binary: throttle},
+		{Name: "batch", unary: batch},
+		{Name: "window", unary: window},
+		{Name: "kv_open", unary: kvOpen},
+		{Name: "kv_items", unary: kvItems},
+		{Name: "kv_get", binary: kvGet},
+		{Name: "kv_put", binary: kvPut},
+		{Name: "kv_delete", binary: kvDelete},
+		{Name: "scan", binary: scan},
+		{Name: "checkpoint", binary: checkpoint},
+		{Name: "loop", binary: loop},
+		{Name: "break", unary: breakLoop},
+	}
+}
@@ -0,0 +1,98 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// @progress is a pass-through sink: `source -> @progress -> work` flows the
+// same elements into work, drawing a progress line on stderr as each one
+// is done. Nothing is drawn unless stderr is a terminal.
+
+// stderrIsTerminal reports whether os.Stderr is a terminal, where New
+// enables @progress.
+func stderrIsTerminal() bool { return term.IsTerminal(os.Stderr.Fd()) }
+
+// progressInterval is the shortest time between two redraws.
+const progressInterval = 100 * time.Millisecond
+
+// progressWidth is the width of the bar in cells.
+const progressWidth = 20
+
+var spinner = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// progress is the progress line of a table flowing through @progress.
+type progress struct {
+	w           io.Writer
+	now         func() time.Time
+	total, done int
+	start, last time.Time
+	frame       int
+}
+
+// track is `src -> @progress`: src itself, or, when progress is enabled,
+// a copy of the table src carrying a progress line for the next flow.
+func (in *Interp) track(src Value) Value {
+	t, ok := src.(*Table)
+	if !ok || !in.Progress {
+		return src
+	}
+	c := *t
+	c.progress = &progress{w: in.Stderr, now: in.clock, total: t.Len(), start: in.clock()}
+	return &c
+}
+
+// tick records one more element done, redrawing at most once per
+// progressInterval.
+func (p *progress) tick() {
+	if p == nil {
+		return
+	}
+	p.done++
+	if now := p.now(); now.Sub(p.last) >= progressInterval {
+		p.last = now
+		p.frame = (p.frame + 1) % len(spinner)
+		p.draw(now, false)
+	}
+}
+
+// finish draws the final line and ends it.
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	p.draw(p.now(), true)
+}
+
+func (p *progress) draw(now time.Time, final bool) {
+	elapsed := now.Sub(p.start)
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(p.done) / elapsed.Seconds()
+	}
+	var b strings.Builder
+	b.WriteString("\r\x1b[K")
+	if final {
+		fmt.Fprintf(&b, "done %d/%d in %s (%.1f/s)\n", p.done, p.total, elapsed.Round(100*time.Millisecond), rate)
+		io.WriteString(p.w, b.String())
+		return
+	}
+	filled := 0
+	pct := 100
+	if p.total > 0 {
+		filled = p.done * progressWidth / p.total
+		pct = p.done * 100 / p.total
+	}
+	eta := "?"
+	if rate > 0 {
+		eta = time.Duration(float64(p.total-p.done) / rate * float64(time.Second)).Round(time.Second).String()
+	}
+	fmt.Fprintf(&b, "%s %s%s %3d%% %d/%d %.1f/s ETA %s", spinner[p.frame],
+		strings.Repeat("█", filled), strings.Repeat("░", progressWidth-filled), pct, p.done, p.total, rate, eta)
+	io.WriteString(p.w, b.String())
+}
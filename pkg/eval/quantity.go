@@ -0,0 +1,71 @@
+package eval
+
+import (
+	"math/big"
+	"strings"
+)
+
+// QuantityKind distinguishes durations from sizes.
+type QuantityKind int
+
+const (
+	Duration QuantityKind = iota
+	Size
+)
+
+// Quantity is a duration or a size, written with a unit: 250ms, 10KB. Its
+// Amount is the exact number of seconds or bytes; operators read it as
+// that number, so 1s + 500ms is 3/2 and 1KiB > 1KB, while the Quantity
+// itself prints as written.
+type Quantity struct {
+	Kind   QuantityKind
+	Amount *Number
+	Value  string // the number as written: 250
+	Unit   string // ms
+}
+
+func (q *Quantity) String() string { return q.Value + q.Unit }
+
+// units gives the kind of each unit suffix (token.Units) and its size in
+// seconds or bytes.
+var units = map[string]struct {
+	kind  QuantityKind
+	scale *big.Rat
+}{
+	"ns": {Duration, big.NewRat(1, 1_000_000_000)},
+	"us": {Duration, big.NewRat(1, 1_000_000)},
+	"ms": {Duration, big.NewRat(1, 1_000)},
+	"s":  {Duration, big.NewRat(1, 1)},
+	"m":  {Duration, big.NewRat(60, 1)},
+	"h":  {Duration, big.NewRat(3600, 1)},
+
+	"B":   {Size, big.NewRat(1, 1)},
+	"KB":  {Size, big.NewRat(1_000, 1)},
+	"MB":  {Size, big.NewRat(1_000_000, 1)},
+	"GB":  {Size, big.NewRat(1_000_000_000, 1)},
+	"TB":  {Size, big.NewRat(1_000_000_000_000, 1)},
+	"KiB": {Size, big.NewRat(1<<10, 1)},
+	"MiB": {Size, big.NewRat(1<<20, 1)},
+	"GiB": {Size, big.NewRat(1<<30, 1)},
+	"TiB": {Size, big.NewRat(1<<40, 1)},
+}
+
+// quantity returns the Quantity of the number value (an integer or a
+// decimal) in unit.
+func quantity(value, unit string) (*Quantity, *Error) {
+	u, ok := units[unit]
+	if !ok {
+		return nil, errorf("unknown unit %s", unit)
+	}
+	n, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return nil, errorf("invalid quantity %s%s", value, unit)
+	}
+	return &Quantity{Kind: u.kind, Amount: ratNumber(n.Mul(n, u.scale)), Value: value, Unit: unit}, nil
+}
+
+// quantityText reads a quantity as printed, the number then the unit.
+func quantityText(s string) (*Quantity, *Error) {
+	i := strings.LastIndexFunc(s, func(r rune) bool { return r >= '0' && r <= '9' }) + 1
+	return quantity(s[:i], s[i:])
+}
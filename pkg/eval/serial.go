@@ -0,0 +1,182 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// The value serializer writes data values as JSON, each an object with
+// one member naming its type, so they read back exactly:
+//
+//	{"i": "42"}  {"q": "3/2"}  {"d": "1.50"}  {"s": "text"}  {"b": true}
+//	{"u": "250ms"}  {"e": "message"}  {"t": [items...], "k": [[key, value]...]}
+//
+// Numbers are kept as text, so no precision is lost. Operators and
+// resources have no serialized form.
+
+// marshalValue returns the serialized form of v, forcing it in full.
+func marshalValue(in *Interp, v Value) ([]byte, *Error) {
+	forceDeep(in, v)
+	s, err := serialize(v)
+	if err != nil {
+		return nil, err
+	}
+	b, jerr := json.Marshal(s)
+	if jerr != nil {
+		return nil, errorf("%v", jerr)
+	}
+	return b, nil
+}
+
+// MarshalValue returns the serialized form of v, forcing it in full. An
+// operator or a resource, which has none, is an error.
+func (in *Interp) MarshalValue(v Value) ([]byte, error) {
+	b, err := marshalValue(in, v)
+	if err != nil {
+		return nil, fmt.Errorf("%s", err.Message)
+	}
+	return b, nil
+}
+
+// UnmarshalValue reads a value written by MarshalValue.
+func UnmarshalValue(b []byte) (Value, error) {
+	return unmarshalValue(b)
+}
+
+func serialize(v Value) (any, *Error) {
+	switch v := v.(type) {
+	case *Number:
+		switch v.Kind {
+		case Decimal:
+			return map[string]string{"d": v.String()}, nil
+		case Rational:
+			return map[string]string{"q": v.String()}, nil
+		}
+		return map[string]string{"i": v.String()}, nil
+	case *Quantity:
+		return map[string]string{"u": v.String()}, nil
+	case String:
+		return map[string]string{"s": string(v)}, nil
+	case Boolean:
+		return map[string]bool{"b": bool(v)}, nil
+	case *Error:
+		return map[string]string{"e": v.Message}, nil
+	case *Table:
+		items := []any{}
+		for _, th := range v.items {
+			s, err := serialize(th.value)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, s)
+		}
+		keyed := [][2]any{}
+		for _, k := range v.keys {
+			s, err := serialize(v.byKey[k].value)
+			if err != nil {
+				return nil, err
+			}
+			keyed = append(keyed, [2]any{serializeKey(k), s})
+		}
+		return map[string]any{"t": items, "k": keyed}, nil
+	}
+	return nil, errorf("cannot serialize %s", v)
+}
+
+func serializeKey(k key) any {
+	switch k.kind {
+	case 'i':
+		return map[string]string{"i": k.s}
+	case 'n':
+		return map[string]string{"q": k.s}
+	case 'b':
+		return map[string]bool{"b": k.s == "true"}
+	}
+	return map[string]string{"s": k.s}
+}
+
+// unmarshalValue reads a value written by marshalValue.
+func unmarshalValue(b []byte) (Value, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	if t, ok := m["t"]; ok {
+		return unmarshalTable(t, m["k"])
+	}
+	if len(m) != 1 {
+		return nil, fmt.Errorf("bad value %s", b)
+	}
+	for tag, raw := range m {
+		if tag == "b" {
+			var x bool
+			if err := json.Unmarshal(raw, &x); err != nil {
+				return nil, err
+			}
+			return Boolean(x), nil
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		switch tag {
+		case "s":
+			return String(s), nil
+		case "e":
+			return &Error{Message: s}, nil
+		case "i", "q":
+			q, ok := new(big.Rat).SetString(s)
+			if !ok {
+				return nil, fmt.Errorf("bad number %q", s)
+			}
+			return ratNumber(q), nil
+		case "d":
+			return decimalText(s), nil
+		case "u":
+			q, err := quantityText(s)
+			if err != nil {
+				return nil, fmt.Errorf("bad quantity %q", s)
+			}
+			return q, nil
+		}
+	}
+	return nil, fmt.Errorf("bad value %s", b)
+}
+
+func unmarshalTable(items, keyed json.RawMessage) (Value, error) {
+	var is []json.RawMessage
+	if err := json.Unmarshal(items, &is); err != nil {
+		return nil, err
+	}
+	var ks [][2]json.RawMessage
+	if keyed != nil {
+		if err := json.Unmarshal(keyed, &ks); err != nil {
+			return nil, err
+		}
+	}
+	t := &Table{}
+	for _, raw := range is {
+		v, err := unmarshalValue(raw)
+		if err != nil {
+			return nil, err
+		}
+		t.push(evaluated(v))
+	}
+	for _, kv := range ks {
+		kval, err := unmarshalValue(kv[0])
+		if err != nil {
+			return nil, err
+		}
+		k, ok := keyOf(kval)
+		if !ok {
+			return nil, fmt.Errorf("bad key %s", kv[0])
+		}
+		v, err := unmarshalValue(kv[1])
+		if err != nil {
+			return nil, err
+		}
+		t.set(k, evaluated(v))
+	}
+	return t, nil
+}
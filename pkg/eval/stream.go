@@ -0,0 +1,192 @@
+package eval
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+
+	"orglang/pkg/ast"
+)
+
+// @stdin is a source of the lines of Stdin, without their line endings.
+// A flow from it, `@stdin -> f -> g -> @stdout`, is streamed: each line
+// goes through every stage and into the sink before the next is read, so
+// a stream of any length runs in constant memory. The output of the
+// stream is buffered, and flushed whenever reading the next line would
+// wait for input. A stage taking its source whole, such as batch, a
+// @progress or a @kv in the flow reads all of the lines first instead.
+
+// flowChain returns the source and the sinks of the flow n, in order:
+// a -> b -> c is (a -> b) -> c.
+func flowChain(n *ast.InfixExpr) (ast.Expression, []ast.Expression) {
+	var sinks []ast.Expression
+	var src ast.Expression = n
+	for {
+		f, ok := src.(*ast.InfixExpr)
+		if !ok || f.Op != "->" {
+			break
+		}
+		sinks = append(sinks, f.Right)
+		src = f.Left
+	}
+	for i, j := 0, len(sinks)-1; i < j; i, j = i+1, j-1 {
+		sinks[i], sinks[j] = sinks[j], sinks[i]
+	}
+	return src, sinks
+}
+
+// evalFlow evaluates the flow n, streaming it if its source is @stdin.
+// Otherwise each flow of the chain runs in turn, as they nest.
+func (in *Interp) evalFlow(n *ast.InfixExpr, env *Env) Value {
+	srcExpr, sinkExprs := flowChain(n)
+	v := in.eval(srcExpr, env)
+	if isStdin(v) {
+		sinks := make([]Value, len(sinkExprs))
+		for i, s := range sinkExprs {
+			sinks[i] = in.eval(s, env)
+		}
+		return in.stream(sinks)
+	}
+	for _, s := range sinkExprs {
+		v = in.flow(v, in.eval(s, env))
+	}
+	return v
+}
+
+func isStdin(v Value) bool {
+	r, ok := v.(*Resource)
+	return ok && r.Name == "stdin" && r.store == nil
+}
+
+// stream sends the lines of @stdin through sinks, the stages of a flow
+// and its sink, one line at a time.
+func (in *Interp) stream(sinks []Value) Value {
+	for _, s := range sinks {
+		if e, ok := s.(*Error); ok {
+			return e
+		}
+	}
+	if !streamable(sinks) {
+		var v Value = in.readLines()
+		for _, s := range sinks {
+			v = in.flow(v, s)
+		}
+		return v
+	}
+
+	stages, last := sinks[:len(sinks)-1], sinks[len(sinks)-1]
+	sink, _ := last.(*Resource)
+	var out *Table
+	if sink == nil {
+		out = &Table{}
+	}
+	// The stages may write to the sink too: they write to its buffer,
+	// keeping the order of the output.
+	var buf *bufio.Writer
+	switch {
+	case sink != nil && sink.Name == "stdout":
+		buf = bufio.NewWriter(in.Stdout)
+		defer func(w io.Writer) { in.Stdout = w }(in.Stdout)
+		in.Stdout = buf
+	case sink != nil && sink.Name == "stderr":
+		buf = bufio.NewWriter(in.Stderr)
+		defer func(w io.Writer) { in.Stderr = w }(in.Stderr)
+		in.Stderr = buf
+	}
+	flush := func() *Error {
+		if buf == nil {
+			return nil
+		}
+		if err := buf.Flush(); err != nil {
+			return errorf("write to %s: %v", sink, err)
+		}
+		return nil
+	}
+
+	r := in.stdinReader()
+	for {
+		if r.Buffered() == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		line, err := r.ReadString('\n')
+		if line == "" && err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			flush()
+			return errorf("read from @stdin: %v", err)
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		var v Value = String(line)
+		for _, s := range stages {
+			v = in.call(s, nil, v)
+		}
+		if sink == nil {
+			out.push(evaluated(in.call(last, nil, v)))
+			continue
+		}
+		if e, ok := in.write(sink, v).(*Error); ok {
+			flush()
+			return e
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if sink == nil {
+		return out
+	}
+	return sink
+}
+
+// streamable tells whether a flow from @stdin into sinks can take the
+// lines one at a time: every stage and the sink are operators mapped
+// over the lines, but for a sink that is a stream to write to.
+func streamable(sinks []Value) bool {
+	for i, s := range sinks {
+		switch s := s.(type) {
+		case *Resource:
+			if i < len(sinks)-1 || s.store != nil || s.Name == "progress" || s.Name == "stdin" {
+				return false
+			}
+		case *Builtin:
+			if s.stage {
+				return false
+			}
+		default:
+			if arity(s) < 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// readLines reads the rest of @stdin into a table of its lines, or an
+// Error if reading fails.
+func (in *Interp) readLines() Value {
+	t := &Table{}
+	r := in.stdinReader()
+	for {
+		line, err := r.ReadString('\n')
+		if line == "" && err != nil {
+			if errors.Is(err, io.EOF) {
+				return t
+			}
+			return errorf("read from @stdin: %v", err)
+		}
+		t.push(evaluated(String(strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"))))
+	}
+}
+
+// stdinReader returns the reader of @stdin, buffering Stdin on first
+// use.
+func (in *Interp) stdinReader() *bufio.Reader {
+	if in.stdin == nil {
+		in.stdin = bufio.NewReader(in.Stdin)
+	}
+	return in.stdin
+}
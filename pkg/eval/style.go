@@ -0,0 +1,113 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// The style builtins wrap text in ANSI escapes, with the palette of the
+// org command itself: a blue accent, muted gray and the pink of the logo.
+// Each style ends with its own reset rather than a full one, so styles
+// nest: bold ("red" fg "x").
+
+// colorEnabled reports whether the style builtins emit escapes: never
+// with NO_COLOR set or TERM=dumb, always with CLICOLOR_FORCE or
+// FORCE_COLOR set, otherwise when stdout is a terminal.
+func colorEnabled() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	if os.Getenv("CLICOLOR_FORCE") != "" || os.Getenv("FORCE_COLOR") != "" {
+		return true
+	}
+	return term.IsTerminal(os.Stdout.Fd())
+}
+
+// colors are the named colors: the eight ANSI colors, their bright
+// variants and the palette of the org command, as 256-color indexes.
+var colors = map[string]int{
+	"black": 0, "red": 1, "green": 2, "yellow": 3, "blue": 4, "magenta": 5, "cyan": 6, "white": 7,
+	"bright_black": 8, "bright_red": 9, "bright_green": 10, "bright_yellow": 11,
+	"bright_blue": 12, "bright_magenta": 13, "bright_cyan": 14, "bright_white": 15,
+	"gray":   8,
+	"accent": 12, "muted": 240, "brand": 205, "title": 39,
+}
+
+// colorCode returns the SGR parameters selecting color c for the
+// foreground (base 30) or background (base 40): a name from colors, an
+// Integer 0..255 or a String "#rrggbb".
+func colorCode(name string, c Value, base int) (string, *Error) {
+	switch c := c.(type) {
+	case *Error:
+		return "", c
+	case *Number:
+		if c.isInt() && c.Rat.Num().IsInt64() {
+			if n := c.Rat.Num().Int64(); n >= 0 && n <= 255 {
+				return indexCode(int(n), base), nil
+			}
+		}
+	case String:
+		s := string(c)
+		if n, ok := colors[s]; ok {
+			return indexCode(n, base), nil
+		}
+		if len(s) == 7 && s[0] == '#' {
+			if rgb, err := strconv.ParseUint(s[1:], 16, 32); err == nil {
+				return fmt.Sprintf("%d;2;%d;%d;%d", base+8, rgb>>16, rgb>>8&0xff, rgb&0xff), nil
+			}
+		}
+	}
+	return "", errorf("%s: unknown color %s", name, c)
+}
+
+// indexCode selects color n of the 256-color palette, using the short
+// codes of the sixteen basic colors, which every terminal understands.
+func indexCode(n, base int) string {
+	switch {
+	case n < 8:
+		return strconv.Itoa(base + n)
+	case n < 16:
+		return strconv.Itoa(base + 60 + n - 8)
+	}
+	return fmt.Sprintf("%d;5;%d", base+8, n)
+}
+
+// styled returns the text of v between the escapes on and off, or just
+// the text when color is disabled.
+func (in *Interp) styled(v Value, on, off string) Value {
+	if e, ok := v.(*Error); ok {
+		return e
+	}
+	forceDeep(in, v)
+	text := Display(v)
+	if !in.Color {
+		return String(text)
+	}
+	var b strings.Builder
+	b.WriteString("\x1b[" + on + "m")
+	b.WriteString(text)
+	b.WriteString("\x1b[" + off + "m")
+	return String(b.String())
+}
+
+// colorize is fg and bg: color left applied to the text of right.
+func colorize(name string, base int) func(*Interp, Value, Value) Value {
+	return func(in *Interp, left, right Value) Value {
+		code, err := colorCode(name, left, base)
+		if err != nil {
+			return err
+		}
+		return in.styled(right, code, strconv.Itoa(base+9))
+	}
+}
+
+// attribute is bold and underline: the SGR attribute on, reset by off.
+func attribute(on, off string) func(*Interp, Value) Value {
+	return func(in *Interp, right Value) Value {
+		return in.styled(right, on, off)
+	}
+}
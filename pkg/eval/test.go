@@ -0,0 +1,183 @@
+package eval
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"orglang/pkg/ast"
+)
+
+// TestPrefix starts the names of the top-level bindings that org test
+// runs as tests, and GoldenPrefix those of golden tests, whose output is
+// compared with a file (see CompareGolden).
+const (
+	TestPrefix   = "test_"
+	GoldenPrefix = "golden_"
+)
+
+// TestResult is the outcome of one test.
+type TestResult struct {
+	Name     string
+	Failure  string // why the test failed; "" if it passed
+	Duration time.Duration
+	Golden   bool
+	Output   []byte // what a golden test wrote to @stdout and @tty
+}
+
+// Passed reports whether the test passed.
+func (r TestResult) Passed() bool { return r.Failure == "" }
+
+// TestNames returns the names of the tests of prog, its top-level
+// bindings whose name starts with TestPrefix or GoldenPrefix, in source
+// order.
+func TestNames(prog *ast.Program) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, s := range prog.Statements {
+		b, ok := s.(*ast.BindingExpr)
+		if !ok || b.Operator != ":" {
+			continue
+		}
+		name := bindingName(b.Name)
+		if (strings.HasPrefix(name, TestPrefix) || strings.HasPrefix(name, GoldenPrefix)) && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// RunTests evaluates prog, a test module, then each of its tests that
+// match accepts (nil runs them all), in source order.
+//
+// A test is the value of its binding, or for a block without operands
+// the result of calling it: the test passes if that value is true (see
+// Truthy) and fails otherwise, giving the Error, such as that of a
+// failed assert, or the value it got. A block taking operands fails,
+// since nothing could give them.
+//
+// A golden test is run the same way, but what it writes to @stdout is
+// its outcome: it passes unless it ends in an Error, its output kept in
+// the result for CompareGolden. It runs with color on and @tty a
+// ScriptedTerminal writing to the same output and reading the lines input
+// returns for the test (input may be nil), so prompts, key presses and
+// styles are tested too; the output is kept as NormalizeTerminal gives it.
+func (in *Interp) RunTests(prog *ast.Program, match func(name string) bool, input func(name string) []string) []TestResult {
+	in.Eval(prog)
+	var results []TestResult
+	for _, name := range TestNames(prog) {
+		if match != nil && !match(name) {
+			continue
+		}
+		r := TestResult{Name: name, Golden: strings.HasPrefix(name, GoldenPrefix)}
+		start := time.Now()
+		if r.Golden {
+			stdout, tty, color := in.Stdout, in.TTY, in.Color
+			var out bytes.Buffer
+			script := &ScriptedTerminal{Out: &out}
+			if input != nil {
+				script.Input = input(name)
+			}
+			in.Stdout, in.TTY, in.Color = &out, script, true
+			r.Failure = in.runTest(name)
+			in.Stdout, in.TTY, in.Color = stdout, tty, color
+			r.Output = NormalizeTerminal(out.Bytes())
+		} else {
+			r.Failure = in.runTest(name)
+		}
+		r.Duration = time.Since(start)
+		results = append(results, r)
+	}
+	return results
+}
+
+// runTest runs the test bound to name and returns why it failed, or "".
+// Only an Error fails a golden test.
+func (in *Interp) runTest(name string) string {
+	th, ok := in.Global.vars[name]
+	if !ok {
+		return "not defined"
+	}
+	v := th.force(in)
+	switch arity(v) {
+	case 0:
+		v = in.call(v, nil, nil)
+	case 1, 2:
+		return "a test cannot take operands"
+	}
+	if err := in.expired(); err != nil {
+		v = err
+	}
+	v = in.Force(v)
+	if e, ok := v.(*Error); ok {
+		return e.String()
+	}
+	if !Truthy(v) && !strings.HasPrefix(name, GoldenPrefix) {
+		return fmt.Sprintf("got %s", Display(v))
+	}
+	return ""
+}
+
+// GoldenFile returns the file holding the expected output of the golden
+// test name of the module source: report_test.org's golden_summary is
+// compared with report_test.summary.golden, next to it.
+func GoldenFile(source, name string) string {
+	return strings.TrimSuffix(source, ".org") + "." + strings.TrimPrefix(name, GoldenPrefix) + ".golden"
+}
+
+// GoldenInput returns the file holding what is typed during the golden
+// test name of the module source, one line per prompt or key press:
+// report_test.org's golden_menu reads report_test.menu.input, next to it.
+func GoldenInput(source, name string) string {
+	return strings.TrimSuffix(source, ".org") + "." + strings.TrimPrefix(name, GoldenPrefix) + ".input"
+}
+
+// CompareGolden compares the output of the golden test r with the file
+// path, failing it if they differ or the file is missing. With update,
+// the output is written to path instead, for the test to pass from then
+// on. A test that failed already is left alone.
+func (r *TestResult) CompareGolden(path string, update bool) {
+	if !r.Golden || !r.Passed() {
+		return
+	}
+	if update {
+		if err := os.WriteFile(path, r.Output, 0o644); err != nil {
+			r.Failure = err.Error()
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		r.Failure = fmt.Sprintf("no golden file %s; run org test --update to write it", path)
+		return
+	}
+	if err != nil {
+		r.Failure = err.Error()
+		return
+	}
+	if !bytes.Equal(r.Output, want) {
+		r.Failure = goldenDiff(path, r.Output, want)
+	}
+}
+
+// goldenDiff describes the first line on which got and want differ.
+func goldenDiff(path string, got, want []byte) string {
+	g, w := strings.Split(string(got), "\n"), strings.Split(string(want), "\n")
+	i := 0
+	for i < len(g) && i < len(w) && g[i] == w[i] {
+		i++
+	}
+	line := func(lines []string) string {
+		if i >= len(lines) {
+			return "(end of output)"
+		}
+		return strconv.Quote(lines[i])
+	}
+	return fmt.Sprintf("output differs from %s at line %d:\n       got:  %s\n       want: %s", path, i+1, line(g), line(w))
+}
@@ -0,0 +1,113 @@
+package eval
+
+import (
+	"fmt"
+	"time"
+)
+
+// sleep pauses evaluation for d.
+func (in *Interp) sleep(d time.Duration) {
+	if in.sleepFn != nil {
+		in.sleepFn(d)
+		return
+	}
+	time.Sleep(d)
+}
+
+// bucket is a token bucket: up to burst calls at once, refilled at rate
+// calls per second.
+type bucket struct {
+	rate, burst float64
+	tokens      float64
+	last        time.Time
+}
+
+// take waits until a call is allowed, or returns an Error if that would
+// pass the deadline in force.
+func (b *bucket) take(in *Interp) *Error {
+	now := in.clock()
+	if b.last.IsZero() {
+		b.tokens = b.burst
+	} else {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		if !in.Deadline.IsZero() && now.Add(wait).After(in.Deadline) {
+			return errorf("deadline exceeded")
+		}
+		in.sleep(wait)
+		b.last = in.clock()
+		b.tokens = 1
+	}
+	b.tokens--
+	return nil
+}
+
+// throttle is `limit throttle op`: op, called at most limit times a
+// second. limit is a number of calls per second, or a table
+// [rate: burst:] allowing burst calls at once before that pace applies.
+func throttle(in *Interp, left, right Value) Value {
+	if e, ok := left.(*Error); ok {
+		return e
+	}
+	b, err := throttleLimit(in, left)
+	if err != nil {
+		return err
+	}
+	n := arity(right)
+	if n < 0 {
+		return errorf("throttle needs an operator, got %s", right)
+	}
+	name := fmt.Sprintf("(%s throttle %s)", left, right)
+	if n == 2 {
+		return &Builtin{Name: name, binary: func(in *Interp, l, r Value) Value {
+			if err := b.take(in); err != nil {
+				return err
+			}
+			return in.call(right, l, r)
+		}}
+	}
+	return &Builtin{Name: name, unary: func(in *Interp, r Value) Value {
+		if err := b.take(in); err != nil {
+			return err
+		}
+		return in.call(right, nil, r)
+	}}
+}
+
+// throttleLimit reads the left operand of throttle.
+func throttleLimit(in *Interp, v Value) (*bucket, *Error) {
+	positive := func(v Value) (float64, bool) {
+		n, ok := v.(*Number)
+		if !ok || n.Rat.Sign() <= 0 {
+			return 0, false
+		}
+		f, _ := n.Rat.Float64()
+		return f, true
+	}
+	if rate, ok := positive(v); ok {
+		return &bucket{rate: rate, burst: 1}, nil
+	}
+	t, ok := v.(*Table)
+	if !ok {
+		return nil, errorf("throttle needs calls per second or [rate: burst:], got %s", v)
+	}
+	b := &bucket{burst: 1}
+	for _, k := range t.keys {
+		f, ok := positive(t.byKey[k].force(in))
+		switch {
+		case k == key{'s', "rate"} && ok:
+			b.rate = f
+		case k == key{'s', "burst"} && ok && f >= 1:
+			b.burst = f
+		default:
+			return nil, errorf("throttle: bad %s in %s", k, v)
+		}
+	}
+	if b.rate == 0 {
+		return nil, errorf("throttle: %s has no rate", v)
+	}
+	return b, nil
+}
@@ -0,0 +1,260 @@
+package eval
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// Terminal is the terminal behind @tty and the prompt builtins. It is the
+// controlling terminal of the process, not stdin or stdout, so prompts
+// still reach the user when those are redirected.
+type Terminal interface {
+	io.Writer
+	// ReadLine reads a line, without its line ending, echoing what is
+	// typed only if echo is set.
+	ReadLine(echo bool) (string, error)
+	// ReadKey reads one key press in raw mode (see keyName).
+	ReadKey() (string, error)
+	// Size returns the width and height of the terminal in cells.
+	Size() (cols, rows int, err error)
+}
+
+// terminal returns in.TTY, opening the controlling terminal on first use.
+func (in *Interp) terminal() (Terminal, *Error) {
+	if in.TTY == nil {
+		t, err := openTTY()
+		if err != nil {
+			return nil, errorf("@tty: %v", err)
+		}
+		in.TTY = t
+	}
+	return in.TTY, nil
+}
+
+// tty is the controlling terminal, /dev/tty.
+type tty struct {
+	f *os.File
+	r *bufio.Reader
+}
+
+func openTTY() (*tty, error) {
+	f, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, errors.New("no terminal")
+	}
+	return &tty{f: f, r: bufio.NewReader(f)}, nil
+}
+
+func (t *tty) Write(p []byte) (int, error) { return t.f.Write(p) }
+
+func (t *tty) ReadLine(echo bool) (string, error) {
+	if !echo {
+		b, err := term.ReadPassword(t.f.Fd())
+		// The newline typed was not echoed either.
+		t.f.WriteString("\n")
+		return string(b), err
+	}
+	s, err := t.r.ReadString('\n')
+	if err == io.EOF && s != "" {
+		err = nil
+	}
+	return strings.TrimRight(s, "\r\n"), err
+}
+
+func (t *tty) ReadKey() (string, error) {
+	state, err := term.MakeRaw(t.f.Fd())
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(t.f.Fd(), state)
+	r, _, err := t.r.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	seq := string(r)
+	// An escape sequence arrives in one read; a lone escape key does not
+	// have anything after it.
+	if r == 0x1b {
+		for t.r.Buffered() > 0 {
+			c, _, err := t.r.ReadRune()
+			if err != nil {
+				break
+			}
+			seq += string(c)
+			if len(seq) > 2 && c >= 0x40 && c <= 0x7e {
+				break
+			}
+		}
+	}
+	return keyName(seq), nil
+}
+
+func (t *tty) Size() (int, int, error) { return term.GetSize(t.f.Fd()) }
+
+// ScriptedTerminal is a Terminal that plays a script, for golden tests:
+// each ReadLine takes the next line of Input, echoed to Out as a terminal
+// would, and each ReadKey the next line as the name of the key pressed
+// (a character, or a name such as enter or up, see keyName). Its size is
+// fixed at 80 by 24, so what a program draws does not depend on the
+// terminal the tests run in. Past the end of Input, reads return io.EOF.
+type ScriptedTerminal struct {
+	Out   io.Writer
+	Input []string
+}
+
+func (t *ScriptedTerminal) Write(p []byte) (int, error) { return t.Out.Write(p) }
+
+func (t *ScriptedTerminal) ReadLine(echo bool) (string, error) {
+	line, err := t.next()
+	if err != nil {
+		return "", err
+	}
+	if echo {
+		io.WriteString(t.Out, line)
+	}
+	io.WriteString(t.Out, "\n")
+	return line, nil
+}
+
+func (t *ScriptedTerminal) ReadKey() (string, error) {
+	key, err := t.next()
+	if key == "" && err == nil {
+		key = "enter"
+	}
+	return key, err
+}
+
+func (t *ScriptedTerminal) Size() (int, int, error) { return 80, 24, nil }
+
+func (t *ScriptedTerminal) next() (string, error) {
+	if len(t.Input) == 0 {
+		return "", io.EOF
+	}
+	line := t.Input[0]
+	t.Input = t.Input[1:]
+	return line, nil
+}
+
+// NormalizeTerminal makes what a program wrote to a terminal readable and
+// stable, for golden files: escape characters become \e, so styles and
+// cursor moves show as \e[1m or \e[2K, and other control characters but
+// tab and newline are written as \r or \x07; a carriage return before a
+// newline is dropped.
+func NormalizeTerminal(out []byte) []byte {
+	var b strings.Builder
+	s := strings.ReplaceAll(string(out), "\r\n", "\n")
+	for _, r := range s {
+		switch {
+		case r == 0x1b:
+			b.WriteString(`\e`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 && r != '\n' && r != '\t', r == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return []byte(b.String())
+}
+
+// keys names the keys that do not type a character.
+var keys = map[string]string{
+	"\r": "enter", "\n": "enter", "\t": "tab", "\x7f": "backspace", "\b": "backspace",
+	"\x1b":   "escape",
+	"\x1b[A": "up", "\x1b[B": "down", "\x1b[C": "right", "\x1b[D": "left",
+	"\x1bOA": "up", "\x1bOB": "down", "\x1bOC": "right", "\x1bOD": "left",
+	"\x1b[H": "home", "\x1b[F": "end", "\x1b[1~": "home", "\x1b[4~": "end",
+	"\x1b[2~": "insert", "\x1b[3~": "delete", "\x1b[5~": "page_up", "\x1b[6~": "page_down",
+}
+
+// keyName returns the name of the key that sent seq: the character it
+// types, a name from keys, ctrl+a to ctrl+z, or the sequence itself for
+// keys it does not know.
+func keyName(seq string) string {
+	if name, ok := keys[seq]; ok {
+		return name
+	}
+	if len(seq) == 1 && seq[0] >= 1 && seq[0] <= 26 {
+		return "ctrl+" + string(rune('a'+seq[0]-1))
+	}
+	return seq
+}
+
+// prompt is prompt and password: write the prompt right to the terminal
+// and read the line typed, echoed or not.
+func prompt(name string, echo bool) func(*Interp, Value) Value {
+	return func(in *Interp, right Value) Value {
+		text, err := textOperand(name, right)
+		if err != nil {
+			return err
+		}
+		t, err := in.terminal()
+		if err != nil {
+			return err
+		}
+		if _, werr := io.WriteString(t, text); werr != nil {
+			return errorf("%s: %v", name, werr)
+		}
+		line, rerr := t.ReadLine(echo)
+		if rerr == io.EOF {
+			return errorf("%s: end of input", name)
+		}
+		if rerr != nil {
+			return errorf("%s: %v", name, rerr)
+		}
+		return String(line)
+	}
+}
+
+// readKey is read_key: write the prompt right and read one key press
+// without waiting for enter.
+func readKey(in *Interp, right Value) Value {
+	text, err := textOperand("read_key", right)
+	if err != nil {
+		return err
+	}
+	t, err := in.terminal()
+	if err != nil {
+		return err
+	}
+	if _, werr := io.WriteString(t, text); werr != nil {
+		return errorf("read_key: %v", werr)
+	}
+	k, rerr := t.ReadKey()
+	if rerr == io.EOF {
+		return errorf("read_key: end of input")
+	}
+	if rerr != nil {
+		return errorf("read_key: %v", rerr)
+	}
+	return String(k)
+}
+
+// ttySize is tty_size @tty: [cols: rows:] of the terminal.
+func ttySize(in *Interp, right Value) Value {
+	if e, ok := right.(*Error); ok {
+		return e
+	}
+	if r, ok := right.(*Resource); !ok || r.Name != "tty" {
+		return errorf("tty_size needs @tty, got %s", right)
+	}
+	t, err := in.terminal()
+	if err != nil {
+		return err
+	}
+	cols, rows, serr := t.Size()
+	if serr != nil {
+		return errorf("tty_size: %v", serr)
+	}
+	out := &Table{}
+	out.set(key{'s', "cols"}, evaluated(Int(int64(cols))))
+	out.set(key{'s', "rows"}, evaluated(Int(int64(rows))))
+	return out
+}
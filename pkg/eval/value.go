@@ -0,0 +1,276 @@
+package eval
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"orglang/pkg/ast"
+)
+
+// Value is an OrgLang value. String returns its source-like
+// representation, as printed by the REPL; Display gives the text written
+// to a sink such as @stdout.
+type Value interface {
+	String() string
+}
+
+// NumKind distinguishes the three numeric types, which share one exact
+// representation.
+type NumKind int
+
+const (
+	Integer NumKind = iota
+	Rational
+	Decimal
+)
+
+// Number is an Integer, Rational or Decimal. The value is always exact;
+// a Decimal's Scale (digits after the point) only matters for printing,
+// as in the C runtime.
+type Number struct {
+	Kind  NumKind
+	Rat   *big.Rat
+	Scale int
+}
+
+// Int returns the Integer n.
+func Int(n int64) *Number {
+	return &Number{Kind: Integer, Rat: new(big.Rat).SetInt64(n)}
+}
+
+// ratNumber returns q as an Integer when its denominator is 1 and as a
+// Rational otherwise.
+func ratNumber(q *big.Rat) *Number {
+	if q.IsInt() {
+		return &Number{Kind: Integer, Rat: q}
+	}
+	return &Number{Kind: Rational, Rat: q}
+}
+
+func decNumber(q *big.Rat, scale int) *Number {
+	return &Number{Kind: Decimal, Rat: q, Scale: scale}
+}
+
+func (n *Number) String() string {
+	switch n.Kind {
+	case Decimal:
+		return n.Rat.FloatString(n.Scale)
+	case Rational:
+		return n.Rat.RatString()
+	}
+	return n.Rat.Num().String()
+}
+
+func (n *Number) isInt() bool { return n.Kind == Integer }
+
+// String is a string value. Strings are tables of codepoints: they index
+// by position and coerce to their length.
+type String string
+
+func (s String) String() string { return strconv.Quote(string(s)) }
+
+// Boolean is true or false.
+type Boolean bool
+
+func (b Boolean) String() string { return strconv.FormatBool(bool(b)) }
+
+// Error is the Error value. Operators propagate it instead of computing.
+type Error struct {
+	Message string
+}
+
+func (e *Error) String() string {
+	if e.Message == "" {
+		return "Error"
+	}
+	return "Error: " + e.Message
+}
+
+func errorf(format string, args ...any) *Error {
+	return &Error{Message: fmt.Sprintf(format, args...)}
+}
+
+// Resource is a built-in resource instance such as @stdout.
+type Resource struct {
+	Name  string
+	store *kvStore // the store of a @kv from kv_open
+}
+
+func (r *Resource) String() string { return "@" + r.Name }
+
+// Display returns the text of v as written to a sink: strings unquoted,
+// everything else as printed by the REPL.
+func Display(v Value) string {
+	if s, ok := v.(String); ok {
+		return string(s)
+	}
+	return v.String()
+}
+
+// thunk is a lazily evaluated table entry or binding.
+type thunk struct {
+	expr  ast.Expression
+	env   *Env
+	value Value
+	busy  bool
+}
+
+func evaluated(v Value) *thunk { return &thunk{value: v} }
+
+func (t *thunk) force(in *Interp) Value {
+	if t.value != nil {
+		return t.value
+	}
+	if t.busy {
+		return errorf("%s depends on itself", t.expr)
+	}
+	t.busy = true
+	t.value = in.eval(t.expr, t.env)
+	t.busy = false
+	t.expr, t.env = nil, nil
+	return t.value
+}
+
+// key is a table key: a string (or binding name), an integer, another
+// number, or a boolean.
+type key struct {
+	kind byte // 's', 'i', 'n' or 'b'
+	s    string
+}
+
+func keyOf(v Value) (key, bool) {
+	switch v := v.(type) {
+	case String:
+		return key{'s', string(v)}, true
+	case Boolean:
+		return key{'b', strconv.FormatBool(bool(v))}, true
+	case *Number:
+		if v.Rat.IsInt() {
+			return key{'i', v.Rat.Num().String()}, true
+		}
+		return key{'n', v.Rat.RatString()}, true
+	}
+	return key{}, false
+}
+
+func (k key) String() string {
+	if k.kind == 's' {
+		if isIdent(k.s) {
+			return k.s
+		}
+		return strconv.Quote(k.s)
+	}
+	return k.s
+}
+
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= 0x80 || i > 0 && r >= '0' && r <= '9' {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Table holds positional elements, indexed from 0, and keyed entries in
+// insertion order. Entries are thunks, evaluated when accessed.
+type Table struct {
+	items []*thunk
+	keys  []key
+	byKey map[key]*thunk
+
+	progress *progress // set by -> @progress for the next flow
+}
+
+func (t *Table) push(th *thunk) { t.items = append(t.items, th) }
+
+func (t *Table) set(k key, th *thunk) {
+	if t.byKey == nil {
+		t.byKey = make(map[key]*thunk)
+	}
+	if _, ok := t.byKey[k]; !ok {
+		t.keys = append(t.keys, k)
+	}
+	t.byKey[k] = th
+}
+
+// remove deletes the keyed entry k, keeping the order of the others.
+func (t *Table) remove(k key) {
+	if _, ok := t.byKey[k]; !ok {
+		return
+	}
+	delete(t.byKey, k)
+	t.keys = slices.DeleteFunc(t.keys, func(x key) bool { return x == k })
+}
+
+// Len is the size of the table: its positional and keyed entries.
+func (t *Table) Len() int { return len(t.items) + len(t.keys) }
+
+// get looks k up among the keyed entries, then, for an integer, among the
+// positional elements.
+func (t *Table) get(k key) (*thunk, bool) {
+	if th, ok := t.byKey[k]; ok {
+		return th, true
+	}
+	if k.kind == 'i' {
+		if i, err := strconv.Atoi(k.s); err == nil && i >= 0 && i < len(t.items) {
+			return t.items[i], true
+		}
+	}
+	return nil, false
+}
+
+func (t *Table) String() string {
+	var b strings.Builder
+	b.WriteString("[")
+	for i, th := range t.items {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(th.describe())
+	}
+	for i, k := range t.keys {
+		if i > 0 || len(t.items) > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(k.String())
+		b.WriteString(": ")
+		b.WriteString(t.byKey[k].describe())
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// describe prints an entry without forcing it.
+func (t *thunk) describe() string {
+	if t.value != nil {
+		return t.value.String()
+	}
+	return "{ " + t.expr.String() + " }"
+}
+
+// forceAll evaluates every entry, so the table prints its values.
+func (t *Table) forceAll(in *Interp) {
+	for _, th := range t.items {
+		forceDeep(in, th.force(in))
+	}
+	for _, k := range t.keys {
+		forceDeep(in, t.byKey[k].force(in))
+	}
+}
+
+func forceDeep(in *Interp, v Value) {
+	if t, ok := v.(*Table); ok {
+		t.forceAll(in)
+	}
+}
+
+func runeLen(s String) int { return utf8.RuneCountInString(string(s)) }
@@ -0,0 +1,126 @@
+package fix
+
+import (
+	"fmt"
+	"strings"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/doc"
+	"orglang/pkg/lexer"
+	"orglang/pkg/lint"
+	"orglang/pkg/parser"
+	"orglang/pkg/token"
+)
+
+// Deprecated rewrites the uses of deprecated bindings in src, read from
+// path, to the replacements their @deprecated notes name
+// (doc.Replacement), and returns the new source with the edits made, in
+// source order:
+//
+//   - bindings of the file itself, used by name, as operators or as
+//     resources (@name), become the replacement;
+//   - bindings of modules the file imports with `alias : "path" @ org`,
+//     resolved as the deprecated lint resolves them (lint.Imports), used
+//     as alias.name, become alias.replacement.
+//
+// Uses are found in the syntax tree, so the bound names of bindings and
+// table keys (t.name, for t not an import) are left alone. A deprecated
+// binding whose note names no replacement is not rewritten. A file that
+// does not parse is an error, as its uses cannot be told apart.
+func Deprecated(path string, src []byte) ([]byte, []Edit, error) {
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, nil, fmt.Errorf("%s: %s", path, strings.Join(errs, "; "))
+	}
+	var tokens []token.Token
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	local := map[string]string{}
+	if mod, err := doc.Parse(path, path, src); err == nil {
+		local = replacements(mod)
+	}
+	imported := map[string]map[string]string{}
+	for alias, mod := range lint.Imports(path, src) {
+		imported[alias] = replacements(mod)
+	}
+
+	at := map[int]token.Token{}
+	for _, tok := range tokens {
+		at[tok.Offset] = tok
+	}
+
+	var edits []Edit
+	// replace rewrites the identifier token starting at offset, if it
+	// names a binding of byName.
+	replace := func(offset int, byName map[string]string) {
+		tok, ok := at[offset]
+		if !ok || tok.Type != token.IDENTIFIER {
+			return
+		}
+		if replacement, ok := byName[tok.Literal]; ok {
+			edits = append(edits, Edit{
+				Line: tok.Line, Column: tok.Column,
+				Offset: tok.Offset, End: tok.End,
+				Old: tok.Literal, New: replacement,
+			})
+		}
+	}
+	// operator rewrites the operator op written between from and to.
+	operator := func(op string, from, to int) {
+		for _, tok := range tokens {
+			if tok.Offset >= from && tok.Offset < to && tok.Literal == op {
+				replace(tok.Offset, local)
+				return
+			}
+		}
+	}
+
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BindingExpr:
+			if _, ok := n.Name.(*ast.Name); !ok {
+				ast.Inspect(n.Name, visit)
+			}
+			ast.Inspect(n.Value, visit)
+			return false
+		case *ast.ResourceDef:
+			ast.Inspect(n.Value, visit)
+			return false
+		case *ast.DotExpr:
+			if alias, ok := n.Left.(*ast.Name); ok && imported[alias.Value] != nil {
+				if key, ok := n.Key.(*ast.Name); ok {
+					replace(key.Start.Offset, imported[alias.Value])
+				}
+				return false
+			}
+			ast.Inspect(n.Left, visit)
+			return false
+		case *ast.Name:
+			replace(n.Start.Offset, local)
+		case *ast.PrefixExpr:
+			operator(n.Op, n.Start.Offset, n.Right.Location().Start.Offset)
+		case *ast.InfixExpr:
+			operator(n.Op, n.Left.Location().End.Offset, n.Right.Location().Start.Offset)
+		}
+		return true
+	}
+	ast.Inspect(prog, visit)
+	return apply(src, edits), edits, nil
+}
+
+// replacements maps the deprecated bindings of mod whose notes name a
+// replacement to it.
+func replacements(mod *doc.Module) map[string]string {
+	byName := map[string]string{}
+	for _, b := range mod.Bindings {
+		if r := doc.Replacement(b.DeprecatedNote); b.Deprecated && r != "" && r != b.Name {
+			byName[b.Name] = r
+		}
+	}
+	return byName
+}
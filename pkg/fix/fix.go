@@ -0,0 +1,114 @@
+// Package fix rewrites OrgLang source to replace deprecated constructs
+// with their replacements, so breaking releases come with a one-command
+// upgrade path (`org fix`).
+//
+// Deprecated finds the uses of deprecated bindings in the syntax tree and
+// rewrites them to the replacements their @deprecated notes name;
+// Renames rewrites the references to bindings given by the user. Both
+// splice replacements into the original bytes, so comments and
+// formatting are preserved.
+package fix
+
+import (
+	"fmt"
+	"sort"
+
+	"orglang/pkg/lexer"
+	"orglang/pkg/token"
+)
+
+// Rename replaces the references to binding Old with New.
+type Rename struct {
+	Old string
+	New string
+}
+
+// Edit is one replacement made in a source file.
+type Edit struct {
+	Line   int // 1-indexed
+	Column int // 1-indexed
+	Offset int // byte range replaced
+	End    int
+	Old    string
+	New    string
+}
+
+func (e Edit) String() string {
+	return fmt.Sprintf("line %d:%d: %s -> %s", e.Line, e.Column, e.Old, e.New)
+}
+
+// Renames rewrites the references to each renamed binding and returns
+// the new source with the edits made, in source order.
+//
+// A reference is an identifier token, including resource names after
+// `@`. Table keys (`t.old`) are not references. A file that binds Old
+// itself (`old : ...` or `old @: ...`) is left alone for that rename,
+// since its references point to its own definition.
+func Renames(src []byte, renames []Rename) ([]byte, []Edit) {
+	var tokens []token.Token
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	byName := map[string]string{}
+	for _, r := range renames {
+		byName[r.Old] = r.New
+	}
+	for i, tok := range tokens {
+		if tok.Type == token.IDENTIFIER && i+1 < len(tokens) &&
+			(tokens[i+1].Type == token.COLON || tokens[i+1].Type == token.AT_COLON) {
+			delete(byName, tok.Literal)
+		}
+	}
+
+	var edits []Edit
+	for i, tok := range tokens {
+		if tok.Type != token.IDENTIFIER {
+			continue
+		}
+		replacement, ok := byName[tok.Literal]
+		if !ok || (i > 0 && tokens[i-1].Type == token.DOT) {
+			continue
+		}
+		edits = append(edits, Edit{
+			Line: tok.Line, Column: tok.Column,
+			Offset: tok.Offset, End: tok.End,
+			Old: tok.Literal, New: replacement,
+		})
+	}
+	return apply(src, edits), edits
+}
+
+// Merge returns src with the edits of a and b made, both computed on src,
+// and the edits made in source order. An edit of b overlapping one of a
+// is dropped.
+func Merge(src []byte, a, b []Edit) ([]byte, []Edit) {
+	edits := append([]Edit(nil), a...)
+	for _, e := range b {
+		overlaps := false
+		for _, f := range a {
+			overlaps = overlaps || e.Offset < f.End && f.Offset < e.End
+		}
+		if !overlaps {
+			edits = append(edits, e)
+		}
+	}
+	return apply(src, edits), edits
+}
+
+// apply splices the edits, which must not overlap, into src.
+func apply(src []byte, edits []Edit) []byte {
+	if len(edits) == 0 {
+		return src
+	}
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].Offset < edits[j].Offset })
+	out := make([]byte, 0, len(src))
+	last := 0
+	for _, e := range edits {
+		out = append(out, src[last:e.Offset]...)
+		out = append(out, e.New...)
+		last = e.End
+	}
+	return append(out, src[last:]...)
+}
@@ -0,0 +1,93 @@
+package fix
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenames(t *testing.T) {
+	src := `# uses the old names
+total : sum [1 2 3];   # sum is deprecated
+@stdout_old <- total;
+t.sum;
+`
+	out, edits := Renames([]byte(src), []Rename{{"sum", "fold_add"}, {"stdout_old", "stdout"}})
+
+	expected := `# uses the old names
+total : fold_add [1 2 3];   # sum is deprecated
+@stdout <- total;
+t.sum;
+`
+	if string(out) != expected {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if len(edits) != 2 || edits[0].String() != "line 2:9: sum -> fold_add" || edits[1].String() != "line 3:2: stdout_old -> stdout" {
+		t.Errorf("unexpected edits: %v", edits)
+	}
+}
+
+func TestRenamesSkipsLocalDefinitions(t *testing.T) {
+	src := "sum : { left + right };\nx : 1 sum 2;"
+	out, edits := Renames([]byte(src), []Rename{{"sum", "fold_add"}})
+	if string(out) != src || len(edits) != 0 {
+		t.Errorf("file defining the old name should be untouched, got %q %v", out, edits)
+	}
+}
+
+func TestRenamesNormalizedIdentifiers(t *testing.T) {
+	// The reference is written decomposed; the replacement spans all its bytes.
+	out, edits := Renames([]byte("x : cafe\u0301 + 1;"), []Rename{{"caf\u00e9", "coffee"}})
+	if string(out) != "x : coffee + 1;" || len(edits) != 1 {
+		t.Errorf("got %q %v", out, edits)
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	dir := t.TempDir()
+	lib := "\"\"\"@deprecated use `twice` instead\"\"\"\ndouble : { right * 2 };\ntwice : { right * 2 };\n"
+	if err := os.WriteFile(filepath.Join(dir, "lib.org"), []byte(lib), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src := `lib : "lib.org" @ org;
+"""@deprecated use plus"""
+sum : 50{ left + right }51;
+plus : 50{ left + right }51;
+"""@deprecated use ` + "`pos`" + `"""
+neg : { 0 - right };
+pos : { right };
+"""@deprecated"""
+old : 1;
+t : [a: 2];
+y : (1 sum (neg 2)) + (3 -> lib.double) + t.neg + old;
+`
+	out, edits, err := Deprecated(filepath.Join(dir, "main.org"), []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Replace(src, "(1 sum (neg 2)) + (3 -> lib.double)", "(1 plus (pos 2)) + (3 -> lib.twice)", 1)
+	if string(out) != expected {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	var got []string
+	for _, e := range edits {
+		got = append(got, e.String())
+	}
+	if strings.Join(got, "|") != "line 11:8: sum -> plus|line 11:13: neg -> pos|line 11:33: double -> twice" {
+		t.Errorf("unexpected edits: %v", got)
+	}
+
+	if _, _, err := Deprecated("bad.org", []byte("x : (;")); err == nil {
+		t.Error("a file that does not parse: no error")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	src := []byte("a + b")
+	out, edits := Merge(src, []Edit{{Offset: 0, End: 1, Old: "a", New: "x"}},
+		[]Edit{{Offset: 4, End: 5, Old: "b", New: "y"}, {Offset: 0, End: 1, Old: "a", New: "z"}})
+	if string(out) != "x + y" || len(edits) != 2 {
+		t.Errorf("got %q %v", out, edits)
+	}
+}
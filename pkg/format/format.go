@@ -0,0 +1,492 @@
+// Package format prints OrgLang source in the canonical style used by
+// `org fmt`.
+//
+// The formatter works on the token stream, like pkg/fix: tokens are
+// re-spaced and lines re-indented, while line breaks, comments and the
+// text of literals are kept. Only the blank lines are normalised (at most
+// one in a row). The formatted source always lexes to the same tokens as
+// the input.
+package format
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+	"orglang/pkg/token"
+)
+
+// Indent is one level of indentation.
+const Indent = "    "
+
+// Source returns src in canonical style. Sources that do not lex or parse
+// are returned as errors, since their structure is unknown.
+func Source(src []byte) ([]byte, error) {
+	out, _, _, err := format(src)
+	return out, err
+}
+
+// Range formats part of src, the lines holding the bytes from start to
+// end, widened to whole top-level statements and comments so that their
+// structure is known. It returns the part, as the offsets from and to in
+// src, and its formatted text: what formatting the whole source writes in
+// its place. from is the start of a line; to is just past a newline, or
+// the end of src. Like Source, it fails on sources that do not parse.
+func Range(src []byte, start, end int) (from, to int, out []byte, err error) {
+	formatted, f, prog, err := format(src)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	start, end = min(max(start, 0), len(src)), min(max(end, start), len(src))
+	from, to = lineStart(src, start), lineEnd(src, max(end-1, start))
+	// Blank lines around the part are left alone.
+	for from < to && blank(src[from:lineEnd(src, from)]) {
+		from = lineEnd(src, from)
+	}
+	for to > from && blank(src[lineStart(src, to-1):to]) {
+		to = lineStart(src, to-1)
+	}
+
+	var spans [][2]int
+	for _, s := range prog.Statements {
+		spans = append(spans, [2]int{s.Location().Start.Offset, s.Location().End.Offset})
+	}
+	for _, l := range f.lines {
+		if l.to > 0 {
+			spans = append(spans, [2]int{l.from, l.to})
+		}
+	}
+	for widened := true; widened; {
+		widened = false
+		for _, s := range spans {
+			if s[0] < to && s[1] > from && (s[0] < from || s[1] > to) {
+				from, to = min(from, lineStart(src, s[0])), max(to, lineEnd(src, s[1]-1))
+				widened = true
+			}
+		}
+	}
+
+	first, last := -1, -1
+	for i, l := range f.lines {
+		if l.to > 0 && l.from >= from && l.to <= to {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return from, to, nil, nil
+	}
+	return from, to, formatted[f.starts[first]:f.starts[last+1]], nil
+}
+
+// format formats src, returning the formatter, which has mapped its lines
+// to the source, and the program it parsed.
+func format(src []byte) ([]byte, *formatter, *ast.Program, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, nil, nil, errors.New(errs[0])
+	}
+
+	f := &formatter{src: src, bindings: p.Bindings()}
+	prevEnd := 0
+	for i, tok := range tokens {
+		var next token.Token
+		if i+1 < len(tokens) {
+			next = tokens[i+1]
+		}
+		f.trivia(prevEnd, tok.Offset)
+		f.token(tok, next)
+		prevEnd = tok.End
+	}
+	f.trivia(prevEnd, len(src))
+	out := f.bytes()
+
+	// A formatter must never change the program.
+	formatted, err := lex(out)
+	if err != nil || !sameTokens(tokens, formatted) {
+		return nil, nil, nil, errors.New("formatting would change the program; please report this source")
+	}
+	return out, f, prog, nil
+}
+
+// lineStart returns the offset of the start of the line holding src[i].
+func lineStart(src []byte, i int) int {
+	return bytes.LastIndexByte(src[:i], '\n') + 1
+}
+
+// lineEnd returns the offset just past the newline ending the line
+// holding src[i], or len(src).
+func lineEnd(src []byte, i int) int {
+	if n := bytes.IndexByte(src[i:], '\n'); n >= 0 {
+		return i + n + 1
+	}
+	return len(src)
+}
+
+func blank(line []byte) bool {
+	return len(bytes.TrimSpace(line)) == 0
+}
+
+func lex(src []byte) ([]token.Token, error) {
+	var tokens []token.Token
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.ILLEGAL {
+			return nil, fmt.Errorf("line %d:%d: %s", tok.Line, tok.Column, tok.Literal)
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
+}
+
+func sameTokens(a, b []token.Token) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Type != b[i].Type || a[i].Literal != b[i].Literal {
+			return false
+		}
+	}
+	return true
+}
+
+// line is one output line: its indentation, code and trailing comment.
+// Lines holding a block comment or a multi-line literal are not aligned.
+// from and to are the offsets in the source of what the line holds; to is
+// 0 for a blank line.
+type line struct {
+	indent   string
+	code     string
+	comment  string
+	raw      bool
+	from, to int
+}
+
+type formatter struct {
+	src      []byte
+	bindings *parser.BindingTable
+	lines    []line
+	open     []token.TokenType // unclosed brackets
+	prev     *token.Token      // last token written
+	breaks   int               // newlines seen since the last item
+	starts   []int             // offset in the output of each line, then its length
+}
+
+// trivia records the newlines and comments in src[from:to], the text
+// between two tokens.
+func (f *formatter) trivia(from, to int) {
+	src := f.src
+	for i := from; i < to; {
+		switch src[i] {
+		case '\n':
+			f.breaks++
+			i++
+		case '#':
+			// ### opens a block comment only at column 1.
+			if (i == 0 || src[i-1] == '\n') && bytes.HasPrefix(src[i:to], []byte("###")) {
+				end := blockCommentEnd(src[:to], i)
+				f.blockComment(string(src[i:end]))
+				f.holds(i, end)
+				i = end
+				continue
+			}
+			end := bytes.IndexByte(src[i:to], '\n')
+			if end < 0 {
+				end = to
+			} else {
+				end += i
+			}
+			f.lineComment(strings.TrimRight(string(src[i:end]), " \t\r"))
+			f.holds(i, end)
+			i = end
+		default:
+			i++
+		}
+	}
+}
+
+// blockCommentEnd returns the end of the ### comment starting at src[i]:
+// just past the ### that starts a later line, or the end of src.
+func blockCommentEnd(src []byte, i int) int {
+	for j := i + 3; j < len(src); j++ {
+		if src[j] == '\n' && bytes.HasPrefix(src[j+1:], []byte("###")) {
+			return j + 4
+		}
+	}
+	return len(src)
+}
+
+// newLine starts a new output line for an item when the source had a
+// line break before it, keeping at most one blank line.
+func (f *formatter) newLine(indent string) bool {
+	if len(f.lines) > 0 && f.breaks == 0 {
+		return false
+	}
+	if len(f.lines) > 0 && f.breaks > 1 {
+		f.lines = append(f.lines, line{})
+	}
+	f.lines = append(f.lines, line{indent: indent})
+	f.breaks = 0
+	return true
+}
+
+func (f *formatter) lineComment(text string) {
+	if f.newLine(f.indent(len(f.open), false)) {
+		cur := &f.lines[len(f.lines)-1]
+		// A ### at column 1 would open a block comment.
+		if cur.indent == "" && strings.HasPrefix(text, "###") {
+			cur.indent = " "
+		}
+		cur.code = text
+		return
+	}
+	cur := &f.lines[len(f.lines)-1]
+	if cur.raw {
+		cur.code += " " + text
+		return
+	}
+	cur.comment = text
+}
+
+func (f *formatter) blockComment(text string) {
+	// A block comment starts at column 1, so always on a new line.
+	f.newLine("")
+	cur := &f.lines[len(f.lines)-1]
+	cur.code, cur.raw = text, true
+}
+
+func (f *formatter) token(tok, next token.Token) {
+	depth := len(f.open)
+	if closes(tok.Type) && depth > 0 {
+		depth--
+	}
+	text := string(f.src[tok.Offset:tok.End])
+
+	if f.newLine(f.indent(depth, f.continues(tok, next))) {
+		cur := &f.lines[len(f.lines)-1]
+		if tok.Type == token.DOCSTRING || tok.Type == token.RAWDOC {
+			text = reindentDoc(text, tok, cur.indent)
+		}
+		cur.code = text
+	} else {
+		cur := &f.lines[len(f.lines)-1]
+		if f.prev != nil && f.spaced(*f.prev, tok) {
+			cur.code += " "
+		}
+		cur.code += text
+	}
+	if strings.Contains(text, "\n") {
+		f.lines[len(f.lines)-1].raw = true
+	}
+
+	switch {
+	case opens(tok.Type):
+		f.open = append(f.open, tok.Type)
+	case closes(tok.Type) && len(f.open) > 0:
+		f.open = f.open[:len(f.open)-1]
+	}
+	f.prev = &tok
+	f.holds(tok.Offset, tok.End)
+}
+
+// holds records that the current line holds src[from:to].
+func (f *formatter) holds(from, to int) {
+	cur := &f.lines[len(f.lines)-1]
+	if cur.to == 0 {
+		cur.from = from
+	}
+	cur.to = to
+}
+
+func (f *formatter) indent(depth int, continued bool) string {
+	if continued {
+		depth++
+	}
+	return strings.Repeat(Indent, depth)
+}
+
+// continues reports whether a line starting with tok (followed by next)
+// continues the expression of the line before, and so is indented one
+// more level.
+func (f *formatter) continues(tok, next token.Token) bool {
+	if f.prev == nil || closes(tok.Type) {
+		return false
+	}
+	inTable := len(f.open) > 0 && f.open[len(f.open)-1] == token.LBRACKET
+	switch f.prev.Type {
+	case token.COLON, token.AT_COLON, token.DOT:
+		return true
+	case token.ELVIS, token.COMMA:
+		return !inTable
+	case token.IDENTIFIER:
+		if inTable {
+			return false
+		}
+		if e, ok := f.bindings.Lookup(f.prev.Literal); ok && e.IsInfix {
+			return true
+		}
+	}
+	if inTable {
+		return false
+	}
+	switch tok.Type {
+	case token.DOT, token.ELVIS, token.COMMA:
+		return true
+	case token.IDENTIFIER:
+		// A leading operator, as in pipelines split before each ->,
+		// unless the line binds it.
+		if next.Type == token.COLON || next.Type == token.AT_COLON {
+			return false
+		}
+		e, ok := f.bindings.Lookup(tok.Literal)
+		return ok && e.IsInfix && !e.IsPrefix
+	}
+	return false
+}
+
+// spaced reports whether a and b, written on the same line, are
+// separated by a space.
+func (f *formatter) spaced(a, b token.Token) bool {
+	inTable := len(f.open) > 0 && f.open[len(f.open)-1] == token.LBRACKET
+	switch {
+	case a.Type == token.LPAREN || a.Type == token.LBRACKET:
+		return false
+	case b.Type == token.RPAREN || b.Type == token.RBRACKET:
+		return false
+	case a.Type == token.INTERP_START || a.Type == token.INTERP_MID,
+		b.Type == token.INTERP_MID || b.Type == token.INTERP_END:
+		// Interpolated expressions sit against their braces: "a${x}b".
+		return false
+	case a.Type == token.LBRACE && b.Type == token.RBRACE:
+		return false
+	case a.Type == token.INTEGER && b.Type == token.LBRACE,
+		a.Type == token.RBRACE && b.Type == token.INTEGER:
+		// Binding powers are written against the braces: 50{ ... }60.
+		return a.End != b.Offset
+	case b.Type == token.SEMICOLON || b.Type == token.COMMA:
+		return false
+	case a.Type == token.DOT || b.Type == token.DOT:
+		return false
+	case a.Type == token.AT:
+		return false
+	case b.Type == token.COLON && inTable:
+		// Table keys: [name: "Ann" age: 30]
+		return false
+	}
+	return true
+}
+
+// opens and closes treat "text${ and }text" as brackets around the
+// interpolated expression; }text${ closes one and opens the next, so it
+// neither.
+func opens(t token.TokenType) bool {
+	return t == token.LPAREN || t == token.LBRACKET || t == token.LBRACE || t == token.INTERP_START
+}
+
+func closes(t token.TokenType) bool {
+	return t == token.RPAREN || t == token.RBRACKET || t == token.RBRACE || t == token.INTERP_END
+}
+
+// reindentDoc moves the lines of a docstring that starts on a line of its
+// own to indent, closing quotes included. The lexer strips the common
+// indentation of docstrings, so their value does not change; if it would,
+// the docstring is kept as written.
+func reindentDoc(text string, tok token.Token, indent string) string {
+	quote := text[:3]
+	body := text[3 : len(text)-3]
+	if !strings.HasPrefix(body, "\n") {
+		return text
+	}
+	lines := strings.Split(body[1:], "\n")
+	common := -1
+	for _, l := range lines[:len(lines)-1] {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		n := len(l) - len(strings.TrimLeft(l, " \t"))
+		if common < 0 || n < common {
+			common = n
+		}
+	}
+	if common < 0 {
+		common = 0
+	}
+	var b strings.Builder
+	b.WriteString(quote + "\n")
+	for i, l := range lines {
+		switch {
+		case i == len(lines)-1:
+			if strings.TrimSpace(l) != "" {
+				return text
+			}
+			b.WriteString(indent)
+		case strings.TrimSpace(l) == "":
+			b.WriteString("\n")
+		default:
+			b.WriteString(indent + l[common:] + "\n")
+		}
+	}
+	b.WriteString(quote)
+
+	out := b.String()
+	if t := lexer.New([]byte(out)).NextToken(); t.Type != tok.Type || t.Literal != tok.Literal {
+		return text
+	}
+	return out
+}
+
+// bytes joins the lines, aligning the trailing comments of consecutive
+// lines like gofmt.
+func (f *formatter) bytes() []byte {
+	lines := f.lines
+	var b strings.Builder
+	f.starts = make([]int, 0, len(lines)+1)
+	for i := 0; i < len(lines); {
+		j := i
+		width := 0
+		for j < len(lines) && lines[j].comment != "" && !lines[j].raw {
+			if w := len(lines[j].indent) + len([]rune(lines[j].code)); w > width {
+				width = w
+			}
+			j++
+		}
+		if j == i {
+			l := lines[i]
+			f.starts = append(f.starts, b.Len())
+			b.WriteString(strings.TrimRight(l.indent+l.code, " \t"))
+			if l.comment != "" {
+				b.WriteString(" " + l.comment)
+			}
+			b.WriteString("\n")
+			i++
+			continue
+		}
+		for ; i < j; i++ {
+			l := lines[i]
+			f.starts = append(f.starts, b.Len())
+			code := l.indent + l.code
+			b.WriteString(code)
+			b.WriteString(strings.Repeat(" ", width-len([]rune(code))+1))
+			b.WriteString(l.comment + "\n")
+		}
+	}
+	out := strings.TrimRight(b.String(), "\n")
+	if out == "" {
+		f.starts = append(f.starts, 0)
+		return nil
+	}
+	f.starts = append(f.starts, len(out)+1)
+	return []byte(out + "\n")
+}
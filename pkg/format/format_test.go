@@ -0,0 +1,139 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{"spacing", "x   :1 +   2 ;y:x  *  2;", "x : 1 + 2; y : x * 2;\n"},
+		{"table keys", `t : [ a : 1   "b":2 ( 3 ) ];`, `t : [a: 1 "b": 2 (3)];` + "\n"},
+		{"blocks", "f : {left + right};\ne : { };", "f : { left + right };\ne : {};\n"},
+		{"binding powers", "op : 50{left - right}60;", "op : 50{ left - right }60;\n"},
+		{"spaced binding powers", "op : 50 {left - right} 60;", "op : 50 { left - right } 60;\n"},
+		{"access and resources", `v : t . 0 . "k"; "hi" ->   @ stdout;`, `v : t.0."k"; "hi" -> @stdout;` + "\n"},
+		{"commas", "x : 1 ,2 , 3;", "x : 1, 2, 3;\n"},
+		{"digit separators", "n:1_000_000  *  3.141_592;", "n : 1_000_000 * 3.141_592;\n"},
+		{"extended assignment", "x :+ 2;", "x :+ 2;\n"},
+		{"interpolation", `s : "a${  x  +  1 }b${[ 1 ]}";`, `s : "a${x + 1}b${[1]}";` + "\n"},
+		{
+			"indentation",
+			"f : {\nn : right;\n  {\n        right + n\n}\n};",
+			"f : {\n    n : right;\n    {\n        right + n\n    }\n};\n",
+		},
+		{
+			"multi-line table",
+			"m : [\n[1 2]\n  [3 4]\n      ];",
+			"m : [\n    [1 2]\n    [3 4]\n];\n",
+		},
+		{
+			"continuation",
+			"total : 1 +\n2;\ndata\n-> { right }\n-> @stdout;",
+			"total : 1 +\n    2;\ndata\n    -> { right }\n    -> @stdout;\n",
+		},
+		{
+			"operator binding is not a continuation",
+			"add : { left + right }\nadd2 : 2 |> add\n",
+			"add : { left + right }\nadd2 : 2 |> add\n",
+		},
+		{"blank lines", "\n\na : 1;\n\n\n\nb : 2;   \n\n\n", "a : 1;\n\nb : 2;\n"},
+		{
+			"comments",
+			"# header\na : 1;   # one\nbb : 22; # two\n\nc : 3;      # three\n  # indented\n",
+			"# header\na : 1;   # one\nbb : 22; # two\n\nc : 3; # three\n# indented\n",
+		},
+		{"comment in block", "f : {\n# doc\nright\n};", "f : {\n    # doc\n    right\n};\n"},
+		{"block comment kept", "###\n  kept   as is\n###\nx : 1;", "###\n  kept   as is\n###\nx : 1;\n"},
+		{
+			"docstring indentation",
+			"m : [\n\"\"\"\n  Doc line.\n    Indented.\n\"\"\"\nf : { right }\n];",
+			"m : [\n    \"\"\"\n    Doc line.\n      Indented.\n    \"\"\"\n    f: { right }\n];\n",
+		},
+		{"strings kept", `s : "a  b\tc" + 'raw  $x';`, `s : "a  b\tc" + 'raw  $x';` + "\n"},
+		{"signed literals", "xs : [1 -2 ( -3 )];", "xs : [1 -2 (-3)];\n"},
+		{"empty", "\n\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Source([]byte(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.expected)
+			}
+			again, err := Source(got)
+			if err != nil || string(again) != string(got) {
+				t.Errorf("not idempotent: %q, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	for _, input := range []string{`x : "open`, "x : (1 + 2;"} {
+		if _, err := Source([]byte(input)); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	src := "a   :  1;\n\n\n\nf : {\nx:right;\n   x  *  2\n};   # f\nb:2;c :3;\n\nd  :  4;"
+	tests := []struct {
+		name, part    string // part selects the range: its first occurrence in src
+		replaced, out string
+	}{
+		{"one line", "a   :", "a   :  1;\n", "a : 1;\n"},
+		{"inside a block", "right", "f : {\nx:right;\n   x  *  2\n};   # f\n", "f : {\n    x : right;\n    x * 2\n}; # f\n"},
+		{"statements sharing a line", "c :3", "b:2;c :3;\n", "b : 2; c : 3;\n"},
+		{"last line without newline", "4", "d  :  4;", "d : 4;\n"},
+		{"blank lines kept around", "\n\n\nf", "f : {\nx:right;\n   x  *  2\n};   # f\n", "f : {\n    x : right;\n    x * 2\n}; # f\n"},
+		{"blank lines inside", "1;\n\n\n\nf :", "a   :  1;\n\n\n\nf : {\nx:right;\n   x  *  2\n};   # f\n", "a : 1;\n\nf : {\n    x : right;\n    x * 2\n}; # f\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := strings.Index(src, tt.part)
+			from, to, out, err := Range([]byte(src), start, start+len(tt.part))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if src[from:to] != tt.replaced || string(out) != tt.out {
+				t.Errorf("replaced %q with %q, want %q with %q", src[from:to], out, tt.replaced, tt.out)
+			}
+		})
+	}
+
+	if _, _, _, err := Range([]byte("x : (1 + 2;"), 0, 1); err == nil {
+		t.Error("a source that does not parse: expected an error")
+	}
+}
+
+// TestExamples formats the example programs: formatting succeeds (so the
+// tokens are unchanged) and is idempotent.
+func TestExamples(t *testing.T) {
+	files, err := filepath.Glob("../../examples/*.org")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no examples: %v", err)
+	}
+	for _, path := range files {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		once, err := Source(src)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		twice, err := Source(once)
+		if err != nil || string(twice) != string(once) {
+			t.Errorf("%s: formatting is not idempotent", path)
+		}
+	}
+}
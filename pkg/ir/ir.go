@@ -0,0 +1,203 @@
+// Package ir is the intermediate representation between the syntax tree
+// and the C printer of the code generator. Lower turns a program into a
+// Module of functions, one for the module's top level and one per block,
+// each a list of basic blocks of instructions; codegen.PrintC prints it as
+// C. Passes over the program, such as constant folding or another
+// backend, work on this structure rather than on the tree or the C text.
+//
+// The form is SSA: every instruction defines one Value, numbered from 0
+// in its function, and values are never reassigned. Where control flow
+// joins, as after the right operand of && was or was not evaluated, the
+// joining block has a Param, the value each jump to it passes.
+package ir
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"orglang/pkg/ast"
+)
+
+// Value is the result of an instruction: its index in Func.Insts.
+type Value int
+
+// NoValue is an absent operand, such as the left operand of a prefix
+// call or the message of an assert without one.
+const NoValue Value = -1
+
+func (v Value) String() string {
+	if v == NoValue {
+		return "_"
+	}
+	return "v" + strconv.Itoa(int(v))
+}
+
+// Op is the operation of an instruction.
+type Op uint8
+
+const (
+	Int      Op = iota // Text: an integer literal
+	Decimal            // Text: a decimal literal
+	Rational           // Text: num/den
+	String             // Text: the string's value
+	Bool               // Text: true or false
+	Operand            // Text: left, right or this
+	Load               // Text: a name, looked up in the scope
+	Bind               // Text: a name bound in this scope to Args[0], the result
+	Assign             // Text: a name rebound where it is bound to Args[0], the result
+	Call               // Text: an operator, called with Args[0] (NoValue for a prefix call) and Args[1]
+	Dot                // Args[0].Args[1]
+	Table              // a new empty table
+	Push               // appends Args[1] to the table Args[0], the result
+	Set                // sets Args[1] to Args[2] in the table Args[0], the result
+	Closure            // Func: the block, closed over this scope
+	Resource           // Text: the resource @Text
+	Truth              // Args[0] as a Boolean of its truth, or itself if an Error
+	Assert             // Args[0] is checked, Args[1] the message; Text: the condition's source
+	Param              // the value passed by the jumps to the block it starts
+	Error              // Text: the message of an Error
+	Import             // Text: the path of a module imported with "path" @ org, as written; its table
+	Apply              // calls the operator Args[0] with Args[1] (NoValue for a prefix call) and Args[2]
+	Concat             // the String of the display texts of Args[0] and Args[1]
+	Quantity           // Text: a duration or size literal as written, 250ms
+	Break              // break Args[0], the result ending a loop, or Args[0] if an Error
+	Unbreak            // the value the break Args[0] ends its loop with
+)
+
+var opNames = [...]string{
+	Int: "int", Decimal: "decimal", Rational: "rational", String: "string", Bool: "bool",
+	Operand: "operand", Load: "load", Bind: "bind", Assign: "assign", Call: "call",
+	Dot: "dot", Table: "table", Push: "push", Set: "set", Closure: "closure",
+	Resource: "resource", Truth: "truth", Assert: "assert", Param: "param", Error: "error",
+	Import: "import", Apply: "apply", Concat: "concat", Quantity: "quantity",
+	Break: "break", Unbreak: "unbreak",
+}
+
+func (o Op) String() string {
+	if int(o) < len(opNames) {
+		return opNames[o]
+	}
+	return fmt.Sprintf("Op(%d)", int(o))
+}
+
+// Inst is one instruction. Which fields it uses depends on its Op.
+type Inst struct {
+	Op   Op
+	Text string
+	Args []Value
+	Func int     // of a Closure, its index in Module.Funcs
+	Pos  ast.Pos // of the expression it was lowered from
+}
+
+// TermKind is how a basic block ends.
+type TermKind uint8
+
+const (
+	Return  TermKind = iota // returns Value
+	Jump                    // goes to Then, passing Value to its Param
+	IfTrue                  // goes to Then if Value is truthy, else to Else
+	IfError                 // goes to Then if Value is an Error, else to Else
+	IfBreak                 // goes to Then if Value is a Break, else to Else
+)
+
+// Term ends a basic block.
+type Term struct {
+	Kind       TermKind
+	Value      Value
+	Then, Else int // indexes in Func.Blocks
+}
+
+// Block is a basic block: instructions run in order, then its Term.
+type Block struct {
+	Insts []Value
+	Term  Term
+}
+
+// Func is a function: a block of the program, or the top level of its
+// module, which is Module.Funcs[0]. Its Blocks start with Blocks[0].
+type Func struct {
+	// Source is the canonical text of the block, its String(), naming
+	// the function; it is "" for the top level.
+	Source string
+	Pos    ast.Pos
+	// Arity is 2 for a block using left, 1 for one using right only, and
+	// 0 otherwise, as the interpreter counts a block's operands.
+	Arity  int
+	Insts  []Inst
+	Blocks []*Block
+}
+
+// Module is a lowered program.
+type Module struct {
+	Path  string
+	Funcs []*Func
+}
+
+// String renders m as text, a function after another, for org build
+// --emit=ir and for tests:
+//
+//	func 1 { (right * 2) }
+//	b0:
+//	  v0 = operand right
+//	  v1 = int 2
+//	  v2 = call * v0 v1
+//	  return v2
+func (m *Module) String() string {
+	var b strings.Builder
+	for i, f := range m.Funcs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		name := "top level"
+		if f.Source != "" {
+			name = f.Source
+		}
+		fmt.Fprintf(&b, "func %d %s\n", i, name)
+		for j, blk := range f.Blocks {
+			fmt.Fprintf(&b, "b%d:\n", j)
+			for _, v := range blk.Insts {
+				fmt.Fprintf(&b, "  %s = %s\n", v, f.Insts[v].describe())
+			}
+			fmt.Fprintf(&b, "  %s\n", blk.Term.describe())
+		}
+	}
+	return b.String()
+}
+
+func (in Inst) describe() string {
+	parts := []string{in.Op.String()}
+	switch in.Op {
+	case String, Assert, Error, Import:
+		parts = append(parts, strconv.Quote(in.Text))
+	case Closure:
+		parts = append(parts, "func "+strconv.Itoa(in.Func))
+	default:
+		if in.Text != "" {
+			parts = append(parts, in.Text)
+		}
+	}
+	for _, a := range in.Args {
+		parts = append(parts, a.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+func (t Term) describe() string {
+	switch t.Kind {
+	case Return:
+		return "return " + t.Value.String()
+	case Jump:
+		if t.Value == NoValue {
+			return fmt.Sprintf("jump b%d", t.Then)
+		}
+		return fmt.Sprintf("jump b%d %s", t.Then, t.Value)
+	case IfTrue:
+		return fmt.Sprintf("if %s b%d b%d", t.Value, t.Then, t.Else)
+	case IfError:
+		return fmt.Sprintf("iferror %s b%d b%d", t.Value, t.Then, t.Else)
+	case IfBreak:
+		return fmt.Sprintf("ifbreak %s b%d b%d", t.Value, t.Then, t.Else)
+	}
+	return fmt.Sprintf("TermKind(%d)", int(t.Kind))
+}
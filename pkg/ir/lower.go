@@ -0,0 +1,521 @@
+package ir
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/diag"
+	"orglang/pkg/parser"
+)
+
+// Lower turns prog, the module at path, into IR. Constructs the code
+// generator does not support yet, such as destructuring, are reported as diagnostics, and lowered as the Error
+// they would give; the module is complete either way. assert statements
+// are checked unless WithAssertions(false) is given.
+//
+// Evaluation order is the interpreter's: operands left to right, the
+// right operand of &&, ||, ?? and ?: only when it decides the result,
+// the value of a guard only when its condition holds. Table elements are
+// evaluated when the table is built, not when first used, but for those
+// of a table literal that ? selects from: only the selected one is.
+func Lower(prog *ast.Program, path string, opts ...Option) (*Module, []diag.Diagnostic) {
+	l := &lowerer{m: &Module{Path: path}, asserts: true}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.function(nil, prog.Statements, true)
+	return l.m, l.diags
+}
+
+// Option configures Lower.
+type Option func(*lowerer)
+
+// WithAssertions decides whether assert statements are checked. Without
+// them, as builds at -O2 and above want (codegen.Assertions), an assert
+// statement lowers to nothing, its condition included; one used as a
+// value is true.
+func WithAssertions(on bool) Option {
+	return func(l *lowerer) { l.asserts = on }
+}
+
+type lowerer struct {
+	m       *Module
+	diags   []diag.Diagnostic
+	asserts bool
+}
+
+// function lowers the block fl, or the top level if fl is nil, to a new
+// function and returns its index. The index is taken before the body is
+// lowered, so functions are numbered in source order, each before the
+// blocks inside it.
+func (l *lowerer) function(fl *ast.FunctionLiteral, body []ast.Statement, top bool) int {
+	f := &Func{}
+	if fl != nil {
+		f.Source, f.Pos = fl.String(), fl.Location().Start
+		switch left, right := parser.Operands(fl); {
+		case left:
+			f.Arity = 2
+		case right:
+			f.Arity = 1
+		}
+	}
+	index := len(l.m.Funcs)
+	l.m.Funcs = append(l.m.Funcs, f)
+	b := &builder{l: l, f: f, top: top}
+	b.cur = b.newBlock()
+	b.body(body)
+	return index
+}
+
+// builder lowers the statements of one function.
+type builder struct {
+	l   *lowerer
+	f   *Func
+	top bool // the module's top level, outside any block
+	cur int  // the block instructions go to
+}
+
+func (b *builder) newBlock() int {
+	b.f.Blocks = append(b.f.Blocks, &Block{})
+	return len(b.f.Blocks) - 1
+}
+
+// emit appends an instruction lowered from n to the current block.
+func (b *builder) emit(n ast.Node, in Inst) Value {
+	in.Pos = n.Location().Start
+	return b.emitIn(b.cur, in)
+}
+
+func (b *builder) emitIn(block int, in Inst) Value {
+	v := Value(len(b.f.Insts))
+	b.f.Insts = append(b.f.Insts, in)
+	b.f.Blocks[block].Insts = append(b.f.Blocks[block].Insts, v)
+	return v
+}
+
+// end ends the current block with t.
+func (b *builder) end(t Term) { b.f.Blocks[b.cur].Term = t }
+
+// join returns a new block starting with a Param, and that Param.
+func (b *builder) join() (int, Value) {
+	blk := b.newBlock()
+	return blk, b.emitIn(blk, Inst{Op: Param})
+}
+
+// body lowers the statements of a function, which returns the value of
+// the last, or an empty table if there is none.
+func (b *builder) body(stmts []ast.Statement) {
+	result := NoValue
+	for _, s := range stmts {
+		switch s := s.(type) {
+		case *ast.GuardExpr:
+			if b.top {
+				result = b.expr(s)
+				continue
+			}
+			result = b.guard(s)
+		case *ast.AssertExpr:
+			if !b.l.asserts {
+				continue
+			}
+			result = b.expr(s)
+			if !b.top {
+				b.returnIfError(result)
+			}
+		default:
+			result = b.expr(s)
+		}
+	}
+	if result == NoValue {
+		result = b.emitIn(b.cur, Inst{Op: Table})
+	}
+	b.end(Term{Kind: Return, Value: result})
+}
+
+// guard lowers `cond !> value`: an Error condition is returned, a true
+// one returns value, and otherwise the condition is the statement's
+// value and the body goes on.
+func (b *builder) guard(g *ast.GuardExpr) Value {
+	cond := b.expr(g.Cond)
+	b.returnIfError(cond)
+	taken, next := b.newBlock(), b.newBlock()
+	b.end(Term{Kind: IfTrue, Value: cond, Then: taken, Else: next})
+	b.cur = taken
+	b.end(Term{Kind: Return, Value: b.expr(g.Value)})
+	b.cur = next
+	return cond
+}
+
+// returnIfError returns v from the function if it is an Error, and goes
+// on in a new block otherwise.
+func (b *builder) returnIfError(v Value) {
+	ret, next := b.newBlock(), b.newBlock()
+	b.end(Term{Kind: IfError, Value: v, Then: ret, Else: next})
+	b.f.Blocks[ret].Term = Term{Kind: Return, Value: v}
+	b.cur = next
+}
+
+// unsupported reports n as beyond the code generator, and lowers it to
+// the Error a program would get from it.
+func (b *builder) unsupported(n ast.Node, format string, args ...any) Value {
+	msg := fmt.Sprintf(format, args...)
+	b.l.diags = append(b.l.diags, diag.Diagnostic{
+		Code:     diag.Unsupported,
+		Severity: diag.Error,
+		Span:     n.Location(),
+		Message:  msg,
+	})
+	return b.emit(n, Inst{Op: Error, Text: msg})
+}
+
+func (b *builder) expr(e ast.Node) Value {
+	switch n := e.(type) {
+	case *ast.IntegerLiteral:
+		return b.emit(n, Inst{Op: Int, Text: n.Value})
+	case *ast.DecimalLiteral:
+		return b.emit(n, Inst{Op: Decimal, Text: n.Value})
+	case *ast.RationalLiteral:
+		return b.emit(n, Inst{Op: Rational, Text: n.Numerator + "/" + n.Denominator})
+	case *ast.StringLiteral:
+		return b.emit(n, Inst{Op: String, Text: n.Value})
+	case *ast.BooleanLiteral:
+		return b.emit(n, Inst{Op: Bool, Text: fmt.Sprint(n.Value)})
+	case *ast.Name:
+		switch n.Value {
+		case "left", "right", "this":
+			if b.top {
+				return b.unsupported(n, "%s used outside any block", n.Value)
+			}
+			return b.emit(n, Inst{Op: Operand, Text: n.Value})
+		}
+		return b.emit(n, Inst{Op: Load, Text: n.Value})
+	case *ast.GroupExpr:
+		return b.expr(n.Inner)
+	case *ast.FunctionLiteral:
+		fn := b.l.function(n, n.Body, false)
+		return b.emit(n, Inst{Op: Closure, Func: fn})
+	case *ast.TableLiteral:
+		return b.table(n, n.Elements)
+	case *ast.CommaExpr:
+		return b.table(n, commaElements(n, nil))
+	case *ast.PrefixExpr:
+		if n.Op == "@" {
+			return b.resource(n, n.Right)
+		}
+		return b.call(n, n.Op, nil, n.Right)
+	case *ast.InfixExpr:
+		return b.infix(n)
+	case *ast.ElvisExpr:
+		// left ?: right is left if it is truthy.
+		left := b.expr(n.Left)
+		done, result := b.join()
+		other := b.newBlock()
+		b.end(Term{Kind: IfTrue, Value: left, Then: b.jumpBlock(done, left), Else: other})
+		b.cur = other
+		b.end(Term{Kind: Jump, Value: b.expr(n.Right), Then: done})
+		b.cur = done
+		return result
+	case *ast.DotExpr:
+		left := b.expr(n.Left)
+		return b.emit(n, Inst{Op: Dot, Args: []Value{left, b.key(n.Key)}})
+	case *ast.BindingExpr:
+		return b.binding(n)
+	case *ast.ResourceDef:
+		name, ok := bindingName(n.Name)
+		if !ok {
+			return b.unsupported(n, "cannot bind %s", n.Name)
+		}
+		value := b.expr(n.Value)
+		return b.emit(n, Inst{Op: Bind, Text: name, Args: []Value{value}})
+	case *ast.ResourceInst:
+		return b.resource(n, n.Name)
+	case *ast.AssertExpr:
+		if !b.l.asserts {
+			return b.emit(n, Inst{Op: Bool, Text: "true"})
+		}
+		cond := b.expr(n.Cond)
+		msg := NoValue
+		if n.Message != nil {
+			msg = b.expr(n.Message)
+		}
+		return b.emit(n, Inst{Op: Assert, Text: n.Cond.String(), Args: []Value{cond, msg}})
+	case *ast.ErrorExpr:
+		// The parser has reported it.
+		return b.emit(n, Inst{Op: Error, Text: n.Message})
+	case *ast.GuardExpr:
+		return b.unsupported(n, "!> must be a statement of a block, returning from it")
+	case *ast.InterpolatedString:
+		return b.interpolate(n)
+	case *ast.QuantityLiteral:
+		return b.emit(n, Inst{Op: Quantity, Text: n.Value + n.Unit})
+	}
+	return b.unsupported(e, "cannot lower %T", e)
+}
+
+// jumpBlock returns a new block jumping to target with v.
+func (b *builder) jumpBlock(target int, v Value) int {
+	blk := b.newBlock()
+	b.f.Blocks[blk].Term = Term{Kind: Jump, Value: v, Then: target}
+	return blk
+}
+
+// interpolate lowers "text${expr}text": the texts and the display text
+// of each part concatenated, the parts evaluated in order until one is
+// an Error, which is the result.
+func (b *builder) interpolate(n *ast.InterpolatedString) Value {
+	done, result := b.join()
+	s := b.emit(n, Inst{Op: String, Text: n.Texts[0]})
+	for i, e := range n.Exprs {
+		part := b.expr(e)
+		next := b.newBlock()
+		b.end(Term{Kind: IfError, Value: part, Then: b.jumpBlock(done, part), Else: next})
+		b.cur = next
+		s = b.emit(e, Inst{Op: Concat, Args: []Value{s, part}})
+		if text := n.Texts[i+1]; text != "" {
+			s = b.emit(n, Inst{Op: Concat, Args: []Value{s, b.emit(n, Inst{Op: String, Text: text})}})
+		}
+	}
+	b.end(Term{Kind: Jump, Value: s, Then: done})
+	b.cur = done
+	return result
+}
+
+// infix lowers a binary operator. &&, || and ?? evaluate their right
+// operand only when it decides the result; the others are calls.
+func (b *builder) infix(n *ast.InfixExpr) Value {
+	switch n.Op {
+	case "&&", "||":
+		// An Error on the left is the result; a left deciding the result
+		// gives false for &&, true for ||; otherwise the truth of right.
+		left := b.expr(n.Left)
+		done, result := b.join()
+		test, decided, rest := b.newBlock(), b.newBlock(), b.newBlock()
+		b.end(Term{Kind: IfError, Value: left, Then: b.jumpBlock(done, left), Else: test})
+		b.cur = test
+		if n.Op == "&&" {
+			b.end(Term{Kind: IfTrue, Value: left, Then: rest, Else: decided})
+		} else {
+			b.end(Term{Kind: IfTrue, Value: left, Then: decided, Else: rest})
+		}
+		b.cur = decided
+		b.end(Term{Kind: Jump, Value: b.emit(n, Inst{Op: Bool, Text: fmt.Sprint(n.Op == "||")}), Then: done})
+		b.cur = rest
+		right := b.expr(n.Right)
+		b.end(Term{Kind: Jump, Value: b.emit(n, Inst{Op: Truth, Args: []Value{right}}), Then: done})
+		b.cur = done
+		return result
+	case "??":
+		left := b.expr(n.Left)
+		done, result := b.join()
+		other := b.newBlock()
+		b.end(Term{Kind: IfError, Value: left, Then: other, Else: b.jumpBlock(done, left)})
+		b.cur = other
+		b.end(Term{Kind: Jump, Value: b.expr(n.Right), Then: done})
+		b.cur = done
+		return result
+	case "?":
+		if t, ok := n.Right.(*ast.TableLiteral); ok && len(t.Elements) > 0 {
+			return b.choose(n, t.Elements)
+		}
+	case "-<", "-<>":
+		return b.unsupported(n, "%s is not supported by the code generator yet", n.Op)
+	case "loop":
+		return b.loop(n)
+	case "@":
+		lit, isStr := n.Left.(*ast.StringLiteral)
+		if org, ok := n.Right.(*ast.Name); !isStr || !ok || org.Value != "org" {
+			return b.unsupported(n, `a module is imported as "path" @ org`)
+		}
+		return b.emit(n, Inst{Op: Import, Text: lit.Value})
+	}
+	return b.call(n, n.Op, n.Left, n.Right)
+}
+
+// loop lowers init loop op to a loop of blocks: op is called on init,
+// then on each of its results in turn, until one is a Break, whose value
+// is the result, or an Error, which is. A literal op must be unary.
+func (b *builder) loop(n *ast.InfixExpr) Value {
+	init := b.expr(n.Left)
+	done, result := b.join()
+	next := b.newBlock()
+	b.end(Term{Kind: IfError, Value: init, Then: b.jumpBlock(done, init), Else: next})
+	b.cur = next
+	op := NoValue
+	if fl, ok := n.Right.(*ast.FunctionLiteral); ok {
+		if left, right := parser.Operands(fl); left || !right {
+			op = b.emit(n, Inst{Op: Error, Text: "loop needs a unary operator, got " + fl.String()})
+		}
+	}
+	if op == NoValue {
+		op = b.expr(n.Right)
+	}
+	head, state := b.join()
+	b.end(Term{Kind: IfError, Value: op, Then: b.jumpBlock(done, op), Else: b.jumpBlock(head, init)})
+	b.cur = head
+	step := b.emit(n, Inst{Op: Apply, Args: []Value{op, NoValue, state}})
+	test, broken := b.newBlock(), b.newBlock()
+	b.end(Term{Kind: IfError, Value: step, Then: b.jumpBlock(done, step), Else: test})
+	b.cur = test
+	b.end(Term{Kind: IfBreak, Value: step, Then: broken, Else: b.jumpBlock(head, step)})
+	b.cur = broken
+	b.end(Term{Kind: Jump, Value: b.emit(n, Inst{Op: Unbreak, Args: []Value{step}}), Then: done})
+	b.cur = done
+	return result
+}
+
+// call lowers a call of the operator op, prefix if left is nil. Inside a
+// block, the operator can be one of its operands, as in this (right - 1).
+// A prefix break is the Break ending a loop.
+func (b *builder) call(n ast.Node, op string, left, right ast.Expression) Value {
+	fn := NoValue
+	switch op {
+	case "left", "right", "this":
+		if b.top {
+			return b.unsupported(n, "%s used outside any block", op)
+		}
+		fn = b.emit(n, Inst{Op: Operand, Text: op})
+	case "break":
+		if left == nil {
+			return b.emit(n, Inst{Op: Break, Args: []Value{b.expr(right)}})
+		}
+	}
+	l := NoValue
+	if left != nil {
+		l = b.expr(left)
+	}
+	r := b.expr(right)
+	if fn != NoValue {
+		return b.emit(n, Inst{Op: Apply, Args: []Value{fn, l, r}})
+	}
+	return b.emit(n, Inst{Op: Call, Text: op, Args: []Value{l, r}})
+}
+
+// binding lowers name : value, and the compound bindings such as
+// name :+ value, which rebind name to name + value where it is bound.
+func (b *builder) binding(n *ast.BindingExpr) Value {
+	if _, ok := n.Name.(*ast.TableLiteral); ok {
+		return b.unsupported(n, "destructuring is not supported by the code generator yet")
+	}
+	name, ok := bindingName(n.Name)
+	if !ok {
+		return b.unsupported(n, "cannot bind %s", n.Name)
+	}
+	value := b.expr(n.Value)
+	if n.Operator == "" || n.Operator == ":" {
+		return b.emit(n, Inst{Op: Bind, Text: name, Args: []Value{value}})
+	}
+	op := strings.TrimPrefix(n.Operator, ":")
+	if op == ">>>" {
+		op = ">>"
+	}
+	cur := b.emit(n.Name, Inst{Op: Load, Text: name})
+	if op == "~" {
+		cur = NoValue
+	}
+	result := b.emit(n, Inst{Op: Call, Text: op, Args: []Value{cur, value}})
+	return b.emit(n, Inst{Op: Assign, Text: name, Args: []Value{result}})
+}
+
+// table lowers a table literal or comma expression of elems: key: value
+// elements set a key, the others are appended in order.
+func (b *builder) table(n ast.Node, elems []ast.Expression) Value {
+	t := b.emit(n, Inst{Op: Table})
+	for _, el := range elems {
+		k, val := entry(el)
+		if k == nil {
+			b.emit(el, Inst{Op: Push, Args: []Value{t, b.expr(el)}})
+			continue
+		}
+		b.emit(el, Inst{Op: Set, Args: []Value{t, b.key(k), b.expr(val)}})
+	}
+	return t
+}
+
+// choose lowers cond ? [k: v ...] over a table literal, evaluating only
+// the selected entry as eval does: cond selects the position of its entry
+// from a table of the keys, and the branch for that position evaluates the
+// entry. An Error cond, or one that keys no entry, is the Error selecting
+// gives.
+func (b *builder) choose(n *ast.InfixExpr, elems []ast.Expression) Value {
+	cond := b.expr(n.Left)
+	keys := b.emit(n, Inst{Op: Table})
+	pos := make([]Value, len(elems))
+	vals := make([]ast.Expression, len(elems))
+	for i, el := range elems {
+		pos[i] = b.emit(el, Inst{Op: Int, Text: strconv.Itoa(i)})
+		k, val := entry(el)
+		if k == nil {
+			b.emit(el, Inst{Op: Push, Args: []Value{keys, pos[i]}})
+			vals[i] = el
+			continue
+		}
+		b.emit(el, Inst{Op: Set, Args: []Value{keys, b.key(k), pos[i]}})
+		vals[i] = val
+	}
+	sel := b.emit(n, Inst{Op: Call, Text: "?", Args: []Value{cond, keys}})
+	done, result := b.join()
+	next := b.newBlock()
+	b.end(Term{Kind: IfError, Value: sel, Then: b.jumpBlock(done, sel), Else: next})
+	b.cur = next
+	for i, val := range vals[:len(vals)-1] {
+		then, other := b.newBlock(), b.newBlock()
+		b.end(Term{Kind: IfTrue, Value: b.emit(n, Inst{Op: Call, Text: "=", Args: []Value{sel, pos[i]}}), Then: then, Else: other})
+		b.cur = then
+		b.end(Term{Kind: Jump, Value: b.expr(val), Then: done})
+		b.cur = other
+	}
+	b.end(Term{Kind: Jump, Value: b.expr(vals[len(vals)-1]), Then: done})
+	b.cur = done
+	return result
+}
+
+// entry returns the key and value of the element el of a table literal,
+// or a nil key for a positional element.
+func entry(el ast.Expression) (k, val ast.Expression) {
+	switch e := el.(type) {
+	case *ast.BindingExpr:
+		if e.Operator == "" || e.Operator == ":" {
+			return e.Name, e.Value
+		}
+	case *ast.ResourceDef:
+		return e.Name, e.Value
+	}
+	return nil, nil
+}
+
+// key lowers the key of a table entry: a name is its string.
+func (b *builder) key(k ast.Expression) Value {
+	if name, ok := k.(*ast.Name); ok {
+		return b.emit(name, Inst{Op: String, Text: name.Value})
+	}
+	return b.expr(k)
+}
+
+// resource lowers @name; other resources are values naming one.
+func (b *builder) resource(n ast.Node, name ast.Expression) Value {
+	if s, ok := bindingName(name); ok {
+		return b.emit(n, Inst{Op: Resource, Text: s})
+	}
+	return b.unsupported(n, "@%s: computed resources are not supported by the code generator yet", name)
+}
+
+// bindingName returns the name a binding binds, for a name or a string.
+func bindingName(e ast.Expression) (string, bool) {
+	switch e := e.(type) {
+	case *ast.Name:
+		return e.Value, true
+	case *ast.StringLiteral:
+		return e.Value, true
+	}
+	return "", false
+}
+
+// commaElements flattens a, b, c into its elements.
+func commaElements(e ast.Expression, out []ast.Expression) []ast.Expression {
+	if c, ok := e.(*ast.CommaExpr); ok {
+		return commaElements(c.Right, commaElements(c.Left, out))
+	}
+	return append(out, e)
+}
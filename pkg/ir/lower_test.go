@@ -0,0 +1,347 @@
+package ir
+
+import (
+	"strings"
+	"testing"
+
+	"orglang/pkg/diag"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+)
+
+func lower(t *testing.T, src string) (*Module, []diag.Diagnostic) {
+	t.Helper()
+	p := parser.New(lexer.New([]byte(src)))
+	prog := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse %q: %v", src, errs)
+	}
+	return Lower(prog, "m.org")
+}
+
+func TestLower(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"binding", "x : 1 + 2", `
+func 0 top level
+b0:
+  v0 = int 1
+  v1 = int 2
+  v2 = call + v0 v1
+  v3 = bind x v2
+  return v3`},
+		{"block", "double : { right * 2 }; double 4", `
+func 0 top level
+b0:
+  v0 = closure func 1
+  v1 = bind double v0
+  v2 = int 4
+  v3 = call double _ v2
+  return v3
+
+func 1 { (right * 2) }
+b0:
+  v0 = operand right
+  v1 = int 2
+  v2 = call * v0 v1
+  return v2`},
+		{"table", `x : 2; [1 "k": x].k`, `
+func 0 top level
+b0:
+  v0 = int 2
+  v1 = bind x v0
+  v2 = table
+  v3 = int 1
+  v4 = push v2 v3
+  v5 = string "k"
+  v6 = load x
+  v7 = set v2 v5 v6
+  v8 = string "k"
+  v9 = dot v2 v8
+  return v9`},
+		{"compound binding", "n :+ 1", `
+func 0 top level
+b0:
+  v0 = int 1
+  v1 = load n
+  v2 = call + v1 v0
+  v3 = assign n v2
+  return v3`},
+		{"and", "true && 1", `
+func 0 top level
+b0:
+  v0 = bool true
+  iferror v0 b5 b2
+b1:
+  v1 = param
+  return v1
+b2:
+  if v0 b4 b3
+b3:
+  v2 = bool false
+  jump b1 v2
+b4:
+  v3 = int 1
+  v4 = truth v3
+  jump b1 v4
+b5:
+  jump b1 v0`},
+		{"coalesce", "(1 / 0) ?? 0", `
+func 0 top level
+b0:
+  v0 = int 1
+  v1 = int 0
+  v2 = call / v0 v1
+  iferror v2 b2 b3
+b1:
+  v3 = param
+  return v3
+b2:
+  v4 = int 0
+  jump b1 v4
+b3:
+  jump b1 v2`},
+		{"guard", "f : { right = 0 !> 1; 2 }", `
+func 0 top level
+b0:
+  v0 = closure func 1
+  v1 = bind f v0
+  return v1
+
+func 1 { ((right = 0) !> 1); 2 }
+b0:
+  v0 = operand right
+  v1 = int 0
+  v2 = call = v0 v1
+  iferror v2 b1 b2
+b1:
+  return v2
+b2:
+  if v2 b3 b4
+b3:
+  v3 = int 1
+  return v3
+b4:
+  v4 = int 2
+  return v4`},
+		{"this", "{ this (right - 1) }", `
+func 0 top level
+b0:
+  v0 = closure func 1
+  return v0
+
+func 1 { (this ((right - 1))) }
+b0:
+  v0 = operand this
+  v1 = operand right
+  v2 = int 1
+  v3 = call - v1 v2
+  v4 = apply v0 _ v3
+  return v4`},
+		{"select", "x : 1; x ? [2 k: 3]", `
+func 0 top level
+b0:
+  v0 = int 1
+  v1 = bind x v0
+  v2 = load x
+  v3 = table
+  v4 = int 0
+  v5 = push v3 v4
+  v6 = int 1
+  v7 = string "k"
+  v8 = set v3 v7 v6
+  v9 = call ? v2 v3
+  iferror v9 b3 b2
+b1:
+  v10 = param
+  return v10
+b2:
+  v11 = call = v9 v4
+  if v11 b4 b5
+b3:
+  jump b1 v9
+b4:
+  v12 = int 2
+  jump b1 v12
+b5:
+  v13 = int 3
+  jump b1 v13`},
+		{"quantity", "1s + 250ms", `
+func 0 top level
+b0:
+  v0 = quantity 1s
+  v1 = quantity 250ms
+  v2 = call + v0 v1
+  return v2`},
+		{"loop", "1 loop { break right }", `
+func 0 top level
+b0:
+  v0 = int 1
+  iferror v0 b3 b2
+b1:
+  v1 = param
+  return v1
+b2:
+  v2 = closure func 1
+  iferror v2 b5 b6
+b3:
+  jump b1 v0
+b4:
+  v3 = param
+  v4 = apply v2 _ v3
+  iferror v4 b9 b7
+b5:
+  jump b1 v2
+b6:
+  jump b4 v0
+b7:
+  ifbreak v4 b8 b10
+b8:
+  v5 = unbreak v4
+  jump b1 v5
+b9:
+  jump b1 v4
+b10:
+  jump b4 v4
+
+func 1 { (break right) }
+b0:
+  v0 = operand right
+  v1 = break v0
+  return v1`},
+		{"interpolation", `x : 1; "a${x}b"`, `
+func 0 top level
+b0:
+  v0 = int 1
+  v1 = bind x v0
+  v3 = string "a"
+  v4 = load x
+  iferror v4 b3 b2
+b1:
+  v2 = param
+  return v2
+b2:
+  v5 = concat v3 v4
+  v6 = string "b"
+  v7 = concat v5 v6
+  jump b1 v7
+b3:
+  jump b1 v4`},
+		{"empty block", "{ }", `
+func 0 top level
+b0:
+  v0 = closure func 1
+  return v0
+
+func 1 {  }
+b0:
+  v0 = table
+  return v0`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, diags := lower(t, tt.src)
+			if len(diags) > 0 {
+				t.Fatalf("diagnostics: %v", diags)
+			}
+			if got, want := strings.TrimSpace(m.String()), strings.TrimSpace(tt.want); got != want {
+				t.Errorf("Lower(%q) =\n%s\nwant\n%s", tt.src, got, want)
+			}
+		})
+	}
+}
+
+// TestLowerArity checks that a block's function counts its operands as
+// the interpreter does, ignoring those of the blocks inside it.
+func TestLowerArity(t *testing.T) {
+	m, _ := lower(t, "a : { left + right }; b : { right }; c : { 1 }; d : { e : { left }; e }")
+	for i, want := range []int{2, 1, 0, 0, 2} {
+		if got := m.Funcs[i+1].Arity; got != want {
+			t.Errorf("%s: arity %d, want %d", m.Funcs[i+1].Source, got, want)
+		}
+	}
+}
+
+// TestLowerPositions checks that instructions keep the position of the
+// expression they come from, for the #line directives of the C.
+func TestLowerPositions(t *testing.T) {
+	m, _ := lower(t, "x : 1;\ny : x * 2")
+	f := m.Funcs[0]
+	for _, v := range f.Blocks[0].Insts {
+		in := f.Insts[v]
+		want := 1
+		if in.Op == Load || in.Op == Call || in.Text == "y" || in.Text == "2" {
+			want = 2
+		}
+		if in.Pos.Line != want {
+			t.Errorf("%s at line %d, want %d", in.describe(), in.Pos.Line, want)
+		}
+	}
+}
+
+func TestLowerUnsupported(t *testing.T) {
+	tests := []struct{ src, msg string }{
+		{"[a b] : [1 2]", "destructuring"},
+		{"left + 1", "left used outside any block"},
+		{"this 1", "this used outside any block"},
+		{`"lib.org" @ 1`, `imported as "path" @ org`},
+	}
+	for _, tt := range tests {
+		m, diags := lower(t, tt.src)
+		if len(diags) != 1 || diags[0].Code != diag.Unsupported || !strings.Contains(diags[0].Message, tt.msg) {
+			t.Errorf("Lower(%q) diagnostics = %v, want one %s about %q", tt.src, diags, diag.Unsupported, tt.msg)
+			continue
+		}
+		if !strings.Contains(m.String(), "error ") {
+			t.Errorf("Lower(%q) lowers no Error:\n%s", tt.src, m)
+		}
+	}
+}
+
+// TestLowerWithoutAssertions checks that an unchecked assert statement
+// leaves nothing behind, not even its condition.
+func TestLowerWithoutAssertions(t *testing.T) {
+	src := `f : { assert (right > 0) "positive"; right }; ok : assert 1 < 2`
+	p := parser.New(lexer.New([]byte(src)))
+	m, diags := Lower(p.ParseProgram(), "m.org", WithAssertions(false))
+	if len(diags) > 0 || len(p.Errors()) > 0 {
+		t.Fatalf("diagnostics: %v %v", p.Errors(), diags)
+	}
+	want := `
+func 0 top level
+b0:
+  v0 = closure func 1
+  v1 = bind f v0
+  v2 = bool true
+  v3 = bind ok v2
+  return v3
+
+func 1 { (assert ((right > 0)) "positive"); right }
+b0:
+  v0 = operand right
+  return v0`
+	if got := m.String(); strings.TrimSpace(got) != strings.TrimSpace(want) {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestLowerEveryStatement lowers the statements of a program using each
+// kind of node the parser builds, none of which may be left out.
+func TestLowerEveryStatement(t *testing.T) {
+	src := `f : { assert right > 0 "positive"; (right - 1) ?: 0 };
+t : [1 2], 3;
+r @: [x: 1];
+v : (@stdout) -> f;
+lib : "lib.org" @ org;
+! true || false`
+	m, diags := lower(t, src)
+	if len(diags) > 0 {
+		t.Fatalf("diagnostics: %v", diags)
+	}
+	for _, op := range []Op{Closure, Assert, Bind, Table, Push, Set, Resource, Call, Truth, Param, Import} {
+		if !strings.Contains(m.String(), " = "+op.String()) {
+			t.Errorf("no %s instruction in\n%s", op, m)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Package learn holds the lessons of org learn, a tutorial of the
+// language run in the terminal, and the session that checks the answers:
+// each lesson asks for a little code, which is evaluated as in the REPL,
+// and passes when its check, an OrgLang expression, is true afterwards.
+package learn
+
+import (
+	"errors"
+	"io"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/eval"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+)
+
+// Lesson is one step of the tutorial.
+type Lesson struct {
+	Title string
+	// Text explains the idea of the lesson, with examples.
+	Text string
+	// Task says what to write.
+	Task string
+	// Check is evaluated in the session after each answer; the lesson is
+	// passed when it is true. It sees the answer's bindings, and it, the
+	// value of its last expression.
+	Check string
+	// Hint helps without giving the answer; Solution is an answer.
+	Hint, Solution string
+}
+
+// Lessons returns the lessons in order, each building on those before.
+func Lessons() []Lesson {
+	return []Lesson{
+		{
+			Title: "Expressions",
+			Text: `An OrgLang program is made of expressions. Numbers are exact:
+integers, decimals and rationals such as 1/3 never round.
+
+  1 + 2 * 3      # 7
+  2 ** 10        # 1024
+  1/3 + 1/6      # 1/2`,
+			Task:     "Write an expression computing 6 times 7.",
+			Check:    "it = 42",
+			Hint:     "The operator for multiplication is *.",
+			Solution: "6 * 7",
+		},
+		{
+			Title: "Bindings",
+			Text: `A name is bound to a value with a colon. Bindings are not
+variables assigned in turn: a name stands for its expression.
+
+  width : 8;
+  height : 5;
+  area : width * height;   # 40`,
+			Task:     "Bind the name answer to 6 times 7.",
+			Check:    "answer = 42",
+			Hint:     "name : expression",
+			Solution: "answer : 6 * 7",
+		},
+		{
+			Title: "Tables",
+			Text: `The only data structure is the table, written in brackets with
+its elements separated by spaces. Elements are numbered from 0 and read
+with a dot.
+
+  days : ["mon" "tue" "wed"];
+  days.1                   # "tue"`,
+			Task:     "Bind primes to a table of the primes 2, 3, 5 and 7.",
+			Check:    "(primes.0 = 2) && (primes.1 = 3) && (primes.2 = 5) && (primes.3 = 7)",
+			Hint:     "[a b c] is a table of three elements.",
+			Solution: "primes : [2 3 5 7]",
+		},
+		{
+			Title: "Keys",
+			Text: `A table also holds values under keys, written key: value.
+The same table can mix positions and keys; a dot reads either.
+
+  point : ["x": 3 "y": 4];
+  point.x                  # 3`,
+			Task:     `Bind ada to a table with "Ada" under the key name and 1815 under born.`,
+			Check:    `(ada.name = "Ada") && (ada.born = 1815)`,
+			Hint:     `["key": value "other": value]`,
+			Solution: `ada : ["name": "Ada" "born": 1815]`,
+		},
+		{
+			Title: "Blocks",
+			Text: `There are no function declarations: a block in braces is a
+value, and it becomes an operator when bound to a name. What follows the
+operator is its right operand, called right inside the block.
+
+  increment : { right + 1 };
+  increment 5              # 6`,
+			Task:     "Bind square to a block giving its right operand times itself.",
+			Check:    "((square 3) = 9) && ((square 5) = 25)",
+			Hint:     "right * right",
+			Solution: "square : { right * right }",
+		},
+		{
+			Title: "Left and right",
+			Text: `A block using left as well is a binary operator, written
+between its operands like + or *.
+
+  add : { left + right };
+  10 add 20                # 30
+
+An operator takes all of the expression to its right, so group it to
+compare its result: (increment 5) = 6.`,
+			Task:     "Bind avg to a block giving the mean of its left and right operands.",
+			Check:    "((2 avg 4) = 3) && ((1 avg 2) = 3/2)",
+			Hint:     "Group the sum before dividing it by 2.",
+			Solution: "avg : { (left + right) / 2 }",
+		},
+		{
+			Title: "Choosing",
+			Text: `There is no if: ? looks up its left operand as a key of the
+table on its right, so a boolean picks between the values under true and
+false.
+
+  (1 > 0) ? [true: "yes" false: "no"]   # "yes"`,
+			Task:     `Bind sign to a block giving "neg" for a negative right operand and "pos" otherwise.`,
+			Check:    `((sign (-3)) = "neg") && ((sign 3) = "pos") && ((sign 0) = "pos")`,
+			Hint:     "Compare right with 0 and look the result up in [true: ... false: ...].",
+			Solution: `sign : { (right < 0) ? [true: "neg" false: "pos"] }`,
+		},
+		{
+			Title: "Errors",
+			Text: `Errors are values: dividing by zero gives an Error, which
+flows through the operators that use it. ?? gives its right operand
+instead of an Error on its left.
+
+  (1 / 0) ?? "none"        # "none"`,
+			Task:     "Bind safe to a block dividing 100 by its right operand, or giving 0 if that fails.",
+			Check:    "((safe 4) = 25) && ((safe 0) = 0)",
+			Hint:     "(100 / right) ?? ...",
+			Solution: "safe : { (100 / right) ?? 0 }",
+		},
+		{
+			Title: "Flows",
+			Text: `-> sends a value to an operator: a table is sent element by
+element, and the results make a new table. Binding takes only what is
+next to it, so group a flow to bind its result.
+
+  ([1 2 3] -> increment)   # [2 3 4]`,
+			Task:     "Bind doubled to the table [1 2 3] sent through a block doubling each element.",
+			Check:    "(doubled.0 = 2) && (doubled.1 = 4) && (doubled.2 = 6)",
+			Hint:     "doubled : ([1 2 3] -> { ... })",
+			Solution: "doubled : ([1 2 3] -> { right * 2 })",
+		},
+	}
+}
+
+// Session evaluates the answers of one run of the tutorial: like the
+// REPL, it keeps the operators and bindings of earlier answers.
+type Session struct {
+	bindings *parser.BindingTable
+	interp   *eval.Interp
+}
+
+// NewSession returns a session whose programs write to out.
+func NewSession(out io.Writer) *Session {
+	in := eval.New()
+	in.Stdout = out
+	bindings := parser.NewBindingTable()
+	bindings.RegisterValue("it")
+	return &Session{bindings: bindings, interp: in}
+}
+
+// Eval evaluates an answer and returns the value of its last expression,
+// which it binds to it; bindings give none. A parse error is returned as
+// err, an error of the program as an *eval.Error value.
+func (s *Session) Eval(src string) (eval.Value, error) {
+	p := parser.New(lexer.New([]byte(src)), parser.WithBindings(s.bindings))
+	prog := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, errors.New(errs[0])
+	}
+	var last eval.Value
+	for _, stmt := range prog.Statements {
+		v := s.interp.Force(s.interp.Eval(stmt))
+		switch stmt.(type) {
+		case *ast.BindingExpr, *ast.ResourceDef:
+			if _, isErr := v.(*eval.Error); !isErr {
+				continue
+			}
+		}
+		last = v
+	}
+	if last != nil {
+		s.interp.Global.Set("it", last)
+	}
+	return last, nil
+}
+
+// Passed evaluates the check of l, and reports whether it is true.
+func (s *Session) Passed(l Lesson) bool {
+	p := parser.New(lexer.New([]byte(l.Check)), parser.WithBindings(s.bindings))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return false
+	}
+	ok, _ := s.interp.Eval(prog).(eval.Boolean)
+	return bool(ok)
+}
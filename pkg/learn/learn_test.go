@@ -0,0 +1,74 @@
+package learn
+
+import (
+	"io"
+	"testing"
+
+	"orglang/pkg/eval"
+)
+
+// TestSolutions takes the tutorial in order with the solutions, each of
+// which must pass its lesson and none the lesson after it.
+func TestSolutions(t *testing.T) {
+	lessons := Lessons()
+	s := NewSession(io.Discard)
+	for i, l := range lessons {
+		if i > 0 && s.Passed(l) {
+			t.Errorf("lesson %d (%s) passes before it is answered", i+1, l.Title)
+		}
+		v, err := s.Eval(l.Solution)
+		if err != nil {
+			t.Fatalf("lesson %d (%s): %s: %v", i+1, l.Title, l.Solution, err)
+		}
+		if e, ok := v.(*eval.Error); ok {
+			t.Fatalf("lesson %d (%s): %s: %s", i+1, l.Title, l.Solution, e)
+		}
+		if !s.Passed(l) {
+			t.Errorf("lesson %d (%s): solution %q does not pass %q", i+1, l.Title, l.Solution, l.Check)
+		}
+		if l.Text == "" || l.Task == "" || l.Hint == "" {
+			t.Errorf("lesson %d (%s) lacks a text, task or hint", i+1, l.Title)
+		}
+	}
+}
+
+func TestWrongAnswers(t *testing.T) {
+	lessons := Lessons()
+	tests := []struct {
+		lesson int
+		answer string
+	}{
+		{0, "6 + 7"},
+		{1, "answer : 41"},
+		{2, "primes : [2 3 5]"},
+		{4, "square : { right * 2 }"},
+		{5, "avg : { left + right / 2 }"},
+		{6, `sign : { (right <= 0) ? [true: "neg" false: "pos"] }`},
+		{7, "safe : { 100 / right }"},
+	}
+	for _, tt := range tests {
+		s := NewSession(io.Discard)
+		s.Eval(tt.answer)
+		if l := lessons[tt.lesson]; s.Passed(l) {
+			t.Errorf("lesson %d (%s): wrong answer %q passes", tt.lesson+1, l.Title, tt.answer)
+		}
+	}
+}
+
+func TestEvalBindsIt(t *testing.T) {
+	s := NewSession(io.Discard)
+	if v, err := s.Eval("x : 2; x * 3"); err != nil || v.String() != "6" {
+		t.Fatalf("Eval = %v, %v; want 6", v, err)
+	}
+	if v, _ := s.Eval("it + 1"); v.String() != "7" {
+		t.Errorf("it + 1 = %v, want 7", v)
+	}
+	if v, _ := s.Eval("y : 1"); v != nil {
+		t.Errorf("a binding gives %v, want nil", v)
+	}
+	if v, err := s.Eval("x : )"); err != nil {
+		t.Errorf("x : ) gives the error %v, want an Error value", err)
+	} else if _, ok := v.(*eval.Error); !ok {
+		t.Errorf("x : ) = %v, want an Error", v)
+	}
+}
@@ -7,11 +7,16 @@
 package lexer
 
 import (
+	"bytes"
 	"fmt"
+	"slices"
 	"strings"
 	"unicode"
 	"unicode/utf8"
 
+	"golang.org/x/text/unicode/norm"
+
+	"orglang/pkg/diag"
 	"orglang/pkg/token"
 )
 
@@ -22,16 +27,118 @@ type Lexer struct {
 	line          int             // current line (1-indexed)
 	col           int             // current column (1-indexed)
 	prevTokenType token.TokenType // type of the last emitted token (for sign gluing)
+
+	encodingErr string // set when the input is not UTF-8; reported as the only token
+
+	interp []int // brace depth inside each open ${ }, innermost last
+
+	normalize   bool      // NFC-normalize identifiers
+	mixedScript bool      // warn on identifiers mixing confusable scripts
+	comments    bool      // return comments as tokens instead of skipping them
+	warnings    []Warning // non-fatal findings, in source order
+
+	diags []diag.Diagnostic // the ILLEGAL tokens returned so far
+}
+
+// Warning is a non-fatal finding reported while scanning.
+type Warning struct {
+	Line    int // 1-indexed
+	Column  int // 1-indexed
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("line %d:%d: %s", w.Line, w.Column, w.Message)
+}
+
+// Option configures a Lexer.
+type Option func(*Lexer)
+
+// WithNormalization enables or disables NFC normalization of identifiers.
+// It is enabled by default, so `café` typed with a precomposed é and with
+// e + U+0301 is the same identifier.
+func WithNormalization(on bool) Option {
+	return func(l *Lexer) { l.normalize = on }
+}
+
+// WithMixedScriptWarnings reports identifiers whose letters mix the
+// Latin, Greek and Cyrillic scripts (e.g. a Cyrillic а inside a Latin
+// name), the usual source of look-alike names.
+func WithMixedScriptWarnings(on bool) Option {
+	return func(l *Lexer) { l.mixedScript = on }
+}
+
+// WithComments returns comments as COMMENT and BLOCK_COMMENT tokens, in
+// source order among the others, for tools that must keep them such as
+// formatters. By default comments are skipped like whitespace. Comment
+// tokens do not change how the tokens after them lex: a sign after a
+// comment glues as it would without it.
+func WithComments(on bool) Option {
+	return func(l *Lexer) { l.comments = on }
 }
 
 // New creates a new Lexer for the given input bytes.
-func New(input []byte) *Lexer {
-	return &Lexer{
-		input: input,
-		pos:   0,
-		line:  1,
-		col:   1,
+func New(input []byte, opts ...Option) *Lexer {
+	l := &Lexer{
+		input:     input,
+		pos:       0,
+		line:      1,
+		col:       1,
+		normalize: true,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	switch enc := detectUTF16(input); {
+	case enc != "":
+		l.encodingErr = fmt.Sprintf("source is %s encoded; re-save it as UTF-8", enc)
+	case bytes.HasPrefix(input, utf8BOM):
+		l.pos = len(utf8BOM)
+	}
+	return l
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// detectUTF16 recognizes UTF-16 input by its byte order mark or, without
+// one, by a NUL byte next to an ASCII one at the start of the file (NUL
+// never appears in OrgLang source). It returns the encoding name or "".
+func detectUTF16(input []byte) string {
+	if len(input) < 2 {
+		return ""
+	}
+	switch {
+	case input[0] == 0xFF && input[1] == 0xFE, input[0] != 0 && input[0] < utf8.RuneSelf && input[1] == 0:
+		return "UTF-16LE"
+	case input[0] == 0xFE && input[1] == 0xFF, input[0] == 0 && input[1] != 0 && input[1] < utf8.RuneSelf:
+		return "UTF-16BE"
 	}
+	return ""
+}
+
+// Warnings returns the warnings reported so far.
+func (l *Lexer) Warnings() []Warning {
+	return l.warnings
+}
+
+func (l *Lexer) warn(line, col int, format string, args ...any) {
+	l.warnings = append(l.warnings, Warning{Line: line, Column: col, Message: fmt.Sprintf(format, args...)})
+}
+
+// Diagnostics returns the lexical errors, one for each ILLEGAL token
+// returned so far.
+func (l *Lexer) Diagnostics() []diag.Diagnostic {
+	return l.diags
+}
+
+// Clone returns a lexer continuing from where l is, independently of it:
+// a parser scans the clone to look further ahead than its next token.
+func (l *Lexer) Clone() *Lexer {
+	c := *l
+	c.interp = slices.Clone(l.interp)
+	c.warnings = slices.Clip(l.warnings)
+	c.diags = slices.Clip(l.diags)
+	return &c
 }
 
 // Tokenize returns all tokens from the input, including the final EOF.
@@ -49,19 +156,43 @@ func (l *Lexer) Tokenize() []token.Token {
 
 // NextToken scans and returns the next token from the input.
 func (l *Lexer) NextToken() token.Token {
+	tok := l.next()
+	if tok.Type == token.ILLEGAL {
+		l.diags = append(l.diags, diag.Diagnostic{Code: diag.LexError, Severity: diag.Error, Span: diag.At(tok), Message: tok.Literal})
+	}
+	return tok
+}
+
+func (l *Lexer) next() token.Token {
+	if l.encodingErr != "" {
+		msg := l.encodingErr
+		l.encodingErr = ""
+		l.pos = len(l.input)
+		return token.Token{Type: token.ILLEGAL, Literal: msg, Line: 1, Column: 1, End: l.pos, EndLine: 1, EndColumn: 1}
+	}
+
 	l.skipWhitespaceAndComments()
 
 	if l.pos >= len(l.input) {
+		if len(l.interp) > 0 {
+			l.interp = nil
+			return l.makeToken(token.ILLEGAL, "unterminated string interpolation")
+		}
 		return l.makeToken(token.EOF, "")
 	}
 
 	r, _ := l.peekRune()
 	startLine := l.line
 	startCol := l.col
+	startPos := l.pos
 
 	var tok token.Token
 
 	switch {
+	// Comments, reached only when they are kept
+	case r == '#':
+		tok = l.readComment(startLine, startCol)
+
 	// Structural delimiters
 	case r == '(':
 		l.readRune()
@@ -77,9 +208,20 @@ func (l *Lexer) NextToken() token.Token {
 		tok = token.Token{Type: token.RBRACKET, Literal: "]", Line: startLine, Column: startCol}
 	case r == '{':
 		l.readRune()
+		if n := len(l.interp); n > 0 {
+			l.interp[n-1]++
+		}
 		tok = token.Token{Type: token.LBRACE, Literal: "{", Line: startLine, Column: startCol}
+	case r == '}' && len(l.interp) > 0 && l.interp[len(l.interp)-1] == 0:
+		// The } closing a ${ resumes the string.
+		l.readRune()
+		l.interp = l.interp[:len(l.interp)-1]
+		tok = l.readStringText(startLine, startCol, true)
 	case r == '}':
 		l.readRune()
+		if n := len(l.interp); n > 0 {
+			l.interp[n-1]--
+		}
 		tok = token.Token{Type: token.RBRACE, Literal: "}", Line: startLine, Column: startCol}
 	case r == ';':
 		l.readRune()
@@ -129,7 +271,11 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	}
 
-	l.prevTokenType = tok.Type
+	tok.Offset, tok.End = startPos, l.pos
+	tok.EndLine, tok.EndColumn = l.line, l.col
+	if tok.Type != token.COMMENT && tok.Type != token.BLOCK_COMMENT {
+		l.prevTokenType = tok.Type
+	}
 	return tok
 }
 
@@ -141,15 +287,25 @@ func (l *Lexer) readRune() (rune, int) {
 	}
 	r, size := utf8.DecodeRune(l.input[l.pos:])
 	l.pos += size
-	if r == '\n' {
+	switch {
+	case r == '\n':
 		l.line++
 		l.col = 1
-	} else {
+	case l.atCRLF(r):
+		// The \r of a \r\n line ending takes no column.
+	default:
 		l.col++
 	}
 	return r, size
 }
 
+// atCRLF reports whether r, just read, is the \r of a \r\n line ending.
+// String literals drop it, so their value does not depend on the line
+// endings the file was saved with.
+func (l *Lexer) atCRLF(r rune) bool {
+	return r == '\r' && l.pos < len(l.input) && l.input[l.pos] == '\n'
+}
+
 func (l *Lexer) peekRune() (rune, int) {
 	if l.pos >= len(l.input) {
 		return 0, 0
@@ -174,7 +330,7 @@ func (l *Lexer) skipWhitespaceAndComments() {
 			l.readRune()
 			continue
 		}
-		if r == '#' {
+		if r == '#' && !l.comments {
 			if l.isBlockComment() {
 				l.skipBlockComment()
 			} else {
@@ -226,6 +382,23 @@ func (l *Lexer) skipBlockComment() {
 	}
 }
 
+// readComment reads the comment at l.pos. A line comment ends before the
+// line break, which stays whitespace; a block comment ends with its
+// closing ###, or at the end of the input when it has none.
+func (l *Lexer) readComment(startLine, startCol int) token.Token {
+	start := l.pos
+	tt := token.COMMENT
+	if l.isBlockComment() {
+		tt = token.BLOCK_COMMENT
+		l.skipBlockComment()
+	} else {
+		for l.pos < len(l.input) && l.input[l.pos] != '\n' && !bytes.HasPrefix(l.input[l.pos:], []byte("\r\n")) {
+			l.readRune()
+		}
+	}
+	return token.Token{Type: tt, Literal: string(l.input[start:l.pos]), Line: startLine, Column: startCol}
+}
+
 // --- Number scanning ---
 
 func (l *Lexer) peekDigitAfterSign() bool {
@@ -241,6 +414,7 @@ func (l *Lexer) shouldGlueSign() bool {
 	case "": // start of file
 		return true
 	case token.LPAREN, token.LBRACKET, token.LBRACE,
+		token.INTERP_START, token.INTERP_MID,
 		token.SEMICOLON, token.COMMA,
 		token.AT, token.AT_COLON, token.COLON, token.DOT,
 		token.ELVIS:
@@ -262,9 +436,12 @@ func (l *Lexer) readNumber(sign rune, startLine, startCol int) token.Token {
 	if sign != 0 {
 		buf.WriteRune(sign)
 	}
+	badSeparator := token.Token{Type: token.ILLEGAL, Literal: "digit separator _ must be between digits", Line: startLine, Column: startCol}
 
 	// Read integer digits
-	l.readDigits(&buf)
+	if !l.readDigits(&buf) {
+		return badSeparator
+	}
 
 	// Check for decimal point: digit.digit
 	if l.pos < len(l.input) {
@@ -275,8 +452,10 @@ func (l *Lexer) readNumber(sign rune, startLine, startCol int) token.Token {
 			if isASCIIDigit(r2) {
 				l.readRune() // consume '.'
 				buf.WriteRune('.')
-				l.readDigits(&buf)
-				return token.Token{Type: token.DECIMAL, Literal: buf.String(), Line: startLine, Column: startCol}
+				if !l.readDigits(&buf) {
+					return badSeparator
+				}
+				return l.withUnit(token.Token{Type: token.DECIMAL, Literal: buf.String(), Line: startLine, Column: startCol})
 			}
 			// Otherwise: 1. -> INTEGER + DOT (dot stays for next token)
 		}
@@ -296,24 +475,67 @@ func (l *Lexer) readNumber(sign rune, startLine, startCol int) token.Token {
 					s, _ := l.readRune()
 					buf.WriteRune(s)
 				}
-				l.readDigits(&buf)
+				if !l.readDigits(&buf) {
+					return badSeparator
+				}
 				return token.Token{Type: token.RATIONAL, Literal: buf.String(), Line: startLine, Column: startCol}
 			}
 		}
 	}
 
-	return token.Token{Type: token.INTEGER, Literal: buf.String(), Line: startLine, Column: startCol}
+	return l.withUnit(token.Token{Type: token.INTEGER, Literal: buf.String(), Line: startLine, Column: startCol})
+}
+
+// withUnit extends an integer or decimal literal with the unit suffix
+// right after it (250ms, 1.5GiB), making it a DURATION or SIZE. The
+// suffix must end the identifier: 5sec stays 5 followed by sec.
+func (l *Lexer) withUnit(tok token.Token) token.Token {
+	end := l.pos
+	for end < len(l.input) && (l.input[end] >= 'a' && l.input[end] <= 'z' || l.input[end] >= 'A' && l.input[end] <= 'Z') {
+		end++
+	}
+	unit := string(l.input[l.pos:end])
+	typ, ok := token.Units[unit]
+	if !ok {
+		return tok
+	}
+	if r, _ := utf8.DecodeRune(l.input[end:]); end < len(l.input) && (isASCIIDigit(r) || l.isIdentContinue(r)) {
+		return tok
+	}
+	for l.pos < end {
+		l.readRune()
+	}
+	tok.Type = typ
+	tok.Literal += unit
+	return tok
 }
 
-func (l *Lexer) readDigits(buf *strings.Builder) {
+// readDigits reads a run of digits into buf, dropping the digit
+// separators: an _ between two digits, as in 1_000_000. It returns false
+// after an _ not followed by a digit, which it consumes.
+func (l *Lexer) readDigits(buf *strings.Builder) bool {
+	digits := false
 	for l.pos < len(l.input) {
 		r, _ := l.peekRune()
+		if r == '_' && digits {
+			l.readRune()
+			if next, _ := l.peekRune(); !isASCIIDigit(next) {
+				for next == '_' {
+					l.readRune()
+					next, _ = l.peekRune()
+				}
+				return false
+			}
+			continue
+		}
 		if !isASCIIDigit(r) {
 			break
 		}
 		l.readRune()
 		buf.WriteRune(r)
+		digits = true
 	}
+	return true
 }
 
 // --- String scanning ---
@@ -325,12 +547,30 @@ func (l *Lexer) readString(startLine, startCol int) token.Token {
 	if l.matchString("\"\"") {
 		return l.readDocstring(startLine, startCol)
 	}
+	return l.readStringText(startLine, startCol, false)
+}
 
+// readStringText reads the text of a string up to its closing quote or
+// the next ${. resumed is true after the } of an interpolation.
+func (l *Lexer) readStringText(startLine, startCol int, resumed bool) token.Token {
 	var buf strings.Builder
 	for l.pos < len(l.input) {
 		r, _ := l.readRune()
 		if r == '"' {
-			return token.Token{Type: token.STRING, Literal: buf.String(), Line: startLine, Column: startCol}
+			typ := token.STRING
+			if resumed {
+				typ = token.INTERP_END
+			}
+			return token.Token{Type: typ, Literal: buf.String(), Line: startLine, Column: startCol}
+		}
+		if r == '$' && l.pos < len(l.input) && l.input[l.pos] == '{' {
+			l.readRune()
+			l.interp = append(l.interp, 0)
+			typ := token.INTERP_START
+			if resumed {
+				typ = token.INTERP_MID
+			}
+			return token.Token{Type: typ, Literal: buf.String(), Line: startLine, Column: startCol}
 		}
 		if r == '\\' {
 			escaped, err := l.readEscape()
@@ -340,6 +580,9 @@ func (l *Lexer) readString(startLine, startCol int) token.Token {
 			buf.WriteRune(escaped)
 			continue
 		}
+		if l.atCRLF(r) {
+			continue
+		}
 		buf.WriteRune(r)
 	}
 
@@ -364,6 +607,9 @@ func (l *Lexer) readDocstring(startLine, startCol int) token.Token {
 			buf.WriteRune(escaped)
 			continue
 		}
+		if l.atCRLF(r) {
+			continue
+		}
 		buf.WriteRune(r)
 	}
 	return token.Token{Type: token.ILLEGAL, Literal: "unterminated docstring", Line: startLine, Column: startCol}
@@ -383,6 +629,9 @@ func (l *Lexer) readRawString(startLine, startCol int) token.Token {
 		if r == '\'' {
 			return token.Token{Type: token.RAWSTRING, Literal: buf.String(), Line: startLine, Column: startCol}
 		}
+		if l.atCRLF(r) {
+			continue
+		}
 		buf.WriteRune(r)
 	}
 	return token.Token{Type: token.ILLEGAL, Literal: "unterminated raw string", Line: startLine, Column: startCol}
@@ -397,6 +646,9 @@ func (l *Lexer) readRawDocstring(startLine, startCol int) token.Token {
 			content := stripDocIndent(buf.String())
 			return token.Token{Type: token.RAWDOC, Literal: content, Line: startLine, Column: startCol}
 		}
+		if l.atCRLF(r) {
+			continue
+		}
 		buf.WriteRune(r)
 	}
 	return token.Token{Type: token.ILLEGAL, Literal: "unterminated raw docstring", Line: startLine, Column: startCol}
@@ -435,6 +687,8 @@ func (l *Lexer) readEscape() (rune, string) {
 		return '\\', ""
 	case '"':
 		return '"', ""
+	case '$':
+		return '$', ""
 	case '0':
 		return 0, ""
 	case 'u':
@@ -499,19 +753,27 @@ func (l *Lexer) readUnicodeEscape() (rune, string) {
 // --- Identifier scanning ---
 
 func (l *Lexer) readIdentifier(startLine, startCol int) token.Token {
-	var buf strings.Builder
-
+	// Identifiers are contiguous in the input: slice them out rather
+	// than copying rune by rune.
+	start := l.pos
 	for l.pos < len(l.input) {
 		r, _ := l.peekRune()
 		if isASCIIDigit(r) || l.isIdentContinue(r) {
 			l.readRune()
-			buf.WriteRune(r)
 		} else {
 			break
 		}
 	}
 
-	lit := buf.String()
+	lit := string(l.input[start:l.pos])
+	if l.normalize {
+		lit = norm.NFC.String(lit)
+	}
+	if l.mixedScript {
+		if scripts := ConfusableScripts(lit); len(scripts) > 1 {
+			l.warn(startLine, startCol, "identifier %q mixes %s scripts", lit, strings.Join(scripts, " and "))
+		}
+	}
 
 	// Check for ?:  (ELVIS)
 	if lit == "?" {
@@ -590,16 +852,17 @@ func (l *Lexer) readColon(startLine, startCol int) token.Token {
 // --- Docstring indent stripping ---
 
 // stripDocIndent removes the common leading whitespace from a docstring.
-// It strips the leading newline and trailing newline if present, then
-// finds the minimum indentation across non-empty lines and removes it.
+// It strips the leading newline and trailing newline if present (with the
+// indentation of closing quotes on a line of their own), then finds the
+// minimum indentation across non-empty lines and removes it.
 func stripDocIndent(s string) string {
 	// Strip leading newline
 	if len(s) > 0 && s[0] == '\n' {
 		s = s[1:]
 	}
 	// Strip trailing newline
-	if len(s) > 0 && s[len(s)-1] == '\n' {
-		s = s[:len(s)-1]
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 && strings.TrimLeft(s[i+1:], " \t") == "" {
+		s = s[:i]
 	}
 
 	lines := strings.Split(s, "\n")
@@ -662,8 +925,36 @@ func (l *Lexer) isIdentStart(r rune) bool {
 	return unicode.IsLetter(r) || unicode.IsSymbol(r) || unicode.IsNumber(r)
 }
 
+// isIdentContinue also accepts combining marks (\p{M}), so decomposed
+// text such as e + U+0301 stays in one identifier and can be normalized.
 func (l *Lexer) isIdentContinue(r rune) bool {
-	return l.isIdentStart(r)
+	return l.isIdentStart(r) || unicode.IsMark(r)
+}
+
+// confusableScriptTables are the scripts whose letters are commonly
+// mistaken for one another.
+var confusableScriptTables = []struct {
+	name  string
+	table *unicode.RangeTable
+}{
+	{"Latin", unicode.Latin},
+	{"Greek", unicode.Greek},
+	{"Cyrillic", unicode.Cyrillic},
+}
+
+// ConfusableScripts returns the confusable scripts (Latin, Greek, Cyrillic)
+// used by the letters of s, in that order.
+func ConfusableScripts(s string) []string {
+	var found []string
+	for _, st := range confusableScriptTables {
+		for _, r := range s {
+			if unicode.IsLetter(r) && unicode.Is(st.table, r) {
+				found = append(found, st.name)
+				break
+			}
+		}
+	}
+	return found
 }
 
 func isStructural(r rune) bool {
@@ -702,5 +993,6 @@ func hexVal(r rune) int {
 // --- Token construction helper ---
 
 func (l *Lexer) makeToken(tt token.TokenType, lit string) token.Token {
-	return token.Token{Type: tt, Literal: lit, Line: l.line, Column: l.col}
+	return token.Token{Type: tt, Literal: lit, Line: l.line, Column: l.col, Offset: l.pos, End: l.pos,
+		EndLine: l.line, EndColumn: l.col}
 }
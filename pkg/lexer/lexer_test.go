@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"orglang/pkg/diag"
 	"orglang/pkg/token"
 )
 
@@ -172,6 +173,8 @@ func TestIntegers(t *testing.T) {
 		{"42", "42"},
 		{"0", "0"},
 		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"1_000_000", "1000000"},
+		{"-1_0", "-10"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
@@ -257,6 +260,8 @@ func TestDecimals(t *testing.T) {
 		{"3.14", "3.14"},
 		{"0.001", "0.001"},
 		{"1.0", "1.0"},
+		{"3.141_592", "3.141592"},
+		{"1_000.000_1", "1000.0001"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
@@ -267,6 +272,55 @@ func TestDecimals(t *testing.T) {
 	}
 }
 
+func TestDigitSeparators(t *testing.T) {
+	tokens := lexAll("1_000/3_000 + 1/-1_0")
+	assertTokenCount(t, tokens, 4)
+	assertToken(t, tokens, 0, token.RATIONAL, "1000/3000")
+	assertToken(t, tokens, 2, token.RATIONAL, "1/-10")
+
+	// An _ at the end of digits, doubled or next to the point is an error.
+	for _, input := range []string{"1_", "1__000", "1_.5", "1.5_", "1/2_", "1_a"} {
+		t.Run(input, func(t *testing.T) {
+			tokens := lexAll(input)
+			assertToken(t, tokens, 0, token.ILLEGAL, "digit separator _ must be between digits")
+		})
+	}
+
+	// A leading _ starts an identifier, as before.
+	tokens = lexAll("x _1")
+	assertTokenCount(t, tokens, 3)
+	assertToken(t, tokens, 1, token.IDENTIFIER, "_1")
+}
+
+func TestQuantities(t *testing.T) {
+	tokens := lexAll("-2m 5s 250ms 1.5h 10KB 3MiB 1_024B")
+	assertTokenCount(t, tokens, 8)
+	assertToken(t, tokens, 0, token.DURATION, "-2m")
+	assertToken(t, tokens, 1, token.DURATION, "5s")
+	assertToken(t, tokens, 2, token.DURATION, "250ms")
+	assertToken(t, tokens, 3, token.DURATION, "1.5h")
+	assertToken(t, tokens, 4, token.SIZE, "10KB")
+	assertToken(t, tokens, 5, token.SIZE, "3MiB")
+	assertToken(t, tokens, 6, token.SIZE, "1024B")
+
+	// A suffix must end the identifier; otherwise the number stands
+	// alone, as before. Rationals take no unit.
+	tokens = lexAll("5sec 2s+ 1/2s 3kb")
+	assertTokenCount(t, tokens, 9)
+	assertToken(t, tokens, 0, token.INTEGER, "5")
+	assertToken(t, tokens, 1, token.IDENTIFIER, "sec")
+	assertToken(t, tokens, 2, token.INTEGER, "2")
+	assertToken(t, tokens, 3, token.IDENTIFIER, "s+")
+	assertToken(t, tokens, 4, token.RATIONAL, "1/2")
+	assertToken(t, tokens, 5, token.IDENTIFIER, "s")
+	assertToken(t, tokens, 6, token.INTEGER, "3")
+	assertToken(t, tokens, 7, token.IDENTIFIER, "kb")
+
+	tokens = lexAll("(5s)")
+	assertTokenCount(t, tokens, 4)
+	assertToken(t, tokens, 1, token.DURATION, "5s")
+}
+
 func TestDecimalSignGlued(t *testing.T) {
 	tokens := lexAll("-3.14")
 	assertTokenCount(t, tokens, 2)
@@ -350,6 +404,8 @@ func TestStringEscapes(t *testing.T) {
 		{"unicode bmp", `"\u0041"`, "A"},
 		{"unicode braced", `"\u{1F600}"`, "\U0001F600"},
 		{"unicode braced short", `"\u{41}"`, "A"},
+		{"dollar", `"\${x}"`, "${x}"},
+		{"lone dollar", `"a$b"`, "a$b"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -379,6 +435,41 @@ func TestStringUnterminatedEscape(t *testing.T) {
 	assertToken(t, tokens, 0, token.ILLEGAL, "unterminated escape sequence")
 }
 
+func TestStringInterpolation(t *testing.T) {
+	tokens := lexAll(`"a${x}b${ {y} }c"`)
+	assertTokenCount(t, tokens, 8)
+	assertToken(t, tokens, 0, token.INTERP_START, "a")
+	assertToken(t, tokens, 1, token.IDENTIFIER, "x")
+	assertToken(t, tokens, 2, token.INTERP_MID, "b")
+	assertToken(t, tokens, 3, token.LBRACE, "{")
+	assertToken(t, tokens, 4, token.IDENTIFIER, "y")
+	assertToken(t, tokens, 5, token.RBRACE, "}")
+	assertToken(t, tokens, 6, token.INTERP_END, "c")
+	assertToken(t, tokens, 7, token.EOF, "")
+}
+
+func TestStringInterpolationNested(t *testing.T) {
+	tokens := lexAll(`"<${ "(${x})" }>"`)
+	assertTokenCount(t, tokens, 6)
+	assertToken(t, tokens, 0, token.INTERP_START, "<")
+	assertToken(t, tokens, 1, token.INTERP_START, "(")
+	assertToken(t, tokens, 2, token.IDENTIFIER, "x")
+	assertToken(t, tokens, 3, token.INTERP_END, ")")
+	assertToken(t, tokens, 4, token.INTERP_END, ">")
+}
+
+func TestStringInterpolationSignGlued(t *testing.T) {
+	tokens := lexAll(`"${-1}"`)
+	assertTokenCount(t, tokens, 4)
+	assertToken(t, tokens, 1, token.INTEGER, "-1")
+}
+
+func TestStringInterpolationUnterminated(t *testing.T) {
+	tokens := lexAll(`"a${x`)
+	assertTokenCount(t, tokens, 4)
+	assertToken(t, tokens, 2, token.ILLEGAL, "unterminated string interpolation")
+}
+
 func TestUnicodeEscapeEmpty(t *testing.T) {
 	tokens := lexAll(`"\u{}"`)
 	// ILLEGAL(empty), ILLEGAL(unterminated string), EOF => 3 tokens
@@ -443,6 +534,24 @@ func TestDocstring(t *testing.T) {
 	assertToken(t, tokens, 0, token.DOCSTRING, "hello\nworld")
 }
 
+func TestTokenEnd(t *testing.T) {
+	tokens := lexAll("ab \"\"\"\n  doc\n\"\"\" é")
+	assertTokenCount(t, tokens, 4)
+	ends := [][2]int{{1, 3}, {3, 4}, {3, 6}}
+	for i, want := range ends {
+		if got := [2]int{tokens[i].EndLine, tokens[i].EndColumn}; got != want {
+			t.Errorf("token[%d] %q ends at %v, want %v", i, tokens[i].Literal, got, want)
+		}
+	}
+}
+
+func TestDocstringIndentedClosing(t *testing.T) {
+	input := "\"\"\"" + "\n    hello\n      world\n    " + "\"\"\""
+	tokens := lexAll(input)
+	assertTokenCount(t, tokens, 2)
+	assertToken(t, tokens, 0, token.DOCSTRING, "hello\n  world")
+}
+
 func TestDocstringUnterminated(t *testing.T) {
 	tokens := lexAll("\"\"\"hello")
 	assertTokenCount(t, tokens, 2)
@@ -590,6 +699,52 @@ func TestUnicodeSymbols(t *testing.T) {
 	}
 }
 
+func TestIdentifierNFCNormalization(t *testing.T) {
+	// e + U+0301 (combining acute) and the precomposed é are the same identifier
+	tokens := lexAll("cafe\u0301 café")
+	assertTokenCount(t, tokens, 3)
+	assertToken(t, tokens, 0, token.IDENTIFIER, "café")
+	assertToken(t, tokens, 1, token.IDENTIFIER, "café")
+}
+
+func TestIdentifierNormalizationDisabled(t *testing.T) {
+	tokens := New([]byte("cafe\u0301"), WithNormalization(false)).Tokenize()
+	assertTokenCount(t, tokens, 2)
+	assertToken(t, tokens, 0, token.IDENTIFIER, "cafe\u0301")
+}
+
+func TestStringsAreNotNormalized(t *testing.T) {
+	tokens := lexAll(`"cafe\u0301"`)
+	assertToken(t, tokens, 0, token.STRING, "cafe\u0301")
+}
+
+func TestMixedScriptWarnings(t *testing.T) {
+	// "pаy" with a Cyrillic а (U+0430)
+	l := New([]byte("ok : 1; p\u0430y : 2; πr : 3"), WithMixedScriptWarnings(true))
+	l.Tokenize()
+	warnings := l.Warnings()
+	if len(warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %d: %v", len(warnings), warnings)
+	}
+	if warnings[0].Line != 1 || warnings[0].Column != 9 {
+		t.Errorf("warning position: got %d:%d", warnings[0].Line, warnings[0].Column)
+	}
+	if warnings[0].Message != "identifier \"p\u0430y\" mixes Latin and Cyrillic scripts" {
+		t.Errorf("unexpected message: %s", warnings[0].Message)
+	}
+	if warnings[1].Message != `identifier "πr" mixes Latin and Greek scripts` {
+		t.Errorf("unexpected message: %s", warnings[1].Message)
+	}
+}
+
+func TestMixedScriptWarningsOffByDefault(t *testing.T) {
+	l := New([]byte("p\u0430y"))
+	l.Tokenize()
+	if len(l.Warnings()) != 0 {
+		t.Errorf("expected no warnings, got %v", l.Warnings())
+	}
+}
+
 // --- Comments ---
 
 func TestLineComment(t *testing.T) {
@@ -658,6 +813,77 @@ func TestCarriageReturn(t *testing.T) {
 	assertToken(t, tokens, 1, token.IDENTIFIER, "y")
 }
 
+// --- Encoding ---
+
+func TestUTF8BOM(t *testing.T) {
+	tokens := lexAll("\xEF\xBB\xBFx : 1;")
+	assertTokenCount(t, tokens, 5)
+	assertToken(t, tokens, 0, token.IDENTIFIER, "x")
+	if tokens[0].Line != 1 || tokens[0].Column != 1 {
+		t.Errorf("expected x at 1:1, got %d:%d", tokens[0].Line, tokens[0].Column)
+	}
+}
+
+func TestUTF8BOMBeforeBlockComment(t *testing.T) {
+	tokens := lexAll("\xEF\xBB\xBF###\nheader\n###\nx")
+	assertTokenCount(t, tokens, 2)
+	assertToken(t, tokens, 0, token.IDENTIFIER, "x")
+}
+
+func TestUTF16(t *testing.T) {
+	tests := []struct {
+		input    string
+		encoding string
+	}{
+		{"\xFF\xFEx\x00", "UTF-16LE"},
+		{"\xFE\xFF\x00x", "UTF-16BE"},
+		{"x\x00 \x00:\x00", "UTF-16LE"},
+		{"\x00x\x00 \x00:", "UTF-16BE"},
+	}
+	for _, tt := range tests {
+		tokens := lexAll(tt.input)
+		assertTokenCount(t, tokens, 2)
+		assertToken(t, tokens, 0, token.ILLEGAL, "source is "+tt.encoding+" encoded; re-save it as UTF-8")
+		assertToken(t, tokens, 1, token.EOF, "")
+	}
+}
+
+func TestCRLFPositions(t *testing.T) {
+	tokens := lexAll("a\r\nbb c\r\n  d")
+	expected := [][2]int{{1, 1}, {2, 1}, {2, 4}, {3, 3}}
+	for i, pos := range expected {
+		if tokens[i].Line != pos[0] || tokens[i].Column != pos[1] {
+			t.Errorf("token %d (%s): expected %d:%d, got %d:%d", i, tokens[i].Literal, pos[0], pos[1], tokens[i].Line, tokens[i].Column)
+		}
+	}
+}
+
+func TestCRLFInStrings(t *testing.T) {
+	tokens := lexAll("\"a\r\nb\" 'c\r\nd' \"\"\"\r\n    e\r\n    f\r\n\"\"\" \"g\\r\\nh\"")
+	assertToken(t, tokens, 0, token.STRING, "a\nb")
+	assertToken(t, tokens, 1, token.RAWSTRING, "c\nd")
+	assertToken(t, tokens, 2, token.DOCSTRING, "e\nf")
+	// Escapes still produce a \r
+	assertToken(t, tokens, 3, token.STRING, "g\r\nh")
+}
+
+func TestCRLFBlockComment(t *testing.T) {
+	tokens := lexAll("###\r\ncomment\r\n###\r\nx")
+	assertTokenCount(t, tokens, 2)
+	assertToken(t, tokens, 0, token.IDENTIFIER, "x")
+}
+
+func TestTabColumns(t *testing.T) {
+	// A tab is one column, like any other rune; diag.Render shows it wider.
+	tokens := lexAll("\tx\n  \ty z\tw")
+	expected := []int{2, 4, 6, 8}
+	for i, col := range expected {
+		if tokens[i].Column != col {
+			t.Errorf("token %d (%s): expected column %d, got %d", i, tokens[i].Literal, col, tokens[i].Column)
+		}
+	}
+}
+
 // --- Edge Cases ---
 
 func TestEmptyInput(t *testing.T) {
@@ -697,6 +923,22 @@ func TestPositionTracking(t *testing.T) {
 	}
 }
 
+func TestTokenOffsets(t *testing.T) {
+	src := "é : \"a b\";\n@x"
+	tokens := lexAll(src)
+	for _, tok := range tokens[:len(tokens)-1] {
+		if got := src[tok.Offset:tok.End]; tok.Type != token.STRING && got != tok.Literal {
+			t.Errorf("%s: source range %d:%d is %q, literal %q", tok.Type, tok.Offset, tok.End, got, tok.Literal)
+		}
+	}
+	if tokens[2].Offset != 5 || tokens[2].End != 10 {
+		t.Errorf("string range: got %d:%d", tokens[2].Offset, tokens[2].End)
+	}
+	if eof := tokens[len(tokens)-1]; eof.Offset != len(src) || eof.End != len(src) {
+		t.Errorf("EOF range: got %d:%d", eof.Offset, eof.End)
+	}
+}
+
 // --- Structural Breaking ---
 
 func TestStructuralBreaking(t *testing.T) {
@@ -955,6 +1197,42 @@ func TestConsecutiveComments(t *testing.T) {
 	assertToken(t, tokens, 0, token.IDENTIFIER, "x")
 }
 
+// --- Comments kept as tokens ---
+
+func TestWithComments(t *testing.T) {
+	input := "x # trailing\n###\nblock\n### after\n# own line\r\ny"
+	tokens := New([]byte(input), WithComments(true)).Tokenize()
+	assertTokenCount(t, tokens, 7)
+	assertToken(t, tokens, 0, token.IDENTIFIER, "x")
+	assertToken(t, tokens, 1, token.COMMENT, "# trailing")
+	assertToken(t, tokens, 2, token.BLOCK_COMMENT, "###\nblock\n###")
+	assertToken(t, tokens, 3, token.IDENTIFIER, "after")
+	assertToken(t, tokens, 4, token.COMMENT, "# own line")
+	assertToken(t, tokens, 5, token.IDENTIFIER, "y")
+	for i, want := range map[int][2]int{1: {1, 3}, 2: {2, 1}, 4: {5, 1}} {
+		if tok := tokens[i]; tok.Line != want[0] || tok.Column != want[1] {
+			t.Errorf("%s at %d:%d, want %d:%d", tok.Type, tok.Line, tok.Column, want[0], want[1])
+		}
+	}
+	if tok := tokens[2]; tok.EndLine != 4 || tok.EndColumn != 4 {
+		t.Errorf("block comment ends at %d:%d, want 4:4", tok.EndLine, tok.EndColumn)
+	}
+}
+
+func TestWithCommentsSignGluing(t *testing.T) {
+	// A comment between ( and -1 does not make the sign infix.
+	tokens := New([]byte("( # c\n-1"), WithComments(true)).Tokenize()
+	assertTokenCount(t, tokens, 4)
+	assertToken(t, tokens, 1, token.COMMENT, "# c")
+	assertToken(t, tokens, 2, token.INTEGER, "-1")
+}
+
+func TestWithCommentsUnterminatedBlock(t *testing.T) {
+	tokens := New([]byte("###\nnever closes"), WithComments(true)).Tokenize()
+	assertTokenCount(t, tokens, 2)
+	assertToken(t, tokens, 0, token.BLOCK_COMMENT, "###\nnever closes")
+}
+
 // --- Token.String coverage for LookupIdent ---
 
 func TestLookupIdentNonKeyword(t *testing.T) {
@@ -1019,3 +1297,65 @@ func TestUnicodeNumberIdentifier(t *testing.T) {
 	assertToken(t, tokens, 0, token.IDENTIFIER, "Ⅰ")
 	assertToken(t, tokens, 1, token.IDENTIFIER, "½")
 }
+
+func TestDiagnostics(t *testing.T) {
+	l := New([]byte("a : 1;\nb : \"x"))
+	l.Tokenize()
+	diags := l.Diagnostics()
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %v", diags)
+	}
+	d := diags[0]
+	if d.Code != diag.LexError || d.Span.Start.Line != 2 || d.Span.Start.Column != 5 || d.Message != "unterminated string" {
+		t.Errorf("got %+v", d)
+	}
+}
+
+// --- Source Files ---
+
+func TestReadSourceLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.org")
+	if err := os.WriteFile(path, make([]byte, 2048), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("ORG_MAX_FILE_SIZE", "1K")
+	_, err := ReadSource(path)
+	tooLarge, ok := err.(*FileTooLargeError)
+	if !ok {
+		t.Fatalf("expected FileTooLargeError, got %v", err)
+	}
+	if tooLarge.Size != 2048 || tooLarge.Limit != 1024 {
+		t.Errorf("unexpected error fields: %+v", tooLarge)
+	}
+	if expected := path + ": file too large (2.0 KiB, limit 1.0 KiB); raise the limit with ORG_MAX_FILE_SIZE"; err.Error() != expected {
+		t.Errorf("message: got %q", err.Error())
+	}
+
+	t.Setenv("ORG_MAX_FILE_SIZE", "0")
+	if src, err := ReadSource(path); err != nil || len(src) != 2048 {
+		t.Errorf("unlimited: got %d bytes, %v", len(src), err)
+	}
+
+	t.Setenv("ORG_MAX_FILE_SIZE", "lots")
+	if _, err := ReadSource(path); err == nil {
+		t.Error("expected error for invalid ORG_MAX_FILE_SIZE")
+	}
+
+	// 8589934592G is 2^63 bytes, which would wrap to a negative limit.
+	t.Setenv("ORG_MAX_FILE_SIZE", "8589934592G")
+	if _, err := ReadSource(path); err == nil {
+		t.Error("expected error for an ORG_MAX_FILE_SIZE overflowing int64")
+	}
+}
+
+func TestMaxFileSizeDefault(t *testing.T) {
+	t.Setenv("ORG_MAX_FILE_SIZE", "")
+	if n, err := MaxFileSize(); err != nil || n != DefaultMaxFileSize {
+		t.Errorf("got %d, %v", n, err)
+	}
+	t.Setenv("ORG_MAX_FILE_SIZE", "512M")
+	if n, _ := MaxFileSize(); n != 512<<20 {
+		t.Errorf("got %d", n)
+	}
+}
@@ -0,0 +1,112 @@
+package lexer
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxFileSize is the largest source file ReadSource accepts when
+// ORG_MAX_FILE_SIZE is not set.
+const DefaultMaxFileSize = 64 << 20
+
+// FileTooLargeError is returned by ReadSource for files over the limit.
+type FileTooLargeError struct {
+	Path  string
+	Size  int64 // bytes seen before giving up; at least Limit+1
+	Limit int64
+}
+
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("%s: file too large (%s, limit %s); raise the limit with ORG_MAX_FILE_SIZE",
+		e.Path, formatSize(e.Size), formatSize(e.Limit))
+}
+
+// MaxFileSize returns the source size limit: ORG_MAX_FILE_SIZE if set
+// (bytes, or with a K, M or G suffix; 0 disables the limit), otherwise
+// DefaultMaxFileSize.
+func MaxFileSize() (int64, error) {
+	v := strings.TrimSpace(os.Getenv("ORG_MAX_FILE_SIZE"))
+	if v == "" {
+		return DefaultMaxFileSize, nil
+	}
+	n, err := parseSize(v)
+	if err != nil {
+		return 0, fmt.Errorf("ORG_MAX_FILE_SIZE: %w", err)
+	}
+	return n, nil
+}
+
+// ReadSource reads a source file, refusing files larger than MaxFileSize
+// before loading them. The lexer and parser stream over the returned
+// bytes, so this is the only full copy of the input held in memory.
+func ReadSource(path string) ([]byte, error) {
+	limit, err := MaxFileSize()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if limit > 0 {
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() && info.Size() > limit {
+			return nil, &FileTooLargeError{Path: path, Size: info.Size(), Limit: limit}
+		}
+	}
+
+	// Pipes and files growing while read have no reliable size up front:
+	// read at most one byte past the limit.
+	var r io.Reader = f
+	if limit > 0 {
+		r = io.LimitReader(f, limit+1)
+	}
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(src)) > limit {
+		return nil, &FileTooLargeError{Path: path, Size: int64(len(src)), Limit: limit}
+	}
+	return src, nil
+}
+
+func parseSize(s string) (int64, error) {
+	digits, mult := s, int64(1)
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		mult = 1 << 10
+	case "M":
+		mult = 1 << 20
+	case "G":
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		digits = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if n > math.MaxInt64/mult {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return n * mult, nil
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
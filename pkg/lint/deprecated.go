@@ -0,0 +1,133 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"orglang/pkg/doc"
+	"orglang/pkg/lexer"
+	"orglang/pkg/manifest"
+	"orglang/pkg/token"
+)
+
+// Deprecated reports uses of bindings whose docstring carries a
+// @deprecated tag, with the tag's note (which names the replacement):
+//
+//   - bindings of the file itself, used by name;
+//   - bindings of modules imported with `name : "path" @ org`, used as
+//     `name.binding`.
+//
+// Imports are resolved relative to the file's directory, then to the
+// working directory, then among the dependencies of the project
+// (manifest.ResolveImport). Modules that cannot be read or parsed are skipped;
+// reporting them is the job of checking those modules.
+func Deprecated(path string, src []byte, sev Severity) []Finding {
+	if sev == Off {
+		return nil
+	}
+
+	var tokens []token.Token
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	local := map[string]*doc.Binding{}
+	if mod, err := doc.Parse(path, path, src); err == nil {
+		for _, b := range mod.Bindings {
+			if b.Deprecated {
+				local[b.Name] = b
+			}
+		}
+	}
+	imports := Imports(path, src)
+
+	var findings []Finding
+	report := func(tok token.Token, name string, b *doc.Binding) {
+		msg := name + " is deprecated"
+		if b.DeprecatedNote != "" {
+			msg += ": " + b.DeprecatedNote
+		}
+		findings = append(findings, Finding{Line: tok.Line, Column: tok.Column, Severity: sev, Rule: "deprecated", Message: msg})
+	}
+
+	for i, tok := range tokens {
+		if tok.Type != token.IDENTIFIER {
+			continue
+		}
+		afterDot := i > 0 && tokens[i-1].Type == token.DOT
+		if afterDot {
+			if i < 2 || tokens[i-2].Type != token.IDENTIFIER {
+				continue
+			}
+			alias := tokens[i-2].Literal
+			if mod := imports[alias]; mod != nil {
+				if b := mod.Lookup(tok.Literal); b != nil && b.Deprecated {
+					report(tok, alias+"."+tok.Literal, b)
+				}
+			}
+			continue
+		}
+		if b := local[tok.Literal]; b != nil && !token.IsDefinition(tokens, i) {
+			report(tok, tok.Literal, b)
+		}
+	}
+	return findings
+}
+
+// Imports returns the documentation of the modules src, read from path,
+// imports with `alias : "path" @ org`, by alias, resolved as Deprecated
+// resolves them. Modules that cannot be read or parsed are left out.
+func Imports(path string, src []byte) map[string]*doc.Module {
+	var tokens []token.Token
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+	imports := map[string]*doc.Module{}
+	for alias, importPath := range importsOf(tokens) {
+		if mod := loadImport(path, importPath); mod != nil {
+			imports[alias] = mod
+		}
+	}
+	return imports
+}
+
+// importsOf finds the `alias : "path" @ org` imports of a token stream.
+func importsOf(tokens []token.Token) map[string]string {
+	imports := map[string]string{}
+	for i := 0; i+4 < len(tokens); i++ {
+		if tokens[i].Type == token.IDENTIFIER && tokens[i+1].Type == token.COLON &&
+			(tokens[i+2].Type == token.STRING || tokens[i+2].Type == token.RAWSTRING) &&
+			tokens[i+3].Type == token.AT && tokens[i+4].Literal == "org" {
+			imports[tokens[i].Literal] = tokens[i+2].Literal
+		}
+	}
+	return imports
+}
+
+func loadImport(from, importPath string) *doc.Module {
+	candidates := []string{importPath}
+	if !filepath.IsAbs(importPath) {
+		candidates = []string{filepath.Join(filepath.Dir(from), importPath), importPath}
+	}
+	if dep, ok := manifest.ResolveImport(from, importPath); ok {
+		candidates = append(candidates, dep)
+	}
+	for _, c := range candidates {
+		if info, err := os.Stat(c); err != nil || info.IsDir() {
+			continue
+		}
+		src, err := lexer.ReadSource(c)
+		if err != nil {
+			return nil
+		}
+		mod, err := doc.Parse(strings.TrimSuffix(filepath.Base(c), ".org"), c, src)
+		if err != nil {
+			return nil
+		}
+		return mod
+	}
+	return nil
+}
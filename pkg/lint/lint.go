@@ -0,0 +1,191 @@
+// Package lint implements the source checks run by `org check`.
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/diag"
+)
+
+// Severity controls how a check's findings are reported.
+type Severity int
+
+const (
+	Off Severity = iota
+	Warning
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Off:
+		return "off"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// ParseSeverity parses "off", "warning" or "error".
+func ParseSeverity(s string) (Severity, error) {
+	switch s {
+	case "off":
+		return Off, nil
+	case "warning", "warn":
+		return Warning, nil
+	case "error":
+		return Error, nil
+	}
+	return Off, fmt.Errorf("invalid severity %q (want off, warning or error)", s)
+}
+
+// Rules lists the lint rules with their default severity.
+var Rules = map[string]Severity{
+	"unicode":    Error,
+	"deprecated": Warning,
+	"duplicate":  Warning,
+	"scope":      Error,
+	"symbols":    Warning,
+}
+
+// Config holds the severity of each rule.
+type Config map[string]Severity
+
+// DefaultConfig returns the default severity of every rule.
+func DefaultConfig() Config {
+	c := Config{}
+	for rule, sev := range Rules {
+		c[rule] = sev
+	}
+	return c
+}
+
+// Set overrides the severities of the given rules, e.g. from the [lint]
+// table of org.toml. Unknown rules and severities are errors.
+func (c Config) Set(levels map[string]string) error {
+	for rule, level := range levels {
+		if _, ok := Rules[rule]; !ok {
+			return fmt.Errorf("unknown lint rule %q", rule)
+		}
+		sev, err := ParseSeverity(level)
+		if err != nil {
+			return fmt.Errorf("lint rule %q: %w", rule, err)
+		}
+		c[rule] = sev
+	}
+	return nil
+}
+
+// Finding is a single problem reported by a check.
+type Finding struct {
+	Line     int // 1-indexed
+	Column   int // 1-indexed
+	Severity Severity
+	Rule     string
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("line %d:%d: %s: %s [%s]", f.Line, f.Column, f.Severity, f.Message, f.Rule)
+}
+
+// Codes are the diagnostic codes of the rules.
+var Codes = map[string]diag.Code{
+	"unicode":    diag.Unicode,
+	"deprecated": diag.Deprecated,
+	"duplicate":  diag.Duplicate,
+	"scope":      diag.Scope,
+	"symbols":    diag.UndeclaredSymbol,
+}
+
+// Diagnostic returns f as a diagnostic, naming its rule in the message.
+func (f Finding) Diagnostic() diag.Diagnostic {
+	sev := diag.Warning
+	if f.Severity == Error {
+		sev = diag.Error
+	}
+	return diag.Diagnostic{
+		Code:     Codes[f.Rule],
+		Severity: sev,
+		Span:     ast.Span{Start: ast.Pos{Line: f.Line, Column: f.Column}},
+		Message:  fmt.Sprintf("%s [%s]", f.Message, f.Rule),
+	}
+}
+
+// HasErrors reports whether any finding has Error severity.
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Check runs every rule over src, the source of path, with the severities
+// of cfg, and returns the findings not silenced by org:ignore comments in
+// source order. declared are the names bound by the std modules the file
+// uses.
+func Check(path string, src []byte, cfg Config, declared []string) []Finding {
+	var findings []Finding
+	findings = append(findings, Unicode(src, cfg["unicode"])...)
+	findings = append(findings, Deprecated(path, src, cfg["deprecated"])...)
+	findings = append(findings, Duplicates(src, cfg["duplicate"])...)
+	findings = append(findings, Scope(src, cfg["scope"])...)
+	findings = append(findings, Symbols(src, declared, cfg["symbols"])...)
+	findings = Suppress(src, findings)
+	sortFindings(findings)
+	return findings
+}
+
+var ignoreRe = regexp.MustCompile(`#\s*org:ignore\b(.*)$`)
+
+// Suppress drops the findings silenced by an `org:ignore` comment:
+//
+//	x : lib.sum [1 2];  # org:ignore deprecated
+//	# org:ignore unicode, deprecated
+//	y : lib.sum [3 4];
+//
+// A trailing comment covers its own line, a comment alone on a line
+// covers the next one. Without rule names every rule is silenced.
+func Suppress(src []byte, findings []Finding) []Finding {
+	ignored := map[int][]string{} // line -> rules; none means all
+	for i, line := range strings.Split(string(src), "\n") {
+		m := ignoreRe.FindStringSubmatchIndex(line)
+		if m == nil {
+			continue
+		}
+		target := i + 1
+		if strings.TrimSpace(line[:m[0]]) == "" {
+			target++
+		}
+		rules := strings.FieldsFunc(line[m[2]:m[3]], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\r' })
+		ignored[target] = rules
+	}
+
+	var kept []Finding
+	for _, f := range findings {
+		rules, ok := ignored[f.Line]
+		if ok && (len(rules) == 0 || slices.Contains(rules, f.Rule)) {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Column < findings[j].Column
+	})
+}
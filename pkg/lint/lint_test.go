@@ -0,0 +1,268 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"orglang/pkg/diag"
+)
+
+func TestUnicodeBidiInComment(t *testing.T) {
+	src := "x : 1; # \u202E} \u2066 hidden\ny : 2;"
+	findings := Unicode([]byte(src), Error)
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %v", findings)
+	}
+	if findings[0].Line != 1 || findings[0].Column != 10 || !strings.Contains(findings[0].Message, "U+202E (RIGHT-TO-LEFT OVERRIDE)") {
+		t.Errorf("unexpected finding: %v", findings[0])
+	}
+	if findings[1].Column != 13 || !strings.Contains(findings[1].Message, "U+2066") {
+		t.Errorf("unexpected finding: %v", findings[1])
+	}
+	if !HasErrors(findings) {
+		t.Error("expected error severity")
+	}
+}
+
+func TestFindingDiagnostic(t *testing.T) {
+	for rule := range Rules {
+		if Codes[rule] == 0 {
+			t.Errorf("rule %s has no diagnostic code", rule)
+		}
+	}
+	f := Finding{Line: 3, Column: 5, Severity: Warning, Rule: "duplicate", Message: "x is bound twice"}
+	d := f.Diagnostic()
+	if d.Code != diag.Duplicate || d.Severity != diag.Warning || d.Span.Start.Line != 3 || d.Span.Start.Column != 5 || d.Message != "x is bound twice [duplicate]" {
+		t.Errorf("got %+v", d)
+	}
+}
+
+func TestUnicodeBidiInString(t *testing.T) {
+	findings := Unicode([]byte(`access : "user\u202E \u2066// admin";`), Warning)
+	// The escapes are decoded by the lexer, not present in the source bytes.
+	if len(findings) != 0 {
+		t.Errorf("escaped controls should not be reported, got %v", findings)
+	}
+
+	findings = Unicode([]byte("access : \"user\u202E\";"), Warning)
+	if len(findings) != 1 || findings[0].Severity != Warning || HasErrors(findings) {
+		t.Errorf("expected one warning, got %v", findings)
+	}
+}
+
+func TestUnicodeConfusableIdentifier(t *testing.T) {
+	findings := Unicode([]byte("p\u0430ypal : 1;"), Error)
+	if len(findings) != 1 || findings[0].String() != "line 1:1: error: identifier \"p\u0430ypal\" mixes Latin and Cyrillic scripts [unicode]" {
+		t.Errorf("got %v", findings)
+	}
+}
+
+func TestUnicodeStringLiterals(t *testing.T) {
+	findings := Unicode([]byte("a : \"pay\u200Bpal\";\nb : 'p\u0430ypal';\nc : \"привет, world\";"), Error)
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %v", findings)
+	}
+	if findings[0].Line != 1 || !strings.Contains(findings[0].Message, "ZERO WIDTH SPACE") {
+		t.Errorf("unexpected finding: %v", findings[0])
+	}
+	if findings[1].Line != 2 || !strings.Contains(findings[1].Message, "mixes Latin and Cyrillic") {
+		t.Errorf("unexpected finding: %v", findings[1])
+	}
+}
+
+func TestUnicodeJoiners(t *testing.T) {
+	findings := Unicode([]byte("a : \"pay\u200Cpal\";\nb : \"pay\u200Dpal\";\nc\u200Dd : 1;"), Error)
+	if len(findings) != 3 {
+		t.Fatalf("expected 3 findings, got %v", findings)
+	}
+	for i, want := range []string{"U+200C (ZERO WIDTH NON-JOINER)", "U+200D (ZERO WIDTH JOINER)", "U+200D (ZERO WIDTH JOINER)"} {
+		if findings[i].Line != i+1 || !strings.Contains(findings[i].Message, want) {
+			t.Errorf("finding %d: got %v, want line %d with %s", i, findings[i], i+1, want)
+		}
+	}
+	if findings[2].Column != 2 {
+		t.Errorf("the joiner in c\u200Dd is at column 2, got %v", findings[2])
+	}
+}
+
+func TestUnicodeOff(t *testing.T) {
+	if findings := Unicode([]byte("x : \"\u202E\";"), Off); findings != nil {
+		t.Errorf("expected no findings, got %v", findings)
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for in, expected := range map[string]Severity{"off": Off, "warning": Warning, "warn": Warning, "error": Error} {
+		got, err := ParseSeverity(in)
+		if err != nil || got != expected {
+			t.Errorf("ParseSeverity(%q): got %v, %v", in, got, err)
+		}
+	}
+	if _, err := ParseSeverity("fatal"); err == nil {
+		t.Error("expected error for unknown severity")
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	dir := t.TempDir()
+	lib := `"""Sums a table.
+@deprecated use ` + "`fold_add`" + ` instead"""
+sum : { right };
+
+fold_add : { right };
+
+"""@deprecated"""
+old_limit : 10;
+`
+	if err := os.WriteFile(filepath.Join(dir, "lib.org"), []byte(lib), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	main := `lib : "lib.org" @ org;
+"""@deprecated use ` + "`new_helper`" + `"""
+helper : { right };
+x : lib.sum [1 2];
+y : lib.fold_add [1 2];
+z : lib.old_limit;
+w : helper 1;
+t.helper;
+`
+	path := filepath.Join(dir, "main.org")
+	findings := Deprecated(path, []byte(main), Warning)
+	expected := []string{
+		"line 4:9: warning: lib.sum is deprecated: use `fold_add` instead [deprecated]",
+		"line 6:9: warning: lib.old_limit is deprecated [deprecated]",
+		"line 7:5: warning: helper is deprecated: use `new_helper` [deprecated]",
+	}
+	if len(findings) != len(expected) {
+		t.Fatalf("expected %d findings, got %v", len(expected), findings)
+	}
+	for i, f := range findings {
+		if f.String() != expected[i] {
+			t.Errorf("finding %d: expected %q, got %q", i, expected[i], f.String())
+		}
+	}
+}
+
+func TestDeprecatedMissingImport(t *testing.T) {
+	src := `lib : "missing.org" @ org; x : lib.sum;`
+	if findings := Deprecated(filepath.Join(t.TempDir(), "main.org"), []byte(src), Warning); len(findings) != 0 {
+		t.Errorf("expected no findings, got %v", findings)
+	}
+}
+
+func TestSuppress(t *testing.T) {
+	src := "a : 1;  # org:ignore deprecated\n# org:ignore unicode, deprecated\nb : 2;\nc : 3; # org:ignore\nd : 4;"
+	findings := []Finding{
+		{Line: 1, Rule: "deprecated"},
+		{Line: 1, Rule: "unicode"},
+		{Line: 3, Rule: "unicode"},
+		{Line: 3, Rule: "deprecated"},
+		{Line: 4, Rule: "unicode"},
+		{Line: 5, Rule: "unicode"},
+	}
+	kept := Suppress([]byte(src), findings)
+	if len(kept) != 2 || kept[0].Line != 1 || kept[0].Rule != "unicode" || kept[1].Line != 5 {
+		t.Errorf("got %v", kept)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	c := DefaultConfig()
+	if c["unicode"] != Error || c["deprecated"] != Warning {
+		t.Errorf("defaults: got %v", c)
+	}
+	if err := c.Set(map[string]string{"deprecated": "error", "unicode": "off"}); err != nil {
+		t.Fatal(err)
+	}
+	if c["unicode"] != Off || c["deprecated"] != Error {
+		t.Errorf("after Set: got %v", c)
+	}
+	if err := c.Set(map[string]string{"tabs": "off"}); err == nil {
+		t.Error("expected error for unknown rule")
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	src := `x : 1;
+x : 2;
+x :+ 3;
+f : { a : 1; (a : 2); b : { a : 3 } };
+t : [k: 1 "k": 2 n: [k: 3]];
+t.k : 4;
+log @: [next: { right }];
+log @: [next: { right }];
+`
+	findings := Duplicates([]byte(src), Warning)
+	want := []string{
+		"line 2:1: warning: x is already bound at line 1:1 [duplicate]",
+		"line 4:15: warning: a is already bound at line 4:7 [duplicate]",
+		"line 5:11: warning: k is already bound at line 5:6 [duplicate]",
+		"line 8:1: warning: log is already bound at line 7:1 [duplicate]",
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("got %v", findings)
+	}
+	for i, w := range want {
+		if findings[i].String() != w {
+			t.Errorf("finding %d = %s, want %s", i, findings[i], w)
+		}
+	}
+	if findings := Duplicates([]byte(src), Off); len(findings) != 0 {
+		t.Errorf("off: got %v", findings)
+	}
+}
+
+func TestScope(t *testing.T) {
+	src := "x : right + 1;\nf : { left + right };\ng : [a: { this (right - 1) } b: left];\n(this)"
+	findings := Scope([]byte(src), Error)
+	if len(findings) != 3 {
+		t.Fatalf("got %v", findings)
+	}
+	for i, w := range []string{
+		"line 1:5: error: right is only bound inside a block [scope]",
+		"line 3:33: error: left is only bound inside a block [scope]",
+		"line 4:2: error: this is only bound inside a block [scope]",
+	} {
+		if findings[i].String() != w {
+			t.Errorf("finding %d = %s, want %s", i, findings[i], w)
+		}
+	}
+	// Unbalanced closers do not underflow the scope stack.
+	if findings := Scope([]byte("} ] right"), Error); len(findings) != 1 {
+		t.Errorf("unbalanced: got %v", findings)
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	src := "⊕ : 200{ left + right }201;\nx : 1 ⊕ 2 ⊗ 3;\ny : ∑ [x (√ 4)];\nz : €price + t.∑ + 1 <= 2;\n"
+	findings := Symbols([]byte(src), []string{"√"}, Warning)
+	if len(findings) != 2 {
+		t.Fatalf("got %v", findings)
+	}
+	for i, w := range []string{
+		"line 2:11: warning: ⊗ is not declared: bind it, or use the std module defining it [symbols]",
+		"line 3:5: warning: ∑ is not declared: bind it, or use the std module defining it [symbols]",
+	} {
+		if findings[i].String() != w {
+			t.Errorf("finding %d = %s, want %s", i, findings[i], w)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	src := "y : right;\nx : 1;\nx : 2;  # org:ignore duplicate\nz : \"a‮b\";\n"
+	findings := Check("", []byte(src), DefaultConfig(), nil)
+	if len(findings) != 2 || findings[0].Rule != "scope" || findings[1].Rule != "unicode" {
+		t.Errorf("got %v", findings)
+	}
+	cfg := DefaultConfig()
+	if err := cfg.Set(map[string]string{"scope": "off", "unicode": "off"}); err != nil {
+		t.Fatal(err)
+	}
+	if findings := Check("", []byte(src), cfg, nil); len(findings) != 0 {
+		t.Errorf("rules off: got %v", findings)
+	}
+}
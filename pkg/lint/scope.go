@@ -0,0 +1,90 @@
+package lint
+
+import (
+	"fmt"
+
+	"orglang/pkg/lexer"
+	"orglang/pkg/token"
+)
+
+// frame is a scope of the source: the file, a block or a table literal.
+// Parentheses open no scope; their bindings land in the enclosing one.
+type frame struct {
+	open  token.TokenType // EOF for the file
+	names map[string]token.Token
+}
+
+// scan walks the tokens of src keeping the stack of scopes, and calls
+// visit for each token with the stack as it stands at that token.
+func scan(src []byte, visit func(tokens []token.Token, i int, stack []frame)) {
+	var tokens []token.Token
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+	stack := []frame{{open: token.EOF, names: map[string]token.Token{}}}
+	for i, tok := range tokens {
+		switch tok.Type {
+		case token.LBRACE, token.LBRACKET:
+			stack = append(stack, frame{open: tok.Type, names: map[string]token.Token{}})
+		case token.LPAREN:
+			stack = append(stack, frame{open: tok.Type, names: stack[len(stack)-1].names})
+		case token.RBRACE, token.RBRACKET, token.RPAREN:
+			// An unbalanced closer is a parse error, reported elsewhere.
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+		visit(tokens, i, stack)
+	}
+}
+
+// Duplicates reports names bound twice in the same scope: two top-level
+// bindings of a file, two bindings of a block or two keys of a table
+// literal. The later binding silently replaces the earlier one.
+func Duplicates(src []byte, sev Severity) []Finding {
+	if sev == Off {
+		return nil
+	}
+	var findings []Finding
+	scan(src, func(tokens []token.Token, i int, stack []frame) {
+		tok := tokens[i]
+		if tok.Type != token.IDENTIFIER && tok.Type != token.STRING && tok.Type != token.RAWSTRING {
+			return
+		}
+		if !token.IsDefinition(tokens, i) || i > 0 && tokens[i-1].Type == token.DOT {
+			return
+		}
+		names := stack[len(stack)-1].names
+		if first, ok := names[tok.Literal]; ok {
+			findings = append(findings, Finding{Line: tok.Line, Column: tok.Column, Severity: sev, Rule: "duplicate",
+				Message: fmt.Sprintf("%s is already bound at line %d:%d", tok.Literal, first.Line, first.Column)})
+			return
+		}
+		names[tok.Literal] = tok
+	})
+	return findings
+}
+
+// Scope reports uses of left, right and this outside any block literal,
+// where they are not bound.
+func Scope(src []byte, sev Severity) []Finding {
+	if sev == Off {
+		return nil
+	}
+	var findings []Finding
+	scan(src, func(tokens []token.Token, i int, stack []frame) {
+		tok := tokens[i]
+		if tok.Type != token.KEYWORD {
+			return
+		}
+		for _, f := range stack {
+			if f.open == token.LBRACE {
+				return
+			}
+		}
+		findings = append(findings, Finding{Line: tok.Line, Column: tok.Column, Severity: sev, Rule: "scope",
+			Message: tok.Literal + " is only bound inside a block"})
+	})
+	return findings
+}
@@ -0,0 +1,65 @@
+package lint
+
+import (
+	"strings"
+	"unicode"
+
+	"orglang/pkg/lexer"
+	"orglang/pkg/token"
+)
+
+// Symbols reports uses of symbol operators, identifiers made of symbols
+// with at least one outside ASCII (`∑`, `√`, `≠`), that are not declared:
+// neither bound in the file nor among declared, the names bound by the
+// std modules the file uses. Unicode symbols lex as identifiers like any
+// other, so an undeclared one is most likely a typo of a declared one or
+// an operator expected from a module the file does not use.
+func Symbols(src []byte, declared []string, sev Severity) []Finding {
+	if sev == Off {
+		return nil
+	}
+	var tokens []token.Token
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	known := map[string]bool{}
+	for _, name := range declared {
+		known[name] = true
+	}
+	for i, tok := range tokens {
+		if tok.Type == token.IDENTIFIER && token.IsDefinition(tokens, i) {
+			known[tok.Literal] = true
+		}
+	}
+
+	var findings []Finding
+	for i, tok := range tokens {
+		if tok.Type != token.IDENTIFIER || known[tok.Literal] || !isSymbolOperator(tok.Literal) {
+			continue
+		}
+		if i > 0 && tokens[i-1].Type == token.DOT {
+			continue // a key or a module member
+		}
+		findings = append(findings, Finding{Line: tok.Line, Column: tok.Column, Severity: sev, Rule: "symbols",
+			Message: tok.Literal + " is not declared: bind it, or use the std module defining it"})
+	}
+	return findings
+}
+
+// isSymbolOperator reports whether name is made of symbols, at least one
+// of them outside ASCII.
+func isSymbolOperator(name string) bool {
+	nonASCII := false
+	for _, r := range name {
+		switch {
+		case r > unicode.MaxASCII && unicode.IsSymbol(r):
+			nonASCII = true
+		case r <= unicode.MaxASCII && strings.ContainsRune("!$%&*-+=^~?/<>|", r):
+		default:
+			return false
+		}
+	}
+	return nonASCII
+}
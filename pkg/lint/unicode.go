@@ -0,0 +1,114 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"orglang/pkg/lexer"
+	"orglang/pkg/token"
+)
+
+// bidiControls are the characters that reorder how text is displayed
+// without changing how it is lexed ("Trojan Source", CVE-2021-42574).
+var bidiControls = map[rune]string{
+	'\u061C': "ARABIC LETTER MARK",
+	'\u200E': "LEFT-TO-RIGHT MARK",
+	'\u200F': "RIGHT-TO-LEFT MARK",
+	'\u202A': "LEFT-TO-RIGHT EMBEDDING",
+	'\u202B': "RIGHT-TO-LEFT EMBEDDING",
+	'\u202C': "POP DIRECTIONAL FORMATTING",
+	'\u202D': "LEFT-TO-RIGHT OVERRIDE",
+	'\u202E': "RIGHT-TO-LEFT OVERRIDE",
+	'\u2066': "LEFT-TO-RIGHT ISOLATE",
+	'\u2067': "RIGHT-TO-LEFT ISOLATE",
+	'\u2068': "FIRST STRONG ISOLATE",
+	'\u2069': "POP DIRECTIONAL ISOLATE",
+}
+
+// invisibles are characters that render as nothing and can make two
+// different strings look identical.
+var invisibles = map[rune]string{
+	'\u00AD': "SOFT HYPHEN",
+	'\u200B': "ZERO WIDTH SPACE",
+	'\u200C': "ZERO WIDTH NON-JOINER",
+	'\u200D': "ZERO WIDTH JOINER",
+	'\u2060': "WORD JOINER",
+	'\uFEFF': "ZERO WIDTH NO-BREAK SPACE",
+}
+
+// Unicode reports characters that make source text read differently from
+// how it is lexed:
+//
+//   - bidirectional control characters anywhere in the file, including
+//     comments and string literals;
+//   - identifiers mixing look-alike scripts (a Cyrillic а in a Latin name);
+//   - invisible characters, and words mixing look-alike scripts, inside
+//     string literals;
+//   - invisible characters between the parts of an identifier, which the
+//     lexer rejects without saying what it saw.
+//
+// Findings carry the given severity; Off disables the check.
+func Unicode(src []byte, sev Severity) []Finding {
+	if sev == Off {
+		return nil
+	}
+	var findings []Finding
+	report := func(line, col int, format string, args ...any) {
+		findings = append(findings, Finding{Line: line, Column: col, Severity: sev, Rule: "unicode", Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Bidi controls are found on the raw bytes, so the ones hidden in
+	// comments are reported too.
+	line, col := 1, 1
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRune(src[i:])
+		if name, ok := bidiControls[r]; ok {
+			report(line, col, "bidirectional control character U+%04X (%s)", r, name)
+		}
+		if r == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+		i += size
+	}
+
+	l := lexer.New(src, lexer.WithMixedScriptWarnings(true))
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.STRING, token.RAWSTRING, token.DOCSTRING, token.RAWDOC,
+			token.INTERP_START, token.INTERP_MID, token.INTERP_END:
+			checkString(tok, report)
+		case token.ILLEGAL:
+			for _, r := range tok.Literal {
+				if name, ok := invisibles[r]; ok {
+					report(tok.Line, tok.Column, "invisible character U+%04X (%s)", r, name)
+				}
+			}
+		}
+	}
+	for _, w := range l.Warnings() {
+		report(w.Line, w.Column, "%s", w.Message)
+	}
+
+	sortFindings(findings)
+	return findings
+}
+
+func checkString(tok token.Token, report func(line, col int, format string, args ...any)) {
+	for _, r := range tok.Literal {
+		if name, ok := invisibles[r]; ok {
+			report(tok.Line, tok.Column, "string contains invisible character U+%04X (%s)", r, name)
+		}
+	}
+	words := strings.FieldsFunc(tok.Literal, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsMark(r)
+	})
+	for _, w := range words {
+		if scripts := lexer.ConfusableScripts(w); len(scripts) > 1 {
+			report(tok.Line, tok.Column, "string word %q mixes %s scripts", w, strings.Join(scripts, " and "))
+		}
+	}
+}
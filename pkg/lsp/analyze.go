@@ -0,0 +1,253 @@
+package lsp
+
+import (
+	"path/filepath"
+	"sort"
+	"unicode/utf8"
+
+	"orglang/pkg/lexer"
+	"orglang/pkg/lint"
+	"orglang/pkg/manifest"
+	"orglang/pkg/parser"
+	"orglang/pkg/std"
+	"orglang/pkg/token"
+)
+
+// Diagnostics returns the problems `org check` reports for src, read from
+// path: lexical and parse errors, undefined identifiers, and the lints
+// configured by the enclosing project's org.toml, with the std modules it
+// uses. path may be "" for a
+// document that is not a file; lints that read imports then see none.
+func Diagnostics(path string, src []byte) []Diagnostic {
+	lines := newLineIndex(src)
+	tokens := tokenize(src)
+	var out []Diagnostic
+	add := func(line, col int, sev int, code, msg string) {
+		start := lines.position(line, col)
+		end := start
+		// Cover the token at the position, if one starts there.
+		i := sort.Search(len(tokens), func(i int) bool {
+			t := tokens[i]
+			return t.Line > line || t.Line == line && t.Column >= col
+		})
+		if i < len(tokens) && tokens[i].Line == line && tokens[i].Column == col {
+			end = lines.offsetPosition(tokens[i].End)
+		}
+		out = append(out, Diagnostic{Range: Range{start, end}, Severity: sev, Code: code, Source: "org", Message: msg})
+	}
+
+	cfg, pre := project(path)
+	l := lexer.New(src)
+	p := parser.New(l, parser.WithStrict(true), parser.WithBindings(pre.Bindings))
+	p.ParseProgram()
+	for _, d := range append(l.Diagnostics(), p.Diagnostics()...) {
+		add(d.Span.Start.Line, d.Span.Start.Column, SeverityError, d.Code.String(), d.Message)
+	}
+
+	for _, f := range lint.Check(path, src, cfg, pre.Names) {
+		sev := SeverityWarning
+		if f.Severity == lint.Error {
+			sev = SeverityError
+		}
+		add(f.Line, f.Column, sev, f.Rule, f.Message)
+	}
+	return out
+}
+
+// project returns the lint configuration and the std modules used by the
+// project holding path, from its org.toml; the defaults and no modules
+// without one, or for a path of "".
+func project(path string) (lint.Config, *std.Prelude) {
+	cfg := lint.DefaultConfig()
+	pre, _ := std.Load(nil)
+	if path != "" {
+		if m, err := manifest.Find(filepath.Dir(path)); err == nil && m != nil {
+			// A bad [lint] table or use list is reported by `org
+			// check`; the defaults stay in effect here.
+			_ = cfg.Set(m.Lint)
+			if used, err := std.Load(m.Use); err == nil {
+				pre = used
+			}
+		}
+	}
+	return cfg, pre
+}
+
+// Symbols returns the top-level bindings of src in source order: each
+// `name : value` or `name @: value` statement outside brackets. Names
+// bound twice are listed at each binding. Symbols are found even when
+// the file has parse errors, so the outline survives typing: a binding
+// starting at column 1 is taken as top-level even after an unclosed
+// bracket, since nested code is indented.
+func Symbols(src []byte) []DocumentSymbol {
+	lines := newLineIndex(src)
+	tokens := tokenize(src)
+	p := parser.New(lexer.New(src))
+	p.ParseProgram()
+	bindings := p.Bindings()
+
+	var out []DocumentSymbol
+	depth := 0
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.Column == 1 && tok.Type == token.IDENTIFIER && i+1 < len(tokens) &&
+			(tokens[i+1].Type == token.COLON || tokens[i+1].Type == token.AT_COLON) {
+			depth = 0
+		}
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth++
+			continue
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+			continue
+		}
+		if depth > 0 || tok.Type != token.IDENTIFIER || i+1 == len(tokens) ||
+			tokens[i+1].Type != token.COLON && tokens[i+1].Type != token.AT_COLON {
+			continue
+		}
+		// The value of a binding is not a new statement: `a : b : 1`
+		// binds b inside the value of a.
+		if i > 0 && (tokens[i-1].Type == token.COLON || tokens[i-1].Type == token.AT_COLON || tokens[i-1].Type == token.DOT) {
+			continue
+		}
+
+		end := statementEnd(tokens, i)
+		sym := DocumentSymbol{
+			Name:           tok.Literal,
+			Range:          Range{lines.offsetPosition(tok.Offset), lines.offsetPosition(tokens[end].End)},
+			SelectionRange: Range{lines.offsetPosition(tok.Offset), lines.offsetPosition(tok.End)},
+		}
+		entry, _ := bindings.Lookup(tok.Literal)
+		sym.Detail = entry.Kind()
+		switch {
+		case isImport(tokens, i):
+			sym.Kind, sym.Detail = SymbolModule, "import "+tokens[i+2].Literal
+		case entry.IsInfix:
+			sym.Kind = SymbolOperator
+		case entry.IsPrefix:
+			sym.Kind = SymbolFunction
+		case tokens[i+1].Type == token.AT_COLON || entry.IsResource:
+			sym.Kind, sym.Detail = SymbolObject, "resource"
+		default:
+			sym.Kind = SymbolVariable
+		}
+		out = append(out, sym)
+	}
+	return out
+}
+
+// statementEnd returns the index of the last token of the statement
+// starting at i: its `;`, or the token before the next top-level
+// binding.
+func statementEnd(tokens []token.Token, i int) int {
+	depth := 0
+	for j := i + 2; j < len(tokens); j++ {
+		switch tokens[j].Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				return j
+			}
+		case token.DOCSTRING, token.RAWDOC:
+			if depth == 0 {
+				return j - 1
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+// isImport reports whether the binding at i is `name : "path" @ org`.
+func isImport(tokens []token.Token, i int) bool {
+	return i+4 < len(tokens) && tokens[i+1].Type == token.COLON &&
+		(tokens[i+2].Type == token.STRING || tokens[i+2].Type == token.RAWSTRING) &&
+		tokens[i+3].Type == token.AT && tokens[i+4].Literal == "org"
+}
+
+func tokenize(src []byte) []token.Token {
+	var tokens []token.Token
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+	return tokens
+}
+
+// lineIndex converts the lexer's positions (1-based lines and rune
+// columns, byte offsets) to LSP positions.
+type lineIndex struct {
+	src    []byte
+	starts []int // byte offset of each line
+}
+
+func newLineIndex(src []byte) lineIndex {
+	starts := []int{0}
+	for i, c := range src {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return lineIndex{src, starts}
+}
+
+// position converts a 1-based line and rune column.
+func (x lineIndex) position(line, col int) Position {
+	if line < 1 {
+		return Position{}
+	}
+	if line > len(x.starts) {
+		return x.offsetPosition(len(x.src))
+	}
+	off := x.starts[line-1]
+	for n := 1; n < col && off < len(x.src) && x.src[off] != '\n'; n++ {
+		_, size := utf8.DecodeRune(x.src[off:])
+		off += size
+	}
+	return x.offsetPosition(off)
+}
+
+// offsetPosition converts a byte offset.
+func (x lineIndex) offsetPosition(off int) Position {
+	off = min(max(off, 0), len(x.src))
+	line := sort.Search(len(x.starts), func(i int) bool { return x.starts[i] > off }) - 1
+	units := 0
+	for i := x.starts[line]; i < off; {
+		r, size := utf8.DecodeRune(x.src[i:])
+		units += utf16Len(r)
+		i += size
+	}
+	return Position{Line: line, Character: units}
+}
+
+// offset converts an LSP position to a byte offset, clamped to the line
+// and to the source.
+func (x lineIndex) offset(p Position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(x.starts) {
+		return len(x.src)
+	}
+	off := x.starts[p.Line]
+	for units := 0; units < p.Character && off < len(x.src) && x.src[off] != '\n'; {
+		r, size := utf8.DecodeRune(x.src[off:])
+		units += utf16Len(r)
+		off += size
+	}
+	return off
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
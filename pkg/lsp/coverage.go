@@ -0,0 +1,36 @@
+package lsp
+
+import "orglang/pkg/eval"
+
+// CoverageHints returns a hint over each statement of src, the document
+// at path, that did not run in the last org test --coverage of it, from
+// the profiles saved in dir (eval.SaveProfile). The hints are tagged
+// unnecessary, which editors show faded. A statement inside one that did
+// not run gets no hint of its own. There are none if the module has no
+// profile or its text changed since, as the positions would be wrong.
+func CoverageHints(dir, path string, src []byte) []Diagnostic {
+	if dir == "" || path == "" {
+		return nil
+	}
+	p := eval.LoadProfile(dir, path)
+	if p == nil || p.Hash != eval.SourceHash(src) {
+		return nil
+	}
+	lines := newLineIndex(src)
+	var out []Diagnostic
+	end := -1 // of the last statement reported
+	for _, s := range p.Statements {
+		if s.Ran || s.Span.Start.Offset < end {
+			continue
+		}
+		end = s.Span.End.Offset
+		out = append(out, Diagnostic{
+			Range:    Range{lines.offsetPosition(s.Span.Start.Offset), lines.offsetPosition(s.Span.End.Offset)},
+			Severity: SeverityHint,
+			Source:   "org test",
+			Message:  "not run by the tests",
+			Tags:     []int{DiagnosticUnnecessary},
+		})
+	}
+	return out
+}
@@ -0,0 +1,45 @@
+package lsp
+
+import (
+	"bytes"
+
+	"orglang/pkg/format"
+)
+
+// onTypeTriggers are the characters after which the client asks for
+// on-type formatting: those ending a statement or a block.
+var onTypeTriggers = []string{";", "}"}
+
+// Format returns the edits formatting src in the canonical style of org
+// fmt, only within rng if it is not nil: the lines rng covers, widened to
+// whole top-level statements (see format.Range). A source that does not
+// parse has no edits, as the formatter cannot tell its structure.
+func Format(src []byte, rng *Range) []TextEdit {
+	lines := newLineIndex(src)
+	from, to := 0, len(src)
+	var out []byte
+	var err error
+	if rng == nil {
+		out, err = format.Source(src)
+	} else {
+		from, to, out, err = format.Range(src, lines.offset(rng.Start), lines.offset(rng.End))
+	}
+	if err != nil || bytes.Equal(src[from:to], out) {
+		return nil
+	}
+	return []TextEdit{{
+		Range:   Range{lines.offsetPosition(from), lines.offsetPosition(to)},
+		NewText: string(out),
+	}}
+}
+
+// FormatOnType returns the edits formatting the statement that ch, just
+// typed before pos, ends or is part of; only ; and } trigger it.
+func FormatOnType(src []byte, pos Position, ch string) []TextEdit {
+	if ch != ";" && ch != "}" {
+		return nil
+	}
+	lines := newLineIndex(src)
+	start := max(lines.offset(pos)-len(ch), 0)
+	return Format(src, &Range{lines.offsetPosition(start), pos})
+}
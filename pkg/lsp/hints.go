@@ -0,0 +1,108 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+	"orglang/pkg/token"
+)
+
+// InlayHints returns the hints of src, read from path, that fall within
+// rng, in source order:
+//
+//   - after each use of an operator that the file or a std module it uses
+//     binds, its binding powers, `lbp 700 rbp 701` for an infix operator
+//     and `bp 100` for a prefix one, since they decide how the operands
+//     around it group;
+//   - after the `{` of each block, the implicit operands its body refers
+//     to, `left, right:`, which make it an infix (both) or prefix (right
+//     only) operator.
+func InlayHints(path string, src []byte, rng Range) []InlayHint {
+	lines := newLineIndex(src)
+	tokens := tokenize(src)
+	_, pre := project(path)
+	p := parser.New(lexer.New(src), parser.WithBindings(pre.Bindings))
+	prog := p.ParseProgram()
+	bindings, defaults := p.Bindings(), parser.NewBindingTable()
+
+	var out []InlayHint
+	add := func(h InlayHint) {
+		if !h.Position.before(rng.Start) && !rng.End.before(h.Position) {
+			out = append(out, h)
+		}
+	}
+
+	for i, tok := range tokens {
+		if tok.Type != token.IDENTIFIER || token.IsDefinition(tokens, i) || i > 0 && tokens[i-1].Type == token.DOT {
+			continue
+		}
+		entry, ok := bindings.Lookup(tok.Literal)
+		if !ok || !entry.IsInfix && !entry.IsPrefix {
+			continue
+		}
+		if def, ok := defaults.Lookup(tok.Literal); ok && def == entry {
+			continue // a builtin operator, as documented
+		}
+		add(InlayHint{
+			Position:    lines.offsetPosition(tok.End),
+			Label:       bindingPowers(entry),
+			Tooltip:     tok.Literal + ": " + entry.Kind(),
+			PaddingLeft: true,
+		})
+	}
+
+	ast.Inspect(prog, func(n ast.Node) bool {
+		fl, ok := n.(*ast.FunctionLiteral)
+		if !ok {
+			return true
+		}
+		usesLeft, usesRight := parser.Operands(fl)
+		label := ""
+		switch {
+		case usesLeft && usesRight:
+			label = "left, right:"
+		case usesLeft:
+			label = "left:"
+		case usesRight:
+			label = "right:"
+		default:
+			return true
+		}
+		// The block starts at its binding power, if it has one.
+		i := sort.Search(len(tokens), func(i int) bool { return tokens[i].Offset >= fl.Span.Start.Offset })
+		for i < len(tokens) && tokens[i].Type != token.LBRACE {
+			i++
+		}
+		if i < len(tokens) {
+			add(InlayHint{
+				Position:     lines.offsetPosition(tokens[i].End),
+				Label:        label,
+				Kind:         InlayHintParameter,
+				PaddingLeft:  true,
+				PaddingRight: true,
+			})
+		}
+		return true
+	})
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Position.before(out[j].Position) })
+	return out
+}
+
+// bindingPowers is the label of an operator's hint.
+func bindingPowers(e parser.BindingEntry) string {
+	switch {
+	case e.IsPrefix && e.IsInfix:
+		return fmt.Sprintf("bp %d, lbp %d rbp %d", e.PrefixBP, e.LBP, e.RBP)
+	case e.IsInfix:
+		return fmt.Sprintf("lbp %d rbp %d", e.LBP, e.RBP)
+	}
+	return fmt.Sprintf("bp %d", e.PrefixBP)
+}
+
+// before reports whether p comes before q.
+func (p Position) before(q Position) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Character < q.Character
+}
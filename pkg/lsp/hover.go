@@ -0,0 +1,80 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"orglang/pkg/doc"
+	"orglang/pkg/lint"
+	"orglang/pkg/token"
+)
+
+// Hover returns the documentation of the name at pos in src, read from
+// path, or nil if there is none: for a binding of the file, or for
+// `alias.name` with alias a module the file imports, its kind and its
+// docstring, split by doc.ParseDocstring into the text, parameters,
+// result, examples and deprecation note, as org doc renders them. A file
+// that does not parse documents nothing until it does.
+func Hover(path string, src []byte, pos Position) *HoverResult {
+	lines := newLineIndex(src)
+	tokens := tokenize(src)
+	off := lines.offset(pos)
+	i := -1
+	for j, tok := range tokens {
+		if tok.Offset <= off && off <= tok.End && tok.Type == token.IDENTIFIER {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		return nil
+	}
+	tok := tokens[i]
+
+	name, b := tok.Literal, (*doc.Binding)(nil)
+	if i >= 2 && tokens[i-1].Type == token.DOT && tokens[i-2].Type == token.IDENTIFIER {
+		alias := tokens[i-2].Literal
+		if mod := lint.Imports(path, src)[alias]; mod != nil {
+			name, b = alias+"."+tok.Literal, mod.Lookup(tok.Literal)
+		}
+	} else if mod, err := doc.Parse(path, path, src); err == nil {
+		b = mod.Lookup(tok.Literal)
+	}
+	if b == nil {
+		return nil
+	}
+	rng := Range{lines.offsetPosition(tok.Offset), lines.offsetPosition(tok.End)}
+	return &HoverResult{
+		Contents: MarkupContent{Kind: "markdown", Value: hoverText(name, b)},
+		Range:    &rng,
+	}
+}
+
+// hoverText renders the documentation of b, bound as name, in Markdown.
+func hoverText(name string, b *doc.Binding) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "```org\n%s : %s\n```\n", name, b.Kind)
+	if b.Deprecated {
+		sb.WriteString("\n**Deprecated**")
+		if b.DeprecatedNote != "" {
+			sb.WriteString(": " + b.DeprecatedNote)
+		}
+		sb.WriteString("\n")
+	}
+	if b.Doc != "" {
+		sb.WriteString("\n" + b.Doc + "\n")
+	}
+	if len(b.Params) > 0 {
+		sb.WriteString("\n**Parameters**\n\n")
+		for _, p := range b.Params {
+			fmt.Fprintf(&sb, "- `%s` %s\n", p.Name, p.Desc)
+		}
+	}
+	if b.Returns != "" {
+		sb.WriteString("\n**Returns** " + b.Returns + "\n")
+	}
+	for _, e := range b.Examples {
+		sb.WriteString("\n```org\n" + e + "\n```\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,259 @@
+package lsp
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// indexFormat is saved with every index; an index of another format is
+// discarded and the workspace indexed again.
+const indexFormat = 1
+
+// maxWorkspaceSymbols bounds the answer to a workspace/symbol request,
+// best matches first.
+const maxWorkspaceSymbols = 200
+
+// Index holds the top-level symbols of every module of a workspace, the
+// .org files below its root, for workspace/symbol. It persists to a file
+// as JSON: opening it again analyzes only the files whose modification
+// time or size changed since, and a saved document updates its entry
+// alone.
+type Index struct {
+	Root string `json:"-"`
+	path string // where the index is saved; "" keeps it in memory
+
+	Format int                     `json:"format"`
+	Files  map[string]*indexedFile `json:"files"` // by path relative to Root, slash-separated
+}
+
+type indexedFile struct {
+	ModTime time.Time        `json:"modTime"`
+	Size    int64            `json:"size"`
+	Symbols []DocumentSymbol `json:"symbols"`
+}
+
+// IndexFile returns the file, in dir, holding the index of the workspace
+// root: one file per workspace, named by a hash of its path.
+func IndexFile(dir, root string) string {
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])[:16]+".json")
+}
+
+// OpenIndex returns the index of the workspace root saved in path, if
+// any, brought up to date with the files (see Refresh). path may be "" to
+// keep the index in memory only.
+func OpenIndex(root, path string) *Index {
+	x := &Index{Root: root, path: path}
+	if data, err := os.ReadFile(path); err == nil {
+		// A damaged or outdated index is rebuilt.
+		if json.Unmarshal(data, x) != nil || x.Format != indexFormat {
+			x.Files = nil
+		}
+	}
+	x.Format = indexFormat
+	if x.Files == nil {
+		x.Files = map[string]*indexedFile{}
+	}
+	x.Refresh()
+	return x
+}
+
+// Refresh analyzes the .org files below Root that are new or changed
+// since they were indexed and forgets those that are gone, then saves the
+// index if anything changed. Hidden directories are skipped.
+func (x *Index) Refresh() {
+	changed := false
+	seen := map[string]bool{}
+	filepath.WalkDir(x.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != x.Root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".org" {
+			return nil
+		}
+		rel, err := x.rel(path)
+		if err != nil {
+			return nil
+		}
+		seen[rel] = true
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if f, ok := x.Files[rel]; ok && f.ModTime.Equal(info.ModTime()) && f.Size == info.Size() {
+			return nil
+		}
+		if x.index(rel, path) {
+			changed = true
+		}
+		return nil
+	})
+	for rel := range x.Files {
+		if !seen[rel] {
+			delete(x.Files, rel)
+			changed = true
+		}
+	}
+	if changed {
+		x.save()
+	}
+}
+
+// Update indexes the file at path again, as a document saved by the
+// client, and saves the index. Files outside Root, or not .org files,
+// are not indexed.
+func (x *Index) Update(path string) {
+	rel, err := x.rel(path)
+	if err != nil || filepath.Ext(path) != ".org" {
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		delete(x.Files, rel)
+	} else if !x.index(rel, path) {
+		return
+	}
+	x.save()
+}
+
+// index reads and analyzes the file at path, entered as rel.
+func (x *Index) index(rel, path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	x.Files[rel] = &indexedFile{ModTime: info.ModTime(), Size: info.Size(), Symbols: Symbols(src)}
+	return true
+}
+
+// rel returns the key of path, which must be below Root.
+func (x *Index) rel(path string) (string, error) {
+	rel, err := filepath.Rel(x.Root, path)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsLocal(rel) {
+		return "", fs.ErrInvalid
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// save writes the index to its file, through a temporary file renamed
+// into place so a crash never leaves half an index. Failing to save only
+// costs the next start its speed.
+func (x *Index) save() {
+	if x.path == "" {
+		return
+	}
+	data, err := json.Marshal(x)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(x.path), 0o755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(x.path), filepath.Base(x.path)+".tmp*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), x.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// Search returns the symbols whose name matches query fuzzily: holds its
+// characters in order, ignoring case. Exact matches come first, then
+// prefixes, substrings and the others, shorter names first within each;
+// an empty query matches every symbol.
+func (x *Index) Search(query string) []SymbolInformation {
+	type match struct {
+		rank int
+		sym  SymbolInformation
+	}
+	var matches []match
+	for rel, f := range x.Files {
+		for _, s := range f.Symbols {
+			rank, ok := fuzzyRank(query, s.Name)
+			if !ok {
+				continue
+			}
+			matches = append(matches, match{rank, SymbolInformation{
+				Name:          s.Name,
+				Kind:          s.Kind,
+				Location:      Location{URI: fileURI(filepath.Join(x.Root, filepath.FromSlash(rel))), Range: s.SelectionRange},
+				ContainerName: rel,
+			}})
+		}
+	}
+	slices.SortFunc(matches, func(a, b match) int {
+		return cmp.Or(
+			cmp.Compare(a.rank, b.rank),
+			cmp.Compare(len(a.sym.Name), len(b.sym.Name)),
+			strings.Compare(a.sym.Name, b.sym.Name),
+			strings.Compare(a.sym.ContainerName, b.sym.ContainerName),
+			cmp.Compare(a.sym.Location.Range.Start.Line, b.sym.Location.Range.Start.Line),
+		)
+	})
+	out := make([]SymbolInformation, 0, min(len(matches), maxWorkspaceSymbols))
+	for _, m := range matches[:min(len(matches), maxWorkspaceSymbols)] {
+		out = append(out, m.sym)
+	}
+	return out
+}
+
+// fuzzyRank tells whether name matches query, and how well: 0 for the
+// same name, 1 for a prefix, 2 for a substring, 3 for a subsequence.
+func fuzzyRank(query, name string) (int, bool) {
+	q, n := strings.ToLower(query), strings.ToLower(name)
+	switch {
+	case q == n:
+		return 0, true
+	case strings.HasPrefix(n, q):
+		return 1, true
+	case strings.Contains(n, q):
+		return 2, true
+	}
+	rest := []rune(n)
+	for _, r := range q {
+		i := slices.Index(rest, r)
+		if i < 0 {
+			return 0, false
+		}
+		rest = rest[i+1:]
+	}
+	return 3, true
+}
+
+// fileURI returns the file: URI of an absolute path.
+func fileURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // C:/dir/x.org
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
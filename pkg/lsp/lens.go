@@ -0,0 +1,117 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"orglang/pkg/eval"
+)
+
+// Commands the code lenses run through workspace/executeCommand.
+const (
+	CommandRun  = "org.run"  // arguments: the document URI
+	CommandTest = "org.test" // arguments: the document URI and the test name
+)
+
+// Message types of window/showMessage and window/logMessage.
+const (
+	MessageError = 1
+	MessageInfo  = 3
+)
+
+// CodeLenses returns the lenses of the document uri: "run" over its main
+// binding and "test" over each test_ or golden_ binding, the tests of org
+// test. Only
+// top-level bindings count, as for the commands.
+func CodeLenses(uri string, src []byte) []CodeLens {
+	var lenses []CodeLens
+	for _, sym := range Symbols(src) {
+		switch {
+		case sym.Name == "main":
+			lenses = append(lenses, CodeLens{Range: sym.SelectionRange, Command: &Command{
+				Title:     "▶ run",
+				Command:   CommandRun,
+				Arguments: []any{uri},
+			}})
+		case strings.HasPrefix(sym.Name, eval.TestPrefix) || strings.HasPrefix(sym.Name, eval.GoldenPrefix):
+			lenses = append(lenses, CodeLens{Range: sym.SelectionRange, Command: &Command{
+				Title:     "▶ test",
+				Command:   CommandTest,
+				Arguments: []any{uri, sym.Name},
+			}})
+		}
+	}
+	return lenses
+}
+
+// commandArgs returns the arguments of the org command that runs cmd, and
+// the file it runs: org run on the document, or org test on it, filtered
+// to the one test.
+func commandArgs(p executeCommandParams) (args []string, path string, err error) {
+	var uri, name string
+	if len(p.Arguments) > 0 {
+		json.Unmarshal(p.Arguments[0], &uri)
+	}
+	if path = uriPath(uri); path == "" {
+		return nil, "", fmt.Errorf("%s needs the URI of a file", p.Command)
+	}
+	switch p.Command {
+	case CommandRun:
+		return []string{"run", path}, path, nil
+	case CommandTest:
+		if len(p.Arguments) > 1 {
+			json.Unmarshal(p.Arguments[1], &name)
+		}
+		if name == "" {
+			return nil, "", errors.New(CommandTest + " needs the name of a test")
+		}
+		return []string{"test", "--filter", "^" + regexp.QuoteMeta(name) + "$", path}, path, nil
+	}
+	return nil, "", fmt.Errorf("unknown command %q", p.Command)
+}
+
+// execute runs the org binary with args in the directory of path, in the
+// background, and reports the outcome to the client: the output in the
+// log, a summary as a message. The file is run as saved.
+func (s *Server) execute(args []string, path string) {
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		c := exec.CommandContext(s.ctx, s.Org, args...)
+		c.Dir = filepath.Dir(path)
+		var out bytes.Buffer
+		c.Stdout, c.Stderr = &out, &out
+		err := c.Run()
+
+		title := "org " + strings.Join(args[:len(args)-1], " ") + " " + filepath.Base(path)
+		kind, summary := MessageInfo, title+": ok"
+		if err != nil {
+			kind, summary = MessageError, title+": "+err.Error()
+		}
+		for _, m := range [][2]string{
+			{"window/logMessage", title + "\n" + out.String()},
+			{"window/showMessage", summary},
+		} {
+			err := s.send(map[string]any{
+				"jsonrpc": "2.0",
+				"method":  m[0],
+				"params":  map[string]any{"type": kind, "message": m[1]},
+			})
+			if err != nil {
+				s.log.Print(err)
+			}
+		}
+	}()
+}
+
+// stopCommands stops the commands still running and waits for them.
+func (s *Server) stopCommands() {
+	s.cancel()
+	s.running.Wait()
+}
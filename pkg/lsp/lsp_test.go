@@ -0,0 +1,538 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/eval"
+)
+
+func TestDiagnostics(t *testing.T) {
+	src := "x : 1;\ny : (2 + ;\nz : undefined_name;\n"
+	diags := Diagnostics("", []byte(src))
+	if len(diags) == 0 {
+		t.Fatal("no diagnostics")
+	}
+	for _, d := range diags {
+		if d.Severity != SeverityError || d.Source != "org" || d.Message == "" {
+			t.Errorf("unexpected diagnostic %+v", d)
+		}
+		if d.Range.Start.Line == 0 {
+			t.Errorf("diagnostic on the valid first line: %+v", d)
+		}
+	}
+	var undefined bool
+	for _, d := range diags {
+		if strings.Contains(d.Message, "undefined_name") {
+			undefined = true
+			want := Range{Position{2, 4}, Position{2, 18}}
+			if d.Range != want {
+				t.Errorf("range of %q = %+v, want %+v", d.Message, d.Range, want)
+			}
+		}
+	}
+	if !undefined {
+		t.Errorf("undefined identifier not reported: %+v", diags)
+	}
+
+	if diags := Diagnostics("", []byte("x : 1;\n")); len(diags) != 0 {
+		t.Errorf("clean source has diagnostics: %+v", diags)
+	}
+}
+
+func TestDiagnosticsLint(t *testing.T) {
+	// A bidirectional override inside a string is an error of the unicode lint.
+	diags := Diagnostics("", []byte("x : \"a‮b\";\n"))
+	if len(diags) != 1 || diags[0].Code != "unicode" || diags[0].Severity != SeverityError {
+		t.Errorf("diagnostics = %+v, want one unicode error", diags)
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	src := `"""Module doc."""
+m : "lib.org" @ org;
+
+"""Adds."""
+add : { left + right };
+neg : { right * -1 };
+answer : 42;
+log @: [next: { right }];
+t : [a: 1 b: [c: 2]];
+`
+	symbols := Symbols([]byte(src))
+	want := []struct {
+		name string
+		kind int
+		line int
+	}{
+		{"m", SymbolModule, 1},
+		{"add", SymbolOperator, 4},
+		{"neg", SymbolFunction, 5},
+		{"answer", SymbolVariable, 6},
+		{"log", SymbolObject, 7},
+		{"t", SymbolVariable, 8},
+	}
+	if len(symbols) != len(want) {
+		t.Fatalf("got %d symbols, want %d: %+v", len(symbols), len(want), symbols)
+	}
+	for i, w := range want {
+		s := symbols[i]
+		if s.Name != w.name || s.Kind != w.kind || s.SelectionRange.Start.Line != w.line {
+			t.Errorf("symbol %d = %s kind %d line %d, want %s kind %d line %d",
+				i, s.Name, s.Kind, s.SelectionRange.Start.Line, w.name, w.kind, w.line)
+		}
+	}
+	add := symbols[1]
+	if add.Range != (Range{Position{4, 0}, Position{4, 23}}) {
+		t.Errorf("range of add = %+v", add.Range)
+	}
+	if add.SelectionRange != (Range{Position{4, 0}, Position{4, 3}}) {
+		t.Errorf("selection range of add = %+v", add.SelectionRange)
+	}
+
+	// The outline survives a parse error.
+	symbols = Symbols([]byte("a : 1;\nb : (;\nc : 2;\n"))
+	if len(symbols) != 3 || symbols[2].Name != "c" {
+		t.Errorf("symbols with a parse error = %+v", symbols)
+	}
+}
+
+func TestPositionsCountUTF16(t *testing.T) {
+	src := []byte("s : \"é😀\"; x : 1;\n")
+	x := newLineIndex(src)
+	// x is the 11th rune of the line: é is one UTF-16 unit, 😀 two.
+	if got := x.position(1, 11); got != (Position{0, 11}) {
+		t.Errorf("position(1, 11) = %+v", got)
+	}
+	if got := x.offsetPosition(bytes.IndexByte(src, 'x')); got != (Position{0, 11}) {
+		t.Errorf("offsetPosition(x) = %+v", got)
+	}
+	if got := x.offsetPosition(len(src)); got != (Position{1, 0}) {
+		t.Errorf("offsetPosition(end) = %+v", got)
+	}
+}
+
+// session runs the server over the given messages and returns what it wrote.
+func session(t *testing.T, msgs ...string) ([]map[string]any, error) {
+	t.Helper()
+	var in bytes.Buffer
+	for _, m := range msgs {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(m), m)
+	}
+	var out, logs bytes.Buffer
+	err := NewServer(&in, &out, &logs).Run()
+
+	var replies []map[string]any
+	r := bufio.NewReader(&out)
+	for {
+		body, rerr := readMessage(r)
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			t.Fatal(rerr)
+		}
+		var v map[string]any
+		if err := json.Unmarshal(body, &v); err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, v)
+	}
+	return replies, err
+}
+
+func TestSession(t *testing.T) {
+	replies, err := session(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"untitled:a","languageId":"org","version":1,"text":"x : (;"}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didChange","params":{"textDocument":{"uri":"untitled:a","version":2},"contentChanges":[{"text":"\"\"\"The answer.\"\"\"\nx : 1;"}]}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"textDocument/documentSymbol","params":{"textDocument":{"uri":"untitled:a"}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"textDocument/hover","params":{"textDocument":{"uri":"untitled:a"},"position":{"line":1,"character":0}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didClose","params":{"textDocument":{"uri":"untitled:a"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 7 {
+		t.Fatalf("got %d messages, want 7: %v", len(replies), replies)
+	}
+
+	caps := replies[0]["result"].(map[string]any)["capabilities"].(map[string]any)
+	if caps["documentSymbolProvider"] != true || caps["inlayHintProvider"] != true {
+		t.Errorf("capabilities = %v", caps)
+	}
+	diagnostics := func(m map[string]any) []any {
+		if m["method"] != "textDocument/publishDiagnostics" {
+			t.Fatalf("want publishDiagnostics, got %v", m)
+		}
+		return m["params"].(map[string]any)["diagnostics"].([]any)
+	}
+	if d := diagnostics(replies[1]); len(d) == 0 {
+		t.Error("no diagnostics for the open document")
+	}
+	if d := diagnostics(replies[2]); len(d) != 0 {
+		t.Errorf("diagnostics after the fix: %v", d)
+	}
+	symbols := replies[3]["result"].([]any)
+	if len(symbols) != 1 || symbols[0].(map[string]any)["name"] != "x" {
+		t.Errorf("symbols = %v", symbols)
+	}
+	if hover, _ := replies[4]["result"].(map[string]any); hover == nil ||
+		!strings.Contains(hover["contents"].(map[string]any)["value"].(string), "The answer.") {
+		t.Errorf("hover reply = %v", replies[4])
+	}
+	if d := diagnostics(replies[5]); len(d) != 0 {
+		t.Errorf("diagnostics after close: %v", d)
+	}
+	if result, ok := replies[6]["result"]; !ok || result != nil {
+		t.Errorf("shutdown reply = %v", replies[6])
+	}
+}
+
+func TestSessionErrors(t *testing.T) {
+	replies, err := session(t,
+		`{"jsonrpc":"2.0","id":1,"method":"textDocument/documentSymbol","params":{}}`,
+		`not json`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+	if err != ErrNoShutdown {
+		t.Errorf("exit without shutdown: err = %v", err)
+	}
+	if len(replies) != 2 {
+		t.Fatalf("got %d messages, want 2: %v", len(replies), replies)
+	}
+	for i, want := range []int{codeServerNotInitialized, codeParseError} {
+		if code := replies[i]["error"].(map[string]any)["code"]; code != float64(want) {
+			t.Errorf("reply %d: code %v, want %d", i, code, want)
+		}
+	}
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, src := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func names(symbols []SymbolInformation) []string {
+	var out []string
+	for _, s := range symbols {
+		out = append(out, s.ContainerName+":"+s.Name)
+	}
+	return out
+}
+
+func TestIndex(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"main.org":        "parse_config : 1;\nmain : { 0 };\n",
+		"lib/config.org":  "config : [a: 1];\nparse : { right };\n",
+		".git/hooks.org":  "hidden : 1;\n",
+		"notes/README.md": "parse : 1;\n",
+	})
+	path := IndexFile(t.TempDir(), root)
+
+	x := OpenIndex(root, path)
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"parse", []string{"lib/config.org:parse", "main.org:parse_config"}},
+		{"CONFIG", []string{"lib/config.org:config", "main.org:parse_config"}},
+		{"pcfg", []string{"main.org:parse_config"}},
+		{"hidden", nil},
+	}
+	for _, tt := range tests {
+		if got := names(x.Search(tt.query)); !slices.Equal(got, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+	if got := x.Search("main"); len(got) != 1 || got[0].Location.URI != fileURI(filepath.Join(root, "main.org")) ||
+		got[0].Location.Range != (Range{Position{1, 0}, Position{1, 4}}) {
+		t.Errorf("location of main = %+v", got)
+	}
+
+	// A saved file is indexed again on its own.
+	writeFiles(t, root, map[string]string{"lib/config.org": "settings : 1;\n"})
+	x.Update(filepath.Join(root, "lib", "config.org"))
+	if got := names(x.Search("settings")); !slices.Equal(got, []string{"lib/config.org:settings"}) {
+		t.Errorf("after the update, Search(settings) = %v", got)
+	}
+
+	// Reopened, the index keeps the entries of unchanged files: this one
+	// changed behind its back, keeping its size and modification time.
+	main := filepath.Join(root, "main.org")
+	info, _ := os.Stat(main)
+	writeFiles(t, root, map[string]string{"main.org": "parse_CONFIG : 1;\nmain : { 0 };\n"})
+	os.Chtimes(main, info.ModTime(), info.ModTime())
+	os.Remove(filepath.Join(root, "lib", "config.org"))
+	x = OpenIndex(root, path)
+	if got := names(x.Search("")); !slices.Equal(got, []string{"main.org:main", "main.org:parse_config"}) {
+		t.Errorf("reopened index = %v", got)
+	}
+
+	// A damaged index is rebuilt.
+	os.WriteFile(path, []byte("{"), 0o644)
+	x = OpenIndex(root, path)
+	if got := names(x.Search("parse")); !slices.Equal(got, []string{"main.org:parse_CONFIG"}) {
+		t.Errorf("rebuilt index = %v", got)
+	}
+}
+
+func TestSessionWorkspaceSymbols(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.org": "alpha : 1;\n"})
+	uri := fileURI(root)
+	replies, err := session(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"rootUri":"`+uri+`","capabilities":{}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"workspace/symbol","params":{"query":"alp"}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"workspace/symbol","params":{"query":"bet"}}`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, root, map[string]string{"b.org": "beta : 1;\n"})
+	caps := replies[0]["result"].(map[string]any)["capabilities"].(map[string]any)
+	if caps["workspaceSymbolProvider"] != true {
+		t.Errorf("capabilities = %v", caps)
+	}
+	symbols := replies[1]["result"].([]any)
+	if len(symbols) != 1 || symbols[0].(map[string]any)["name"] != "alpha" {
+		t.Errorf("symbols = %v", symbols)
+	}
+	if symbols := replies[2]["result"].([]any); len(symbols) != 0 {
+		t.Errorf("symbols of a missing name = %v", symbols)
+	}
+
+	// The first workspace folder is the root; a saved document is indexed
+	// again (see TestIndex).
+	replies, err = session(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"workspaceFolders":[{"uri":"`+uri+`","name":"w"}],"capabilities":{}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didSave","params":{"textDocument":{"uri":"`+fileURI(filepath.Join(root, "b.org"))+`"}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"workspace/symbol","params":{"query":"bet"}}`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if symbols := replies[1]["result"].([]any); len(symbols) != 1 {
+		t.Errorf("symbols after save = %v", symbols)
+	}
+}
+
+func TestInlayHints(t *testing.T) {
+	src := "dot : 700{ left * right }701;\nneg : { 0 - right };\nsum : 1 dot 2 + neg 3;\nk : { 42 };\nt : [1 2] -> { right + 1 };\n"
+	all := Range{Position{0, 0}, Position{5, 0}}
+	hints := InlayHints("", []byte(src), all)
+	want := []struct {
+		pos   Position
+		label string
+	}{
+		{Position{0, 10}, "left, right:"},
+		{Position{1, 7}, "right:"},
+		{Position{2, 11}, "lbp 700 rbp 701"},
+		{Position{2, 19}, "bp 100"},
+		{Position{4, 14}, "right:"},
+	}
+	if len(hints) != len(want) {
+		t.Fatalf("got %d hints, want %d: %+v", len(hints), len(want), hints)
+	}
+	for i, w := range want {
+		if h := hints[i]; h.Position != w.pos || h.Label != w.label {
+			t.Errorf("hint %d = %q at %+v, want %q at %+v", i, h.Label, h.Position, w.label, w.pos)
+		}
+	}
+	if hints[2].Tooltip != "dot: infix operator (lbp 700, rbp 701)" {
+		t.Errorf("tooltip = %q", hints[2].Tooltip)
+	}
+
+	// Only the hints within the range are returned.
+	hints = InlayHints("", []byte(src), Range{Position{2, 0}, Position{2, 30}})
+	if len(hints) != 2 || hints[0].Label != "lbp 700 rbp 701" {
+		t.Errorf("hints of line 3 = %+v", hints)
+	}
+}
+
+func TestHover(t *testing.T) {
+	dir := t.TempDir()
+	lib := "\"\"\"Doubles.\n@param right the number\n@returns twice right\n@deprecated use twice\"\"\"\ndouble : { right * 2 };\n"
+	if err := os.WriteFile(filepath.Join(dir, "lib.org"), []byte(lib), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "main.org")
+	src := "lib : \"lib.org\" @ org;\n\"\"\"The answer.\"\"\"\nx : 42;\ny : x + (1 -> lib.double);\n"
+
+	h := Hover(path, []byte(src), Position{3, 4})
+	if h == nil || h.Contents.Kind != "markdown" || !strings.Contains(h.Contents.Value, "x : value") ||
+		!strings.Contains(h.Contents.Value, "The answer.") {
+		t.Fatalf("hover of x = %+v", h)
+	}
+	if h.Range == nil || *h.Range != (Range{Position{3, 4}, Position{3, 5}}) {
+		t.Errorf("range = %+v", h.Range)
+	}
+
+	h = Hover(path, []byte(src), Position{3, 19})
+	if h == nil {
+		t.Fatal("no hover for lib.double")
+	}
+	for _, want := range []string{"lib.double : prefix operator", "**Deprecated**: use twice", "Doubles.", "- `right` the number", "**Returns** twice right"} {
+		if !strings.Contains(h.Contents.Value, want) {
+			t.Errorf("hover of lib.double lacks %q:\n%s", want, h.Contents.Value)
+		}
+	}
+
+	// Punctuation, a number and a file that does not parse have no
+	// documentation.
+	for _, pos := range []Position{{3, 2}, {3, 9}} {
+		if h := Hover(path, []byte(src), pos); h != nil {
+			t.Errorf("hover at %+v = %+v", pos, h)
+		}
+	}
+	if h := Hover(path, []byte("x : (;\nx"), Position{1, 0}); h != nil {
+		t.Errorf("hover in a bad file = %+v", h)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	src := []byte("a   :  1;\nf : {\nx:right;\n   x  *  2\n};\nb:2;\n")
+	tests := []struct {
+		name  string
+		edits []TextEdit
+		want  []TextEdit
+	}{
+		{"document", Format(src, nil), []TextEdit{{Range{Position{0, 0}, Position{6, 0}}, "a : 1;\nf : {\n    x : right;\n    x * 2\n};\nb : 2;\n"}}},
+		{"range", Format(src, &Range{Position{2, 1}, Position{2, 3}}), []TextEdit{{Range{Position{1, 0}, Position{5, 0}}, "f : {\n    x : right;\n    x * 2\n};\n"}}},
+		{"on type ;", FormatOnType(src, Position{5, 4}, ";"), []TextEdit{{Range{Position{5, 0}, Position{6, 0}}, "b : 2;\n"}}},
+		{"on type }", FormatOnType(src, Position{4, 1}, "}"), []TextEdit{{Range{Position{1, 0}, Position{5, 0}}, "f : {\n    x : right;\n    x * 2\n};\n"}}},
+		{"other character", FormatOnType(src, Position{5, 1}, "b"), nil},
+		{"formatted already", Format([]byte("a : 1;\n"), nil), nil},
+		{"syntax error", Format([]byte("a : (1;\n"), nil), nil},
+	}
+	for _, tt := range tests {
+		if !slices.Equal(tt.edits, tt.want) {
+			t.Errorf("%s: edits = %+v, want %+v", tt.name, tt.edits, tt.want)
+		}
+	}
+}
+
+func TestCodeLenses(t *testing.T) {
+	src := []byte("helper : 1;\nmain : { 0 };\ntest_one : helper = 1;\nf : { test_inner : 1; 2 };\ngolden_out : 1 -> @stdout;\n")
+	lenses := CodeLenses("file:///p/a_test.org", src)
+	var got []string
+	for _, l := range lenses {
+		got = append(got, fmt.Sprintf("%d %s %s %v", l.Range.Start.Line, l.Command.Title, l.Command.Command, l.Command.Arguments))
+	}
+	want := []string{
+		"1 ▶ run org.run [file:///p/a_test.org]",
+		"2 ▶ test org.test [file:///p/a_test.org test_one]",
+		"4 ▶ test org.test [file:///p/a_test.org golden_out]",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("lenses = %q, want %q", got, want)
+	}
+}
+
+func TestExecuteCommand(t *testing.T) {
+	echo, err := exec.LookPath("echo")
+	if err != nil {
+		t.Skip("no echo command")
+	}
+	dir := t.TempDir()
+	uri := fileURI(filepath.Join(dir, "a_test.org"))
+	var out, logs bytes.Buffer
+	s := NewServer(&bytes.Buffer{}, &out, &logs)
+	s.Org = echo
+	s.initialized = true
+	for i, params := range []string{
+		`{"command":"org.test","arguments":["` + uri + `","test_one"]}`,
+		`{"command":"org.run","arguments":["untitled:a"]}`,
+		`{"command":"org.build","arguments":["` + uri + `"]}`,
+	} {
+		id := json.RawMessage(strconv.Itoa(i + 1))
+		if err := s.handle(&message{ID: &id, Method: "workspace/executeCommand", Params: json.RawMessage(params)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.running.Wait()
+
+	var replies []map[string]any
+	r := bufio.NewReader(&out)
+	for {
+		body, err := readMessage(r)
+		if err == io.EOF {
+			break
+		}
+		var v map[string]any
+		json.Unmarshal(body, &v)
+		replies = append(replies, v)
+	}
+	if len(replies) != 5 {
+		t.Fatalf("got %d messages, want 5: %v", len(replies), replies)
+	}
+	var messages []string
+	for _, r := range replies {
+		switch {
+		case r["method"] != nil:
+			messages = append(messages, r["params"].(map[string]any)["message"].(string))
+		case r["id"].(float64) == 1 && r["error"] != nil:
+			t.Errorf("org.test: %v", r["error"])
+		case r["id"].(float64) > 1 && r["error"] == nil:
+			t.Errorf("reply %v: expected an error", r["id"])
+		}
+	}
+	path := filepath.Join(dir, "a_test.org")
+	want := "org test --filter ^test_one$ a_test.org\ntest --filter ^test_one$ " + path + "\n"
+	if !slices.Contains(messages, want) || !slices.Contains(messages, "org test --filter ^test_one$ a_test.org: ok") {
+		t.Errorf("messages = %q", messages)
+	}
+}
+
+func TestCoverageHints(t *testing.T) {
+	src := []byte("f : {\n  1\n};\ng : 2;\n")
+	dir, path := t.TempDir(), "/p/a_test.org"
+	span := func(from, to int) ast.Span {
+		return ast.Span{Start: ast.Pos{Offset: from}, End: ast.Pos{Offset: to}}
+	}
+	p := &eval.Profile{Source: path, Hash: eval.SourceHash(src), Statements: []eval.StatementCoverage{
+		{Span: span(0, 11), Ran: false},
+		{Span: span(8, 9), Ran: false}, // inside f: no hint of its own
+		{Span: span(13, 18), Ran: true},
+	}}
+	if err := eval.SaveProfile(dir, p); err != nil {
+		t.Fatal(err)
+	}
+	hints := CoverageHints(dir, path, src)
+	if len(hints) != 1 {
+		t.Fatalf("hints = %+v", hints)
+	}
+	h := hints[0]
+	if h.Range != (Range{Position{0, 0}, Position{2, 1}}) || h.Severity != SeverityHint || !slices.Equal(h.Tags, []int{DiagnosticUnnecessary}) {
+		t.Errorf("hint = %+v", h)
+	}
+	if hints := CoverageHints(dir, path, append(src, '\n')); hints != nil {
+		t.Errorf("hints of a changed source = %+v", hints)
+	}
+	if hints := CoverageHints(dir, "/p/other.org", src); hints != nil {
+		t.Errorf("hints without a profile = %+v", hints)
+	}
+}
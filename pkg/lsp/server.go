@@ -0,0 +1,402 @@
+// Package lsp implements the Language Server Protocol over a byte stream
+// for `org lsp`: the client opens and edits documents, and the server
+// publishes their diagnostics and answers document and workspace symbol,
+// hover, inlay hint, formatting and code lens requests, running the
+// commands of the lenses with the org binary.
+//
+// Documents are synchronized in full on every change and analyzed with
+// the lexer, parser and lints, the same checks as `org check`. Workspace
+// symbols come from an Index of the workspace's modules, kept on disk
+// and updated when a document is saved.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// ErrNoShutdown is returned by Run when the client sent exit without
+// shutdown; the process should then exit with status 1.
+var ErrNoShutdown = errors.New("exit before shutdown")
+
+// Server is a language server for one client.
+type Server struct {
+	in   *bufio.Reader
+	out  io.Writer
+	log  *log.Logger
+	docs map[string][]byte // open documents by URI
+
+	// IndexDir is where the symbol indexes of workspaces are saved, one
+	// file each (IndexFile); "" keeps them in memory only.
+	IndexDir string
+	index    *Index // of the workspace; nil without a root
+
+	// CoverageDir is where org test --coverage saves the coverage profiles
+	// of modules, shown as hints over the statements that did not run;
+	// "" shows none.
+	CoverageDir string
+
+	// Org is the org binary the code lenses run programs and tests with;
+	// "" disables them.
+	Org     string
+	ctx     context.Context // canceled when Run returns, stopping commands
+	cancel  context.CancelFunc
+	running sync.WaitGroup // commands still running
+	sending sync.Mutex     // commands report while messages are served
+
+	initialized bool
+	shutdown    bool
+}
+
+// NewServer returns a server reading messages from in and writing them
+// to out. Problems with the connection itself are logged to logw.
+func NewServer(in io.Reader, out io.Writer, logw io.Writer) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		in:     bufio.NewReader(in),
+		out:    out,
+		log:    log.New(logw, "org lsp: ", 0),
+		docs:   map[string][]byte{},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Run serves messages until the client sends exit or closes the input.
+// Commands still running then are stopped.
+func (s *Server) Run() error {
+	defer s.stopCommands()
+	for {
+		body, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			if err := s.reply(nil, nil, &responseError{codeParseError, err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrNoShutdown
+			}
+			return nil
+		}
+		if err := s.handle(&msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle dispatches one message. Only errors writing to the client are
+// returned; the others are answered or logged.
+func (s *Server) handle(msg *message) error {
+	if msg.ID == nil {
+		if msg.Method == "" {
+			return nil // a response to a request we did not send
+		}
+		if s.initialized && !s.shutdown {
+			if err := s.notify(msg.Method, msg.Params); err != nil {
+				s.log.Printf("%s: %v", msg.Method, err)
+			}
+		}
+		return nil
+	}
+
+	var result any
+	var rerr *responseError
+	switch {
+	case msg.Method == "initialize":
+		s.initialized = true
+		var p initializeParams
+		if err := json.Unmarshal(msg.Params, &p); err == nil {
+			if root := workspaceRoot(p); root != "" {
+				path := ""
+				if s.IndexDir != "" {
+					path = IndexFile(s.IndexDir, root)
+				}
+				s.index = OpenIndex(root, path)
+			}
+		}
+		capabilities := map[string]any{
+			"textDocumentSync": map[string]any{
+				"openClose": true,
+				"change":    1, // full
+				"save":      true,
+			},
+			"documentSymbolProvider":          true,
+			"workspaceSymbolProvider":         true,
+			"inlayHintProvider":               true,
+			"hoverProvider":                   true,
+			"documentFormattingProvider":      true,
+			"documentRangeFormattingProvider": true,
+			"documentOnTypeFormattingProvider": map[string]any{
+				"firstTriggerCharacter": onTypeTriggers[0],
+				"moreTriggerCharacter":  onTypeTriggers[1:],
+			},
+		}
+		if s.Org != "" {
+			capabilities["codeLensProvider"] = map[string]any{}
+			capabilities["executeCommandProvider"] = map[string]any{"commands": []string{CommandRun, CommandTest}}
+		}
+		result = map[string]any{
+			"capabilities": capabilities,
+			"serverInfo":   map[string]any{"name": "org"},
+		}
+	case !s.initialized || s.shutdown:
+		rerr = &responseError{codeServerNotInitialized, "server is not initialized or was shut down"}
+	case msg.Method == "shutdown":
+		s.shutdown = true
+	case msg.Method == "textDocument/documentSymbol":
+		var p documentParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			rerr = &responseError{codeInvalidParams, err.Error()}
+			break
+		}
+		src, ok := s.docs[p.TextDocument.URI]
+		if !ok {
+			rerr = &responseError{codeInvalidParams, "document is not open: " + p.TextDocument.URI}
+			break
+		}
+		symbols := Symbols(src)
+		if symbols == nil {
+			symbols = []DocumentSymbol{}
+		}
+		result = symbols
+	case msg.Method == "textDocument/hover":
+		var p textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			rerr = &responseError{codeInvalidParams, err.Error()}
+			break
+		}
+		src, ok := s.docs[p.TextDocument.URI]
+		if !ok {
+			rerr = &responseError{codeInvalidParams, "document is not open: " + p.TextDocument.URI}
+			break
+		}
+		// No documentation is a null result, not an error.
+		if h := Hover(uriPath(p.TextDocument.URI), src, p.Position); h != nil {
+			result = h
+		}
+	case msg.Method == "textDocument/inlayHint":
+		var p inlayHintParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			rerr = &responseError{codeInvalidParams, err.Error()}
+			break
+		}
+		src, ok := s.docs[p.TextDocument.URI]
+		if !ok {
+			rerr = &responseError{codeInvalidParams, "document is not open: " + p.TextDocument.URI}
+			break
+		}
+		hints := InlayHints(uriPath(p.TextDocument.URI), src, p.Range)
+		if hints == nil {
+			hints = []InlayHint{}
+		}
+		result = hints
+	case msg.Method == "textDocument/formatting" || msg.Method == "textDocument/rangeFormatting":
+		var p rangeParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			rerr = &responseError{codeInvalidParams, err.Error()}
+			break
+		}
+		src, ok := s.docs[p.TextDocument.URI]
+		if !ok {
+			rerr = &responseError{codeInvalidParams, "document is not open: " + p.TextDocument.URI}
+			break
+		}
+		if msg.Method == "textDocument/formatting" {
+			p.Range = nil
+		}
+		edits := Format(src, p.Range)
+		if edits == nil {
+			edits = []TextEdit{}
+		}
+		result = edits
+	case msg.Method == "textDocument/onTypeFormatting":
+		var p onTypeFormattingParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			rerr = &responseError{codeInvalidParams, err.Error()}
+			break
+		}
+		src, ok := s.docs[p.TextDocument.URI]
+		if !ok {
+			rerr = &responseError{codeInvalidParams, "document is not open: " + p.TextDocument.URI}
+			break
+		}
+		edits := FormatOnType(src, p.Position, p.Ch)
+		if edits == nil {
+			edits = []TextEdit{}
+		}
+		result = edits
+	case msg.Method == "textDocument/codeLens":
+		var p documentParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			rerr = &responseError{codeInvalidParams, err.Error()}
+			break
+		}
+		src, ok := s.docs[p.TextDocument.URI]
+		if !ok {
+			rerr = &responseError{codeInvalidParams, "document is not open: " + p.TextDocument.URI}
+			break
+		}
+		lenses := []CodeLens{}
+		if s.Org != "" {
+			lenses = append(lenses, CodeLenses(p.TextDocument.URI, src)...)
+		}
+		result = lenses
+	case msg.Method == "workspace/executeCommand":
+		var p executeCommandParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			rerr = &responseError{codeInvalidParams, err.Error()}
+			break
+		}
+		if s.Org == "" {
+			rerr = &responseError{codeInvalidRequest, "commands are disabled"}
+			break
+		}
+		args, path, err := commandArgs(p)
+		if err != nil {
+			rerr = &responseError{codeInvalidParams, err.Error()}
+			break
+		}
+		s.execute(args, path)
+	case msg.Method == "workspace/symbol":
+		var p workspaceSymbolParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			rerr = &responseError{codeInvalidParams, err.Error()}
+			break
+		}
+		symbols := []SymbolInformation{}
+		if s.index != nil {
+			symbols = s.index.Search(p.Query)
+		}
+		result = symbols
+	case msg.Method == "":
+		rerr = &responseError{codeInvalidRequest, "request without a method"}
+	default:
+		rerr = &responseError{codeMethodNotFound, "method not supported: " + msg.Method}
+	}
+	return s.reply(msg.ID, result, rerr)
+}
+
+// notify handles a notification.
+func (s *Server) notify(method string, params json.RawMessage) error {
+	switch method {
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return err
+		}
+		s.docs[p.TextDocument.URI] = []byte(p.TextDocument.Text)
+		return s.publish(p.TextDocument.URI, &p.TextDocument.Version)
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return err
+		}
+		if len(p.ContentChanges) == 0 {
+			return nil
+		}
+		s.docs[p.TextDocument.URI] = []byte(p.ContentChanges[len(p.ContentChanges)-1].Text)
+		return s.publish(p.TextDocument.URI, &p.TextDocument.Version)
+	case "textDocument/didSave":
+		var p documentParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return err
+		}
+		if path := uriPath(p.TextDocument.URI); s.index != nil && path != "" {
+			s.index.Update(path)
+		}
+		return nil
+	case "textDocument/didClose":
+		var p documentParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return err
+		}
+		delete(s.docs, p.TextDocument.URI)
+		return s.send(map[string]any{
+			"jsonrpc": "2.0",
+			"method":  "textDocument/publishDiagnostics",
+			"params":  publishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}},
+		})
+	}
+	// Other notifications ($/cancelRequest, workspace/didChangeConfiguration, ...) need no action.
+	return nil
+}
+
+// publish sends the diagnostics of the open document uri.
+func (s *Server) publish(uri string, version *int) error {
+	path := uriPath(uri)
+	diags := append(Diagnostics(path, s.docs[uri]), CoverageHints(s.CoverageDir, path, s.docs[uri])...)
+	if diags == nil {
+		diags = []Diagnostic{}
+	}
+	return s.send(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  "textDocument/publishDiagnostics",
+		"params":  publishDiagnosticsParams{URI: uri, Version: version, Diagnostics: diags},
+	})
+}
+
+func (s *Server) reply(id *json.RawMessage, result any, rerr *responseError) error {
+	resp := map[string]any{"jsonrpc": "2.0", "id": id}
+	if rerr != nil {
+		resp["error"] = rerr
+	} else {
+		// A null result is still a result: shutdown answers null.
+		resp["result"] = result
+	}
+	return s.send(resp)
+}
+
+func (s *Server) send(v any) error {
+	s.sending.Lock()
+	defer s.sending.Unlock()
+	if err := writeMessage(s.out, v); err != nil {
+		return fmt.Errorf("writing to client: %w", err)
+	}
+	return nil
+}
+
+// workspaceRoot returns the directory of the workspace the client opened:
+// its first folder, or else its root.
+func workspaceRoot(p initializeParams) string {
+	uri := p.RootURI
+	if len(p.WorkspaceFolders) > 0 {
+		uri = p.WorkspaceFolders[0].URI
+	}
+	if path := uriPath(uri); path != "" {
+		return path
+	}
+	return p.RootPath
+}
+
+// uriPath returns the file path of a file: URI, or "" for other schemes.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	path := u.Path
+	// file:///C:/dir/x.org
+	if runtime.GOOS == "windows" && strings.HasPrefix(path, "/") && len(path) > 2 && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
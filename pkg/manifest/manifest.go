@@ -0,0 +1,322 @@
+// Package manifest reads the project manifest, org.toml.
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// FileName is the name of the manifest at the root of a project.
+const FileName = "org.toml"
+
+// DepsDir is the directory, under the project root, where org get puts
+// the dependencies, each in a directory named after it.
+const DepsDir = "deps"
+
+// Manifest is the content of an org.toml file.
+type Manifest struct {
+	// Name is the project's, and of the binary org build makes of it.
+	Name string `toml:"name"`
+	// Version is the project's version, e.g. "0.1.0".
+	Version string `toml:"version"`
+	// Main is the entry point, the file org build compiles when given no
+	// input, relative to the project root.
+	Main string `toml:"main"`
+	// Lib is the module dependents import by the project's name,
+	// relative to the project root; Main stands for it when it is unset.
+	Lib string `toml:"lib"`
+	// Sources are the directories holding the project's modules,
+	// relative to the project root.
+	Sources []string `toml:"sources"`
+
+	// Lint sets the severity of lint rules by name ("error", "warning"
+	// or "off"), e.g. `deprecated = "error"` under [lint].
+	Lint map[string]string `toml:"lint"`
+
+	// Dependencies maps the logical names of the modules the project
+	// depends on to their sources, fetched by org get into DepsDir: a git
+	// URL, with the ref to check out after #, or the URL of a .tar.gz,
+	// .tgz or .zip archive.
+	Dependencies map[string]string `toml:"dependencies"`
+
+	// Use names the standard library modules the project's programs
+	// opt into, e.g. `use = ["math"]`.
+	Use []string `toml:"use"`
+
+	// CFlags, LDFlags and Libs are passed to the C compiler by org build
+	// before those of --cflags, --ldflags and --lib: compile flags,
+	// linker flags and the libraries to link against, e.g.
+	// `libs = ["m"]`.
+	CFlags  []string `toml:"cflags"`
+	LDFlags []string `toml:"ldflags"`
+	Libs    []string `toml:"libs"`
+
+	// Targets are the binaries org build --all makes, for projects
+	// shipping several tools, each an [[target]] table.
+	Targets []Target `toml:"target"`
+
+	// Path is the file the manifest was read from.
+	Path string `toml:"-"`
+}
+
+// Target is a binary of a project with several entry points.
+type Target struct {
+	// Name is the binary's, built into bin/<name> under the project
+	// root.
+	Name string `toml:"name"`
+	// Main is the entry point, relative to the project root.
+	Main string `toml:"main"`
+	// Optimize is the optimization level, when set, unless -O is given.
+	Optimize *int `toml:"optimize"`
+	// CFlags, LDFlags and Libs replace the project's, when set; those
+	// of the command line are still appended.
+	CFlags  []string `toml:"cflags"`
+	LDFlags []string `toml:"ldflags"`
+	Libs    []string `toml:"libs"`
+}
+
+// Dir returns the project root, the directory holding the manifest.
+func (m *Manifest) Dir() string {
+	return filepath.Dir(m.Path)
+}
+
+// Entry returns the path of the entry point, or "" if Main is not set.
+func (m *Manifest) Entry() string {
+	if m.Main == "" {
+		return ""
+	}
+	return filepath.Join(m.Dir(), filepath.FromSlash(m.Main))
+}
+
+// LibEntry returns the path of the module dependents import by name: Lib,
+// else the entry point, or "" if neither is set.
+func (m *Manifest) LibEntry() string {
+	if m.Lib == "" {
+		return m.Entry()
+	}
+	return filepath.Join(m.Dir(), filepath.FromSlash(m.Lib))
+}
+
+// Output returns the path of the binary org build makes of the project:
+// bin/<name> under the project root, or, without a name, the entry point
+// without its extension.
+func (m *Manifest) Output() string {
+	if m.Name != "" {
+		return filepath.Join(m.Dir(), "bin", m.Name)
+	}
+	entry := m.Entry()
+	return strings.TrimSuffix(entry, filepath.Ext(entry))
+}
+
+// TargetEntry returns the path of the entry point of t.
+func (m *Manifest) TargetEntry(t *Target) string {
+	return filepath.Join(m.Dir(), filepath.FromSlash(t.Main))
+}
+
+// TargetOutput returns the path of the binary of t: bin/<name> under the
+// project root.
+func (m *Manifest) TargetOutput(t *Target) string {
+	return filepath.Join(m.Dir(), "bin", t.Name)
+}
+
+// DepDir returns the directory of the dependency name.
+func (m *Manifest) DepDir(name string) string {
+	return filepath.Join(m.Dir(), DepsDir, name)
+}
+
+// ResolveImport returns the file of the import path when it is a logical
+// name: the name of a dependency, standing for its entry point (the main
+// key of its own org.toml, else <name>.org, else main.org), or that name
+// followed by a path within the dependency ("http/client.org").
+func (m *Manifest) ResolveImport(path string) (string, bool) {
+	name, rest, _ := strings.Cut(filepath.ToSlash(path), "/")
+	if _, ok := m.Dependencies[name]; !ok || filepath.IsAbs(path) {
+		return "", false
+	}
+	dir := m.DepDir(name)
+	if rest != "" {
+		return filepath.Join(dir, filepath.FromSlash(rest)), true
+	}
+	if dep, err := Load(filepath.Join(dir, FileName)); err == nil && dep.LibEntry() != "" {
+		return dep.LibEntry(), true
+	}
+	for _, entry := range []string{name + ".org", "main.org"} {
+		if info, err := os.Stat(filepath.Join(dir, entry)); err == nil && !info.IsDir() {
+			return filepath.Join(dir, entry), true
+		}
+	}
+	return filepath.Join(dir, name+".org"), true
+}
+
+// ResolveImport returns the file of the import path, written in the
+// module from, when it names a dependency of the project holding from
+// (see Manifest.ResolveImport).
+func ResolveImport(from, path string) (string, bool) {
+	m, err := Find(filepath.Dir(from))
+	if err != nil || m == nil {
+		return "", false
+	}
+	return m.ResolveImport(path)
+}
+
+// Scaffold returns the org.toml of a new project named name, with its
+// entry point src/main.org.
+func Scaffold(name string) []byte {
+	return scaffold(name, `# The entry point, built by `+"`org build`"+` without arguments.
+main = "src/main.org"`)
+}
+
+// ScaffoldLib returns the org.toml of a new library named name, without
+// an entry point: dependents import its module src/lib.org.
+func ScaffoldLib(name string) []byte {
+	return scaffold(name, `# The module dependents import by the project's name.
+lib = "src/lib.org"`)
+}
+
+func scaffold(name, entry string) []byte {
+	return fmt.Appendf(nil, `# The project manifest, read by org build, run and check.
+name = %q
+version = "0.1.0"
+
+%s
+# The directories holding the project's modules.
+sources = ["src"]
+
+# Standard library modules the programs use, e.g. ["math"].
+use = []
+
+# Modules fetched by `+"`org get`"+` into deps/, imported by name:
+# http = "https://example.com/org-http.git#v1.0.0"
+[dependencies]
+
+# Severities of the lint rules of org check: "error", "warning" or "off".
+[lint]
+`, name, entry)
+}
+
+// Load reads the manifest at path. Unknown keys are errors, so typos do
+// not go unnoticed, and so are targets org build --all could not build.
+func Load(path string) (*Manifest, error) {
+	var m Manifest
+	meta, err := toml.DecodeFile(path, &m)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("%s: unknown key %q", path, undecoded[0].String())
+	}
+	m.Path = path
+	if err := m.checkTargets(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// checkTargets checks that every target has a main and a name of its own
+// that can name a file in bin.
+func (m *Manifest) checkTargets() error {
+	seen := map[string]bool{}
+	for i, t := range m.Targets {
+		switch {
+		case t.Name == "":
+			return fmt.Errorf("target %d has no name", i+1)
+		case t.Name != filepath.Base(t.Name) || strings.ContainsAny(t.Name, `/\`) || t.Name == "." || t.Name == "..":
+			return fmt.Errorf("target %q: the name must be a file name", t.Name)
+		case t.Main == "":
+			return fmt.Errorf("target %q has no main", t.Name)
+		case seen[t.Name]:
+			return fmt.Errorf("target %q is declared twice", t.Name)
+		case t.Optimize != nil && (*t.Optimize < 0 || *t.Optimize > 3):
+			return fmt.Errorf("target %q: optimize must be 0 to 3, got %d", t.Name, *t.Optimize)
+		}
+		seen[t.Name] = true
+	}
+	return nil
+}
+
+// Find looks for org.toml in dir and its parents. It returns nil, nil
+// when there is none.
+func Find(dir string) (*Manifest, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return Load(path)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// CompareVersions compares the versions a and b, numbers separated by dots
+// with an optional leading v ("1.2", "v0.10.1"), and returns -1, 0 or +1
+// as a is older than, the same as or newer than b. Missing numbers are 0,
+// so 1.2 is 1.2.0. A pre-release, after a -, is older than its release
+// and pre-releases compare as strings: 1.0.0-rc1 < 1.0.0-rc2 < 1.0.0.
+func CompareVersions(a, b string) (int, error) {
+	na, pa, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	nb, pb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < max(len(na), len(nb)); i++ {
+		var x, y int
+		if i < len(na) {
+			x = na[i]
+		}
+		if i < len(nb) {
+			y = nb[i]
+		}
+		if x != y {
+			return cmpInt(x, y), nil
+		}
+	}
+	switch {
+	case pa == pb:
+		return 0, nil
+	case pa == "":
+		return 1, nil
+	case pb == "":
+		return -1, nil
+	}
+	return strings.Compare(pa, pb), nil
+}
+
+// parseVersion splits v into its numbers and its pre-release.
+func parseVersion(v string) ([]int, string, error) {
+	release, pre, _ := strings.Cut(strings.TrimPrefix(v, "v"), "-")
+	var nums []int
+	for _, f := range strings.Split(release, ".") {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 || strings.HasPrefix(f, "+") {
+			return nil, "", fmt.Errorf("invalid version %q (want numbers separated by dots, e.g. 1.2.0)", v)
+		}
+		nums = append(nums, n)
+	}
+	return nums, pre, nil
+}
+
+func cmpInt(x, y int) int {
+	if x < y {
+		return -1
+	}
+	return 1
+}
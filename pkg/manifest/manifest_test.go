@@ -0,0 +1,235 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	src := "use = [\"math\"]\ncflags = [\"-O2\", \"-DNDEBUG\"]\nlibs = [\"m\"]\n\n[lint]\ndeprecated = \"error\"\nunicode = \"off\"\n"
+	if err := os.WriteFile(filepath.Join(root, FileName), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(root, "lib", "util")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Find(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil || m.Dir() != root {
+		t.Fatalf("expected manifest in %s, got %+v", root, m)
+	}
+	if m.Lint["deprecated"] != "error" || m.Lint["unicode"] != "off" {
+		t.Errorf("lint: got %v", m.Lint)
+	}
+	if len(m.Use) != 1 || m.Use[0] != "math" {
+		t.Errorf("use: got %v", m.Use)
+	}
+	if len(m.CFlags) != 2 || len(m.LDFlags) != 0 || len(m.Libs) != 1 || m.Libs[0] != "m" {
+		t.Errorf("cflags %v, ldflags %v, libs %v", m.CFlags, m.LDFlags, m.Libs)
+	}
+}
+
+func TestFindNone(t *testing.T) {
+	m, err := Find(t.TempDir())
+	if err != nil || m != nil {
+		t.Errorf("expected no manifest, got %+v, %v", m, err)
+	}
+}
+
+func TestLoadUnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte("[lnit]\nunicode = \"off\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), `unknown key "lnit"`) {
+		t.Errorf("expected unknown key error, got %v", err)
+	}
+}
+
+func TestTargets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	src := `cflags = ["-O2"]
+
+[[target]]
+name = "fetch"
+main = "cmd/fetch/main.org"
+
+[[target]]
+name = "report"
+main = "cmd/report/main.org"
+optimize = 3
+cflags = []
+`
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Targets) != 2 {
+		t.Fatalf("targets: got %+v", m.Targets)
+	}
+	fetch, report := &m.Targets[0], &m.Targets[1]
+	if fetch.Optimize != nil || fetch.CFlags != nil {
+		t.Errorf("fetch: optimize %v, cflags %v", fetch.Optimize, fetch.CFlags)
+	}
+	if report.Optimize == nil || *report.Optimize != 3 || report.CFlags == nil || len(report.CFlags) != 0 {
+		t.Errorf("report: optimize %v, cflags %v", report.Optimize, report.CFlags)
+	}
+	if got, want := m.TargetEntry(fetch), filepath.Join(m.Dir(), "cmd", "fetch", "main.org"); got != want {
+		t.Errorf("TargetEntry() = %s, want %s", got, want)
+	}
+	if got, want := m.TargetOutput(report), filepath.Join(m.Dir(), "bin", "report"); got != want {
+		t.Errorf("TargetOutput() = %s, want %s", got, want)
+	}
+}
+
+func TestBadTargets(t *testing.T) {
+	for src, want := range map[string]string{
+		"[[target]]\nmain = \"a.org\"\n":                                                           "target 1 has no name",
+		"[[target]]\nname = \"a\"\n":                                                               `target "a" has no main`,
+		"[[target]]\nname = \"../a\"\nmain = \"a.org\"\n":                                          `target "../a": the name must be a file name`,
+		"[[target]]\nname = \"a\"\nmain = \"a.org\"\noptimize = 4\n":                               `target "a": optimize must be 0 to 3, got 4`,
+		"[[target]]\nname = \"a\"\nmain = \"a.org\"\n[[target]]\nname = \"a\"\nmain = \"b.org\"\n": `target "a" is declared twice`,
+		"[[target]]\nname = \"a\"\nmain = \"a.org\"\noptimise = 2\n":                               `unknown key "target.optimise"`,
+	} {
+		path := filepath.Join(t.TempDir(), FileName)
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: got %v, want %s", src, err, want)
+		}
+	}
+}
+
+func TestScaffold(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, FileName)
+	if err := os.WriteFile(path, Scaffold("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "hello" || m.Version != "0.1.0" || m.Main != "src/main.org" {
+		t.Errorf("got name %q, version %q, main %q", m.Name, m.Version, m.Main)
+	}
+	if len(m.Sources) != 1 || m.Sources[0] != "src" {
+		t.Errorf("sources: got %v", m.Sources)
+	}
+	if got, want := m.Entry(), filepath.Join(root, "src", "main.org"); got != want {
+		t.Errorf("Entry() = %s, want %s", got, want)
+	}
+	if got, want := m.Output(), filepath.Join(root, "bin", "hello"); got != want {
+		t.Errorf("Output() = %s, want %s", got, want)
+	}
+
+	m.Name = ""
+	if got, want := m.Output(), filepath.Join(root, "src", "main"); got != want {
+		t.Errorf("Output() without a name = %s, want %s", got, want)
+	}
+}
+
+func TestScaffoldLib(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, FileName)
+	if err := os.WriteFile(path, ScaffoldLib("units"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Main != "" || m.Entry() != "" {
+		t.Errorf("a library has no entry point, got main %q", m.Main)
+	}
+	if got, want := m.LibEntry(), filepath.Join(root, "src", "lib.org"); got != want {
+		t.Errorf("LibEntry() = %s, want %s", got, want)
+	}
+}
+
+func TestResolveImport(t *testing.T) {
+	root := t.TempDir()
+	src := "[dependencies]\nhttp = \"https://example.com/http.git#v1\"\njson = \"https://example.com/json.tar.gz\"\nutil = \"https://example.com/util.zip\"\nconv = \"https://example.com/conv.git\"\n"
+	files := map[string]string{
+		FileName:                   src,
+		"deps/http/org.toml":       "main = \"src/client.org\"\n",
+		"deps/http/src/client.org": "get : 1;\n",
+		"deps/json/json.org":       "parse : 1;\n",
+		"deps/util/main.org":       "id : 1;\n",
+		"deps/conv/org.toml":       "main = \"src/main.org\"\nlib = \"src/lib.org\"\n",
+		"deps/conv/src/lib.org":    "ratio : 1;\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	from := filepath.Join(root, "src", "main.org")
+
+	tests := []struct {
+		path string
+		want string // relative to root; "" when not a dependency
+	}{
+		{"http", "deps/http/src/client.org"},
+		{"json", "deps/json/json.org"},
+		{"util", "deps/util/main.org"},
+		{"conv", "deps/conv/src/lib.org"},
+		{"http/src/client.org", "deps/http/src/client.org"},
+		{"lib.org", ""},
+		{"other/x.org", ""},
+	}
+	for _, tt := range tests {
+		got, ok := ResolveImport(from, tt.path)
+		if tt.want == "" {
+			if ok {
+				t.Errorf("ResolveImport(%q) = %s, want no dependency", tt.path, got)
+			}
+			continue
+		}
+		if want := filepath.Join(root, filepath.FromSlash(tt.want)); !ok || got != want {
+			t.Errorf("ResolveImport(%q) = %s, %v, want %s", tt.path, got, ok, want)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"0.1.0", "0.1.0", 0},
+		{"1.2", "1.2.0", 0},
+		{"v1.2.0", "1.2.0", 0},
+		{"0.2.0", "0.10.0", -1},
+		{"1.0.1", "1.0.0", 1},
+		{"1.0.0-rc1", "1.0.0", -1},
+		{"1.0.0-rc1", "1.0.0-rc2", -1},
+		{"1.0.0", "0.9.9-rc1", 1},
+	}
+	for _, tt := range tests {
+		got, err := CompareVersions(tt.a, tt.b)
+		if err != nil || got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, %v, want %d", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+	for _, v := range []string{"", "1..2", "one", "1.-2", "1.+2"} {
+		if _, err := CompareVersions(v, "1.0.0"); err == nil {
+			t.Errorf("CompareVersions(%q, ...): no error", v)
+		}
+	}
+}
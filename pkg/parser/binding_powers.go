@@ -1,26 +1,57 @@
 package parser
 
+import (
+	"fmt"
+	"maps"
+)
+
 // BindingEntry represents the parsing rules for a specific identifier or operator.
 type BindingEntry struct {
-	LBP      int  // Left Binding Power (how tightly it binds to the left in LED)
-	RBP      int  // Right Binding Power (associativity in LED)
-	PrefixBP int  // Binding Power for NUD consumption (0 if not prefix)
-	IsPrefix bool // True if it can appear in prefix position (NUD) as an operator
-	IsInfix  bool // True if it can appear in infix position (LED)
+	LBP        int  // Left Binding Power (how tightly it binds to the left in LED)
+	RBP        int  // Right Binding Power (associativity in LED)
+	PrefixBP   int  // Binding Power for NUD consumption (0 if not prefix)
+	IsPrefix   bool // True if it can appear in prefix position (NUD) as an operator
+	IsInfix    bool // True if it can appear in infix position (LED)
+	IsResource bool // True if the name was defined with @:
+}
+
+// Kind describes how the parser classifies the entry, including its
+// binding powers, e.g. "infix operator (lbp 200, rbp 201)".
+func (e BindingEntry) Kind() string {
+	switch {
+	case e.IsPrefix && e.IsInfix:
+		return fmt.Sprintf("prefix/infix operator (prefix bp %d, lbp %d, rbp %d)", e.PrefixBP, e.LBP, e.RBP)
+	case e.IsPrefix:
+		return fmt.Sprintf("prefix operator (bp %d)", e.PrefixBP)
+	case e.IsInfix:
+		return fmt.Sprintf("infix operator (lbp %d, rbp %d)", e.LBP, e.RBP)
+	case e.IsResource:
+		return "resource"
+	}
+	return "value"
 }
 
 // BindingTable manages dynamic operator bindings.
+//
+// Tables returned by NewBindingTable are overlays on a shared, immutable
+// table of the default operators: registrations go to the overlay, so
+// creating a parser (per REPL line, module load, ...) does not rebuild
+// the defaults.
 type BindingTable struct {
 	entries map[string]BindingEntry
 	parent  *BindingTable // For scoped lookups (if we support scopes in the future)
 }
 
-func NewBindingTable() *BindingTable {
-	bt := &BindingTable{
-		entries: make(map[string]BindingEntry),
-	}
+// defaultBindings holds the built-in operators. It is never written to
+// after initialisation.
+var defaultBindings = func() *BindingTable {
+	bt := &BindingTable{}
 	bt.initDefaults()
 	return bt
+}()
+
+func NewBindingTable() *BindingTable {
+	return &BindingTable{parent: defaultBindings}
 }
 
 func (bt *BindingTable) Lookup(name string) (BindingEntry, bool) {
@@ -34,68 +65,95 @@ func (bt *BindingTable) Lookup(name string) (BindingEntry, bool) {
 	return BindingEntry{}, false
 }
 
+// set records entry in this table, shadowing any parent entry.
+func (bt *BindingTable) set(name string, entry BindingEntry) {
+	if bt.entries == nil {
+		bt.entries = make(map[string]BindingEntry)
+	}
+	bt.entries[name] = entry
+}
+
+// Entries returns the entries registered on bt itself, leaving out the
+// defaults it overlays, as a REPL session saves them.
+func (bt *BindingTable) Entries() map[string]BindingEntry {
+	return maps.Clone(bt.entries)
+}
+
+// Register records entry, such as one returned by Entries, under name.
+func (bt *BindingTable) Register(name string, entry BindingEntry) {
+	bt.set(name, entry)
+}
+
 func (bt *BindingTable) RegisterPrefix(name string, bp int) {
-	bt.entries[name] = BindingEntry{
+	bt.set(name, BindingEntry{
 		LBP:      0,
 		RBP:      0,
 		PrefixBP: bp,
 		IsPrefix: true,
 		IsInfix:  false,
-	}
+	})
 }
 
 func (bt *BindingTable) RegisterInfix(name string, lbp int) {
 	// Default left-associative: RBP = LBP
-	bt.entries[name] = BindingEntry{
+	bt.set(name, BindingEntry{
 		LBP:      lbp,
 		RBP:      lbp + 1,
 		PrefixBP: 0,
 		IsPrefix: false,
 		IsInfix:  true,
-	}
+	})
 }
 
 func (bt *BindingTable) RegisterInfixRightAssoc(name string, lbp int) {
 	// Right-associative: RBP = LBP - 1
-	bt.entries[name] = BindingEntry{
+	bt.set(name, BindingEntry{
 		LBP:      lbp,
 		RBP:      lbp - 1,
 		PrefixBP: 0,
 		IsPrefix: false,
 		IsInfix:  true,
-	}
+	})
 }
 
 func (bt *BindingTable) RegisterValue(name string) {
 	// Just a value, NUD returns Name(name)
-	bt.entries[name] = BindingEntry{
+	bt.set(name, BindingEntry{
 		LBP:      0,
 		RBP:      0,
 		PrefixBP: 0,
 		IsPrefix: false,
 		IsInfix:  false,
-	}
+	})
 }
 
 func (bt *BindingTable) RegisterDual(name string, prefixBP, infixLBP int) {
-	bt.entries[name] = BindingEntry{
+	bt.set(name, BindingEntry{
 		LBP:      infixLBP,
 		RBP:      infixLBP, // Default left-assoc for infix
 		PrefixBP: prefixBP,
 		IsPrefix: true,
 		IsInfix:  true,
-	}
+	})
+}
+
+// MarkResource flags an already registered name as a resource
+// definition (name @: value).
+func (bt *BindingTable) MarkResource(name string) {
+	entry, _ := bt.Lookup(name)
+	entry.IsResource = true
+	bt.set(name, entry)
 }
 
 // RegisterCustomInfix registers an operator with explicit LBP and RBP
 func (bt *BindingTable) RegisterCustomInfix(name string, lbp, rbp int) {
-	bt.entries[name] = BindingEntry{
+	bt.set(name, BindingEntry{
 		LBP:      lbp,
 		RBP:      rbp,
 		PrefixBP: 0,
 		IsPrefix: false,
 		IsInfix:  true,
-	}
+	})
 }
 
 func (bt *BindingTable) initDefaults() {
@@ -143,6 +201,10 @@ func (bt *BindingTable) initDefaults() {
 	bt.RegisterInfix("??", 125)
 	// Conditional (cond ? table)
 	bt.RegisterInfix("?", 100)
+	// Guard (cond !> value), below ? so either side may be a conditional
+	bt.RegisterInfix("!>", 90)
+	// Assertion (assert cond "message"), taking a whole guard condition
+	bt.RegisterPrefix("assert", 90)
 
 	// Extended Assignment (Right Assoc, same level as :)
 	bt.RegisterInfixRightAssoc(":+", 80)
@@ -169,6 +231,33 @@ func (bt *BindingTable) initDefaults() {
 	bt.RegisterPrefix("--", 900)
 	bt.RegisterPrefix("@", 900)
 
+	// Named builtins bind like user-defined prefix blocks.
+	bt.RegisterPrefix("yaml_parse", 100)
+	bt.RegisterPrefix("toml_parse", 100)
+	for _, name := range []string{
+		"glob", "walk", "path_join", "path_dir", "path_base", "path_ext",
+		"sha256", "sha256_bytes", "md5", "md5_bytes", "crc32", "crc32_bytes",
+		"prompt", "password", "read_key", "tty_size", "bold", "underline",
+		"batch", "window", "kv_open", "kv_items", "sqrt", "break",
+	} {
+		bt.RegisterPrefix(name, 100)
+	}
+	bt.RegisterInfix("fg", 100)
+	bt.RegisterInfix("bg", 100)
+	bt.RegisterInfix("deadline", 100)
+	bt.RegisterInfix("throttle", 100)
+	bt.RegisterInfix("kv_get", 100)
+	bt.RegisterInfix("kv_put", 100)
+	bt.RegisterInfix("kv_delete", 100)
+	bt.RegisterInfix("scan", 100)
+	bt.RegisterInfix("checkpoint", 100)
+	bt.RegisterInfix("loop", 100)
+	bt.RegisterInfix("config", 100)
+
+	// this is the innermost enclosing block, called like any user-defined
+	// block: `this (right - 1)` or `(left - 1) this right`.
+	bt.RegisterDual("this", 100, 100)
+
 	// @ is both prefix (resource instantiation) and infix (module loading).
 	// Both use BP 900 as per the parser plan.
 	bt.RegisterDual("@", 900, 900)
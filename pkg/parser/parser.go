@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"orglang/pkg/ast"
+	"orglang/pkg/diag"
 	"orglang/pkg/lexer"
 	"orglang/pkg/token"
 )
@@ -15,17 +16,47 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 	prevToken token.Token // Track previous token for adjacency checks
-	errors    []string
+	diags     []diag.Diagnostic
 	bpTable   *BindingTable
 	inTable   bool
+	strict    bool // undefined identifiers are errors, not just ErrorExpr nodes
+	keyNext   bool // the next identifier names a table key or resource, not a binding
+	guards    []guard
 }
 
-func New(l *lexer.Lexer) *Parser {
+// guard is a `!>` parsed, with its operator token; placed is set when
+// it turns out to be a statement of a block, the only place it may be.
+type guard struct {
+	expr   *ast.GuardExpr
+	tok    token.Token
+	placed bool
+}
+
+// Option configures a Parser.
+type Option func(*Parser)
+
+// WithStrict makes undefined identifiers hard errors reported by Errors().
+// Without it they only become ErrorExpr nodes, which suits the REPL where
+// names may be defined later; builds and `org check` parse strictly.
+func WithStrict(on bool) Option {
+	return func(p *Parser) { p.strict = on }
+}
+
+// WithBindings parses with bt instead of a fresh binding table, so
+// operators defined by an earlier parse (a previous REPL input) are known.
+// Definitions made by this parse are added to bt.
+func WithBindings(bt *BindingTable) Option {
+	return func(p *Parser) { p.bpTable = bt }
+}
+
+func New(l *lexer.Lexer, opts ...Option) *Parser {
 	p := &Parser{
 		l:       l,
-		errors:  []string{},
 		bpTable: NewBindingTable(),
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
 	p.nextToken()
 	p.nextToken()
 	return p
@@ -45,18 +76,58 @@ func (p *Parser) cur() token.Token {
 	return p.curToken
 }
 
+// Errors returns the parse errors as "line L:C: message" strings.
 func (p *Parser) Errors() []string {
-	return p.errors
+	errs := []string{}
+	for _, d := range p.diags {
+		errs = append(errs, d.String())
+	}
+	return errs
+}
+
+// Diagnostics returns the parse errors with their codes and spans.
+func (p *Parser) Diagnostics() []diag.Diagnostic {
+	return p.diags
+}
+
+// Bindings returns the binding table as populated by the parse so far.
+func (p *Parser) Bindings() *BindingTable {
+	return p.bpTable
 }
 
 func (p *Parser) addError(msg string) {
-	p.errors = append(p.errors, fmt.Sprintf("line %d:%d: %s", p.curToken.Line, p.curToken.Column, msg))
+	p.addErrorAt(p.curToken, msg)
+}
+
+func (p *Parser) addErrorAt(t token.Token, msg string) {
+	p.report(diag.Diagnostic{Code: diag.SyntaxError, Span: diag.At(t), Message: msg})
+}
+
+func (p *Parser) report(d diag.Diagnostic) {
+	d.Severity = diag.Error
+	p.diags = append(p.diags, d)
+}
+
+// span is the source range from the first byte of start to just past
+// the last token consumed.
+func (p *Parser) span(start token.Token) ast.Span {
+	end := p.prevToken
+	if end.End < start.Offset {
+		// Nothing consumed since start: an empty range at start.
+		end = token.Token{End: start.Offset, EndLine: start.Line, EndColumn: start.Column}
+	}
+	return ast.Span{
+		Start: ast.Pos{Line: start.Line, Column: start.Column, Offset: start.Offset},
+		End:   ast.Pos{Line: end.EndLine, Column: end.EndColumn, Offset: end.End},
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
 	prog := &ast.Program{
 		Statements: []ast.Statement{},
 	}
+	start := p.curToken
+	defer func() { prog.SetLocation(p.span(start)) }()
 
 	for p.curToken.Type != token.EOF {
 		if p.curToken.Type == token.SEMICOLON {
@@ -65,6 +136,7 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 
 		stmt := p.parseExpression(0)
+		p.checkGuards()
 		if stmt != nil {
 			if s, ok := stmt.(ast.Statement); ok {
 				prog.Statements = append(prog.Statements, s)
@@ -115,8 +187,11 @@ func (p *Parser) parseExpression(minBP int) ast.Expression {
 
 	left := p.nud(t)
 	if left == nil {
-		return &ast.ErrorExpr{Message: fmt.Sprintf("unexpected token %s (%q)", t.Type, t.Literal)}
+		left = &ast.ErrorExpr{Message: fmt.Sprintf("unexpected token %s (%q)", t.Type, t.Literal)}
+		left.SetLocation(p.span(t))
+		return left
 	}
+	left.SetLocation(p.span(t))
 
 	for {
 		lbp := p.getBindingPower(p.curToken)
@@ -127,12 +202,16 @@ func (p *Parser) parseExpression(minBP int) ast.Expression {
 		ledOp := p.curToken
 		p.nextToken() // Consume Operator
 		left = p.led(ledOp, left)
+		left.SetLocation(p.span(t))
 	}
 
 	return left
 }
 
 func (p *Parser) nud(t token.Token) ast.Expression {
+	isKey := p.keyNext
+	p.keyNext = false
+
 	switch t.Type {
 	case token.INTEGER:
 		if p.curToken.Type == token.LBRACE && p.areAdjacent(t, p.curToken) {
@@ -142,6 +221,8 @@ func (p *Parser) nud(t token.Token) ast.Expression {
 		return &ast.IntegerLiteral{Value: t.Literal}
 	case token.DECIMAL:
 		return &ast.DecimalLiteral{Value: t.Literal}
+	case token.DURATION, token.SIZE:
+		return quantityLiteral(t)
 	case token.RATIONAL:
 		parts := strings.Split(t.Literal, "/")
 		if len(parts) != 2 {
@@ -152,13 +233,15 @@ func (p *Parser) nud(t token.Token) ast.Expression {
 		isDoc := t.Type == token.DOCSTRING || t.Type == token.RAWDOC
 		isRaw := t.Type == token.RAWSTRING || t.Type == token.RAWDOC
 		return &ast.StringLiteral{Value: t.Literal, IsDoc: isDoc, IsRaw: isRaw}
+	case token.INTERP_START:
+		return p.parseInterpolation(t)
 	case token.BOOLEAN:
 		val := t.Literal == "true"
 		return &ast.BooleanLiteral{Value: val}
 	case token.IDENTIFIER, token.KEYWORD, token.AT:
-		return p.nudIdentifier(t)
+		return p.nudIdentifier(t, isKey)
 	case token.LPAREN:
-		expr := p.parseExpression(0)
+		expr := p.parseGrouped()
 		if p.curToken.Type == token.RPAREN {
 			p.nextToken()
 		} else {
@@ -168,6 +251,9 @@ func (p *Parser) nud(t token.Token) ast.Expression {
 	case token.LBRACE:
 		return p.parseFunctionLiteral(nil)
 	case token.LBRACKET:
+		if p.atPattern() {
+			return p.parsePattern()
+		}
 		return p.parseTableLiteral()
 	case token.ILLEGAL:
 		return &ast.ErrorExpr{Message: t.Literal}
@@ -175,15 +261,40 @@ func (p *Parser) nud(t token.Token) ast.Expression {
 	return nil
 }
 
-func (p *Parser) nudIdentifier(t token.Token) ast.Expression {
+// parseAssert parses `assert cond "message"` after assert: the condition
+// binds like that of a guard, and the message, a string that may be
+// interpolated, is optional.
+func (p *Parser) parseAssert(bp int) ast.Expression {
+	a := &ast.AssertExpr{Cond: p.parseExpression(bp)}
+	switch p.curToken.Type {
+	case token.STRING, token.RAWSTRING, token.INTERP_START:
+		a.Message = p.parseExpression(PREFIX)
+	}
+	return a
+}
+
+// nudIdentifier parses a name. isKey is set for the name after `.` or
+// `@`, which is a table key or resource rather than a binding reference.
+func (p *Parser) nudIdentifier(t token.Token, isKey bool) ast.Expression {
 	name := t.Literal
 	entry, ok := p.bpTable.Lookup(name)
 
+	if (name == "this" || ok && entry.IsPrefix && name != "@") && p.operandMissing() {
+		// An operator as a value, e.g. passed on or composed:
+		// `apply this;`, `double o inc`.
+		return &ast.Name{Value: name}
+	}
+
+	if name == "assert" && ok && entry.IsPrefix {
+		return p.parseAssert(entry.PrefixBP)
+	}
+
 	if ok && entry.IsPrefix {
 		bp := entry.PrefixBP
 		if bp == 0 {
 			bp = PREFIX
 		}
+		p.keyNext = name == "@"
 		right := p.parseExpression(bp)
 		return &ast.PrefixExpr{Op: name, Right: right}
 	}
@@ -198,10 +309,19 @@ func (p *Parser) nudIdentifier(t token.Token) ast.Expression {
 		if p.curToken.Type == token.IDENTIFIER && strings.HasPrefix(p.curToken.Literal, ":") {
 			return &ast.Name{Value: name}
 		}
-		if name == "left" || name == "right" || name == "this" {
+		if name == "left" || name == "right" || name == "this" || isKey {
 			return &ast.Name{Value: name}
 		}
-		return &ast.ErrorExpr{Message: fmt.Sprintf("undefined identifier: %s", name)}
+		msg := fmt.Sprintf("undefined identifier: %s", name)
+		if p.strict {
+			p.report(diag.Diagnostic{
+				Code:    diag.UndefinedIdentifier,
+				Span:    diag.At(t),
+				Message: msg,
+				Hint:    fmt.Sprintf("bind it before this use, as in `%s : ...`, or import the module defining it", name),
+			})
+		}
+		return &ast.ErrorExpr{Message: msg}
 	}
 
 	return &ast.Name{Value: name}
@@ -214,6 +334,7 @@ func (p *Parser) led(t token.Token, left ast.Expression) ast.Expression {
 	case token.AT_COLON:
 		return p.ledBinding(left, true, ":")
 	case token.DOT:
+		p.keyNext = true
 		right := p.parseExpression(p.getBindingPower(t))
 		return &ast.DotExpr{Left: left, Key: right}
 	case token.ELVIS:
@@ -222,12 +343,19 @@ func (p *Parser) led(t token.Token, left ast.Expression) ast.Expression {
 	case token.COMMA:
 		right := p.parseExpression(60)
 		return &ast.CommaExpr{Left: left, Right: right}
-	case token.IDENTIFIER:
-		if t.Literal == "|>" {
-			return &ast.InfixExpr{Left: left, Op: "|>", Right: p.parseAtom()}
+	case token.IDENTIFIER, token.KEYWORD:
+		if t.Literal == "|>" || t.Literal == "o" {
+			start := p.curToken
+			right := p.parseAtom()
+			right.SetLocation(p.span(start))
+			return &ast.InfixExpr{Left: left, Op: t.Literal, Right: right}
 		}
-		if t.Literal == "o" {
-			return &ast.InfixExpr{Left: left, Op: "o", Right: p.parseAtom()}
+
+		if t.Literal == "!>" {
+			entry, _ := p.bpTable.Lookup(t.Literal)
+			g := &ast.GuardExpr{Cond: left, Value: p.parseExpression(entry.RBP)}
+			p.guards = append(p.guards, guard{expr: g, tok: t})
+			return g
 		}
 
 		// Check for extended assignment operators
@@ -242,6 +370,7 @@ func (p *Parser) led(t token.Token, left ast.Expression) ast.Expression {
 
 	case token.AT:
 		bp := 900
+		p.keyNext = true
 		right := p.parseExpression(bp)
 		return &ast.InfixExpr{Left: left, Op: "@", Right: right}
 	}
@@ -250,6 +379,14 @@ func (p *Parser) led(t token.Token, left ast.Expression) ast.Expression {
 }
 
 func (p *Parser) ledBinding(left ast.Expression, isResource bool, op string) ast.Expression {
+	// A function may call itself: make its name known while its body is
+	// parsed. registerBinding replaces the entry once the body is known.
+	if name, ok := left.(*ast.Name); ok && op == ":" && p.startsFunctionLiteral() {
+		if _, defined := p.bpTable.Lookup(name.Value); !defined {
+			p.bpTable.RegisterValue(name.Value)
+		}
+	}
+
 	// Colon is Right-associative. RBP = 79.
 	val := p.parseExpression(79)
 
@@ -262,6 +399,17 @@ func (p *Parser) ledBinding(left ast.Expression, isResource bool, op string) ast
 			} else {
 				p.bpTable.RegisterValue(name.Value)
 			}
+			if isResource {
+				p.bpTable.MarkResource(name.Value)
+			}
+		}
+	}
+
+	if pattern, ok := left.(*ast.TableLiteral); ok && op == ":" {
+		for _, el := range pattern.Elements {
+			if name, ok := el.(*ast.Name); ok {
+				p.bpTable.RegisterValue(name.Value)
+			}
 		}
 	}
 
@@ -272,8 +420,7 @@ func (p *Parser) ledBinding(left ast.Expression, isResource bool, op string) ast
 }
 
 func (p *Parser) registerBinding(name string, fl *ast.FunctionLiteral, isRes bool) {
-	usesLeft := bodyContainsName(fl.Body, "left")
-	usesRight := bodyContainsName(fl.Body, "right")
+	usesLeft, usesRight := Operands(fl)
 
 	lbp := 100
 	if fl.LBP != nil {
@@ -293,6 +440,13 @@ func (p *Parser) registerBinding(name string, fl *ast.FunctionLiteral, isRes boo
 	}
 }
 
+// Operands reports whether the body of fl refers to its left and right
+// operands. Nested blocks have their own operands and are not searched.
+// A block using both is binary, one using only right is unary.
+func Operands(fl *ast.FunctionLiteral) (left, right bool) {
+	return bodyContainsName(fl.Body, "left"), bodyContainsName(fl.Body, "right")
+}
+
 func bodyContainsName(stmts []ast.Statement, name string) bool {
 	for _, s := range stmts {
 		if nodeContainsName(s, name) {
@@ -325,8 +479,18 @@ func nodeContainsName(n ast.Node, name string) bool {
 		return nodeContainsName(v.Name, name)
 	case *ast.ElvisExpr:
 		return nodeContainsName(v.Left, name) || nodeContainsName(v.Right, name)
+	case *ast.GuardExpr:
+		return nodeContainsName(v.Cond, name) || nodeContainsName(v.Value, name)
+	case *ast.AssertExpr:
+		return nodeContainsName(v.Cond, name) || v.Message != nil && nodeContainsName(v.Message, name)
 	case *ast.CommaExpr:
 		return nodeContainsName(v.Left, name) || nodeContainsName(v.Right, name)
+	case *ast.InterpolatedString:
+		for _, e := range v.Exprs {
+			if nodeContainsName(e, name) {
+				return true
+			}
+		}
 	case *ast.TableLiteral:
 		for _, e := range v.Elements {
 			if nodeContainsName(e, name) {
@@ -346,7 +510,7 @@ func (p *Parser) parseAtom() ast.Expression {
 	switch t.Type {
 	case token.LPAREN:
 		p.nextToken()
-		inner := p.parseExpression(0)
+		inner := p.parseGrouped()
 		if p.curToken.Type == token.RPAREN {
 			p.nextToken()
 		} else {
@@ -358,13 +522,18 @@ func (p *Parser) parseAtom() ast.Expression {
 	case token.IDENTIFIER:
 		p.nextToken()
 		return &ast.Name{Value: t.Literal}
-	case token.INTEGER, token.DECIMAL, token.RATIONAL, token.STRING, token.DOCSTRING, token.RAWSTRING, token.RAWDOC, token.BOOLEAN:
+	case token.INTERP_START:
+		p.nextToken()
+		return p.parseInterpolation(t)
+	case token.INTEGER, token.DECIMAL, token.DURATION, token.SIZE, token.RATIONAL, token.STRING, token.DOCSTRING, token.RAWSTRING, token.RAWDOC, token.BOOLEAN:
 		p.nextToken()
 		switch t.Type {
 		case token.INTEGER:
 			return &ast.IntegerLiteral{Value: t.Literal}
 		case token.DECIMAL:
 			return &ast.DecimalLiteral{Value: t.Literal}
+		case token.DURATION, token.SIZE:
+			return quantityLiteral(t)
 		case token.RATIONAL:
 			parts := strings.Split(t.Literal, "/")
 			if len(parts) == 2 {
@@ -389,6 +558,12 @@ func (p *Parser) parseFunctionLiteral(lbp *int) *ast.FunctionLiteral {
 		p.nextToken()
 	}
 
+	// A block inside a table literal has its own statements, where
+	// operators are infix again.
+	prevInTable := p.inTable
+	p.inTable = false
+	defer func() { p.inTable = prevInTable }()
+
 	body := []ast.Statement{}
 
 	for p.curToken.Type != token.RBRACE && p.curToken.Type != token.EOF {
@@ -398,6 +573,9 @@ func (p *Parser) parseFunctionLiteral(lbp *int) *ast.FunctionLiteral {
 		}
 
 		stmt := p.parseExpression(0)
+		if g, ok := stmt.(*ast.GuardExpr); ok {
+			p.placeGuard(g)
+		}
 		if stmt != nil {
 			if s, ok := stmt.(ast.Statement); ok {
 				body = append(body, s)
@@ -421,6 +599,27 @@ func (p *Parser) parseFunctionLiteral(lbp *int) *ast.FunctionLiteral {
 	return &ast.FunctionLiteral{LBP: lbp, Body: body, RBP: rbp}
 }
 
+// placeGuard marks g as a statement of a block.
+func (p *Parser) placeGuard(g *ast.GuardExpr) {
+	for i := range p.guards {
+		if p.guards[i].expr == g {
+			p.guards[i].placed = true
+		}
+	}
+}
+
+// checkGuards reports the guards of the top-level statement just parsed
+// that are not statements of a block: at the top level or nested in an
+// expression, `!>` has no block to return from.
+func (p *Parser) checkGuards() {
+	for _, g := range p.guards {
+		if !g.placed {
+			p.addErrorAt(g.tok, "!> must be a statement of a block, returning from it")
+		}
+	}
+	p.guards = nil
+}
+
 func (p *Parser) parseTableLiteral() *ast.TableLiteral {
 	elements := []ast.Expression{}
 
@@ -454,6 +653,108 @@ func (p *Parser) parseTableLiteral() *ast.TableLiteral {
 	return &ast.TableLiteral{Elements: elements}
 }
 
+// atPattern reports whether the table literal just opened, its first
+// token current, is a destructuring pattern: names only, then `]` and
+// `:`, as in `[q r] : 7 divmod 2`. Its names are being bound, so they
+// must not be looked up as the elements of a table would.
+func (p *Parser) atPattern() bool {
+	if p.curToken.Type != token.IDENTIFIER {
+		return false
+	}
+	l := p.l.Clone()
+	t := p.peekToken
+	for t.Type == token.IDENTIFIER {
+		t = l.NextToken()
+	}
+	return t.Type == token.RBRACKET && l.NextToken().Type == token.COLON
+}
+
+// parsePattern parses the names of a destructuring pattern, after its
+// `[`, into a table literal of Names.
+func (p *Parser) parsePattern() *ast.TableLiteral {
+	pattern := &ast.TableLiteral{}
+	for p.curToken.Type == token.IDENTIFIER {
+		t := p.curToken
+		p.nextToken()
+		name := &ast.Name{Value: t.Literal}
+		name.SetLocation(p.span(t))
+		pattern.Elements = append(pattern.Elements, name)
+	}
+	p.nextToken() // ]
+	return pattern
+}
+
+// quantityLiteral splits a DURATION or SIZE token into its number and
+// its unit, the trailing letters.
+func quantityLiteral(t token.Token) *ast.QuantityLiteral {
+	i := strings.LastIndexFunc(t.Literal, func(r rune) bool { return r >= '0' && r <= '9' }) + 1
+	return &ast.QuantityLiteral{Value: t.Literal[:i], Unit: t.Literal[i:]}
+}
+
+// parseInterpolation parses "text${expr}text...", whose INTERP_START
+// token start has been consumed. Each ${ } holds one expression, parsed
+// like the inside of parentheses.
+func (p *Parser) parseInterpolation(start token.Token) ast.Expression {
+	n := &ast.InterpolatedString{Texts: []string{start.Literal}}
+	for {
+		if p.curToken.Type == token.INTERP_MID || p.curToken.Type == token.INTERP_END {
+			p.addError("empty interpolation ${}")
+			empty := &ast.ErrorExpr{Message: "empty interpolation"}
+			empty.SetLocation(p.span(p.curToken))
+			n.Exprs = append(n.Exprs, empty)
+		} else {
+			n.Exprs = append(n.Exprs, p.parseGrouped())
+		}
+		switch p.curToken.Type {
+		case token.INTERP_MID:
+			n.Texts = append(n.Texts, p.curToken.Literal)
+			p.nextToken()
+		case token.INTERP_END:
+			n.Texts = append(n.Texts, p.curToken.Literal)
+			p.nextToken()
+			return n
+		default:
+			p.addError("expected '}' closing string interpolation")
+			return n
+		}
+	}
+}
+
+// endsExpression reports whether t cannot start an operand.
+func (p *Parser) endsExpression(t token.Token) bool {
+	switch t.Type {
+	case token.EOF, token.SEMICOLON, token.RPAREN, token.RBRACE, token.RBRACKET, token.COMMA,
+		token.INTERP_MID, token.INTERP_END:
+		return true
+	}
+	return false
+}
+
+// operandMissing reports whether the current token cannot be the operand
+// of a prefix operator: it ends the expression or is `o` or `|>`.
+func (p *Parser) operandMissing() bool {
+	if p.endsExpression(p.curToken) {
+		return true
+	}
+	return p.curToken.Type == token.IDENTIFIER && (p.curToken.Literal == "o" || p.curToken.Literal == "|>")
+}
+
+// parseGrouped parses the expression inside parentheses. Operators are
+// infix there even within a table literal: [n: (1 + 2)].
+func (p *Parser) parseGrouped() ast.Expression {
+	prevInTable := p.inTable
+	p.inTable = false
+	defer func() { p.inTable = prevInTable }()
+	return p.parseExpression(0)
+}
+
+// startsFunctionLiteral reports whether the current token opens a
+// function literal: `{` or an `N{` left binding power.
+func (p *Parser) startsFunctionLiteral() bool {
+	return p.curToken.Type == token.LBRACE ||
+		(p.curToken.Type == token.INTEGER && p.peekToken.Type == token.LBRACE && p.areAdjacent(p.curToken, p.peekToken))
+}
+
 func (p *Parser) areAdjacent(t1, t2 token.Token) bool {
 	return t1.Line == t2.Line && (t1.Column+len(t1.Literal) == t2.Column)
 }
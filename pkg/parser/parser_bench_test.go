@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"orglang/pkg/lexer"
+)
+
+// Results of these benchmarks are recorded in testdata/bench.txt; update
+// it when changing the parser's hot path:
+//
+//	go test ./pkg/parser -run '^$' -bench . -benchmem -count 5
+
+// manyStatements returns n bindings, each using the previous one.
+func manyStatements(n int) string {
+	var b strings.Builder
+	b.WriteString("x0 : 0;\n")
+	for i := 1; i < n; i++ {
+		fmt.Fprintf(&b, "x%d : x%d + %d * 2;\n", i, i-1, i)
+	}
+	return b.String()
+}
+
+// deepPipeline returns a single pipeline of n stages.
+func deepPipeline(n int) string {
+	var b strings.Builder
+	b.WriteString("inc : { right + 1 };\nr : 0")
+	for i := 0; i < n; i++ {
+		b.WriteString(" |> { left + 1 }")
+	}
+	b.WriteString(";\n")
+	return b.String()
+}
+
+// giantTable returns a table literal of n keyed elements.
+func giantTable(n int) string {
+	var b strings.Builder
+	b.WriteString("t : [")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "k%d: %d ", i, i)
+	}
+	b.WriteString("];\n")
+	return b.String()
+}
+
+func benchmarkParse(b *testing.B, input string) {
+	src := []byte(input)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for b.Loop() {
+		p := New(lexer.New(src))
+		p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			b.Fatalf("parse errors: %v", p.Errors()[:1])
+		}
+	}
+}
+
+func BenchmarkParseProgram(b *testing.B) {
+	inputs := []struct {
+		name  string
+		input string
+	}{
+		{"Statements10k", manyStatements(10000)},
+		{"Pipeline1k", deepPipeline(1000)},
+		{"Table10k", giantTable(10000)},
+		{"Empty", ""},
+	}
+	for _, in := range inputs {
+		b.Run(in.name, func(b *testing.B) { benchmarkParse(b, in.input) })
+	}
+}
+
+func TestBenchmarkInputsParse(t *testing.T) {
+	for _, input := range []string{manyStatements(100), deepPipeline(100), giantTable(100)} {
+		p := New(lexer.New([]byte(input)), WithStrict(true))
+		p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Errorf("parse errors: %v", p.Errors())
+		}
+	}
+}
@@ -1,8 +1,14 @@
 package parser
 
 import (
+	"encoding/json"
+	"slices"
+	"strings"
 	"testing"
 
+	"orglang/pkg/ast"
+	"orglang/pkg/ast/asttest"
+	"orglang/pkg/diag"
 	"orglang/pkg/lexer"
 )
 
@@ -27,6 +33,11 @@ func TestParser(t *testing.T) {
 			input:    "5/2;",
 			expected: "5/2",
 		},
+		{
+			name:     "Quantity Literals",
+			input:    "[250ms 1.5GiB];",
+			expected: "[250ms 1.5GiB]",
+		},
 		{
 			name:     "Prefix Expression",
 			input:    "- 5;", // Space to ensure prefix operator, not negative number
@@ -42,31 +53,11 @@ func TestParser(t *testing.T) {
 			input:    "5 + 5;",
 			expected: "(5 + 5)",
 		},
-		{
-			name:     "Operator Precedence",
-			input:    "5 + 5 * 2;",
-			expected: "(5 + (5 * 2))",
-		},
-		{
-			name:     "Operator Precedence 2",
-			input:    "(5 + 5) * 2;",
-			expected: "(((5 + 5)) * 2)", // GroupExpr adds parens ((...))
-		},
-		{
-			name:     "Right Associativity",
-			input:    "a:1; b:2; c:3; a : b : c;",
-			expected: "(a : 1)\n(b : 2)\n(c : 3)\n(a : (b : c))",
-		},
 		{
 			name:     "Dot Expression",
 			input:    "a:1; b:2; a.b;",
 			expected: "(a : 1)\n(b : 2)\n(a.b)",
 		},
-		{
-			name:     "Method Call chain",
-			input:    "a:1; b:2; c:3; a.b.c;",
-			expected: "(a : 1)\n(b : 2)\n(c : 3)\n((a.b).c)",
-		},
 		{
 			name:     "Function Literal",
 			input:    "{ 1 + 1 };",
@@ -396,6 +387,11 @@ func TestParser(t *testing.T) {
 			input:    "v:1; v :<< 1;",
 			expected: "(v : 1)\n(v :<< 1)",
 		},
+		{
+			name:     "String Interpolation",
+			input:    `x : 1; "a${x + 1}b${x}";`,
+			expected: "(x : 1)\n\"a${(x + 1)}b${x}\"",
+		},
 	}
 
 	for _, tt := range tests {
@@ -417,6 +413,42 @@ func TestParser(t *testing.T) {
 	}
 }
 
+// TestParserTrees checks how expressions group by the trees they parse
+// to, which name the first node that differs when a case fails.
+func TestParserTrees(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *ast.Program
+	}{
+		{"Operator Precedence", "5 + 5 * 2;", asttest.Program(asttest.Infix("+", asttest.Int("5"), asttest.Infix("*", asttest.Int("5"), asttest.Int("2"))))},
+		{"Operator Precedence 2", "(5 + 5) * 2;", asttest.Program(asttest.Infix("*", asttest.Group(asttest.Infix("+", asttest.Int("5"), asttest.Int("5"))), asttest.Int("2")))},
+		{"Right Associativity", "a:1; b:2; c:3; a : b : c;", asttest.Program(
+			asttest.Bind(asttest.Name("a"), asttest.Int("1")), asttest.Bind(asttest.Name("b"), asttest.Int("2")), asttest.Bind(asttest.Name("c"), asttest.Int("3")),
+			asttest.Bind(asttest.Name("a"), asttest.Bind(asttest.Name("b"), asttest.Name("c"))),
+		)},
+		{"Method Call chain", "a:1; b:2; c:3; a.b.c;", asttest.Program(
+			asttest.Bind(asttest.Name("a"), asttest.Int("1")), asttest.Bind(asttest.Name("b"), asttest.Int("2")), asttest.Bind(asttest.Name("c"), asttest.Int("3")),
+			asttest.Dot(asttest.Dot(asttest.Name("a"), asttest.Name("b")), asttest.Name("c")),
+		)},
+		{"Prefix and infix", "x : 1; - x + 2;", asttest.Program(
+			asttest.Bind(asttest.Name("x"), asttest.Int("1")),
+			asttest.Infix("+", asttest.Prefix("-", asttest.Name("x")), asttest.Int("2")),
+		)},
+		{"Table elements are atoms", "[(1 + 2) \"s\" 1/2];", asttest.Program(
+			asttest.Table(asttest.Group(asttest.Infix("+", asttest.Int("1"), asttest.Int("2"))), asttest.Str("s"), asttest.Rat("1", "2")),
+		)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(lexer.New([]byte(tt.input)))
+			prog := p.ParseProgram()
+			checkErrors(t, p)
+			asttest.Equal(t, prog, tt.expected)
+		})
+	}
+}
+
 func checkErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {
@@ -435,3 +467,383 @@ func trim(s string) string {
 	}
 	return s
 }
+
+func TestBindingKinds(t *testing.T) {
+	input := "x : 1; sq : { right * right }; add : { left + right }; pow : 50{ left ** right }60; Log @: [];"
+	p := New(lexer.New([]byte(input)))
+	p.ParseProgram()
+	checkErrors(t, p)
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"x", "value"},
+		{"sq", "prefix operator (bp 100)"},
+		{"add", "infix operator (lbp 100, rbp 101)"},
+		{"pow", "infix operator (lbp 50, rbp 60)"},
+		{"Log", "resource"},
+		{"-", "prefix/infix operator (prefix bp 900, lbp 200, rbp 200)"},
+	}
+	for _, tt := range tests {
+		entry, ok := p.Bindings().Lookup(tt.name)
+		if !ok {
+			t.Errorf("%s: not found in binding table", tt.name)
+			continue
+		}
+		if entry.Kind() != tt.expected {
+			t.Errorf("%s: expected kind %q, got %q", tt.name, tt.expected, entry.Kind())
+		}
+	}
+}
+
+func TestStrictUndefinedIdentifiers(t *testing.T) {
+	input := "x : 1;\ny : x + z;\nw : undefined_fn 2;"
+
+	lenient := New(lexer.New([]byte(input)))
+	lenient.ParseProgram()
+	checkErrors(t, lenient)
+
+	strict := New(lexer.New([]byte(input)), WithStrict(true))
+	strict.ParseProgram()
+	errors := strict.Errors()
+	expected := []string{
+		"line 2:9: undefined identifier: z",
+		"line 3:5: undefined identifier: undefined_fn",
+	}
+	if len(errors) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), errors)
+	}
+	for i, msg := range errors {
+		if msg != expected[i] {
+			t.Errorf("error %d: expected %q, got %q", i, expected[i], msg)
+		}
+	}
+}
+
+func TestStrictAllowsKeysAndResources(t *testing.T) {
+	input := `person : ["name": "Alice"];
+n : person.name;
+lib : "lib.org" @ org;
+h : lib.helper 1;
+msg : {"hi" -> @stdout};
+fact : { right = 0 ? [true: 1 false: right * fact] };
+inc : { x : right; x + 1 };`
+	p := New(lexer.New([]byte(input)), WithStrict(true))
+	p.ParseProgram()
+	checkErrors(t, p)
+}
+
+func TestDestructuringPattern(t *testing.T) {
+	input := "divmod : { [(left / right) (left % right)] };\n[q r] : 7 divmod 2;\ns : q + r;\n[q 1];"
+	p := New(lexer.New([]byte(input)), WithStrict(true))
+	prog := p.ParseProgram()
+	checkErrors(t, p)
+	asttest.Equal(t, prog.Statements[1], asttest.Bind(asttest.Table(asttest.Name("q"), asttest.Name("r")), asttest.Infix("divmod", asttest.Int("7"), asttest.Int("2"))))
+	for _, name := range []string{"q", "r"} {
+		if entry, ok := p.Bindings().Lookup(name); !ok || entry.Kind() != "value" {
+			t.Errorf("%s: want a value binding, got %+v", name, entry)
+		}
+	}
+	if _, ok := prog.Statements[3].(*ast.TableLiteral); !ok {
+		t.Errorf("[q 1] = %T, want a table literal", prog.Statements[3])
+	}
+
+	// Without the `:` the names are looked up, as in any table.
+	p = New(lexer.New([]byte("[a b];")), WithStrict(true))
+	p.ParseProgram()
+	if len(p.Errors()) != 2 {
+		t.Errorf("errors = %v, want a and b undefined", p.Errors())
+	}
+}
+
+func TestGuards(t *testing.T) {
+	input := "sign : { right < 0 !> (0 - 1); right = 0 !> 0; 1 };\ninner : { f : { right !> 1; 2 }; f };"
+	p := New(lexer.New([]byte(input)), WithStrict(true))
+	prog := p.ParseProgram()
+	checkErrors(t, p)
+	asttest.Equal(t, prog.Statements[0], asttest.Bind(asttest.Name("sign"), asttest.Block(
+		asttest.Guard(asttest.Infix("<", asttest.Name("right"), asttest.Int("0")), asttest.Group(asttest.Infix("-", asttest.Int("0"), asttest.Int("1")))),
+		asttest.Guard(asttest.Infix("=", asttest.Name("right"), asttest.Int("0")), asttest.Int("0")),
+		asttest.Int("1"),
+	)))
+	if entry, _ := p.Bindings().Lookup("sign"); !entry.IsPrefix {
+		t.Errorf("sign uses right in its guards: want a prefix operator, got %s", entry.Kind())
+	}
+
+	// Anywhere but as a statement of a block, !> has nothing to return from.
+	p = New(lexer.New([]byte("x : 1 !> 2;\nf : { y : (true !> 3); y };\ntrue !> 4;")))
+	p.ParseProgram()
+	expected := []string{
+		"line 1:7: !> must be a statement of a block, returning from it",
+		"line 2:17: !> must be a statement of a block, returning from it",
+		"line 3:6: !> must be a statement of a block, returning from it",
+	}
+	if got := p.Errors(); !slices.Equal(got, expected) {
+		t.Errorf("errors = %q, want %q", got, expected)
+	}
+}
+
+func TestAssert(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ast.Statement
+	}{
+		{`f : { assert right > 0 && right < 10 "out of range"; right };`, asttest.Bind(asttest.Name("f"), asttest.Block(
+			asttest.Assert(asttest.Infix("&&", asttest.Infix(">", asttest.Name("right"), asttest.Int("0")), asttest.Infix("<", asttest.Name("right"), asttest.Int("10"))), asttest.Str("out of range")),
+			asttest.Name("right"),
+		))},
+		{`f : { assert right; right };`, asttest.Bind(asttest.Name("f"), asttest.Block(asttest.Assert(asttest.Name("right"), nil), asttest.Name("right")))},
+		{`g : { assert (right <> 0) "got ${right}"; 1 / right };`, asttest.Bind(asttest.Name("g"), asttest.Block(
+			asttest.Assert(asttest.Group(asttest.Infix("<>", asttest.Name("right"), asttest.Int("0"))), asttest.Interpolated([]string{"got ", ""}, asttest.Name("right"))),
+			asttest.Infix("/", asttest.Int("1"), asttest.Name("right")),
+		))},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New([]byte(tt.input)), WithStrict(true))
+		prog := p.ParseProgram()
+		checkErrors(t, p)
+		asttest.Equal(t, prog.Statements[0], tt.expected)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	input := "x : 1 + 2;\nf : { left *\n  (right) };\ns : \"a${x}\";"
+	prog := New(lexer.New([]byte(input))).ParseProgram()
+	if len(prog.Statements) != 3 {
+		t.Fatalf("got %d statements", len(prog.Statements))
+	}
+	x := prog.Statements[0].(*ast.BindingExpr)
+	f := prog.Statements[1].(*ast.BindingExpr)
+	body := f.Value.(*ast.FunctionLiteral).Body[0].(*ast.InfixExpr)
+	s := prog.Statements[2].(*ast.BindingExpr).Value.(*ast.InterpolatedString)
+	span := func(l1, c1, o1, l2, c2, o2 int) ast.Span {
+		return ast.Span{Start: ast.Pos{Line: l1, Column: c1, Offset: o1}, End: ast.Pos{Line: l2, Column: c2, Offset: o2}}
+	}
+	tests := []struct {
+		name string
+		node ast.Node
+		want ast.Span
+	}{
+		{"program", prog, span(1, 1, 0, 4, 13, 49)},
+		{"binding", x, span(1, 1, 0, 1, 10, 9)},
+		{"name", x.Name, span(1, 1, 0, 1, 2, 1)},
+		{"infix", x.Value, span(1, 5, 4, 1, 10, 9)},
+		{"literal", x.Value.(*ast.InfixExpr).Right, span(1, 9, 8, 1, 10, 9)},
+		{"block", f.Value, span(2, 5, 15, 3, 12, 35)},
+		{"multi-line infix", body, span(2, 7, 17, 3, 10, 33)},
+		{"group", body.Right, span(3, 3, 26, 3, 10, 33)},
+		{"interpolation", s, span(4, 5, 41, 4, 12, 48)},
+		{"interpolated name", s.Exprs[0], span(4, 9, 45, 4, 10, 46)},
+	}
+	for _, tt := range tests {
+		if got := tt.node.Location(); got != tt.want {
+			t.Errorf("%s %s: span %+v, want %+v", tt.name, tt.node, got, tt.want)
+		}
+	}
+}
+
+func TestInterpolationErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a${}b";`, "line 1:5: empty interpolation ${}"},
+		{`"a${1 2}b";`, "line 1:7: expected '}' closing string interpolation"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New([]byte(tt.input)))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%s: expected %q, got %v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	p := New(lexer.New([]byte("x : 1;\ny : (x + z;")), WithStrict(true))
+	p.ParseProgram()
+	diags := p.Diagnostics()
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %v", diags)
+	}
+	undefined := diags[0]
+	if undefined.Code != diag.UndefinedIdentifier || undefined.Span.Start.Column != 10 || undefined.Span.End.Column != 11 || undefined.Hint == "" {
+		t.Errorf("undefined identifier: got %+v", undefined)
+	}
+	if diags[1].Code != diag.SyntaxError || diags[1].Severity != diag.Error {
+		t.Errorf("missing ')': got %+v", diags[1])
+	}
+	if got, want := p.Errors()[0], "line 2:10: undefined identifier: z"; got != want {
+		t.Errorf("Errors()[0] = %q, want %q", got, want)
+	}
+}
+
+func TestDump(t *testing.T) {
+	prog := New(lexer.New([]byte("x : -1 + y.k;\nf : { right };"))).ParseProgram()
+	var b strings.Builder
+	ast.Dump(&b, prog)
+	expected := `Program 1:1-2:15
+  Statements[0]: BindingExpr 1:1-1:13 Operator=":"
+    Name: Name 1:1-1:2 Value="x"
+    Value: InfixExpr 1:5-1:13 Op="+"
+      Left: IntegerLiteral 1:5-1:7 Value="-1"
+      Right: DotExpr 1:10-1:13
+        Left: ErrorExpr 1:10-1:11 Message="undefined identifier: y"
+        Key: Name 1:12-1:13 Value="k"
+  Statements[1]: BindingExpr 2:1-2:14 Operator=":"
+    Name: Name 2:1-2:2 Value="f"
+    Value: FunctionLiteral 2:5-2:14
+      Body[0]: Name 2:7-2:12 Value="right"
+`
+	if b.String() != expected {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), expected)
+	}
+
+	js, err := json.Marshal(ast.ToJSON(prog.Statements[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"type":"BindingExpr"`, `"operator":":"`, `"lbp":null`, `"body":[{`, `"start":{"column":5,"line":2,"offset":18}`} {
+		if !strings.Contains(string(js), want) {
+			t.Errorf("JSON %s lacks %s", js, want)
+		}
+	}
+}
+
+func TestBindingTableDefaultsShared(t *testing.T) {
+	// Redefining an operator in one parse must not leak into the next:
+	// the defaults are shared between parsers.
+	p := New(lexer.New([]byte("+ : { right * 2 }; x : + 3;")))
+	p.ParseProgram()
+	checkErrors(t, p)
+	if entry, _ := p.Bindings().Lookup("+"); entry.Kind() != "prefix operator (bp 100)" {
+		t.Errorf("redefined +: got %q", entry.Kind())
+	}
+
+	q := New(lexer.New([]byte("a : 1; b : a + 2;")))
+	prog := q.ParseProgram()
+	checkErrors(t, q)
+	if entry, _ := q.Bindings().Lookup("+"); entry.Kind() != "infix operator (lbp 200, rbp 201)" {
+		t.Errorf("default +: got %q", entry.Kind())
+	}
+	if _, ok := q.Bindings().Lookup("x"); ok {
+		t.Error("binding x leaked from another parse")
+	}
+	if got := strings.TrimSpace(prog.String()); got != "(a : 1)\n(b : (a + 2))" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestBindingTableEntries(t *testing.T) {
+	// The entries of a table registered on another, as :load does, parse
+	// its operators as the first one did.
+	p := New(lexer.New([]byte("add : 50{ left + right }60; n : 1;")))
+	p.ParseProgram()
+	checkErrors(t, p)
+	entries := p.Bindings().Entries()
+	if len(entries) != 2 {
+		t.Fatalf("entries = %v, want add and n only", entries)
+	}
+	bt := NewBindingTable()
+	for name, entry := range entries {
+		bt.Register(name, entry)
+	}
+	q := New(lexer.New([]byte("n add 2")), WithBindings(bt))
+	prog := q.ParseProgram()
+	checkErrors(t, q)
+	if got := strings.TrimSpace(prog.String()); got != "(n add 2)" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestThisRecursion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"factorial : { (right <= 1) ? [true: 1 false: (right * this (right - 1))] };",
+			"(factorial : { (((right <= 1)) ? [(true : 1) (false : ((right * (this ((right - 1))))))]) })",
+		},
+		{
+			"fib : { left <= 1 ? [true: left false: ((left - 1) this right)] };",
+			"(fib : { ((left <= 1) ? [(true : left) (false : ((((left - 1)) this right)))]) })",
+		},
+		{
+			// this is the innermost block.
+			"outer : { inner : { this right }; inner (this 1) };",
+			"(outer : { (inner : { (this right) }); (inner ((this 1))) })",
+		},
+		{
+			"apply : { right 1 }; f : { apply this };",
+			"(apply : { right; 1 })\n(f : { (apply this) })",
+		},
+		{
+			"t : [n: (1 + 2) add: { left + right }];",
+			"(t : [(n : ((1 + 2))) (add : { (left + right) })])",
+		},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New([]byte(tt.input)), WithStrict(true))
+		prog := p.ParseProgram()
+		checkErrors(t, p)
+		if got := strings.TrimSpace(prog.String()); got != tt.expected {
+			t.Errorf("%s\nexpected %q\ngot      %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestArgumentTables(t *testing.T) {
+	input := `clamp : { (right.0 < right.1) ? [true: right.1 false: right.0] };
+x : clamp [5 7];
+area : { right.width * right.height };
+a : area ["width": 2 "height": 3];
+pair : { [left.0 right.0] };
+p : [1] pair [2];`
+	expected := `(clamp : { ((((right.0) < (right.1))) ? [(true : (right.1)) (false : (right.0))]) })
+(x : (clamp [5 7]))
+(area : { ((right.width) * (right.height)) })
+(a : (area [("width" : 2) ("height" : 3)]))
+(pair : { [(left.0) (right.0)] })
+(p : ([1] pair [2]))`
+	p := New(lexer.New([]byte(input)), WithStrict(true))
+	prog := p.ParseProgram()
+	checkErrors(t, p)
+	if got := strings.TrimSpace(prog.String()); got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestPrefixOperatorAsValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"inc : { right + 1 }; double : { right * 2 }; h : double o inc;",
+			"(inc : { (right + 1) })\n(double : { (right * 2) })\n(h : (double o inc))",
+		},
+		{
+			"inc : { right + 1 }; f : inc |> { right };",
+			"(inc : { (right + 1) })\n(f : (inc |> { right }))",
+		},
+		{
+			"inc : { right + 1 }; t : [(inc) 1];",
+			"(inc : { (right + 1) })\n(t : [(inc) 1])",
+		},
+		{
+			"inc : { right + 1 }; x : inc 1;",
+			"(inc : { (right + 1) })\n(x : (inc 1))",
+		},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New([]byte(tt.input)), WithStrict(true))
+		prog := p.ParseProgram()
+		checkErrors(t, p)
+		if got := strings.TrimSpace(prog.String()); got != tt.expected {
+			t.Errorf("%s\nexpected %q\ngot      %q", tt.input, tt.expected, got)
+		}
+	}
+}
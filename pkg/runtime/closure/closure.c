@@ -0,0 +1,157 @@
+#include "closure.h"
+#include "../core/print.h"
+#include "../table/table.h"
+#include <stdio.h>
+
+/* Depth of the calls running, against ORG_MAX_CALL_DEPTH. */
+static int call_depth = 0;
+
+static OrgClosure *new_closure(Arena *arena, OrgClosureKind kind, int arity,
+                               const char *name) {
+  OrgClosure *c = (OrgClosure *)arena_alloc(arena, sizeof(OrgClosure), 8);
+  if (!c)
+    return NULL;
+  c->header.type = ORG_TYPE_CLOSURE;
+  c->header.flags = (uint8_t)kind;
+  c->header._pad = 0;
+  c->header.size = (uint32_t)sizeof(OrgClosure);
+  c->arity = arity;
+  c->_pad2 = 0;
+  c->name = name;
+  return c;
+}
+
+static OrgClosure *get_closure(OrgValue v) {
+  return (OrgClosure *)ORG_GET_PTR(v);
+}
+
+/* The name of v in a message: a closure's name, else v as text. */
+static const char *describe(Arena *arena, OrgValue v, char *buf,
+                            size_t size) {
+  if (org_is_closure(v))
+    return get_closure(v)->name;
+  OrgValue text = org_display(arena, v);
+  if (org_is_error(text))
+    return "value";
+  snprintf(buf, size, "%.*s", (int)org_string_byte_len(text),
+           org_string_data(text));
+  return buf;
+}
+
+/* The Error "<v><suffix>", v described by describe. */
+static OrgValue error_about(Arena *arena, OrgValue v, const char *suffix) {
+  char buf[128], msg[256];
+  snprintf(msg, sizeof msg, "%s%s", describe(arena, v, buf, sizeof buf),
+           suffix);
+  return org_make_error(arena, msg);
+}
+
+OrgValue org_make_closure(Arena *arena, OrgFunc fn, OrgValue env, int arity,
+                          const char *name) {
+  OrgClosure *c = new_closure(arena, ORG_CLOSURE_BLOCK, arity, name);
+  if (!c)
+    return ORG_ERROR;
+  c->u.block.fn = fn;
+  c->u.block.env = env;
+  return ORG_TAG_PTR_VAL(c);
+}
+
+OrgValue org_builtin(Arena *arena, const char *name, OrgBinaryFunc binary,
+                     OrgUnaryFunc unary) {
+  OrgClosure *c =
+      new_closure(arena, ORG_CLOSURE_BUILTIN, binary ? 2 : 1, name);
+  if (!c)
+    return ORG_ERROR;
+  c->u.builtin.binary = binary;
+  c->u.builtin.unary = unary;
+  return ORG_TAG_PTR_VAL(c);
+}
+
+int org_arity(OrgValue v) {
+  return org_is_closure(v) ? get_closure(v)->arity : -1;
+}
+
+/* left if f is binary, else ORG_UNUSED: the operand f gets in g o f. */
+static OrgValue operand(OrgValue f, OrgValue left) {
+  return org_arity(f) == 2 ? left : ORG_UNUSED;
+}
+
+static OrgValue call(Arena *arena, OrgClosure *c, OrgValue self,
+                     OrgValue left, OrgValue right) {
+  switch ((OrgClosureKind)c->header.flags) {
+  case ORG_CLOSURE_BLOCK: {
+    OrgValue scope = org_scope_new(arena, c->u.block.env);
+    if (org_is_error(scope))
+      return scope;
+    return c->u.block.fn(arena, scope, self, left, right);
+  }
+  case ORG_CLOSURE_BUILTIN:
+    if (ORG_IS_UNUSED(left) && c->u.builtin.unary)
+      return c->u.builtin.unary(arena, right);
+    if (!c->u.builtin.binary)
+      return error_about(arena, self, " is a prefix operator");
+    if (ORG_IS_UNUSED(left))
+      return error_about(arena, self, " needs a left operand");
+    return c->u.builtin.binary(arena, left, right);
+  case ORG_CLOSURE_PARTIAL:
+    if (!ORG_IS_UNUSED(c->u.partial.right))
+      return org_call(arena, c->u.partial.op, ORG_UNUSED,
+                      c->u.partial.right);
+    return org_call(arena, c->u.partial.op, c->u.partial.left, right);
+  case ORG_CLOSURE_COMPOSED: {
+    OrgValue g = c->u.composed.g, f = c->u.composed.f;
+    OrgValue inner = org_call(arena, f, operand(f, left), right);
+    return org_call(arena, g, operand(g, left), inner);
+  }
+  }
+  return ORG_ERROR;
+}
+
+OrgValue org_call(Arena *arena, OrgValue f, OrgValue left, OrgValue right) {
+  if (org_is_error(f))
+    return f;
+  if (!org_is_closure(f))
+    return error_about(arena, f, " is not an operator");
+  if (call_depth >= ORG_MAX_CALL_DEPTH) {
+    char msg[64];
+    snprintf(msg, sizeof msg, "call depth exceeds %d", ORG_MAX_CALL_DEPTH);
+    return org_make_error(arena, msg);
+  }
+  call_depth++;
+  OrgValue result = call(arena, get_closure(f), f, left, right);
+  call_depth--;
+  return result;
+}
+
+OrgValue org_partial(Arena *arena, OrgValue left, OrgValue op) {
+  if (org_is_error(left))
+    return left;
+  if (org_is_error(op))
+    return op;
+  int arity = org_arity(op);
+  if (arity < 1)
+    return error_about(arena, op, " takes no operand");
+  OrgClosure *c =
+      new_closure(arena, ORG_CLOSURE_PARTIAL, arity - 1, "(|> operator)");
+  if (!c)
+    return ORG_ERROR;
+  c->u.partial.op = op;
+  c->u.partial.left = arity == 2 ? left : ORG_UNUSED;
+  c->u.partial.right = arity == 1 ? left : ORG_UNUSED;
+  return ORG_TAG_PTR_VAL(c);
+}
+
+OrgValue org_compose(Arena *arena, OrgValue g, OrgValue f) {
+  if (org_is_error(g))
+    return g;
+  if (org_is_error(f))
+    return f;
+  int arity = org_arity(g) == 2 || org_arity(f) == 2 ? 2 : 1;
+  OrgClosure *c =
+      new_closure(arena, ORG_CLOSURE_COMPOSED, arity, "(o operator)");
+  if (!c)
+    return ORG_ERROR;
+  c->u.composed.g = g;
+  c->u.composed.f = f;
+  return ORG_TAG_PTR_VAL(c);
+}
@@ -0,0 +1,103 @@
+#ifndef ORG_CLOSURE_H
+#define ORG_CLOSURE_H
+
+#include "../core/values.h"
+
+/*
+ * Closures — the operators of a program as values.
+ *
+ * A block of the program is a generated C function and the scope it was
+ * defined in. Calling it runs the function in a new scope whose parent is
+ * that one, with the block itself as self, so `this` recurses. The other
+ * kinds are the runtime's operators (org_builtin), `x |> op`
+ * (org_partial) and `g o f` (org_compose).
+ *
+ * An absent operand is ORG_UNUSED. A block gets it as it is, and its
+ * prologue turns it into an Error (org_operand).
+ */
+
+/* The generated function of a block. */
+typedef OrgValue (*OrgFunc)(Arena *arena, OrgValue env, OrgValue self,
+                            OrgValue left, OrgValue right);
+
+typedef OrgValue (*OrgBinaryFunc)(Arena *arena, OrgValue left,
+                                  OrgValue right);
+typedef OrgValue (*OrgUnaryFunc)(Arena *arena, OrgValue right);
+
+/* The kind of a closure, in its header's flags. */
+typedef enum OrgClosureKind {
+  ORG_CLOSURE_BLOCK,
+  ORG_CLOSURE_BUILTIN,
+  ORG_CLOSURE_PARTIAL,
+  ORG_CLOSURE_COMPOSED,
+} OrgClosureKind;
+
+typedef struct OrgClosure {
+  OrgObject header;
+  int32_t arity;    /* 2 binary, 1 unary, 0 neither (see org_arity) */
+  int32_t _pad2;
+  const char *name; /* for messages: the block's binding or source */
+  union {
+    struct {
+      OrgFunc fn;
+      OrgValue env; /* the scope the block was defined in */
+    } block;
+    struct {
+      OrgBinaryFunc binary; /* NULL for a prefix operator */
+      OrgUnaryFunc unary;   /* NULL for an infix one */
+    } builtin;
+    struct {
+      OrgValue op;
+      OrgValue left;  /* the operand fixed, the other ORG_UNUSED */
+      OrgValue right;
+    } partial;
+    struct {
+      OrgValue g, f; /* g o f */
+    } composed;
+  } u;
+} OrgClosure;
+
+/*
+ * Maximum depth of nested calls: deeper, a call is an Error ("call depth
+ * exceeds 10000") rather than overflowing the C stack.
+ */
+#define ORG_MAX_CALL_DEPTH 10000
+
+/*
+ * The closure of the block fn defined in env. arity is 2 if the block
+ * uses left, 1 if it uses right only, else 0; name is a static string.
+ */
+OrgValue org_make_closure(Arena *arena, OrgFunc fn, OrgValue env, int arity,
+                          const char *name);
+
+/*
+ * A runtime operator named name, infix with binary, prefix with unary,
+ * or both, as - is.
+ */
+OrgValue org_builtin(Arena *arena, const char *name, OrgBinaryFunc binary,
+                     OrgUnaryFunc unary);
+
+static inline int org_is_closure(OrgValue v) {
+  return ORG_IS_PTR(v) && org_get_type(v) == ORG_TYPE_CLOSURE;
+}
+
+/* 0, 1 or 2 for a closure, following its operands, and -1 otherwise. */
+int org_arity(OrgValue v);
+
+/*
+ * Call f with left (ORG_UNUSED for a prefix call) and right. An Error f
+ * is the result; a value that is not a closure is an Error ("... is not an
+ * operator"), as is a prefix call of an infix builtin and the reverse.
+ */
+OrgValue org_call(Arena *arena, OrgValue f, OrgValue left, OrgValue right);
+
+/*
+ * left |> op: op with left fixed as its left operand if op is binary,
+ * else as its right one. An Error left is the result.
+ */
+OrgValue org_partial(Arena *arena, OrgValue left, OrgValue op);
+
+/* g o f: the result of f becomes the right operand of g. */
+OrgValue org_compose(Arena *arena, OrgValue g, OrgValue f);
+
+#endif /* ORG_CLOSURE_H */
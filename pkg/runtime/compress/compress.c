@@ -0,0 +1,203 @@
+#include "compress.h"
+#include <stdlib.h>
+
+#ifdef ORG_WITH_ZLIB
+
+#include <zlib.h>
+
+/* zlib counts in uInt; longer input is passed in pieces of this size. */
+#define MAX_PIECE ((size_t)1 << 30)
+
+/* Size of the output buffer handed to the writer. */
+#define OUT_SIZE 16384
+
+/* zlib's windowBits: 15 is the largest window, +16 selects gzip. */
+static int window_bits(OrgCompressFormat fmt) {
+  return fmt == ORG_COMPRESS_GZIP ? 15 + 16 : 15;
+}
+
+int org_compress_available(void) { return 1; }
+
+/* ---- Compression ---- */
+
+struct OrgCompressor {
+  z_stream z;
+  OrgByteWriter w;
+  void *ctx;
+  int failed;
+};
+
+OrgCompressor *org_compressor_new(OrgCompressFormat fmt, int level,
+                                  OrgByteWriter w, void *ctx) {
+  if (level < ORG_COMPRESS_DEFAULT_LEVEL || level > 9)
+    return NULL;
+  OrgCompressor *c = calloc(1, sizeof *c);
+  if (!c)
+    return NULL;
+  if (deflateInit2(&c->z, level, Z_DEFLATED, window_bits(fmt), 8,
+                   Z_DEFAULT_STRATEGY) != Z_OK) {
+    free(c);
+    return NULL;
+  }
+  c->w = w;
+  c->ctx = ctx;
+  return c;
+}
+
+/* Run deflate over the pending input, writing all the output it makes. */
+static int deflate_pending(OrgCompressor *c, int flush) {
+  unsigned char out[OUT_SIZE];
+  int ret;
+  do {
+    c->z.next_out = out;
+    c->z.avail_out = sizeof out;
+    ret = deflate(&c->z, flush);
+    if (ret == Z_STREAM_ERROR)
+      return -1;
+    size_t n = sizeof out - c->z.avail_out;
+    if (n && c->w(c->ctx, out, n) < 0)
+      return -1;
+  } while (c->z.avail_out == 0 || (flush == Z_FINISH && ret != Z_STREAM_END));
+  return 0;
+}
+
+int org_compressor_write(OrgCompressor *c, const void *data, size_t len) {
+  const unsigned char *p = data;
+  while (!c->failed && len > 0) {
+    size_t piece = len < MAX_PIECE ? len : MAX_PIECE;
+    c->z.next_in = (Bytef *)p;
+    c->z.avail_in = (uInt)piece;
+    if (deflate_pending(c, Z_NO_FLUSH) < 0)
+      c->failed = 1;
+    p += piece;
+    len -= piece;
+  }
+  return c->failed ? -1 : 0;
+}
+
+int org_compressor_close(OrgCompressor *c) {
+  int failed = c->failed;
+  if (!failed) {
+    c->z.next_in = NULL;
+    c->z.avail_in = 0;
+    failed = deflate_pending(c, Z_FINISH) < 0;
+  }
+  deflateEnd(&c->z);
+  free(c);
+  return failed ? -1 : 0;
+}
+
+/* ---- Decompression ---- */
+
+struct OrgDecompressor {
+  z_stream z;
+  OrgCompressFormat fmt;
+  OrgByteWriter w;
+  void *ctx;
+  int failed;
+  int ended; /* the current stream or gzip member is complete */
+};
+
+OrgDecompressor *org_decompressor_new(OrgCompressFormat fmt, OrgByteWriter w,
+                                      void *ctx) {
+  OrgDecompressor *d = calloc(1, sizeof *d);
+  if (!d)
+    return NULL;
+  if (inflateInit2(&d->z, window_bits(fmt)) != Z_OK) {
+    free(d);
+    return NULL;
+  }
+  d->fmt = fmt;
+  d->w = w;
+  d->ctx = ctx;
+  return d;
+}
+
+/* Inflate the pending input, writing all the output it makes. */
+static int inflate_pending(OrgDecompressor *d) {
+  unsigned char out[OUT_SIZE];
+  int full = 0;
+  while (d->z.avail_in > 0 || full) {
+    if (d->ended) {
+      if (d->z.avail_in == 0)
+        break;
+      /* Only gzip allows another member after the end of a stream. */
+      if (d->fmt != ORG_COMPRESS_GZIP || inflateReset(&d->z) != Z_OK)
+        return -1;
+      d->ended = 0;
+    }
+    d->z.next_out = out;
+    d->z.avail_out = sizeof out;
+    int ret = inflate(&d->z, Z_NO_FLUSH);
+    size_t n = sizeof out - d->z.avail_out;
+    if (n && d->w(d->ctx, out, n) < 0)
+      return -1;
+    full = d->z.avail_out == 0;
+    if (ret == Z_STREAM_END)
+      d->ended = 1;
+    else if (ret == Z_BUF_ERROR)
+      break; /* no progress possible until more input arrives */
+    else if (ret != Z_OK)
+      return -1;
+  }
+  return 0;
+}
+
+int org_decompressor_write(OrgDecompressor *d, const void *data, size_t len) {
+  const unsigned char *p = data;
+  while (!d->failed && len > 0) {
+    size_t piece = len < MAX_PIECE ? len : MAX_PIECE;
+    d->z.next_in = (Bytef *)p;
+    d->z.avail_in = (uInt)piece;
+    if (inflate_pending(d) < 0)
+      d->failed = 1;
+    p += piece;
+    len -= piece;
+  }
+  return d->failed ? -1 : 0;
+}
+
+int org_decompressor_close(OrgDecompressor *d) {
+  int failed = d->failed || !d->ended;
+  inflateEnd(&d->z);
+  free(d);
+  return failed ? -1 : 0;
+}
+
+#else /* !ORG_WITH_ZLIB */
+
+int org_compress_available(void) { return 0; }
+
+OrgCompressor *org_compressor_new(OrgCompressFormat fmt, int level,
+                                  OrgByteWriter w, void *ctx) {
+  (void)fmt, (void)level, (void)w, (void)ctx;
+  return NULL;
+}
+
+int org_compressor_write(OrgCompressor *c, const void *data, size_t len) {
+  (void)c, (void)data, (void)len;
+  return -1;
+}
+
+int org_compressor_close(OrgCompressor *c) {
+  (void)c;
+  return -1;
+}
+
+OrgDecompressor *org_decompressor_new(OrgCompressFormat fmt, OrgByteWriter w,
+                                      void *ctx) {
+  (void)fmt, (void)w, (void)ctx;
+  return NULL;
+}
+
+int org_decompressor_write(OrgDecompressor *d, const void *data, size_t len) {
+  (void)d, (void)data, (void)len;
+  return -1;
+}
+
+int org_decompressor_close(OrgDecompressor *d) {
+  (void)d;
+  return -1;
+}
+
+#endif /* ORG_WITH_ZLIB */
@@ -0,0 +1,77 @@
+#ifndef ORG_COMPRESS_H
+#define ORG_COMPRESS_H
+
+#include <stddef.h>
+
+/*
+ * gzip and zlib streams, for resources that (de)compress another byte
+ * stream: a compressor or decompressor wraps a writer and passes it the
+ * transformed bytes as input is pushed in. A reading resource pushes the
+ * compressed bytes it reads into a decompressor; a writing resource
+ * pushes what it is sent into a compressor.
+ *
+ * Implemented against zlib when built with -DORG_WITH_ZLIB (link -lz).
+ * Without it the runtime still builds: org_compress_available() is 0 and
+ * the constructors return NULL, which the resources report as an Error.
+ */
+
+typedef enum OrgCompressFormat {
+  ORG_COMPRESS_GZIP, /* RFC 1952, as written by gzip */
+  ORG_COMPRESS_ZLIB, /* RFC 1950 */
+} OrgCompressFormat;
+
+/* Default compression level (zlib's 6); levels run from 0 to 9. */
+#define ORG_COMPRESS_DEFAULT_LEVEL (-1)
+
+/*
+ * The wrapped stream: writes len bytes of data, returning 0, or -1 to
+ * stop the (de)compression with an error.
+ */
+typedef int (*OrgByteWriter)(void *ctx, const void *data, size_t len);
+
+/* Non-zero when the runtime was built with zlib. */
+int org_compress_available(void);
+
+typedef struct OrgCompressor OrgCompressor;
+
+/*
+ * A compressor writing the compressed stream to w. Returns NULL when
+ * zlib is unavailable, the level is out of range or memory runs out.
+ */
+OrgCompressor *org_compressor_new(OrgCompressFormat fmt, int level,
+                                  OrgByteWriter w, void *ctx);
+
+/* Compress len bytes of data. Returns 0, or -1 if the writer failed. */
+int org_compressor_write(OrgCompressor *c, const void *data, size_t len);
+
+/*
+ * Finish the stream (writing its trailer) and free c. Returns 0, or -1
+ * if this or an earlier write failed.
+ */
+int org_compressor_close(OrgCompressor *c);
+
+typedef struct OrgDecompressor OrgDecompressor;
+
+/*
+ * A decompressor writing the decompressed bytes to w. A gzip stream may
+ * hold several members, as `cat a.gz b.gz` does; they are decompressed
+ * one after the other. Returns NULL when zlib is unavailable or memory
+ * runs out.
+ */
+OrgDecompressor *org_decompressor_new(OrgCompressFormat fmt, OrgByteWriter w,
+                                      void *ctx);
+
+/*
+ * Decompress the next len bytes of the compressed stream. Returns 0, or
+ * -1 if the data is corrupt, follows the end of a zlib stream or the
+ * writer failed.
+ */
+int org_decompressor_write(OrgDecompressor *d, const void *data, size_t len);
+
+/*
+ * Free d. Returns 0, or -1 if the stream was truncated or an earlier
+ * write failed.
+ */
+int org_decompressor_close(OrgDecompressor *d);
+
+#endif /* ORG_COMPRESS_H */
@@ -1,7 +1,56 @@
 #include "arena.h"
+#include "status.h"
+#include <errno.h>
+#include <stdint.h>
+#include <stdio.h>
 #include <stdlib.h>
 #include <string.h>
 
+static void default_oom_handler(size_t size) {
+  fprintf(stderr, "Error: out of memory (allocating %zu bytes)\n", size);
+  exit(ORG_EXIT_OOM);
+}
+
+static ArenaOOMHandler oom_handler = default_oom_handler;
+
+void arena_set_oom_handler(ArenaOOMHandler handler) {
+  oom_handler = handler ? handler : default_oom_handler;
+}
+
+size_t arena_page_size_from_env(size_t fallback) {
+  const char *v = getenv("ORG_ARENA_SIZE");
+  if (!v || !*v)
+    return fallback;
+
+  char *end;
+  errno = 0;
+  unsigned long long n = strtoull(v, &end, 10);
+  if (end == v || errno == ERANGE || n > SIZE_MAX)
+    return fallback;
+  unsigned shift = 0;
+  switch (*end) {
+  case 'K':
+  case 'k':
+    shift = 10;
+    end++;
+    break;
+  case 'M':
+  case 'm':
+    shift = 20;
+    end++;
+    break;
+  case 'G':
+  case 'g':
+    shift = 30;
+    end++;
+    break;
+  }
+  /* A size that does not fit in size_t once scaled is not valid. */
+  if (*end != '\0' || n == 0 || n > (SIZE_MAX >> shift))
+    return fallback;
+  return (size_t)n << shift;
+}
+
 /*
  * Align `n` up to the next multiple of `align`.
  * align must be a power of 2.
@@ -15,6 +64,8 @@ static inline size_t align_up(size_t n, size_t align) {
  * The page struct and its data[] are allocated in a single malloc.
  */
 static ArenaPage *page_new(size_t capacity) {
+  if (capacity > SIZE_MAX - sizeof(ArenaPage))
+    return NULL;
   ArenaPage *p = (ArenaPage *)malloc(sizeof(ArenaPage) + capacity);
   if (!p)
     return NULL;
@@ -52,7 +103,7 @@ void *arena_alloc(Arena *arena, size_t size, size_t align) {
   /* padding is the required offset from data[] start */
 
   /* Fast path: fits in current page */
-  if (padding + size <= page->capacity) {
+  if (size <= page->capacity && padding <= page->capacity - size) {
     void *ptr = (void *)aligned;
     page->used = padding + size;
     return ptr;
@@ -65,12 +116,14 @@ void *arena_alloc(Arena *arena, size_t size, size_t align) {
    */
   size_t new_capacity = arena->default_page_size;
   if (size > new_capacity / 2) {
-    new_capacity = align_up(size, align);
+    new_capacity = size > SIZE_MAX - align ? SIZE_MAX : align_up(size, align);
   }
 
   ArenaPage *new_page = page_new(new_capacity);
-  if (!new_page)
+  if (!new_page) {
+    oom_handler(size);
     return NULL;
+  }
 
   new_page->prev = arena->current;
   arena->current = new_page;
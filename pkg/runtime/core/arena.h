@@ -34,6 +34,30 @@ typedef struct ArenaCheckpoint {
     size_t used;      /* used offset at time of save */
 } ArenaCheckpoint;
 
+/*
+ * Default page size of the program arena when ORG_ARENA_SIZE is unset.
+ * Pages are chained, so this only bounds how often malloc is called,
+ * not how much the program can allocate.
+ */
+#define ARENA_DEFAULT_PAGE_SIZE (1024 * 1024)
+
+/*
+ * Called when the system cannot provide a new page of `size` bytes.
+ * The default handler prints "Error: out of memory" to stderr and exits
+ * with status 3. If a handler returns, arena_alloc returns NULL.
+ */
+typedef void (*ArenaOOMHandler)(size_t size);
+
+/* Install an out-of-memory handler; NULL restores the default. */
+void arena_set_oom_handler(ArenaOOMHandler handler);
+
+/*
+ * Page size for the program arena: ORG_ARENA_SIZE if set to a valid size
+ * (bytes, or with a K, M or G suffix, fitting in size_t), otherwise
+ * `fallback`.
+ */
+size_t arena_page_size_from_env(size_t fallback);
+
 /*
  * Create a new arena. page_size is the default capacity (in bytes)
  * for each page's data[] region. Typical values: 4096 or 65536.
@@ -44,7 +68,8 @@ Arena *arena_new(size_t page_size);
 /*
  * Allocate `size` bytes from the arena, aligned to `align` bytes.
  * align must be a power of 2 (typically 8).
- * Returns NULL only if the system is out of memory.
+ * When the system is out of memory the OOM handler is called; NULL is
+ * returned only if it returns.
  */
 void *arena_alloc(Arena *arena, size_t size, size_t align);
 
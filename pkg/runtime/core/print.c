@@ -0,0 +1,270 @@
+#include "print.h"
+#include "../table/table.h"
+#include "status.h"
+#include <errno.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <unistd.h>
+
+int org_write_bytes(int fd, const char *data, size_t len) {
+  while (len > 0) {
+    ssize_t n = write(fd, data, len);
+    if (n < 0) {
+      if (errno == EINTR)
+        continue;
+      return -1;
+    }
+    data += n;
+    len -= (size_t)n;
+  }
+  return 0;
+}
+
+int org_write_string(int fd, OrgValue s) {
+  if (!ORG_IS_PTR(s) || org_get_type(s) != ORG_TYPE_STRING)
+    return -1;
+  return org_write_bytes(fd, org_string_data(s), org_string_byte_len(s));
+}
+
+int org_write_error(int fd, OrgValue e) {
+  const char *msg = org_error_message(e);
+  if (!*msg)
+    return org_write_bytes(fd, "Error\n", 6);
+  if (org_write_bytes(fd, "Error: ", 7) < 0 ||
+      org_write_bytes(fd, msg, strlen(msg)) < 0)
+    return -1;
+  return org_write_bytes(fd, "\n", 1);
+}
+
+/*
+ * Where text goes: the file descriptor fd, or, if fd is -1, a buffer
+ * growing with malloc, for org_display.
+ */
+typedef struct Out {
+  int fd;
+  char *buf;
+  size_t len, cap;
+} Out;
+
+static int out_bytes(Out *o, const char *data, size_t len) {
+  if (o->fd >= 0)
+    return org_write_bytes(o->fd, data, len);
+  if (len == 0)
+    return 0; /* o->buf may still be NULL */
+  if (o->len + len > o->cap) {
+    size_t cap = o->cap ? o->cap : 64;
+    while (cap < o->len + len)
+      cap *= 2;
+    char *buf = realloc(o->buf, cap);
+    if (!buf)
+      return -1;
+    o->buf = buf;
+    o->cap = cap;
+  }
+  memcpy(o->buf + o->len, data, len);
+  o->len += len;
+  return 0;
+}
+
+static int out_string(Out *o, OrgValue s) {
+  return out_bytes(o, org_string_data(s), org_string_byte_len(s));
+}
+
+/* Free a string allocated by mpz_get_str/mpq_get_str. */
+static void free_gmp_str(char *str) {
+  void (*gmp_free)(void *, size_t);
+  mp_get_memory_functions(NULL, NULL, &gmp_free);
+  gmp_free(str, strlen(str) + 1);
+}
+
+/* Write a GMP-allocated string and free it. */
+static int write_gmp_str(Out *o, char *str) {
+  int rc = out_bytes(o, str, strlen(str));
+  free_gmp_str(str);
+  return rc;
+}
+
+void org_decimal_digits(mpz_t n, const mpq_t q, int32_t scale) {
+  mpz_t r, ten;
+  mpz_inits(r, ten, NULL);
+  mpz_ui_pow_ui(ten, 10, scale > 0 ? (unsigned long)scale : 0);
+  mpz_mul(n, mpq_numref(q), ten);
+  mpz_tdiv_qr(n, r, n, mpq_denref(q));
+  mpz_abs(r, r);
+  mpz_mul_2exp(r, r, 1);
+  if (mpz_cmp(r, mpq_denref(q)) >= 0) {
+    if (mpq_sgn(q) < 0)
+      mpz_sub_ui(n, n, 1);
+    else
+      mpz_add_ui(n, n, 1);
+  }
+  mpz_clears(r, ten, NULL);
+}
+
+/* Write q rounded half away from zero to scale digits after the point. */
+static int write_decimal(Out *o, const mpq_t q, int32_t scale) {
+  mpz_t n;
+  mpz_init(n);
+  org_decimal_digits(n, q, scale);
+
+  int neg = mpz_sgn(n) < 0;
+  mpz_abs(n, n);
+  char *digits = mpz_get_str(NULL, 10, n);
+  size_t len = strlen(digits);
+  int rc = 0;
+  if (neg)
+    rc = out_bytes(o, "-", 1);
+  if (scale <= 0) {
+    if (rc == 0)
+      rc = out_bytes(o, digits, len);
+  } else {
+    size_t sc = (size_t)scale;
+    if (rc == 0 && len <= sc) {
+      /* 0.0…0digits */
+      rc = out_bytes(o, "0.", 2);
+      for (size_t i = len; rc == 0 && i < sc; i++)
+        rc = out_bytes(o, "0", 1);
+      if (rc == 0)
+        rc = out_bytes(o, digits, len);
+    } else if (rc == 0) {
+      rc = out_bytes(o, digits, len - sc);
+      if (rc == 0)
+        rc = out_bytes(o, ".", 1);
+      if (rc == 0)
+        rc = out_bytes(o, digits + len - sc, sc);
+    }
+  }
+  free_gmp_str(digits);
+  mpz_clear(n);
+  return rc;
+}
+
+static int write_value(Out *o, OrgValue v) {
+  char buf[32];
+  if (ORG_IS_SMALL(v)) {
+    int n = snprintf(buf, sizeof buf, "%lld",
+                     (long long)ORG_UNTAG_SMALL_INT(v));
+    return out_bytes(o, buf, (size_t)n);
+  }
+  if (ORG_IS_TRUE(v))
+    return out_bytes(o, "true", 4);
+  if (ORG_IS_FALSE(v))
+    return out_bytes(o, "false", 5);
+  if (org_is_error(v)) {
+    const char *msg = org_error_message(v);
+    if (!*msg)
+      return out_bytes(o, "Error", 5);
+    if (out_bytes(o, "Error: ", 7) < 0)
+      return -1;
+    return out_bytes(o, msg, strlen(msg));
+  }
+  if (!ORG_IS_PTR(v))
+    return out_bytes(o, "<unused>", 8);
+
+  switch (org_get_type(v)) {
+  case ORG_TYPE_STRING:
+    return out_string(o, v);
+  case ORG_TYPE_BIGINT:
+    return write_gmp_str(o, mpz_get_str(NULL, 10, *org_get_bigint(v)));
+  case ORG_TYPE_RATIONAL:
+    return write_gmp_str(o, mpq_get_str(NULL, 10, *org_get_rational(v)));
+  case ORG_TYPE_DECIMAL:
+    return write_decimal(o, *org_get_decimal(v), org_get_decimal_scale(v));
+  case ORG_TYPE_TABLE: {
+    OrgTable *t = (OrgTable *)ORG_GET_PTR(v);
+    if (out_bytes(o, "[", 1) < 0)
+      return -1;
+    for (uint32_t i = 0; i < t->next_index; i++) {
+      if (i > 0 && out_bytes(o, " ", 1) < 0)
+        return -1;
+      OrgValue e = org_table_get(v, ORG_TAG_SMALL_INT(i));
+      if (ORG_IS_PTR(e) && org_get_type(e) == ORG_TYPE_STRING) {
+        /* Quote nested strings so [1 "2"] and [1 2] differ. */
+        if (out_bytes(o, "\"", 1) < 0 || out_string(o, e) < 0 ||
+            out_bytes(o, "\"", 1) < 0)
+          return -1;
+      } else if (write_value(o, e) < 0) {
+        return -1;
+      }
+    }
+    return out_bytes(o, "]", 1);
+  }
+  case ORG_TYPE_QUANTITY: {
+    OrgQuantity *q = (OrgQuantity *)ORG_GET_PTR(v);
+    return out_bytes(o, q->text, q->byte_len);
+  }
+  case ORG_TYPE_BREAK:
+    if (out_bytes(o, "(break ", 7) < 0 ||
+        write_value(o, org_break_value(v)) < 0)
+      return -1;
+    return out_bytes(o, ")", 1);
+  case ORG_TYPE_CLOSURE:
+    return out_bytes(o, "<block>", 7);
+  case ORG_TYPE_RESOURCE:
+    return out_bytes(o, "<resource>", 10);
+  default:
+    return out_bytes(o, "<value>", 7);
+  }
+}
+
+int org_write_value(int fd, OrgValue v) {
+  Out o = {.fd = fd};
+  return write_value(&o, v);
+}
+
+OrgValue org_display(Arena *arena, OrgValue v) {
+  if (ORG_IS_PTR(v) && org_get_type(v) == ORG_TYPE_STRING)
+    return v;
+  Out o = {.fd = -1};
+  OrgValue s = ORG_ERROR;
+  if (write_value(&o, v) == 0)
+    s = org_make_string(arena, o.buf ? o.buf : "", o.len);
+  free(o.buf);
+  return s;
+}
+
+OrgValue org_concat(Arena *arena, OrgValue a, OrgValue b) {
+  if (org_is_error(a))
+    return a;
+  if (org_is_error(b))
+    return b;
+  Out o = {.fd = -1};
+  OrgValue s = ORG_ERROR;
+  if (write_value(&o, a) == 0 && write_value(&o, b) == 0)
+    s = org_make_string(arena, o.buf ? o.buf : "", o.len);
+  free(o.buf);
+  return s;
+}
+
+/* Write the positional elements of a table, one per line. */
+static int write_lines(int fd, OrgValue table) {
+  OrgTable *t = (OrgTable *)ORG_GET_PTR(table);
+  for (uint32_t i = 0; i < t->next_index; i++) {
+    if (org_write_value(fd, org_table_get(table, ORG_TAG_SMALL_INT(i))) < 0 ||
+        org_write_bytes(fd, "\n", 1) < 0)
+      return -1;
+  }
+  return 0;
+}
+
+int org_finish(OrgValue result) {
+  if (org_is_error(result)) {
+    org_write_error(STDERR_FILENO, result);
+    return ORG_EXIT_ERROR;
+  }
+  if (org_is_integer(result)) {
+    if (ORG_IS_SMALL(result) && ORG_UNTAG_SMALL_INT(result) >= 0 &&
+        ORG_UNTAG_SMALL_INT(result) <= 255)
+      return (int)ORG_UNTAG_SMALL_INT(result);
+    if (org_write_bytes(STDERR_FILENO, "Error: exit status ", 19) == 0 &&
+        org_write_value(STDERR_FILENO, result) == 0)
+      org_write_bytes(STDERR_FILENO, " out of range 0-255\n", 20);
+    return ORG_EXIT_ERROR;
+  }
+  if (ORG_IS_PTR(result) && org_get_type(result) == ORG_TYPE_TABLE) {
+    if (write_lines(STDOUT_FILENO, result) < 0)
+      return ORG_EXIT_RESOURCE;
+  }
+  return ORG_EXIT_OK;
+}
@@ -0,0 +1,65 @@
+#ifndef ORG_PRINT_H
+#define ORG_PRINT_H
+
+#include "values.h"
+
+/*
+ * Output of OrgLang values.
+ *
+ * Strings come from the program and its input, so they are written as
+ * raw bytes and never used as a printf format: "100% done %s %n" prints
+ * exactly those characters. Generated code and runtime diagnostics must
+ * go through these helpers (or pass user text as a "%s" argument).
+ */
+
+/* Write the bytes of a String to fd. Returns 0, or -1 on a write error. */
+int org_write_string(int fd, OrgValue s);
+
+/* Write len raw bytes to fd, retrying short writes. Returns 0 or -1. */
+int org_write_bytes(int fd, const char *data, size_t len);
+
+/*
+ * Write "Error: <message>\n" for an Error value ("Error\n" for the
+ * ORG_ERROR singleton). Returns 0 or -1.
+ */
+int org_write_error(int fd, OrgValue e);
+
+/*
+ * Write v as text: Strings raw, numbers in source notation (Decimals to
+ * their scale), booleans, "Error: <message>", and tables as their
+ * positional elements in brackets, nested strings quoted. Returns 0 or -1.
+ */
+int org_write_value(int fd, OrgValue v);
+
+/*
+ * v as text, as org_write_value writes it, in a String allocated in
+ * arena: what -> @stdout writes, and interpolation inserts. A String is
+ * itself. ORG_ERROR if the text cannot be allocated.
+ */
+OrgValue org_display(Arena *arena, OrgValue v);
+
+/*
+ * The String of the text of a followed by that of b, as org_display
+ * makes them: "n = ${n}" is two of these. An Error a, else b, is the
+ * result.
+ */
+OrgValue org_concat(Arena *arena, OrgValue a, OrgValue b);
+
+/*
+ * Set n to the digits of a Decimal: q * 10^scale rounded half away from
+ * zero, so the Decimal is n / 10^scale. n must be initialized.
+ */
+void org_decimal_digits(mpz_t n, const mpq_t q, int32_t scale);
+
+/*
+ * End of program: turn the result of main into the exit status (see
+ * status.h). An Error is reported on stderr and exits ORG_EXIT_ERROR; an
+ * Integer from 0 to 255 is the status itself (any other Integer is
+ * reported as out of range and exits ORG_EXIT_ERROR); a Table has its
+ * positional elements written to stdout, one per line, and exits
+ * ORG_EXIT_OK (ORG_EXIT_RESOURCE if the write fails). Any other value
+ * exits ORG_EXIT_OK. Generated main() returns org_finish(result).
+ */
+int org_finish(OrgValue result);
+
+#endif /* ORG_PRINT_H */
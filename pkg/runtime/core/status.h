@@ -0,0 +1,16 @@
+#ifndef ORG_STATUS_H
+#define ORG_STATUS_H
+
+/*
+ * Exit status of compiled programs.
+ *
+ * Scripts compose OrgLang programs in shells, so every way a program can
+ * end maps to a fixed status:
+ */
+#define ORG_EXIT_OK 0        /* main finished with a value that is not an Error */
+#define ORG_EXIT_ERROR 1     /* an uncaught Error reached the scheduler */
+#define ORG_EXIT_NO_MAIN 2   /* the entry module defines no main */
+#define ORG_EXIT_OOM 3       /* the arena could not get memory */
+#define ORG_EXIT_RESOURCE 4  /* a resource failed, e.g. writing to @stdout */
+
+#endif /* ORG_STATUS_H */
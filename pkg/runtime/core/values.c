@@ -1,4 +1,5 @@
 #include "values.h"
+#include <stdio.h>
 #include <stdlib.h>
 #include <string.h>
 
@@ -169,6 +170,160 @@ OrgValue org_make_decimal_str(Arena *arena, const char *str) {
   return ORG_TAG_PTR_VAL(d);
 }
 
+/* ---- Error ---- */
+
+OrgValue org_make_error(Arena *arena, const char *message) {
+  size_t len = strlen(message);
+  size_t total = sizeof(OrgErrorObj) + len + 1;
+  OrgErrorObj *e = (OrgErrorObj *)arena_alloc(arena, total, 8);
+  if (!e)
+    return ORG_ERROR;
+
+  e->header.type = ORG_TYPE_ERROR_OBJ;
+  e->header.flags = 0;
+  e->header._pad = 0;
+  e->header.size = (uint32_t)total;
+  e->byte_len = (uint32_t)len;
+  e->_pad2 = 0;
+  memcpy(e->message, message, len + 1);
+
+  return ORG_TAG_PTR_VAL(e);
+}
+
+const char *org_error_message(OrgValue v) {
+  if (ORG_IS_PTR(v) && org_get_type(v) == ORG_TYPE_ERROR_OBJ)
+    return ((OrgErrorObj *)ORG_GET_PTR(v))->message;
+  return "";
+}
+
+OrgValue org_operand(Arena *arena, OrgValue v, const char *side,
+                     const char *block) {
+  if (!ORG_IS_UNUSED(v))
+    return v;
+  char msg[256];
+  snprintf(msg, sizeof msg, "block %s expects a %s operand",
+           block ? block : "<anonymous>", side);
+  return org_make_error(arena, msg);
+}
+
+/* ---- Quantity ---- */
+
+/* The units of quantities (token.Units) and their size in seconds or bytes. */
+static const struct {
+  const char *unit;
+  OrgQuantityKind kind;
+  const char *scale;
+} units[] = {
+    {"ns", ORG_QUANTITY_DURATION, "1/1000000000"},
+    {"us", ORG_QUANTITY_DURATION, "1/1000000"},
+    {"ms", ORG_QUANTITY_DURATION, "1/1000"},
+    {"s", ORG_QUANTITY_DURATION, "1"},
+    {"m", ORG_QUANTITY_DURATION, "60"},
+    {"h", ORG_QUANTITY_DURATION, "3600"},
+    {"B", ORG_QUANTITY_SIZE, "1"},
+    {"KB", ORG_QUANTITY_SIZE, "1000"},
+    {"MB", ORG_QUANTITY_SIZE, "1000000"},
+    {"GB", ORG_QUANTITY_SIZE, "1000000000"},
+    {"TB", ORG_QUANTITY_SIZE, "1000000000000"},
+    {"KiB", ORG_QUANTITY_SIZE, "1024"},
+    {"MiB", ORG_QUANTITY_SIZE, "1048576"},
+    {"GiB", ORG_QUANTITY_SIZE, "1073741824"},
+    {"TiB", ORG_QUANTITY_SIZE, "1099511627776"},
+};
+
+/* q as an Integer if it is one, else a Rational. */
+static OrgValue exact(Arena *arena, const mpq_t q) {
+  if (mpz_cmp_ui(mpq_denref(q), 1) != 0)
+    return org_make_rational_mpz(arena, mpq_numref(q), mpq_denref(q));
+  if (mpz_fits_slong_p(mpq_numref(q)) &&
+      org_small_fits((int64_t)mpz_get_si(mpq_numref(q))))
+    return ORG_TAG_SMALL_INT((int64_t)mpz_get_si(mpq_numref(q)));
+  OrgValue b = org_make_bigint_si(arena, 0);
+  if (!org_is_error(b))
+    mpz_set(*org_get_bigint(b), mpq_numref(q));
+  return b;
+}
+
+OrgValue org_make_quantity(Arena *arena, const char *text) {
+  size_t len = strlen(text);
+  size_t digits = len;
+  while (digits > 0 && (text[digits - 1] < '0' || text[digits - 1] > '9'))
+    digits--;
+  const char *unit = text + digits;
+  size_t u = 0;
+  while (u < sizeof units / sizeof units[0] && strcmp(units[u].unit, unit) != 0)
+    u++;
+  if (digits == 0 || u == sizeof units / sizeof units[0]) {
+    char msg[256];
+    snprintf(msg, sizeof msg, "unknown unit %s", unit);
+    return org_make_error(arena, msg);
+  }
+
+  /* The number without its point, over 10^(digits after the point). */
+  char *num = (char *)arena_alloc(arena, digits + 1, 1);
+  if (!num)
+    return ORG_ERROR;
+  size_t n = 0, scale = 0;
+  for (size_t i = 0; i < digits; i++) {
+    if (text[i] == '.')
+      scale = digits - i - 1;
+    else
+      num[n++] = text[i];
+  }
+  num[n] = '\0';
+  mpq_t q, s;
+  mpq_init(q);
+  mpq_init(s);
+  mpz_set_str(mpq_numref(q), num, 10);
+  mpz_ui_pow_ui(mpq_denref(q), 10, (unsigned long)scale);
+  mpq_canonicalize(q);
+  mpq_set_str(s, units[u].scale, 10);
+  mpq_mul(q, q, s);
+  OrgValue amount = exact(arena, q);
+  mpq_clear(q);
+  mpq_clear(s);
+  if (org_is_error(amount))
+    return amount;
+
+  size_t total = sizeof(OrgQuantity) + len + 1;
+  OrgQuantity *qty = (OrgQuantity *)arena_alloc(arena, total, 8);
+  if (!qty)
+    return ORG_ERROR;
+  qty->header.type = ORG_TYPE_QUANTITY;
+  qty->header.flags = (uint8_t)units[u].kind;
+  qty->header._pad = 0;
+  qty->header.size = (uint32_t)total;
+  qty->amount = amount;
+  qty->byte_len = (uint32_t)len;
+  qty->_pad2 = 0;
+  memcpy(qty->text, text, len + 1);
+  return ORG_TAG_PTR_VAL(qty);
+}
+
+/* ---- Break ---- */
+
+OrgValue org_make_break(Arena *arena, OrgValue value) {
+  if (org_is_error(value))
+    return value;
+  OrgBreak *b = (OrgBreak *)arena_alloc(arena, sizeof(OrgBreak), 8);
+  if (!b)
+    return ORG_ERROR;
+  b->header.type = ORG_TYPE_BREAK;
+  b->header.flags = 0;
+  b->header._pad = 0;
+  b->header.size = (uint32_t)sizeof(OrgBreak);
+  b->value = value;
+  return ORG_TAG_PTR_VAL(b);
+}
+
+int org_is_break(OrgValue v) {
+  return ORG_IS_PTR(v) && org_get_type(v) == ORG_TYPE_BREAK;
+}
+
+OrgValue org_break_value(OrgValue v) {
+  return ((OrgBreak *)ORG_GET_PTR(v))->value;
+}
+
 /* ---- Type name ---- */
 
 const char *org_type_name(OrgValue v) {
@@ -201,6 +356,10 @@ const char *org_type_name(OrgValue v) {
       return "Resource";
     case ORG_TYPE_ERROR_OBJ:
       return "ErrorObj";
+    case ORG_TYPE_QUANTITY:
+      return "Quantity";
+    case ORG_TYPE_BREAK:
+      return "Break";
     }
   }
   return "Unknown";
@@ -66,6 +66,8 @@ typedef enum OrgType {
   ORG_TYPE_CLOSURE,
   ORG_TYPE_RESOURCE,
   ORG_TYPE_ERROR_OBJ,
+  ORG_TYPE_QUANTITY,
+  ORG_TYPE_BREAK,
 } OrgType;
 
 /*
@@ -176,6 +178,96 @@ const char *org_string_data(OrgValue v);
 uint32_t org_string_byte_len(OrgValue v);
 uint32_t org_string_codepoint_len(OrgValue v);
 
+/* ---- Error objects ---- */
+
+/*
+ * An Error carrying a message. Operations that have nothing to add return
+ * the ORG_ERROR singleton; OrgLang code sees both as Error.
+ */
+typedef struct OrgErrorObj {
+  OrgObject header;
+  uint32_t byte_len; /* Length of message in bytes, without the NUL */
+  uint32_t _pad2;
+  char message[];    /* NUL-terminated */
+} OrgErrorObj;
+
+/* Create an Error with a message */
+OrgValue org_make_error(Arena *arena, const char *message);
+
+/* True for the ORG_ERROR singleton and for Error objects */
+static inline int org_is_error(OrgValue v) {
+  return ORG_IS_ERROR(v) ||
+         (ORG_IS_PTR(v) && org_get_type(v) == ORG_TYPE_ERROR_OBJ);
+}
+
+/* Message of an Error ("" for the ORG_ERROR singleton) */
+const char *org_error_message(OrgValue v);
+
+/*
+ * The value a block receives as its left or right operand. A block called
+ * without that operand (a binary block used as prefix, or a unary block
+ * used infix) is passed ORG_UNUSED; it gets an Error naming the block
+ * instead, e.g. "block fact expects a left operand", so misuse surfaces
+ * as an OrgLang Error rather than a crash.
+ */
+OrgValue org_operand(Arena *arena, OrgValue v, const char *side,
+                     const char *block);
+
+/* ---- Quantity representation (durations and sizes) ---- */
+
+typedef enum OrgQuantityKind {
+  ORG_QUANTITY_DURATION,
+  ORG_QUANTITY_SIZE,
+} OrgQuantityKind;
+
+/*
+ * A quantity literal: 250ms, 10KB. It prints as written, while operators
+ * read it as its amount, the exact number of seconds or bytes.
+ */
+typedef struct OrgQuantity {
+  OrgObject header; /* flags: OrgQuantityKind */
+  OrgValue amount;  /* Integer or Rational */
+  uint32_t byte_len;
+  uint32_t _pad2;
+  char text[];      /* as written, NUL-terminated */
+} OrgQuantity;
+
+/*
+ * The quantity written as text, a number then a unit (250ms, 1.5KiB); an
+ * Error for an unknown unit.
+ */
+OrgValue org_make_quantity(Arena *arena, const char *text);
+
+static inline int org_is_quantity(OrgValue v) {
+  return ORG_IS_PTR(v) && org_get_type(v) == ORG_TYPE_QUANTITY;
+}
+
+/* The amount of a quantity; any other value is itself. */
+static inline OrgValue org_amount(OrgValue v) {
+  return org_is_quantity(v) ? ((OrgQuantity *)ORG_GET_PTR(v))->amount : v;
+}
+
+/* ---- Break (the result ending a loop) ---- */
+
+/*
+ * break value: the result of a loop's step that ends the loop with value.
+ * Outside a loop it is a plain value, printed as (break value).
+ */
+typedef struct OrgBreak {
+  OrgObject header;
+  OrgValue value;
+} OrgBreak;
+
+/* break value; an Error value is returned as it is. */
+OrgValue org_make_break(Arena *arena, OrgValue value);
+
+/*
+ * Whether v is a break, and the value it ends a loop with. Functions
+ * rather than inline, for the loops of the LLVM backend to call.
+ */
+int org_is_break(OrgValue v);
+OrgValue org_break_value(OrgValue v);
+
 /* ---- Type query ---- */
 const char *org_type_name(OrgValue v);
 
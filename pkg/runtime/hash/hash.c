@@ -0,0 +1,309 @@
+#include "hash.h"
+#include "../table/table.h"
+#include <stdlib.h>
+#include <string.h>
+
+/* ---- SHA-256 (FIPS 180-4) ---- */
+
+static const uint32_t sha256_k[64] = {
+    0x428a2f98, 0x71374491, 0xb5c0fbcf, 0xe9b5dba5, 0x3956c25b, 0x59f111f1,
+    0x923f82a4, 0xab1c5ed5, 0xd807aa98, 0x12835b01, 0x243185be, 0x550c7dc3,
+    0x72be5d74, 0x80deb1fe, 0x9bdc06a7, 0xc19bf174, 0xe49b69c1, 0xefbe4786,
+    0x0fc19dc6, 0x240ca1cc, 0x2de92c6f, 0x4a7484aa, 0x5cb0a9dc, 0x76f988da,
+    0x983e5152, 0xa831c66d, 0xb00327c8, 0xbf597fc7, 0xc6e00bf3, 0xd5a79147,
+    0x06ca6351, 0x14292967, 0x27b70a85, 0x2e1b2138, 0x4d2c6dfc, 0x53380d13,
+    0x650a7354, 0x766a0abb, 0x81c2c92e, 0x92722c85, 0xa2bfe8a1, 0xa81a664b,
+    0xc24b8b70, 0xc76c51a3, 0xd192e819, 0xd6990624, 0xf40e3585, 0x106aa070,
+    0x19a4c116, 0x1e376c08, 0x2748774c, 0x34b0bcb5, 0x391c0cb3, 0x4ed8aa4a,
+    0x5b9cca4f, 0x682e6ff3, 0x748f82ee, 0x78a5636f, 0x84c87814, 0x8cc70208,
+    0x90befffa, 0xa4506ceb, 0xbef9a3f7, 0xc67178f2,
+};
+
+static uint32_t rotr(uint32_t x, int n) { return (x >> n) | (x << (32 - n)); }
+static uint32_t rotl(uint32_t x, int n) { return (x << n) | (x >> (32 - n)); }
+
+static void sha256_block(OrgSha256 *h, const uint8_t *p) {
+  uint32_t w[64];
+  for (int i = 0; i < 16; i++)
+    w[i] = (uint32_t)p[4 * i] << 24 | (uint32_t)p[4 * i + 1] << 16 |
+           (uint32_t)p[4 * i + 2] << 8 | (uint32_t)p[4 * i + 3];
+  for (int i = 16; i < 64; i++) {
+    uint32_t s0 = rotr(w[i - 15], 7) ^ rotr(w[i - 15], 18) ^ (w[i - 15] >> 3);
+    uint32_t s1 = rotr(w[i - 2], 17) ^ rotr(w[i - 2], 19) ^ (w[i - 2] >> 10);
+    w[i] = w[i - 16] + s0 + w[i - 7] + s1;
+  }
+  uint32_t a = h->state[0], b = h->state[1], c = h->state[2], d = h->state[3];
+  uint32_t e = h->state[4], f = h->state[5], g = h->state[6], k = h->state[7];
+  for (int i = 0; i < 64; i++) {
+    uint32_t s1 = rotr(e, 6) ^ rotr(e, 11) ^ rotr(e, 25);
+    uint32_t t1 = k + s1 + ((e & f) ^ (~e & g)) + sha256_k[i] + w[i];
+    uint32_t s0 = rotr(a, 2) ^ rotr(a, 13) ^ rotr(a, 22);
+    uint32_t t2 = s0 + ((a & b) ^ (a & c) ^ (b & c));
+    k = g;
+    g = f;
+    f = e;
+    e = d + t1;
+    d = c;
+    c = b;
+    b = a;
+    a = t1 + t2;
+  }
+  h->state[0] += a;
+  h->state[1] += b;
+  h->state[2] += c;
+  h->state[3] += d;
+  h->state[4] += e;
+  h->state[5] += f;
+  h->state[6] += g;
+  h->state[7] += k;
+}
+
+void org_sha256_init(OrgSha256 *h) {
+  static const uint32_t iv[8] = {0x6a09e667, 0xbb67ae85, 0x3c6ef372,
+                                 0xa54ff53a, 0x510e527f, 0x9b05688c,
+                                 0x1f83d9ab, 0x5be0cd19};
+  memcpy(h->state, iv, sizeof iv);
+  h->len = 0;
+}
+
+/*
+ * Feed data to a 64-byte block function, buffering partial blocks in
+ * block; len is the count of bytes fed so far.
+ */
+#define FEED(h, data, n, fn)                                                   \
+  do {                                                                         \
+    const uint8_t *p_ = (const uint8_t *)(data);                               \
+    size_t n_ = (n);                                                           \
+    size_t used_ = (size_t)((h)->len % 64);                                    \
+    (h)->len += n_;                                                            \
+    if (used_) {                                                               \
+      size_t take_ = 64 - used_ < n_ ? 64 - used_ : n_;                        \
+      memcpy((h)->block + used_, p_, take_);                                   \
+      p_ += take_;                                                             \
+      n_ -= take_;                                                             \
+      if (used_ + take_ < 64)                                                  \
+        break;                                                                 \
+      fn((h), (h)->block);                                                     \
+    }                                                                          \
+    for (; n_ >= 64; p_ += 64, n_ -= 64)                                       \
+      fn((h), p_);                                                             \
+    memcpy((h)->block, p_, n_);                                                \
+  } while (0)
+
+void org_sha256_update(OrgSha256 *h, const void *data, size_t len) {
+  FEED(h, data, len, sha256_block);
+}
+
+void org_sha256_final(OrgSha256 *h, uint8_t out[ORG_SHA256_SIZE]) {
+  uint64_t bits = h->len * 8;
+  uint8_t pad[72] = {0x80};
+  size_t used = (size_t)(h->len % 64);
+  size_t n = (used < 56 ? 56 : 120) - used;
+  for (int i = 0; i < 8; i++)
+    pad[n + (size_t)i] = (uint8_t)(bits >> (56 - 8 * i));
+  org_sha256_update(h, pad, n + 8);
+  for (int i = 0; i < 8; i++) {
+    out[4 * i] = (uint8_t)(h->state[i] >> 24);
+    out[4 * i + 1] = (uint8_t)(h->state[i] >> 16);
+    out[4 * i + 2] = (uint8_t)(h->state[i] >> 8);
+    out[4 * i + 3] = (uint8_t)h->state[i];
+  }
+}
+
+/* ---- MD5 (RFC 1321) ---- */
+
+static const uint32_t md5_k[64] = {
+    0xd76aa478, 0xe8c7b756, 0x242070db, 0xc1bdceee, 0xf57c0faf, 0x4787c62a,
+    0xa8304613, 0xfd469501, 0x698098d8, 0x8b44f7af, 0xffff5bb1, 0x895cd7be,
+    0x6b901122, 0xfd987193, 0xa679438e, 0x49b40821, 0xf61e2562, 0xc040b340,
+    0x265e5a51, 0xe9b6c7aa, 0xd62f105d, 0x02441453, 0xd8a1e681, 0xe7d3fbc8,
+    0x21e1cde6, 0xc33707d6, 0xf4d50d87, 0x455a14ed, 0xa9e3e905, 0xfcefa3f8,
+    0x676f02d9, 0x8d2a4c8a, 0xfffa3942, 0x8771f681, 0x6d9d6122, 0xfde5380c,
+    0xa4beea44, 0x4bdecfa9, 0xf6bb4b60, 0xbebfbc70, 0x289b7ec6, 0xeaa127fa,
+    0xd4ef3085, 0x04881d05, 0xd9d4d039, 0xe6db99e5, 0x1fa27cf8, 0xc4ac5665,
+    0xf4292244, 0x432aff97, 0xab9423a7, 0xfc93a039, 0x655b59c3, 0x8f0ccc92,
+    0xffeff47d, 0x85845dd1, 0x6fa87e4f, 0xfe2ce6e0, 0xa3014314, 0x4e0811a1,
+    0xf7537e82, 0xbd3af235, 0x2ad7d2bb, 0xeb86d391,
+};
+
+static const int md5_s[64] = {7,  12, 17, 22, 7,  12, 17, 22, 7,  12, 17,
+                              22, 7,  12, 17, 22, 5,  9,  14, 20, 5,  9,
+                              14, 20, 5,  9,  14, 20, 5,  9,  14, 20, 4,
+                              11, 16, 23, 4,  11, 16, 23, 4,  11, 16, 23,
+                              4,  11, 16, 23, 6,  10, 15, 21, 6,  10, 15,
+                              21, 6,  10, 15, 21, 6,  10, 15, 21};
+
+static void md5_block(OrgMd5 *h, const uint8_t *p) {
+  uint32_t m[16];
+  for (int i = 0; i < 16; i++)
+    m[i] = (uint32_t)p[4 * i] | (uint32_t)p[4 * i + 1] << 8 |
+           (uint32_t)p[4 * i + 2] << 16 | (uint32_t)p[4 * i + 3] << 24;
+  uint32_t a = h->state[0], b = h->state[1], c = h->state[2], d = h->state[3];
+  for (int i = 0; i < 64; i++) {
+    uint32_t f;
+    int g;
+    if (i < 16) {
+      f = (b & c) | (~b & d);
+      g = i;
+    } else if (i < 32) {
+      f = (d & b) | (~d & c);
+      g = (5 * i + 1) % 16;
+    } else if (i < 48) {
+      f = b ^ c ^ d;
+      g = (3 * i + 5) % 16;
+    } else {
+      f = c ^ (b | ~d);
+      g = (7 * i) % 16;
+    }
+    uint32_t t = d;
+    d = c;
+    c = b;
+    b = b + rotl(a + f + md5_k[i] + m[g], md5_s[i]);
+    a = t;
+  }
+  h->state[0] += a;
+  h->state[1] += b;
+  h->state[2] += c;
+  h->state[3] += d;
+}
+
+void org_md5_init(OrgMd5 *h) {
+  h->state[0] = 0x67452301;
+  h->state[1] = 0xefcdab89;
+  h->state[2] = 0x98badcfe;
+  h->state[3] = 0x10325476;
+  h->len = 0;
+}
+
+void org_md5_update(OrgMd5 *h, const void *data, size_t len) {
+  FEED(h, data, len, md5_block);
+}
+
+void org_md5_final(OrgMd5 *h, uint8_t out[ORG_MD5_SIZE]) {
+  uint64_t bits = h->len * 8;
+  uint8_t pad[72] = {0x80};
+  size_t used = (size_t)(h->len % 64);
+  size_t n = (used < 56 ? 56 : 120) - used;
+  for (int i = 0; i < 8; i++)
+    pad[n + (size_t)i] = (uint8_t)(bits >> (8 * i));
+  org_md5_update(h, pad, n + 8);
+  for (int i = 0; i < 4; i++) {
+    out[4 * i] = (uint8_t)h->state[i];
+    out[4 * i + 1] = (uint8_t)(h->state[i] >> 8);
+    out[4 * i + 2] = (uint8_t)(h->state[i] >> 16);
+    out[4 * i + 3] = (uint8_t)(h->state[i] >> 24);
+  }
+}
+
+/* ---- CRC-32 (IEEE 802.3, reflected polynomial 0xedb88320) ---- */
+
+/* The CRC of each nibble; two lookups per byte keep the table small. */
+static const uint32_t crc32_nibble[16] = {
+    0x00000000, 0x1db71064, 0x3b6e20c8, 0x26d930ac, 0x76dc4190, 0x6b6b51f4,
+    0x4db26158, 0x5005713c, 0xedb88320, 0xf00f9344, 0xd6d6a3e8, 0xcb61b38c,
+    0x9b64c2b0, 0x86d3d2d4, 0xa00ae278, 0xbdbdf21c,
+};
+
+uint32_t org_crc32_update(uint32_t crc, const void *data, size_t len) {
+  const uint8_t *p = data;
+  crc = ~crc;
+  for (size_t i = 0; i < len; i++) {
+    crc = crc32_nibble[(crc ^ p[i]) & 15] ^ (crc >> 4);
+    crc = crc32_nibble[(crc ^ (p[i] >> 4)) & 15] ^ (crc >> 4);
+  }
+  return ~crc;
+}
+
+/* ---- OrgValue level ---- */
+
+/*
+ * The bytes of data in a malloc'd buffer, or NULL with *err set when data
+ * is neither a String nor a Table of byte Integers.
+ */
+static uint8_t *data_bytes(OrgValue data, size_t *len, const char **err) {
+  if (ORG_IS_PTR(data) && org_get_type(data) == ORG_TYPE_STRING) {
+    *len = org_string_byte_len(data);
+    uint8_t *buf = malloc(*len ? *len : 1);
+    if (!buf) {
+      *err = "out of memory";
+      return NULL;
+    }
+    memcpy(buf, org_string_data(data), *len);
+    return buf;
+  }
+  if (!ORG_IS_PTR(data) || org_get_type(data) != ORG_TYPE_TABLE) {
+    *err = "hash needs a String or a Table of bytes";
+    return NULL;
+  }
+  uint32_t n = org_table_count(data);
+  uint8_t *buf = malloc(n ? n : 1);
+  if (!buf) {
+    *err = "out of memory";
+    return NULL;
+  }
+  for (uint32_t i = 0; i < n; i++) {
+    OrgValue b = org_table_get(data, ORG_TAG_SMALL_INT(i));
+    if (!ORG_IS_SMALL(b) || ORG_UNTAG_SMALL_INT(b) < 0 ||
+        ORG_UNTAG_SMALL_INT(b) > 255) {
+      free(buf);
+      *err = "hash needs a String or a Table of bytes";
+      return NULL;
+    }
+    buf[i] = (uint8_t)ORG_UNTAG_SMALL_INT(b);
+  }
+  *len = n;
+  return buf;
+}
+
+OrgValue org_hash(Arena *arena, OrgHashAlg alg, OrgValue data, int raw) {
+  if (org_is_error(data))
+    return data;
+  size_t len;
+  const char *err = NULL;
+  uint8_t *buf = data_bytes(data, &len, &err);
+  if (!buf)
+    return org_make_error(arena, err);
+
+  uint8_t digest[ORG_SHA256_SIZE];
+  size_t size;
+  switch (alg) {
+  case ORG_HASH_SHA256: {
+    OrgSha256 h;
+    org_sha256_init(&h);
+    org_sha256_update(&h, buf, len);
+    org_sha256_final(&h, digest);
+    size = ORG_SHA256_SIZE;
+    break;
+  }
+  case ORG_HASH_MD5: {
+    OrgMd5 h;
+    org_md5_init(&h);
+    org_md5_update(&h, buf, len);
+    org_md5_final(&h, digest);
+    size = ORG_MD5_SIZE;
+    break;
+  }
+  default: {
+    uint32_t crc = org_crc32_update(0, buf, len);
+    for (int i = 0; i < 4; i++)
+      digest[i] = (uint8_t)(crc >> (24 - 8 * i));
+    size = ORG_CRC32_SIZE;
+    break;
+  }
+  }
+  free(buf);
+
+  if (raw) {
+    OrgValue t = org_table_new_sized(arena, (uint32_t)size);
+    for (size_t i = 0; i < size; i++)
+      org_table_push(arena, t, ORG_TAG_SMALL_INT(digest[i]));
+    return t;
+  }
+  static const char hex[] = "0123456789abcdef";
+  char text[2 * ORG_SHA256_SIZE];
+  for (size_t i = 0; i < size; i++) {
+    text[2 * i] = hex[digest[i] >> 4];
+    text[2 * i + 1] = hex[digest[i] & 15];
+  }
+  return org_make_string(arena, text, 2 * size);
+}
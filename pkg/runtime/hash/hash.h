@@ -0,0 +1,62 @@
+#ifndef ORG_HASH_H
+#define ORG_HASH_H
+
+#include "../core/values.h"
+
+/*
+ * Checksums and digests: SHA-256, MD5 and CRC-32 (IEEE, as in zlib and
+ * gzip), for the build cache, for verifying downloads and behind the
+ * sha256/md5/crc32 builtins. MD5 is only fit for checksums; it is broken
+ * as a cryptographic hash.
+ *
+ * The streaming contexts take input in pieces of any size; the digest is
+ * that of the concatenation.
+ */
+
+#define ORG_SHA256_SIZE 32
+#define ORG_MD5_SIZE 16
+#define ORG_CRC32_SIZE 4
+
+typedef struct OrgSha256 {
+  uint32_t state[8];
+  uint64_t len; /* bytes hashed so far */
+  uint8_t block[64];
+} OrgSha256;
+
+void org_sha256_init(OrgSha256 *h);
+void org_sha256_update(OrgSha256 *h, const void *data, size_t len);
+void org_sha256_final(OrgSha256 *h, uint8_t out[ORG_SHA256_SIZE]);
+
+typedef struct OrgMd5 {
+  uint32_t state[4];
+  uint64_t len;
+  uint8_t block[64];
+} OrgMd5;
+
+void org_md5_init(OrgMd5 *h);
+void org_md5_update(OrgMd5 *h, const void *data, size_t len);
+void org_md5_final(OrgMd5 *h, uint8_t out[ORG_MD5_SIZE]);
+
+/*
+ * Continue the CRC-32 crc (0 to start) over data. The result of one call
+ * is the crc to pass to the next.
+ */
+uint32_t org_crc32_update(uint32_t crc, const void *data, size_t len);
+
+/* ---- OrgValue level ---- */
+
+typedef enum OrgHashAlg {
+  ORG_HASH_SHA256,
+  ORG_HASH_MD5,
+  ORG_HASH_CRC32,
+} OrgHashAlg;
+
+/*
+ * The digest of data, a String (its UTF-8 bytes) or a Table of byte
+ * Integers 0..255 in positions 0, 1, ... (as returned raw). The digest is
+ * a lowercase hex String, or with raw a Table of byte Integers; CRC-32 is
+ * big-endian in both. Any other data is an Error.
+ */
+OrgValue org_hash(Arena *arena, OrgHashAlg alg, OrgValue data, int raw);
+
+#endif /* ORG_HASH_H */
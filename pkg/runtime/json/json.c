@@ -0,0 +1,546 @@
+#include "json.h"
+#include "../core/print.h"
+#include "../table/table.h"
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+/* Exponents beyond this are rejected rather than expanded to digits. */
+#define MAX_EXPONENT 4096
+
+/* ---- Decoding ---- */
+
+typedef struct Decoder {
+  Arena *arena;
+  const char *s;
+  size_t len;
+  size_t pos;
+  int depth;
+} Decoder;
+
+static int decode_value(Decoder *d, OrgValue *out);
+
+static void skip_space(Decoder *d) {
+  while (d->pos < d->len && (d->s[d->pos] == ' ' || d->s[d->pos] == '\t' ||
+                             d->s[d->pos] == '\n' || d->s[d->pos] == '\r'))
+    d->pos++;
+}
+
+static int peek(Decoder *d) {
+  return d->pos < d->len ? (unsigned char)d->s[d->pos] : -1;
+}
+
+static int literal(Decoder *d, const char *word) {
+  size_t n = strlen(word);
+  if (d->len - d->pos < n || memcmp(d->s + d->pos, word, n) != 0)
+    return -1;
+  d->pos += n;
+  return 0;
+}
+
+static int is_digit(int c) { return c >= '0' && c <= '9'; }
+
+static int hex4(Decoder *d, uint32_t *out) {
+  if (d->len - d->pos < 4)
+    return -1;
+  uint32_t v = 0;
+  for (int i = 0; i < 4; i++) {
+    char c = d->s[d->pos++];
+    v <<= 4;
+    if (c >= '0' && c <= '9')
+      v |= (uint32_t)(c - '0');
+    else if (c >= 'a' && c <= 'f')
+      v |= (uint32_t)(c - 'a' + 10);
+    else if (c >= 'A' && c <= 'F')
+      v |= (uint32_t)(c - 'A' + 10);
+    else
+      return -1;
+  }
+  *out = v;
+  return 0;
+}
+
+static size_t put_utf8(char *p, uint32_t cp) {
+  if (cp < 0x80) {
+    p[0] = (char)cp;
+    return 1;
+  }
+  if (cp < 0x800) {
+    p[0] = (char)(0xC0 | (cp >> 6));
+    p[1] = (char)(0x80 | (cp & 0x3F));
+    return 2;
+  }
+  if (cp < 0x10000) {
+    p[0] = (char)(0xE0 | (cp >> 12));
+    p[1] = (char)(0x80 | ((cp >> 6) & 0x3F));
+    p[2] = (char)(0x80 | (cp & 0x3F));
+    return 3;
+  }
+  p[0] = (char)(0xF0 | (cp >> 18));
+  p[1] = (char)(0x80 | ((cp >> 12) & 0x3F));
+  p[2] = (char)(0x80 | ((cp >> 6) & 0x3F));
+  p[3] = (char)(0x80 | (cp & 0x3F));
+  return 4;
+}
+
+/* Decode the code point of a \u escape (after the u), joining surrogates. */
+static int unicode_escape(Decoder *d, uint32_t *out) {
+  uint32_t cp, lo;
+  if (hex4(d, &cp) < 0)
+    return -1;
+  if (cp >= 0xD800 && cp <= 0xDBFF) {
+    if (literal(d, "\\u") < 0 || hex4(d, &lo) < 0 || lo < 0xDC00 ||
+        lo > 0xDFFF)
+      return -1;
+    cp = 0x10000 + ((cp - 0xD800) << 10) + (lo - 0xDC00);
+  } else if (cp >= 0xDC00 && cp <= 0xDFFF) {
+    return -1;
+  }
+  *out = cp;
+  return 0;
+}
+
+/* Decode the string starting at the opening quote into an arena buffer. */
+static int decode_chars(Decoder *d, const char **data, size_t *n) {
+  d->pos++; /* opening quote */
+  /* Escapes only shrink the text, so its length bounds the result. */
+  size_t start = d->pos, end = start;
+  while (end < d->len && d->s[end] != '"')
+    end += d->s[end] == '\\' ? 2 : 1;
+  if (end >= d->len)
+    return -1;
+  char *buf = (char *)arena_alloc(d->arena, end - start + 1, 1);
+  if (!buf)
+    return -1;
+
+  size_t w = 0;
+  while (d->s[d->pos] != '"') {
+    unsigned char c = (unsigned char)d->s[d->pos++];
+    if (c < 0x20)
+      return -1;
+    if (c != '\\') {
+      buf[w++] = (char)c;
+      continue;
+    }
+    static const char escapes[] = "\"\\/bfnrt", values[] = "\"\\/\b\f\n\r\t";
+    char e = d->s[d->pos++];
+    const char *plain = e ? strchr(escapes, e) : NULL;
+    uint32_t cp;
+    if (plain)
+      buf[w++] = values[plain - escapes];
+    else if (e == 'u' && unicode_escape(d, &cp) == 0)
+      w += put_utf8(buf + w, cp);
+    else
+      return -1;
+  }
+  d->pos++; /* closing quote */
+  *data = buf;
+  *n = w;
+  return 0;
+}
+
+static int decode_string(Decoder *d, OrgValue *out) {
+  const char *data;
+  size_t n;
+  if (decode_chars(d, &data, &n) < 0)
+    return -1;
+  *out = org_make_string(d->arena, data, n);
+  return 0;
+}
+
+static int decode_number(Decoder *d, OrgValue *out) {
+  size_t start = d->pos;
+  int neg = peek(d) == '-';
+  if (neg)
+    d->pos++;
+  size_t int_start = d->pos;
+  if (peek(d) == '0')
+    d->pos++;
+  else if (is_digit(peek(d)))
+    while (is_digit(peek(d)))
+      d->pos++;
+  else
+    return -1;
+  size_t int_end = d->pos, frac_start = d->pos, frac_end = d->pos;
+  if (peek(d) == '.') {
+    frac_start = ++d->pos;
+    if (!is_digit(peek(d)))
+      return -1;
+    while (is_digit(peek(d)))
+      d->pos++;
+    frac_end = d->pos;
+  }
+  long exp = 0;
+  int has_exp = 0;
+  if (peek(d) == 'e' || peek(d) == 'E') {
+    has_exp = 1;
+    d->pos++;
+    int eneg = 0;
+    if (peek(d) == '+' || peek(d) == '-')
+      eneg = d->s[d->pos++] == '-';
+    if (!is_digit(peek(d)))
+      return -1;
+    while (is_digit(peek(d))) {
+      exp = exp * 10 + (d->s[d->pos++] - '0');
+      if (exp > MAX_EXPONENT)
+        return -1;
+    }
+    if (eneg)
+      exp = -exp;
+  }
+
+  size_t ilen = int_end - int_start, flen = frac_end - frac_start;
+  if (!has_exp && flen == 0) {
+    /* Integer */
+    char *text = (char *)arena_alloc(d->arena, d->pos - start + 1, 1);
+    if (!text)
+      return -1;
+    memcpy(text, d->s + start, d->pos - start);
+    text[d->pos - start] = '\0';
+    long long n = strtoll(text, NULL, 10);
+    if (ilen < 19 && org_small_fits(n))
+      *out = ORG_TAG_SMALL_INT(n);
+    else
+      *out = org_make_bigint_str(d->arena, text);
+    return 0;
+  }
+
+  /* Decimal: digits × 10^(exp - flen), written as sign, digits, point. */
+  long scale = (long)flen - exp;
+  size_t ndigits = ilen + flen;
+  size_t zeros_after = scale < 0 ? (size_t)-scale : 0;
+  size_t cap = ndigits + zeros_after + (scale > 0 ? (size_t)scale : 0) + 4;
+  char *text = (char *)arena_alloc(d->arena, cap, 1);
+  if (!text)
+    return -1;
+  char *digits = text;
+  memcpy(digits, d->s + int_start, ilen);
+  memcpy(digits + ilen, d->s + frac_start, flen);
+  memset(digits + ndigits, '0', zeros_after);
+  ndigits += zeros_after;
+  digits[ndigits] = '\0';
+
+  char *num = (char *)arena_alloc(d->arena, cap, 1);
+  if (!num)
+    return -1;
+  size_t w = 0;
+  if (neg)
+    num[w++] = '-';
+  if (scale <= 0) {
+    memcpy(num + w, digits, ndigits);
+    w += ndigits;
+  } else if ((size_t)scale >= ndigits) {
+    num[w++] = '0';
+    num[w++] = '.';
+    memset(num + w, '0', (size_t)scale - ndigits);
+    w += (size_t)scale - ndigits;
+    memcpy(num + w, digits, ndigits);
+    w += ndigits;
+  } else {
+    size_t whole = ndigits - (size_t)scale;
+    memcpy(num + w, digits, whole);
+    w += whole;
+    num[w++] = '.';
+    memcpy(num + w, digits + whole, (size_t)scale);
+    w += (size_t)scale;
+  }
+  num[w] = '\0';
+  *out = org_make_decimal_str(d->arena, num);
+  return 0;
+}
+
+static int decode_array(Decoder *d, OrgValue *out) {
+  d->pos++;
+  OrgValue table = org_table_new(d->arena);
+  skip_space(d);
+  if (peek(d) == ']') {
+    d->pos++;
+    *out = table;
+    return 0;
+  }
+  for (;;) {
+    OrgValue item;
+    if (decode_value(d, &item) < 0)
+      return -1;
+    org_table_push(d->arena, table, item);
+    skip_space(d);
+    int c = peek(d);
+    d->pos++;
+    if (c == ']')
+      break;
+    if (c != ',')
+      return -1;
+  }
+  *out = table;
+  return 0;
+}
+
+static int decode_object(Decoder *d, OrgValue *out) {
+  d->pos++;
+  OrgValue table = org_table_new(d->arena);
+  skip_space(d);
+  if (peek(d) == '}') {
+    d->pos++;
+    *out = table;
+    return 0;
+  }
+  for (;;) {
+    OrgValue key, value;
+    skip_space(d);
+    if (peek(d) != '"' || decode_string(d, &key) < 0)
+      return -1;
+    skip_space(d);
+    if (peek(d) != ':')
+      return -1;
+    d->pos++;
+    if (decode_value(d, &value) < 0)
+      return -1;
+    org_table_set(d->arena, table, key, value);
+    skip_space(d);
+    int c = peek(d);
+    d->pos++;
+    if (c == '}')
+      break;
+    if (c != ',')
+      return -1;
+  }
+  *out = table;
+  return 0;
+}
+
+static int decode_value(Decoder *d, OrgValue *out) {
+  skip_space(d);
+  int c = peek(d);
+  switch (c) {
+  case '{':
+  case '[': {
+    if (++d->depth > ORG_JSON_MAX_DEPTH)
+      return -1;
+    int rc = c == '{' ? decode_object(d, out) : decode_array(d, out);
+    d->depth--;
+    return rc;
+  }
+  case '"':
+    return decode_string(d, out);
+  case 't':
+    *out = ORG_TRUE;
+    return literal(d, "true");
+  case 'f':
+    *out = ORG_FALSE;
+    return literal(d, "false");
+  case 'n':
+    *out = ORG_UNUSED;
+    return literal(d, "null");
+  default:
+    return decode_number(d, out);
+  }
+}
+
+int org_json_decode(Arena *arena, const char *s, size_t len, OrgValue *out) {
+  Decoder d = {arena, s, len, 0, 0};
+  OrgValue v;
+  if (decode_value(&d, &v) < 0)
+    return -1;
+  skip_space(&d);
+  if (d.pos != d.len)
+    return -1;
+  *out = v;
+  return 0;
+}
+
+/* ---- Encoding ---- */
+
+typedef struct Buffer {
+  char *data;
+  size_t len;
+  size_t cap;
+  int failed;
+} Buffer;
+
+static void put(Buffer *b, const char *s, size_t n) {
+  if (b->failed)
+    return;
+  if (b->len + n + 1 > b->cap) {
+    size_t cap = b->cap ? b->cap : 64;
+    while (b->len + n + 1 > cap)
+      cap *= 2;
+    char *data = (char *)realloc(b->data, cap);
+    if (!data) {
+      b->failed = 1;
+      return;
+    }
+    b->data = data;
+    b->cap = cap;
+  }
+  memcpy(b->data + b->len, s, n);
+  b->len += n;
+  b->data[b->len] = '\0';
+}
+
+static void put_str(Buffer *b, const char *s) { put(b, s, strlen(s)); }
+
+/* Append a GMP-allocated string and free it. */
+static void put_gmp(Buffer *b, char *s) {
+  void (*gmp_free)(void *, size_t);
+  mp_get_memory_functions(NULL, NULL, &gmp_free);
+  put_str(b, s);
+  gmp_free(s, strlen(s) + 1);
+}
+
+static void encode_string(Buffer *b, const char *s, size_t n) {
+  static const char hex[] = "0123456789abcdef";
+  put(b, "\"", 1);
+  size_t run = 0; /* bytes copied as they are */
+  for (size_t i = 0; i < n; i++) {
+    unsigned char c = (unsigned char)s[i];
+    const char *esc = NULL;
+    char u[7];
+    if (c == '"')
+      esc = "\\\"";
+    else if (c == '\\')
+      esc = "\\\\";
+    else if (c == '\n')
+      esc = "\\n";
+    else if (c == '\r')
+      esc = "\\r";
+    else if (c == '\t')
+      esc = "\\t";
+    else if (c < 0x20) {
+      memcpy(u, "\\u00", 4);
+      u[4] = hex[c >> 4];
+      u[5] = hex[c & 15];
+      u[6] = '\0';
+      esc = u;
+    }
+    if (!esc)
+      continue;
+    put(b, s + run, i - run);
+    put_str(b, esc);
+    run = i + 1;
+  }
+  put(b, s + run, n - run);
+  put(b, "\"", 1);
+}
+
+static void encode_decimal(Buffer *b, OrgValue v) {
+  int32_t scale = org_get_decimal_scale(v);
+  mpz_t n;
+  mpz_init(n);
+  org_decimal_digits(n, *org_get_decimal(v), scale);
+  if (mpz_sgn(n) < 0) {
+    put(b, "-", 1);
+    mpz_abs(n, n);
+  }
+  char *digits = mpz_get_str(NULL, 10, n);
+  mpz_clear(n);
+  size_t len = strlen(digits);
+  size_t sc = scale > 0 ? (size_t)scale : 0;
+  if (sc == 0) {
+    put_str(b, digits);
+  } else if (len <= sc) {
+    put(b, "0.", 2);
+    for (size_t i = len; i < sc; i++)
+      put(b, "0", 1);
+    put_str(b, digits);
+  } else {
+    put(b, digits, len - sc);
+    put(b, ".", 1);
+    put(b, digits + len - sc, sc);
+  }
+  void (*gmp_free)(void *, size_t);
+  mp_get_memory_functions(NULL, NULL, &gmp_free);
+  gmp_free(digits, len + 1);
+}
+
+static void encode(Buffer *b, OrgValue v, int depth);
+
+static void encode_table(Buffer *b, OrgValue v, int depth) {
+  OrgTable *t = (OrgTable *)ORG_GET_PTR(v);
+  if (t->count == t->next_index) {
+    put(b, "[", 1);
+    for (uint32_t i = 0; i < t->next_index; i++) {
+      if (i > 0)
+        put(b, ",", 1);
+      encode(b, org_table_get(v, ORG_TAG_SMALL_INT(i)), depth + 1);
+    }
+    put(b, "]", 1);
+    return;
+  }
+  put(b, "{", 1);
+  int first = 1;
+  for (uint32_t i = 0; i < t->capacity; i++) {
+    OrgTableEntry *e = &t->entries[i];
+    if (ORG_IS_UNUSED(e->key))
+      continue;
+    if (!first)
+      put(b, ",", 1);
+    first = 0;
+    if (ORG_IS_SMALL(e->key)) {
+      char num[32];
+      int n = snprintf(num, sizeof num, "\"%lld\"",
+                       (long long)ORG_UNTAG_SMALL_INT(e->key));
+      put(b, num, (size_t)n);
+    } else {
+      encode_string(b, org_string_data(e->key), org_string_byte_len(e->key));
+    }
+    put(b, ":", 1);
+    encode(b, e->value, depth + 1);
+  }
+  put(b, "}", 1);
+}
+
+static void encode(Buffer *b, OrgValue v, int depth) {
+  if (b->failed)
+    return;
+  if (depth > ORG_JSON_MAX_DEPTH || org_is_error(v)) {
+    b->failed = 1;
+    return;
+  }
+  if (ORG_IS_SMALL(v)) {
+    char num[32];
+    int n = snprintf(num, sizeof num, "%lld", (long long)ORG_UNTAG_SMALL_INT(v));
+    put(b, num, (size_t)n);
+    return;
+  }
+  if (ORG_IS_TRUE(v) || ORG_IS_FALSE(v)) {
+    put_str(b, ORG_IS_TRUE(v) ? "true" : "false");
+    return;
+  }
+  if (!ORG_IS_PTR(v)) {
+    put_str(b, "null");
+    return;
+  }
+  switch (org_get_type(v)) {
+  case ORG_TYPE_BIGINT:
+    put_gmp(b, mpz_get_str(NULL, 10, *org_get_bigint(v)));
+    return;
+  case ORG_TYPE_RATIONAL:
+    put(b, "\"", 1);
+    put_gmp(b, mpq_get_str(NULL, 10, *org_get_rational(v)));
+    put(b, "\"", 1);
+    return;
+  case ORG_TYPE_DECIMAL:
+    encode_decimal(b, v);
+    return;
+  case ORG_TYPE_STRING:
+    encode_string(b, org_string_data(v), org_string_byte_len(v));
+    return;
+  case ORG_TYPE_TABLE:
+    encode_table(b, v, depth);
+    return;
+  default:
+    b->failed = 1;
+  }
+}
+
+char *org_json_encode(OrgValue v, size_t *len) {
+  Buffer b = {NULL, 0, 0, 0};
+  encode(&b, v, 0);
+  if (b.failed) {
+    free(b.data);
+    return NULL;
+  }
+  if (len)
+    *len = b.len;
+  return b.data;
+}
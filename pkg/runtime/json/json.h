@@ -0,0 +1,42 @@
+#ifndef ORG_JSON_H
+#define ORG_JSON_H
+
+#include "../core/values.h"
+
+/*
+ * JSON <-> OrgValue, for the network glue of generated services (see
+ * `org gen service`) and any C code that speaks JSON to OrgLang.
+ *
+ *   JSON                OrgLang
+ *   object              Table with String keys
+ *   array               Table with positional items
+ *   string              String
+ *   integer number      Integer (BigInt when it does not fit)
+ *   other number        Decimal, exact: 1.50 keeps scale 2, 1e-3 is 0.001
+ *   true / false        Boolean
+ *   null                ORG_UNUSED
+ *
+ * Encoding is the reverse; a Table is an array when it only has positional
+ * items (an empty Table is []), else an object whose integer keys become
+ * strings. A Rational has no JSON form and is encoded as the string "n/d".
+ */
+
+/* Maximum nesting of arrays and objects accepted by org_json_decode. */
+#define ORG_JSON_MAX_DEPTH 256
+
+/*
+ * Decode the JSON text s[0..len) into *out, allocating in arena. Returns
+ * 0, or -1 if the text is not a single valid JSON value (or nests deeper
+ * than ORG_JSON_MAX_DEPTH).
+ */
+int org_json_decode(Arena *arena, const char *s, size_t len, OrgValue *out);
+
+/*
+ * Encode v as compact JSON in a NUL-terminated malloc'd buffer; *len (if
+ * not NULL) receives its length. The caller frees it. Returns NULL when v
+ * holds a value JSON cannot represent (an Error, block or resource) or
+ * memory runs out.
+ */
+char *org_json_encode(OrgValue v, size_t *len);
+
+#endif /* ORG_JSON_H */
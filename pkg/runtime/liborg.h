@@ -0,0 +1,22 @@
+#ifndef ORG_LIBORG_H
+#define ORG_LIBORG_H
+
+/*
+ * liborg — the runtime a compiled program links against.
+ *
+ * The C that org build emits includes this header only; the runtime's
+ * sources are compiled alongside it (see pkg/runtime/sources.go).
+ */
+
+#include "core/arena.h"
+#include "core/print.h"
+#include "core/status.h"
+#include "core/values.h"
+#include "gmp/gmp_glue.h"
+#include "ops/ops.h"
+#include "table/table.h"
+#include "closure/closure.h"
+#include "resource/resource.h"
+#include "module/module.h"
+
+#endif /* ORG_LIBORG_H */
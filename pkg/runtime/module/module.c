@@ -0,0 +1,81 @@
+#include "module.h"
+#include "../closure/closure.h"
+#include "../core/print.h"
+#include "../core/status.h"
+#include "../gmp/gmp_glue.h"
+#include "../ops/ops.h"
+#include "../resource/resource.h"
+#include "../table/table.h"
+#include <string.h>
+#include <unistd.h>
+
+static OrgValue not(Arena *arena, OrgValue right) {
+  (void)arena;
+  if (org_is_error(right))
+    return right;
+  return ORG_BOOL(!org_truthy(right));
+}
+
+static OrgValue inc(Arena *arena, OrgValue right) {
+  return org_add(arena, right, ORG_TAG_SMALL_INT(1));
+}
+
+static OrgValue dec(Arena *arena, OrgValue right) {
+  return org_sub(arena, right, ORG_TAG_SMALL_INT(1));
+}
+
+static const struct {
+  const char *name;
+  OrgBinaryFunc binary;
+  OrgUnaryFunc unary;
+} builtins[] = {
+    {"+", org_add, NULL},  {"-", org_sub, org_neg}, {"*", org_mul, NULL},
+    {"/", org_div, NULL},  {"%", org_mod, NULL},    {"**", org_pow, NULL},
+    {"=", org_eq, NULL},   {"<>", org_ne, NULL},    {"~=", org_ne, NULL},
+    {"<", org_lt, NULL},   {">", org_gt, NULL},     {"<=", org_le, NULL},
+    {">=", org_ge, NULL},  {"!", NULL, not},        {"++", NULL, inc},
+    {"--", NULL, dec},
+};
+
+OrgValue org_builtins(Arena *arena) {
+  static Arena *made_in = NULL;
+  static OrgValue scope = ORG_UNUSED;
+  if (made_in == arena && !ORG_IS_UNUSED(scope))
+    return scope;
+  scope = org_scope_new(arena, ORG_UNUSED);
+  made_in = arena;
+  for (size_t i = 0; i < sizeof builtins / sizeof builtins[0]; i++)
+    org_scope_bind(arena, scope, builtins[i].name,
+                   org_builtin(arena, builtins[i].name, builtins[i].binary,
+                               builtins[i].unary));
+  return scope;
+}
+
+OrgValue org_module_scope(Arena *arena) {
+  return org_scope_new(arena, org_builtins(arena));
+}
+
+int org_run(Arena *arena, OrgModuleInit init, const char *module, int argc,
+            char **argv) {
+  if (!arena) {
+    org_write_bytes(STDERR_FILENO, "Error: out of memory\n", 21);
+    return ORG_EXIT_OOM;
+  }
+  org_gmp_init();
+  org_gmp_set_arena(arena);
+  org_set_args(arena, argc, argv);
+
+  OrgValue scope = init(arena);
+  OrgValue key = org_make_string(arena, "main", 4);
+  if (org_table_has(scope, key) != ORG_TRUE) {
+    if (org_write_bytes(STDERR_FILENO, "Error: no main in ", 18) == 0 &&
+        org_write_bytes(STDERR_FILENO, module, strlen(module)) == 0)
+      org_write_bytes(STDERR_FILENO, "\n", 1);
+    return ORG_EXIT_NO_MAIN;
+  }
+  OrgValue result = org_table_get(scope, key);
+  if (org_arity(result) >= 0)
+    result = org_call(arena, result, ORG_UNUSED,
+                      org_resource_inst(arena, scope, "args"));
+  return org_finish(result);
+}
@@ -0,0 +1,37 @@
+#ifndef ORG_MODULE_H
+#define ORG_MODULE_H
+
+#include "../core/values.h"
+
+/*
+ * Modules and programs.
+ *
+ * A module's top level runs in a module scope, whose parent is the scope
+ * of the runtime's operators (+, -, !, ...), so a program can pass them
+ * as values (10 |> +) and rebind them. The scope, once run, is the table
+ * of the module its importers get (see the initialiser PrintC emits).
+ */
+
+/* The scope binding the runtime's operators, made once per arena. */
+OrgValue org_builtins(Arena *arena);
+
+/* A new module scope, over org_builtins. */
+OrgValue org_module_scope(Arena *arena);
+
+/* The initialiser of a module: org_module_init_<module>. */
+typedef OrgValue (*OrgModuleInit)(Arena *arena);
+
+/*
+ * Run a program: initialise GMP over arena, make argv the @args, run the
+ * entry module by calling init, and finish with its main (org_finish).
+ * A main that is an operator is called with @args as its right operand;
+ * any other main is the result itself. Without a main, "Error: no main
+ * in <module>" is reported and the status is ORG_EXIT_NO_MAIN. A NULL
+ * arena, as arena_new returns when out of memory, exits ORG_EXIT_OOM.
+ *
+ * Returns the exit status; the generated main() returns it.
+ */
+int org_run(Arena *arena, OrgModuleInit init, const char *module, int argc,
+            char **argv);
+
+#endif /* ORG_MODULE_H */
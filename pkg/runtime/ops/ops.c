@@ -1,4 +1,6 @@
 #include "ops.h"
+#include "../table/table.h"
+#include <stdio.h>
 #include <string.h>
 
 /*
@@ -144,6 +146,8 @@ static int32_t get_scale(OrgValue v) {
 /* ========== Arithmetic Operations ========== */
 
 OrgValue org_add(Arena *arena, OrgValue a, OrgValue b) {
+  a = org_amount(a);
+  b = org_amount(b);
   /* Fast path: both small integers */
   if (ORG_IS_SMALL(a) && ORG_IS_SMALL(b)) {
     int64_t sa = ORG_UNTAG_SMALL_INT(a);
@@ -220,6 +224,8 @@ OrgValue org_add(Arena *arena, OrgValue a, OrgValue b) {
 }
 
 OrgValue org_sub(Arena *arena, OrgValue a, OrgValue b) {
+  a = org_amount(a);
+  b = org_amount(b);
   if (ORG_IS_SMALL(a) && ORG_IS_SMALL(b)) {
     int64_t sa = ORG_UNTAG_SMALL_INT(a);
     int64_t sb = ORG_UNTAG_SMALL_INT(b);
@@ -290,6 +296,8 @@ OrgValue org_sub(Arena *arena, OrgValue a, OrgValue b) {
 }
 
 OrgValue org_mul(Arena *arena, OrgValue a, OrgValue b) {
+  a = org_amount(a);
+  b = org_amount(b);
   if (ORG_IS_SMALL(a) && ORG_IS_SMALL(b)) {
     int64_t sa = ORG_UNTAG_SMALL_INT(a);
     int64_t sb = ORG_UNTAG_SMALL_INT(b);
@@ -363,6 +371,8 @@ OrgValue org_mul(Arena *arena, OrgValue a, OrgValue b) {
  * - Otherwise → Rational
  */
 OrgValue org_div(Arena *arena, OrgValue a, OrgValue b) {
+  a = org_amount(a);
+  b = org_amount(b);
   if (ORG_IS_ERROR(a) || ORG_IS_ERROR(b))
     return ORG_ERROR;
 
@@ -454,6 +464,8 @@ OrgValue org_div(Arena *arena, OrgValue a, OrgValue b) {
 }
 
 OrgValue org_mod(Arena *arena, OrgValue a, OrgValue b) {
+  a = org_amount(a);
+  b = org_amount(b);
   if (ORG_IS_ERROR(a) || ORG_IS_ERROR(b))
     return ORG_ERROR;
 
@@ -488,6 +500,7 @@ OrgValue org_mod(Arena *arena, OrgValue a, OrgValue b) {
 }
 
 OrgValue org_neg(Arena *arena, OrgValue a) {
+  a = org_amount(a);
   if (ORG_IS_ERROR(a))
     return ORG_ERROR;
 
@@ -538,6 +551,8 @@ OrgValue org_neg(Arena *arena, OrgValue a) {
 }
 
 OrgValue org_pow(Arena *arena, OrgValue base, OrgValue exp) {
+  base = org_amount(base);
+  exp = org_amount(exp);
   if (ORG_IS_ERROR(base) || ORG_IS_ERROR(exp))
     return ORG_ERROR;
 
@@ -611,6 +626,8 @@ static int org_cmp_internal(OrgValue a, OrgValue b) {
 }
 
 OrgValue org_eq(Arena *arena, OrgValue a, OrgValue b) {
+  a = org_amount(a);
+  b = org_amount(b);
   (void)arena;
   if (ORG_IS_ERROR(a) || ORG_IS_ERROR(b))
     return ORG_ERROR;
@@ -621,6 +638,8 @@ OrgValue org_eq(Arena *arena, OrgValue a, OrgValue b) {
 }
 
 OrgValue org_lt(Arena *arena, OrgValue a, OrgValue b) {
+  a = org_amount(a);
+  b = org_amount(b);
   (void)arena;
   if (ORG_IS_ERROR(a) || ORG_IS_ERROR(b))
     return ORG_ERROR;
@@ -630,6 +649,8 @@ OrgValue org_lt(Arena *arena, OrgValue a, OrgValue b) {
 }
 
 OrgValue org_le(Arena *arena, OrgValue a, OrgValue b) {
+  a = org_amount(a);
+  b = org_amount(b);
   (void)arena;
   if (ORG_IS_ERROR(a) || ORG_IS_ERROR(b))
     return ORG_ERROR;
@@ -639,6 +660,8 @@ OrgValue org_le(Arena *arena, OrgValue a, OrgValue b) {
 }
 
 OrgValue org_gt(Arena *arena, OrgValue a, OrgValue b) {
+  a = org_amount(a);
+  b = org_amount(b);
   (void)arena;
   if (ORG_IS_ERROR(a) || ORG_IS_ERROR(b))
     return ORG_ERROR;
@@ -648,6 +671,8 @@ OrgValue org_gt(Arena *arena, OrgValue a, OrgValue b) {
 }
 
 OrgValue org_ge(Arena *arena, OrgValue a, OrgValue b) {
+  a = org_amount(a);
+  b = org_amount(b);
   (void)arena;
   if (ORG_IS_ERROR(a) || ORG_IS_ERROR(b))
     return ORG_ERROR;
@@ -657,6 +682,8 @@ OrgValue org_ge(Arena *arena, OrgValue a, OrgValue b) {
 }
 
 OrgValue org_ne(Arena *arena, OrgValue a, OrgValue b) {
+  a = org_amount(a);
+  b = org_amount(b);
   (void)arena;
   if (ORG_IS_ERROR(a) || ORG_IS_ERROR(b))
     return ORG_ERROR;
@@ -665,3 +692,51 @@ OrgValue org_ne(Arena *arena, OrgValue a, OrgValue b) {
   }
   return ORG_BOOL(org_cmp_internal(a, b) != 0);
 }
+
+/* ---- Truth ---- */
+
+int org_truthy(OrgValue v) {
+  if (ORG_IS_SMALL(v))
+    return ORG_UNTAG_SMALL_INT(v) != 0;
+  if (ORG_IS_FALSE(v) || org_is_error(v))
+    return 0;
+  if (!ORG_IS_PTR(v))
+    return 1;
+  switch (org_get_type(v)) {
+  case ORG_TYPE_BIGINT:
+    return mpz_sgn(*org_get_bigint(v)) != 0;
+  case ORG_TYPE_RATIONAL:
+    return mpq_sgn(*org_get_rational(v)) != 0;
+  case ORG_TYPE_DECIMAL:
+    return mpq_sgn(*org_get_decimal(v)) != 0;
+  case ORG_TYPE_STRING:
+    return org_string_byte_len(v) > 0;
+  case ORG_TYPE_TABLE:
+    return org_table_count(v) > 0;
+  case ORG_TYPE_QUANTITY:
+    return org_truthy(org_amount(v));
+  default:
+    return 1;
+  }
+}
+
+OrgValue org_truth(OrgValue v) {
+  if (org_is_error(v))
+    return v;
+  return ORG_BOOL(org_truthy(v));
+}
+
+OrgValue org_assert(Arena *arena, OrgValue cond, OrgValue message,
+                    const char *text, const char *path, int line) {
+  if (org_is_error(cond) || org_truthy(cond))
+    return cond;
+  int len = (int)strlen(text);
+  if (ORG_IS_PTR(message) && org_get_type(message) == ORG_TYPE_STRING) {
+    text = org_string_data(message);
+    len = (int)org_string_byte_len(message);
+  }
+  char msg[512];
+  snprintf(msg, sizeof msg, "assertion failed at %s:%d: %.*s", path, line,
+           len, text);
+  return org_make_error(arena, msg);
+}
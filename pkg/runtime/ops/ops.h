@@ -13,7 +13,8 @@
  *   Decimal     | Decimal  | Decimal  | Decimal
  *
  * All operations use the fast path for SmallInt+SmallInt when possible,
- * with automatic overflow promotion to BigInt.
+ * with automatic overflow promotion to BigInt. A quantity operand is read
+ * as its amount (org_amount), so 1s + 500ms is 3/2.
  */
 
 /* Arithmetic */
@@ -33,6 +34,25 @@ OrgValue org_gt(Arena *arena, OrgValue a, OrgValue b);
 OrgValue org_ge(Arena *arena, OrgValue a, OrgValue b);
 OrgValue org_ne(Arena *arena, OrgValue a, OrgValue b);
 
+/* Truth */
+
+/*
+ * The truth of v as a condition: false for false, zero, "", an empty
+ * table and an Error; true otherwise.
+ */
+int org_truthy(OrgValue v);
+
+/* The truth of v as a Boolean, for && and ||; an Error is returned as is. */
+OrgValue org_truth(OrgValue v);
+
+/*
+ * assert cond "msg": cond if it is true or an Error, otherwise an Error
+ * "assertion failed at path:line: msg", where msg is the String message,
+ * or text, the source of cond, when message is not a String.
+ */
+OrgValue org_assert(Arena *arena, OrgValue cond, OrgValue message,
+                    const char *text, const char *path, int line);
+
 /*
  * Normalize an integer result: if a BigInt fits in a SmallInt,
  * convert it back. Used after arithmetic to keep values compact.
@@ -0,0 +1,314 @@
+#ifdef ORG_WITH_PYTHON
+
+#include "pyconv.h"
+#include "../core/print.h"
+#include "../table/table.h"
+#include <stdio.h>
+#include <string.h>
+
+PyObject *OrgError = NULL;
+
+static PyObject *FractionType = NULL;
+static PyObject *DecimalType = NULL;
+
+/* Import module.name as a new reference, or NULL. */
+static PyObject *import_attr(const char *module, const char *name) {
+  PyObject *m = PyImport_ImportModule(module);
+  if (!m)
+    return NULL;
+  PyObject *attr = PyObject_GetAttrString(m, name);
+  Py_DECREF(m);
+  return attr;
+}
+
+int org_py_init(PyObject *module) {
+  if (!FractionType && !(FractionType = import_attr("fractions", "Fraction")))
+    return -1;
+  if (!DecimalType && !(DecimalType = import_attr("decimal", "Decimal")))
+    return -1;
+  if (!OrgError &&
+      !(OrgError = PyErr_NewException("org.Error", PyExc_Exception, NULL)))
+    return -1;
+  if (!module)
+    return 0;
+  Py_INCREF(OrgError);
+  if (PyModule_AddObject(module, "Error", OrgError) < 0) {
+    Py_DECREF(OrgError);
+    return -1;
+  }
+  return 0;
+}
+
+/* Free a string allocated by mpz_get_str. */
+static void free_gmp_str(char *str) {
+  void (*gmp_free)(void *, size_t);
+  mp_get_memory_functions(NULL, NULL, &gmp_free);
+  gmp_free(str, strlen(str) + 1);
+}
+
+static PyObject *mpz_to_py(const mpz_t n) {
+  char *digits = mpz_get_str(NULL, 10, n);
+  PyObject *obj = PyLong_FromString(digits, NULL, 10);
+  free_gmp_str(digits);
+  return obj;
+}
+
+static PyObject *decimal_to_py(OrgValue v) {
+  mpz_t n;
+  mpz_init(n);
+  int32_t scale = org_get_decimal_scale(v);
+  org_decimal_digits(n, *org_get_decimal(v), scale);
+  char *digits = mpz_get_str(NULL, 10, n);
+  mpz_clear(n);
+
+  /* Decimal("314E-2") keeps the scale: Decimal('3.14'). */
+  PyObject *text = PyUnicode_FromFormat("%sE-%d", digits, (int)scale);
+  free_gmp_str(digits);
+  if (!text)
+    return NULL;
+  PyObject *obj = PyObject_CallOneArg(DecimalType, text);
+  Py_DECREF(text);
+  return obj;
+}
+
+static PyObject *table_to_py(OrgValue v) {
+  OrgTable *t = (OrgTable *)ORG_GET_PTR(v);
+
+  if (t->count == t->next_index) {
+    PyObject *list = PyList_New(t->count);
+    if (!list)
+      return NULL;
+    for (uint32_t i = 0; i < t->count; i++) {
+      PyObject *item = org_to_py(org_table_get(v, ORG_TAG_SMALL_INT(i)));
+      if (!item) {
+        Py_DECREF(list);
+        return NULL;
+      }
+      PyList_SET_ITEM(list, i, item);
+    }
+    return list;
+  }
+
+  PyObject *dict = PyDict_New();
+  if (!dict)
+    return NULL;
+  for (uint32_t i = 0; i < t->capacity; i++) {
+    OrgTableEntry *e = &t->entries[i];
+    if (ORG_IS_UNUSED(e->key))
+      continue;
+    PyObject *key = org_to_py(e->key);
+    PyObject *value = key ? org_to_py(e->value) : NULL;
+    int rc = value ? PyDict_SetItem(dict, key, value) : -1;
+    Py_XDECREF(key);
+    Py_XDECREF(value);
+    if (rc < 0) {
+      Py_DECREF(dict);
+      return NULL;
+    }
+  }
+  return dict;
+}
+
+PyObject *org_to_py(OrgValue v) {
+  if (!OrgError && org_py_init(NULL) < 0)
+    return NULL;
+
+  if (ORG_IS_SMALL(v))
+    return PyLong_FromLongLong(ORG_UNTAG_SMALL_INT(v));
+  if (ORG_IS_UNUSED(v))
+    Py_RETURN_NONE;
+  if (ORG_IS_TRUE(v))
+    Py_RETURN_TRUE;
+  if (ORG_IS_FALSE(v))
+    Py_RETURN_FALSE;
+  if (org_is_error(v)) {
+    const char *msg = org_error_message(v);
+    PyErr_SetString(OrgError, *msg ? msg : "Error");
+    return NULL;
+  }
+  if (!ORG_IS_PTR(v)) {
+    PyErr_SetString(PyExc_TypeError, "invalid OrgLang value");
+    return NULL;
+  }
+
+  switch (org_get_type(v)) {
+  case ORG_TYPE_BIGINT:
+    return mpz_to_py(*org_get_bigint(v));
+  case ORG_TYPE_RATIONAL: {
+    mpq_t *q = org_get_rational(v);
+    PyObject *num = mpz_to_py(mpq_numref(*q));
+    PyObject *den = num ? mpz_to_py(mpq_denref(*q)) : NULL;
+    PyObject *obj =
+        den ? PyObject_CallFunctionObjArgs(FractionType, num, den, NULL) : NULL;
+    Py_XDECREF(num);
+    Py_XDECREF(den);
+    return obj;
+  }
+  case ORG_TYPE_DECIMAL:
+    return decimal_to_py(v);
+  case ORG_TYPE_STRING:
+    return PyUnicode_DecodeUTF8(org_string_data(v),
+                                (Py_ssize_t)org_string_byte_len(v), "strict");
+  case ORG_TYPE_TABLE:
+    return table_to_py(v);
+  default:
+    PyErr_Format(PyExc_TypeError, "cannot convert OrgLang %s to Python",
+                 org_type_name(v));
+    return NULL;
+  }
+}
+
+/* Convert obj through its str() with make (a bigint or decimal maker). */
+static int from_str(Arena *arena, PyObject *obj,
+                    OrgValue (*make)(Arena *, const char *), OrgValue *out) {
+  PyObject *text = PyObject_Str(obj);
+  if (!text)
+    return -1;
+  const char *s = PyUnicode_AsUTF8(text);
+  if (s)
+    *out = make(arena, s);
+  Py_DECREF(text);
+  return s ? 0 : -1;
+}
+
+static int decimal_from_py(Arena *arena, PyObject *obj, OrgValue *out) {
+  PyObject *finite = PyObject_CallMethod(obj, "is_finite", NULL);
+  if (!finite)
+    return -1;
+  int ok = PyObject_IsTrue(finite);
+  Py_DECREF(finite);
+  if (ok <= 0) {
+    if (ok == 0)
+      PyErr_SetString(PyExc_ValueError,
+                      "OrgLang Decimals are finite; got NaN or infinity");
+    return -1;
+  }
+  /* Fixed-point notation: Decimal('1E+3') is written 1000. */
+  PyObject *text = PyObject_CallMethod(obj, "__format__", "s", "f");
+  if (!text)
+    return -1;
+  const char *s = PyUnicode_AsUTF8(text);
+  if (s)
+    *out = org_make_decimal_str(arena, s);
+  Py_DECREF(text);
+  return s ? 0 : -1;
+}
+
+static int rational_from_py(Arena *arena, PyObject *obj, OrgValue *out) {
+  PyObject *num = PyObject_GetAttrString(obj, "numerator");
+  PyObject *den = num ? PyObject_GetAttrString(obj, "denominator") : NULL;
+  PyObject *ns = den ? PyObject_Str(num) : NULL;
+  PyObject *ds = ns ? PyObject_Str(den) : NULL;
+  const char *n = ds ? PyUnicode_AsUTF8(ns) : NULL;
+  const char *d = n ? PyUnicode_AsUTF8(ds) : NULL;
+  if (d)
+    *out = org_make_rational_str(arena, n, d);
+  Py_XDECREF(num);
+  Py_XDECREF(den);
+  Py_XDECREF(ns);
+  Py_XDECREF(ds);
+  return d ? 0 : -1;
+}
+
+static int dict_from_py(Arena *arena, PyObject *obj, OrgValue *out) {
+  OrgValue table = org_table_new_sized(arena, (uint32_t)PyDict_Size(obj));
+  PyObject *key, *value;
+  Py_ssize_t pos = 0;
+  while (PyDict_Next(obj, &pos, &key, &value)) {
+    OrgValue k, v;
+    if (!PyUnicode_Check(key) && !PyLong_Check(key)) {
+      PyErr_Format(PyExc_TypeError,
+                   "OrgLang table keys are str or int, not %.100s",
+                   Py_TYPE(key)->tp_name);
+      return -1;
+    }
+    if (org_from_py(arena, key, &k) < 0 || org_from_py(arena, value, &v) < 0)
+      return -1;
+    if (!ORG_IS_SMALL(k) &&
+        !(ORG_IS_PTR(k) && org_get_type(k) == ORG_TYPE_STRING)) {
+      PyErr_SetString(PyExc_OverflowError, "OrgLang table key out of range");
+      return -1;
+    }
+    org_table_set(arena, table, k, v);
+  }
+  *out = table;
+  return 0;
+}
+
+int org_from_py(Arena *arena, PyObject *obj, OrgValue *out) {
+  if (!OrgError && org_py_init(NULL) < 0)
+    return -1;
+
+  if (obj == Py_None) {
+    *out = ORG_UNUSED;
+    return 0;
+  }
+  if (PyBool_Check(obj)) {
+    *out = ORG_BOOL(obj == Py_True);
+    return 0;
+  }
+  if (PyLong_Check(obj)) {
+    int overflow;
+    long long n = PyLong_AsLongLongAndOverflow(obj, &overflow);
+    if (n == -1 && PyErr_Occurred())
+      return -1;
+    if (!overflow && org_small_fits(n)) {
+      *out = ORG_TAG_SMALL_INT(n);
+      return 0;
+    }
+    return from_str(arena, obj, org_make_bigint_str, out);
+  }
+  if (PyUnicode_Check(obj)) {
+    Py_ssize_t len;
+    const char *s = PyUnicode_AsUTF8AndSize(obj, &len);
+    if (!s)
+      return -1;
+    *out = org_make_string(arena, s, (size_t)len);
+    return 0;
+  }
+  if (PyFloat_Check(obj)) {
+    /* Through the shortest repr: 0.1 is the Decimal 0.1. */
+    PyObject *repr = PyObject_Repr(obj);
+    PyObject *d = repr ? PyObject_CallOneArg(DecimalType, repr) : NULL;
+    int rc = d ? decimal_from_py(arena, d, out) : -1;
+    Py_XDECREF(repr);
+    Py_XDECREF(d);
+    return rc;
+  }
+  if (PyList_Check(obj) || PyTuple_Check(obj)) {
+    PyObject *seq = PySequence_Fast(obj, "expected a sequence");
+    if (!seq)
+      return -1;
+    Py_ssize_t n = PySequence_Fast_GET_SIZE(seq);
+    OrgValue table = org_table_new_sized(arena, (uint32_t)n);
+    for (Py_ssize_t i = 0; i < n; i++) {
+      OrgValue item;
+      if (org_from_py(arena, PySequence_Fast_GET_ITEM(seq, i), &item) < 0) {
+        Py_DECREF(seq);
+        return -1;
+      }
+      org_table_push(arena, table, item);
+    }
+    Py_DECREF(seq);
+    *out = table;
+    return 0;
+  }
+  if (PyDict_Check(obj))
+    return dict_from_py(arena, obj, out);
+
+  int is = PyObject_IsInstance(obj, DecimalType);
+  if (is < 0)
+    return -1;
+  if (is)
+    return decimal_from_py(arena, obj, out);
+  if ((is = PyObject_IsInstance(obj, FractionType)) < 0)
+    return -1;
+  if (is)
+    return rational_from_py(arena, obj, out);
+
+  PyErr_Format(PyExc_TypeError, "cannot convert %.100s to an OrgLang value",
+               Py_TYPE(obj)->tp_name);
+  return -1;
+}
+
+#endif /* ORG_WITH_PYTHON */
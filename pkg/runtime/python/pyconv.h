@@ -0,0 +1,56 @@
+#ifndef ORG_PYCONV_H
+#define ORG_PYCONV_H
+
+/*
+ * Value conversion between OrgLang and CPython, used by the extension
+ * modules of `org build --library --python`.
+ *
+ * Only compiled with -DORG_WITH_PYTHON (and the CPython include path), so
+ * the rest of the runtime builds without Python headers.
+ *
+ *   OrgLang           Python
+ *   Integer           int
+ *   Rational          fractions.Fraction
+ *   Decimal           decimal.Decimal (rounded to its scale)
+ *   Boolean           bool
+ *   String            str
+ *   Table             list when it only has positional items, else dict
+ *   Error             raises org.Error (OrgError below)
+ *   (absent operand)  None
+ *
+ * Python floats become Decimals through their shortest repr, so 0.1 is
+ * the Decimal 0.1 rather than the binary fraction.
+ */
+
+#ifdef ORG_WITH_PYTHON
+
+#define PY_SSIZE_T_CLEAN
+#include <Python.h>
+
+#include "../core/values.h"
+
+/* The exception raised for OrgLang Errors; set by org_py_init. */
+extern PyObject *OrgError;
+
+/*
+ * Add the Error exception to an extension module and import the Python
+ * modules the conversions use. Returns 0, or -1 with a Python exception.
+ */
+int org_py_init(PyObject *module);
+
+/*
+ * Convert an OrgLang value to a new reference. Returns NULL with a Python
+ * exception for Errors and for values Python cannot hold (blocks and
+ * resources).
+ */
+PyObject *org_to_py(OrgValue v);
+
+/*
+ * Convert a Python object to an OrgLang value allocated in arena. Returns
+ * 0, or -1 with a TypeError for objects that have no OrgLang counterpart.
+ */
+int org_from_py(Arena *arena, PyObject *obj, OrgValue *out);
+
+#endif /* ORG_WITH_PYTHON */
+
+#endif /* ORG_PYCONV_H */
@@ -0,0 +1,120 @@
+#include "resource.h"
+#include "../closure/closure.h"
+#include "../core/print.h"
+#include "../table/table.h"
+#include <stdio.h>
+#include <string.h>
+#include <unistd.h>
+
+/* The table of @args, once org_set_args has made it. */
+static OrgValue args = ORG_UNUSED;
+
+static OrgValue new_resource(Arena *arena, const char *name, int fd) {
+  OrgResource *r = (OrgResource *)arena_alloc(arena, sizeof(OrgResource), 8);
+  if (!r)
+    return ORG_ERROR;
+  r->header.type = ORG_TYPE_RESOURCE;
+  r->header.flags = 0;
+  r->header._pad = 0;
+  r->header.size = (uint32_t)sizeof(OrgResource);
+  r->fd = fd;
+  r->_pad2 = 0;
+  r->name = name;
+  return ORG_TAG_PTR_VAL(r);
+}
+
+void org_set_args(Arena *arena, int argc, char **argv) {
+  args = org_table_new(arena);
+  for (int i = 1; i < argc; i++)
+    org_table_push(arena, args, org_make_string(arena, argv[i], strlen(argv[i])));
+}
+
+OrgValue org_resource_inst(Arena *arena, OrgValue env, const char *name) {
+  OrgValue v = org_scope_get(arena, env, name);
+  if (!org_is_error(v))
+    return v;
+  if (strcmp(name, "stdout") == 0)
+    return new_resource(arena, "stdout", STDOUT_FILENO);
+  if (strcmp(name, "stderr") == 0)
+    return new_resource(arena, "stderr", STDERR_FILENO);
+  if (strcmp(name, "args") == 0)
+    return ORG_IS_UNUSED(args) ? org_table_new(arena) : args;
+  char msg[256];
+  snprintf(msg, sizeof msg, "unknown resource @%s", name);
+  return org_make_error(arena, msg);
+}
+
+/* Write v and a newline to r. */
+static OrgValue write_line(Arena *arena, OrgResource *r, OrgValue v) {
+  if (org_write_value(r->fd, v) < 0 || org_write_bytes(r->fd, "\n", 1) < 0) {
+    char msg[256];
+    snprintf(msg, sizeof msg, "write to @%s failed", r->name);
+    return org_make_error(arena, msg);
+  }
+  return ORG_TRUE;
+}
+
+static OrgValue write_to(Arena *arena, OrgValue sink, OrgValue source) {
+  OrgResource *r = (OrgResource *)ORG_GET_PTR(sink);
+  if (r->fd < 0) {
+    char msg[256];
+    snprintf(msg, sizeof msg, "cannot write to @%s", r->name);
+    return org_make_error(arena, msg);
+  }
+  if (!ORG_IS_PTR(source) || org_get_type(source) != ORG_TYPE_TABLE) {
+    OrgValue rc = write_line(arena, r, source);
+    return org_is_error(rc) ? rc : sink;
+  }
+  OrgTable *t = (OrgTable *)ORG_GET_PTR(source);
+  for (uint32_t i = 0; i < t->next_index; i++) {
+    OrgValue rc =
+        write_line(arena, r, org_table_get(source, ORG_TAG_SMALL_INT(i)));
+    if (org_is_error(rc))
+      return rc;
+  }
+  return sink;
+}
+
+/* Whether key is one of the positional elements of t. */
+static int positional(OrgTable *t, OrgValue key) {
+  return ORG_IS_SMALL(key) && ORG_UNTAG_SMALL_INT(key) >= 0 &&
+         ORG_UNTAG_SMALL_INT(key) < (int64_t)t->next_index;
+}
+
+/* sink over each element of the table source, into a new table. */
+static OrgValue map(Arena *arena, OrgValue source, OrgValue sink) {
+  OrgTable *t = (OrgTable *)ORG_GET_PTR(source);
+  OrgValue out = org_table_new_sized(arena, t->count);
+  if (org_is_error(out))
+    return out;
+  for (uint32_t i = 0; i < t->next_index; i++) {
+    OrgValue v = org_table_get(source, ORG_TAG_SMALL_INT(i));
+    org_table_push(arena, out, org_call(arena, sink, ORG_UNUSED, v));
+  }
+  for (uint32_t i = 0; i < t->capacity; i++) {
+    OrgTableEntry *e = &t->entries[i];
+    if (ORG_IS_UNUSED(e->key) || positional(t, e->key))
+      continue;
+    org_table_set(arena, out, e->key,
+                  org_call(arena, sink, ORG_UNUSED, e->value));
+  }
+  return out;
+}
+
+OrgValue org_op_arrow(Arena *arena, OrgValue source, OrgValue sink) {
+  if (org_is_error(sink))
+    return sink;
+  if (org_is_resource(sink))
+    return write_to(arena, sink, source);
+  if (!org_is_closure(sink)) {
+    OrgValue text = org_display(arena, sink);
+    char msg[256];
+    snprintf(msg, sizeof msg, "%.*s is not a sink",
+             org_is_error(text) ? 5 : (int)org_string_byte_len(text),
+             org_is_error(text) ? "value" : org_string_data(text));
+    return org_make_error(arena, msg);
+  }
+  if (ORG_IS_PTR(source) && org_get_type(source) == ORG_TYPE_TABLE)
+    return map(arena, source, sink);
+  return org_call(arena, sink, ORG_UNUSED, source);
+}
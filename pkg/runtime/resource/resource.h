@@ -0,0 +1,46 @@
+#ifndef ORG_RESOURCE_H
+#define ORG_RESOURCE_H
+
+#include "../core/values.h"
+
+/*
+ * Resources — the effects of a program.
+ *
+ * The primitive resources are written to with ->: @stdout and @stderr
+ * write each value on a line of its own. @args is the table of the
+ * command-line arguments (org_set_args).
+ *
+ * TODO(scheduler): @stdin, @tty and resources defined with @: need the
+ * scheduler; @stdin is an Error for now.
+ */
+
+typedef struct OrgResource {
+  OrgObject header;
+  int32_t fd;       /* written to by ->, or -1 */
+  int32_t _pad2;
+  const char *name; /* without the @ */
+} OrgResource;
+
+static inline int org_is_resource(OrgValue v) {
+  return ORG_IS_PTR(v) && org_get_type(v) == ORG_TYPE_RESOURCE;
+}
+
+/*
+ * @name: name as bound in env or a scope enclosing it, else the primitive
+ * resource of that name, else an Error "unknown resource @name".
+ */
+OrgValue org_resource_inst(Arena *arena, OrgValue env, const char *name);
+
+/* Make the arguments after argv[0] the strings of @args. */
+void org_set_args(Arena *arena, int argc, char **argv);
+
+/*
+ * source -> sink. A resource sink writes the source, or the positional
+ * elements of a table source, one per line, and is the result. An
+ * operator sink is called with the source as its right operand or, for a
+ * table source, mapped over its elements into a new table. An Error sink
+ * is the result; any other sink is an Error.
+ */
+OrgValue org_op_arrow(Arena *arena, OrgValue source, OrgValue sink);
+
+#endif /* ORG_RESOURCE_H */
@@ -0,0 +1,79 @@
+// Package runtime embeds the C runtime compiled programs link against
+// (liborg.h), so that org build can compile it alongside the generated C
+// wherever the org binary runs.
+package runtime
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// files are the headers and sources of the runtime.
+//
+//go:embed liborg.h */*.h */*.c
+var files embed.FS
+
+// linked are the directories of the runtime a program links, those
+// liborg.h includes. python/ is compiled with CPython extension modules
+// only.
+var linked = []string{"core", "gmp", "ops", "table", "closure", "resource", "module"}
+
+// Libs are the libraries the runtime links against, as -l names.
+var Libs = []string{"gmp"}
+
+// Notice is the notice shipped with the binaries org build makes: the
+// license of the runtime they include, and of GMP they link against.
+//
+//go:embed NOTICE
+var Notice string
+
+// Write writes the runtime under dir, the directory the generated C is
+// compiled with as -I dir, and returns the paths of the C sources of the
+// runtime a program links.
+func Write(dir string) ([]string, error) {
+	written, err := write(dir, func(string) bool { return true })
+	var sources []string
+	for _, file := range written {
+		rel, _ := filepath.Rel(dir, file)
+		if strings.HasSuffix(file, ".c") && slices.Contains(linked, filepath.Dir(rel)) {
+			sources = append(sources, file)
+		}
+	}
+	return sources, err
+}
+
+// WriteHeaders writes the headers of the runtime under dir, which the
+// header of a library build includes, and returns their paths.
+func WriteHeaders(dir string) ([]string, error) {
+	return write(dir, func(name string) bool { return path.Ext(name) == ".h" })
+}
+
+// write writes the files of the runtime whose names match under dir and
+// returns their paths.
+func write(dir string, match func(name string) bool) ([]string, error) {
+	var written []string
+	err := fs.WalkDir(files, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !match(name) {
+			return err
+		}
+		data, err := files.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, data, 0o644); err != nil {
+			return err
+		}
+		written = append(written, file)
+		return nil
+	})
+	return written, err
+}
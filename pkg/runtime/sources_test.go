@@ -0,0 +1,60 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+// TestWrite checks that Write writes liborg.h and a source for each
+// directory it includes.
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	sources, err := Write(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := os.ReadFile(filepath.Join(dir, "liborg.h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dirs []string
+	for _, src := range sources {
+		if _, err := os.Stat(src); err != nil {
+			t.Error(err)
+		}
+		dirs = append(dirs, filepath.Base(filepath.Dir(src)))
+	}
+	for _, m := range regexp.MustCompile(`#include "(\w+)/`).FindAllStringSubmatch(string(h), -1) {
+		if !slices.Contains(dirs, m[1]) {
+			t.Errorf("liborg.h includes %s/, which has no source among %v", m[1], sources)
+		}
+	}
+	if slices.Contains(dirs, "python") {
+		t.Errorf("sources include python/, which needs Python.h: %v", sources)
+	}
+}
+
+// TestWriteHeaders checks that WriteHeaders writes the headers only.
+func TestWriteHeaders(t *testing.T) {
+	dir := t.TempDir()
+	headers, err := WriteHeaders(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"liborg.h", filepath.Join("core", "values.h"), filepath.Join("python", "pyconv.h")} {
+		if !slices.Contains(headers, filepath.Join(dir, want)) {
+			t.Errorf("%s not written: %v", want, headers)
+		}
+	}
+	for _, h := range headers {
+		if filepath.Ext(h) != ".h" {
+			t.Errorf("%s is not a header", h)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "core", "values.c")); err == nil {
+		t.Error("core/values.c written")
+	}
+}
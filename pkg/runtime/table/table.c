@@ -1,4 +1,6 @@
 #include "table.h"
+#include "../core/print.h"
+#include <stdio.h>
 #include <string.h>
 
 /* ---- Hashing ---- */
@@ -14,8 +16,8 @@ static uint32_t fnv1a(const char *data, size_t len) {
 }
 
 uint32_t org_hash_value(OrgValue key) {
-  if (ORG_IS_SMALL(key)) {
-    /* Integer key: mix the bits */
+  if (ORG_IS_SMALL(key) || ORG_IS_BOOL(key)) {
+    /* Integer or Boolean key: mix the bits */
     uint64_t k = (uint64_t)key;
     k = (k ^ (k >> 16)) * 0x45d9f3b;
     k = (k ^ (k >> 16)) * 0x45d9f3b;
@@ -111,9 +113,9 @@ static int table_grow(Arena *arena, OrgTable *t) {
   return 1;
 }
 
-/* Check if a key is valid (String or SmallInt) */
+/* Check if a key is valid (String, SmallInt or Boolean) */
 static int is_valid_key(OrgValue key) {
-  if (ORG_IS_SMALL(key))
+  if (ORG_IS_SMALL(key) || ORG_IS_BOOL(key))
     return 1;
   if (ORG_IS_PTR(key) && org_get_type(key) == ORG_TYPE_STRING)
     return 1;
@@ -148,6 +150,7 @@ OrgValue org_table_new_sized(Arena *arena, uint32_t expected) {
   t->entries = alloc_entries(arena, cap);
   if (!t->entries)
     return ORG_ERROR;
+  t->parent = ORG_UNUSED;
 
   return ORG_TAG_PTR_VAL(t);
 }
@@ -192,11 +195,12 @@ OrgValue org_table_push(Arena *arena, OrgValue table, OrgValue value) {
   return org_table_set(arena, table, key, value);
 }
 
-OrgValue org_table_get(OrgValue table, OrgValue key) {
+/* The entry of key in table, or NULL if it has none. */
+static OrgTableEntry *lookup(OrgValue table, OrgValue key) {
   if (!ORG_IS_PTR(table) || org_get_type(table) != ORG_TYPE_TABLE)
-    return ORG_ERROR;
+    return NULL;
   if (!is_valid_key(key))
-    return ORG_ERROR;
+    return NULL;
 
   OrgTable *t = get_table(table);
   uint32_t hash = org_hash_value(key);
@@ -206,16 +210,17 @@ OrgValue org_table_get(OrgValue table, OrgValue key) {
   for (;;) {
     OrgTableEntry *e = &t->entries[idx];
     if (ORG_IS_UNUSED(e->key))
-      return ORG_ERROR; /* Not found */
+      return NULL; /* Not found */
     if (e->hash == hash && org_key_equal(e->key, key))
-      return e->value;
+      return e;
     idx = (idx + 1) & mask;
   }
 }
 
-OrgValue org_table_get_cstr(OrgValue table, const char *name) {
+/* The entry of the string key name in table, or NULL if it has none. */
+static OrgTableEntry *lookup_cstr(OrgValue table, const char *name) {
   if (!ORG_IS_PTR(table) || org_get_type(table) != ORG_TYPE_TABLE)
-    return ORG_ERROR;
+    return NULL;
 
   /* Build a temporary key for lookup without arena allocation.
    * We use the hash of the raw string bytes and compare against
@@ -229,22 +234,31 @@ OrgValue org_table_get_cstr(OrgValue table, const char *name) {
   for (;;) {
     OrgTableEntry *e = &t->entries[idx];
     if (ORG_IS_UNUSED(e->key))
-      return ORG_ERROR;
+      return NULL;
     if (e->hash == hash && ORG_IS_PTR(e->key) &&
         org_get_type(e->key) == ORG_TYPE_STRING) {
       OrgString *s = (OrgString *)ORG_GET_PTR(e->key);
       if (s->byte_len == (uint32_t)name_len &&
           memcmp(s->data, name, name_len) == 0) {
-        return e->value;
+        return e;
       }
     }
     idx = (idx + 1) & mask;
   }
 }
 
+OrgValue org_table_get(OrgValue table, OrgValue key) {
+  OrgTableEntry *e = lookup(table, key);
+  return e ? e->value : ORG_ERROR;
+}
+
+OrgValue org_table_get_cstr(OrgValue table, const char *name) {
+  OrgTableEntry *e = lookup_cstr(table, name);
+  return e ? e->value : ORG_ERROR;
+}
+
 OrgValue org_table_has(OrgValue table, OrgValue key) {
-  OrgValue v = org_table_get(table, key);
-  return ORG_IS_ERROR(v) ? ORG_FALSE : ORG_TRUE;
+  return ORG_BOOL(lookup(table, key) != NULL);
 }
 
 uint32_t org_table_count(OrgValue table) {
@@ -252,3 +266,114 @@ uint32_t org_table_count(OrgValue table) {
     return 0;
   return get_table(table)->count;
 }
+
+/* ---- Indexing ---- */
+
+static int is_table(OrgValue v) {
+  return ORG_IS_PTR(v) && org_get_type(v) == ORG_TYPE_TABLE;
+}
+
+/* The error message "<prefix><v as text><suffix>". */
+static OrgValue error_about(Arena *arena, const char *prefix, OrgValue v,
+                            const char *suffix) {
+  OrgValue text = org_display(arena, v);
+  if (org_is_error(text))
+    return ORG_ERROR;
+  char msg[256];
+  snprintf(msg, sizeof msg, "%s%.*s%s", prefix,
+           (int)org_string_byte_len(text), org_string_data(text), suffix);
+  return org_make_error(arena, msg);
+}
+
+/* The codepoint of the String s at index i, or ORG_ERROR past its end. */
+static OrgValue codepoint_at(Arena *arena, OrgValue s, int64_t i) {
+  const char *data = org_string_data(s);
+  uint32_t len = org_string_byte_len(s);
+  for (uint32_t at = 0; at < len;) {
+    uint32_t size = 1;
+    uint8_t b = (uint8_t)data[at];
+    if ((b & 0xE0) == 0xC0)
+      size = 2;
+    else if ((b & 0xF0) == 0xE0)
+      size = 3;
+    else if ((b & 0xF8) == 0xF0)
+      size = 4;
+    if (at + size > len)
+      size = len - at;
+    if (i-- == 0)
+      return org_make_string(arena, data + at, size);
+    at += size;
+  }
+  return ORG_ERROR;
+}
+
+OrgValue org_index(Arena *arena, OrgValue v, OrgValue key) {
+  if (org_is_error(v))
+    return v;
+  if (org_is_error(key))
+    return key;
+  if (!is_valid_key(key))
+    return error_about(arena, "", key, " cannot be a table key");
+  if (is_table(v)) {
+    OrgTableEntry *e = lookup(v, key);
+    if (e)
+      return e->value;
+  } else if (ORG_IS_PTR(v) && org_get_type(v) == ORG_TYPE_STRING) {
+    if (ORG_IS_SMALL(key) && ORG_UNTAG_SMALL_INT(key) >= 0) {
+      OrgValue c = codepoint_at(arena, v, ORG_UNTAG_SMALL_INT(key));
+      if (!ORG_IS_ERROR(c))
+        return c;
+    }
+  } else {
+    return error_about(arena, "", v, " is not a table");
+  }
+  return error_about(arena, "no key ", key, "");
+}
+
+OrgValue org_select(Arena *arena, OrgValue key, OrgValue table) {
+  if (org_is_error(key))
+    return key;
+  return org_index(arena, table, key);
+}
+
+/* ---- Scopes ---- */
+
+OrgValue org_scope_new(Arena *arena, OrgValue parent) {
+  OrgValue scope = org_table_new(arena);
+  if (is_table(scope))
+    get_table(scope)->parent = parent;
+  return scope;
+}
+
+OrgValue org_scope_get(Arena *arena, OrgValue env, const char *name) {
+  for (OrgValue s = env; is_table(s); s = get_table(s)->parent) {
+    OrgTableEntry *e = lookup_cstr(s, name);
+    if (e)
+      return e->value;
+  }
+  char msg[256];
+  snprintf(msg, sizeof msg, "undefined identifier: %s", name);
+  return org_make_error(arena, msg);
+}
+
+OrgValue org_scope_bind(Arena *arena, OrgValue env, const char *name,
+                        OrgValue value) {
+  OrgValue key = org_make_string(arena, name, strlen(name));
+  if (org_is_error(key))
+    return key;
+  if (ORG_IS_ERROR(org_table_set(arena, env, key, value)))
+    return ORG_ERROR;
+  return value;
+}
+
+OrgValue org_scope_assign(Arena *arena, OrgValue env, const char *name,
+                          OrgValue value) {
+  for (OrgValue s = env; is_table(s); s = get_table(s)->parent) {
+    OrgTableEntry *e = lookup_cstr(s, name);
+    if (e) {
+      e->value = value;
+      return value;
+    }
+  }
+  return org_scope_bind(arena, env, name, value);
+}
@@ -15,7 +15,7 @@
  */
 
 typedef struct OrgTableEntry {
-  OrgValue key;   /* String or SmallInt key (ORG_UNUSED = empty slot) */
+  OrgValue key;   /* String, SmallInt or Boolean key (ORG_UNUSED = empty slot) */
   OrgValue value; /* The stored value */
   uint32_t hash;  /* Cached hash of the key */
   uint32_t _pad;
@@ -28,6 +28,7 @@ typedef struct OrgTable {
   uint32_t next_index; /* Next auto-index for positional elements */
   uint32_t _pad;
   OrgTableEntry *entries; /* Arena-allocated hash table array */
+  OrgValue parent;        /* Enclosing scope of a scope, else ORG_UNUSED */
 } OrgTable;
 
 /* ---- Construction ---- */
@@ -42,7 +43,8 @@ OrgValue org_table_new_sized(Arena *arena, uint32_t expected);
 
 /*
  * Set a key-value pair. If the key already exists, its value is updated.
- * Key must be a String or SmallInt. Returns ORG_ERROR on invalid key.
+ * Key must be a String, SmallInt or Boolean. Returns ORG_ERROR on invalid
+ * key.
  */
 OrgValue org_table_set(Arena *arena, OrgValue table, OrgValue key,
                        OrgValue value);
@@ -57,7 +59,7 @@ OrgValue org_table_push(Arena *arena, OrgValue table, OrgValue value);
 
 /*
  * Get a value by key. Returns ORG_ERROR if key not found.
- * Key must be a String or SmallInt.
+ * Key must be a String, SmallInt or Boolean.
  */
 OrgValue org_table_get(OrgValue table, OrgValue key);
 
@@ -73,6 +75,42 @@ OrgValue org_table_get_cstr(OrgValue table, const char *name);
  */
 OrgValue org_table_has(OrgValue table, OrgValue key);
 
+/*
+ * table.key and key ? table for OrgLang code: the entry of a table, or
+ * the codepoint of a String at an index. An Error key or value is
+ * returned as it is; a missing entry is an Error saying so ("no key b").
+ */
+OrgValue org_index(Arena *arena, OrgValue v, OrgValue key);
+
+/* key ? table: org_index, with an Error key returned first. */
+OrgValue org_select(Arena *arena, OrgValue key, OrgValue table);
+
+/* ---- Scopes ---- */
+
+/*
+ * A scope is a table of the names bound in it, with the scope it is
+ * nested in as its parent: a module scope, or the scope of a call, over
+ * the scope the block was defined in.
+ */
+OrgValue org_scope_new(Arena *arena, OrgValue parent);
+
+/*
+ * The value of name in env or the nearest scope enclosing it, or an
+ * Error "undefined identifier: name".
+ */
+OrgValue org_scope_get(Arena *arena, OrgValue env, const char *name);
+
+/* Bind name to value in env itself. Returns value. */
+OrgValue org_scope_bind(Arena *arena, OrgValue env, const char *name,
+                        OrgValue value);
+
+/*
+ * Rebind name to value in the nearest scope binding it, from env out, or
+ * in env if none does (x :+ 1). Returns value.
+ */
+OrgValue org_scope_assign(Arena *arena, OrgValue env, const char *name,
+                          OrgValue value);
+
 /* ---- Size ---- */
 
 /* Get the number of entries in the table. */
@@ -80,10 +118,10 @@ uint32_t org_table_count(OrgValue table);
 
 /* ---- Internal ---- */
 
-/* Compute hash for a key (String or SmallInt). */
+/* Compute hash for a key (String, SmallInt or Boolean). */
 uint32_t org_hash_value(OrgValue key);
 
-/* Compare two keys for equality (String content or SmallInt value). */
+/* Compare two keys for equality (String content, else the value). */
 int org_key_equal(OrgValue a, OrgValue b);
 
 #endif /* ORG_TABLE_H */
@@ -0,0 +1,87 @@
+// Package scaffold holds the project templates of org new. Each kind of
+// project is a directory under templates: its files are copied into the
+// new project with the same paths, after text/template has filled in
+// {{.Name}}, the project's name. Every project also gets the manifest of
+// manifest.Scaffold, or manifest.ScaffoldLib for a lib, and
+// templates/gitignore as .gitignore.
+package scaffold
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"text/template"
+
+	"orglang/pkg/manifest"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// Kind is a kind of project org new creates.
+type Kind struct {
+	Name    string
+	Summary string
+}
+
+// Kinds returns the kinds of project, in the order org new lists them.
+func Kinds() []Kind {
+	return []Kind{
+		{"cli", "a command-line program reading its arguments"},
+		{"pipeline", "a program sending its input through stages of a flow"},
+		{"lib", "a library for other projects to depend on"},
+	}
+}
+
+// File is a file of a new project.
+type File struct {
+	Path string // relative to the project root, slash-separated
+	Data []byte
+}
+
+// Files returns the files of a new project of kind named name: org.toml
+// and .gitignore, then those of the kind's template in path order.
+func Files(kind, name string) ([]File, error) {
+	known := false
+	var names []string
+	for _, k := range Kinds() {
+		known = known || k.Name == kind
+		names = append(names, k.Name)
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown kind of project %q (have %s)", kind, strings.Join(names, ", "))
+	}
+	ignore, _ := templates.ReadFile("templates/gitignore")
+	toml := manifest.Scaffold(name)
+	if kind == "lib" {
+		toml = manifest.ScaffoldLib(name)
+	}
+	files := []File{
+		{Path: manifest.FileName, Data: toml},
+		{Path: ".gitignore", Data: ignore},
+	}
+	root := path.Join("templates", kind)
+	err := fs.WalkDir(templates, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		src, err := templates.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		t, err := template.New(p).Option("missingkey=error").Parse(string(src))
+		if err != nil {
+			return err
+		}
+		var out bytes.Buffer
+		if err := t.Execute(&out, struct{ Name string }{name}); err != nil {
+			return err
+		}
+		files = append(files, File{Path: strings.TrimPrefix(p, root+"/"), Data: out.Bytes()})
+		return nil
+	})
+	return files, err
+}
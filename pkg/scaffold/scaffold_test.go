@@ -0,0 +1,98 @@
+package scaffold
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/eval"
+	"orglang/pkg/lexer"
+	"orglang/pkg/manifest"
+	"orglang/pkg/parser"
+)
+
+func parseFile(t *testing.T, path string) *ast.Program {
+	t.Helper()
+	src, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parser.New(lexer.New(src), parser.WithStrict(true))
+	prog := p.ParseProgram()
+	if diags := p.Diagnostics(); len(diags) > 0 {
+		t.Fatalf("%s: %v", path, diags)
+	}
+	return prog
+}
+
+// TestKinds writes each kind of project and runs its tests, as org test
+// would in the new project.
+func TestKinds(t *testing.T) {
+	for _, k := range Kinds() {
+		t.Run(k.Name, func(t *testing.T) {
+			dir := t.TempDir()
+			files, err := Files(k.Name, "demo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range files {
+				path := filepath.Join(dir, filepath.FromSlash(f.Path))
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, f.Data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			m, err := manifest.Load(filepath.Join(dir, manifest.FileName))
+			if err != nil || m.Name != "demo" {
+				t.Fatalf("manifest: %+v, %v", m, err)
+			}
+			if _, err := os.Stat(m.LibEntry()); err != nil {
+				t.Errorf("entry point: %v", err)
+			}
+			if (m.Main == "") != (k.Name == "lib") {
+				t.Errorf("main = %q", m.Main)
+			}
+
+			load := func(from, path string) (string, *ast.Program, error) {
+				path = filepath.Join(filepath.Dir(from), path)
+				return path, parseFile(t, path), nil
+			}
+			tests := 0
+			for _, f := range files {
+				if !strings.HasSuffix(f.Path, ".org") {
+					continue
+				}
+				file := filepath.Join(dir, filepath.FromSlash(f.Path))
+				prog := parseFile(t, file)
+				if strings.Contains(string(f.Data), "{{") {
+					t.Errorf("%s: template not filled in", f.Path)
+				}
+				if !strings.HasSuffix(f.Path, "_test.org") {
+					continue
+				}
+				in := eval.New()
+				in.Modules, in.File = load, file
+				for _, r := range in.RunTests(prog, nil, nil) {
+					r.CompareGolden(eval.GoldenFile(file, r.Name), false)
+					if !r.Passed() {
+						t.Errorf("%s: %s: %s", f.Path, r.Name, r.Failure)
+					}
+					tests++
+				}
+			}
+			if tests == 0 {
+				t.Error("no tests")
+			}
+		})
+	}
+}
+
+func TestUnknownKind(t *testing.T) {
+	if _, err := Files("app", "demo"); err == nil || !strings.Contains(err.Error(), "cli, pipeline, lib") {
+		t.Errorf("err = %v", err)
+	}
+}
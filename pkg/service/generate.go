@@ -0,0 +1,331 @@
+package service
+
+import (
+	"strings"
+	"text/template"
+)
+
+// File is a generated file, named relative to the output directory.
+// Skeleton files are meant to be edited and are not overwritten unless
+// asked to; the others are regenerated from the spec.
+type File struct {
+	Name     string
+	Content  string
+	Skeleton bool
+}
+
+// Generate returns the files of the service described by s, read from
+// the spec file named spec:
+//
+//	<name>.org         the handlers (skeleton), dispatcher and resource
+//	<name>_server.c    the JSON-RPC server over the library build
+func (s *Spec) Generate(spec string) []File {
+	data := struct {
+		*Spec
+		Source string
+	}{s, spec}
+	return []File{
+		{Name: s.Name + ".org", Content: run(moduleTmpl, data), Skeleton: true},
+		{Name: s.Name + "_server.c", Content: run(serverTmpl, data)},
+	}
+}
+
+func run(t *template.Template, data any) string {
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		// The templates only read fields of the spec.
+		panic(err)
+	}
+	return b.String()
+}
+
+var funcs = template.FuncMap{
+	"join": strings.Join,
+	// wrap fills text into lines of at most 72 columns after prefix.
+	"wrap": func(prefix, text string) string {
+		var lines []string
+		line := prefix
+		for _, w := range strings.Fields(text) {
+			if line != prefix && len(line)+1+len(w) > 72 {
+				lines = append(lines, line)
+				line = prefix
+			}
+			if line != prefix {
+				line += " "
+			}
+			line += w
+		}
+		return strings.Join(append(lines, line), "\n")
+	},
+}
+
+var moduleTmpl = template.Must(template.New("module").Funcs(funcs).Parse(`# {{.Name}}.org — the {{.Name}} service, generated by ` + "`org gen service`" + ` from
+# {{.Source}}. Implement the on_* handlers; the rest follows the spec.
+#
+# ` + "`org build --library {{.Name}}.org`" + ` builds the module as a C library
+# with the header {{.Name}}.h, which {{.Name}}_server.c serves as JSON-RPC 2.0 on
+# port {{.Port}}.
+{{range .Methods}}
+"""
+{{if .Doc}}{{wrap "" .Doc}}{{else}}Handles the {{.Name}} method.{{end}}
+{{- if .Params}}
+
+Params: {{join .Params ", "}}.{{end}}
+
+@param right the params of the request: a table keyed by name, or positional
+@returns the result of the call; an Error is answered as a JSON-RPC error
+"""
+{{.Handler}} : {
+    right # TODO: implement. Echoes the params until then.
+};
+{{end}}
+"""
+Calls the handler of the method named left with the params right. An
+unknown method is an Error.
+
+@export {{.Name}}_handle
+"""
+handle : {
+    left ? [
+{{- range .Methods}}
+        {{.Name}}: ({{.Handler}} right)
+{{- end}}
+    ]
+};
+
+"""
+The service as a resource: each request [method: ... params: ...] pushed
+into it is answered by its handler, so a stream of requests is served by
+` + "`requests -> @{{.Name}}`" + `.
+"""
+{{.Name}} @: [
+    next: { right.method handle right.params }
+];
+`))
+
+var serverTmpl = template.Must(template.New("server").Funcs(funcs).Parse(`/*
+ * {{.Name}}_server.c — JSON-RPC 2.0 server of the {{.Name}} service, generated by
+ * ` + "`org gen service`" + ` from {{.Source}}. Regenerate it rather than edit it.
+ *
+ * Each request is a JSON-RPC 2.0 object on one line of a TCP connection,
+ * answered by one line; notifications (no "id") get no answer and batches
+ * are not supported. The method and params go to {{.Name}}_handle, the
+ * dispatcher of {{.Name}}.org, and an Error it returns becomes error -32000
+ * with its message. Connections are served one at a time, so a handler
+ * runs alone; the values of a request live until its answer is written.
+ *
+ * Build, with the runtime headers of pkg/runtime:
+ *   org build --library {{.Name}}.org
+ *   cc -Ipkg/runtime -I. {{.Name}}_server.c lib{{.Name}}.a -lgmp -o {{.Name}}_server
+ *   ./{{.Name}}_server [port [address]]     (default {{.Port}} on 127.0.0.1)
+ */
+#include "{{.Name}}.h"
+#include "core/arena.h"
+#include "core/print.h"
+#include "core/status.h"
+#include "gmp/gmp_glue.h"
+#include "json/json.h"
+#include "table/table.h"
+#include <arpa/inet.h>
+#include <errno.h>
+#include <netinet/in.h>
+#include <signal.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <sys/socket.h>
+#include <unistd.h>
+
+#define DEFAULT_PORT {{.Port}}
+#define MAX_LINE (1 << 20) /* bytes of one request */
+
+static const char *const methods[] = {
+{{- range .Methods}}
+    "{{.Name}}",
+{{- end}}
+    NULL,
+};
+
+static int is_string(OrgValue v) {
+  return ORG_IS_PTR(v) && org_get_type(v) == ORG_TYPE_STRING;
+}
+
+static int is_table(OrgValue v) {
+  return ORG_IS_PTR(v) && org_get_type(v) == ORG_TYPE_TABLE;
+}
+
+static int string_is(OrgValue v, const char *s) {
+  return is_string(v) && org_string_byte_len(v) == strlen(s) &&
+         memcmp(org_string_data(v), s, strlen(s)) == 0;
+}
+
+/* Set *out to obj[key]; returns 0 if obj has no such key. */
+static int field(Arena *arena, OrgValue obj, const char *key, OrgValue *out) {
+  OrgValue k = org_make_string(arena, key, strlen(key));
+  if (!ORG_IS_TRUE(org_table_has(obj, k)))
+    return 0;
+  *out = org_table_get(obj, k);
+  return 1;
+}
+
+/* Write {"jsonrpc":"2.0",<body>,"id":<id>} and a newline. */
+static int reply(int fd, const char *body, OrgValue id) {
+  char *id_json = org_json_encode(id, NULL);
+  int rc = -1;
+  if (id_json && org_write_bytes(fd, "{\"jsonrpc\":\"2.0\",", 17) == 0 &&
+      org_write_bytes(fd, body, strlen(body)) == 0 &&
+      org_write_bytes(fd, ",\"id\":", 6) == 0 &&
+      org_write_bytes(fd, id_json, strlen(id_json)) == 0)
+    rc = org_write_bytes(fd, "}\n", 2);
+  free(id_json);
+  return rc;
+}
+
+static int reply_error(int fd, Arena *arena, OrgValue id, int code,
+                       const char *message) {
+  char *msg = org_json_encode(org_make_string(arena, message, strlen(message)),
+                              NULL);
+  size_t size = (msg ? strlen(msg) : 0) + 64;
+  char *body = malloc(size);
+  int rc = -1;
+  if (msg && body) {
+    snprintf(body, size, "\"error\":{\"code\":%d,\"message\":%s}", code, msg);
+    rc = reply(fd, body, id);
+  }
+  free(msg);
+  free(body);
+  return rc;
+}
+
+/* Answer one request. Returns 0, or -1 when the connection failed. */
+static int serve_request(int fd, Arena *arena, const char *line, size_t len) {
+  OrgValue req, method, version, id = ORG_UNUSED, params = ORG_UNUSED;
+  if (org_json_decode(arena, line, len, &req) < 0)
+    return reply_error(fd, arena, id, -32700, "Parse error");
+  if (!is_table(req))
+    return reply_error(fd, arena, id, -32600, "Invalid Request");
+  int notification = !field(arena, req, "id", &id);
+  if (!field(arena, req, "jsonrpc", &version) || !string_is(version, "2.0") ||
+      !field(arena, req, "method", &method) || !is_string(method))
+    return reply_error(fd, arena, id, -32600, "Invalid Request");
+  if (field(arena, req, "params", &params) && !is_table(params))
+    return reply_error(fd, arena, id, -32602, "Invalid params");
+  if (!is_table(params))
+    params = org_table_new(arena);
+
+  int known = 0;
+  for (const char *const *m = methods; *m && !known; m++)
+    known = string_is(method, *m);
+  if (!known)
+    return notification ? 0 : reply_error(fd, arena, id, -32601,
+                                           "Method not found");
+
+  OrgValue result = {{.Name}}_handle(method, params);
+  if (notification)
+    return 0;
+  if (org_is_error(result)) {
+    const char *msg = org_error_message(result);
+    return reply_error(fd, arena, id, -32000, *msg ? msg : "Error");
+  }
+  char *json = org_json_encode(result, NULL);
+  if (!json)
+    return reply_error(fd, arena, id, -32603,
+                       "Internal error: the result has no JSON form");
+  char *body = malloc(strlen(json) + 10);
+  int rc = -1;
+  if (body) {
+    strcpy(body, "\"result\":");
+    strcat(body, json);
+    rc = reply(fd, body, id);
+  }
+  free(json);
+  free(body);
+  return rc;
+}
+
+/* Serve the requests of one connection until it closes. */
+static void serve(int fd, Arena *arena) {
+  char *buf = malloc(MAX_LINE);
+  size_t len = 0;
+  while (buf) {
+    ssize_t n = read(fd, buf + len, MAX_LINE - len);
+    if (n < 0 && errno == EINTR)
+      continue;
+    if (n <= 0)
+      break;
+    len += (size_t)n;
+
+    char *start = buf, *nl;
+    while ((nl = memchr(start, '\n', (size_t)(buf + len - start)))) {
+      size_t line = (size_t)(nl - start);
+      if (line > 0 && start[line - 1] == '\r')
+        line--;
+      if (line > 0) {
+        /* Numbers decoded by GMP go to the request arena too. */
+        Arena *gmp = org_gmp_get_arena();
+        ArenaCheckpoint cp = arena_save(arena);
+        org_gmp_set_arena(arena);
+        int rc = serve_request(fd, arena, start, line);
+        org_gmp_set_arena(gmp);
+        arena_restore(arena, cp);
+        if (rc < 0)
+          goto done;
+      }
+      start = nl + 1;
+    }
+    len = (size_t)(buf + len - start);
+    memmove(buf, start, len);
+    if (len == MAX_LINE) {
+      reply_error(fd, arena, ORG_UNUSED, -32600, "Invalid Request: too long");
+      break;
+    }
+  }
+done:
+  free(buf);
+}
+
+int main(int argc, char **argv) {
+  int port = argc > 1 ? atoi(argv[1]) : DEFAULT_PORT;
+  const char *address = argc > 2 ? argv[2] : "127.0.0.1";
+  struct sockaddr_in addr = {0};
+  addr.sin_family = AF_INET;
+  addr.sin_port = htons((uint16_t)port);
+  if (argc > 3 || port <= 0 || port > 65535 ||
+      inet_pton(AF_INET, address, &addr.sin_addr) != 1) {
+    fprintf(stderr, "usage: %s [port [address]]\n", argv[0]);
+    return EXIT_FAILURE;
+  }
+  signal(SIGPIPE, SIG_IGN);
+
+  int status = {{.Name}}_init();
+  if (status != 0)
+    return status;
+  int srv = socket(AF_INET, SOCK_STREAM, 0);
+  int on = 1;
+  if (srv < 0 || setsockopt(srv, SOL_SOCKET, SO_REUSEADDR, &on, sizeof on) < 0 ||
+      bind(srv, (struct sockaddr *)&addr, sizeof addr) < 0 ||
+      listen(srv, 16) < 0) {
+    perror("{{.Name}}_server");
+    {{.Name}}_shutdown();
+    return ORG_EXIT_RESOURCE;
+  }
+  fprintf(stderr, "{{.Name}}: listening on %s:%d\n", address, port);
+
+  Arena *arena = arena_new(ARENA_DEFAULT_PAGE_SIZE);
+  for (;;) {
+    int fd = accept(srv, NULL, NULL);
+    if (fd < 0) {
+      if (errno == EINTR || errno == ECONNABORTED)
+        continue;
+      perror("{{.Name}}_server: accept");
+      break;
+    }
+    serve(fd, arena);
+    close(fd);
+  }
+  arena_destroy(arena);
+  close(srv);
+  {{.Name}}_shutdown();
+  return ORG_EXIT_RESOURCE;
+}
+`))
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"orglang/pkg/format"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+)
+
+const calcSpec = `# The calculator.
+service : [
+    name: "calc"
+    port: 9000
+    methods: [
+        add: [params: ["a" "b"] doc: "Adds a and b."]
+        ping: []
+    ]
+];
+`
+
+func TestParse(t *testing.T) {
+	spec, err := Parse([]byte(calcSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Spec{
+		Name:     "calc",
+		Port:     9000,
+		Protocol: "jsonrpc",
+		Methods: []Method{
+			{Name: "add", Params: []string{"a", "b"}, Doc: "Adds a and b."},
+			{Name: "ping"},
+		},
+	}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("Parse = %+v, want %+v", spec, want)
+	}
+
+	spec, err = Parse([]byte(`service : [name: "s" methods: [m: []]];`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Port != DefaultPort || spec.Protocol != "jsonrpc" {
+		t.Errorf("defaults: port %d, protocol %q", spec.Port, spec.Protocol)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`x : 1;`, "no `service"},
+		{`service : 1;`, "want a table literal"},
+		{`service : [methods: [m: []]];`, "name is missing"},
+		{`service : [name: "my-svc" methods: [m: []]];`, "C identifier prefix"},
+		{`service : [name: "my_svc" methods: [m: []]];`, "C identifier prefix"},
+		{`service : [name: "s" port: 70000 methods: [m: []]];`, "1 to 65535"},
+		{`service : [name: "s" protocol: "grpc" methods: [m: []]];`, "grpc is not supported"},
+		{`service : [name: "s" protocol: "soap" methods: [m: []]];`, "unknown protocol"},
+		{`service : [name: "s" methods: []];`, "at least one method"},
+		{`service : [name: "s" methods: [m: [] m: []]];`, "given twice"},
+		{`service : [name: "s" methods: ["true": []]];`, "not keywords"},
+		{`service : [name: "s" methods: [m: [params: [1]]]];`, "want a string"},
+		{`service : [name: "s" methods: [m: [args: []]]];`, "unknown key"},
+		{`service : [name: "s" methods: [m: [] on_m: []]];`, "clashes with the handler"},
+		{`service : [name: "handle" methods: [m: []]];`, "dispatcher"},
+		{`service : [name: "s" name: "t" methods: [m: []]];`, "given twice"},
+		{`service : [name: "s" host: "x" methods: [m: []]];`, "unknown key"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%s) = %v, want error containing %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func generate(t *testing.T) (module, server File) {
+	t.Helper()
+	spec, err := Parse([]byte(calcSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := spec.Generate("calc_spec.org")
+	if len(files) != 2 {
+		t.Fatalf("Generate returned %d files", len(files))
+	}
+	return files[0], files[1]
+}
+
+func TestGenerateModule(t *testing.T) {
+	module, _ := generate(t)
+	if module.Name != "calc.org" || !module.Skeleton {
+		t.Errorf("module file %q, skeleton %v", module.Name, module.Skeleton)
+	}
+	src := []byte(module.Content)
+	p := parser.New(lexer.New(src))
+	p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("generated module does not parse: %v\n%s", errs, src)
+	}
+	out, err := format.Source(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != module.Content {
+		t.Errorf("generated module is not formatted:\n%s", module.Content)
+	}
+	for _, want := range []string{
+		"on_add : {",
+		"Params: a, b.",
+		"Handles the ping method.",
+		"@export calc_handle",
+		"add: (on_add right)",
+		"ping: (on_ping right)",
+		"calc @: [",
+	} {
+		if !strings.Contains(module.Content, want) {
+			t.Errorf("module is missing %q:\n%s", want, module.Content)
+		}
+	}
+}
+
+func TestGenerateServer(t *testing.T) {
+	_, server := generate(t)
+	if server.Name != "calc_server.c" || server.Skeleton {
+		t.Errorf("server file %q, skeleton %v", server.Name, server.Skeleton)
+	}
+	for _, want := range []string{
+		`#include "calc.h"`,
+		"#define DEFAULT_PORT 9000",
+		"    \"add\",\n    \"ping\",\n    NULL,",
+		"calc_handle(method, params)",
+		"calc_init()",
+		"calc_shutdown()",
+	} {
+		if !strings.Contains(server.Content, want) {
+			t.Errorf("server is missing %q", want)
+		}
+	}
+}
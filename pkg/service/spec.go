@@ -0,0 +1,243 @@
+// Package service generates the scaffold of a network service from a
+// table-based description, for `org gen service`.
+//
+// A spec is an OrgLang file binding `service` to a table literal:
+//
+//	service : [
+//	    name: "calc"
+//	    port: 8080
+//	    protocol: "jsonrpc"
+//	    methods: [
+//	        add: [params: ["a" "b"] doc: "Adds a and b."]
+//	        ping: []
+//	    ]
+//	];
+//
+// The spec is read from the syntax tree, not evaluated, so it must be
+// written with literals. From it the generator writes the OrgLang module
+// (one handler per method, a dispatcher exported to C and the service
+// resource) and the C server that feeds network requests to it.
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/codegen"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+	"orglang/pkg/token"
+)
+
+// DefaultPort is the port of a spec without one.
+const DefaultPort = 8080
+
+// Spec is a service description.
+type Spec struct {
+	Name     string
+	Port     int
+	Protocol string
+	Methods  []Method
+}
+
+// Method is one remote procedure. Params only documents the parameter
+// names; requests may pass them by name or by position.
+type Method struct {
+	Name   string
+	Params []string
+	Doc    string
+}
+
+// Handler is the name of the OrgLang block that implements m.
+func (m Method) Handler() string { return "on_" + m.Name }
+
+var methodRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Parse reads the spec bound to `service` in src.
+func Parse(src []byte) (*Spec, error) {
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, fmt.Errorf("%s", errs[0])
+	}
+	var table *ast.TableLiteral
+	for _, stmt := range prog.Statements {
+		b, ok := stmt.(*ast.BindingExpr)
+		if !ok || b.Operator != "" && b.Operator != ":" {
+			continue
+		}
+		if n, ok := b.Name.(*ast.Name); ok && n.Value == "service" {
+			if table, ok = b.Value.(*ast.TableLiteral); !ok {
+				return nil, fmt.Errorf("service: want a table literal, got %s", b.Value)
+			}
+		}
+	}
+	if table == nil {
+		return nil, fmt.Errorf("no `service : [...]` binding")
+	}
+
+	spec := &Spec{Port: DefaultPort, Protocol: "jsonrpc"}
+	seen := map[string]bool{}
+	for _, e := range table.Elements {
+		key, value, err := entry("service", e)
+		if err != nil {
+			return nil, err
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("service.%s is given twice", key)
+		}
+		seen[key] = true
+		switch key {
+		case "name":
+			spec.Name, err = stringValue("service.name", value)
+		case "port":
+			spec.Port, err = portValue(value)
+		case "protocol":
+			spec.Protocol, err = stringValue("service.protocol", value)
+		case "methods":
+			spec.Methods, err = methods(value)
+		default:
+			err = fmt.Errorf("service.%s: unknown key (want name, port, protocol or methods)", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return spec, spec.validate()
+}
+
+func (s *Spec) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("service.name is missing")
+	}
+	// The name prefixes the C symbols of the library and its header, so
+	// it must be one that mangling keeps as is.
+	if _, err := codegen.ExportSymbol(s.Name, codegen.Export{Binding: "handle"}); err != nil || s.Name != codegen.MangleIdentifier(s.Name) {
+		return fmt.Errorf("service.name: %q is not usable as a C identifier prefix (use letters and digits)", s.Name)
+	}
+	switch s.Protocol {
+	case "jsonrpc":
+	case "grpc":
+		return fmt.Errorf("service.protocol: grpc is not supported yet; use jsonrpc")
+	default:
+		return fmt.Errorf("service.protocol: unknown protocol %q (want jsonrpc)", s.Protocol)
+	}
+	if len(s.Methods) == 0 {
+		return fmt.Errorf("service.methods: a service needs at least one method")
+	}
+	// The module binds the handlers, handle and the resource; handler
+	// names have a '_', which service names cannot.
+	if s.Name == "handle" {
+		return fmt.Errorf("service.name: handle is the name of the dispatcher")
+	}
+	names := map[string]bool{}
+	for _, m := range s.Methods {
+		names[m.Name] = true
+	}
+	for _, m := range s.Methods {
+		// The dispatch table binds the method names in the scope where
+		// the handlers are called.
+		if names[m.Handler()] {
+			return fmt.Errorf("service.methods: method %s clashes with the handler of method %s", m.Handler(), m.Name)
+		}
+	}
+	return nil
+}
+
+// entry splits a `key: value` table element.
+func entry(where string, e ast.Expression) (string, ast.Expression, error) {
+	b, ok := e.(*ast.BindingExpr)
+	if !ok || b.Operator != "" && b.Operator != ":" {
+		return "", nil, fmt.Errorf("%s: want `key: value` entries, got %s", where, e)
+	}
+	switch n := b.Name.(type) {
+	case *ast.Name:
+		return n.Value, b.Value, nil
+	case *ast.StringLiteral:
+		return n.Value, b.Value, nil
+	}
+	return "", nil, fmt.Errorf("%s: key %s is not a name", where, b.Name)
+}
+
+func stringValue(where string, e ast.Expression) (string, error) {
+	if s, ok := e.(*ast.StringLiteral); ok && !s.IsDoc {
+		return s.Value, nil
+	}
+	return "", fmt.Errorf("%s: want a string, got %s", where, e)
+}
+
+func portValue(e ast.Expression) (int, error) {
+	if i, ok := e.(*ast.IntegerLiteral); ok {
+		if n, err := strconv.Atoi(i.Value); err == nil && n > 0 && n < 65536 {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("service.port: want an integer from 1 to 65535, got %s", e)
+}
+
+func methods(e ast.Expression) ([]Method, error) {
+	t, ok := e.(*ast.TableLiteral)
+	if !ok {
+		return nil, fmt.Errorf("service.methods: want a table, got %s", e)
+	}
+	var out []Method
+	seen := map[string]bool{}
+	for _, el := range t.Elements {
+		name, value, err := entry("service.methods", el)
+		if err != nil {
+			return nil, err
+		}
+		where := "service.methods." + name
+		if !methodRe.MatchString(name) || token.LookupIdent(name) != token.IDENTIFIER {
+			return nil, fmt.Errorf("%s: method names are letters, digits and _, and not keywords", where)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s is given twice", where)
+		}
+		seen[name] = true
+
+		m := Method{Name: name}
+		opts, ok := value.(*ast.TableLiteral)
+		if !ok {
+			return nil, fmt.Errorf("%s: want a table such as [params: [\"a\"] doc: \"...\"], got %s", where, value)
+		}
+		for _, o := range opts.Elements {
+			key, v, err := entry(where, o)
+			if err != nil {
+				return nil, err
+			}
+			switch key {
+			case "params":
+				if m.Params, err = params(where+".params", v); err != nil {
+					return nil, err
+				}
+			case "doc":
+				if m.Doc, err = stringValue(where+".doc", v); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, fmt.Errorf("%s.%s: unknown key (want params or doc)", where, key)
+			}
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+func params(where string, e ast.Expression) ([]string, error) {
+	t, ok := e.(*ast.TableLiteral)
+	if !ok {
+		return nil, fmt.Errorf("%s: want a table of strings, got %s", where, e)
+	}
+	var out []string
+	for _, el := range t.Elements {
+		s, err := stringValue(where, el)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
@@ -0,0 +1,87 @@
+// Package std holds the standard library modules written in OrgLang,
+// embedded in the org binary.
+//
+// A program opts into a module by name (`use = ["math"]` in org.toml, or
+// `--use math`). Unlike an imported module, reached through its alias, a
+// standard module is a prelude: its bindings, operators included, join the
+// top-level scope of the program, so `2 × 3` parses with the binding power
+// the module gives `×`.
+package std
+
+import (
+	"embed"
+	"fmt"
+	"slices"
+	"strings"
+
+	"orglang/pkg/ast"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+)
+
+//go:embed *.org
+var files embed.FS
+
+// Names returns the names of the modules, sorted.
+func Names() []string {
+	entries, _ := files.ReadDir(".")
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".org"))
+	}
+	return names
+}
+
+// Source returns the source of the module name.
+func Source(name string) ([]byte, bool) {
+	src, err := files.ReadFile(name + ".org")
+	return src, err == nil
+}
+
+// Prelude is what the modules used by a program add to it.
+type Prelude struct {
+	// Bindings holds the operators and names of the modules; parsing
+	// the program with it (parser.WithBindings) makes them known.
+	Bindings *parser.BindingTable
+	// Statements bind the modules' names, to run before the program.
+	Statements []ast.Statement
+	// Names are the names bound, in module order.
+	Names []string
+}
+
+// Load parses the modules names, in order, into a prelude. A module named
+// twice is loaded once; unknown modules are errors.
+func Load(names []string) (*Prelude, error) {
+	pre := &Prelude{Bindings: parser.NewBindingTable()}
+	var loaded []string
+	for _, name := range names {
+		if slices.Contains(loaded, name) {
+			continue
+		}
+		loaded = append(loaded, name)
+		src, ok := Source(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown std module %q (have %s)", name, strings.Join(Names(), ", "))
+		}
+		p := parser.New(lexer.New(src), parser.WithStrict(true), parser.WithBindings(pre.Bindings))
+		prog := p.ParseProgram()
+		if errs := p.Errors(); len(errs) > 0 {
+			return nil, fmt.Errorf("std module %s: %s", name, errs[0])
+		}
+		pre.Statements = append(pre.Statements, prog.Statements...)
+		for _, stmt := range prog.Statements {
+			if be, ok := stmt.(*ast.BindingExpr); ok {
+				if n, ok := be.Name.(*ast.Name); ok && !slices.Contains(pre.Names, n.Value) {
+					pre.Names = append(pre.Names, n.Value)
+				}
+			}
+		}
+	}
+	return pre, nil
+}
+
+// Apply runs the prelude before prog, returning prog.
+func (pre *Prelude) Apply(prog *ast.Program) *ast.Program {
+	prog.Statements = append(slices.Clip(pre.Statements), prog.Statements...)
+	return prog
+}
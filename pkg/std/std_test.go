@@ -0,0 +1,61 @@
+package std
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"orglang/pkg/eval"
+	"orglang/pkg/lexer"
+	"orglang/pkg/parser"
+)
+
+func TestMath(t *testing.T) {
+	pre, err := Load([]string{"math"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`2 × 3 + 1`, "7"},
+		{`1 + 6 ÷ 4`, "5/2"},
+		{`10 ± 2`, "[12 8]"},
+		{`[(1 ≠ 2) (2 ≤ 2) (1 ≥ 2)]`, "[true true false]"},
+		{`¬ true ∨ false ∧ true`, "false"},
+		{`inc : { right + 1 }; dbl : { right * 2 }; 5 -> ((inc) ∘ (dbl))`, "11"},
+		{`[(√ 16) (√ 9/4) (√ 2.25) (√ 2)]`, "[4 3/2 1.50 1.4142135623730950]"},
+		{`√ (0 - 1)`, "Error: sqrt of negative number -1"},
+		{`[(∑ [1 2 3 4]) (∑ []) (∏ [2 3 4]) (∏ [])]`, "[10 0 24 1]"},
+	}
+	for _, tt := range tests {
+		p := parser.New(lexer.New([]byte(tt.input)), parser.WithStrict(true), parser.WithBindings(pre.Bindings))
+		prog := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("parse %q: %v", tt.input, p.Errors())
+		}
+		in := eval.New()
+		if got := in.Force(in.Eval(pre.Apply(prog))).String(); got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	if names := Names(); !slices.Contains(names, "math") {
+		t.Errorf("Names() = %v, want math among them", names)
+	}
+	pre, err := Load([]string{"math"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"×", "√", "∑", "∘"} {
+		if !slices.Contains(pre.Names, name) {
+			t.Errorf("math does not bind %s", name)
+		}
+	}
+	if _, err := Load([]string{"nope"}); err == nil || !strings.Contains(err.Error(), `unknown std module "nope"`) {
+		t.Errorf("Load(nope) = %v, want unknown module", err)
+	}
+}
@@ -9,6 +9,11 @@ const (
 	ILLEGAL TokenType = "ILLEGAL"
 	EOF     TokenType = "EOF"
 
+	// Comments, returned only by a lexer keeping them
+	// (lexer.WithComments); the literal is the comment's text.
+	COMMENT       TokenType = "COMMENT"       // # to the end of the line
+	BLOCK_COMMENT TokenType = "BLOCK_COMMENT" // ### at column 1 to a line starting with ###
+
 	// Literals
 	INTEGER   TokenType = "INTEGER"
 	DECIMAL   TokenType = "DECIMAL"
@@ -17,7 +22,17 @@ const (
 	DOCSTRING TokenType = "DOCSTRING"
 	RAWSTRING TokenType = "RAWSTRING"
 	RAWDOC    TokenType = "RAWDOC"
-	BOOLEAN   TokenType = "BOOLEAN"
+
+	// Quantities: an integer or decimal with a unit suffix (see Units).
+	DURATION TokenType = "DURATION" // 5s, 250ms, 1.5h
+	SIZE     TokenType = "SIZE"     // 10KB, 3MiB
+
+	// Interpolated strings: "a${x}b${y}c" lexes as INTERP_START "a", the
+	// tokens of x, INTERP_MID "b", the tokens of y, INTERP_END "c".
+	INTERP_START TokenType = "INTERP_START" // "text${
+	INTERP_MID   TokenType = "INTERP_MID"   // }text${
+	INTERP_END   TokenType = "INTERP_END"   // }text"
+	BOOLEAN      TokenType = "BOOLEAN"
 
 	// Identifiers and keywords
 	IDENTIFIER TokenType = "IDENTIFIER"
@@ -50,6 +65,13 @@ type Token struct {
 	Literal string
 	Line    int // 1-indexed
 	Column  int // 1-indexed
+	Offset  int // byte offset of the token's first byte in the source
+	End     int // byte offset just past the token's last byte
+
+	// EndLine and EndColumn are the position just past the token's last
+	// byte; they differ from Line when the token spans lines.
+	EndLine   int
+	EndColumn int
 }
 
 // keywords maps reserved words to their token type.
@@ -61,6 +83,17 @@ var keywords = map[string]TokenType{
 	"right": KEYWORD,
 }
 
+// Units maps the unit suffixes of quantity literals to the literal's
+// type. Size units up to TB are powers of 1000, the binary ones (KiB to
+// TiB) powers of 1024.
+var Units = map[string]TokenType{
+	"ns": DURATION, "us": DURATION, "ms": DURATION,
+	"s": DURATION, "m": DURATION, "h": DURATION,
+
+	"B": SIZE, "KB": SIZE, "MB": SIZE, "GB": SIZE, "TB": SIZE,
+	"KiB": SIZE, "MiB": SIZE, "GiB": SIZE, "TiB": SIZE,
+}
+
 // LookupIdent checks if an identifier is a keyword or boolean literal.
 // Returns the appropriate TokenType.
 func LookupIdent(ident string) TokenType {
@@ -69,3 +102,9 @@ func LookupIdent(ident string) TokenType {
 	}
 	return IDENTIFIER
 }
+
+// IsDefinition reports whether the identifier at i of tokens is being
+// bound: followed by ":" or "@:".
+func IsDefinition(tokens []Token, i int) bool {
+	return i+1 < len(tokens) && (tokens[i+1].Type == COLON || tokens[i+1].Type == AT_COLON)
+}
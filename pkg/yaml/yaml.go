@@ -0,0 +1,196 @@
+// Package yaml parses YAML 1.2 documents for the yaml_parse builtin.
+//
+// The syntax is read by go.yaml.in/yaml/v3; this package turns its node
+// tree into plain values, resolving scalars with the YAML 1.2 core schema
+// and keeping the keys of mappings in document order.
+//
+// Not supported, and reported as errors: anchors and aliases, tags,
+// multiple documents, duplicate keys, collections as keys, and
+// .inf/.nan, which have no exact value.
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// Parse returns the value of the document in src:
+//
+//	mapping          *Mapping, keys in document order
+//	sequence         []any
+//	null             nil
+//	true, false      bool
+//	integer          *big.Int
+//	float            Float
+//	anything else    string
+func Parse(src []byte) (any, error) {
+	text := strings.TrimPrefix(string(src), "\ufeff")
+	if !utf8.ValidString(text) {
+		return nil, &Error{Line: 1, Msg: "input is not valid UTF-8"}
+	}
+	dec := yaml.NewDecoder(strings.NewReader(text))
+	var doc yaml.Node
+	if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+		return nil, nil
+	} else if err != nil {
+		return nil, syntaxError(err)
+	}
+	var next yaml.Node
+	if err := dec.Decode(&next); err == nil {
+		return nil, &Error{Line: next.Line, Msg: "multiple documents are not supported"}
+	} else if !errors.Is(err, io.EOF) {
+		return nil, syntaxError(err)
+	}
+	return value(&doc)
+}
+
+// Mapping is a YAML mapping. Keys are scalars.
+type Mapping struct {
+	Keys   []any
+	Values []any
+}
+
+// Float is a float scalar, as written (e.g. "1.5e3"), so that it can be
+// converted exactly.
+type Float string
+
+// Error is a parse error at a 1-based line, or 0 when the line is not
+// known.
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+var syntaxRe = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// syntaxError returns the error of the YAML reader as an Error.
+func syntaxError(err error) *Error {
+	msg := err.Error()
+	if m := syntaxRe.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return &Error{Line: line, Msg: m[2]}
+	}
+	return &Error{Msg: strings.TrimPrefix(msg, "yaml: ")}
+}
+
+func fail(n *yaml.Node, format string, args ...any) error {
+	return &Error{Line: n.Line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// value returns the value of the node n.
+func value(n *yaml.Node) (any, error) {
+	switch {
+	case n.Kind == yaml.AliasNode || n.Anchor != "":
+		return nil, fail(n, "anchors and aliases are not supported")
+	case n.Style&yaml.TaggedStyle != 0:
+		return nil, fail(n, "tags are not supported")
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return value(n.Content[0])
+	case yaml.SequenceNode:
+		items := []any{}
+		for _, c := range n.Content {
+			v, err := value(c)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case yaml.MappingNode:
+		m, err := mapping(n)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	if n.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return n.Value, nil
+	}
+	return plain(n)
+}
+
+// mapping returns the Mapping of the node n, whose content alternates
+// keys and values.
+func mapping(n *yaml.Node) (*Mapping, error) {
+	m := &Mapping{}
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k := n.Content[i]
+		if k.Kind != yaml.ScalarNode {
+			return nil, fail(k, "collection keys are not supported")
+		}
+		key, err := value(k)
+		if err != nil {
+			return nil, err
+		}
+		id := fmt.Sprintf("%T %v", key, key)
+		if seen[id] {
+			return nil, fail(k, "duplicate key %s", k.Value)
+		}
+		seen[id] = true
+		v, err := value(n.Content[i+1])
+		if err != nil {
+			return nil, err
+		}
+		m.Keys = append(m.Keys, key)
+		m.Values = append(m.Values, v)
+	}
+	return m, nil
+}
+
+var (
+	intRe   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	floatRe = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+	infRe   = regexp.MustCompile(`^([-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+)
+
+// plain resolves the plain scalar n with the core schema.
+func plain(n *yaml.Node) (any, error) {
+	s := n.Value
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	switch {
+	case intRe.MatchString(s):
+		i, _ := new(big.Int).SetString(strings.TrimPrefix(s, "+"), 10)
+		return i, nil
+	case strings.HasPrefix(s, "0x") && len(s) > 2, strings.HasPrefix(s, "0o") && len(s) > 2:
+		base := 16
+		if s[1] == 'o' {
+			base = 8
+		}
+		if i, ok := new(big.Int).SetString(s[2:], base); ok {
+			return i, nil
+		}
+	case floatRe.MatchString(s):
+		return Float(s), nil
+	case infRe.MatchString(s):
+		return nil, fail(n, "%s has no exact value", s)
+	}
+	return s, nil
+}
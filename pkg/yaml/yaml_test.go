@@ -0,0 +1,130 @@
+package yaml
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// show prints a parsed value compactly: {k: v} mappings, [a b] sequences,
+// quoted strings.
+func show(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case *Mapping:
+		parts := make([]string, len(v.Keys))
+		for i, k := range v.Keys {
+			parts[i] = show(k) + ": " + show(v.Values[i])
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case []any:
+		parts := make([]string, len(v))
+		for i, x := range v {
+			parts[i] = show(x)
+		}
+		return "[" + strings.Join(parts, " ") + "]"
+	case string:
+		return fmt.Sprintf("%q", v)
+	case Float:
+		return "float:" + string(v)
+	case *big.Int:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"", "null"},
+		{"42", "42"},
+		{"# only a comment\n", "null"},
+		{"a: 1\nb: two\nc:\n", `{"a": 1, "b": "two", "c": null}`},
+		{"--- # doc\nname: org # trailing\n...\n", `{"name": "org"}`},
+		{"server:\n  host: localhost\n  port: 8080\ndebug: true\n",
+			`{"server": {"host": "localhost", "port": 8080}, "debug": true}`},
+		{"- a\n- b\n-\n  - c\n", `["a" "b" ["c"]]`},
+		{"items:\n- x\n- y\nn: 1\n", `{"items": ["x" "y"], "n": 1}`},
+		{"users:\n  - name: ann\n    admin: yes\n  - name: bo\n", `{"users": [{"name": "ann", "admin": "yes"} {"name": "bo"}]}`},
+		{"- - 1\n  - 2\n- 3\n", `[[1 2] 3]`},
+		{"ports: [80, 443]\nenv: {A: 1, B: [x, 'y z'], C}\n",
+			`{"ports": [80 443], "env": {"A": 1, "B": ["x" "y z"], "C": null}}`},
+		{"list: [\n  1,\n  2,\n]\n", `{"list": [1 2]}`},
+		{"values: [~, null, True, FALSE, -7, +3, 0x1F, 0o17, 1.50, -2.5e3, .5]\n",
+			`{"values": [null null true false -7 3 31 15 float:1.50 float:-2.5e3 float:.5]}`},
+		{"big: 123456789012345678901234567890\n", `{"big": 123456789012345678901234567890}`},
+		{`s: "tab\tnew\nline \"q\" \u00e9 \x41"` + "\n", `{"s": "tab\tnew\nline \"q\" é A"}`},
+		{"s: 'it''s # not a comment'\nt: it's\n", `{"s": "it's # not a comment", "t": "it's"}`},
+		{"url: http://example.com:8080/x\n", `{"url": "http://example.com:8080/x"}`},
+		{"\"quoted key\": 1\n'k2': 2\n3: three\n", `{"quoted key": 1, "k2": 2, 3: "three"}`},
+		{"s: \"one\n  two\"\n", `{"s": "one two"}`},
+		{"lit: |\n  a\n   b\n\n  c\nnext: 1\n", `{"lit": "a\n b\n\nc\n", "next": 1}`},
+		{"fold: >\n  a\n  b\n\n  c\n", `{"fold": "a b\nc\n"}`},
+		{"strip: |-\n  a\n\nkeep: |+\n  a\n\n", `{"strip": "a", "keep": "a\n\n"}`},
+		{"- |\n  text\n- >-\n  x\n  y\n", `["text\n" "x y"]`},
+		{"a: 1\r\nb: 2\r\n", `{"a": 1, "b": 2}`},
+		{"? a\n: b\n", `{"a": "b"}`},
+		{"a: one\n  two\n", `{"a": "one two"}`},
+		{"a: [b: 1]\n", `{"a": [{"b": 1}]}`},
+		{"1: a\n\"1\": b\n", `{1: "a", "1": "b"}`},
+	}
+	for _, tt := range tests {
+		v, err := Parse([]byte(tt.src))
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		if got := show(v); got != tt.want {
+			t.Errorf("Parse(%q)\n got %s\nwant %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"a: &x 1\nb: *x\n", "line 1: anchors and aliases"},
+		{"a: !!str 1\n", "tags are not supported"},
+		{"? [a]\n: b\n", "collection keys are not supported"},
+		{"%YAML 1.2\n---\na: 1\n", "incompatible YAML document"},
+		{"a: 1\n---\nb: 2\n", "line 2: multiple documents"},
+		{"a: 1\na: 2\n", "line 2: duplicate key a"},
+		{"a:\n\tb: 1\n", "line 2: found character that cannot start any token"},
+		{"a: 1\n  b: 2\n", "line 2: mapping values are not allowed"},
+		{"a: b: c\n", "mapping values are not allowed"},
+		{"a: [1, 2\n", "line 1: did not find expected ',' or ']'"},
+		{"a: \"open\n", "unexpected end of stream"},
+		{"{0', '}", "unexpected end of stream"},
+		{"a: .inf\n", "no exact value"},
+		{`a: "\q"` + "\n", "unknown escape character"},
+		{"a: 1\n- b\n", "line 1: did not find expected key"},
+		{"a: |x\n  t\n", "did not find expected comment or line break"},
+		{"\xff: 1\n", "not valid UTF-8"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) = %v, want error containing %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+// FuzzParse checks that Parse returns a value or an *Error for any input.
+func FuzzParse(f *testing.F) {
+	for _, src := range []string{
+		"a: 1\nb: [x, {y: 2}]\n", "- |\n  text\n", "{0', '}", "? [a]\n: b\n", "a: &x 1\nb: *x\n",
+	} {
+		f.Add([]byte(src))
+	}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		if _, err := Parse(src); err != nil {
+			if _, ok := err.(*Error); !ok {
+				t.Errorf("Parse(%q) = %T %v, want an *Error", src, err, err)
+			}
+		}
+	})
+}
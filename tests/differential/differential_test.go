@@ -0,0 +1,156 @@
+// Package differential runs the programs of testdata through both
+// backends, the interpreter (org run) and the binary org build makes of
+// them, and compares their standard output and exit status, so semantic
+// drift between the two fails the tests.
+//
+// Each program has a main. What the interpreter does with it is recorded
+// next to it: the output in <name>.out and, when it is not 0, the exit
+// status in an "# exit: N" comment on the program's first line. go test
+// -update rewrites the .out files from the interpreter.
+//
+// Without a C compiler only the interpreter is checked, and the
+// comparison is skipped saying so.
+package differential
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"orglang/pkg/codegen"
+)
+
+var update = flag.Bool("update", false, "rewrite the .out files from the interpreter")
+
+// org is the org binary the programs are run and built with.
+var org string
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	dir, err := os.MkdirTemp("", "differential")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	org = filepath.Join(dir, "org")
+	if out, err := exec.Command("go", "build", "-o", org, "orglang/cmd/org").CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "building org: %v\n%s", err, out)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// result is what a run of a program did.
+type result struct {
+	stdout []byte
+	status int
+}
+
+// run runs the command name with args, and returns its output and exit
+// status.
+func run(t *testing.T, name string, args ...string) result {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if err != nil && !errors.As(err, &exit) {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("%s: no exit after 30s", name)
+	}
+	return result{stdout.Bytes(), cmd.ProcessState.ExitCode()}
+}
+
+// diff describes how got differs from want, or returns "" if it does not.
+func diff(got, want result) string {
+	if got.status != want.status {
+		return fmt.Sprintf("exit status %d, want %d", got.status, want.status)
+	}
+	g := strings.Split(string(got.stdout), "\n")
+	w := strings.Split(string(want.stdout), "\n")
+	for i := 0; i < len(g) || i < len(w); i++ {
+		switch {
+		case i >= len(g):
+			return fmt.Sprintf("output line %d missing, want %q", i+1, w[i])
+		case i >= len(w):
+			return fmt.Sprintf("output line %d is %q, want none", i+1, g[i])
+		case g[i] != w[i]:
+			return fmt.Sprintf("output line %d is %q, want %q", i+1, g[i], w[i])
+		}
+	}
+	return ""
+}
+
+// expected returns the recorded result of the program file.
+func expected(t *testing.T, file string) result {
+	t.Helper()
+	src, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := result{}
+	first, _, _ := strings.Cut(string(src), "\n")
+	if s, ok := strings.CutPrefix(first, "# exit:"); ok {
+		if want.status, err = strconv.Atoi(strings.TrimSpace(s)); err != nil {
+			t.Fatalf("%s: bad exit comment %q", file, first)
+		}
+	}
+	if want.stdout, err = os.ReadFile(strings.TrimSuffix(file, ".org") + ".out"); err != nil && !*update {
+		t.Fatal(err)
+	}
+	return want
+}
+
+func TestPrograms(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.org"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no programs: %v", err)
+	}
+	_, noCC := codegen.FindCompiler("", nil)
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".org"), func(t *testing.T) {
+			want := expected(t, file)
+			interp := run(t, org, "run", file)
+			if *update {
+				if err := os.WriteFile(strings.TrimSuffix(file, ".org")+".out", interp.stdout, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				want.stdout = interp.stdout
+			}
+			if d := diff(interp, want); d != "" {
+				t.Errorf("interpreter: %s", d)
+			}
+
+			if noCC != nil {
+				t.Skipf("no C backend to compare with: %v", noCC)
+			}
+			bin := filepath.Join(t.TempDir(), "prog")
+			if out, err := exec.Command(org, "build", "-o", bin, file).CombinedOutput(); err != nil {
+				if bytes.Contains(out, []byte("gmp.h")) {
+					t.Skip("no GMP to link the C backend with")
+				}
+				t.Fatalf("org build: %v\n%s", err, out)
+			}
+			if d := diff(run(t, bin), interp); d != "" {
+				t.Errorf("C backend differs from the interpreter: %s", d)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Package library builds testdata/mathlib.org with org build --library
+// and uses what it writes as a C program and a Python program would: a
+// static and a shared library linked into testdata/app.c, which calls the
+// exports through the generated header, and the CPython extension module
+// imported by python3.
+//
+// Without a C compiler, GMP, or for the extension module python3-config,
+// the tests are skipped saying so.
+package library
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"orglang/pkg/codegen"
+)
+
+// org is the org binary the library is built with.
+var org string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "library")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	org = filepath.Join(dir, "org")
+	if out, err := exec.Command("go", "build", "-o", org, "orglang/cmd/org").CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "building org: %v\n%s", err, out)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// want is what app.c prints, and what the Python calls give. The last
+// line is add called without its left operand: the runtime's + makes an
+// Error of the Error org_operand gives it.
+const want = "144\n42\n5\nError\n"
+
+// build copies mathlib.org and app.c to a new directory and builds the
+// library there with the arguments of org build, returning the directory.
+func build(t *testing.T, args ...string) (string, *codegen.Compiler) {
+	t.Helper()
+	cc, err := codegen.FindCompiler("", nil)
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"mathlib.org", "app.c"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(org, append(append([]string{"build"}, args...), "mathlib.org")...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if bytes.Contains(out, []byte("gmp.h")) {
+			t.Skip("no GMP to build the library with")
+		}
+		t.Fatalf("org build: %v\n%s", err, out)
+	}
+	return dir, cc
+}
+
+// runApp links app.c against the library in dir and runs it.
+func runApp(t *testing.T, dir string, cc *codegen.Compiler, ldflags ...string) {
+	t.Helper()
+	app := filepath.Join(dir, "app")
+	args := append(cc.Command[1:], "-o", app, filepath.Join(dir, "app.c"), "-L", dir, "-lmathlib", "-lgmp")
+	link := exec.Command(cc.Command[0], append(args, ldflags...)...)
+	if out, err := link.CombinedOutput(); err != nil {
+		t.Fatalf("linking app.c: %v\n%s", err, out)
+	}
+	out, err := exec.Command(app).Output()
+	if err != nil {
+		t.Fatalf("app: %v", err)
+	}
+	if string(out) != want {
+		t.Errorf("app printed %q, want %q", out, want)
+	}
+}
+
+func TestStatic(t *testing.T) {
+	dir, cc := build(t, "--library")
+	for _, name := range []string{"libmathlib.a", "mathlib.h", filepath.Join("orglang", "core", "values.h")} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+	runApp(t, dir, cc)
+}
+
+func TestShared(t *testing.T) {
+	dir, cc := build(t, "--library=shared")
+	if _, err := os.Stat(filepath.Join(dir, "libmathlib.a")); err == nil {
+		t.Error("--library=shared wrote libmathlib.a")
+	}
+	runApp(t, dir, cc, "-Wl,-rpath,"+dir)
+}
+
+func TestPython(t *testing.T) {
+	if _, err := exec.LookPath("python3-config"); err != nil {
+		t.Skip("no python3-config to build the extension module with")
+	}
+	dir, _ := build(t, "--library", "--python")
+	script := `import mathlib
+print(mathlib.sq(right=12))
+print(mathlib.answer())
+print(mathlib.add(2, 3))
+try:
+    mathlib.add(right=3)
+except mathlib.Error as e:
+    print(e)
+`
+	cmd := exec.Command("python3", "-c", script)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("python3: %v\n%s", err, out)
+	}
+	if string(out) != want {
+		t.Errorf("python3 printed %q, want %q", out, want)
+	}
+}
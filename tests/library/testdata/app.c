@@ -0,0 +1,20 @@
+/* Links against the library of mathlib.org and calls its exports. */
+#include "mathlib.h"
+#include "orglang/core/print.h"
+
+static void line(OrgValue v) {
+  org_write_value(1, v);
+  org_write_bytes(1, "\n", 1);
+}
+
+int main(void) {
+  if (mathlib_init() != 0)
+    return 1;
+  line(mathlib_sq(ORG_UNUSED, ORG_TAG_SMALL_INT(12)));
+  line(mathlib_answer());
+  line(ml_add(ORG_TAG_SMALL_INT(2), ORG_TAG_SMALL_INT(3)));
+  /* add without its left operand: an Error */
+  line(ml_add(ORG_UNUSED, ORG_TAG_SMALL_INT(3)));
+  mathlib_shutdown();
+  return 0;
+}
@@ -0,0 +1,302 @@
+//go:build linux
+
+// Package pty runs the interactive programs of testdata under a real
+// pseudo-terminal, as a user at a terminal would: the org binary is
+// started with the terminal's slave as its controlling terminal and as
+// its stdin, stdout and stderr, so @tty, prompts, raw key presses, the
+// terminal's size and color detection are those of the running binary,
+// not of a stand-in. What the terminal shows is compared with
+// <name>.golden, after eval.NormalizeTerminal has made its escape
+// sequences readable; go test -update rewrites the golden files.
+//
+// What is typed comes from <name>.input, one line per read. A line is
+// typed once the program has written something since the last one and
+// then stopped writing, so the prompt is on the terminal before the
+// answer is echoed after it. The terminal's mode tells what to type: a
+// line and enter in canonical mode, as for prompt and password, and in
+// raw mode, as for read_key, the key the line names (a character, or a
+// name such as enter or up; an empty line is enter). When the input has
+// run out, ^D is typed. A program's exit status, when it is not 0, is
+// given by an "# exit: N" comment on its first line.
+package pty
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"orglang/pkg/eval"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files from the terminal")
+
+// org is the org binary the programs are run with.
+var org string
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	dir, err := os.MkdirTemp("", "pty")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	org = filepath.Join(dir, "org")
+	if out, err := exec.Command("go", "build", "-o", org, "orglang/cmd/org").CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "building org: %v\n%s", err, out)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+const (
+	// settle is how long the program must have stopped writing before
+	// the next input is typed.
+	settle = 100 * time.Millisecond
+	// timeout bounds a program's run.
+	timeout = 30 * time.Second
+)
+
+// openPTY returns the master and the slave of a new pseudo-terminal of
+// 80 columns by 24 rows.
+func openPTY() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("unlock pty: %w", err)
+	}
+	n, err := unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("pty number: %w", err)
+	}
+	slave, err = os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	if err := unix.IoctlSetWinsize(int(slave.Fd()), unix.TIOCSWINSZ, &unix.Winsize{Row: 24, Col: 80}); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, nil, fmt.Errorf("set pty size: %w", err)
+	}
+	return master, slave, nil
+}
+
+// screen collects what the program writes to the terminal.
+type screen struct {
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	last time.Time // of the last write
+	done chan struct{}
+}
+
+// read copies the master into s until the terminal is closed; reading it
+// fails with EIO once no process has the slave open.
+func (s *screen) read(master *os.File) {
+	defer close(s.done)
+	p := make([]byte, 4096)
+	for {
+		n, err := master.Read(p)
+		if n > 0 {
+			s.mu.Lock()
+			s.buf.Write(p[:n])
+			s.last = time.Now()
+			s.mu.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// state returns how much was written, and when last.
+func (s *screen) state() (int, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Len(), s.last
+}
+
+// keySequences are what the keys named in an input file send.
+var keySequences = map[string]string{
+	"": "\r", "enter": "\r", "tab": "\t", "backspace": "\x7f", "escape": "\x1b",
+	"up": "\x1b[A", "down": "\x1b[B", "right": "\x1b[C", "left": "\x1b[D",
+	"home": "\x1b[H", "end": "\x1b[F", "insert": "\x1b[2~", "delete": "\x1b[3~",
+	"page_up": "\x1b[5~", "page_down": "\x1b[6~",
+}
+
+// keySequence returns what typing the key name sends.
+func keySequence(name string) string {
+	if seq, ok := keySequences[name]; ok {
+		return seq
+	}
+	if c, ok := strings.CutPrefix(name, "ctrl+"); ok && len(c) == 1 && c[0] >= 'a' && c[0] <= 'z' {
+		return string(rune(c[0] - 'a' + 1))
+	}
+	return name
+}
+
+// result is what a run of a program did.
+type result struct {
+	screen []byte // normalized
+	status int
+}
+
+// run runs file with org run under a pseudo-terminal, typing input.
+func run(t *testing.T, file string, input []string) result {
+	t.Helper()
+	master, slave, err := openPTY()
+	if err != nil {
+		t.Skipf("no pseudo-terminal: %v", err)
+	}
+	defer master.Close()
+
+	cmd := exec.Command(org, "run", file)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color", "NO_COLOR=", "CLICOLOR_FORCE=")
+	if err := cmd.Start(); err != nil {
+		slave.Close()
+		t.Fatal(err)
+	}
+	slave.Close()
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	s := &screen{done: make(chan struct{})}
+	go s.read(master)
+
+	deadline := time.After(timeout)
+	seen, eof := 0, false
+	var waitErr error
+loop:
+	for {
+		select {
+		case waitErr = <-exited:
+			break loop
+		case <-deadline:
+			cmd.Process.Kill()
+			<-exited
+			<-s.done
+			t.Fatalf("no exit after %v; the terminal shows:\n%s", timeout, eval.NormalizeTerminal(s.buf.Bytes()))
+		case <-time.After(settle / 4):
+		}
+		n, last := s.state()
+		if n == seen || time.Since(last) < settle || eof {
+			continue
+		}
+		seen = n
+		if len(input) == 0 {
+			// ^D ends the input of a canonical read.
+			master.WriteString("\x04")
+			eof = true
+			continue
+		}
+		line := input[0]
+		input = input[1:]
+		tio, err := unix.IoctlGetTermios(int(master.Fd()), unix.TCGETS)
+		if err != nil {
+			t.Fatalf("terminal mode: %v", err)
+		}
+		if tio.Lflag&unix.ICANON != 0 {
+			master.WriteString(line + "\r")
+		} else {
+			master.WriteString(keySequence(line))
+		}
+	}
+	<-s.done
+	var exit *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exit) {
+		t.Fatal(waitErr)
+	}
+	return result{eval.NormalizeTerminal(s.buf.Bytes()), cmd.ProcessState.ExitCode()}
+}
+
+// readInput returns the lines of the input file of program file, if any.
+func readInput(t *testing.T, file string) []string {
+	data, err := os.ReadFile(strings.TrimSuffix(file, ".org") + ".input")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// wantStatus returns the exit status given by the first line of file.
+func wantStatus(t *testing.T, file string) int {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _, _ := strings.Cut(string(src), "\n")
+	s, ok := strings.CutPrefix(first, "# exit:")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		t.Fatalf("%s: bad exit comment %q", file, first)
+	}
+	return n
+}
+
+func TestPrograms(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.org"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no programs: %v", err)
+	}
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".org"), func(t *testing.T) {
+			t.Parallel()
+			got := run(t, file, readInput(t, file))
+			if want := wantStatus(t, file); got.status != want {
+				t.Errorf("exit status %d, want %d; the terminal shows:\n%s", got.status, want, got.screen)
+			}
+			golden := strings.TrimSuffix(file, ".org") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got.screen, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.screen, want) {
+				t.Errorf("the terminal shows:\n%s\nwant:\n%s", got.screen, want)
+			}
+		})
+	}
+}
+
+// TestKeySequence checks what the keys named in input files send, the
+// sequences keyName in pkg/eval reads back as those names.
+func TestKeySequence(t *testing.T) {
+	for name, want := range map[string]string{
+		"a": "a", "": "\r", "down": "\x1b[B", "ctrl+c": "\x03", "page_down": "\x1b[6~", "é": "é",
+	} {
+		if got := keySequence(name); got != want {
+			t.Errorf("keySequence(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+/*
+ * test_pyconv.c — Unit tests for the OrgLang <-> CPython value conversion.
+ *
+ * Needs the CPython headers and library, so it lives outside the
+ * tests/runtime/test_*.c glob of `just test-c`; run it with
+ * `just test-c-python`, or:
+ *   clang -DORG_WITH_PYTHON -Wall -Wextra -g $(python3-config --includes) \
+ *       -Ipkg/runtime -o test_pyconv tests/runtime/python/test_pyconv.c \
+ *       $(find pkg/runtime -name '*.c') -lgmp \
+ *       $(python3-config --ldflags --embed)
+ */
+#include "../../../pkg/runtime/python/pyconv.h"
+#include "../../../pkg/runtime/table/table.h"
+#include <stdio.h>
+#include <string.h>
+
+static int tests_run = 0;
+static int tests_passed = 0;
+
+#define TEST(name)                                                             \
+  do {                                                                         \
+    tests_run++;                                                               \
+    printf("  %-50s", name);                                                   \
+  } while (0)
+
+#define PASS()                                                                 \
+  do {                                                                         \
+    tests_passed++;                                                            \
+    printf("✅\n");                                                            \
+  } while (0)
+
+#define ASSERT(cond)                                                           \
+  do {                                                                         \
+    if (!(cond)) {                                                             \
+      printf("❌ FAIL: %s (line %d)\n", #cond, __LINE__);                      \
+      PyErr_Clear();                                                           \
+      return;                                                                  \
+    }                                                                          \
+  } while (0)
+
+/* True if repr(obj) is want; consumes obj. */
+static int repr_is(PyObject *obj, const char *want) {
+  if (!obj)
+    return 0;
+  PyObject *r = PyObject_Repr(obj);
+  Py_DECREF(obj);
+  if (!r)
+    return 0;
+  int ok = strcmp(PyUnicode_AsUTF8(r), want) == 0;
+  Py_DECREF(r);
+  return ok;
+}
+
+/* Evaluate a Python expression. */
+static PyObject *py(const char *expr) {
+  static PyObject *globals = NULL;
+  if (!globals) {
+    globals = PyDict_New();
+    PyRun_String("from fractions import Fraction\nfrom decimal import Decimal",
+                 Py_file_input, globals, globals);
+  }
+  return PyRun_String(expr, Py_eval_input, globals, globals);
+}
+
+static void test_to_py_scalars(void) {
+  TEST("to py: integers, booleans, strings, unused");
+  Arena *a = arena_new(4096);
+  ASSERT(repr_is(org_to_py(ORG_TAG_SMALL_INT(-42)), "-42"));
+  ASSERT(repr_is(org_to_py(org_make_bigint_str(a, "123456789012345678901234")),
+                 "123456789012345678901234"));
+  ASSERT(repr_is(org_to_py(ORG_TRUE), "True"));
+  ASSERT(repr_is(org_to_py(ORG_FALSE), "False"));
+  ASSERT(repr_is(org_to_py(ORG_UNUSED), "None"));
+  ASSERT(repr_is(org_to_py(org_make_string(a, "h\xc3\xa9", 3)), "'h\xc3\xa9'"));
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_to_py_numbers(void) {
+  TEST("to py: Rational is Fraction, Decimal keeps scale");
+  Arena *a = arena_new(4096);
+  ASSERT(repr_is(org_to_py(org_make_rational_str(a, "2", "6")),
+                 "Fraction(1, 3)"));
+  ASSERT(repr_is(org_to_py(org_make_decimal_str(a, "3.140")),
+                 "Decimal('3.140')"));
+  ASSERT(repr_is(org_to_py(org_make_decimal_str(a, "-0.5")),
+                 "Decimal('-0.5')"));
+  ASSERT(repr_is(org_to_py(org_make_decimal_str(a, "7")), "Decimal('7')"));
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_to_py_tables(void) {
+  TEST("to py: positional table is list, keyed is dict");
+  Arena *a = arena_new(4096);
+  OrgValue list = org_table_new(a);
+  org_table_push(a, list, ORG_TAG_SMALL_INT(1));
+  org_table_push(a, list, org_make_string(a, "x", 1));
+  ASSERT(repr_is(org_to_py(list), "[1, 'x']"));
+
+  OrgValue dict = org_table_new(a);
+  org_table_set(a, dict, org_make_string(a, "name", 4),
+                org_make_string(a, "Ann", 3));
+  PyObject *d = org_to_py(dict);
+  ASSERT(d && PyDict_Check(d));
+  ASSERT(repr_is(Py_XNewRef(PyDict_GetItemString(d, "name")), "'Ann'"));
+  Py_DECREF(d);
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_to_py_error(void) {
+  TEST("to py: Error raises org.Error with its message");
+  Arena *a = arena_new(4096);
+  ASSERT(org_to_py(org_make_error(a, "boom")) == NULL);
+  ASSERT(PyErr_ExceptionMatches(OrgError));
+  PyObject *type, *value, *tb;
+  PyErr_Fetch(&type, &value, &tb);
+  ASSERT(repr_is(PyObject_Str(value), "'boom'"));
+  Py_XDECREF(type);
+  Py_XDECREF(value);
+  Py_XDECREF(tb);
+  ASSERT(org_to_py(ORG_ERROR) == NULL && PyErr_ExceptionMatches(OrgError));
+  PyErr_Clear();
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_from_py_scalars(void) {
+  TEST("from py: None, bool, int, big int, str");
+  Arena *a = arena_new(4096);
+  OrgValue v;
+  ASSERT(org_from_py(a, Py_None, &v) == 0 && ORG_IS_UNUSED(v));
+  ASSERT(org_from_py(a, Py_True, &v) == 0 && ORG_IS_TRUE(v));
+  PyObject *o = py("-7");
+  ASSERT(org_from_py(a, o, &v) == 0 && v == ORG_TAG_SMALL_INT(-7));
+  Py_DECREF(o);
+  o = py("2**100");
+  ASSERT(org_from_py(a, o, &v) == 0 && ORG_IS_PTR(v) &&
+         org_get_type(v) == ORG_TYPE_BIGINT);
+  ASSERT(mpz_cmp_ui(*org_get_bigint(v), 0) > 0 &&
+         mpz_sizeinbase(*org_get_bigint(v), 2) == 101);
+  Py_DECREF(o);
+  o = py("'caf\\u00e9'");
+  ASSERT(org_from_py(a, o, &v) == 0 && org_string_byte_len(v) == 5 &&
+         org_string_codepoint_len(v) == 4);
+  Py_DECREF(o);
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_from_py_numbers(void) {
+  TEST("from py: Fraction, Decimal and float");
+  Arena *a = arena_new(4096);
+  OrgValue v;
+  PyObject *o = py("Fraction(3, 6)");
+  ASSERT(org_from_py(a, o, &v) == 0 && org_is_rational(v));
+  ASSERT(mpq_cmp_si(*org_get_rational(v), 1, 2) == 0);
+  Py_DECREF(o);
+  o = py("Decimal('1E+3')");
+  ASSERT(org_from_py(a, o, &v) == 0 && org_is_decimal(v));
+  ASSERT(mpq_cmp_si(*org_get_decimal(v), 1000, 1) == 0);
+  Py_DECREF(o);
+  o = py("0.1");
+  ASSERT(org_from_py(a, o, &v) == 0 && org_is_decimal(v));
+  ASSERT(mpq_cmp_si(*org_get_decimal(v), 1, 10) == 0);
+  ASSERT(org_get_decimal_scale(v) == 1);
+  Py_DECREF(o);
+  o = py("float('nan')");
+  ASSERT(org_from_py(a, o, &v) == -1 && PyErr_ExceptionMatches(PyExc_ValueError));
+  PyErr_Clear();
+  Py_DECREF(o);
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_from_py_containers(void) {
+  TEST("from py: list, tuple and dict become tables");
+  Arena *a = arena_new(4096);
+  OrgValue v;
+  PyObject *o = py("[1, (2, 3), {'k': 'v', 0: None}]");
+  ASSERT(org_from_py(a, o, &v) == 0 && org_table_count(v) == 3);
+  OrgValue inner = org_table_get(v, ORG_TAG_SMALL_INT(1));
+  ASSERT(org_table_count(inner) == 2);
+  ASSERT(org_table_get(inner, ORG_TAG_SMALL_INT(1)) == ORG_TAG_SMALL_INT(3));
+  OrgValue map = org_table_get(v, ORG_TAG_SMALL_INT(2));
+  OrgValue k = org_table_get_cstr(map, "k");
+  ASSERT(org_string_byte_len(k) == 1 && org_string_data(k)[0] == 'v');
+  Py_DECREF(o);
+
+  /* And back. */
+  o = py("[1, [2, 3]]");
+  ASSERT(org_from_py(a, o, &v) == 0);
+  Py_DECREF(o);
+  ASSERT(repr_is(org_to_py(v), "[1, [2, 3]]"));
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_from_py_rejects(void) {
+  TEST("from py: unsupported objects raise TypeError");
+  Arena *a = arena_new(4096);
+  OrgValue v;
+  PyObject *o = py("object()");
+  ASSERT(org_from_py(a, o, &v) == -1 && PyErr_ExceptionMatches(PyExc_TypeError));
+  PyErr_Clear();
+  Py_DECREF(o);
+  o = py("{1.5: 1}");
+  ASSERT(org_from_py(a, o, &v) == -1 && PyErr_ExceptionMatches(PyExc_TypeError));
+  PyErr_Clear();
+  Py_DECREF(o);
+  arena_destroy(a);
+  PASS();
+}
+
+int main(void) {
+  printf("=== Python Conversion Tests ===\n");
+  Py_Initialize();
+  if (org_py_init(NULL) < 0) {
+    PyErr_Print();
+    return 1;
+  }
+
+  test_to_py_scalars();
+  test_to_py_numbers();
+  test_to_py_tables();
+  test_to_py_error();
+  test_from_py_scalars();
+  test_from_py_numbers();
+  test_from_py_containers();
+  test_from_py_rejects();
+
+  printf("\n%d/%d tests passed\n", tests_passed, tests_run);
+  return tests_passed == tests_run ? 0 : 1;
+}
@@ -7,7 +7,9 @@
  */
 #include "../../pkg/runtime/core/arena.h"
 #include <assert.h>
+#include <stdint.h>
 #include <stdio.h>
+#include <stdlib.h>
 #include <string.h>
 
 static int tests_run = 0;
@@ -192,6 +194,49 @@ static void test_arena_many_small_allocs(void) {
   PASS();
 }
 
+static size_t oom_size = 0;
+
+static void record_oom(size_t size) { oom_size = size; }
+
+static void test_arena_oom_handler(void) {
+  TEST("arena_alloc calls the OOM handler");
+  Arena *a = arena_new(256);
+  arena_set_oom_handler(record_oom);
+  void *p = arena_alloc(a, SIZE_MAX - 16, 8);
+  arena_set_oom_handler(NULL);
+  ASSERT(p == NULL);
+  ASSERT(oom_size == SIZE_MAX - 16);
+  /* The arena stays usable */
+  ASSERT(arena_alloc(a, 16, 8) != NULL);
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_arena_page_size_from_env(void) {
+  TEST("arena_page_size_from_env parses ORG_ARENA_SIZE");
+  unsetenv("ORG_ARENA_SIZE");
+  ASSERT(arena_page_size_from_env(4096) == 4096);
+  setenv("ORG_ARENA_SIZE", "65536", 1);
+  ASSERT(arena_page_size_from_env(4096) == 65536);
+  setenv("ORG_ARENA_SIZE", "64K", 1);
+  ASSERT(arena_page_size_from_env(4096) == 64 * 1024);
+  setenv("ORG_ARENA_SIZE", "16M", 1);
+  ASSERT(arena_page_size_from_env(4096) == 16 * 1024 * 1024);
+  setenv("ORG_ARENA_SIZE", "lots", 1);
+  ASSERT(arena_page_size_from_env(4096) == 4096);
+  setenv("ORG_ARENA_SIZE", "0", 1);
+  ASSERT(arena_page_size_from_env(4096) == 4096);
+  /* Sizes overflowing size_t, before or after the suffix, are invalid. */
+  setenv("ORG_ARENA_SIZE", "99999999999999999999999", 1);
+  ASSERT(arena_page_size_from_env(4096) == 4096);
+  setenv("ORG_ARENA_SIZE", "18446744073709551615G", 1);
+  ASSERT(arena_page_size_from_env(4096) == 4096);
+  setenv("ORG_ARENA_SIZE", "17179869184G", 1);
+  ASSERT(arena_page_size_from_env(4096) == 4096);
+  unsetenv("ORG_ARENA_SIZE");
+  PASS();
+}
+
 int main(void) {
   printf("=== Arena Tests ===\n");
 
@@ -205,6 +250,8 @@ int main(void) {
   test_arena_save_restore();
   test_arena_save_restore_across_pages();
   test_arena_many_small_allocs();
+  test_arena_oom_handler();
+  test_arena_page_size_from_env();
 
   printf("\n%d/%d tests passed\n", tests_passed, tests_run);
   return tests_passed == tests_run ? 0 : 1;
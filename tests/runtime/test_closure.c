@@ -0,0 +1,423 @@
+/*
+ * test_closure.c — Unit tests for closures, scopes and org_run.
+ *
+ * Compile:
+ *   clang -Wall -Wextra -g -Ipkg/runtime -o test_closure \
+ *       tests/runtime/test_closure.c \
+ *       pkg/runtime/core/arena.c pkg/runtime/core/values.c \
+ *       pkg/runtime/core/print.c pkg/runtime/gmp/gmp_glue.c \
+ *       pkg/runtime/ops/ops.c pkg/runtime/table/table.c \
+ *       pkg/runtime/closure/closure.c pkg/runtime/resource/resource.c \
+ *       pkg/runtime/module/module.c -lgmp
+ */
+#include "../../pkg/runtime/liborg.h"
+#include <stdio.h>
+#include <string.h>
+
+static int tests_run = 0;
+static int tests_passed = 0;
+
+#define TEST(name)                                                             \
+  do {                                                                         \
+    tests_run++;                                                               \
+    printf("  %-55s", name);                                                   \
+  } while (0)
+
+#define PASS()                                                                 \
+  do {                                                                         \
+    tests_passed++;                                                            \
+    printf("✅\n");                                                            \
+  } while (0)
+
+#define ASSERT(cond)                                                           \
+  do {                                                                         \
+    if (!(cond)) {                                                             \
+      printf("❌ FAIL: %s (line %d)\n", #cond, __LINE__);                      \
+      return;                                                                  \
+    }                                                                          \
+  } while (0)
+
+static Arena *arena;
+
+static void setup(void) {
+  arena = arena_new(65536);
+  org_gmp_init();
+  org_gmp_set_arena(arena);
+}
+
+static void teardown(void) { arena_destroy(arena); }
+
+static OrgValue str(const char *s) {
+  return org_make_string(arena, s, strlen(s));
+}
+
+static int is_message(OrgValue e, const char *msg) {
+  return org_is_error(e) && !ORG_IS_ERROR(e) &&
+         strcmp(org_error_message(e), msg) == 0;
+}
+
+/* {left - right}, as PrintC emits it. */
+static OrgValue block_sub(Arena *a, OrgValue env, OrgValue self,
+                          OrgValue left, OrgValue right) {
+  (void)env;
+  (void)self;
+  return org_sub(a, left, right);
+}
+
+/* {right * 2}. */
+static OrgValue block_double(Arena *a, OrgValue env, OrgValue self,
+                             OrgValue left, OrgValue right) {
+  (void)env;
+  (void)self;
+  (void)left;
+  return org_mul(a, right, ORG_TAG_SMALL_INT(2));
+}
+
+/* {(right = 0) ? [true: 0 false: this (right - 1)]}, eagerly. */
+static OrgValue block_down(Arena *a, OrgValue env, OrgValue self,
+                           OrgValue left, OrgValue right) {
+  (void)env;
+  (void)left;
+  if (right == ORG_TAG_SMALL_INT(0))
+    return right;
+  return org_call(a, self, ORG_UNUSED,
+                  org_sub(a, right, ORG_TAG_SMALL_INT(1)));
+}
+
+/* {right}. */
+static OrgValue block_right(Arena *a, OrgValue env, OrgValue self,
+                            OrgValue left, OrgValue right) {
+  (void)a;
+  (void)env;
+  (void)self;
+  (void)left;
+  return right;
+}
+
+/* {x}: x as the scope of the call sees it. */
+static OrgValue block_get_x(Arena *a, OrgValue env, OrgValue self,
+                            OrgValue left, OrgValue right) {
+  (void)self;
+  (void)left;
+  (void)right;
+  return org_scope_get(a, env, "x");
+}
+
+/* ========== Scopes ========== */
+
+static void test_scope_bind_get(void) {
+  TEST("scope: bind then get");
+  OrgValue s = org_scope_new(arena, ORG_UNUSED);
+  ASSERT(org_scope_bind(arena, s, "x", ORG_TAG_SMALL_INT(1)) ==
+         ORG_TAG_SMALL_INT(1));
+  ASSERT(org_scope_get(arena, s, "x") == ORG_TAG_SMALL_INT(1));
+  PASS();
+}
+
+static void test_scope_parent(void) {
+  TEST("scope: get falls back to the parent");
+  OrgValue outer = org_scope_new(arena, ORG_UNUSED);
+  OrgValue inner = org_scope_new(arena, outer);
+  org_scope_bind(arena, outer, "x", ORG_TAG_SMALL_INT(1));
+  ASSERT(org_scope_get(arena, inner, "x") == ORG_TAG_SMALL_INT(1));
+  org_scope_bind(arena, inner, "x", ORG_TAG_SMALL_INT(2));
+  ASSERT(org_scope_get(arena, inner, "x") == ORG_TAG_SMALL_INT(2));
+  ASSERT(org_scope_get(arena, outer, "x") == ORG_TAG_SMALL_INT(1));
+  PASS();
+}
+
+static void test_scope_assign(void) {
+  TEST("scope: assign rebinds where the name is bound");
+  OrgValue outer = org_scope_new(arena, ORG_UNUSED);
+  OrgValue inner = org_scope_new(arena, outer);
+  org_scope_bind(arena, outer, "x", ORG_TAG_SMALL_INT(1));
+  org_scope_assign(arena, inner, "x", ORG_TAG_SMALL_INT(2));
+  ASSERT(org_scope_get(arena, outer, "x") == ORG_TAG_SMALL_INT(2));
+  org_scope_assign(arena, inner, "y", ORG_TAG_SMALL_INT(3));
+  ASSERT(org_is_error(org_scope_get(arena, outer, "y")));
+  ASSERT(org_scope_get(arena, inner, "y") == ORG_TAG_SMALL_INT(3));
+  PASS();
+}
+
+static void test_scope_undefined(void) {
+  TEST("scope: undefined name → Error");
+  OrgValue s = org_scope_new(arena, ORG_UNUSED);
+  ASSERT(is_message(org_scope_get(arena, s, "nope"),
+                    "undefined identifier: nope"));
+  PASS();
+}
+
+/* ========== Index ========== */
+
+static void test_index_table(void) {
+  TEST("index: table entry, missing key");
+  OrgValue t = org_table_new(arena);
+  org_table_push(arena, t, ORG_TAG_SMALL_INT(10));
+  org_table_set(arena, t, str("a"), ORG_TAG_SMALL_INT(1));
+  ASSERT(org_index(arena, t, ORG_TAG_SMALL_INT(0)) == ORG_TAG_SMALL_INT(10));
+  ASSERT(org_index(arena, t, str("a")) == ORG_TAG_SMALL_INT(1));
+  ASSERT(is_message(org_index(arena, t, str("b")), "no key b"));
+  PASS();
+}
+
+static void test_index_string(void) {
+  TEST("index: codepoint of a string");
+  OrgValue c = org_index(arena, str("héllo"), ORG_TAG_SMALL_INT(1));
+  ASSERT(org_get_type(c) == ORG_TYPE_STRING);
+  ASSERT(org_string_byte_len(c) == 2);
+  ASSERT(memcmp(org_string_data(c), "é", 2) == 0);
+  PASS();
+}
+
+static void test_index_not_table(void) {
+  TEST("index: not a table → Error");
+  ASSERT(is_message(org_index(arena, ORG_TAG_SMALL_INT(5), str("a")),
+                    "5 is not a table"));
+  ASSERT(org_index(arena, ORG_ERROR, str("a")) == ORG_ERROR);
+  PASS();
+}
+
+/* ========== Calls ========== */
+
+static void test_call_block(void) {
+  TEST("call: block gets left and right");
+  OrgValue f = org_make_closure(arena, block_sub, ORG_UNUSED, 2, "sub");
+  ASSERT(org_arity(f) == 2);
+  ASSERT(org_call(arena, f, ORG_TAG_SMALL_INT(5), ORG_TAG_SMALL_INT(3)) ==
+         ORG_TAG_SMALL_INT(2));
+  PASS();
+}
+
+static void test_call_table(void) {
+  TEST("call: an argument table is passed as is");
+  /* clamp [5 7]: the arguments are right.0 and right.1 */
+  OrgValue args = org_table_new(arena);
+  org_table_push(arena, args, ORG_TAG_SMALL_INT(5));
+  org_table_push(arena, args, ORG_TAG_SMALL_INT(7));
+  OrgValue f = org_make_closure(arena, block_right, ORG_UNUSED, 1, "id");
+  OrgValue got = org_call(arena, f, ORG_UNUSED, args);
+  ASSERT(got == args);
+  ASSERT(org_table_count(got) == 2);
+  ASSERT(org_index(arena, got, ORG_TAG_SMALL_INT(1)) == ORG_TAG_SMALL_INT(7));
+  PASS();
+}
+
+static void test_call_scope(void) {
+  TEST("call: block runs over the scope it was made in");
+  OrgValue s = org_scope_new(arena, ORG_UNUSED);
+  org_scope_bind(arena, s, "x", ORG_TAG_SMALL_INT(7));
+  OrgValue f = org_make_closure(arena, block_get_x, s, 0, "get");
+  ASSERT(org_call(arena, f, ORG_UNUSED, ORG_UNUSED) == ORG_TAG_SMALL_INT(7));
+  PASS();
+}
+
+static void test_call_this(void) {
+  TEST("call: self recurses");
+  OrgValue f = org_make_closure(arena, block_down, ORG_UNUSED, 1, "down");
+  ASSERT(org_call(arena, f, ORG_UNUSED, ORG_TAG_SMALL_INT(100)) ==
+         ORG_TAG_SMALL_INT(0));
+  PASS();
+}
+
+static void test_call_depth(void) {
+  TEST("call: too deep → Error");
+  OrgValue f = org_make_closure(arena, block_down, ORG_UNUSED, 1, "down");
+  OrgValue r = org_call(arena, f, ORG_UNUSED, ORG_TAG_SMALL_INT(20000));
+  ASSERT(is_message(r, "call depth exceeds 10000"));
+  ASSERT(org_call(arena, f, ORG_UNUSED, ORG_TAG_SMALL_INT(3)) ==
+         ORG_TAG_SMALL_INT(0));
+  PASS();
+}
+
+static void test_call_not_operator(void) {
+  TEST("call: not an operator → Error");
+  ASSERT(is_message(org_call(arena, ORG_TAG_SMALL_INT(1), ORG_UNUSED,
+                             ORG_TAG_SMALL_INT(2)),
+                    "1 is not an operator"));
+  ASSERT(org_call(arena, ORG_ERROR, ORG_UNUSED, ORG_UNUSED) == ORG_ERROR);
+  PASS();
+}
+
+static void test_call_builtin(void) {
+  TEST("call: builtins, infix and prefix");
+  OrgValue b = org_builtins(arena);
+  OrgValue minus = org_scope_get(arena, b, "-");
+  ASSERT(org_call(arena, minus, ORG_TAG_SMALL_INT(5), ORG_TAG_SMALL_INT(3)) ==
+         ORG_TAG_SMALL_INT(2));
+  ASSERT(org_call(arena, minus, ORG_UNUSED, ORG_TAG_SMALL_INT(3)) ==
+         ORG_TAG_SMALL_INT(-3));
+  OrgValue times = org_scope_get(arena, b, "*");
+  ASSERT(is_message(org_call(arena, times, ORG_UNUSED, ORG_TAG_SMALL_INT(3)),
+                    "* needs a left operand"));
+  OrgValue not = org_scope_get(arena, b, "!");
+  ASSERT(org_call(arena, not, ORG_UNUSED, ORG_FALSE) == ORG_TRUE);
+  ASSERT(is_message(org_call(arena, not, ORG_TRUE, ORG_FALSE),
+                    "! is a prefix operator"));
+  PASS();
+}
+
+static void test_partial(void) {
+  TEST("partial: fixes the left, or the right, operand");
+  OrgValue b = org_builtins(arena);
+  OrgValue add10 =
+      org_partial(arena, ORG_TAG_SMALL_INT(10), org_scope_get(arena, b, "+"));
+  ASSERT(org_arity(add10) == 1);
+  ASSERT(org_call(arena, add10, ORG_UNUSED, ORG_TAG_SMALL_INT(5)) ==
+         ORG_TAG_SMALL_INT(15));
+  OrgValue dbl = org_make_closure(arena, block_double, ORG_UNUSED, 1, "dbl");
+  OrgValue six = org_partial(arena, ORG_TAG_SMALL_INT(3), dbl);
+  ASSERT(org_arity(six) == 0);
+  ASSERT(org_call(arena, six, ORG_UNUSED, ORG_UNUSED) == ORG_TAG_SMALL_INT(6));
+  ASSERT(is_message(org_partial(arena, ORG_TAG_SMALL_INT(1), six),
+                    "(|> operator) takes no operand"));
+  PASS();
+}
+
+static void test_compose(void) {
+  TEST("compose: g o f");
+  OrgValue b = org_builtins(arena);
+  OrgValue dbl = org_make_closure(arena, block_double, ORG_UNUSED, 1, "dbl");
+  OrgValue neg_dbl = org_compose(arena, org_scope_get(arena, b, "-"), dbl);
+  ASSERT(org_call(arena, neg_dbl, ORG_UNUSED, ORG_TAG_SMALL_INT(4)) ==
+         ORG_TAG_SMALL_INT(-8));
+  PASS();
+}
+
+/* ========== Truth ========== */
+
+static void test_truthy(void) {
+  TEST("truthy: false, zero, empty and Errors are false");
+  ASSERT(!org_truthy(ORG_FALSE));
+  ASSERT(!org_truthy(ORG_TAG_SMALL_INT(0)));
+  ASSERT(!org_truthy(str("")));
+  ASSERT(!org_truthy(org_table_new(arena)));
+  ASSERT(!org_truthy(ORG_ERROR));
+  ASSERT(org_truthy(ORG_TRUE));
+  ASSERT(org_truthy(str("a")));
+  ASSERT(org_truth(ORG_ERROR) == ORG_ERROR);
+  ASSERT(org_truth(ORG_TAG_SMALL_INT(2)) == ORG_TRUE);
+  PASS();
+}
+
+static void test_assert(void) {
+  TEST("assert: false condition → Error");
+  ASSERT(org_assert(arena, ORG_TRUE, ORG_UNUSED, "x", "a.org", 1) ==
+         ORG_TRUE);
+  ASSERT(is_message(
+      org_assert(arena, ORG_FALSE, str("too big"), "x < 3", "a.org", 4),
+      "assertion failed at a.org:4: too big"));
+  ASSERT(is_message(
+      org_assert(arena, ORG_FALSE, ORG_UNUSED, "x < 3", "a.org", 4),
+      "assertion failed at a.org:4: x < 3"));
+  PASS();
+}
+
+/* ========== Flow ========== */
+
+static void test_arrow_map(void) {
+  TEST("arrow: operator sink maps over a table");
+  OrgValue t = org_table_new(arena);
+  org_table_push(arena, t, ORG_TAG_SMALL_INT(1));
+  org_table_push(arena, t, ORG_TAG_SMALL_INT(2));
+  org_table_set(arena, t, str("a"), ORG_TAG_SMALL_INT(3));
+  OrgValue dbl = org_make_closure(arena, block_double, ORG_UNUSED, 1, "dbl");
+  OrgValue out = org_op_arrow(arena, t, dbl);
+  ASSERT(org_table_count(out) == 3);
+  ASSERT(org_table_get(out, ORG_TAG_SMALL_INT(1)) == ORG_TAG_SMALL_INT(4));
+  ASSERT(org_table_get(out, str("a")) == ORG_TAG_SMALL_INT(6));
+  ASSERT(org_op_arrow(arena, ORG_TAG_SMALL_INT(2), dbl) ==
+         ORG_TAG_SMALL_INT(4));
+  ASSERT(is_message(org_op_arrow(arena, t, ORG_TAG_SMALL_INT(1)),
+                    "1 is not a sink"));
+  PASS();
+}
+
+static void test_resource(void) {
+  TEST("resource: primitives and unknown");
+  OrgValue s = org_module_scope(arena);
+  ASSERT(org_is_resource(org_resource_inst(arena, s, "stdout")));
+  ASSERT(is_message(org_resource_inst(arena, s, "nope"),
+                    "unknown resource @nope"));
+  char *argv[] = {"prog", "a", "b"};
+  org_set_args(arena, 3, argv);
+  ASSERT(org_table_count(org_resource_inst(arena, s, "args")) == 2);
+  PASS();
+}
+
+/* ========== Programs ========== */
+
+static OrgValue init_status(Arena *a) {
+  OrgValue module = org_module_scope(a);
+  org_scope_bind(a, module, "main", ORG_TAG_SMALL_INT(7));
+  return module;
+}
+
+static OrgValue init_args(Arena *a) {
+  OrgValue module = org_module_scope(a);
+  org_scope_bind(a, module, "main",
+                 org_partial(a, ORG_TAG_SMALL_INT(0),
+                             org_scope_get(a, module, "+")));
+  return module;
+}
+
+static OrgValue init_empty(Arena *a) { return org_module_scope(a); }
+
+static void test_run(void) {
+  TEST("run: main is the exit status");
+  ASSERT(org_run(arena, init_status, "a.org", 0, NULL) == 7);
+  ASSERT(org_run(arena, init_empty, "a.org", 0, NULL) == ORG_EXIT_NO_MAIN);
+  ASSERT(org_run(NULL, init_empty, "a.org", 0, NULL) == ORG_EXIT_OOM);
+  org_gmp_set_arena(arena);
+  PASS();
+}
+
+static void test_run_operator(void) {
+  TEST("run: an operator main gets @args");
+  char *argv[] = {"prog"};
+  /* 0 + [] is an Error: main was called with the table of @args. */
+  ASSERT(org_run(arena, init_args, "a.org", 1, argv) == ORG_EXIT_ERROR);
+  PASS();
+}
+
+int main(void) {
+  printf("=== Closure Tests ===\n");
+  setup();
+
+  /* Scopes */
+  test_scope_bind_get();
+  test_scope_parent();
+  test_scope_assign();
+  test_scope_undefined();
+
+  /* Index */
+  test_index_table();
+  test_index_string();
+  test_index_not_table();
+
+  /* Calls */
+  test_call_block();
+  test_call_table();
+  test_call_scope();
+  test_call_this();
+  test_call_depth();
+  test_call_not_operator();
+  test_call_builtin();
+  test_partial();
+  test_compose();
+
+  /* Truth */
+  test_truthy();
+  test_assert();
+
+  /* Flow */
+  test_arrow_map();
+  test_resource();
+
+  /* Programs */
+  test_run();
+  test_run_operator();
+
+  teardown();
+  printf("\n%d/%d tests passed\n", tests_passed, tests_run);
+  return tests_passed == tests_run ? 0 : 1;
+}
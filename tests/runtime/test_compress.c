@@ -0,0 +1,203 @@
+/*
+ * test_compress.c — Unit tests for gzip and zlib streams.
+ *
+ * Built by `just test-c` without zlib, where only the fallback is
+ * checked, and by `just test-c-zlib` with it:
+ *   clang -DORG_WITH_ZLIB -Wall -Wextra -g -o test_compress \
+ *       test_compress.c ../../pkg/runtime/compress/compress.c -lz
+ */
+#include "../../pkg/runtime/compress/compress.h"
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+static int tests_run = 0;
+static int tests_passed = 0;
+
+#define TEST(name)                                                             \
+  do {                                                                         \
+    tests_run++;                                                               \
+    printf("  %-50s", name);                                                   \
+  } while (0)
+
+#define PASS()                                                                 \
+  do {                                                                         \
+    tests_passed++;                                                            \
+    printf("✅\n");                                                            \
+  } while (0)
+
+#define ASSERT(cond)                                                           \
+  do {                                                                         \
+    if (!(cond)) {                                                             \
+      printf("❌ FAIL: %s (line %d)\n", #cond, __LINE__);                      \
+      return;                                                                  \
+    }                                                                          \
+  } while (0)
+
+/* A growable buffer, the wrapped stream of the tests. */
+typedef struct Buf {
+  unsigned char *data;
+  size_t len, cap;
+  int fail_after; /* writes accepted before failing; -1 for never */
+} Buf;
+
+static int buf_write(void *ctx, const void *data, size_t len) {
+  Buf *b = ctx;
+  if (b->fail_after == 0)
+    return -1;
+  if (b->fail_after > 0)
+    b->fail_after--;
+  if (b->len + len > b->cap) {
+    size_t cap = (b->len + len) * 2;
+    unsigned char *p = realloc(b->data, cap);
+    if (!p)
+      return -1;
+    b->data = p;
+    b->cap = cap;
+  }
+  memcpy(b->data + b->len, data, len);
+  b->len += len;
+  return 0;
+}
+
+static Buf new_buf(void) { return (Buf){NULL, 0, 0, -1}; }
+
+#ifdef ORG_WITH_ZLIB
+
+/* "hello\n" as written by gzip. */
+static const unsigned char hello_gz[] = {
+    0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02,
+    0x03, 0xcb, 0x48, 0xcd, 0xc9, 0xc9, 0xe7, 0x02, 0x00,
+    0x20, 0x30, 0x3a, 0x36, 0x06, 0x00, 0x00, 0x00};
+
+static int compress_all(OrgCompressFormat fmt, const void *data, size_t len,
+                        Buf *out) {
+  OrgCompressor *c =
+      org_compressor_new(fmt, ORG_COMPRESS_DEFAULT_LEVEL, buf_write, out);
+  if (!c)
+    return -1;
+  int w = org_compressor_write(c, data, len);
+  return org_compressor_close(c) < 0 || w < 0 ? -1 : 0;
+}
+
+/* Decompress data fed in pieces of step bytes. */
+static int decompress_all(OrgCompressFormat fmt, const void *data, size_t len,
+                          size_t step, Buf *out) {
+  OrgDecompressor *d = org_decompressor_new(fmt, buf_write, out);
+  if (!d)
+    return -1;
+  const unsigned char *p = data;
+  int w = 0;
+  for (size_t i = 0; i < len && w == 0; i += step)
+    w = org_decompressor_write(d, p + i, len - i < step ? len - i : step);
+  return org_decompressor_close(d) < 0 || w < 0 ? -1 : 0;
+}
+
+static void test_gzip_interop(void) {
+  TEST("gzip: reads what gzip writes");
+  Buf out = new_buf();
+  ASSERT(org_compress_available());
+  ASSERT(decompress_all(ORG_COMPRESS_GZIP, hello_gz, sizeof hello_gz, 1,
+                        &out) == 0);
+  ASSERT(out.len == 6 && memcmp(out.data, "hello\n", 6) == 0);
+  free(out.data);
+  PASS();
+}
+
+static void test_round_trip(void) {
+  TEST("round trip: gzip and zlib, large input");
+  size_t len = 1 << 20;
+  unsigned char *data = malloc(len);
+  ASSERT(data);
+  for (size_t i = 0; i < len; i++)
+    data[i] = (unsigned char)(i * 7 % 251);
+  OrgCompressFormat fmts[] = {ORG_COMPRESS_GZIP, ORG_COMPRESS_ZLIB};
+  for (int f = 0; f < 2; f++) {
+    Buf z = new_buf(), back = new_buf();
+    ASSERT(compress_all(fmts[f], data, len, &z) == 0);
+    ASSERT(z.len < len / 10);
+    ASSERT(decompress_all(fmts[f], z.data, z.len, 4093, &back) == 0);
+    ASSERT(back.len == len && memcmp(back.data, data, len) == 0);
+    free(z.data);
+    free(back.data);
+  }
+  free(data);
+  PASS();
+}
+
+static void test_gzip_members(void) {
+  TEST("gzip: concatenated members");
+  unsigned char two[2 * sizeof hello_gz];
+  memcpy(two, hello_gz, sizeof hello_gz);
+  memcpy(two + sizeof hello_gz, hello_gz, sizeof hello_gz);
+  Buf out = new_buf();
+  ASSERT(decompress_all(ORG_COMPRESS_GZIP, two, sizeof two, 5, &out) == 0);
+  ASSERT(out.len == 12 && memcmp(out.data, "hello\nhello\n", 12) == 0);
+  free(out.data);
+
+  /* A zlib stream has one member; data after it is an error. */
+  Buf z = new_buf();
+  ASSERT(compress_all(ORG_COMPRESS_ZLIB, "x", 1, &z) == 0);
+  buf_write(&z, "x", 1);
+  out = new_buf();
+  ASSERT(decompress_all(ORG_COMPRESS_ZLIB, z.data, z.len, z.len, &out) == -1);
+  free(z.data);
+  free(out.data);
+  PASS();
+}
+
+static void test_bad_streams(void) {
+  TEST("errors: corrupt, truncated, failing writer");
+  Buf out = new_buf();
+  unsigned char bad[sizeof hello_gz];
+  memcpy(bad, hello_gz, sizeof bad);
+  bad[12] ^= 0xff;
+  ASSERT(decompress_all(ORG_COMPRESS_GZIP, bad, sizeof bad, 1, &out) == -1);
+  out.len = 0;
+  ASSERT(decompress_all(ORG_COMPRESS_GZIP, hello_gz, sizeof hello_gz - 1, 1,
+                        &out) == -1);
+  out.len = 0;
+  ASSERT(decompress_all(ORG_COMPRESS_ZLIB, hello_gz, sizeof hello_gz, 1,
+                        &out) == -1);
+  ASSERT(decompress_all(ORG_COMPRESS_GZIP, "", 0, 1, &out) == -1);
+  free(out.data);
+
+  Buf failing = new_buf();
+  failing.fail_after = 0;
+  ASSERT(compress_all(ORG_COMPRESS_GZIP, "abc", 3, &failing) == -1);
+  ASSERT(decompress_all(ORG_COMPRESS_GZIP, hello_gz, sizeof hello_gz, 1,
+                        &failing) == -1);
+  ASSERT(org_compressor_new(ORG_COMPRESS_GZIP, 10, buf_write, &failing) ==
+         NULL);
+  PASS();
+}
+
+#else
+
+static void test_unavailable(void) {
+  TEST("without zlib: unavailable, constructors fail");
+  Buf out = new_buf();
+  ASSERT(!org_compress_available());
+  ASSERT(org_compressor_new(ORG_COMPRESS_GZIP, ORG_COMPRESS_DEFAULT_LEVEL,
+                            buf_write, &out) == NULL);
+  ASSERT(org_decompressor_new(ORG_COMPRESS_ZLIB, buf_write, &out) == NULL);
+  PASS();
+}
+
+#endif /* ORG_WITH_ZLIB */
+
+int main(void) {
+  printf("=== Compression Tests ===\n");
+
+#ifdef ORG_WITH_ZLIB
+  test_gzip_interop();
+  test_round_trip();
+  test_gzip_members();
+  test_bad_streams();
+#else
+  test_unavailable();
+#endif
+
+  printf("\n%d/%d tests passed\n", tests_passed, tests_run);
+  return tests_passed == tests_run ? 0 : 1;
+}
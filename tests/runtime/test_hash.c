@@ -0,0 +1,173 @@
+/*
+ * test_hash.c — Unit tests for SHA-256, MD5 and CRC-32.
+ *
+ * Compile:
+ *   clang -Wall -Wextra -g -o test_hash \
+ *       test_hash.c ../../pkg/runtime/hash/hash.c \
+ *       ../../pkg/runtime/core/values.c ../../pkg/runtime/core/arena.c \
+ *       ../../pkg/runtime/table/table.c ../../pkg/runtime/gmp/gmp_glue.c -lgmp
+ */
+#include "../../pkg/runtime/gmp/gmp_glue.h"
+#include "../../pkg/runtime/hash/hash.h"
+#include "../../pkg/runtime/table/table.h"
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+static int tests_run = 0;
+static int tests_passed = 0;
+
+#define TEST(name)                                                             \
+  do {                                                                         \
+    tests_run++;                                                               \
+    printf("  %-50s", name);                                                   \
+  } while (0)
+
+#define PASS()                                                                 \
+  do {                                                                         \
+    tests_passed++;                                                            \
+    printf("✅\n");                                                            \
+  } while (0)
+
+#define ASSERT(cond)                                                           \
+  do {                                                                         \
+    if (!(cond)) {                                                             \
+      printf("❌ FAIL: %s (line %d)\n", #cond, __LINE__);                      \
+      return;                                                                  \
+    }                                                                          \
+  } while (0)
+
+static void hex(const uint8_t *d, size_t n, char *out) {
+  for (size_t i = 0; i < n; i++)
+    sprintf(out + 2 * i, "%02x", d[i]);
+}
+
+static int sha256_is(const char *s, const char *want) {
+  OrgSha256 h;
+  uint8_t d[ORG_SHA256_SIZE];
+  char got[2 * ORG_SHA256_SIZE + 1];
+  org_sha256_init(&h);
+  org_sha256_update(&h, s, strlen(s));
+  org_sha256_final(&h, d);
+  hex(d, sizeof d, got);
+  return strcmp(got, want) == 0;
+}
+
+static int md5_is(const char *s, const char *want) {
+  OrgMd5 h;
+  uint8_t d[ORG_MD5_SIZE];
+  char got[2 * ORG_MD5_SIZE + 1];
+  org_md5_init(&h);
+  org_md5_update(&h, s, strlen(s));
+  org_md5_final(&h, d);
+  hex(d, sizeof d, got);
+  return strcmp(got, want) == 0;
+}
+
+static int is_string(OrgValue v, const char *want) {
+  return ORG_IS_PTR(v) && org_get_type(v) == ORG_TYPE_STRING &&
+         org_string_byte_len(v) == strlen(want) &&
+         memcmp(org_string_data(v), want, strlen(want)) == 0;
+}
+
+static void test_sha256(void) {
+  TEST("sha256: FIPS 180-4 test vectors");
+  ASSERT(sha256_is(
+      "", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"));
+  ASSERT(sha256_is(
+      "abc",
+      "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"));
+  ASSERT(sha256_is(
+      "abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq",
+      "248d6a61d20638b8e5c026930c3e6039a33ce45964ff2167f6ecedd419db06c1"));
+  PASS();
+}
+
+static void test_sha256_streaming(void) {
+  TEST("sha256: pieces of any size");
+  /* One million 'a', fed in pieces that straddle block boundaries. */
+  static char a[1000];
+  memset(a, 'a', sizeof a);
+  OrgSha256 h;
+  org_sha256_init(&h);
+  for (int i = 0; i < 1000; i++) {
+    size_t n = (size_t)(i % 7 + 1) * 137;
+    org_sha256_update(&h, a, n);
+    org_sha256_update(&h, a, 1000 - n);
+  }
+  uint8_t d[ORG_SHA256_SIZE];
+  char got[2 * ORG_SHA256_SIZE + 1];
+  org_sha256_final(&h, d);
+  hex(d, sizeof d, got);
+  ASSERT(strcmp(got, "cdc76e5c9914fb9281a1c7e284d73e67"
+                     "f1809a48a497200e046d39ccc7112cd0") == 0);
+  PASS();
+}
+
+static void test_md5(void) {
+  TEST("md5: RFC 1321 test vectors");
+  ASSERT(md5_is("", "d41d8cd98f00b204e9800998ecf8427e"));
+  ASSERT(md5_is("abc", "900150983cd24fb0d6963f7d28e17f72"));
+  ASSERT(md5_is("message digest", "f96b697d7cb7938d525a2f31aaf161d0"));
+  ASSERT(md5_is("1234567890123456789012345678901234567890"
+                "1234567890123456789012345678901234567890",
+                "57edf4a22be3c955ac49da2e2107b67a"));
+  PASS();
+}
+
+static void test_crc32(void) {
+  TEST("crc32: check value, incremental");
+  ASSERT(org_crc32_update(0, "", 0) == 0);
+  ASSERT(org_crc32_update(0, "123456789", 9) == 0xcbf43926);
+  uint32_t crc = org_crc32_update(0, "1234", 4);
+  ASSERT(org_crc32_update(crc, "56789", 5) == 0xcbf43926);
+  PASS();
+}
+
+static void test_org_hash(void) {
+  TEST("org_hash: hex, raw, tables of bytes, errors");
+  Arena *a = arena_new(4096);
+  org_gmp_set_arena(a);
+  OrgValue abc = org_make_string(a, "abc", 3);
+  ASSERT(is_string(org_hash(a, ORG_HASH_MD5, abc, 0),
+                   "900150983cd24fb0d6963f7d28e17f72"));
+  ASSERT(is_string(org_hash(a, ORG_HASH_CRC32,
+                            org_make_string(a, "123456789", 9), 0),
+                   "cbf43926"));
+
+  OrgValue raw =
+      org_hash(a, ORG_HASH_CRC32, org_make_string(a, "123456789", 9), 1);
+  ASSERT(org_table_count(raw) == 4);
+  ASSERT(org_table_get(raw, ORG_TAG_SMALL_INT(0)) == ORG_TAG_SMALL_INT(0xcb));
+  ASSERT(org_table_get(raw, ORG_TAG_SMALL_INT(3)) == ORG_TAG_SMALL_INT(0x26));
+
+  /* A Table of bytes hashes like the String of the same bytes. */
+  OrgValue bytes = org_table_new(a);
+  for (const char *p = "abc"; *p; p++)
+    org_table_push(a, bytes, ORG_TAG_SMALL_INT(*p));
+  ASSERT(is_string(org_hash(a, ORG_HASH_SHA256, bytes, 0),
+                   "ba7816bf8f01cfea414140de5dae2223"
+                   "b00361a396177a9cb410ff61f20015ad"));
+  ASSERT(org_table_count(org_hash(a, ORG_HASH_SHA256, bytes, 1)) == 32);
+
+  org_table_push(a, bytes, ORG_TAG_SMALL_INT(256));
+  ASSERT(org_is_error(org_hash(a, ORG_HASH_SHA256, bytes, 0)));
+  ASSERT(org_is_error(org_hash(a, ORG_HASH_MD5, ORG_TAG_SMALL_INT(1), 0)));
+  ASSERT(org_hash(a, ORG_HASH_MD5, ORG_ERROR, 0) == ORG_ERROR);
+  arena_destroy(a);
+  PASS();
+}
+
+int main(void) {
+  printf("=== Hash Tests ===\n");
+  org_gmp_init();
+
+  test_sha256();
+  test_sha256_streaming();
+  test_md5();
+  test_crc32();
+  test_org_hash();
+
+  printf("\n%d/%d tests passed\n", tests_passed, tests_run);
+  return tests_passed == tests_run ? 0 : 1;
+}
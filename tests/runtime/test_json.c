@@ -0,0 +1,191 @@
+/*
+ * test_json.c — Unit tests for JSON decoding and encoding of OrgValues.
+ *
+ * Compile:
+ *   clang -Wall -Wextra -g -o test_json \
+ *       test_json.c ../../pkg/runtime/json/json.c \
+ *       ../../pkg/runtime/core/print.c ../../pkg/runtime/core/values.c \
+ *       ../../pkg/runtime/core/arena.c ../../pkg/runtime/table/table.c \
+ *       ../../pkg/runtime/gmp/gmp_glue.c -lgmp
+ */
+#include "../../pkg/runtime/gmp/gmp_glue.h"
+#include "../../pkg/runtime/json/json.h"
+#include "../../pkg/runtime/table/table.h"
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+static int tests_run = 0;
+static int tests_passed = 0;
+
+#define TEST(name)                                                             \
+  do {                                                                         \
+    tests_run++;                                                               \
+    printf("  %-50s", name);                                                   \
+  } while (0)
+
+#define PASS()                                                                 \
+  do {                                                                         \
+    tests_passed++;                                                            \
+    printf("✅\n");                                                            \
+  } while (0)
+
+#define ASSERT(cond)                                                           \
+  do {                                                                         \
+    if (!(cond)) {                                                             \
+      printf("❌ FAIL: %s (line %d)\n", #cond, __LINE__);                      \
+      return;                                                                  \
+    }                                                                          \
+  } while (0)
+
+/* A fresh arena that also holds GMP's allocations. */
+static Arena *new_arena(void) {
+  Arena *a = arena_new(4096);
+  org_gmp_set_arena(a);
+  return a;
+}
+
+static int decode(Arena *a, const char *s, OrgValue *out) {
+  return org_json_decode(a, s, strlen(s), out);
+}
+
+/* Decode s and encode it again; compare with want. */
+static int round_trips(Arena *a, const char *s, const char *want) {
+  OrgValue v;
+  if (decode(a, s, &v) < 0)
+    return 0;
+  char *out = org_json_encode(v, NULL);
+  int ok = out && strcmp(out, want) == 0;
+  if (!ok)
+    printf("\n    got %s, want %s\n  %-50s", out ? out : "NULL", want, "");
+  free(out);
+  return ok;
+}
+
+static int is_string(OrgValue v, const char *want) {
+  return ORG_IS_PTR(v) && org_get_type(v) == ORG_TYPE_STRING &&
+         org_string_byte_len(v) == strlen(want) &&
+         memcmp(org_string_data(v), want, strlen(want)) == 0;
+}
+
+static void test_decode_scalars(void) {
+  TEST("decode: literals, integers, strings");
+  Arena *a = new_arena();
+  OrgValue v;
+  ASSERT(decode(a, " true ", &v) == 0 && ORG_IS_TRUE(v));
+  ASSERT(decode(a, "false", &v) == 0 && ORG_IS_FALSE(v));
+  ASSERT(decode(a, "null", &v) == 0 && ORG_IS_UNUSED(v));
+  ASSERT(decode(a, "-42", &v) == 0 && v == ORG_TAG_SMALL_INT(-42));
+  ASSERT(decode(a, "123456789012345678901234567890", &v) == 0 &&
+         ORG_IS_PTR(v) && org_get_type(v) == ORG_TYPE_BIGINT);
+  ASSERT(decode(a, "\"a\\\"b\\\\c\\n\"", &v) == 0 && is_string(v, "a\"b\\c\n"));
+  ASSERT(decode(a, "\"caf\\u00e9 \\ud83d\\ude00\"", &v) == 0 &&
+         is_string(v, "caf\xc3\xa9 \xf0\x9f\x98\x80"));
+  ASSERT(org_string_codepoint_len(v) == 6);
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_decode_decimals(void) {
+  TEST("decode: fractions, exponents are exact Decimals");
+  Arena *a = new_arena();
+  OrgValue v;
+  ASSERT(decode(a, "1.50", &v) == 0 && org_is_decimal(v));
+  ASSERT(mpq_cmp_si(*org_get_decimal(v), 3, 2) == 0);
+  ASSERT(org_get_decimal_scale(v) == 2);
+  ASSERT(decode(a, "1e-3", &v) == 0 && org_is_decimal(v));
+  ASSERT(mpq_cmp_si(*org_get_decimal(v), 1, 1000) == 0);
+  ASSERT(decode(a, "-2.5E2", &v) == 0 && org_is_decimal(v));
+  ASSERT(mpq_cmp_si(*org_get_decimal(v), -250, 1) == 0);
+  ASSERT(org_get_decimal_scale(v) == 0);
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_decode_containers(void) {
+  TEST("decode: arrays and objects become tables");
+  Arena *a = new_arena();
+  OrgValue v;
+  ASSERT(decode(a, "{\"method\": \"add\", \"params\": [1, [], {}]}", &v) == 0);
+  ASSERT(is_string(org_table_get_cstr(v, "method"), "add"));
+  OrgValue params = org_table_get_cstr(v, "params");
+  ASSERT(org_table_count(params) == 3);
+  ASSERT(org_table_get(params, ORG_TAG_SMALL_INT(0)) == ORG_TAG_SMALL_INT(1));
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_decode_rejects(void) {
+  TEST("decode: invalid JSON is rejected");
+  Arena *a = new_arena();
+  OrgValue v;
+  const char *bad[] = {"",        "01",          "1.",      "-",
+                       "[1,]",    "{\"a\" 1}",   "{1: 2}",  "\"\\x\"",
+                       "\"abc",   "\"\\ud800\"", "tru",     "1 2",
+                       "\"\t\"",  "1e99999",     "[1 2]",   "\"\\u12\""};
+  for (size_t i = 0; i < sizeof bad / sizeof bad[0]; i++) {
+    if (decode(a, bad[i], &v) != -1) {
+      printf("\n    accepted %s\n  %-50s", bad[i], "");
+      return;
+    }
+  }
+  char deep[2 * ORG_JSON_MAX_DEPTH + 3];
+  memset(deep, '[', ORG_JSON_MAX_DEPTH + 1);
+  memset(deep + ORG_JSON_MAX_DEPTH + 1, ']', ORG_JSON_MAX_DEPTH + 1);
+  deep[2 * ORG_JSON_MAX_DEPTH + 2] = '\0';
+  ASSERT(decode(a, deep, &v) == -1);
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_round_trip(void) {
+  TEST("encode: round trips compact JSON");
+  Arena *a = new_arena();
+  ASSERT(round_trips(a, "[1, -2, true, null, \"x\"]", "[1,-2,true,null,\"x\"]"));
+  ASSERT(round_trips(a, " {\"k\" : [ ] }", "{\"k\":[]}"));
+  ASSERT(round_trips(a, "[0.050, 12.5, -0.5, 1e2]", "[0.050,12.5,-0.5,100]"));
+  ASSERT(round_trips(a, "\"tab\\tquote\\\"\\u0001\"",
+                     "\"tab\\tquote\\\"\\u0001\""));
+  ASSERT(round_trips(a, "99999999999999999999", "99999999999999999999"));
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_encode_values(void) {
+  TEST("encode: rationals, integer keys, unencodable");
+  Arena *a = new_arena();
+  char *out = org_json_encode(org_make_rational_str(a, "1", "3"), NULL);
+  ASSERT(out && strcmp(out, "\"1/3\"") == 0);
+  free(out);
+
+  OrgValue t = org_table_new(a);
+  org_table_push(a, t, ORG_TAG_SMALL_INT(7));
+  org_table_set(a, t, org_make_string(a, "k", 1), ORG_TRUE);
+  size_t len;
+  out = org_json_encode(t, &len);
+  ASSERT(out && (strcmp(out, "{\"0\":7,\"k\":true}") == 0 ||
+                 strcmp(out, "{\"k\":true,\"0\":7}") == 0));
+  ASSERT(len == strlen(out));
+  free(out);
+
+  ASSERT(org_json_encode(ORG_ERROR, NULL) == NULL);
+  org_table_push(a, t, org_make_error(a, "boom"));
+  ASSERT(org_json_encode(t, NULL) == NULL);
+  arena_destroy(a);
+  PASS();
+}
+
+int main(void) {
+  printf("=== JSON Tests ===\n");
+  org_gmp_init();
+
+  test_decode_scalars();
+  test_decode_decimals();
+  test_decode_containers();
+  test_decode_rejects();
+  test_round_trip();
+  test_encode_values();
+
+  printf("\n%d/%d tests passed\n", tests_passed, tests_run);
+  return tests_passed == tests_run ? 0 : 1;
+}
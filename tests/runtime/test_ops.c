@@ -5,7 +5,8 @@
  *   clang -Wall -Wextra -g -Ipkg/runtime -o test_ops \
  *       tests/runtime/test_ops.c \
  *       pkg/runtime/core/arena.c pkg/runtime/core/values.c \
- *       pkg/runtime/gmp/gmp_glue.c pkg/runtime/ops/ops.c -lgmp
+ *       pkg/runtime/core/print.c pkg/runtime/gmp/gmp_glue.c \
+ *       pkg/runtime/ops/ops.c pkg/runtime/table/table.c -lgmp
  */
 #include "../../pkg/runtime/gmp/gmp_glue.h"
 #include "../../pkg/runtime/ops/ops.h"
@@ -856,6 +857,41 @@ static void test_add_non_numeric(void) {
   PASS();
 }
 
+/* ========== Quantities ========== */
+
+static void test_quantity_amount(void) {
+  TEST("quantity: amount in seconds or bytes");
+  OrgValue ms = org_make_quantity(arena, "250ms");
+  ASSERT(org_is_quantity(ms));
+  ASSERT(ORG_GET_PTR(ms)->flags == ORG_QUANTITY_DURATION);
+  OrgValue a = org_amount(ms);
+  ASSERT(org_is_rational(a));
+  ASSERT(mpq_cmp_si(*org_get_rational(a), 1, 4) == 0);
+  OrgValue kib = org_make_quantity(arena, "1.5KiB");
+  ASSERT(ORG_GET_PTR(kib)->flags == ORG_QUANTITY_SIZE);
+  ASSERT(org_amount(kib) == ORG_TAG_SMALL_INT(1536));
+  ASSERT(org_amount(ORG_TAG_SMALL_INT(7)) == ORG_TAG_SMALL_INT(7));
+  ASSERT(org_is_error(org_make_quantity(arena, "5sec")));
+  PASS();
+}
+
+static void test_quantity_ops(void) {
+  TEST("quantity: operators read the amount");
+  OrgValue s = org_make_quantity(arena, "1s");
+  OrgValue sum = org_add(arena, s, org_make_quantity(arena, "500ms"));
+  ASSERT(org_is_rational(sum));
+  ASSERT(mpq_cmp_si(*org_get_rational(sum), 3, 2) == 0);
+  ASSERT(ORG_IS_TRUE(org_eq(arena, s, org_make_quantity(arena, "1000ms"))));
+  ASSERT(ORG_IS_TRUE(org_gt(arena, org_make_quantity(arena, "1KiB"),
+                            org_make_quantity(arena, "1KB"))));
+  ASSERT(org_mul(arena, org_make_quantity(arena, "2m"),
+                 ORG_TAG_SMALL_INT(2)) == ORG_TAG_SMALL_INT(240));
+  ASSERT(org_neg(arena, s) == ORG_TAG_SMALL_INT(-1));
+  ASSERT(org_truthy(s));
+  ASSERT(!org_truthy(org_make_quantity(arena, "0B")));
+  PASS();
+}
+
 int main(void) {
   printf("=== Ops Tests ===\n");
   setup();
@@ -966,6 +1002,10 @@ int main(void) {
   test_error_propagation();
   test_add_non_numeric();
 
+  /* Quantities */
+  test_quantity_amount();
+  test_quantity_ops();
+
   teardown();
   printf("\n%d/%d tests passed\n", tests_passed, tests_run);
   return tests_passed == tests_run ? 0 : 1;
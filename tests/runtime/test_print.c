@@ -0,0 +1,336 @@
+/*
+ * test_print.c — Unit tests for writing values (format-string safety).
+ *
+ * Compile:
+ *   clang -Wall -Wextra -g -o test_print \
+ *       test_print.c ../../pkg/runtime/core/print.c \
+ *       ../../pkg/runtime/core/values.c ../../pkg/runtime/core/arena.c \
+ *       ../../pkg/runtime/table/table.c -lgmp
+ */
+#include "../../pkg/runtime/core/print.h"
+#include "../../pkg/runtime/core/status.h"
+#include "../../pkg/runtime/table/table.h"
+#include <stdio.h>
+#include <string.h>
+#include <unistd.h>
+
+static int tests_run = 0;
+static int tests_passed = 0;
+
+#define TEST(name)                                                             \
+  do {                                                                         \
+    tests_run++;                                                               \
+    printf("  %-50s", name);                                                   \
+  } while (0)
+
+#define PASS()                                                                 \
+  do {                                                                         \
+    tests_passed++;                                                            \
+    printf("✅\n");                                                            \
+  } while (0)
+
+#define ASSERT(cond)                                                           \
+  do {                                                                         \
+    if (!(cond)) {                                                             \
+      printf("❌ FAIL: %s (line %d)\n", #cond, __LINE__);                      \
+      return;                                                                  \
+    }                                                                          \
+  } while (0)
+
+/* Read what was written to the pipe into buf (NUL-terminated). */
+static size_t drain(int fd, char *buf, size_t cap) {
+  ssize_t n = read(fd, buf, cap - 1);
+  if (n < 0)
+    n = 0;
+  buf[n] = '\0';
+  return (size_t)n;
+}
+
+static const char hostile[] = "100%% done %s %n %x%x%x";
+
+static void test_write_string_format_chars(void) {
+  TEST("write string: % sequences are not interpreted");
+  int p[2];
+  ASSERT(pipe(p) == 0);
+  Arena *a = arena_new(4096);
+  OrgValue s = org_make_string(a, hostile, strlen(hostile));
+  ASSERT(org_write_string(p[1], s) == 0);
+  char buf[128];
+  size_t n = drain(p[0], buf, sizeof buf);
+  ASSERT(n == strlen(hostile));
+  ASSERT(strcmp(buf, hostile) == 0);
+  arena_destroy(a);
+  close(p[0]);
+  close(p[1]);
+  PASS();
+}
+
+static void test_write_string_rejects_non_string(void) {
+  TEST("write string: non-string values are rejected");
+  ASSERT(org_write_string(1, ORG_TAG_SMALL_INT(1)) == -1);
+  ASSERT(org_write_string(1, ORG_ERROR) == -1);
+  PASS();
+}
+
+static void test_write_error_message(void) {
+  TEST("write error: message with % sequences");
+  int p[2];
+  ASSERT(pipe(p) == 0);
+  Arena *a = arena_new(4096);
+  ASSERT(org_write_error(p[1], org_make_error(a, hostile)) == 0);
+  char buf[128];
+  drain(p[0], buf, sizeof buf);
+  ASSERT(strcmp(buf, "Error: 100%% done %s %n %x%x%x\n") == 0);
+  arena_destroy(a);
+  close(p[0]);
+  close(p[1]);
+  PASS();
+}
+
+static void test_write_error_singleton(void) {
+  TEST("write error: bare Error");
+  int p[2];
+  ASSERT(pipe(p) == 0);
+  ASSERT(org_write_error(p[1], ORG_ERROR) == 0);
+  char buf[32];
+  drain(p[0], buf, sizeof buf);
+  ASSERT(strcmp(buf, "Error\n") == 0);
+  close(p[0]);
+  close(p[1]);
+  PASS();
+}
+
+static void test_operand_error_block_name(void) {
+  TEST("operand error: % in block name kept verbatim");
+  Arena *a = arena_new(4096);
+  OrgValue e = org_operand(a, ORG_UNUSED, "left", "%s%n");
+  ASSERT(strcmp(org_error_message(e), "block %s%n expects a left operand") ==
+         0);
+  arena_destroy(a);
+  PASS();
+}
+
+/* Write v with org_write_value into buf (NUL-terminated). */
+static int value_text(OrgValue v, char *buf, size_t cap) {
+  int p[2];
+  if (pipe(p) != 0)
+    return -1;
+  int rc = org_write_value(p[1], v);
+  close(p[1]);
+  drain(p[0], buf, cap);
+  close(p[0]);
+  return rc;
+}
+
+static void test_write_value_numbers(void) {
+  TEST("write value: numbers in source notation");
+  Arena *a = arena_new(4096);
+  char buf[128];
+  ASSERT(value_text(ORG_TAG_SMALL_INT(-42), buf, sizeof buf) == 0);
+  ASSERT(strcmp(buf, "-42") == 0);
+  OrgValue big = org_make_bigint_str(a, "123456789012345678901234567890");
+  value_text(big, buf, sizeof buf);
+  mpz_clear(*org_get_bigint(big));
+  ASSERT(strcmp(buf, "123456789012345678901234567890") == 0);
+  OrgValue third = org_make_rational_str(a, "2", "6");
+  value_text(third, buf, sizeof buf);
+  mpq_clear(*org_get_rational(third));
+  ASSERT(strcmp(buf, "1/3") == 0);
+  OrgValue pi = org_make_decimal_str(a, "3.14");
+  value_text(pi, buf, sizeof buf);
+  mpq_clear(*org_get_decimal(pi));
+  ASSERT(strcmp(buf, "3.14") == 0);
+  OrgValue small = org_make_decimal_str(a, "-0.05");
+  value_text(small, buf, sizeof buf);
+  mpq_clear(*org_get_decimal(small));
+  ASSERT(strcmp(buf, "-0.05") == 0);
+  value_text(ORG_TRUE, buf, sizeof buf);
+  ASSERT(strcmp(buf, "true") == 0);
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_write_value_decimal_rounding(void) {
+  TEST("write value: decimals round to their scale");
+  Arena *a = arena_new(4096);
+  char buf[64];
+  OrgValue d = org_make_decimal_str(a, "0.66");
+  mpq_set_ui(*org_get_decimal(d), 2, 3); /* 2/3 at scale 2 */
+  value_text(d, buf, sizeof buf);
+  int up = strcmp(buf, "0.67") == 0;
+  mpq_set_si(*org_get_decimal(d), -1, 8);
+  value_text(d, buf, sizeof buf);
+  int away = strcmp(buf, "-0.13") == 0;
+  mpq_clear(*org_get_decimal(d));
+  ASSERT(up);
+  ASSERT(away);
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_write_value_table(void) {
+  TEST("write value: table with nested strings quoted");
+  Arena *a = arena_new(4096);
+  OrgValue t = org_table_new(a);
+  org_table_push(a, t, ORG_TAG_SMALL_INT(1));
+  org_table_push(a, t, org_make_string(a, "two", 3));
+  OrgValue inner = org_table_new(a);
+  org_table_push(a, inner, ORG_FALSE);
+  org_table_push(a, t, inner);
+  char buf[64];
+  ASSERT(value_text(t, buf, sizeof buf) == 0);
+  ASSERT(strcmp(buf, "[1 \"two\" [false]]") == 0);
+  arena_destroy(a);
+  PASS();
+}
+
+static int is_text(OrgValue s, const char *want) {
+  return org_get_type(s) == ORG_TYPE_STRING &&
+         org_string_byte_len(s) == strlen(want) &&
+         memcmp(org_string_data(s), want, strlen(want)) == 0;
+}
+
+static void test_display_concat(void) {
+  TEST("display, concat: values as text, strings raw");
+  Arena *a = arena_new(4096);
+  OrgValue t = org_table_new(a);
+  org_table_push(a, t, org_make_string(a, "x", 1));
+  ASSERT(is_text(org_display(a, t), "[\"x\"]"));
+  ASSERT(is_text(org_display(a, org_make_quantity(a, "1.5h")), "1.5h"));
+  ASSERT(is_text(org_display(a, org_make_break(a, t)), "(break [\"x\"])"));
+  OrgValue s = org_concat(a, org_make_string(a, "n = ", 4),
+                          ORG_TAG_SMALL_INT(42));
+  ASSERT(is_text(s, "n = 42"));
+  ASSERT(is_text(org_concat(a, s, org_make_string(a, "!", 1)), "n = 42!"));
+  ASSERT(org_concat(a, s, ORG_ERROR) == ORG_ERROR);
+  OrgValue empty = org_make_string(a, "", 0);
+  ASSERT(is_text(org_concat(a, empty, empty), ""));
+  arena_destroy(a);
+  PASS();
+}
+
+/* Run org_finish with stdout and stderr redirected to pipes. */
+static int finish_io(OrgValue result, char *out, char *err, size_t cap) {
+  int po[2], pe[2];
+  if (pipe(po) != 0 || pipe(pe) != 0)
+    return -1;
+  fflush(stdout);
+  int saved_out = dup(STDOUT_FILENO), saved_err = dup(STDERR_FILENO);
+  dup2(po[1], STDOUT_FILENO);
+  dup2(pe[1], STDERR_FILENO);
+  int status = org_finish(result);
+  dup2(saved_out, STDOUT_FILENO);
+  dup2(saved_err, STDERR_FILENO);
+  close(saved_out);
+  close(saved_err);
+  close(po[1]);
+  close(pe[1]);
+  drain(po[0], out, cap);
+  drain(pe[0], err, cap);
+  close(po[0]);
+  close(pe[0]);
+  return status;
+}
+
+static int finish_captured(OrgValue result, char *buf, size_t cap) {
+  char out[256];
+  return finish_io(result, out, buf, cap < sizeof out ? cap : sizeof out);
+}
+
+static void test_finish_ok(void) {
+  TEST("finish: other results exit 0 silently");
+  Arena *a = arena_new(4096);
+  char buf[64];
+  ASSERT(finish_captured(ORG_FALSE, buf, sizeof buf) == ORG_EXIT_OK);
+  ASSERT(buf[0] == '\0');
+  ASSERT(finish_captured(org_make_string(a, "done", 4), buf, sizeof buf) ==
+         ORG_EXIT_OK);
+  ASSERT(buf[0] == '\0');
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_finish_integer_status(void) {
+  TEST("finish: Integer result is the exit status");
+  Arena *a = arena_new(4096);
+  char buf[128];
+  ASSERT(finish_captured(ORG_TAG_SMALL_INT(0), buf, sizeof buf) == 0);
+  ASSERT(finish_captured(ORG_TAG_SMALL_INT(42), buf, sizeof buf) == 42);
+  ASSERT(buf[0] == '\0');
+  ASSERT(finish_captured(ORG_TAG_SMALL_INT(255), buf, sizeof buf) == 255);
+  ASSERT(finish_captured(ORG_TAG_SMALL_INT(256), buf, sizeof buf) ==
+         ORG_EXIT_ERROR);
+  ASSERT(strcmp(buf, "Error: exit status 256 out of range 0-255\n") == 0);
+  ASSERT(finish_captured(ORG_TAG_SMALL_INT(-1), buf, sizeof buf) ==
+         ORG_EXIT_ERROR);
+  OrgValue big = org_make_bigint_str(a, "99999999999999999999999");
+  int status = finish_captured(big, buf, sizeof buf);
+  mpz_clear(*org_get_bigint(big));
+  ASSERT(status == ORG_EXIT_ERROR);
+  ASSERT(strcmp(buf, "Error: exit status 99999999999999999999999 out of range "
+                     "0-255\n") == 0);
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_finish_table_printed(void) {
+  TEST("finish: Table result printed one element per line");
+  Arena *a = arena_new(4096);
+  OrgValue t = org_table_new(a);
+  org_table_push(a, t, org_make_string(a, "100% done %n", 12));
+  org_table_push(a, t, ORG_TAG_SMALL_INT(7));
+  org_table_set(a, t, org_make_string(a, "key", 3), ORG_TRUE);
+  char out[128], err[128];
+  ASSERT(finish_io(t, out, err, sizeof out) == ORG_EXIT_OK);
+  ASSERT(strcmp(out, "100% done %n\n7\n") == 0);
+  ASSERT(err[0] == '\0');
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_finish_uncaught_error(void) {
+  TEST("finish: uncaught Error exits 1 and reports it");
+  Arena *a = arena_new(4096);
+  char buf[128];
+  OrgValue e = org_make_error(a, "block add expects a left operand");
+  ASSERT(finish_captured(e, buf, sizeof buf) == ORG_EXIT_ERROR);
+  ASSERT(strcmp(buf, "Error: block add expects a left operand\n") == 0);
+  ASSERT(finish_captured(ORG_ERROR, buf, sizeof buf) == ORG_EXIT_ERROR);
+  ASSERT(strcmp(buf, "Error\n") == 0);
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_exit_codes_distinct(void) {
+  TEST("exit codes are distinct");
+  int codes[] = {ORG_EXIT_OK, ORG_EXIT_ERROR, ORG_EXIT_NO_MAIN, ORG_EXIT_OOM,
+                 ORG_EXIT_RESOURCE};
+  size_t n = sizeof codes / sizeof codes[0];
+  for (size_t i = 0; i < n; i++)
+    for (size_t j = i + 1; j < n; j++)
+      ASSERT(codes[i] != codes[j]);
+  PASS();
+}
+
+int main(void) {
+  printf("=== Print Tests ===\n");
+
+  test_write_string_format_chars();
+  test_write_string_rejects_non_string();
+  test_write_error_message();
+  test_write_error_singleton();
+  test_operand_error_block_name();
+  test_write_value_numbers();
+  test_write_value_decimal_rounding();
+  test_write_value_table();
+  test_display_concat();
+
+  test_finish_ok();
+  test_finish_integer_status();
+  test_finish_table_printed();
+  test_finish_uncaught_error();
+  test_exit_codes_distinct();
+
+  printf("\n%d/%d tests passed\n", tests_passed, tests_run);
+  return tests_passed == tests_run ? 0 : 1;
+}
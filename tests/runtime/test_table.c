@@ -5,7 +5,8 @@
  *   clang -Wall -Wextra -g -Ipkg/runtime -o test_table \
  *       tests/runtime/test_table.c \
  *       pkg/runtime/core/arena.c pkg/runtime/core/values.c \
- *       pkg/runtime/gmp/gmp_glue.c pkg/runtime/table/table.c -lgmp
+ *       pkg/runtime/core/print.c pkg/runtime/gmp/gmp_glue.c \
+ *       pkg/runtime/table/table.c -lgmp
  */
 #include "../../pkg/runtime/gmp/gmp_glue.h"
 #include "../../pkg/runtime/table/table.h"
@@ -194,9 +195,21 @@ static void test_get_not_found(void) {
 static void test_invalid_key(void) {
   TEST("table: invalid key type → Error");
   OrgValue t = org_table_new(arena);
-  /* Boolean is not a valid table key */
-  ASSERT(ORG_IS_ERROR(org_table_set(arena, t, ORG_TRUE, ORG_TAG_SMALL_INT(1))));
-  ASSERT(ORG_IS_ERROR(org_table_get(t, ORG_TRUE)));
+  /* A decimal is not a valid table key */
+  OrgValue key = org_make_decimal_str(arena, "1.5");
+  ASSERT(ORG_IS_ERROR(org_table_set(arena, t, key, ORG_TAG_SMALL_INT(1))));
+  ASSERT(ORG_IS_ERROR(org_table_get(t, key)));
+  PASS();
+}
+
+static void test_bool_key(void) {
+  TEST("table: set/get with boolean key");
+  OrgValue t = org_table_new(arena);
+  org_table_set(arena, t, ORG_TRUE, ORG_TAG_SMALL_INT(1));
+  org_table_set(arena, t, ORG_FALSE, ORG_TAG_SMALL_INT(0));
+  ASSERT(org_table_get(t, ORG_TRUE) == ORG_TAG_SMALL_INT(1));
+  ASSERT(org_table_get(t, ORG_FALSE) == ORG_TAG_SMALL_INT(0));
+  ASSERT(org_table_count(t) == 2);
   PASS();
 }
 
@@ -387,6 +400,7 @@ int main(void) {
 
   /* Invalid operations */
   test_invalid_key();
+  test_bool_key();
   test_get_non_table();
   test_get_cstr_non_table();
 
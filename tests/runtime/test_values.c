@@ -202,6 +202,71 @@ static void test_string_empty(void) {
   PASS();
 }
 
+/* ---- Error Tests ---- */
+
+static void test_error_object(void) {
+  TEST("error: object with message");
+  Arena *a = arena_new(4096);
+  OrgValue e = org_make_error(a, "division by zero");
+  ASSERT(ORG_IS_PTR(e));
+  ASSERT(org_is_error(e));
+  ASSERT(!ORG_IS_ERROR(e));
+  ASSERT(strcmp(org_error_message(e), "division by zero") == 0);
+  ASSERT(org_is_error(ORG_ERROR));
+  ASSERT(strcmp(org_error_message(ORG_ERROR), "") == 0);
+  ASSERT(!org_is_error(ORG_UNUSED));
+  ASSERT(!org_is_error(ORG_TAG_SMALL_INT(0)));
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_operand_present(void) {
+  TEST("operand: present operand passes through");
+  Arena *a = arena_new(4096);
+  OrgValue v = ORG_TAG_SMALL_INT(7);
+  ASSERT(org_operand(a, v, "left", "add") == v);
+  ASSERT(org_operand(a, ORG_ERROR, "right", "add") == ORG_ERROR);
+  arena_destroy(a);
+  PASS();
+}
+
+static void test_operand_missing(void) {
+  TEST("operand: missing operand becomes an Error");
+  Arena *a = arena_new(4096);
+  /* add : { left + right }; add 1  -- infix block used as prefix */
+  OrgValue left = org_operand(a, ORG_UNUSED, "left", "add");
+  ASSERT(org_is_error(left));
+  ASSERT(strcmp(org_error_message(left), "block add expects a left operand") ==
+         0);
+  /* sq : { right * right }; 2 sq  -- prefix block missing its operand */
+  OrgValue right = org_operand(a, ORG_UNUSED, "right", "sq");
+  ASSERT(strcmp(org_error_message(right),
+                "block sq expects a right operand") == 0);
+  OrgValue anon = org_operand(a, ORG_UNUSED, "left", NULL);
+  ASSERT(strcmp(org_error_message(anon),
+                "block <anonymous> expects a left operand") == 0);
+  arena_destroy(a);
+  PASS();
+}
+
+/* ---- Break Tests ---- */
+
+static void test_break(void) {
+  TEST("break: wraps the value ending a loop");
+  Arena *a = arena_new(4096);
+  OrgValue b = org_make_break(a, ORG_TAG_SMALL_INT(5));
+  ASSERT(org_is_break(b));
+  ASSERT(org_break_value(b) == ORG_TAG_SMALL_INT(5));
+  ASSERT(!org_is_break(ORG_TAG_SMALL_INT(5)));
+  ASSERT(!org_is_break(ORG_UNUSED));
+  ASSERT(strcmp(org_type_name(b), "Break") == 0);
+  /* break (1 / 0) is the Error, not a break of it */
+  OrgValue e = org_make_error(a, "division by zero");
+  ASSERT(org_make_break(a, e) == e);
+  arena_destroy(a);
+  PASS();
+}
+
 /* ---- Type Name Tests ---- */
 
 static void test_type_name(void) {
@@ -254,6 +319,11 @@ int main(void) {
   test_string_utf8_emoji();
   test_string_empty();
 
+  test_error_object();
+  test_operand_present();
+  test_operand_missing();
+  test_break();
+
   test_type_name();
   test_pointer_alignment();
 